@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package announce
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/clock"
+)
+
+const (
+	// DefaultLevel is the RMS level, in dBFS, prompts are played at when
+	// the live stream is too quiet to match.
+	DefaultLevel = -20.0
+
+	// MaxPromptDuration bounds the audio taken from one prompt, which is
+	// decoded into memory before it plays.
+	MaxPromptDuration = 5 * time.Minute
+
+	// levelWindow is the time constant of the live level measurement
+	levelWindow = 3 * time.Second
+
+	// silenceLevel is the RMS level, in dBFS, below which the live stream
+	// is taken as silent
+	silenceLevel = -60.0
+)
+
+// Prompt opens the audio of an announcement. It is called every time the
+// announcement is due, as a Source can only be played once.
+type Prompt func() (audio.Source, error)
+
+// Options configures an Announcer.
+type Options struct {
+	// Clock tells when announcements are due. Defaults to clock.Real().
+	Clock clock.Clock
+
+	// Level is the RMS level, in dBFS, prompts are played at. 0 matches
+	// the level of the live stream over the last few seconds, falling back
+	// to DefaultLevel when it is silent.
+	Level float64
+}
+
+// Announcer is an audio.Source that passes a live stream through and
+// replaces it with prompts when they are due, such as "your call is
+// important to us" every minute over hold music.
+//
+// The live stream keeps being read while a prompt plays, so the output
+// stays in step with it. Prompts are resampled and remixed to the format
+// of the live stream and scaled to its level, never beyond full scale.
+// Prompts due while another plays follow it.
+//
+// A failing prompt never breaks the stream: it is skipped and its error
+// kept for Err. Add is safe to call from any goroutine while another one
+// reads.
+type Announcer struct {
+	live   audio.Source
+	format audio.Format
+	clk    clock.Clock
+	level  float64
+
+	mu         *sync.Mutex
+	entries    []*entry
+	due        []Prompt
+	playing    []float32
+	meanSquare float64 // of the live stream
+	msCoeff    float64
+	err        error
+	closed     bool
+}
+
+type entry struct {
+	sched  Schedule
+	prompt Prompt
+	next   time.Time
+}
+
+// New creates an Announcer over live.
+func New(live audio.Source, opts Options) (*Announcer, error) {
+	format := audio.FormatOf(live)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+
+	return &Announcer{
+		live:    live,
+		format:  format,
+		clk:     opts.Clock,
+		level:   opts.Level,
+		mu:      &sync.Mutex{},
+		msCoeff: 1 / (levelWindow.Seconds() * float64(format.Rate*format.Channels)),
+	}, nil
+}
+
+func (a *Announcer) SampleRate() int      { return a.format.Rate }
+func (a *Announcer) Channels() int        { return a.format.Channels }
+func (a *Announcer) BufSize() int         { return a.live.BufSize() }
+func (a *Announcer) Format() audio.Format { return a.format }
+
+// Add plays prompt on sched, first at sched.Next of the current time.
+func (a *Announcer) Add(sched Schedule, prompt Prompt) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return ErrAnnouncerClosed
+	}
+
+	a.entries = append(a.entries, &entry{sched: sched, prompt: prompt, next: sched.Next(a.clk.Now())})
+	return nil
+}
+
+// Playing reports whether a prompt is playing.
+func (a *Announcer) Playing() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return len(a.playing) > 0
+}
+
+// Err returns the error of the last prompt that failed, if any.
+func (a *Announcer) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.err
+}
+
+// ReadSamples reads the live stream into dst, overwritten by the playing
+// prompt. Due prompts are opened and decoded here, before the live stream
+// is read.
+func (a *Announcer) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%a.format.Channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, io.EOF
+	}
+
+	now := a.clk.Now()
+	for _, e := range a.entries {
+		if !e.next.IsZero() && !now.Before(e.next) {
+			a.due = append(a.due, e.prompt)
+			e.next = e.sched.Next(now)
+		}
+	}
+	for len(a.playing) == 0 && len(a.due) > 0 {
+		prompt := a.due[0]
+		a.due = a.due[1:]
+		samples, err := a.prepare(prompt)
+		if err != nil {
+			a.err = fmt.Errorf("announcement: %w", err)
+			continue
+		}
+		a.playing = samples
+	}
+
+	n, err := a.live.ReadSamples(dst)
+	for _, v := range dst[:n] {
+		a.meanSquare += (float64(v)*float64(v) - a.meanSquare) * a.msCoeff
+	}
+	if len(a.playing) > 0 {
+		m := copy(dst[:n], a.playing)
+		a.playing = a.playing[m:]
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("live stream: %w", err)
+	}
+	return n, err
+}
+
+// prepare decodes prompt in the live format at the playing level
+func (a *Announcer) prepare(prompt Prompt) ([]float32, error) {
+	src, err := prompt()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var s audio.Source = src
+	if src.SampleRate() != a.format.Rate {
+		s = audio.Resample(src, a.format.Rate)
+	}
+
+	ch := s.Channels()
+	if ch <= 0 {
+		return nil, fmt.Errorf("%w: %d", audio.ErrInvalidChannels, ch)
+	}
+	limit := audio.FrameLen(a.format.Rate, MaxPromptDuration) * ch
+	var samples []float32
+	buf := make([]float32, 4096-4096%ch)
+	for len(samples) < limit {
+		n, err := s.ReadSamples(buf)
+		samples = append(samples, buf[:n-n%ch]...)
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	samples = remix(samples[:min(len(samples), limit)], ch, a.format.Channels)
+
+	var sum, peak float64
+	for _, v := range samples {
+		sum += float64(v) * float64(v)
+		peak = max(peak, math.Abs(float64(v)))
+	}
+	if peak == 0 {
+		return samples, nil
+	}
+
+	level := a.level
+	if level == 0 {
+		level = 10 * math.Log10(max(a.meanSquare, 1e-20))
+		if level < silenceLevel {
+			level = DefaultLevel
+		}
+	}
+	rms := math.Sqrt(sum / float64(len(samples)))
+	gain := float32(min(math.Pow(10, level/20)/rms, 1/peak))
+	for i := range samples {
+		samples[i] *= gain
+	}
+	return samples, nil
+}
+
+// remix converts interleaved samples from one channel count to another,
+// copying mono to every channel and averaging down otherwise
+func remix(samples []float32, from, to int) []float32 {
+	if from == to {
+		return samples
+	}
+
+	frames := len(samples) / from
+	out := make([]float32, frames*to)
+	for f := range frames {
+		var sum float32
+		for _, v := range samples[f*from : (f+1)*from] {
+			sum += v
+		}
+		for c := range to {
+			out[f*to+c] = sum / float32(from)
+		}
+	}
+	return out
+}
+
+// Close closes the live stream. Prompts that have not played are dropped.
+func (a *Announcer) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	a.playing, a.due, a.entries = nil, nil, nil
+
+	if err := a.live.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
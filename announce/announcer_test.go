@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package announce
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+	"github.com/ik5/audpbx/clock"
+)
+
+var epoch = time.Date(2024, time.January, 10, 10, 0, 0, 0, time.UTC)
+
+// constantPrompt opens a constant prompt of the given format and length
+func constantPrompt(rate, channels int, d time.Duration, value float32) Prompt {
+	return func() (audio.Source, error) {
+		return audiotest.NewConstantSource(rate, channels, audio.FrameLen(rate, d), value), nil
+	}
+}
+
+// play reads d of a in 10 ms blocks, advancing fc by the audio read
+func play(t *testing.T, a *Announcer, fc *clock.Fake, d time.Duration) []float32 {
+	t.Helper()
+
+	block := audio.FrameLen(a.SampleRate(), 10*time.Millisecond) * a.Channels()
+	var out []float32
+	buf := make([]float32, block)
+	for range d / (10 * time.Millisecond) {
+		n, err := a.ReadSamples(buf)
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+		out = append(out, buf[:n]...)
+		fc.Advance(10 * time.Millisecond)
+	}
+	return out
+}
+
+// checkLevel fails t unless samples from to to of out are all near want
+func checkLevel(t *testing.T, out []float32, from, to int, want, tolerance float64) {
+	t.Helper()
+
+	for i := from; i < to; i++ {
+		if d := math.Abs(float64(out[i]) - want); d > tolerance {
+			t.Fatalf("sample %d = %v, want %v", i, out[i], want)
+		}
+	}
+}
+
+func TestAnnouncer(t *testing.T) {
+	t.Parallel()
+
+	fc := clock.NewFake(epoch)
+	live := audiotest.NewConstantSource(8000, 1, 8000*3, 0.05)
+	a, err := New(live, Options{Clock: fc, Level: -20})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Due together, so the second follows the first. Both are converted
+	// to 8 kHz mono at -20 dBFS.
+	if err := a.Add(Every(time.Second), constantPrompt(16000, 2, 250*time.Millisecond, 0.5)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := a.Add(Every(time.Second), constantPrompt(8000, 1, 250*time.Millisecond, -0.3)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	out := play(t, a, fc, 2*time.Second)
+	if len(out) != 16000 {
+		t.Fatalf("read %d samples, want 16000", len(out))
+	}
+
+	checkLevel(t, out, 0, 8000, 0.05, 1e-6)
+	checkLevel(t, out, 8000+10, 10000-10, 0.1, 0.001)
+	checkLevel(t, out, 10000, 12000, -0.1, 0.001)
+	checkLevel(t, out, 12000, 16000, 0.05, 1e-6)
+	if a.Playing() {
+		t.Error("Playing() = true between announcements")
+	}
+
+	// Again a second later
+	out = play(t, a, fc, 200*time.Millisecond)
+	checkLevel(t, out, 10, 1600, 0.1, 0.001)
+	if !a.Playing() {
+		t.Error("Playing() = false during an announcement")
+	}
+
+	// Until the live stream ends
+	buf := make([]float32, 800)
+	for {
+		_, err := a.ReadSamples(buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+	if err := a.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestAnnouncer_Level(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		live   float32
+		level  float64
+		prompt func(sample, channel int) float32
+		want   float64 // peak of the prompt
+	}{
+		{
+			name:   "matches the live stream",
+			live:   0.2,
+			prompt: func(int, int) float32 { return 0.8 },
+			// After 10 s, 96% of the way into the 3 s level window
+			want: 0.196,
+		},
+		{
+			name:   "silent live stream",
+			prompt: func(int, int) float32 { return 0.8 },
+			want:   0.1,
+		},
+		{
+			name:  "limited to full scale",
+			live:  0.2,
+			level: -3,
+			prompt: func(sample, _ int) float32 {
+				if sample%100 == 0 {
+					return 0.5
+				}
+				return 0
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fc := clock.NewFake(epoch)
+			live := audiotest.NewConstantSource(8000, 1, 8000*11, tt.live)
+			a, err := New(live, Options{Clock: fc, Level: tt.level})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			prompt := func() (audio.Source, error) {
+				return audiotest.NewMockSource(8000, 1, 800, tt.prompt), nil
+			}
+			if err := a.Add(Every(10*time.Second), prompt); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+
+			out := play(t, a, fc, 10100*time.Millisecond)
+			var peak float64
+			for _, v := range out[80000:] {
+				peak = max(peak, math.Abs(float64(v)))
+			}
+			if math.Abs(peak-tt.want) > 0.002 {
+				t.Errorf("prompt peak = %.4f, want %.4f", peak, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnouncer_PromptError(t *testing.T) {
+	t.Parallel()
+
+	fc := clock.NewFake(epoch)
+	a, err := New(audiotest.NewConstantSource(8000, 2, 8000, 0.05), Options{Clock: fc, Level: -20})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	failing := []Prompt{
+		func() (audio.Source, error) { return nil, audiotest.ErrInjected },
+		func() (audio.Source, error) {
+			src := audiotest.NewConstantSource(8000, 1, 800, 0.5)
+			return audiotest.NewFaultySource(src, audiotest.WithErrorOnCall(1, audiotest.ErrInjected)), nil
+		},
+	}
+	for _, p := range failing {
+		if err := a.Add(Every(100*time.Millisecond), p); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := a.Add(Every(100*time.Millisecond), constantPrompt(8000, 1, 10*time.Millisecond, 0.5)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// The failures are skipped and the working prompt plays in stereo
+	out := play(t, a, fc, 200*time.Millisecond)
+	checkLevel(t, out, 0, 1600, 0.05, 1e-6)
+	checkLevel(t, out, 1600, 1760, 0.1, 0.001)
+	checkLevel(t, out, 1760, 3200, 0.05, 1e-6)
+	if err := a.Err(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Err() = %v, want %v", err, audiotest.ErrInjected)
+	}
+}
+
+func TestAnnouncer_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(audiotest.NewSilentSource(0, 1, 10), Options{}); !errors.Is(err, audio.ErrInvalidSampleRate) {
+		t.Errorf("New(0 Hz) error = %v, want %v", err, audio.ErrInvalidSampleRate)
+	}
+
+	live := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 2, 100))
+	a, err := New(live, Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := a.ReadSamples(make([]float32, 3)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(3) error = %v, want %v", err, audio.ErrInvalidDstSize)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !live.Closed() {
+		t.Error("Close() did not close the live stream")
+	}
+	if err := a.Add(Every(time.Second), constantPrompt(8000, 1, time.Second, 1)); !errors.Is(err, ErrAnnouncerClosed) {
+		t.Errorf("Add() after Close error = %v, want %v", err, ErrAnnouncerClosed)
+	}
+	if n, err := a.ReadSamples(make([]float32, 4)); n != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("ReadSamples() after Close = %d, %v, want 0, EOF", n, err)
+	}
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package announce plays recorded announcements into a live audio stream
+// on a schedule, the way a call queue breaks into its hold music.
+//
+// An Announcer wraps the live stream as an audio.Source. Prompts are added
+// with a Schedule, either a fixed interval or a cron-like specification,
+// and replace the live audio while they play:
+//
+//	a, err := announce.New(music, announce.Options{})
+//	a.Add(announce.Every(time.Minute), func() (audio.Source, error) {
+//	    f, err := os.Open("important.wav")
+//	    if err != nil {
+//	        return nil, err
+//	    }
+//	    return wav.Decoder{}.Decode(f)
+//	})
+//	sched, _ := announce.ParseSchedule("0 9-17 * * 1-5")
+//	a.Add(sched, openHoursPrompt)
+//
+// # Schedules
+//
+// ParseSchedule reads "@every 90s", "@hourly", "@daily", "@weekly",
+// "@monthly" and five field crontab lines. Cron schedules use the time
+// zone of the clock's times; Options.Clock can swap in a fake clock for
+// tests.
+//
+// # Format and Level
+//
+// Prompts do not need to match the live stream: they are resampled to its
+// rate and remixed to its channel count. Each prompt is scaled so that its
+// RMS level matches the live stream over the last few seconds, or a fixed
+// Options.Level, without clipping; a prompt over silence plays at
+// DefaultLevel.
+//
+// # Errors
+//
+// A prompt that cannot be opened or decoded is skipped, so a missing file
+// never silences the line; the error is kept for Err.
+package announce
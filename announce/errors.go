@@ -0,0 +1,10 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package announce
+
+import "errors"
+
+var (
+	ErrInvalidSchedule = errors.New("invalid announcement schedule")
+	ErrAnnouncerClosed = errors.New("announcer is closed")
+)
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package announce
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	t.Parallel()
+
+	// A Wednesday
+	from := time.Date(2024, time.January, 10, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want []time.Time // the next three times
+	}{
+		{
+			spec: "@every 90s",
+			want: []time.Time{from.Add(90 * time.Second), from.Add(180 * time.Second), from.Add(270 * time.Second)},
+		},
+		{
+			spec: "*/15 * * * *",
+			want: []time.Time{
+				time.Date(2024, time.January, 10, 10, 15, 0, 0, time.UTC),
+				time.Date(2024, time.January, 10, 10, 30, 0, 0, time.UTC),
+				time.Date(2024, time.January, 10, 10, 45, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "@hourly",
+			want: []time.Time{
+				time.Date(2024, time.January, 10, 11, 0, 0, 0, time.UTC),
+				time.Date(2024, time.January, 10, 12, 0, 0, 0, time.UTC),
+				time.Date(2024, time.January, 10, 13, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			// Office hours, skipping the weekend
+			spec: "0 9,17 * * 1-5",
+			want: []time.Time{
+				time.Date(2024, time.January, 10, 17, 0, 0, 0, time.UTC),
+				time.Date(2024, time.January, 11, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, time.January, 11, 17, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "30 8 * * 6,7",
+			want: []time.Time{
+				time.Date(2024, time.January, 13, 8, 30, 0, 0, time.UTC),
+				time.Date(2024, time.January, 14, 8, 30, 0, 0, time.UTC),
+				time.Date(2024, time.January, 20, 8, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			// Either day field matches when both are given
+			spec: "0 0 1 * 5",
+			want: []time.Time{
+				time.Date(2024, time.January, 12, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, time.January, 19, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, time.January, 26, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 12 29 2 *",
+			want: []time.Time{
+				time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC),
+				time.Date(2028, time.February, 29, 12, 0, 0, 0, time.UTC),
+				time.Date(2032, time.February, 29, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 0 30 2 *",
+			want: []time.Time{{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseSchedule() error = %v", err)
+			}
+
+			at := from
+			for i, want := range tt.want {
+				at = s.Next(at)
+				if !at.Equal(want) {
+					t.Fatalf("time %d = %v, want %v", i+1, at, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSchedule_Errors(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{
+		"",
+		"@every",
+		"@every -1s",
+		"@yearly",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+	} {
+		if _, err := ParseSchedule(spec); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("ParseSchedule(%q) error = %v, want ErrInvalidSchedule", spec, err)
+		}
+	}
+}
@@ -4,6 +4,9 @@ package audio
 
 import (
 	"io"
+	"mime"
+	"slices"
+	"strings"
 	"sync"
 )
 
@@ -27,9 +30,12 @@ type Decoder interface {
     Decode(r io.Reader) (Source, error)
 }
 
-// Registry for decoders by format key (e.g., "wav", "mp3", "ogg vorbis").
+// Registry for decoders by format key (e.g., "wav", "mp3", "ogg vorbis")
+// and by MIME type.
 type Registry struct {
     codecs map[string]Decoder
+    mimes  map[string]Decoder
+    policy Policy // nil to allow every format
 
     mtx *sync.Mutex
 }
@@ -37,6 +43,7 @@ type Registry struct {
 func NewRegistry() *Registry {
 	return &Registry{
 		codecs: make(map[string]Decoder),
+		mimes: make(map[string]Decoder),
 		mtx: &sync.Mutex{},
     }
 }
@@ -48,10 +55,90 @@ func (r *Registry) Register(format string, d Decoder) {
 	r.codecs[format] = d
 }
 
+// Get returns the decoder registered for format, and false when there is
+// none or the policy of the registry refuses it (see Lookup).
 func (r *Registry) Get(format string) (Decoder, bool) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
     d, ok := r.codecs[format]
-    return d, ok
+    if !ok || !r.allowed(format, d) {
+        return nil, false
+    }
+    return d, true
+}
+
+// Formats returns the registered format keys the policy allows, sorted.
+func (r *Registry) Formats() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	formats := make([]string, 0, len(r.codecs))
+	for f, d := range r.codecs {
+		if r.allowed(f, d) {
+			formats = append(formats, f)
+		}
+	}
+	slices.Sort(formats)
+	return formats
+}
+
+// Capabilities returns the Capabilities of the decoder registered for
+// format, and false when there is none or it does not report them.
+func (r *Registry) Capabilities(format string) (Capabilities, bool) {
+	d, ok := r.Get(format)
+	if !ok {
+		return Capabilities{}, false
+	}
+	return CapabilitiesOf(d)
+}
+
+// RegisterMIME registers d for a MIME type such as "audio/mpeg", so HTTP
+// handlers can pick the decoder from a Content-Type header. Types are
+// matched without case and parameters.
+func (r *Registry) RegisterMIME(mimeType string, d Decoder) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.mimes[mediaType(mimeType)] = d
+}
+
+// GetByMIME returns the decoder registered for the media type of
+// contentType, which may carry parameters ("audio/wav; codecs=1"), and
+// false when there is none or the policy of the registry refuses it.
+func (r *Registry) GetByMIME(contentType string) (Decoder, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	t := mediaType(contentType)
+	d, ok := r.mimes[t]
+	if !ok || !r.allowed(t, d) {
+		return nil, false
+	}
+	return d, true
+}
+
+// MIMETypes returns the registered MIME types the policy allows, sorted.
+func (r *Registry) MIMETypes() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	types := make([]string, 0, len(r.mimes))
+	for t, d := range r.mimes {
+		if r.allowed(t, d) {
+			types = append(types, t)
+		}
+	}
+	slices.Sort(types)
+	return types
+}
+
+// mediaType returns the lower case media type of a Content-Type value
+func mediaType(contentType string) string {
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return t
+	}
+	// Malformed parameters still leave a usable type
+	t, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(t))
 }
@@ -5,6 +5,7 @@ package audio
 import (
 	"errors"
 	"io"
+	"slices"
 	"testing"
 )
 
@@ -242,3 +243,63 @@ func BenchmarkRegistry_ConcurrentRegisterGet(b *testing.B) {
 		}
 	})
 }
+
+func TestRegistry_MIME(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	mp3Decoder := &mockDecoder{name: "mp3"}
+	wavDecoder := &mockDecoder{name: "wav"}
+	registry.RegisterMIME("audio/mpeg", mp3Decoder)
+	registry.RegisterMIME("Audio/WAV", wavDecoder)
+
+	tests := []struct {
+		contentType string
+		want        Decoder
+		wantOK      bool
+	}{
+		{"audio/mpeg", mp3Decoder, true},
+		{"AUDIO/MPEG", mp3Decoder, true},
+		{"audio/wav", wavDecoder, true},
+		{"audio/wav; codecs=1", wavDecoder, true},
+		{"audio/wav; codecs", wavDecoder, true},
+		{"audio/ogg", nil, false},
+		{"", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := registry.GetByMIME(tt.contentType)
+			if ok != tt.wantOK {
+				t.Errorf("Registry.GetByMIME(%q) ok = %v, want %v", tt.contentType, ok, tt.wantOK)
+			}
+			if tt.wantOK && got != tt.want {
+				t.Errorf("Registry.GetByMIME(%q) returned wrong decoder", tt.contentType)
+			}
+		})
+	}
+
+	if got, want := registry.MIMETypes(), []string{"audio/mpeg", "audio/wav"}; !slices.Equal(got, want) {
+		t.Errorf("Registry.MIMETypes() = %q, want %q", got, want)
+	}
+}
+
+func TestRegistry_Formats(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	if got := registry.Formats(); len(got) != 0 {
+		t.Errorf("Registry.Formats() = %q on an empty registry", got)
+	}
+
+	for _, f := range []string{"wav", "aiff", "mp3"} {
+		registry.Register(f, &mockDecoder{name: f})
+	}
+	registry.RegisterMIME("audio/ogg", &mockDecoder{name: "ogg"})
+
+	if got, want := registry.Formats(), []string{"aiff", "mp3", "wav"}; !slices.Equal(got, want) {
+		t.Errorf("Registry.Formats() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package audiotest provides utilities for testing code built on the audio
+// package, in the spirit of net/http/httptest and testing/iotest.
+//
+// # Generators
+//
+// MockSource and its constructors (NewSilentSource, NewSineSource,
+// NewConstantSource) produce deterministic audio of a fixed length:
+//
+//	src := audiotest.NewSineSource(8000, 1, 8000, 440) // 1 s of 440 Hz
+//
+// Package audio/gen renders the same signals, and noise and sweeps, for
+// use outside tests.
+//
+// # Fault Injection
+//
+// FaultySource and FaultyReader wrap a Source or an io.Reader and misbehave
+// on schedule: short reads, transient errors on chosen calls and early end
+// of stream. They are meant to prove that every pipeline node propagates
+// errors and copes with partial reads:
+//
+//	src := audiotest.NewFaultySource(inner,
+//	    audiotest.WithShortReads(7),
+//	    audiotest.WithErrorOnCall(3, audiotest.ErrInjected),
+//	    audiotest.WithEOFAfter(1000),
+//	)
+//
+// # Latency Measurement
+//
+// LatencyProbe embeds a short chirp in a stream and finds it again after the
+// stream went through a pipeline or a transport, reporting the end-to-end
+// delay, which is what jitter buffer and device I/O tuning needs:
+//
+//	probe := audiotest.NewLatencyProbe(16000)
+//	res, err := probe.Measure(pipeline(probe.Source(1, time.Second)))
+//
+// # Frequency Response
+//
+// SweepProbe sends a logarithmic sine sweep through a complete path, such
+// as a phone line or a codec and transport, and reports the gain and the
+// harmonic distortion of the path in every third of an octave, with its
+// latency, to qualify it end to end:
+//
+//	probe, err := audiotest.NewSweepProbe(8000, 100, 3800, 5*time.Second)
+//	res, err := probe.Measure(path(probe.Source(1, 6*time.Second)))
+//	if b, _ := res.Band(3150); b.Gain < -3 {
+//	    return fmt.Errorf("line rolls off at %.0f Hz", b.Freq)
+//	}
+//
+// # Quality Scoring
+//
+// SpeechQuality gives a PESQ-inspired score of a processed signal against
+// a reference, and SpeechLikeSignal renders deterministic speech-like
+// material at any rate to use as that reference.
+//
+// The package does not import audio, so the audio package can use it in
+// its own tests; its types still satisfy audio.Source.
+package audiotest
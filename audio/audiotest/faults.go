@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audiotest
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrInjected is a ready to use error for WithErrorOnCall.
+var ErrInjected = errors.New("audiotest: injected error")
+
+// Source mirrors audio.Source so sources can be wrapped without importing
+// the audio package (which uses this package in its own tests).
+type Source interface {
+	SampleRate() int
+	Channels() int
+	ReadSamples(dst []float32) (n int, err error)
+	BufSize() int
+	Close() error
+}
+
+// faults is the schedule shared by FaultySource and FaultyReader
+type faults struct {
+	maxRead  int           // largest read handed out, 0 means unlimited
+	errOn    map[int]error // 1-based call number -> error returned instead of data
+	eofAfter int           // units (samples or bytes) before a forced EOF, -1 disables
+	closeErr error
+}
+
+// FaultOption configures the schedule of a FaultySource or FaultyReader.
+type FaultOption func(*faults)
+
+// WithShortReads limits every read to at most n samples (FaultySource) or
+// bytes (FaultyReader). For sources n is rounded down to whole frames.
+func WithShortReads(n int) FaultOption {
+	return func(f *faults) {
+		f.maxRead = n
+	}
+}
+
+// WithErrorOnCall makes the call-th read (1-based) return err without
+// consuming any data. Later calls continue normally, which models transient
+// failures such as a network hiccup. It may be given several times.
+func WithErrorOnCall(call int, err error) FaultOption {
+	return func(f *faults) {
+		f.errOn[call] = err
+	}
+}
+
+// WithEOFAfter ends the stream with io.EOF after n samples (FaultySource) or
+// bytes (FaultyReader), even if the wrapped stream has more.
+func WithEOFAfter(n int) FaultOption {
+	return func(f *faults) {
+		f.eofAfter = n
+	}
+}
+
+// WithCloseError makes Close return err (FaultySource only).
+func WithCloseError(err error) FaultOption {
+	return func(f *faults) {
+		f.closeErr = err
+	}
+}
+
+func newFaults(opts []FaultOption) faults {
+	f := faults{errOn: make(map[int]error), eofAfter: -1}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
+
+// FaultySource wraps a Source and misbehaves according to its options.
+type FaultySource struct {
+	src       Source
+	faults    faults
+	calls     int
+	delivered int
+	closed    bool
+}
+
+// NewFaultySource wraps src with the given fault schedule. Without options
+// it behaves exactly like src.
+func NewFaultySource(src Source, opts ...FaultOption) *FaultySource {
+	return &FaultySource{src: src, faults: newFaults(opts)}
+}
+
+func (s *FaultySource) SampleRate() int { return s.src.SampleRate() }
+func (s *FaultySource) Channels() int   { return s.src.Channels() }
+func (s *FaultySource) BufSize() int    { return s.src.BufSize() }
+
+// Calls returns how many times ReadSamples was called.
+func (s *FaultySource) Calls() int { return s.calls }
+
+// Closed reports whether Close was called.
+func (s *FaultySource) Closed() bool { return s.closed }
+
+func (s *FaultySource) Close() error {
+	s.closed = true
+	if s.faults.closeErr != nil {
+		return s.faults.closeErr
+	}
+	return s.src.Close()
+}
+
+func (s *FaultySource) ReadSamples(dst []float32) (int, error) {
+	s.calls++
+	if err, ok := s.faults.errOn[s.calls]; ok {
+		return 0, err
+	}
+
+	channels := max(s.src.Channels(), 1)
+	want := len(dst)
+	if s.faults.maxRead > 0 {
+		want = min(want, max(s.faults.maxRead/channels, 1)*channels)
+	}
+	if s.faults.eofAfter >= 0 {
+		left := s.faults.eofAfter - s.delivered
+		if left <= 0 {
+			return 0, io.EOF
+		}
+		want = min(want, left)
+	}
+
+	n, err := s.src.ReadSamples(dst[:want])
+	s.delivered += n
+	if err == nil && s.faults.eofAfter >= 0 && s.delivered >= s.faults.eofAfter {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// FaultyReader wraps an io.Reader and misbehaves according to its options,
+// for testing decoders against unreliable inputs.
+type FaultyReader struct {
+	r         io.Reader
+	faults    faults
+	calls     int
+	delivered int
+}
+
+// NewFaultyReader wraps r with the given fault schedule.
+func NewFaultyReader(r io.Reader, opts ...FaultOption) *FaultyReader {
+	return &FaultyReader{r: r, faults: newFaults(opts)}
+}
+
+// Calls returns how many times Read was called.
+func (r *FaultyReader) Calls() int { return r.calls }
+
+func (r *FaultyReader) Read(p []byte) (int, error) {
+	r.calls++
+	if err, ok := r.faults.errOn[r.calls]; ok {
+		return 0, err
+	}
+
+	want := len(p)
+	if r.faults.maxRead > 0 {
+		want = min(want, r.faults.maxRead)
+	}
+	if r.faults.eofAfter >= 0 {
+		left := r.faults.eofAfter - r.delivered
+		if left <= 0 {
+			return 0, io.EOF
+		}
+		want = min(want, left)
+	}
+
+	n, err := r.r.Read(p[:want])
+	r.delivered += n
+	return n, err
+}
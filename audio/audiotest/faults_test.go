@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audiotest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFaultySource(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		channels  int
+		opts      []FaultOption
+		wantReads []int
+		wantErrs  []error
+	}{
+		{
+			name:      "passthrough",
+			channels:  1,
+			wantReads: []int{16, 4, 0},
+			wantErrs:  []error{nil, io.EOF, io.EOF},
+		},
+		{
+			name:      "short reads",
+			channels:  1,
+			opts:      []FaultOption{WithShortReads(7)},
+			wantReads: []int{7, 7, 6},
+			wantErrs:  []error{nil, nil, io.EOF},
+		},
+		{
+			name:      "short reads keep whole frames",
+			channels:  2,
+			opts:      []FaultOption{WithShortReads(7)},
+			wantReads: []int{6, 6, 6, 2},
+			wantErrs:  []error{nil, nil, nil, io.EOF},
+		},
+		{
+			name:      "transient error",
+			channels:  1,
+			opts:      []FaultOption{WithErrorOnCall(2, ErrInjected)},
+			wantReads: []int{16, 0, 4},
+			wantErrs:  []error{nil, ErrInjected, io.EOF},
+		},
+		{
+			name:      "early eof",
+			channels:  1,
+			opts:      []FaultOption{WithEOFAfter(10), WithShortReads(8)},
+			wantReads: []int{8, 2, 0},
+			wantErrs:  []error{nil, io.EOF, io.EOF},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			frames := 20 / tt.channels
+			src := NewFaultySource(NewConstantSource(8000, tt.channels, frames, 0.5), tt.opts...)
+			buf := make([]float32, 16)
+
+			for i, want := range tt.wantReads {
+				n, err := src.ReadSamples(buf)
+				if n != want {
+					t.Errorf("read %d: got %d samples, want %d", i+1, n, want)
+				}
+				if !errors.Is(err, tt.wantErrs[i]) {
+					t.Errorf("read %d: got error %v, want %v", i+1, err, tt.wantErrs[i])
+				}
+			}
+
+			if src.Calls() != len(tt.wantReads) {
+				t.Errorf("Calls() = %d, want %d", src.Calls(), len(tt.wantReads))
+			}
+		})
+	}
+}
+
+func TestFaultySource_Close(t *testing.T) {
+	t.Parallel()
+
+	src := NewFaultySource(NewSilentSource(8000, 1, 10), WithCloseError(ErrInjected))
+	if err := src.Close(); !errors.Is(err, ErrInjected) {
+		t.Errorf("Close() = %v, want %v", err, ErrInjected)
+	}
+	if !src.Closed() {
+		t.Error("Closed() = false after Close")
+	}
+}
+
+func TestFaultyReader(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte{1, 2, 3, 4}, 8)
+
+	tests := []struct {
+		name string
+		opts []FaultOption
+		want int
+		err  error
+	}{
+		{name: "passthrough", want: len(data)},
+		{name: "short reads", opts: []FaultOption{WithShortReads(3)}, want: len(data)},
+		{name: "early eof", opts: []FaultOption{WithEOFAfter(10)}, want: 10},
+		{name: "error", opts: []FaultOption{WithErrorOnCall(2, ErrInjected)}, want: 8, err: ErrInjected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := NewFaultyReader(bytes.NewReader(data), tt.opts...)
+			var got []byte
+			buf := make([]byte, 8)
+			var err error
+			for {
+				var n int
+				n, err = r.Read(buf)
+				got = append(got, buf[:n]...)
+				if err != nil {
+					break
+				}
+			}
+
+			if len(got) != tt.want {
+				t.Errorf("read %d bytes, want %d", len(got), tt.want)
+			}
+			if !bytes.Equal(got, data[:len(got)]) {
+				t.Error("data was altered")
+			}
+			wantErr := tt.err
+			if wantErr == nil {
+				wantErr = io.EOF
+			}
+			if !errors.Is(err, wantErr) {
+				t.Errorf("final error = %v, want %v", err, wantErr)
+			}
+		})
+	}
+}
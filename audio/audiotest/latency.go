@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audiotest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ik5/audpbx/utils"
+)
+
+// ErrProbeNotFound is returned when the latency probe chirp cannot be found
+// in the received audio.
+var ErrProbeNotFound = errors.New("audiotest: latency probe not found")
+
+const (
+	probeChirpDuration = 50 * time.Millisecond
+	probeLead          = 100 * time.Millisecond
+	probeMinFreq       = 300.0
+	probeMaxFreq       = 3400.0 // survives narrowband telephony paths
+
+	// Normalized correlation below this is treated as no detection.
+	probeMinConfidence = 0.5
+)
+
+// LatencyResult is the outcome of a latency measurement.
+type LatencyResult struct {
+	// Latency is the end-to-end delay between sending and receiving the chirp.
+	Latency time.Duration
+	// Samples is the delay expressed in frames at the received rate.
+	Samples int
+	// Confidence is the normalized correlation of the detected chirp, 0..1.
+	Confidence float64
+}
+
+// LatencyProbe measures the delay a pipeline or a transport adapter adds by
+// embedding a short linear chirp (300-3400 Hz, 50 ms) in a stream and
+// finding it again on the other side with cross-correlation.
+//
+//	probe := audiotest.NewLatencyProbe(16000)
+//	out := audio.NewResampler(probe.Source(1, time.Second), 8000)
+//	res, err := probe.Measure(out)
+//
+// The received stream may have a different sample rate than the sent one;
+// the chirp is regenerated at the received rate before matching.
+type LatencyProbe struct {
+	rate   int
+	offset int // frame at which the chirp starts in the sent stream
+}
+
+// NewLatencyProbe creates a probe that sends at rate. The chirp starts
+// 100 ms into the stream, so pipelines that drop a little audio at the start
+// can still be measured.
+func NewLatencyProbe(rate int) *LatencyProbe {
+	return &LatencyProbe{
+		rate:   rate,
+		offset: int(int64(rate) * int64(probeLead) / int64(time.Second)),
+	}
+}
+
+// Offset returns the frame at which the chirp starts in the sent stream.
+func (p *LatencyProbe) Offset() int { return p.offset }
+
+// Chirp renders the probe chirp at rate.
+func Chirp(rate int) []float32 {
+	n := int(int64(rate) * int64(probeChirpDuration) / int64(time.Second))
+	out := make([]float32, n)
+	dur := probeChirpDuration.Seconds()
+	sweep := (probeMaxFreq - probeMinFreq) / dur
+
+	for i := range out {
+		t := float64(i) / float64(rate)
+		phase := 2 * math.Pi * (probeMinFreq*t + 0.5*sweep*t*t)
+		// Hann envelope keeps the chirp free of clicks that would smear the
+		// correlation peak after filtering
+		env := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		out[i] = float32(0.8 * env * math.Sin(phase))
+	}
+
+	return out
+}
+
+// Source returns a Source that is silent except for the chirp at Offset,
+// with every channel carrying the same signal. total is the length of the
+// stream and is extended when it is too short to hold the chirp.
+func (p *LatencyProbe) Source(channels int, total time.Duration) *MockSource {
+	chirp := Chirp(p.rate)
+	frames := int(int64(p.rate) * int64(total) / int64(time.Second))
+	frames = max(frames, p.offset+len(chirp))
+
+	return NewMockSource(p.rate, channels, frames, func(sample int, _ int) float32 {
+		i := sample - p.offset
+		if i < 0 || i >= len(chirp) {
+			return 0
+		}
+		return chirp[i]
+	})
+}
+
+// Measure reads src to the end and returns the latency of the chirp found in
+// its first channel.
+func (p *LatencyProbe) Measure(src Source) (LatencyResult, error) {
+	channels := max(src.Channels(), 1)
+	buf := make([]float32, 1024*channels)
+	var mono []float32
+
+	for {
+		n, err := src.ReadSamples(buf)
+		for i := 0; i+channels <= n; i += channels {
+			mono = append(mono, buf[i])
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return LatencyResult{}, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	return p.Detect(mono, src.SampleRate())
+}
+
+// Detect finds the chirp in the mono signal received at rate and returns the
+// latency relative to when it was sent. Negative latencies are possible when
+// the pipeline drops audio at its start.
+func (p *LatencyProbe) Detect(received []float32, rate int) (LatencyResult, error) {
+	if rate <= 0 {
+		return LatencyResult{}, ErrProbeNotFound
+	}
+	chirp := Chirp(rate)
+	if len(received) < len(chirp) {
+		return LatencyResult{}, ErrProbeNotFound
+	}
+
+	pos, confidence := findChirp(received, chirp)
+	if confidence < probeMinConfidence {
+		return LatencyResult{Confidence: confidence},
+			fmt.Errorf("%w: confidence %.2f", ErrProbeNotFound, confidence)
+	}
+
+	// Where the chirp would be at the received rate without any delay
+	sent := float64(p.offset) * float64(rate) / float64(p.rate)
+	delay := float64(pos) - sent
+
+	return LatencyResult{
+		Latency:    time.Duration(delay / float64(rate) * float64(time.Second)),
+		Samples:    int(math.Round(delay)),
+		Confidence: confidence,
+	}, nil
+}
+
+// findChirp returns the position of chirp in signal with the best normalized
+// cross-correlation and that correlation. The correlation is computed in the
+// frequency domain in one go.
+func findChirp(signal, chirp []float32) (int, float64) {
+	size := utils.NextPowerOfTwo(len(signal) + len(chirp))
+
+	a := make([]complex128, size)
+	for i, v := range signal {
+		a[i] = complex(float64(v), 0)
+	}
+	b := make([]complex128, size)
+	var chirpEnergy float64
+	for i, v := range chirp {
+		b[i] = complex(float64(v), 0)
+		chirpEnergy += float64(v) * float64(v)
+	}
+
+	utils.FFT(a)
+	utils.FFT(b)
+	for i := range a {
+		a[i] *= complex(real(b[i]), -imag(b[i]))
+	}
+	utils.IFFT(a)
+
+	// Running energy of the signal under the chirp window for normalization
+	best, bestPos := 0.0, 0
+	var windowEnergy float64
+	for i := range len(chirp) {
+		windowEnergy += float64(signal[i]) * float64(signal[i])
+	}
+	for pos := 0; pos+len(chirp) <= len(signal); pos++ {
+		if pos > 0 {
+			out := float64(signal[pos-1])
+			in := float64(signal[pos+len(chirp)-1])
+			windowEnergy += in*in - out*out
+		}
+		if windowEnergy <= 1e-12 {
+			continue
+		}
+
+		c := real(a[pos]) / math.Sqrt(chirpEnergy*windowEnergy)
+		if c > best {
+			best, bestPos = c, pos
+		}
+	}
+
+	return bestPos, best
+}
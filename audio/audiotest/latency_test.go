@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audiotest
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestLatencyProbe_Detect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		rate  int
+		delay int
+		noise float32
+	}{
+		{name: "no delay", rate: 8000, delay: 0},
+		{name: "20 ms", rate: 8000, delay: 160},
+		{name: "odd delay wideband", rate: 16000, delay: 1237},
+		{name: "noisy", rate: 48000, delay: 4800, noise: 0.1},
+		{name: "dropped start", rate: 8000, delay: -40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			probe := NewLatencyProbe(tt.rate)
+			sent := readMock(probe.Source(1, 500*time.Millisecond))
+
+			received := make([]float32, len(sent)+max(tt.delay, 0))
+			if tt.delay >= 0 {
+				copy(received[tt.delay:], sent)
+			} else {
+				copy(received, sent[-tt.delay:])
+			}
+			rng := rand.New(rand.NewSource(1))
+			for i := range received {
+				received[i] += tt.noise * float32(rng.NormFloat64())
+			}
+
+			res, err := probe.Detect(received, tt.rate)
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if res.Samples != tt.delay {
+				t.Errorf("Samples = %d, want %d", res.Samples, tt.delay)
+			}
+			want := time.Duration(tt.delay) * time.Second / time.Duration(tt.rate)
+			if diff := res.Latency - want; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("Latency = %v, want %v", res.Latency, want)
+			}
+		})
+	}
+}
+
+func TestLatencyProbe_NotFound(t *testing.T) {
+	t.Parallel()
+
+	probe := NewLatencyProbe(8000)
+	silence := make([]float32, 8000)
+	if _, err := probe.Detect(silence, 8000); !errors.Is(err, ErrProbeNotFound) {
+		t.Errorf("Detect(silence) error = %v, want %v", err, ErrProbeNotFound)
+	}
+	if _, err := probe.Detect(silence[:10], 8000); !errors.Is(err, ErrProbeNotFound) {
+		t.Errorf("Detect(short) error = %v, want %v", err, ErrProbeNotFound)
+	}
+	if _, err := probe.Detect(silence, -8000); !errors.Is(err, ErrProbeNotFound) {
+		t.Errorf("Detect(negative rate) error = %v, want %v", err, ErrProbeNotFound)
+	}
+}
+
+func TestLatencyProbe_MeasureSource(t *testing.T) {
+	t.Parallel()
+
+	probe := NewLatencyProbe(16000)
+	src := NewFaultySource(probe.Source(2, time.Second), WithShortReads(37))
+
+	res, err := probe.Measure(src)
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+	if res.Samples != 0 || res.Confidence < 0.99 {
+		t.Errorf("Measure() = %+v, want zero latency with full confidence", res)
+	}
+}
+
+func readMock(src *MockSource) []float32 {
+	out := make([]float32, 0, src.totalSamples*src.channels)
+	buf := make([]float32, 256*src.channels)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if err != nil {
+			return out
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audiotest
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+
+	"github.com/ik5/audpbx/utils"
+)
+
+// Objective speech quality scoring loosely modeled on PESQ (ITU-T P.862):
+// both signals are time aligned and level matched, turned into a compressed
+// loudness spectrum on a Bark-like band scale, and the per band loudness
+// differences are aggregated into a disturbance that is mapped to a MOS-like
+// score between 1 (bad) and 4.5 (transparent).
+//
+// It is not a calibrated PESQ implementation; its purpose is to catch
+// audible regressions in CI by comparing against scores recorded for a
+// known-good build.
+
+const (
+	// MaxQualityScore is the score of a transparent (identical) signal.
+	MaxQualityScore = 4.5
+	// MinQualityScore is the score floor.
+	MinQualityScore = 1.0
+
+	qualityFrameMS   = 32
+	qualityMaxLagMS  = 100
+	qualityLoudnessE = 0.23 // Zwicker loudness exponent
+	qualityDeadZone  = 0.05 // ignore loudness differences below this
+)
+
+// SpeechQuality compares deg against the reference ref, both at rate, and
+// returns a MOS-like score in [MinQualityScore, MaxQualityScore].
+// Constant delays up to 100 ms and overall gain differences are compensated.
+func SpeechQuality(ref, deg []float32, rate int) float64 {
+	lag := bestLag(ref, deg, rate*qualityMaxLagMS/1000)
+
+	// Align: deg[i+lag] corresponds to ref[i]
+	var r, d []float32
+	if lag >= 0 {
+		n := min(len(ref), len(deg)-lag)
+		if n <= 0 {
+			return MinQualityScore
+		}
+		r, d = ref[:n], deg[lag:lag+n]
+	} else {
+		n := min(len(ref)+lag, len(deg))
+		if n <= 0 {
+			return MinQualityScore
+		}
+		r, d = ref[-lag:-lag+n], deg[:n]
+	}
+
+	// Level alignment to the reference
+	refRMS, degRMS := rms(r), rms(d)
+	if refRMS == 0 {
+		if degRMS == 0 {
+			return MaxQualityScore
+		}
+		return MinQualityScore
+	}
+	gain := 1.0
+	if degRMS > 0 {
+		gain = refRMS / degRMS
+	}
+
+	frame := utils.NextPowerOfTwo(rate * qualityFrameMS / 1000)
+	bands := barkBands(frame, rate)
+	refLoud := loudness(r, 1, frame, bands)
+	degLoud := loudness(d, gain, frame, bands)
+
+	// Loudness differences are taken relative to the average band loudness
+	// of the reference, so the score does not depend on the absolute level
+	// of the material
+	frameLoud := make([]float64, len(refLoud))
+	var meanLoud float64
+	for f := range refLoud {
+		for _, v := range refLoud[f] {
+			frameLoud[f] += v
+		}
+		frameLoud[f] /= float64(len(refLoud[f]))
+		meanLoud += frameLoud[f]
+	}
+	if len(refLoud) == 0 || meanLoud == 0 {
+		return MaxQualityScore
+	}
+	meanLoud /= float64(len(refLoud))
+
+	// Disturbance: per frame L2 norm of loudness differences above the dead
+	// zone, then L2 over speech-active frames (as PESQ does for its
+	// symmetric disturbance), which emphasizes bursts of distortion.
+	// Frames far below the average loudness count as silence.
+	var total float64
+	active := 0
+	for f := range refLoud {
+		if frameLoud[f] < 0.2*meanLoud {
+			continue
+		}
+
+		var dist float64
+		for b := range refLoud[f] {
+			diff := (math.Abs(degLoud[f][b]-refLoud[f][b]) - qualityDeadZone*refLoud[f][b]) / meanLoud
+			if diff > 0 {
+				dist += diff * diff
+			}
+		}
+		total += dist
+		active++
+	}
+	if active == 0 {
+		return MaxQualityScore
+	}
+
+	disturbance := math.Sqrt(total / float64(active))
+	score := MaxQualityScore - 0.45*disturbance
+	return math.Max(MinQualityScore, math.Min(MaxQualityScore, score))
+}
+
+// bestLag returns the delay of deg relative to ref, within ±maxLag samples,
+// maximizing the cross-correlation
+func bestLag(ref, deg []float32, maxLag int) int {
+	best, bestLag := math.Inf(-1), 0
+	n := min(len(ref), len(deg))
+	// Correlating every fourth sample is plenty to find the peak region
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		var c float64
+		for i := 0; i < n; i += 4 {
+			j := i + lag
+			if j < 0 || j >= len(deg) {
+				continue
+			}
+			c += float64(ref[i]) * float64(deg[j])
+		}
+		if c > best {
+			best, bestLag = c, lag
+		}
+	}
+	return bestLag
+}
+
+func rms(x []float32) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+	var p float64
+	for _, v := range x {
+		p += float64(v) * float64(v)
+	}
+	return math.Sqrt(p / float64(len(x)))
+}
+
+// barkBands returns the FFT bin edges of roughly one Bark wide bands up to
+// the Nyquist frequency
+func barkBands(frame, rate int) []int {
+	edges := []int{1}
+	binHz := float64(rate) / float64(frame)
+	for z := 1.0; ; z++ {
+		// Inverse of Traunmüller's Bark formula
+		hz := 1960 * (z + 0.53) / (26.28 - z)
+		if hz <= 0 || hz >= float64(rate)/2 || z > 24 {
+			break
+		}
+		bin := int(hz / binHz)
+		if bin > edges[len(edges)-1] {
+			edges = append(edges, bin)
+		}
+	}
+	return append(edges, frame/2)
+}
+
+// loudness returns the compressed band loudness of every half overlapping
+// Hann windowed frame of x scaled by gain
+func loudness(x []float32, gain float64, frame int, bands []int) [][]float64 {
+	window := make([]float64, frame)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frame-1))
+	}
+
+	buf := make([]complex128, frame)
+	var out [][]float64
+	for start := 0; start+frame <= len(x); start += frame / 2 {
+		for i := range frame {
+			buf[i] = complex(float64(x[start+i])*gain*window[i], 0)
+		}
+		utils.FFT(buf)
+
+		bandLoud := make([]float64, len(bands)-1)
+		for b := range bandLoud {
+			var p float64
+			for k := bands[b]; k < bands[b+1]; k++ {
+				a := cmplx.Abs(buf[k])
+				p += a * a
+			}
+			bandLoud[b] = math.Pow(p/float64(frame), qualityLoudnessE)
+		}
+		out = append(out, bandLoud)
+	}
+	return out
+}
+
+// SpeechLikeSignal renders a deterministic speech-like test signal at rate:
+// voiced syllables (harmonics of a gliding 100-220 Hz pitch shaped by three
+// formants), fricative bursts (dense random partials between 2 and 7 kHz)
+// and short pauses. Components above 0.45*rate are omitted, so the same seed
+// renders the same content at any sample rate, band limited to that rate.
+// This makes it usable as a golden reference for resampling presets.
+func SpeechLikeSignal(rate int, seconds float64, seed int64) []float32 {
+	return SpeechLikeSignalLimited(rate, 0.45*float64(rate), seconds, seed)
+}
+
+// SpeechLikeSignalLimited is SpeechLikeSignal with components at or above
+// maxFreq omitted. It renders references for upsampling, where the output
+// can only carry what the lower input rate could.
+func SpeechLikeSignalLimited(rate int, maxFreq float64, seconds float64, seed int64) []float32 {
+	rng := rand.New(rand.NewSource(seed))
+
+	type segment struct {
+		start, end float64 // seconds
+		voiced     bool
+		f0a, f0b   float64
+		formants   [3]float64
+		partials   []float64 // fricative partial frequencies
+		phases     []float64
+	}
+
+	var segs []segment
+	for t := 0.0; t < seconds; {
+		length := 0.08 + rng.Float64()*0.2
+		switch kind := rng.Intn(5); {
+		case kind < 3:
+			segs = append(segs, segment{
+				start: t, end: t + length, voiced: true,
+				f0a: 100 + rng.Float64()*120, f0b: 100 + rng.Float64()*120,
+				formants: [3]float64{
+					300 + rng.Float64()*500,
+					900 + rng.Float64()*1300,
+					2300 + rng.Float64()*700,
+				},
+			})
+		case kind == 3:
+			s := segment{start: t, end: t + length}
+			for range 48 {
+				s.partials = append(s.partials, 2000+rng.Float64()*5000)
+				s.phases = append(s.phases, rng.Float64()*2*math.Pi)
+			}
+			segs = append(segs, s)
+		}
+		// kind == 4 is a pause
+		t += length
+	}
+
+	limit := min(maxFreq, 0.45*float64(rate))
+	out := make([]float32, int(seconds*float64(rate)))
+	si := 0
+	var phase float64 // running phase of the fundamental
+	for n := range out {
+		t := float64(n) / float64(rate)
+		for si < len(segs) && segs[si].end <= t {
+			si++
+		}
+		if si == len(segs) || t < segs[si].start {
+			continue
+		}
+		s := segs[si]
+
+		// Raised cosine envelope avoids clicks at segment boundaries
+		pos := (t - s.start) / (s.end - s.start)
+		env := 0.5 - 0.5*math.Cos(2*math.Pi*pos)
+
+		var v float64
+		if s.voiced {
+			f0 := s.f0a + (s.f0b-s.f0a)*pos
+			phase += 2 * math.Pi * f0 / float64(rate)
+			// Formants make harmonics above 5 kHz negligible
+			for h := 1; float64(h)*f0 < min(limit, 5000); h++ {
+				f := float64(h) * f0
+				var amp float64
+				for i, fm := range s.formants {
+					bw := 80.0 * float64(i+1)
+					amp += math.Exp(-(f-fm)*(f-fm)/(2*bw*bw)) / float64(i+1)
+				}
+				v += amp * math.Sin(float64(h)*phase)
+			}
+			v *= 0.25
+		} else {
+			for i, f := range s.partials {
+				if f < limit {
+					v += math.Sin(2*math.Pi*f*t+s.phases[i]) * 0.02
+				}
+			}
+		}
+		out[n] = float32(v * env)
+	}
+
+	return out
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audiotest
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSpeechQuality_Identical(t *testing.T) {
+	t.Parallel()
+
+	ref := SpeechLikeSignal(8000, 2, 1)
+	if got := SpeechQuality(ref, ref, 8000); got != MaxQualityScore {
+		t.Errorf("SpeechQuality(ref, ref) = %.2f, want %.2f", got, MaxQualityScore)
+	}
+}
+
+func TestSpeechQuality_DelayAndGainInvariant(t *testing.T) {
+	t.Parallel()
+
+	ref := SpeechLikeSignal(8000, 2, 2)
+
+	// 20 ms late and 6 dB quieter
+	deg := make([]float32, len(ref)+160)
+	for i, v := range ref {
+		deg[i+160] = v * 0.5
+	}
+
+	if got := SpeechQuality(ref, deg, 8000); got < 4.4 {
+		t.Errorf("SpeechQuality(delayed, scaled) = %.2f, want ≥ 4.4", got)
+	}
+}
+
+func TestSpeechQuality_DegradesWithNoise(t *testing.T) {
+	t.Parallel()
+
+	ref := SpeechLikeSignal(8000, 2, 3)
+	rng := rand.New(rand.NewSource(3))
+
+	prev := MaxQualityScore + 1
+	for _, level := range []float32{0.001, 0.01, 0.05, 0.2} {
+		deg := make([]float32, len(ref))
+		for i, v := range ref {
+			deg[i] = v + level*float32(rng.NormFloat64())
+		}
+
+		score := SpeechQuality(ref, deg, 8000)
+		t.Logf("noise %.3f: score %.2f", level, score)
+		if score >= prev {
+			t.Errorf("noise %.3f: score %.2f did not drop below %.2f", level, score, prev)
+		}
+		prev = score
+	}
+
+	if prev > 2.5 {
+		t.Errorf("heavy noise scored %.2f, want ≤ 2.5", prev)
+	}
+}
+
+func TestSpeechLikeSignal_Deterministic(t *testing.T) {
+	t.Parallel()
+
+	a := SpeechLikeSignal(16000, 1, 42)
+	b := SpeechLikeSignal(16000, 1, 42)
+	if len(a) != 16000 {
+		t.Fatalf("len = %d, want 16000", len(a))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("sample %d differs between runs", i)
+		}
+	}
+
+	peak := float32(0)
+	for _, v := range a {
+		peak = max(peak, v, -v)
+	}
+	if peak == 0 || peak > 1 {
+		t.Errorf("peak = %v, want in (0, 1]", peak)
+	}
+}
@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audiotest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// ErrInvalidSweep is returned for a sweep probe that cannot be rendered.
+var ErrInvalidSweep = errors.New("audiotest: invalid sweep")
+
+const (
+	sweepAmplitude = 0.5 // -6 dBFS, headroom for codecs and gain stages
+	sweepFade      = 10 * time.Millisecond
+	sweepLead      = 100 * time.Millisecond
+	sweepHarmonics = 5 // highest harmonic counted in the distortion
+
+	// Normalized correlation below this is treated as no detection.
+	sweepMinConfidence = 0.3
+)
+
+// SweepBand is the response of a path in one third of an octave.
+type SweepBand struct {
+	// Freq is the center of the band, in Hz: 1000 Hz times a power of the
+	// cube root of two.
+	Freq float64
+	// Gain is the level the path passes the band at, in dB.
+	Gain float64
+	// THD is the total harmonic distortion in the band, the harmonics up to
+	// the fifth against the fundamental, in dB. Harmonics above the Nyquist
+	// frequency of the received audio are not counted, and it is NaN when
+	// none is below it.
+	THD float64
+}
+
+// SweepResult is the outcome of a sweep measurement.
+type SweepResult struct {
+	// Latency is the delay of the path, found as LatencyProbe finds it.
+	Latency time.Duration
+	// Confidence is the normalized correlation of the detected sweep, 0..1.
+	Confidence float64
+	// Bands is the response in every third of an octave the sweep covers,
+	// by rising frequency, up to the Nyquist frequency of the received
+	// audio.
+	Bands []SweepBand
+}
+
+// Band returns the band whose center is nearest to freq, and false when
+// there are none.
+func (r SweepResult) Band(freq float64) (SweepBand, bool) {
+	var best SweepBand
+	found := false
+	for _, b := range r.Bands {
+		if !found || math.Abs(math.Log(b.Freq/freq)) < math.Abs(math.Log(best.Freq/freq)) {
+			best, found = b, true
+		}
+	}
+	return best, found
+}
+
+// SweepProbe qualifies a complete path, such as a phone line, a codec or a
+// transport built with this module, by sending a logarithmic sine sweep
+// through it and analyzing what comes out: the gain and the harmonic
+// distortion of the path in every third of an octave the sweep covers.
+//
+//	probe, err := audiotest.NewSweepProbe(8000, 100, 3800, 5*time.Second)
+//	res, err := probe.Measure(path(probe.Source(1, 6*time.Second)))
+//	for _, b := range res.Bands {
+//	    fmt.Printf("%6.0f Hz %+5.1f dB THD %5.1f dB\n", b.Freq, b.Gain, b.THD)
+//	}
+//
+// The sweep spends as long on every octave and is faded in and out over
+// 10 ms. Its harmonics trail it in frequency, so each band separates the
+// distortion of the path from its response. As with LatencyProbe, the
+// received audio may have another rate than the sent one; the sweep is
+// rendered again at the received rate before the analysis.
+type SweepProbe struct {
+	rate     int
+	from, to float64
+	duration time.Duration
+	offset   int // frame at which the sweep starts in the sent stream
+}
+
+// NewSweepProbe creates a probe sending at rate a sweep from from Hz to to
+// Hz lasting duration. The sweep starts 100 ms into the stream. It fails
+// with ErrInvalidSweep unless 0 < from < to <= rate/2 and the sweep lasts
+// at least 100 ms.
+func NewSweepProbe(rate int, from, to float64, duration time.Duration) (*SweepProbe, error) {
+	if rate <= 0 || !(from > 0) || !(to > from) || to > float64(rate)/2 || duration < 100*time.Millisecond {
+		return nil, fmt.Errorf("%w: %v to %v Hz over %v at %d Hz", ErrInvalidSweep, from, to, duration, rate)
+	}
+	return &SweepProbe{
+		rate:     rate,
+		from:     from,
+		to:       to,
+		duration: duration,
+		offset:   int(int64(rate) * int64(sweepLead) / int64(time.Second)),
+	}, nil
+}
+
+// Offset returns the frame at which the sweep starts in the sent stream.
+func (p *SweepProbe) Offset() int { return p.offset }
+
+// Sweep renders the sweep at rate.
+func (p *SweepProbe) Sweep(rate int) []float32 {
+	out := make([]float32, int(int64(rate)*int64(p.duration)/int64(time.Second)))
+	fade := float64(rate) * sweepFade.Seconds()
+	for i := range out {
+		env := 1.0
+		if edge := float64(min(i, len(out)-1-i)); edge < fade {
+			env = 0.5 - 0.5*math.Cos(math.Pi*edge/fade)
+		}
+		out[i] = float32(sweepAmplitude * env * math.Sin(p.phase(float64(i)/float64(rate))))
+	}
+	return out
+}
+
+// Source returns a Source that is silent except for the sweep at Offset,
+// with every channel carrying the same signal. total is the length of the
+// stream and is extended when it is too short to hold the sweep; the
+// silence after it gives the path time to deliver it.
+func (p *SweepProbe) Source(channels int, total time.Duration) *MockSource {
+	sweep := p.Sweep(p.rate)
+	frames := int(int64(p.rate) * int64(total) / int64(time.Second))
+	frames = max(frames, p.offset+len(sweep))
+
+	return NewMockSource(p.rate, channels, frames, func(sample int, _ int) float32 {
+		i := sample - p.offset
+		if i < 0 || i >= len(sweep) {
+			return 0
+		}
+		return sweep[i]
+	})
+}
+
+// Measure reads src to the end and analyzes the sweep found in its first
+// channel.
+func (p *SweepProbe) Measure(src Source) (SweepResult, error) {
+	channels := max(src.Channels(), 1)
+	buf := make([]float32, 1024*channels)
+	var mono []float32
+
+	for {
+		n, err := src.ReadSamples(buf)
+		for i := 0; i+channels <= n; i += channels {
+			mono = append(mono, buf[i])
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return SweepResult{}, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	return p.Analyze(mono, src.SampleRate())
+}
+
+// Analyze finds the sweep in the mono signal received at rate and returns
+// the response of the path. It fails with ErrProbeNotFound when the sweep
+// is not there, or not all of it.
+func (p *SweepProbe) Analyze(received []float32, rate int) (SweepResult, error) {
+	if rate <= 0 {
+		return SweepResult{}, ErrProbeNotFound
+	}
+	sweep := p.Sweep(rate)
+	if len(received) < len(sweep) {
+		return SweepResult{}, ErrProbeNotFound
+	}
+
+	pos, confidence := findChirp(received, sweep)
+	if confidence < sweepMinConfidence {
+		return SweepResult{Confidence: confidence},
+			fmt.Errorf("%w: confidence %.2f", ErrProbeNotFound, confidence)
+	}
+
+	sent := float64(p.offset) * float64(rate) / float64(p.rate)
+	res := SweepResult{
+		Latency:    time.Duration((float64(pos) - sent) / float64(rate) * float64(time.Second)),
+		Confidence: confidence,
+	}
+
+	// Bands wholly in the sweep, clear of its fades
+	nyquist := float64(rate) / 2
+	edge := math.Pow(2, 1.0/6)
+	for k := int(math.Ceil(3 * math.Log2(p.from*edge/1000))); ; k++ {
+		center := 1000 * math.Pow(2, float64(k)/3)
+		lo, hi := center/edge, center*edge
+		if hi > p.to || hi > nyquist {
+			break
+		}
+		start := int(p.time(lo)*float64(rate)) + pos
+		end := int(p.time(hi)*float64(rate)) + pos
+		if start < pos+int(sweepFade.Seconds()*float64(rate)) || end > pos+len(sweep)-int(sweepFade.Seconds()*float64(rate)) {
+			continue
+		}
+		res.Bands = append(res.Bands, p.band(received[start:end], start-pos, rate, center, hi, nyquist))
+	}
+	return res, nil
+}
+
+// band measures one band from the received audio of it, which starts at
+// frame first of the sweep at rate and reaches hi Hz
+func (p *SweepProbe) band(x []float32, first, rate int, center, hi, nyquist float64) SweepBand {
+	// Project onto each harmonic of the sweep under a Hann window, which
+	// keeps the fundamental from leaking into the harmonics
+	var weight float64
+	amp := make([]complex128, sweepHarmonics+1)
+	for i, v := range x {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(x)))
+		weight += w
+		turn := cmplx.Exp(complex(0, -p.phase(float64(first+i)/float64(rate))))
+		rot := complex(w*float64(v), 0)
+		for h := 1; h <= sweepHarmonics; h++ {
+			rot *= turn
+			amp[h] += rot
+		}
+	}
+
+	fundamental := cmplx.Abs(amp[1])
+	thd := math.NaN()
+	if 2*hi < nyquist {
+		var harmonics float64
+		for h := 2; h <= sweepHarmonics && float64(h)*hi < nyquist; h++ {
+			harmonics += cmplx.Abs(amp[h]) * cmplx.Abs(amp[h])
+		}
+		thd = 10 * math.Log10(math.Max(harmonics/math.Max(fundamental*fundamental, 1e-20), 1e-20))
+	}
+
+	return SweepBand{
+		Freq: center,
+		Gain: 20 * math.Log10(math.Max(2*fundamental/weight/sweepAmplitude, 1e-10)),
+		THD:  thd,
+	}
+}
+
+// phase returns the phase of the sweep t seconds into it, which runs at
+// from Hz times e^(t/l)
+func (p *SweepProbe) phase(t float64) float64 {
+	l := p.duration.Seconds() / math.Log(p.to/p.from)
+	return 2 * math.Pi * p.from * l * (math.Exp(t/l) - 1)
+}
+
+// time returns the time into the sweep at which it reaches freq Hz
+func (p *SweepProbe) time(freq float64) float64 {
+	return p.duration.Seconds() * math.Log(freq/p.from) / math.Log(p.to/p.from)
+}
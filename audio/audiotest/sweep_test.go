@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audiotest
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSweepProbe_Analyze(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		rate  int
+		delay int
+		path  func(x []float32) []float32
+		gain  func(f float64) float64 // dB
+		thd   float64                 // dB, worst band, or the THD of every band when exact
+		exact bool
+	}{
+		{
+			name: "transparent",
+			rate: 8000,
+			path: func(x []float32) []float32 { return x },
+			gain: func(float64) float64 { return 0 },
+			thd:  -60,
+		},
+		{
+			name:  "attenuated and delayed",
+			rate:  16000,
+			delay: 1237,
+			path: func(x []float32) []float32 {
+				for i := range x {
+					x[i] *= 0.5
+				}
+				return x
+			},
+			gain: func(float64) float64 { return -6.02 },
+			thd:  -60,
+		},
+		{
+			// Averaging two samples passes f at |cos(πf/rate)|
+			name: "low-pass",
+			rate: 8000,
+			path: func(x []float32) []float32 {
+				for i := len(x) - 1; i > 0; i-- {
+					x[i] = (x[i] + x[i-1]) / 2
+				}
+				return x
+			},
+			gain: func(f float64) float64 { return 20 * math.Log10(math.Cos(math.Pi*f/8000)) },
+			thd:  -40,
+		},
+		{
+			// x + 0.1x² gives a second harmonic of 0.05 A² against A
+			name: "second harmonic",
+			rate: 8000,
+			path: func(x []float32) []float32 {
+				for i, v := range x {
+					x[i] = v + 0.1*v*v
+				}
+				return x
+			},
+			gain:  func(float64) float64 { return 0 },
+			thd:   20 * math.Log10(0.05*sweepAmplitude),
+			exact: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			probe, err := NewSweepProbe(tt.rate, 100, 3800, 3*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := readMock(probe.Source(1, 4*time.Second))
+			received := make([]float32, len(sent)+tt.delay)
+			copy(received[tt.delay:], tt.path(sent))
+
+			res, err := probe.Analyze(received, tt.rate)
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if want := time.Duration(tt.delay) * time.Second / time.Duration(tt.rate); res.Latency != want {
+				t.Errorf("Latency = %v, want %v", res.Latency, want)
+			}
+			// Third octaves from 125 Hz to 3150 Hz
+			if len(res.Bands) != 15 || math.Abs(res.Bands[0].Freq-125) > 1 || math.Abs(res.Bands[14].Freq-3175) > 1 {
+				t.Fatalf("Bands = %v, want 15 from 125 Hz to 3150 Hz", res.Bands)
+			}
+
+			for _, b := range res.Bands {
+				if want := tt.gain(b.Freq); math.Abs(b.Gain-want) > 0.1 {
+					t.Errorf("%.0f Hz: gain %.2f dB, want %.2f dB", b.Freq, b.Gain, want)
+				}
+				switch {
+				case math.IsNaN(b.THD):
+					if 2*b.Freq*math.Pow(2, 1.0/6) < float64(tt.rate)/2 {
+						t.Errorf("%.0f Hz: THD not measured", b.Freq)
+					}
+				case tt.exact && math.Abs(b.THD-tt.thd) > 0.5:
+					t.Errorf("%.0f Hz: THD %.1f dB, want %.1f dB", b.Freq, b.THD, tt.thd)
+				case !tt.exact && b.THD > tt.thd:
+					t.Errorf("%.0f Hz: THD %.1f dB, above %.1f dB", b.Freq, b.THD, tt.thd)
+				}
+			}
+		})
+	}
+}
+
+func TestSweepProbe_Measure(t *testing.T) {
+	t.Parallel()
+
+	// Sent at 16 kHz, received at 8 kHz: the bands stop below 4 kHz
+	probe, err := NewSweepProbe(16000, 100, 7000, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := readMock(probe.Source(1, 3*time.Second))
+	narrow := make([]float32, 0, len(sent)/2)
+	for i := 0; i+1 < len(sent); i += 2 {
+		narrow = append(narrow, sent[i])
+	}
+
+	res, err := probe.Measure(NewMockSource(8000, 2, len(narrow), func(sample, _ int) float32 { return narrow[sample] }))
+	if err != nil {
+		t.Fatalf("Measure() error = %v", err)
+	}
+	last := res.Bands[len(res.Bands)-1]
+	if last.Freq*math.Pow(2, 1.0/6) > 4000 {
+		t.Errorf("last band at %.0f Hz, above the received Nyquist frequency", last.Freq)
+	}
+	if b, ok := res.Band(1000); !ok || b.Freq != 1000 || math.Abs(b.Gain) > 0.1 {
+		t.Errorf("Band(1000) = %+v, %v, want 1000 Hz at 0 dB", b, ok)
+	}
+}
+
+func TestSweepProbe_Errors(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		rate     int
+		from, to float64
+		duration time.Duration
+	}{
+		{0, 100, 3400, time.Second},
+		{8000, 0, 3400, time.Second},
+		{8000, 3400, 100, time.Second},
+		{8000, 100, 4001, time.Second},
+		{8000, 100, 3400, 50 * time.Millisecond},
+	} {
+		if _, err := NewSweepProbe(tt.rate, tt.from, tt.to, tt.duration); !errors.Is(err, ErrInvalidSweep) {
+			t.Errorf("NewSweepProbe(%d, %v, %v, %v) error = %v, want ErrInvalidSweep", tt.rate, tt.from, tt.to, tt.duration, err)
+		}
+	}
+
+	probe, err := NewSweepProbe(8000, 100, 3400, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := probe.Analyze(make([]float32, 16000), 8000); !errors.Is(err, ErrProbeNotFound) {
+		t.Errorf("Analyze(silence) error = %v, want ErrProbeNotFound", err)
+	}
+	if _, err := probe.Analyze(make([]float32, 100), 8000); !errors.Is(err, ErrProbeNotFound) {
+		t.Errorf("Analyze(short) error = %v, want ErrProbeNotFound", err)
+	}
+	src := NewFaultySource(probe.Source(1, time.Second), WithErrorOnCall(2, ErrInjected))
+	if _, err := probe.Measure(src); !errors.Is(err, ErrInjected) {
+		t.Errorf("Measure() error = %v, want ErrInjected", err)
+	}
+	if _, ok := (SweepResult{}).Band(1000); ok {
+		t.Error("Band() of no bands found one")
+	}
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Defaults of BargeInOptions.
+const (
+	DefaultBargeInThreshold = 0.03
+	DefaultBargeInMinSpeech = 200 * time.Millisecond
+)
+
+// BargeInOptions configures a BargeIn. Zero values select the defaults.
+type BargeInOptions struct {
+	// Threshold is the RMS level, in [0, 1] over all channels of a window,
+	// at or above which the caller counts as speaking. The default, 0.03
+	// (-30 dBFS), separates speech from a quiet line; raise it on noisy
+	// lines or where the prompt echoes back.
+	Threshold float32
+	// Window is the duration over which the level is measured,
+	// DefaultTriggerWindow (20 ms) by default.
+	Window time.Duration
+	// MinSpeech is how long the caller must speak without a pause to barge
+	// in, 200 ms by default, so a cough or a click does not.
+	MinSpeech time.Duration
+	// AutoStop makes prompts started with Play end as soon as the caller
+	// barges in.
+	AutoStop bool
+	// OnBargeIn, when set, is called from ReadSamples when the caller
+	// barges in, once per arming.
+	OnBargeIn func()
+}
+
+// BargeIn passes the inbound audio of a call through unchanged and, while
+// armed, watches it for the caller talking over the prompt being played,
+// as IVR menus let callers who know their option skip the rest.
+//
+// It is armed by Play, for as long as the prompt it returns plays, or by
+// hand with Arm and Disarm. The caller barges in once windows at or above
+// the threshold add up to MinSpeech without a quiet window between them.
+//
+// Play, Arm, Disarm and BargedIn are safe to call from any goroutine while
+// another one reads.
+type BargeIn struct {
+	src       Source
+	channels  int
+	threshold float32
+	window    int // samples
+	minSpeech int // sample frames
+	autoStop  bool
+	onBargeIn func()
+
+	// Level of the window being measured
+	sumSq float64
+	count int
+
+	mu     *sync.Mutex
+	armed  bool
+	fired  bool
+	speech int // sample frames of speech so far
+	prompt *bargePrompt
+}
+
+// NewBargeIn creates a disarmed BargeIn over the inbound stream src.
+func NewBargeIn(src Source, opts BargeInOptions) *BargeIn {
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultBargeInThreshold
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultTriggerWindow
+	}
+	if opts.MinSpeech <= 0 {
+		opts.MinSpeech = DefaultBargeInMinSpeech
+	}
+
+	channels := max(src.Channels(), 1)
+	return &BargeIn{
+		src:       src,
+		channels:  channels,
+		threshold: opts.Threshold,
+		window:    max(FrameLen(src.SampleRate(), opts.Window), 1) * channels,
+		minSpeech: FrameLen(src.SampleRate(), opts.MinSpeech),
+		autoStop:  opts.AutoStop,
+		onBargeIn: opts.OnBargeIn,
+		mu:        &sync.Mutex{},
+	}
+}
+
+func (b *BargeIn) SampleRate() int    { return b.src.SampleRate() }
+func (b *BargeIn) Channels() int      { return b.src.Channels() }
+func (b *BargeIn) BufSize() int       { return b.src.BufSize() }
+func (b *BargeIn) Format() Format     { return FormatOf(b.src) }
+func (b *BargeIn) Upstream() []Source { return []Source{b.src} }
+
+func (b *BargeIn) Close() error {
+	if err := b.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// Arm starts watching for barge-in, forgetting any earlier one. It does
+// nothing when already armed.
+func (b *BargeIn) Arm() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.arm()
+}
+
+func (b *BargeIn) arm() {
+	if b.armed {
+		return
+	}
+	b.armed, b.fired, b.speech = true, false, 0
+}
+
+// Disarm stops watching. BargedIn keeps reporting what happened while it
+// was armed.
+func (b *BargeIn) Disarm() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.armed = false
+	b.prompt = nil
+}
+
+// BargedIn reports whether the caller barged in since the last arming.
+func (b *BargeIn) BargedIn() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.fired
+}
+
+// Play arms b for as long as prompt plays and returns the prompt to play
+// in its place; closing that closes prompt. With AutoStop the returned
+// source ends, as if prompt had, when the caller barges in. A later Play
+// takes over from an earlier one.
+func (b *BargeIn) Play(prompt Source) Source {
+	p := &bargePrompt{Source: prompt, b: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.armed = false
+	b.arm()
+	b.prompt = p
+	return p
+}
+
+// ReadSamples passes the inbound audio through, watching it while armed.
+func (b *BargeIn) ReadSamples(dst []float32) (int, error) {
+	n, err := b.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	if n > 0 {
+		b.watch(dst[:n-n%b.channels])
+	}
+	return n, err
+}
+
+// watch measures samples window by window
+func (b *BargeIn) watch(samples []float32) {
+	for _, v := range samples {
+		b.sumSq += float64(v) * float64(v)
+		b.count++
+		if b.count < b.window {
+			continue
+		}
+
+		level := float32(math.Sqrt(b.sumSq / float64(b.count)))
+		b.sumSq, b.count = 0, 0
+		if b.detect(level >= b.threshold) && b.onBargeIn != nil {
+			b.onBargeIn()
+		}
+	}
+}
+
+// detect counts a window of speech or silence and reports whether it made
+// the caller barge in
+func (b *BargeIn) detect(speech bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.armed || b.fired {
+		return false
+	}
+	if !speech {
+		b.speech = 0
+		return false
+	}
+
+	b.speech += b.window / b.channels
+	if b.speech < b.minSpeech {
+		return false
+	}
+	b.fired = true
+	if b.autoStop && b.prompt != nil {
+		b.prompt.stopped = true
+	}
+	return true
+}
+
+// bargePrompt is a prompt played through BargeIn.Play
+type bargePrompt struct {
+	Source
+	b       *BargeIn
+	stopped bool // guarded by b.mu
+}
+
+func (p *bargePrompt) Format() Format     { return FormatOf(p.Source) }
+func (p *bargePrompt) Upstream() []Source { return []Source{p.Source} }
+
+func (p *bargePrompt) ReadSamples(dst []float32) (int, error) {
+	p.b.mu.Lock()
+	stopped := p.stopped
+	p.b.mu.Unlock()
+	if stopped {
+		return 0, io.EOF
+	}
+
+	n, err := p.Source.ReadSamples(dst)
+	if errors.Is(err, io.EOF) {
+		p.done()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, err
+}
+
+func (p *bargePrompt) Close() error {
+	p.done()
+	if err := p.Source.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// done disarms the BargeIn if p is still the prompt it watches for
+func (p *bargePrompt) done() {
+	p.b.mu.Lock()
+	defer p.b.mu.Unlock()
+
+	if p.b.prompt == p {
+		p.b.armed = false
+		p.b.prompt = nil
+	}
+}
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// callerSpeech is an 8 kHz mono inbound stream at 0.3 inside spans of
+// sample frames and at 0.01 (line noise) elsewhere
+func callerSpeech(frames int, spans ...[2]int) Source {
+	return newMockSource(8000, 1, frames, func(sample, _ int) float32 {
+		for _, s := range spans {
+			if sample >= s[0] && sample < s[1] {
+				return 0.3
+			}
+		}
+		return 0.01
+	})
+}
+
+func TestBargeIn_Play(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		spans    [][2]int
+		autoStop bool
+		chunk    int // samples per read, both ways
+		wantFire bool
+		wantPlay int // prompt samples played
+	}{
+		// 200 ms of speech from 100 ms on fires at 300 ms
+		{name: "auto stop", spans: [][2]int{{800, 3200}}, autoStop: true, chunk: 160, wantFire: true, wantPlay: 2400},
+		{name: "unaligned reads", spans: [][2]int{{800, 3200}}, autoStop: true, chunk: 100, wantFire: true, wantPlay: 2400},
+		{name: "no auto stop", spans: [][2]int{{800, 3200}}, chunk: 160, wantFire: true, wantPlay: 4000},
+		{name: "too short", spans: [][2]int{{800, 1600}}, autoStop: true, chunk: 160, wantPlay: 4000},
+		// 120 ms, a 20 ms pause, 120 ms
+		{name: "pause resets", spans: [][2]int{{800, 1760}, {1920, 2880}}, autoStop: true, chunk: 160, wantPlay: 4000},
+		{name: "after the prompt", spans: [][2]int{{4000, 6400}}, autoStop: true, chunk: 160, wantPlay: 4000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fired := 0
+			b := NewBargeIn(callerSpeech(8000, tt.spans...), BargeInOptions{
+				AutoStop:  tt.autoStop,
+				OnBargeIn: func() { fired++ },
+			})
+			menu := audiotest.NewFaultySource(newConstantSource(8000, 1, 4000, 0.5))
+			prompt := b.Play(menu)
+
+			// One chunk each way at a time, as a call does
+			played := 0
+			out := make([]float32, tt.chunk)
+			in := make([]float32, tt.chunk)
+			promptDone := false
+			for {
+				if !promptDone {
+					n, err := prompt.ReadSamples(out)
+					played += n
+					if errors.Is(err, io.EOF) {
+						promptDone = true
+					} else if err != nil {
+						t.Fatalf("prompt ReadSamples() error = %v", err)
+					}
+				}
+				if _, err := b.ReadSamples(in); errors.Is(err, io.EOF) {
+					break
+				} else if err != nil {
+					t.Fatalf("inbound ReadSamples() error = %v", err)
+				}
+			}
+
+			if tt.wantFire != (fired == 1) || fired > 1 {
+				t.Errorf("OnBargeIn called %d times, want fired = %v", fired, tt.wantFire)
+			}
+			if b.BargedIn() != tt.wantFire {
+				t.Errorf("BargedIn() = %v, want %v", b.BargedIn(), tt.wantFire)
+			}
+			if played != tt.wantPlay {
+				t.Errorf("played %d prompt samples, want %d", played, tt.wantPlay)
+			}
+
+			if err := prompt.Close(); err != nil {
+				t.Fatalf("prompt Close() error = %v", err)
+			}
+			if !menu.Closed() {
+				t.Error("closing the prompt did not close the wrapped source")
+			}
+		})
+	}
+}
+
+func TestBargeIn_Arm(t *testing.T) {
+	t.Parallel()
+
+	fired := 0
+	b := NewBargeIn(callerSpeech(8000, [2]int{0, 2000}, [2]int{4000, 6000}), BargeInOptions{
+		Threshold: 0.1,
+		MinSpeech: 100 * time.Millisecond,
+		OnBargeIn: func() { fired++ },
+	})
+
+	// Disarmed: the first burst goes unnoticed, and is passed through
+	got := readAll(t, &limitedSource{Source: b, left: 3000}, 160)
+	if len(got) != 3000 || got[0] != 0.3 || got[2999] != 0.01 {
+		t.Fatalf("passed through %d samples, want the inbound audio", len(got))
+	}
+	if fired != 0 || b.BargedIn() {
+		t.Fatal("BargeIn fired while disarmed")
+	}
+
+	b.Arm()
+	readAll(t, b, 160)
+	if fired != 1 || !b.BargedIn() {
+		t.Errorf("after Arm() fired %d times, BargedIn() = %v, want once, true", fired, b.BargedIn())
+	}
+
+	b.Disarm()
+	if !b.BargedIn() {
+		t.Error("Disarm() forgot the barge-in")
+	}
+	b.Arm()
+	if b.BargedIn() {
+		t.Error("Arm() did not forget the earlier barge-in")
+	}
+}
+
+func TestBargeIn_Errors(t *testing.T) {
+	t.Parallel()
+
+	in := audiotest.NewFaultySource(newSilentSource(8000, 1, 100), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	b := NewBargeIn(in, BargeInOptions{})
+	if _, err := b.ReadSamples(make([]float32, 10)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+
+	bad := audiotest.NewFaultySource(newSilentSource(8000, 1, 100), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	if _, err := b.Play(bad).ReadSamples(make([]float32, 10)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("prompt ReadSamples() error = %v, want ErrInjected", err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !in.Closed() {
+		t.Error("Close() did not close the inbound source")
+	}
+}
+
+// limitedSource ends after left samples of Source, without closing it
+type limitedSource struct {
+	Source
+	left int
+}
+
+func (l *limitedSource) ReadSamples(dst []float32) (int, error) {
+	if l.left == 0 {
+		return 0, io.EOF
+	}
+	n, err := l.Source.ReadSamples(dst[:min(len(dst), l.left)])
+	l.left -= n
+	return n, err
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Capabilities describes what the package of a format reads and writes, so
+// that a request, such as a conversion to 24-bit AIFF, can be checked, and
+// refused with a precise message, before a job starts on it.
+type Capabilities struct {
+	// Name is the name of the format, such as "WAV".
+	Name string
+	// Decode and Encode report whether the package reads and writes the
+	// format.
+	Decode bool
+	Encode bool
+	// BitDepths are the sample sizes, in bits, the decoder reads, and
+	// EncodeBitDepths those the encoder writes. They are nil for formats
+	// without PCM samples, such as MP3 or G.711.
+	BitDepths       []int
+	EncodeBitDepths []int
+	// MaxChannels is the most channels the format holds.
+	MaxChannels int
+	// Rates are the sample rates the format holds, nil for any rate.
+	Rates []int
+	// Seekable reports whether decoded sources implement Seeker when they
+	// read from an io.ReadSeeker.
+	Seekable bool
+	// Metadata reports whether decoded sources implement Metadata.
+	Metadata bool
+}
+
+// CapabilityReporter is implemented by decoders that describe their format
+// with Capabilities, as those of formats/... do. Check for it with a type
+// assertion, or use CapabilitiesOf.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf returns the Capabilities of d, and false when d does not
+// report them.
+func CapabilitiesOf(d Decoder) (Capabilities, bool) {
+	if c, ok := d.(CapabilityReporter); ok {
+		return c.Capabilities(), true
+	}
+	return Capabilities{}, false
+}
+
+// CheckDecode reports whether streams of format f can be decoded. The
+// SampleKind of f is only checked when it is known.
+func (c Capabilities) CheckDecode(f Format) error {
+	if !c.Decode {
+		return fmt.Errorf("%w: %s cannot be decoded", ErrUnsupported, c.Name)
+	}
+	return c.check(f, c.BitDepths)
+}
+
+// CheckEncode reports whether audio of format f can be encoded as it is:
+// an error names what the format cannot hold, so it can be shown to the
+// user as is. The SampleKind of f is only checked when it is known, so a
+// Format with only a rate and channels asks whether the encoder can write
+// them at all.
+func (c Capabilities) CheckEncode(f Format) error {
+	if !c.Encode {
+		return fmt.Errorf("%w: %s cannot be encoded", ErrUnsupported, c.Name)
+	}
+	return c.check(f, c.EncodeBitDepths)
+}
+
+func (c Capabilities) check(f Format, depths []int) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	if c.MaxChannels > 0 && f.Channels > c.MaxChannels {
+		return fmt.Errorf("%w: %s holds at most %d channels, not %d", ErrUnsupported, c.Name, c.MaxChannels, f.Channels)
+	}
+	if c.Rates != nil && !slices.Contains(c.Rates, f.Rate) {
+		return fmt.Errorf("%w: %s does not hold %d Hz, only %v", ErrUnsupported, c.Name, f.Rate, c.Rates)
+	}
+	if bits := f.SampleKind.BitDepth(); bits > 0 && depths != nil && !slices.Contains(depths, bits) {
+		return fmt.Errorf("%w: %s does not hold %d-bit samples, only %v", ErrUnsupported, c.Name, bits, depths)
+	}
+	return nil
+}
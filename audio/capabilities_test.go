@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"testing"
+)
+
+// reportingDecoder is a mockDecoder reporting capabilities
+type reportingDecoder struct {
+	mockDecoder
+	caps Capabilities
+}
+
+func (d *reportingDecoder) Capabilities() Capabilities { return d.caps }
+
+func TestCapabilities_Check(t *testing.T) {
+	t.Parallel()
+
+	wavLike := Capabilities{
+		Name:            "WAV",
+		Decode:          true,
+		Encode:          true,
+		BitDepths:       []int{8, 16, 24, 32},
+		EncodeBitDepths: []int{16},
+		MaxChannels:     2,
+	}
+	opusLike := Capabilities{Name: "Opus", Decode: true, Rates: []int{48000}}
+
+	tests := []struct {
+		name    string
+		caps    Capabilities
+		format  Format
+		encode  bool
+		wantErr error
+	}{
+		{name: "encode", caps: wavLike, format: Format{Rate: 8000, Channels: 1, SampleKind: SampleInt16}, encode: true},
+		{name: "encode unknown kind", caps: wavLike, format: Format{Rate: 44100, Channels: 2}, encode: true},
+		{name: "encode bit depth", caps: wavLike, format: Format{Rate: 8000, Channels: 1, SampleKind: SampleInt24}, encode: true, wantErr: ErrUnsupported},
+		{name: "decode bit depth", caps: wavLike, format: Format{Rate: 8000, Channels: 1, SampleKind: SampleInt24}},
+		{name: "channels", caps: wavLike, format: Format{Rate: 8000, Channels: 6}, encode: true, wantErr: ErrUnsupported},
+		{name: "invalid", caps: wavLike, format: Format{Rate: 0, Channels: 1}, encode: true, wantErr: ErrInvalidSampleRate},
+		{name: "rate", caps: opusLike, format: Format{Rate: 48000, Channels: 2}},
+		{name: "other rate", caps: opusLike, format: Format{Rate: 8000, Channels: 1}, wantErr: ErrUnsupported},
+		{name: "no encoder", caps: opusLike, format: Format{Rate: 48000, Channels: 2}, encode: true, wantErr: ErrUnsupported},
+		{name: "no decoder", caps: Capabilities{Name: "X", Encode: true}, format: Format{Rate: 8000, Channels: 1}, wantErr: ErrUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			check := tt.caps.CheckDecode
+			if tt.encode {
+				check = tt.caps.CheckEncode
+			}
+			if err := check(tt.format); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("check(%s) error = %v, want %v", tt.format, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistry_Capabilities(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	registry.Register("wav", &reportingDecoder{caps: Capabilities{Name: "WAV", Decode: true}})
+	registry.Register("raw", &mockDecoder{name: "raw"})
+
+	if c, ok := registry.Capabilities("wav"); !ok || c.Name != "WAV" {
+		t.Errorf("Capabilities(wav) = %+v, %v, want WAV", c, ok)
+	}
+	if _, ok := registry.Capabilities("raw"); ok {
+		t.Error("Capabilities(raw) of a decoder without capabilities is ok")
+	}
+	if _, ok := registry.Capabilities("mp3"); ok {
+		t.Error("Capabilities(mp3) of an unregistered format is ok")
+	}
+}
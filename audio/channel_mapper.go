@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// foldGain is the weight of a speaker folded into a neighbouring one, -3
+// dB as in ITU-R BS.775 downmixes
+const foldGain = 0.70710677
+
+// ChannelMapper remixes the channels of a source through a mix matrix:
+// output channel i is the sum over input channels j of matrix[i][j] times
+// input channel j. It upmixes stereo to 5.1, downmixes 5.1 to stereo,
+// swaps left and right ({{0, 1}, {1, 0}}) or drops channels, where
+// MonoMixer only folds to mono.
+//
+// The output is not clipped; the matrices of RemixMatrix keep every output
+// channel within range.
+type ChannelMapper struct {
+	src    Source
+	matrix [][]float32
+	in     int
+	layout Layout
+	tmp    []float32
+}
+
+// NewChannelMapper creates a ChannelMapper of src. matrix has a row per
+// output channel and, in each row, a weight per channel of src. The
+// output has the default layout for its channel count.
+func NewChannelMapper(src Source, matrix [][]float32) (*ChannelMapper, error) {
+	in := src.Channels()
+	if in <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidChannels, in)
+	}
+	if len(matrix) == 0 {
+		return nil, fmt.Errorf("%w: empty mix matrix", ErrInvalidChannels)
+	}
+	for i, row := range matrix {
+		if len(row) != in {
+			return nil, fmt.Errorf("%w: row %d of the mix matrix has %d weights for %d channels", ErrFormatMismatch, i, len(row), in)
+		}
+	}
+
+	return &ChannelMapper{
+		src:    src,
+		matrix: matrix,
+		in:     in,
+		layout: DefaultLayout(len(matrix)),
+	}, nil
+}
+
+// RemixTo creates a ChannelMapper turning src into the layout to, with
+// the matrix of RemixMatrix. The layout of src is taken from its Format.
+func RemixTo(src Source, to Layout) (*ChannelMapper, error) {
+	from := FormatOf(src).Layout
+	if from.Count() != src.Channels() {
+		from = DefaultLayout(src.Channels())
+	}
+	matrix := RemixMatrix(from, to)
+	if matrix == nil {
+		return nil, fmt.Errorf("%w: cannot remix %s to %s", ErrFormatMismatch, from, to)
+	}
+
+	m, err := NewChannelMapper(src, matrix)
+	if err != nil {
+		return nil, err
+	}
+	m.layout = to
+	return m, nil
+}
+
+// RemixMatrix returns the mix matrix from the layout from to the layout
+// to. Speakers found in both are kept as they are. Those missing from to
+// are folded into the nearest ones at -3 dB: the center into the front
+// pair, or the front pair into the center, and each surround into the
+// surround of the same side of to, or else its front; the LFE channel is
+// dropped. Speakers of to missing from from stay silent, so stereo
+// upmixed to 5.1 plays from the front pair alone. Rows whose weights sum
+// to more than 1 are scaled down to 1, keeping the output within range.
+//
+// It returns nil when either layout is unknown.
+func RemixMatrix(from, to Layout) [][]float32 {
+	if from == LayoutUnknown || to == LayoutUnknown {
+		return nil
+	}
+
+	outs := to.Labels()
+	row := make(map[ChannelLabel]int, len(outs))
+	matrix := make([][]float32, len(outs))
+	for i, c := range outs {
+		row[c] = i
+		matrix[i] = make([]float32, from.Count())
+	}
+
+	for j, c := range from.Labels() {
+		if i, ok := row[c]; ok {
+			matrix[i][j] = 1
+			continue
+		}
+		for _, t := range foldTargets(c, to) {
+			matrix[row[t.label]][j] += t.gain
+		}
+	}
+
+	for _, weights := range matrix {
+		var sum float32
+		for _, w := range weights {
+			sum += w
+		}
+		if sum > 1 {
+			for j := range weights {
+				weights[j] /= sum
+			}
+		}
+	}
+	return matrix
+}
+
+type foldTarget struct {
+	label ChannelLabel
+	gain  float32
+}
+
+// foldTargets returns the speakers of to that c, missing from to, is
+// folded into
+func foldTargets(c ChannelLabel, to Layout) []foldTarget {
+	var same, surround []ChannelLabel
+	switch c {
+	case ChannelLFE:
+		return nil
+	case ChannelFL, ChannelFLC, ChannelTFL:
+		same = []ChannelLabel{ChannelFL}
+	case ChannelFR, ChannelFRC, ChannelTFR:
+		same = []ChannelLabel{ChannelFR}
+	case ChannelBL, ChannelSL, ChannelTBL:
+		surround = []ChannelLabel{ChannelSL, ChannelBL}
+		same = []ChannelLabel{ChannelFL}
+	case ChannelBR, ChannelSR, ChannelTBR:
+		surround = []ChannelLabel{ChannelSR, ChannelBR}
+		same = []ChannelLabel{ChannelFR}
+	default:
+		// Center speakers
+		if c != ChannelFC && to.Has(ChannelFC) {
+			return []foldTarget{{ChannelFC, foldGain}}
+		}
+		if to.Has(ChannelFL) && to.Has(ChannelFR) {
+			return []foldTarget{{ChannelFL, foldGain}, {ChannelFR, foldGain}}
+		}
+		if to.Has(ChannelFC) {
+			return []foldTarget{{ChannelFC, 1}}
+		}
+		return nil
+	}
+
+	// A surround moves to the other kind of surround of its side as is
+	for _, s := range surround {
+		if s != c && to.Has(s) {
+			return []foldTarget{{s, 1}}
+		}
+	}
+	for _, s := range same {
+		if to.Has(s) {
+			return []foldTarget{{s, foldGain}}
+		}
+	}
+	if to.Has(ChannelFC) {
+		return []foldTarget{{ChannelFC, foldGain}}
+	}
+	return nil
+}
+
+func (m *ChannelMapper) SampleRate() int    { return m.src.SampleRate() }
+func (m *ChannelMapper) Channels() int      { return len(m.matrix) }
+func (m *ChannelMapper) BufSize() int       { return m.src.BufSize() }
+func (m *ChannelMapper) Upstream() []Source { return []Source{m.src} }
+
+// Format returns the format of the source with the channels of the
+// output.
+func (m *ChannelMapper) Format() Format {
+	f := FormatOf(m.src)
+	f.Channels = len(m.matrix)
+	f.Layout = m.layout
+	return f
+}
+
+func (m *ChannelMapper) Close() error {
+	if err := m.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with remixed frames. len(dst) must be a multiple
+// of Channels().
+func (m *ChannelMapper) ReadSamples(dst []float32) (int, error) {
+	out := len(m.matrix)
+	if len(dst)%out != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	frames := len(dst) / out
+	if cap(m.tmp) < frames*m.in {
+		m.tmp = make([]float32, frames*m.in)
+	}
+	tmp := m.tmp[:frames*m.in]
+
+	n, err := m.src.ReadSamples(tmp)
+	frames = n / m.in
+	for f := range frames {
+		frame := tmp[f*m.in : (f+1)*m.in]
+		for i, weights := range m.matrix {
+			var sum float32
+			for j, w := range weights {
+				sum += frame[j] * w
+			}
+			dst[f*out+i] = sum
+		}
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return frames * out, fmt.Errorf("%w", err)
+	}
+	return frames * out, err
+}
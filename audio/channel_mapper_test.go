@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// channelValues is a source whose channel c is at (c+1)/10
+func channelValues(channels, frames int) *audiotest.MockSource {
+	return newMockSource(8000, channels, frames, func(_, c int) float32 {
+		return float32(c+1) / 10
+	})
+}
+
+func TestRemixMatrix(t *testing.T) {
+	t.Parallel()
+
+	const g = foldGain
+	tests := []struct {
+		name     string
+		from, to Layout
+		want     [][]float32
+	}{
+		{name: "identity", from: LayoutStereo, to: LayoutStereo, want: [][]float32{{1, 0}, {0, 1}}},
+		{name: "stereo to mono", from: LayoutStereo, to: LayoutMono, want: [][]float32{{0.5, 0.5}}},
+		{name: "mono to stereo", from: LayoutMono, to: LayoutStereo, want: [][]float32{{g}, {g}}},
+		// FL FR C LFE BL BR, normalized from 1 + 2 * 0.707
+		{
+			name: "5.1 to stereo", from: Layout5_1, to: LayoutStereo,
+			want: [][]float32{
+				{1 / (1 + 2*g), 0, g / (1 + 2*g), 0, g / (1 + 2*g), 0},
+				{0, 1 / (1 + 2*g), g / (1 + 2*g), 0, 0, g / (1 + 2*g)},
+			},
+		},
+		{
+			name: "stereo to 5.1", from: LayoutStereo, to: Layout5_1,
+			want: [][]float32{{1, 0}, {0, 1}, {0, 0}, {0, 0}, {0, 0}, {0, 0}},
+		},
+		// Back surrounds play on the side ones
+		{
+			name: "5.1 to 5.1 side", from: Layout5_1, to: Layout5_1Side,
+			want: [][]float32{
+				{1, 0, 0, 0, 0, 0}, {0, 1, 0, 0, 0, 0}, {0, 0, 1, 0, 0, 0},
+				{0, 0, 0, 1, 0, 0}, {0, 0, 0, 0, 1, 0}, {0, 0, 0, 0, 0, 1},
+			},
+		},
+		{name: "unknown", from: LayoutUnknown, to: LayoutStereo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := RemixMatrix(tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("RemixMatrix() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				for j := range got[i] {
+					if math.Abs(float64(got[i][j]-tt.want[i][j])) > 1e-6 {
+						t.Fatalf("RemixMatrix() = %v, want %v", got, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChannelMapper_ReadSamples(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		src    Source
+		matrix [][]float32
+		remix  Layout // RemixTo instead of the matrix
+		want   []float32
+		layout Layout
+	}{
+		{name: "swap", src: channelValues(2, 100), matrix: [][]float32{{0, 1}, {1, 0}}, want: []float32{0.2, 0.1}, layout: LayoutStereo},
+		{name: "pick", src: channelValues(6, 100), matrix: [][]float32{{0, 0, 1, 0, 0, 0}}, want: []float32{0.3}, layout: LayoutMono},
+		{name: "duplicate", src: channelValues(1, 100), matrix: [][]float32{{1}, {1}, {0.5}}, want: []float32{0.1, 0.1, 0.05}, layout: LayoutSurround},
+		{name: "remix 5.1", src: channelValues(6, 100), remix: LayoutStereo, want: []float32{
+			(0.1 + foldGain*0.3 + foldGain*0.5) / (1 + 2*foldGain),
+			(0.2 + foldGain*0.3 + foldGain*0.6) / (1 + 2*foldGain),
+		}, layout: LayoutStereo},
+		{name: "remix to side", src: layoutSource{channelValues(6, 100), Layout5_1}, remix: Layout5_1Side, want: []float32{0.1, 0.2, 0.3, 0.4, 0.5, 0.6}, layout: Layout5_1Side},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var m *ChannelMapper
+			var err error
+			if tt.remix != LayoutUnknown {
+				m, err = RemixTo(tt.src, tt.remix)
+			} else {
+				m, err = NewChannelMapper(tt.src, tt.matrix)
+			}
+			if err != nil {
+				t.Fatalf("creating the mapper: %v", err)
+			}
+			if f := m.Format(); f.Channels != len(tt.want) || f.Layout != tt.layout {
+				t.Errorf("Format() = %s, want %d channels %s", f, len(tt.want), tt.layout)
+			}
+
+			got := readAll(t, m, 33*len(tt.want))
+			if len(got) != 100*len(tt.want) {
+				t.Fatalf("read %d samples, want %d", len(got), 100*len(tt.want))
+			}
+			for i, v := range got {
+				if w := tt.want[i%len(tt.want)]; math.Abs(float64(v-w)) > 1e-6 {
+					t.Fatalf("sample %d = %v, want %v", i, v, w)
+				}
+			}
+		})
+	}
+}
+
+func TestChannelMapper_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewChannelMapper(channelValues(2, 10), nil); !errors.Is(err, ErrInvalidChannels) {
+		t.Errorf("NewChannelMapper(nil) error = %v, want ErrInvalidChannels", err)
+	}
+	if _, err := NewChannelMapper(channelValues(2, 10), [][]float32{{1, 0}, {1}}); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("NewChannelMapper(short row) error = %v, want ErrFormatMismatch", err)
+	}
+	if _, err := RemixTo(channelValues(5, 10), LayoutStereo); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("RemixTo() of 5 unknown channels error = %v, want ErrFormatMismatch", err)
+	}
+
+	src := audiotest.NewFaultySource(channelValues(2, 10), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	m, err := NewChannelMapper(src, [][]float32{{0.5, 0.5}})
+	if err != nil {
+		t.Fatalf("NewChannelMapper() error = %v", err)
+	}
+	if _, err := m.ReadSamples(make([]float32, 4)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := m.Close(); err != nil || !src.Closed() {
+		t.Errorf("Close() error = %v, source closed = %v", err, src.Closed())
+	}
+}
@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultChecksumBlock is the audio each checksum of a ChecksumTagger
+// covers when no block length is given.
+const DefaultChecksumBlock = 20 * time.Millisecond
+
+// ChecksumTagger passes a source through unchanged, taking a CRC-32 of the
+// bits of every block of its samples, so that a ChecksumVerifier at the
+// end of a pipeline can prove the pipeline left them untouched. That is
+// what a pipeline meant as a no-op, such as a resampler to the rate the
+// audio already has, must do to be put in an existing recording path.
+//
+//	tag := audio.NewChecksumTagger(src, 0)
+//	v, err := tag.Verifier(audio.NewResampler(tag, tag.SampleRate()))
+//	// v returns ErrNotBitExact from the first block that changed
+//
+// The tagger and the verifier may be read from different goroutines.
+type ChecksumTagger struct {
+	src    Source
+	blocks *checksums
+	sum    blockSum
+}
+
+// NewChecksumTagger creates a ChecksumTagger of src taking a checksum of
+// every block of audio, DefaultChecksumBlock when block is 0. Closing it
+// closes src.
+func NewChecksumTagger(src Source, block time.Duration) *ChecksumTagger {
+	if block <= 0 {
+		block = DefaultChecksumBlock
+	}
+	blockLen := max(FrameLen(src.SampleRate(), block), 1) * max(src.Channels(), 1)
+	return &ChecksumTagger{
+		src:    src,
+		blocks: &checksums{mu: &sync.Mutex{}, blockLen: blockLen},
+		sum:    blockSum{size: blockLen},
+	}
+}
+
+func (t *ChecksumTagger) SampleRate() int    { return t.src.SampleRate() }
+func (t *ChecksumTagger) Channels() int      { return t.src.Channels() }
+func (t *ChecksumTagger) BufSize() int       { return t.src.BufSize() }
+func (t *ChecksumTagger) Format() Format     { return FormatOf(t.src) }
+func (t *ChecksumTagger) Upstream() []Source { return []Source{t.src} }
+
+func (t *ChecksumTagger) Close() error {
+	if err := t.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (t *ChecksumTagger) ReadSamples(dst []float32) (int, error) {
+	n, err := t.src.ReadSamples(dst)
+	t.sum.add(dst[:n], t.blocks.push)
+	if errors.Is(err, io.EOF) {
+		t.blocks.end(t.sum.flush())
+		return n, io.EOF
+	}
+	if err != nil {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, nil
+}
+
+// Verifier returns a ChecksumVerifier of out, the end of a pipeline reading
+// the tagger, which must have its format.
+func (t *ChecksumTagger) Verifier(out Source) (*ChecksumVerifier, error) {
+	if err := FormatOf(out).Compatible(t.Format()); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &ChecksumVerifier{
+		src:    out,
+		blocks: t.blocks,
+		sum:    blockSum{size: t.blocks.blockLen},
+	}, nil
+}
+
+// ChecksumVerifier passes the end of a pipeline through, checking every
+// block of its samples against the checksum its ChecksumTagger took of
+// the same block. ReadSamples returns an error matching ErrNotBitExact,
+// along with the samples, for every block that differs, and for output
+// longer or shorter than the input; reading on checks the next blocks.
+// An error found at the end is returned before io.EOF.
+type ChecksumVerifier struct {
+	src      Source
+	blocks   *checksums
+	sum      blockSum
+	verified int64 // blocks
+	failed   int64
+	ended    bool
+}
+
+func (v *ChecksumVerifier) SampleRate() int    { return v.src.SampleRate() }
+func (v *ChecksumVerifier) Channels() int      { return v.src.Channels() }
+func (v *ChecksumVerifier) BufSize() int       { return v.src.BufSize() }
+func (v *ChecksumVerifier) Format() Format     { return FormatOf(v.src) }
+func (v *ChecksumVerifier) Upstream() []Source { return []Source{v.src} }
+
+func (v *ChecksumVerifier) Close() error {
+	if err := v.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// Verified returns the number of blocks found unchanged so far, and the
+// number found changed. Call it from the goroutine that reads.
+func (v *ChecksumVerifier) Verified() (ok, changed int64) {
+	return v.verified, v.failed
+}
+
+func (v *ChecksumVerifier) ReadSamples(dst []float32) (int, error) {
+	if v.ended {
+		return 0, io.EOF
+	}
+	n, err := v.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	var errs []error
+	v.sum.add(dst[:n], func(crc uint32) { errs = append(errs, v.check(crc, false)) })
+	if errors.Is(err, io.EOF) {
+		if crc, partial := v.sum.flush(); partial {
+			errs = append(errs, v.check(crc, true))
+		}
+		if left := v.blocks.left(); left > 0 {
+			errs = append(errs, fmt.Errorf("%w: output %d blocks short of the input", ErrNotBitExact, left))
+		}
+		// io.EOF follows on the next read
+		v.ended = len(errs) > 0
+	}
+	if verr := errors.Join(errs...); verr != nil {
+		return n, verr
+	}
+	return n, err
+}
+
+// check compares the checksum of the next block of the output with the
+// input's
+func (v *ChecksumVerifier) check(crc uint32, last bool) error {
+	block := v.verified + v.failed
+	want, ok := v.blocks.pop(last)
+	switch {
+	case !ok:
+		v.failed++
+		return fmt.Errorf("%w: output longer than the input, from block %d", ErrNotBitExact, block)
+	case want != crc:
+		v.failed++
+		return fmt.Errorf("%w: block %d, frames %d on", ErrNotBitExact, block, block*int64(v.sum.size/max(v.src.Channels(), 1)))
+	}
+	v.verified++
+	return nil
+}
+
+// checksums carries the checksums of a tagger to its verifier
+type checksums struct {
+	mu       *sync.Mutex
+	blockLen int // samples
+	queue    []uint32
+	ended    bool
+	last     *uint32 // of the partial block at the end of the input
+}
+
+func (c *checksums) push(crc uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queue = append(c.queue, crc)
+}
+
+// end marks the end of the input, with the checksum of the partial block
+// before it
+func (c *checksums) end(crc uint32, partial bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ended = true
+	if partial {
+		c.last = &crc
+	}
+}
+
+// pop returns the checksum of the next whole block, or of the partial
+// block at the end when last is set
+func (c *checksums) pop(last bool) (uint32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if last {
+		if len(c.queue) > 0 || c.last == nil {
+			return 0, false
+		}
+		crc := *c.last
+		c.last = nil
+		return crc, true
+	}
+	if len(c.queue) == 0 {
+		return 0, false
+	}
+	crc := c.queue[0]
+	c.queue = c.queue[1:]
+	return crc, true
+}
+
+// left returns the blocks of the input not matched by the output
+func (c *checksums) left() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.queue)
+	if c.last != nil || !c.ended {
+		n++
+	}
+	return n
+}
+
+// blockSum takes the checksums of blocks of size samples
+type blockSum struct {
+	size   int
+	filled int
+	crc    uint32
+	buf    []byte
+}
+
+// add sums samples, calling done with the checksum of every block they
+// complete
+func (b *blockSum) add(samples []float32, done func(uint32)) {
+	for len(samples) > 0 {
+		n := min(len(samples), b.size-b.filled)
+		if cap(b.buf) < 4*n {
+			b.buf = make([]byte, 4*n)
+		}
+		buf := b.buf[:4*n]
+		for i, v := range samples[:n] {
+			binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+		}
+		b.crc = crc32.Update(b.crc, crc32.IEEETable, buf)
+		b.filled += n
+		samples = samples[n:]
+
+		if b.filled == b.size {
+			done(b.crc)
+			b.crc, b.filled = 0, 0
+		}
+	}
+}
+
+// flush returns the checksum of the partial block summed so far, and
+// whether there is one
+func (b *blockSum) flush() (uint32, bool) {
+	crc, partial := b.crc, b.filled > 0
+	b.crc, b.filled = 0, 0
+	return crc, partial
+}
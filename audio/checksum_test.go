@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestChecksumVerifier(t *testing.T) {
+	t.Parallel()
+
+	// 5 ms blocks are 40 frames at 8 kHz; the source has 10 blocks and a
+	// half
+	tests := []struct {
+		name     string
+		pipeline func(Source) Source
+		chunk    int
+		ok       int64
+		changed  int64
+		errs     int // reads returning ErrNotBitExact
+	}{
+		{name: "pass-through", pipeline: func(s Source) Source { return s }, chunk: 30, ok: 11},
+		{name: "unity gain", pipeline: func(s Source) Source { return NewGain(s, 1) }, chunk: 128, ok: 11},
+		{name: "gain", pipeline: func(s Source) Source { return NewGain(s, 0.5) }, chunk: 128, changed: 11, errs: 7},
+		{name: "fade in", pipeline: func(s Source) Source { return FadeIn(s, 5*time.Millisecond) }, chunk: 80, ok: 10, changed: 1, errs: 1},
+		{
+			// The partial block at the end differs
+			name: "longer", chunk: 64, ok: 10, changed: 1, errs: 1,
+			pipeline: func(s Source) Source {
+				c, _ := NewConcat(s, newSineSource(8000, 2, 10, 440))
+				return c
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tag := NewChecksumTagger(newSineSource(8000, 2, 420, 440), 5*time.Millisecond)
+			v, err := tag.Verifier(tt.pipeline(tag))
+			if err != nil {
+				t.Fatalf("Verifier() error = %v", err)
+			}
+
+			errs, buf := 0, make([]float32, tt.chunk)
+			for {
+				_, err := v.ReadSamples(buf)
+				if errors.Is(err, ErrNotBitExact) {
+					errs++
+					continue
+				}
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+			if ok, changed := v.Verified(); ok != tt.ok || changed != tt.changed || errs != tt.errs {
+				t.Errorf("Verified() = %d, %d with %d errors, want %d, %d with %d", ok, changed, errs, tt.ok, tt.changed, tt.errs)
+			}
+		})
+	}
+}
+
+func TestChecksumVerifier_Short(t *testing.T) {
+	t.Parallel()
+
+	tag := NewChecksumTagger(newSineSource(8000, 1, 400, 440), 0)
+	short := newSineSource(8000, 1, 200, 440)
+	v, err := tag.Verifier(short)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The input is read in full, the output is half of it
+	readAll(t, tag, 100)
+	if _, err := v.ReadSamples(make([]float32, 400)); !errors.Is(err, ErrNotBitExact) {
+		t.Errorf("ReadSamples() error = %v, want ErrNotBitExact", err)
+	}
+	if _, err := v.ReadSamples(make([]float32, 400)); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadSamples() after the end error = %v, want io.EOF", err)
+	}
+	if ok, changed := v.Verified(); ok != 1 || changed != 1 {
+		t.Errorf("Verified() = %d, %d, want 1, 1", ok, changed)
+	}
+
+	if _, err := tag.Verifier(newSineSource(16000, 1, 10, 440)); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("Verifier(16 kHz) error = %v, want ErrFormatMismatch", err)
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Concat is a Source playing sources one after the other, as a single
+// clip: the prompts of a sentence, or a recording split into files. Each
+// source is closed as soon as it ends.
+type Concat struct {
+	format  Format
+	srcs    []Source // not yet ended, the first one playing
+	bufSize int
+}
+
+// NewConcat creates a Concat of srcs, which must all have the rate and
+// channel count of the first one. On error the sources are left open.
+func NewConcat(srcs ...Source) (*Concat, error) {
+	if len(srcs) == 0 {
+		return nil, fmt.Errorf("%w: no sources to concatenate", ErrInvalidState)
+	}
+
+	format := FormatOf(srcs[0])
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("source 0: %w", err)
+	}
+	bufSize := 0
+	for i, src := range srcs {
+		if err := FormatOf(src).Compatible(format); err != nil {
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+		bufSize = max(bufSize, src.BufSize())
+	}
+
+	return &Concat{format: format, srcs: srcs, bufSize: bufSize}, nil
+}
+
+// NewConcatTo creates a Concat of srcs converted to format as needed:
+// sources of another rate are resampled, and those of another channel
+// count remixed with RemixTo, so prompts recorded apart play as one. On
+// error the sources are left open.
+func NewConcatTo(format Format, srcs ...Source) (*Concat, error) {
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if format.Layout.Count() != format.Channels {
+		format.Layout = DefaultLayout(format.Channels)
+	}
+
+	conv := make([]Source, len(srcs))
+	for i, src := range srcs {
+		from := FormatOf(src)
+		if err := from.Validate(); err != nil {
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+		a := Adaptation{Requested: from, Format: from}
+		a.Format.Rate, a.Format.Channels, a.Format.Layout = format.Rate, format.Channels, format.Layout
+		if from.Channels == format.Channels {
+			a.Format.Layout = from.Layout
+		}
+
+		var err error
+		if conv[i], err = a.Apply(src); err != nil {
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+	}
+	return NewConcat(conv...)
+}
+
+func (c *Concat) SampleRate() int { return c.format.Rate }
+func (c *Concat) Channels() int   { return c.format.Channels }
+func (c *Concat) BufSize() int    { return c.bufSize }
+func (c *Concat) Format() Format  { return c.format }
+
+// Upstream returns the clips not played to the end yet.
+func (c *Concat) Upstream() []Source { return slices.Clone(c.srcs) }
+
+// Remaining returns the number of sources not yet ended, the playing one
+// included.
+func (c *Concat) Remaining() int { return len(c.srcs) }
+
+// ReadSamples fills dst from the playing source and those after it, and
+// returns io.EOF once the last one has ended. A source that fails is left
+// for Close, and its error returned with the samples read before it.
+func (c *Concat) ReadSamples(dst []float32) (int, error) {
+	ch := c.format.Channels
+	if len(dst)%ch != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	written := 0
+	for written < len(dst) && len(c.srcs) > 0 {
+		n, err := c.srcs[0].ReadSamples(dst[written:])
+		written += n - n%ch
+
+		if errors.Is(err, io.EOF) {
+			cerr := c.srcs[0].Close()
+			c.srcs = c.srcs[1:]
+			if cerr != nil {
+				return written, fmt.Errorf("%w", cerr)
+			}
+			continue
+		}
+		if err != nil {
+			return written, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			// Nothing yet from this source: let the caller come back
+			break
+		}
+	}
+
+	if len(c.srcs) == 0 {
+		return written, io.EOF
+	}
+	return written, nil
+}
+
+// Close closes the sources that have not ended.
+func (c *Concat) Close() error {
+	var errs []error
+	for _, src := range c.srcs {
+		errs = append(errs, src.Close())
+	}
+	c.srcs = nil
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestConcat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		chunk int
+		short bool
+	}{
+		{name: "small reads", chunk: 7},
+		{name: "large reads", chunk: 1000},
+		{name: "short reads", chunk: 64, short: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []audiotest.FaultOption
+			if tt.short {
+				opts = append(opts, audiotest.WithShortReads(5))
+			}
+			parts := []*audiotest.FaultySource{
+				audiotest.NewFaultySource(newConstantSource(8000, 1, 100, 0.1), opts...),
+				audiotest.NewFaultySource(newConstantSource(8000, 1, 0, 0.2), opts...),
+				audiotest.NewFaultySource(newConstantSource(8000, 1, 50, 0.3), opts...),
+			}
+			c, err := NewConcat(parts[0], parts[1], parts[2])
+			if err != nil {
+				t.Fatalf("NewConcat() error = %v", err)
+			}
+
+			got := readAll(t, c, tt.chunk)
+			if len(got) != 150 || got[0] != 0.1 || got[99] != 0.1 || got[100] != 0.3 || got[149] != 0.3 {
+				t.Fatalf("read %d samples, want 100 at 0.1 then 50 at 0.3", len(got))
+			}
+			for i, p := range parts {
+				if !p.Closed() {
+					t.Errorf("source %d not closed once ended", i)
+				}
+			}
+			if c.Remaining() != 0 {
+				t.Errorf("Remaining() = %d, want 0", c.Remaining())
+			}
+		})
+	}
+}
+
+func TestConcat_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewConcat(); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("NewConcat() error = %v, want ErrInvalidState", err)
+	}
+	if _, err := NewConcat(newSilentSource(8000, 1, 10), newSilentSource(16000, 1, 10)); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("NewConcat(8 kHz, 16 kHz) error = %v, want ErrFormatMismatch", err)
+	}
+
+	bad := audiotest.NewFaultySource(newSilentSource(8000, 1, 100), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	next := audiotest.NewFaultySource(newSilentSource(8000, 1, 100))
+	c, err := NewConcat(bad, next)
+	if err != nil {
+		t.Fatalf("NewConcat() error = %v", err)
+	}
+	if _, err := c.ReadSamples(make([]float32, 10)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if _, err := c.ReadSamples(make([]float32, 3)); err != nil {
+		t.Errorf("ReadSamples() error = %v, want none", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !bad.Closed() || !next.Closed() {
+		t.Error("Close() did not close the remaining sources")
+	}
+}
+
+func TestNewConcatTo(t *testing.T) {
+	t.Parallel()
+
+	// A stereo 8 kHz prompt, a mono one and a 16 kHz one, of 100 ms each
+	c, err := NewConcatTo(Format{Rate: 8000, Channels: 2},
+		newConstantSource(8000, 2, 800, 0.5),
+		newConstantSource(8000, 1, 800, 0.5),
+		newConstantSource(16000, 2, 1600, 0.5),
+	)
+	if err != nil {
+		t.Fatalf("NewConcatTo() error = %v", err)
+	}
+	if f := c.Format(); f.Rate != 8000 || f.Channels != 2 || f.Layout != LayoutStereo {
+		t.Errorf("Format() = %v, want 8000 Hz stereo", f)
+	}
+
+	got := readAll(t, c, 256)
+	if frames := len(got) / 2; frames < 2390 || frames > 2410 {
+		t.Fatalf("played %d frames, want about 2400", frames)
+	}
+	for _, f := range []int{0, 799, 800, 1599} {
+		if got[2*f] == 0 || got[2*f] != got[2*f+1] {
+			t.Errorf("frame %d = %v, want the same on both channels", f, got[2*f:2*f+2])
+		}
+	}
+
+	if _, err := NewConcatTo(Format{Rate: 8000, Channels: 1}, newConstantSource(8000, 0, 10, 0)); !errors.Is(err, ErrInvalidChannels) {
+		t.Errorf("NewConcatTo(0 channels) error = %v, want ErrInvalidChannels", err)
+	}
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// sniffLen is how much of a stream DetectFormat looks at: enough for the
+// first Ogg page header and the start of its packet
+const sniffLen = 64
+
+// DetectFormat returns the registry keys a stream starting with header
+// may be registered under, most specific first, or nil when it is not
+// recognized:
+//
+//   - RIFF/WAVE: "wav", preceded by "g711" for µ-law and A-law files and
+//     by "wav49" for GSM 06.10 ones
+//   - ID3 tag or MPEG layer III frame sync: "mp3"
+//   - Ogg: "vorbis" or "opus" by the first packet, then "ogg"
+//   - FORM/AIFF and FORM/AIFC: "aiff", "aif"
+//   - fLaC: "flac"
+//   - PCMZ: "pcmz", the intermediate format of formats/pcmz
+func DetectFormat(header []byte) []string {
+	switch {
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		// The fmt chunk is almost always first
+		if len(header) >= 22 && string(header[12:16]) == "fmt " {
+			switch binary.LittleEndian.Uint16(header[20:22]) {
+			case 6, 7:
+				return []string{"g711", "wav"}
+			case 0x31:
+				return []string{"wav49", "wav"}
+			}
+		}
+		return []string{"wav"}
+
+	case len(header) >= 12 && string(header[0:4]) == "FORM" &&
+		(string(header[8:12]) == "AIFF" || string(header[8:12]) == "AIFC"):
+		return []string{"aiff", "aif"}
+
+	case bytes.HasPrefix(header, []byte("PCMZ")):
+		return []string{"pcmz"}
+
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return []string{"flac"}
+
+	case bytes.HasPrefix(header, []byte("OggS")):
+		// The first packet follows the 27-byte page header and the
+		// segment table
+		if len(header) > 27 {
+			packet := header[min(27+int(header[26]), len(header)):]
+			switch {
+			case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+				return []string{"vorbis", "ogg"}
+			case bytes.HasPrefix(packet, []byte("OpusHead")):
+				return []string{"opus", "ogg"}
+			}
+		}
+		return []string{"ogg"}
+
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return []string{"mp3"}
+
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && (header[1]>>1)&0x03 == 0x01:
+		// Frame sync with layer III; ADTS AAC has layer 0
+		return []string{"mp3"}
+	}
+	return nil
+}
+
+// DetectAndDecode recognizes the format of r from its first bytes and
+// decodes it with the matching registered decoder, whatever the file is
+// named. The bytes looked at are passed on to the decoder: seekable
+// readers are seeked back, so decoders can still offer random access, and
+// other readers are buffered. Unrecognized streams and formats without a
+// registered decoder fail with ErrUnknownFormat, and formats the policy of
+// the registry refuses with ErrRefusedByPolicy.
+func (r *Registry) DetectAndDecode(in io.Reader) (Source, error) {
+	dec, in, err := r.detect(in)
+	if err != nil {
+		return nil, err
+	}
+	return dec.Decode(in)
+}
+
+// detect returns the registered decoder for the format of in, and a reader
+// of in from its start
+func (r *Registry) detect(in io.Reader) (Decoder, io.Reader, error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(in, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	header = header[:n]
+
+	keys := DetectFormat(header)
+	var dec Decoder
+	var refused error // of the first key the policy refuses
+	for _, key := range keys {
+		d, err := r.Lookup(key)
+		if err == nil {
+			dec = d
+			break
+		}
+		if refused == nil && errors.Is(err, ErrRefusedByPolicy) {
+			refused = err
+		}
+	}
+	if dec == nil {
+		switch {
+		case refused != nil:
+			return nil, nil, refused
+		case len(keys) == 0:
+			return nil, nil, ErrUnknownFormat
+		}
+		return nil, nil, fmt.Errorf("%w: no decoder registered for %s", ErrUnknownFormat, keys[0])
+	}
+
+	if s, ok := in.(io.ReadSeeker); ok {
+		if _, err := s.Seek(int64(-n), io.SeekCurrent); err == nil {
+			return dec, in, nil
+		}
+	}
+	return dec, io.MultiReader(bytes.NewReader(header), in), nil
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// oggPage returns the start of an Ogg page with one segment holding packet
+func oggPage(packet string) []byte {
+	page := append([]byte("OggS"), make([]byte, 22)...)
+	page = append(page, 1, byte(len(packet)))
+	return append(page, packet...)
+}
+
+func TestDetectFormat(t *testing.T) {
+	t.Parallel()
+
+	wavFmt := func(tag byte) []byte {
+		return append([]byte("RIFF\x24\x00\x00\x00WAVEfmt \x10\x00\x00\x00"), tag, 0, 1, 0)
+	}
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   []string
+	}{
+		{"wav", wavFmt(1), []string{"wav"}},
+		{"wav without fmt first", []byte("RIFF\x00\x00\x00\x00WAVELIST"), []string{"wav"}},
+		{"mulaw wav", wavFmt(7), []string{"g711", "wav"}},
+		{"alaw wav", wavFmt(6), []string{"g711", "wav"}},
+		{"gsm wav", wavFmt(0x31), []string{"wav49", "wav"}},
+		{"riff avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"), nil},
+		{"aiff", []byte("FORM\x00\x00\x00\x00AIFFCOMM"), []string{"aiff", "aif"}},
+		{"aifc", []byte("FORM\x00\x00\x00\x00AIFCFVER"), []string{"aiff", "aif"}},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), []string{"flac"}},
+		{"pcmz", []byte("PCMZ\x01\x01\x01\x00"), []string{"pcmz"}},
+		{"ogg vorbis", oggPage("\x01vorbis\x00\x00\x00\x00"), []string{"vorbis", "ogg"}},
+		{"ogg opus", oggPage("OpusHead\x01\x02"), []string{"opus", "ogg"}},
+		{"ogg other", oggPage("\x80theora"), []string{"ogg"}},
+		{"id3", []byte("ID3\x04\x00\x00"), []string{"mp3"}},
+		{"mpeg frame", []byte{0xFF, 0xFB, 0x90, 0x64}, []string{"mp3"}},
+		{"mpeg 2 frame", []byte{0xFF, 0xF3, 0x48, 0xC4}, []string{"mp3"}},
+		{"adts aac", []byte{0xFF, 0xF1, 0x50, 0x80}, nil},
+		{"text", []byte("hello, world"), nil},
+		{"empty", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := DetectFormat(tt.header); !slices.Equal(got, tt.want) {
+				t.Errorf("DetectFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingDecoder decodes any stream into a silent source, keeping the
+// bytes it was given
+type recordingDecoder struct {
+	got *[]byte
+}
+
+func (d recordingDecoder) Decode(r io.Reader) (Source, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	*d.got = b
+	return newSilentSource(8000, 1, 0), nil
+}
+
+func TestRegistry_DetectAndDecode(t *testing.T) {
+	t.Parallel()
+
+	opus := append(oggPage("OpusHead\x01\x02"), bytes.Repeat([]byte{0x55}, 100)...)
+	tests := []struct {
+		name string
+		in   func([]byte) io.Reader
+	}{
+		{"seekable", func(b []byte) io.Reader { return bytes.NewReader(b) }},
+		{"stream", func(b []byte) io.Reader { return iotest.OneByteReader(bytes.NewReader(b)) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var vorbis, ogg []byte
+			reg := NewRegistry()
+			reg.Register("vorbis", recordingDecoder{&vorbis})
+			reg.Register("ogg", recordingDecoder{&ogg})
+
+			if _, err := reg.DetectAndDecode(tt.in(opus)); err != nil {
+				t.Fatalf("DetectAndDecode() error = %v", err)
+			}
+			if vorbis != nil {
+				t.Error("Opus stream went to the Vorbis decoder")
+			}
+			if !bytes.Equal(ogg, opus) {
+				t.Errorf("decoder got %d bytes, want the whole %d byte stream", len(ogg), len(opus))
+			}
+		})
+	}
+
+	// Streams shorter than the sniffed length
+	var wav []byte
+	reg := NewRegistry()
+	reg.Register("wav", recordingDecoder{&wav})
+	short := []byte("RIFF\x00\x00\x00\x00WAVE")
+	if _, err := reg.DetectAndDecode(bytes.NewBuffer(short)); err != nil {
+		t.Fatalf("DetectAndDecode(short) error = %v", err)
+	}
+	if !bytes.Equal(wav, short) {
+		t.Errorf("decoder got %q, want %q", wav, short)
+	}
+}
+
+func TestRegistry_DetectAndDecodeErrors(t *testing.T) {
+	t.Parallel()
+
+	reg := NewRegistry()
+	reg.Register("wav", recordingDecoder{new([]byte)})
+
+	tests := []struct {
+		name string
+		in   io.Reader
+		want error
+	}{
+		{"unknown", bytes.NewReader([]byte("not audio at all")), ErrUnknownFormat},
+		{"empty", bytes.NewReader(nil), ErrUnknownFormat},
+		{"not registered", bytes.NewReader([]byte("fLaC\x00\x00\x00\x22")), ErrUnknownFormat},
+		{"read error", iotest.ErrReader(audiotest.ErrInjected), audiotest.ErrInjected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := reg.DetectAndDecode(tt.in); !errors.Is(err, tt.want) {
+				t.Errorf("DetectAndDecode() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
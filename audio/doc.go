@@ -7,6 +7,7 @@
 //   - Resampler for sample rate conversion
 //   - MonoMixer for channel mixing
 //   - Format registry for decoder registration
+//   - Format descriptor and channel layouts
 //
 // # Source Interface
 //
@@ -33,6 +34,15 @@
 //
 // Resampling works for both upsampling and downsampling with high quality.
 //
+// Other algorithms plug in as a ResamplerBackend: LinearResampling for
+// speed, CubicResampling (the Resampler above), SincResampling for
+// band-limited quality, or any implementation of the interface, such as a
+// binding to libsamplerate. Pick one per pipeline, or for every pipeline
+// the library builds with SetDefaultResampler:
+//
+//	audio.SetDefaultResampler(audio.SincResampling{})
+//	resampled := audio.Resample(source, 48000)
+//
 // # Channel Mixing
 //
 // The MonoMixer converts multi-channel audio to mono by averaging:
@@ -43,6 +53,265 @@
 //
 // Mono audio is often required for voice processing applications.
 //
+// To keep the channels apart instead, as the caller and agent legs of a
+// stereo call recording, Split returns a mono source for each:
+//
+//	legs := audio.Split(recording)
+//	caller, agent := legs[0], legs[1]
+//
+// ChannelMapper remixes channels through any mix matrix, and RemixTo
+// builds the matrix between two speaker layouts:
+//
+//	swapped, err := audio.NewChannelMapper(stereo, [][]float32{{0, 1}, {1, 0}})
+//	downmix, err := audio.RemixTo(movie, audio.LayoutStereo) // 5.1 in
+//
+// # Format Descriptor
+//
+// Format bundles the rate, channel count, speaker Layout and native
+// SampleKind of a stream. Sources may implement Formatter to report it;
+// FormatOf falls back to SampleRate and Channels otherwise:
+//
+//	f := audio.FormatOf(source)
+//	if err := f.Validate(); err != nil {
+//	    return err
+//	}
+//
+// When a source reports an explicit Layout (e.g. 5.1), MonoMixer uses
+// layout aware downmix weights instead of a plain average. Decoders
+// read it from the file: LayoutFromMask for a WAVE_FORMAT_EXTENSIBLE
+// channel mask, LayoutFromCAFLabels for CAF channel labels and
+// VorbisLayout for the channel order of Vorbis and Opus, whose samples
+// ReorderChannels puts in the interleaving order of the Layout.
+//
+// # Mixing
+//
+// Mixer sums any number of sources of the same format, each at its own
+// gain, as a conference bridge mixes its parties:
+//
+//	bridge, err := audio.NewMixer(alice, bob, carol)
+//	bridge.SetGain(2, 0.5) // carol is too loud
+//
+// TrackMerger instead keeps each party on its own channels, for a
+// multi-track recording of the conference. It places each one on the
+// timeline at the wall clock time its first RTP timestamp maps to, by a
+// sender report, rather than by when its packets arrived:
+//
+//	rec, err := audio.NewTrackMerger(
+//	    audio.Track{Source: alice, Timestamp: aliceFirst, Report: aliceSR},
+//	    audio.Track{Source: bob, Timestamp: bobFirst, Report: bobSR},
+//	)
+//
+// # Tee
+//
+// NewTee goes the other way, feeding one source to several pipelines,
+// such as a recorder and live analysis, through a ring buffer they share,
+// so the input is decoded once:
+//
+//	outs := audio.NewTee(call, 2)
+//	go wav.Encode(file, outs[0])
+//	segments, err := audio.DetectSpeech(outs[1], audio.VADOptions{})
+//
+// # Gain and Normalization
+//
+// Gain scales a single source, by a linear factor or in dB. Normalize
+// measures a whole source first, then scales it so its peak, or its RMS
+// level, reaches a target, bringing prompts recorded apart to one level:
+//
+//	louder := audio.NewGainDB(source, 6)
+//	prompt, err := audio.Normalize(source, audio.NormalizeOptions{
+//	    Mode:   audio.NormalizeRMS,
+//	    Target: -18, // dBFS
+//	})
+//
+// # Ducking
+//
+// DuckingMixer lays a voice over music and turns the music down while the
+// voice speaks, fading it back after a pause:
+//
+//	mix, err := audio.NewDuckingMixer(holdMusic, announcement, audio.DuckOptions{
+//	    Depth:   18,                     // dB
+//	    Release: 800 * time.Millisecond, // slow return
+//	})
+//
+// # FIR Filtering
+//
+// FIR convolves a source with arbitrary taps using FFT overlap-save, which
+// keeps long filters (sinc kernels, impulse responses) affordable:
+//
+//	filtered := audio.NewFIR(source, taps)
+//
+// # Fixed-Size Frames
+//
+// Codecs, VAD and speech engines consume audio in frames of a fixed
+// duration. Framer regroups whatever a source returns into such frames,
+// zero padding the last one:
+//
+//	framer := audio.NewFramer(source, audio.FrameLen(source.SampleRate(), 20*time.Millisecond))
+//	for {
+//	    frame, valid, err := framer.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    // ...
+//	}
+//
+// # Triggered Capture
+//
+// Trigger holds back audio on an always-on line until it gets loud enough
+// (or Fire is called by, say, a wake-word detector), then passes it on,
+// including a short pre-roll so the first syllable is not clipped:
+//
+//	gate := audio.NewTrigger(line, audio.TriggerOptions{
+//	    Threshold: 0.03,
+//	    PreRoll:   300 * time.Millisecond,
+//	})
+//
+// Reset rearms the gate once the command has been handled.
+//
+// # Barge-In
+//
+// BargeIn sits in the inbound chain of a call and tells when the caller
+// talks over the prompt being played. Prompts played through it are
+// watched while they play and, with AutoStop, cut short:
+//
+//	inbound := audio.NewBargeIn(leg, audio.BargeInOptions{
+//	    MinSpeech: 300 * time.Millisecond,
+//	    AutoStop:  true,
+//	    OnBargeIn: func() { menu.Listen() },
+//	})
+//	out = inbound.Play(menuPrompt)
+//
+// # Voice Activity Detection
+//
+// VAD classifies the audio it passes on as speech or not, from its energy
+// and zero-crossing rate, and reports the segments of speech it finds,
+// for trimming silence from recordings or measuring talk time:
+//
+//	vad := audio.NewVAD(leg, audio.VADOptions{
+//	    OnSegment: func(s audio.SpeechSegment) { talk += s.Duration(rate) },
+//	})
+//
+// DetectSpeech runs one over a whole source and returns the segments.
+//
+// TrimSilence strips the dead air before and after a recording, such as a
+// voicemail, and SplitOnSilence cuts one into parts at its long pauses:
+//
+//	trimmed := audio.TrimSilence(message, -40, 300*time.Millisecond)
+//	parts, err := audio.SplitOnSilence(announcements, -40, time.Second)
+//
+// # Speed Reading
+//
+// Reviewers listening to calls for QA prefer them without the long
+// pauses and a little faster. SpeedRead exports that version of a
+// recording: ShortenSilence cuts every silence down to a maximum, 300 ms
+// by default, and TimeStretch speeds it up without raising the pitch:
+//
+//	fast, err := audio.SpeedRead(call, audio.SpeedReadOptions{Speed: 1.25})
+//	err = wav.Encode(out, fast)
+//
+// Stages that move audio in time implement Retimer, and MapTime maps a
+// time of the input of a pipeline through all of them, for keeping the
+// word timestamps of a transcript in sync (see speech.Align):
+//
+//	at, kept := audio.MapTime(fast, 90*time.Second) // where 1:30 of the call plays
+//
+// # Scheduled Playout
+//
+// Playout is an endless source for a bridge or stream that must never run
+// dry: it plays clips at their scheduled stream time and silence between
+// them.
+//
+//	p, _ := audio.NewPlayout(audio.Format{Rate: 8000, Channels: 1})
+//	p.Schedule(p.Elapsed()+time.Until(boarding), announcement)
+//	p.Queue(welcome) // as soon as the line is free
+//
+// PriorityQueue plays whatever has the highest priority, so a page can cut
+// into hold music, which then carries on where it stopped:
+//
+//	q, _ := audio.NewPriorityQueue(audio.Format{Rate: 8000, Channels: 1})
+//	q.Push(0, music, audio.Resume)
+//	q.Push(10, page, audio.Discard) // interrupts the music
+//
+// Concat plays clips back to back as one, as a sentence is built from
+// prompts:
+//
+//	sentence, err := audio.NewConcat(youHave, three, newMessages)
+//
+// NewConcatTo resamples and remixes prompts recorded apart to one format
+// first. Loop and LoopForever replay a source that can seek, such as a
+// decoded file:
+//
+//	music, err := audio.LoopForever(holdMusic)
+//	twice, err := audio.Loop(announcement, 2)
+//
+// Music made to loop marks the loop in its file, as a WAV smpl chunk or an
+// AIFF INST chunk; the decoders report it through LoopPoints, and the
+// Looper plays the intro once and then repeats only the loop.
+//
+// Where clips do not start and end in silence, FadeIn and FadeOut ramp
+// their edges, and Crossfade blends one into the next, so the boundaries
+// do not click:
+//
+//	music := audio.FadeIn(holdMusic, 500*time.Millisecond)
+//	joined, err := audio.Crossfade(intro, loop, 200*time.Millisecond)
+//
+// # Pacing and Backpressure
+//
+// A Pacer writes a source to a Sink in real time, a frame per tick. A sink
+// that cannot keep up, such as a congested network stream, returns
+// ErrNotReady instead of blocking, and the Pacer buffers or drops the
+// audio by its BackpressurePolicy while the source keeps its pace:
+//
+//	p, _ := audio.NewPacer(mix, stream, audio.PacerOptions{Policy: audio.BackpressureDrop})
+//	err := p.Run(ctx)
+//
+// # Jitter Buffer
+//
+// Audio received from the network comes in packets that arrive late, out
+// of order, twice or not at all. A JitterBuffer takes them from a channel,
+// puts them back in order, conceals short losses by repeating the audio
+// before them and plays silence through longer ones, so its reader gets a
+// steady stream at the pace it reads:
+//
+//	jb, err := audio.NewJitterBuffer(packets, audio.JitterOptions{Format: audio.Format{Rate: 8000, Channels: 1}})
+//	p, err := playback.NewPlayer(jb, playback.Options{})
+//
+// Stats reports the packets lost, late and duplicated, for call quality
+// metrics.
+//
+// # Random Access
+//
+// Sources that can jump around implement the optional Seeker interface;
+// the format decoders do when they read from an io.ReadSeeker such as
+// *os.File:
+//
+//	if s, ok := source.(audio.Seeker); ok {
+//	    err = s.SeekFrame(int64(90 * source.SampleRate())) // 1:30
+//	}
+//
+// Likewise, sources whose container records the length of the stream
+// implement Lengther, so a player can show it before decoding anything:
+//
+//	if l, ok := source.(audio.Lengther); ok && l.TotalFrames() >= 0 {
+//	    fmt.Println("duration:", l.Duration())
+//	}
+//
+// and sources whose container carries tags implement Metadata:
+//
+//	if m, ok := source.(audio.Metadata); ok {
+//	    fmt.Println(m.Metadata().Artist, "-", m.Metadata().Title)
+//	}
+//
+// DecodeHeader gathers all three into a Header without decoding any audio,
+// for checking an upload before accepting it. Decoders whose Decode reads
+// more than the header, as the MP3 one indexes its frames, implement
+// HeaderDecoder to read the header alone:
+//
+//	h, err := audio.DecodeHeader(mp3.Decoder{}, upload)
+//	if err == nil && h.Format.Channels > 2 {
+//	    return errTooManyChannels
+//	}
+//
 // # Format Registry
 //
 // The registry allows dynamic decoder registration:
@@ -52,6 +321,49 @@
 //	decoder, _ := registry.Get("wav")
 //
 // This is useful for applications that need to support multiple formats.
+// DetectAndDecode picks the decoder from the content instead of a file
+// name, so misnamed files and HTTP bodies decode too:
+//
+//	source, err := registry.DetectAndDecode(resp.Body)
+//
+// Decoders can also be registered by MIME type, for picking one from a
+// Content-Type header, and Formats lists what is registered:
+//
+//	registry.RegisterMIME("audio/mpeg", mp3.Decoder{})
+//	decoder, ok := registry.GetByMIME(resp.Header.Get("Content-Type"))
+//
+// A Policy keeps formats a deployment must not use out of reach, such as
+// patent encumbered codecs, without unregistering them. Lookup and
+// DetectAndDecode then fail with ErrRefusedByPolicy, naming the policy:
+//
+//	registry.SetPolicy(audio.DenyFormats("no-mp3", "MP3"))
+//	_, err := registry.Lookup("mp3") // format refused by the codec policy: mp3 refused by the "no-mp3" policy
+//
+// The decoders of formats/... report the Capabilities of their format:
+// bit depths, channels and rates, whether it is written too, seeks and
+// carries tags. A request can be checked before a job is started on it:
+//
+//	caps, _ := registry.Capabilities("wav")
+//	if err := caps.CheckEncode(audio.Format{Rate: 8000, Channels: 1, SampleKind: audio.SampleInt24}); err != nil {
+//	    return err // not supported by the format: WAV does not hold 24-bit samples, only [16]
+//	}
+//
+// Matrix gathers the Capabilities of every registered decoder, with the
+// MIME types it is registered for, into a supported format matrix, and
+// WriteMatrix renders it as a Markdown table, so an application lists the
+// formats it supports from the decoders it has rather than by hand:
+//
+//	err := audio.WriteMatrix(os.Stdout, registry.Matrix())
+//
+// Negotiate proposes the nearest format the encoder writes instead, a
+// downmix or a rate change, and asks before taking it:
+//
+//	a, err := caps.Negotiate(audio.FormatOf(movie), func(a audio.Adaptation) bool {
+//	    return confirm(strings.Join(a.Changes(), ", ")) // "downmix 5.1 to stereo"
+//	})
+//	if err == nil {
+//	    src, err = a.Apply(movie)
+//	}
 //
 // # Sample Format
 //
@@ -63,6 +375,38 @@
 // This normalized format makes it easy to process audio without worrying
 // about bit depths and ensures no clipping during intermediate processing.
 //
+// Measurement pipelines, where the rounding to float32 between the stages
+// of a long chain of filters shows, can keep float64 samples instead:
+// Source64 is the float64 counterpart of Source, To64 and From64 convert
+// between the two, and dsp.Biquad64 filters without rounding:
+//
+//	s := audio.To64(source)
+//	for _, p := range bands {
+//	    s, err = dsp.NewBiquad64(s, p)
+//	}
+//	out := audio.From64(s)
+//
+// # Debugging Pipelines
+//
+// Snapshot walks a pipeline from its last stage to its inputs and returns
+// its topology, with the format of each stage, which encodes to JSON or,
+// with WriteDOT, to Graphviz. A Probe put between stages counts the reads,
+// samples, underruns and errors at that point:
+//
+//	in := audio.NewProbe(decoder, "caller")
+//	out := audio.NewProbe(audio.NewResampler(audio.NewMonoMixer(in), 8000), "out")
+//	// ... on a stall
+//	audio.Snapshot(out).WriteDOT(f)
+//
+// A pipeline meant to leave the audio alone, as when it is put in an
+// existing recording path, can be proved bit-transparent: a
+// ChecksumTagger takes a checksum of every block of its input, and a
+// ChecksumVerifier at its end returns ErrNotBitExact for a block that
+// differs:
+//
+//	tag := audio.NewChecksumTagger(decoder, 0)
+//	v, err := tag.Verifier(buildPipeline(tag))
+//
 // # Performance Considerations
 //
 // The audio processing functions are optimized for performance:
@@ -90,4 +434,15 @@
 //	    }
 //	    // Process n samples from buf
 //	}
+//
+// Decoders of chained streams (chained Ogg, concatenated WAV files) return
+// a *FormatChangedError, matching ErrFormatChanged, where the rate or
+// channel count changes. The source keeps going in the new format, so
+// the pipeline after it can be rebuilt before reading on:
+//
+//	var changed *audio.FormatChangedError
+//	if errors.As(err, &changed) {
+//	    mono = audio.NewMonoMixer(source) // picks up changed.To
+//	    continue
+//	}
 package audio
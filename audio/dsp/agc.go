@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dsp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Defaults of AGCOptions.
+const (
+	DefaultAGCTarget     = -18.0 // dBFS RMS
+	DefaultAGCMaxGain    = 30.0  // dB
+	DefaultAGCNoiseFloor = -50.0 // dBFS RMS
+	DefaultAGCAttack     = 50 * time.Millisecond
+	DefaultAGCDecay      = 2 * time.Second
+)
+
+// agcWindow is the time constant of the level an AGC measures
+const agcWindow = 50 * time.Millisecond
+
+// AGCOptions configures an AGC. Zero values select the defaults.
+type AGCOptions struct {
+	// Target is the RMS level, in dBFS, the AGC brings the source to. The
+	// default, -18 dBFS, leaves headroom for the peaks of speech.
+	Target float64
+
+	// MaxGain is the most the AGC boosts a quiet source, in dB, 30 by
+	// default. It does not limit how far a loud source is turned down.
+	MaxGain float64
+
+	// NoiseFloor is the RMS level, in dBFS, under which the gain holds,
+	// so that pauses and line noise are not boosted. It defaults to
+	// -50 dBFS.
+	NoiseFloor float64
+
+	// Attack is how fast the gain falls when the source gets louder, and
+	// Decay how fast it rises when the source gets quieter: the times the
+	// gain takes to cover about 63% of a change. Attack is short, 50 ms by
+	// default, so a party who starts shouting is turned down at once;
+	// Decay is long, 2 s, so the gain does not pump between words.
+	Attack time.Duration
+	Decay  time.Duration
+}
+
+func (o AGCOptions) withDefaults() AGCOptions {
+	if o.Target == 0 {
+		o.Target = DefaultAGCTarget
+	}
+	if o.MaxGain <= 0 {
+		o.MaxGain = DefaultAGCMaxGain
+	}
+	if o.NoiseFloor == 0 {
+		o.NoiseFloor = DefaultAGCNoiseFloor
+	}
+	if o.Attack <= 0 {
+		o.Attack = DefaultAGCAttack
+	}
+	if o.Decay <= 0 {
+		o.Decay = DefaultAGCDecay
+	}
+	return o
+}
+
+// AGC is an automatic gain control: it keeps the level of a source near a
+// target as it drifts, such as the far end of a call, which comes in
+// louder or quieter by the phone, the network and the talker.
+//
+// The level is the RMS over all channels, which are turned up or down
+// alike. Samples the gain would push over full scale are clipped, so put a
+// Limiter after an AGC whose sources can jump.
+type AGC struct {
+	src      audio.Source
+	channels int
+	opts     AGCOptions
+	window   float64 // smoothing coefficients per frame
+	attack   float64
+	decay    float64
+	power    float64 // mean square, smoothed
+	gain     float64 // dB
+}
+
+// NewAGC creates an AGC over src, configured by opts. It fails with
+// ErrInvalidTarget when opts.Target is above 0 dBFS.
+func NewAGC(src audio.Source, opts AGCOptions) (*AGC, error) {
+	format := audio.FormatOf(src)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	opts = opts.withDefaults()
+	if opts.Target > 0 || math.IsNaN(opts.Target) {
+		return nil, fmt.Errorf("%w: %g dBFS", ErrInvalidTarget, opts.Target)
+	}
+
+	return &AGC{
+		src:      src,
+		channels: format.Channels,
+		opts:     opts,
+		window:   smoothing(agcWindow, format.Rate),
+		attack:   smoothing(opts.Attack, format.Rate),
+		decay:    smoothing(opts.Decay, format.Rate),
+	}, nil
+}
+
+func (a *AGC) SampleRate() int          { return a.src.SampleRate() }
+func (a *AGC) Channels() int            { return a.channels }
+func (a *AGC) BufSize() int             { return a.src.BufSize() }
+func (a *AGC) Format() audio.Format     { return audio.FormatOf(a.src) }
+func (a *AGC) Upstream() []audio.Source { return []audio.Source{a.src} }
+
+// Gain returns the current gain, in dB. Call it from the goroutine that
+// reads.
+func (a *AGC) Gain() float64 { return a.gain }
+
+func (a *AGC) Close() error {
+	if err := a.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with leveled interleaved samples. len(dst) must be
+// a multiple of Channels().
+func (a *AGC) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%a.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	n, err := a.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	for f := 0; f+a.channels <= n; f += a.channels {
+		frame := dst[f : f+a.channels]
+		power := energy(frame) / float64(a.channels)
+		a.power = power + a.window*(a.power-power)
+
+		if level := 10 * math.Log10(a.power); level > a.opts.NoiseFloor {
+			target := min(a.opts.Target-level, a.opts.MaxGain)
+			coeff := a.decay
+			if target < a.gain {
+				coeff = a.attack
+			}
+			a.gain = target + coeff*(a.gain-target)
+		}
+
+		gain := float32(math.Pow(10, a.gain/20))
+		for i, v := range frame {
+			frame[i] = min(max(v*gain, -1), 1)
+		}
+	}
+	return n, err
+}
+
+// energy returns the sum of squares of frame
+func energy(frame []float32) float64 {
+	var e float64
+	for _, v := range frame {
+		e += float64(v) * float64(v)
+	}
+	return e
+}
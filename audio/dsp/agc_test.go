@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dsp
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestAGC(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		level  float32
+		opts   AGCOptions
+		wantDB float64 // of the output, once settled
+	}{
+		{name: "quiet", level: 0.05, wantDB: -18},
+		{name: "loud", level: 0.5, wantDB: -18},
+		{name: "target", level: 0.05, opts: AGCOptions{Target: -12}, wantDB: -12},
+		{name: "max gain", level: 0.005, opts: AGCOptions{MaxGain: 12}, wantDB: -34.02},
+		{name: "under the noise floor", level: 0.002, wantDB: -53.98},
+		{name: "noise floor", level: 0.002, opts: AGCOptions{NoiseFloor: -60, MaxGain: 40}, wantDB: -18},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := tt.opts
+			opts.Decay = 200 * time.Millisecond
+			a, err := NewAGC(audiotest.NewConstantSource(8000, 2, 16000, tt.level), opts)
+			if err != nil {
+				t.Fatalf("NewAGC() error = %v", err)
+			}
+			out := readAll(t, a)
+			if got := peakDB(out[len(out)-1]); math.Abs(got-tt.wantDB) > 0.05 {
+				t.Errorf("settled at %.2f dBFS, want %.2f", got, tt.wantDB)
+			}
+		})
+	}
+}
+
+func TestAGC_Attack(t *testing.T) {
+	t.Parallel()
+
+	// The far end jumps by 20 dB: the gain follows within a few attack
+	// times, not the decay time
+	src := audiotest.NewMockSource(8000, 1, 16000, func(i, _ int) float32 {
+		if i < 8000 {
+			return 0.0126 // -38 dBFS
+		}
+		return 0.126
+	})
+	a, err := NewAGC(src, AGCOptions{Attack: 10 * time.Millisecond, Decay: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := readAll(t, a)
+	if got := peakDB(out[7999]); math.Abs(got+18) > 0.05 {
+		t.Fatalf("before the jump at %.2f dBFS, want -18", got)
+	}
+	if got := peakDB(out[8000+2400]); math.Abs(got+18) > 0.05 {
+		t.Errorf("300 ms after the jump at %.2f dBFS, want -18", got)
+	}
+}
+
+func TestAGC_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewAGC(audiotest.NewSilentSource(8000, 1, 10), AGCOptions{Target: 3}); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("NewAGC(+3 dBFS) error = %v, want ErrInvalidTarget", err)
+	}
+	if _, err := NewAGC(audiotest.NewSilentSource(8000, 0, 10), AGCOptions{}); !errors.Is(err, audio.ErrInvalidChannels) {
+		t.Errorf("NewAGC(0 channels) error = %v, want ErrInvalidChannels", err)
+	}
+
+	faulty := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 2, 1000), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	a, err := NewAGC(faulty, AGCOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.ReadSamples(make([]float32, 3)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+	if _, err := a.ReadSamples(make([]float32, 64)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := a.Close(); err != nil || !faulty.Closed() {
+		t.Errorf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
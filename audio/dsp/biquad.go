@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dsp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// DefaultQ is the Q of a filter when Params.Q is zero: 1/√2, the flattest
+// passband without resonance.
+const DefaultQ = math.Sqrt2 / 2
+
+// FilterType selects the response of a Biquad.
+type FilterType int
+
+const (
+	LowPass FilterType = iota
+	HighPass
+	BandPass // 0 dB at the center frequency
+	Notch
+	Peak
+	LowShelf
+	HighShelf
+)
+
+func (t FilterType) String() string {
+	switch t {
+	case LowPass:
+		return "low-pass"
+	case HighPass:
+		return "high-pass"
+	case BandPass:
+		return "band-pass"
+	case Notch:
+		return "notch"
+	case Peak:
+		return "peaking EQ"
+	case LowShelf:
+		return "low shelf"
+	case HighShelf:
+		return "high shelf"
+	default:
+		return fmt.Sprintf("FilterType(%d)", int(t))
+	}
+}
+
+// Params describes a filter. Zero values select the defaults.
+type Params struct {
+	Type FilterType
+	// Freq is the characteristic frequency, in Hz, below half the sample
+	// rate.
+	Freq float64
+	// Q is the quality factor, DefaultQ by default.
+	Q float64
+	// GainDB is the gain of peaking and shelving filters, in dB.
+	GainDB float64
+}
+
+// Coefficients are those of a biquad, normalized so that a0 is 1:
+//
+//	y[n] = B0*x[n] + B1*x[n-1] + B2*x[n-2] - A1*y[n-1] - A2*y[n-2]
+type Coefficients struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+}
+
+// Design returns the coefficients of the filter p at rate.
+func Design(p Params, rate int) (Coefficients, error) {
+	if rate <= 0 {
+		return Coefficients{}, fmt.Errorf("%w: %d", audio.ErrInvalidSampleRate, rate)
+	}
+	if p.Freq <= 0 || p.Freq >= float64(rate)/2 {
+		return Coefficients{}, fmt.Errorf("%w: %g Hz at %d Hz", ErrInvalidFrequency, p.Freq, rate)
+	}
+	if p.Q == 0 {
+		p.Q = DefaultQ
+	}
+	if p.Q < 0 || math.IsNaN(p.Q) || math.IsInf(p.Q, 0) {
+		return Coefficients{}, fmt.Errorf("%w: %g", ErrInvalidQ, p.Q)
+	}
+
+	w0 := 2 * math.Pi * p.Freq / float64(rate)
+	cos, sin := math.Cos(w0), math.Sin(w0)
+	alpha := sin / (2 * p.Q)
+	a := math.Pow(10, p.GainDB/40)
+	sq := 2 * math.Sqrt(a) * alpha
+
+	var b0, b1, b2, a0, a1, a2 float64
+	switch p.Type {
+	case LowPass:
+		b0, b1, b2 = (1-cos)/2, 1-cos, (1-cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case HighPass:
+		b0, b1, b2 = (1+cos)/2, -(1 + cos), (1+cos)/2
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case BandPass:
+		b0, b1, b2 = alpha, 0, -alpha
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case Notch:
+		b0, b1, b2 = 1, -2*cos, 1
+		a0, a1, a2 = 1+alpha, -2*cos, 1-alpha
+	case Peak:
+		b0, b1, b2 = 1+alpha*a, -2*cos, 1-alpha*a
+		a0, a1, a2 = 1+alpha/a, -2*cos, 1-alpha/a
+	case LowShelf:
+		b0 = a * ((a + 1) - (a-1)*cos + sq)
+		b1 = 2 * a * ((a - 1) - (a+1)*cos)
+		b2 = a * ((a + 1) - (a-1)*cos - sq)
+		a0 = (a + 1) + (a-1)*cos + sq
+		a1 = -2 * ((a - 1) + (a+1)*cos)
+		a2 = (a + 1) + (a-1)*cos - sq
+	case HighShelf:
+		b0 = a * ((a + 1) + (a-1)*cos + sq)
+		b1 = -2 * a * ((a - 1) + (a+1)*cos)
+		b2 = a * ((a + 1) + (a-1)*cos - sq)
+		a0 = (a + 1) - (a-1)*cos + sq
+		a1 = 2 * ((a - 1) - (a+1)*cos)
+		a2 = (a + 1) - (a-1)*cos - sq
+	default:
+		return Coefficients{}, fmt.Errorf("%w: %v", ErrUnknownFilter, p.Type)
+	}
+
+	return Coefficients{B0: b0 / a0, B1: b1 / a0, B2: b2 / a0, A1: a1 / a0, A2: a2 / a0}, nil
+}
+
+// Magnitude returns the gain of the filter at freq Hz, at rate.
+func (c Coefficients) Magnitude(freq float64, rate int) float64 {
+	z := cmplx.Exp(complex(0, -2*math.Pi*freq/float64(rate))) // z^-1
+	num := complex(c.B0, 0) + complex(c.B1, 0)*z + complex(c.B2, 0)*z*z
+	den := 1 + complex(c.A1, 0)*z + complex(c.A2, 0)*z*z
+	return cmplx.Abs(num / den)
+}
+
+// Biquad filters a source with a biquad, each channel on its own.
+type Biquad struct {
+	src      audio.Source
+	channels int
+	params   Params
+	coeffs   Coefficients
+	state    [][2]float64 // per channel, transposed direct form II
+}
+
+// NewBiquad creates a Biquad filtering src with p, failing as Design does.
+func NewBiquad(src audio.Source, p Params) (*Biquad, error) {
+	coeffs, err := Design(p, src.SampleRate())
+	if err != nil {
+		return nil, err
+	}
+	channels := src.Channels()
+	if channels <= 0 {
+		return nil, fmt.Errorf("%w: %d", audio.ErrInvalidChannels, channels)
+	}
+
+	return &Biquad{
+		src:      src,
+		channels: channels,
+		params:   p,
+		coeffs:   coeffs,
+		state:    make([][2]float64, channels),
+	}, nil
+}
+
+func (b *Biquad) SampleRate() int            { return b.src.SampleRate() }
+func (b *Biquad) Channels() int              { return b.channels }
+func (b *Biquad) BufSize() int               { return b.src.BufSize() }
+func (b *Biquad) Format() audio.Format       { return audio.FormatOf(b.src) }
+func (b *Biquad) Upstream() []audio.Source   { return []audio.Source{b.src} }
+func (b *Biquad) Params() Params             { return b.params }
+func (b *Biquad) Coefficients() Coefficients { return b.coeffs }
+
+func (b *Biquad) Close() error {
+	if err := b.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with filtered interleaved samples. len(dst) must
+// be a multiple of Channels().
+func (b *Biquad) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%b.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	n, err := b.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	filter(b.coeffs, b.state, dst[:n])
+	return n, err
+}
+
+// filter runs interleaved samples through a biquad with a state per
+// channel
+func filter[T float32 | float64](c Coefficients, state [][2]float64, samples []T) {
+	for i, x := range samples {
+		s := &state[i%len(state)]
+		in := float64(x)
+		y := c.B0*in + s[0]
+		s[0] = c.B1*in - c.A1*y + s[1]
+		s[1] = c.B2*in - c.A2*y
+		samples[i] = T(y)
+	}
+}
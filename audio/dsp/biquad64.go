@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dsp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Biquad64 is the float64 variant of Biquad, an audio.Source64 filtering
+// another, for measurement chains: its samples are never rounded to
+// float32 between filters.
+type Biquad64 struct {
+	src      audio.Source64
+	channels int
+	params   Params
+	coeffs   Coefficients
+	state    [][2]float64
+}
+
+// NewBiquad64 creates a Biquad64 filtering src with p, failing as Design
+// does.
+func NewBiquad64(src audio.Source64, p Params) (*Biquad64, error) {
+	coeffs, err := Design(p, src.SampleRate())
+	if err != nil {
+		return nil, err
+	}
+	channels := src.Channels()
+	if channels <= 0 {
+		return nil, fmt.Errorf("%w: %d", audio.ErrInvalidChannels, channels)
+	}
+
+	return &Biquad64{
+		src:      src,
+		channels: channels,
+		params:   p,
+		coeffs:   coeffs,
+		state:    make([][2]float64, channels),
+	}, nil
+}
+
+func (b *Biquad64) SampleRate() int            { return b.src.SampleRate() }
+func (b *Biquad64) Channels() int              { return b.channels }
+func (b *Biquad64) BufSize() int               { return b.src.BufSize() }
+func (b *Biquad64) Format() audio.Format       { return audio.Format64Of(b.src) }
+func (b *Biquad64) Params() Params             { return b.params }
+func (b *Biquad64) Coefficients() Coefficients { return b.coeffs }
+
+func (b *Biquad64) Close() error {
+	if err := b.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples64 fills dst with filtered interleaved samples. len(dst) must
+// be a multiple of Channels().
+func (b *Biquad64) ReadSamples64(dst []float64) (int, error) {
+	if len(dst)%b.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	n, err := b.src.ReadSamples64(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	filter(b.coeffs, b.state, dst[:n])
+	return n, err
+}
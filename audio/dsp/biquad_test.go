@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dsp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+	"github.com/ik5/audpbx/audio/gen"
+)
+
+func toDB(gain float64) float64 { return 20 * math.Log10(gain) }
+
+func TestDesign(t *testing.T) {
+	t.Parallel()
+
+	type point struct {
+		freq   float64
+		wantDB float64
+		tol    float64 // 0 for a stopband, at wantDB or below
+	}
+	tests := []struct {
+		name   string
+		params Params
+		points []point
+	}{
+		{
+			name:   "low-pass",
+			params: Params{Type: LowPass, Freq: 1000},
+			points: []point{{100, 0, 0.1}, {1000, -3.01, 0.05}, {3900, -60, 0}},
+		},
+		{
+			name:   "high-pass",
+			params: Params{Type: HighPass, Freq: 300},
+			points: []point{{3000, 0, 0.1}, {300, -3.01, 0.05}, {30, -40, 0}},
+		},
+		{
+			name:   "band-pass",
+			params: Params{Type: BandPass, Freq: 1000, Q: 2},
+			points: []point{{1000, 0, 0.01}, {100, -25, 0}},
+		},
+		{
+			name:   "notch",
+			params: Params{Type: Notch, Freq: 50, Q: 10},
+			points: []point{{50, -100, 0}, {1000, 0, 0.1}},
+		},
+		{
+			name:   "peaking",
+			params: Params{Type: Peak, Freq: 1000, Q: 1, GainDB: 6},
+			points: []point{{1000, 6, 0.01}, {50, 0, 0.1}},
+		},
+		{
+			name:   "low shelf",
+			params: Params{Type: LowShelf, Freq: 200, GainDB: -12},
+			points: []point{{10, -12, 0.1}, {200, -6, 0.1}, {3000, 0, 0.1}},
+		},
+		{
+			name:   "high shelf",
+			params: Params{Type: HighShelf, Freq: 2000, GainDB: 9},
+			points: []point{{3990, 9, 0.2}, {2000, 4.5, 0.1}, {50, 0, 0.1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := Design(tt.params, 8000)
+			if err != nil {
+				t.Fatalf("Design() error = %v", err)
+			}
+			for _, p := range tt.points {
+				got := toDB(c.Magnitude(p.freq, 8000))
+				if (p.tol == 0 && got > p.wantDB) || (p.tol > 0 && math.Abs(got-p.wantDB) > p.tol) {
+					t.Errorf("gain at %g Hz = %.2f dB, want %.2f ± %g", p.freq, got, p.wantDB, p.tol)
+				}
+			}
+		})
+	}
+}
+
+func TestDesign_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params Params
+		rate   int
+		want   error
+	}{
+		{name: "no rate", params: Params{Freq: 1000}, want: audio.ErrInvalidSampleRate},
+		{name: "no frequency", params: Params{}, rate: 8000, want: ErrInvalidFrequency},
+		{name: "at nyquist", params: Params{Freq: 4000}, rate: 8000, want: ErrInvalidFrequency},
+		{name: "negative Q", params: Params{Freq: 1000, Q: -1}, rate: 8000, want: ErrInvalidQ},
+		{name: "unknown type", params: Params{Type: 42, Freq: 1000}, rate: 8000, want: ErrUnknownFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := Design(tt.params, tt.rate); !errors.Is(err, tt.want) {
+				t.Fatalf("Design() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// levelDB returns the RMS level of src in dBFS, skipping the first 100 ms
+// in which filters settle
+func levelDB(t *testing.T, src audio.Source) []float64 {
+	t.Helper()
+
+	ch := src.Channels()
+	skip := src.SampleRate() / 10 * ch
+	sums := make([]float64, ch)
+	count := 0
+	buf := make([]float32, 256*ch)
+	for read := 0; ; {
+		n, err := src.ReadSamples(buf)
+		for i, v := range buf[:n] {
+			if read+i >= skip {
+				sums[i%ch] += float64(v) * float64(v)
+				count++
+			}
+		}
+		read += n
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+
+	levels := make([]float64, ch)
+	for c, s := range sums {
+		levels[c] = 10 * math.Log10(s/float64(count/ch))
+	}
+	return levels
+}
+
+func TestBiquad_TelephoneBand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		freq   float64
+		wantDB float64 // relative to the input
+		tol    float64 // 0 for a stopband, at wantDB or below
+	}{
+		{freq: 50, wantDB: -30},
+		{freq: 1000, wantDB: 0, tol: 0.2},
+		{freq: 7000, wantDB: -30},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%g Hz", tt.freq), func(t *testing.T) {
+			t.Parallel()
+
+			src := gen.Sine(tt.freq, gen.Options{Rate: 16000, Duration: time.Second})
+			hp, err := NewBiquad(src, Params{Type: HighPass, Freq: 300})
+			if err != nil {
+				t.Fatal(err)
+			}
+			lp, err := NewBiquad(hp, Params{Type: LowPass, Freq: 3400})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A sine at 0.5 is at -9.03 dBFS
+			got := levelDB(t, lp)[0] + 9.03
+			if (tt.tol == 0 && got > tt.wantDB) || (tt.tol > 0 && math.Abs(got-tt.wantDB) > tt.tol) {
+				t.Errorf("%g Hz gain = %.2f dB, want %.2f ± %g", tt.freq, got, tt.wantDB, tt.tol)
+			}
+		})
+	}
+}
+
+func TestBiquad_Channels(t *testing.T) {
+	t.Parallel()
+
+	// 50 Hz on the left, 1 kHz on the right, through a hum notch
+	src := audiotest.NewMockSource(8000, 2, 8000, func(i, c int) float32 {
+		freq := 50.0
+		if c == 1 {
+			freq = 1000
+		}
+		return float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/8000))
+	})
+	b, err := NewBiquad(src, Params{Type: Notch, Freq: 50, Q: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels := levelDB(t, b)
+	if levels[0] > -40 || math.Abs(levels[1]+9.03) > 0.1 {
+		t.Fatalf("levels = %v dBFS, want the left channel removed", levels)
+	}
+
+	if _, err := b.ReadSamples(make([]float32, 3)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+	if b.Params().Type != Notch || b.Coefficients().B0 == 0 {
+		t.Errorf("Params() = %+v, Coefficients() = %+v", b.Params(), b.Coefficients())
+	}
+}
+
+func TestBiquad_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewBiquad(audiotest.NewSilentSource(8000, 1, 10), Params{Freq: 5000}); !errors.Is(err, ErrInvalidFrequency) {
+		t.Errorf("NewBiquad(5 kHz at 8 kHz) error = %v, want ErrInvalidFrequency", err)
+	}
+
+	src := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 1000), audiotest.WithErrorOnCall(2, audiotest.ErrInjected))
+	b, err := NewBiquad(src, Params{Freq: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]float32, 100)
+	if _, err := b.ReadSamples(buf); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	if _, err := b.ReadSamples(buf); !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := b.Close(); err != nil || !src.Closed() {
+		t.Fatalf("Close() error = %v, source closed = %v", err, src.Closed())
+	}
+}
+
+func TestBiquad64(t *testing.T) {
+	t.Parallel()
+
+	// Peaking filters of +6 and -6 dB undo each other; after 50 pairs the
+	// float32 chain has drifted from the input and the float64 one has not
+	sine := func() audio.Source { return audiotest.NewSineSource(48000, 1, 4800, 1000) }
+	var (
+		src32 = sine()
+		src64 = audio.To64(sine())
+		err   error
+	)
+	for i := range 100 {
+		p := Params{Type: Peak, Freq: 1000, Q: 2, GainDB: 6}
+		if i%2 == 1 {
+			p.GainDB = -6
+		}
+		if src32, err = NewBiquad(src32, p); err != nil {
+			t.Fatal(err)
+		}
+		if src64, err = NewBiquad64(src64, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := readAll(t, sine())
+	got32 := readAll(t, src32)
+	got64 := make([]float64, len(want))
+	if n, err := src64.ReadSamples64(got64); n != len(want) || (err != nil && !errors.Is(err, io.EOF)) {
+		t.Fatalf("ReadSamples64() = %d, %v", n, err)
+	}
+
+	var err32, err64 float64
+	for i := range want {
+		err32 = max(err32, math.Abs(float64(got32[i]-want[i])))
+		err64 = max(err64, math.Abs(got64[i]-float64(want[i])))
+	}
+	if err64 > 1e-9 || err64*1000 > err32 {
+		t.Errorf("largest error %g in float64, %g in float32", err64, err32)
+	}
+	if f := audio.Format64Of(src64); f.SampleKind != audio.SampleFloat64 {
+		t.Errorf("Format64Of() = %v, want float64 samples", f)
+	}
+}
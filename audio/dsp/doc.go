@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package dsp holds signal processing stages for audio sources: biquads,
+// the second order IIR filters of Robert Bristow-Johnson's Audio EQ
+// Cookbook (low-pass, high-pass, band-pass, notch, peaking EQ and shelving
+// filters), a compressor, a limiter and an automatic gain control.
+//
+// A Biquad wraps an audio.Source and is one itself, so filters chain like
+// the stages of the audio package. Band limiting a wideband prompt to the
+// telephone band, 300 to 3400 Hz, takes a high-pass and a low-pass filter:
+//
+//	hp, err := dsp.NewBiquad(src, dsp.Params{Type: dsp.HighPass, Freq: 300})
+//	lp, err := dsp.NewBiquad(hp, dsp.Params{Type: dsp.LowPass, Freq: 3400})
+//
+// and removing mains hum a narrow notch at its frequency, followed by more
+// at its harmonics where they are audible:
+//
+//	hum, err := dsp.NewBiquad(src, dsp.Params{Type: dsp.Notch, Freq: 50, Q: 10})
+//
+// # Parameters
+//
+// Freq is the cutoff of low-pass and high-pass filters, the center of
+// band-pass, notch and peaking filters and the midpoint of the slope of
+// shelves. Q sets the width of the band or the resonance at the cutoff,
+// DefaultQ (a Butterworth response) by default; higher values narrow the
+// band. GainDB is the boost, or cut when negative, of peaking and shelving
+// filters, and is ignored by the others.
+//
+// Filters are minimum phase, not linear phase, and cost five
+// multiplications per sample and channel. For long linear phase filters,
+// see audio.FIR. Biquad64 filters an audio.Source64, keeping the samples
+// in float64 between the filters of a long chain.
+//
+// # Dynamics
+//
+// Compressor evens out the level of a source above a threshold, and
+// Limiter keeps it under a ceiling, so a hot source is leveled rather than
+// hard clipped when it is quantized to 16 bits:
+//
+//	comp, err := dsp.NewCompressor(src, -20, 4, 5*time.Millisecond, 200*time.Millisecond)
+//	lim, err := dsp.NewLimiter(comp, -1) // dBFS
+//
+// AGC instead brings a source to a target RMS level as it drifts, slowly
+// up and quickly down, as the far end of a call needs; pauses under its
+// noise floor are left alone:
+//
+//	agc, err := dsp.NewAGC(leg, dsp.AGCOptions{Target: -18, MaxGain: 24})
+package dsp
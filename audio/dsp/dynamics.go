@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dsp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// limiterRelease is how fast a Limiter recovers from a peak
+const limiterRelease = 50 * time.Millisecond
+
+// Compressor reduces the dynamic range of a source: the level above
+// threshold is divided by ratio, so a recording with a quiet and a loud
+// party evens out, and less of it is left to clip when quantized.
+//
+// The level is the peak over all channels, which are compressed alike so
+// that the stereo image holds. Gain reduction follows a rising level
+// within attack and a falling one within release, the times the
+// reduction takes to cover about 63% of a change.
+type Compressor struct {
+	src       audio.Source
+	channels  int
+	threshold float64 // dBFS
+	slope     float64 // 1 - 1/ratio
+	attack    float64 // smoothing coefficients per frame
+	release   float64
+	reduction float64 // dB
+}
+
+// NewCompressor creates a Compressor over src, compressing above threshold
+// dBFS by ratio (4 turns 8 dB over into 2 dB over). It fails with
+// ErrInvalidRatio when ratio is below 1.
+func NewCompressor(src audio.Source, threshold, ratio float64, attack, release time.Duration) (*Compressor, error) {
+	format := audio.FormatOf(src)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if ratio < 1 || math.IsNaN(ratio) {
+		return nil, fmt.Errorf("%w: %g", ErrInvalidRatio, ratio)
+	}
+
+	return &Compressor{
+		src:       src,
+		channels:  format.Channels,
+		threshold: threshold,
+		slope:     1 - 1/ratio,
+		attack:    smoothing(attack, format.Rate),
+		release:   smoothing(release, format.Rate),
+	}, nil
+}
+
+func (c *Compressor) SampleRate() int          { return c.src.SampleRate() }
+func (c *Compressor) Channels() int            { return c.channels }
+func (c *Compressor) BufSize() int             { return c.src.BufSize() }
+func (c *Compressor) Format() audio.Format     { return audio.FormatOf(c.src) }
+func (c *Compressor) Upstream() []audio.Source { return []audio.Source{c.src} }
+
+// Reduction returns the current gain reduction, in dB. Call it from the
+// goroutine that reads.
+func (c *Compressor) Reduction() float64 { return c.reduction }
+
+func (c *Compressor) Close() error {
+	if err := c.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with compressed interleaved samples. len(dst) must
+// be a multiple of Channels().
+func (c *Compressor) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%c.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	n, err := c.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	for f := 0; f+c.channels <= n; f += c.channels {
+		frame := dst[f : f+c.channels]
+		target := 0.0
+		if over := peakDB(peakOf(frame)) - c.threshold; over > 0 {
+			target = over * c.slope
+		}
+		coeff := c.release
+		if target > c.reduction {
+			coeff = c.attack
+		}
+		c.reduction = target + coeff*(c.reduction-target)
+
+		gain := float32(math.Pow(10, -c.reduction/20))
+		for i := range frame {
+			frame[i] *= gain
+		}
+	}
+	return n, err
+}
+
+// Limiter keeps a source under a ceiling: peaks that would exceed it are
+// turned down at once, and the gain recovers over 50 ms after them. Put
+// it last before quantizing to 16 bits, so hot sources are leveled
+// instead of hard clipped.
+//
+// All channels are limited alike, so that the stereo image holds.
+type Limiter struct {
+	src      audio.Source
+	channels int
+	ceiling  float32
+	release  float64
+	gain     float64
+}
+
+// NewLimiter creates a Limiter over src with ceiling in dBFS, such as -1.
+// It fails with ErrInvalidCeiling when ceiling is above 0 dBFS.
+func NewLimiter(src audio.Source, ceiling float64) (*Limiter, error) {
+	format := audio.FormatOf(src)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if ceiling > 0 || math.IsNaN(ceiling) {
+		return nil, fmt.Errorf("%w: %g dBFS", ErrInvalidCeiling, ceiling)
+	}
+
+	return &Limiter{
+		src:      src,
+		channels: format.Channels,
+		ceiling:  float32(math.Pow(10, ceiling/20)),
+		release:  smoothing(limiterRelease, format.Rate),
+		gain:     1,
+	}, nil
+}
+
+func (l *Limiter) SampleRate() int          { return l.src.SampleRate() }
+func (l *Limiter) Channels() int            { return l.channels }
+func (l *Limiter) BufSize() int             { return l.src.BufSize() }
+func (l *Limiter) Format() audio.Format     { return audio.FormatOf(l.src) }
+func (l *Limiter) Upstream() []audio.Source { return []audio.Source{l.src} }
+
+func (l *Limiter) Close() error {
+	if err := l.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with limited interleaved samples. len(dst) must be
+// a multiple of Channels().
+func (l *Limiter) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%l.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	n, err := l.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	for f := 0; f+l.channels <= n; f += l.channels {
+		frame := dst[f : f+l.channels]
+		l.gain = 1 + l.release*(l.gain-1)
+		if p := peakOf(frame); p*float32(l.gain) > l.ceiling {
+			l.gain = float64(l.ceiling / p)
+		}
+
+		gain := float32(l.gain)
+		for i, v := range frame {
+			frame[i] = min(max(v*gain, -l.ceiling), l.ceiling)
+		}
+	}
+	return n, err
+}
+
+// smoothing returns the coefficient of a one pole smoother with time
+// constant d at rate
+func smoothing(d time.Duration, rate int) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return math.Exp(-1 / (d.Seconds() * float64(rate)))
+}
+
+// peakOf returns the largest magnitude of frame
+func peakOf(frame []float32) float32 {
+	var p float32
+	for _, v := range frame {
+		p = max(p, v, -v)
+	}
+	return p
+}
+
+// peakDB returns the level of a linear peak in dBFS
+func peakDB(v float32) float64 {
+	if v <= 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(float64(v))
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dsp
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// readAll reads src to the end
+func readAll(t *testing.T, src audio.Source) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, 256*src.Channels())
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+func TestCompressor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		level     float32
+		threshold float64
+		ratio     float64
+		wantDB    float64 // of the output, once settled
+	}{
+		{name: "above threshold", level: 0.5, threshold: -20, ratio: 4, wantDB: -16.51},
+		{name: "limiting ratio", level: 0.5, threshold: -20, ratio: 100, wantDB: -19.86},
+		{name: "below threshold", level: 0.05, threshold: -20, ratio: 4, wantDB: -26.02},
+		{name: "ratio 1", level: 0.5, threshold: -20, ratio: 1, wantDB: -6.02},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src := audiotest.NewConstantSource(8000, 2, 8000, tt.level)
+			c, err := NewCompressor(src, tt.threshold, tt.ratio, 5*time.Millisecond, 100*time.Millisecond)
+			if err != nil {
+				t.Fatalf("NewCompressor() error = %v", err)
+			}
+			out := readAll(t, c)
+			if got := peakDB(out[len(out)-1]); math.Abs(got-tt.wantDB) > 0.01 {
+				t.Errorf("settled at %.2f dBFS, want %.2f", got, tt.wantDB)
+			}
+		})
+	}
+}
+
+func TestCompressor_Attack(t *testing.T) {
+	t.Parallel()
+
+	// A step about 14 dB over the threshold at 4:1 calls for about 10.5 dB of
+	// reduction, 63% of which is reached after the attack time
+	c, err := NewCompressor(audiotest.NewConstantSource(8000, 1, 8000, 0.5), -20, 4, 10*time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadSamples(make([]float32, 80)); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	if got, want := c.Reduction(), 10.5*(1-math.Exp(-1)); math.Abs(got-want) > 0.1 {
+		t.Fatalf("Reduction() = %.2f dB after the attack time, want %.2f", got, want)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+
+	// A hot 440 Hz tone at 1.5 for 100 ms, then at 0.1
+	src := audiotest.NewMockSource(8000, 2, 8000, func(i, _ int) float32 {
+		amp := 0.1
+		if i < 800 {
+			amp = 1.5
+		}
+		return float32(amp * math.Sin(2*math.Pi*440*float64(i)/8000))
+	})
+	l, err := NewLimiter(src, -1)
+	if err != nil {
+		t.Fatalf("NewLimiter() error = %v", err)
+	}
+	out := readAll(t, l)
+
+	ceiling := float32(math.Pow(10, -1.0/20))
+	var hot float32
+	for _, v := range out[:1600] {
+		hot = max(hot, v, -v)
+	}
+	if hot > ceiling || hot < 0.99*ceiling {
+		t.Errorf("hot peak = %v, want up to the ceiling %v", hot, ceiling)
+	}
+
+	// Half a second later, the quiet tone passes untouched
+	for i := 8000; i < len(out); i++ {
+		want := float32(0.1 * math.Sin(2*math.Pi*440*float64(i/2)/8000))
+		if math.Abs(float64(out[i]-want)) > 1e-4 {
+			t.Fatalf("sample %d = %v, want %v", i, out[i], want)
+		}
+	}
+}
+
+func TestDynamics_Errors(t *testing.T) {
+	t.Parallel()
+
+	silent := audiotest.NewSilentSource(8000, 1, 10)
+	if _, err := NewCompressor(silent, -20, 0.5, 0, 0); !errors.Is(err, ErrInvalidRatio) {
+		t.Errorf("NewCompressor(ratio 0.5) error = %v, want ErrInvalidRatio", err)
+	}
+	if _, err := NewLimiter(silent, 1); !errors.Is(err, ErrInvalidCeiling) {
+		t.Errorf("NewLimiter(+1 dBFS) error = %v, want ErrInvalidCeiling", err)
+	}
+	if _, err := NewLimiter(audiotest.NewSilentSource(8000, 0, 10), -1); !errors.Is(err, audio.ErrInvalidChannels) {
+		t.Errorf("NewLimiter(0 channels) error = %v, want ErrInvalidChannels", err)
+	}
+
+	faulty := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 2, 1000), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	c, err := NewCompressor(faulty, -20, 4, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadSamples(make([]float32, 3)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+	if _, err := c.ReadSamples(make([]float32, 64)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := c.Close(); err != nil || !faulty.Closed() {
+		t.Errorf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
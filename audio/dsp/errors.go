@@ -0,0 +1,14 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dsp
+
+import "errors"
+
+var (
+	ErrUnknownFilter    = errors.New("unknown filter type")
+	ErrInvalidFrequency = errors.New("filter frequency out of range")
+	ErrInvalidQ         = errors.New("invalid filter Q")
+	ErrInvalidRatio     = errors.New("compression ratio below 1")
+	ErrInvalidCeiling   = errors.New("limiter ceiling above full scale")
+	ErrInvalidTarget    = errors.New("AGC target above full scale")
+)
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Defaults of DuckOptions.
+const (
+	DefaultDuckThreshold = 0.02
+	DefaultDuckDepth     = 15.0
+	DefaultDuckAttack    = 50 * time.Millisecond
+	DefaultDuckRelease   = 500 * time.Millisecond
+)
+
+// duckHold is how long the voice detector stays active after the voice
+// drops below the threshold, bridging the gaps between words
+const duckHold = 150 * time.Millisecond
+
+// DuckOptions configures a DuckingMixer. Zero values select the defaults.
+type DuckOptions struct {
+	// Threshold is the voice peak level, in [0, 1], above which the music
+	// is ducked. The default, 0.02 (-34 dBFS), ignores line noise.
+	Threshold float32
+	// Depth is the attenuation of the music in dB while the voice is
+	// active, 15 dB by default.
+	Depth float64
+	// Attack is the time constant with which the music fades down when
+	// the voice starts, 50 ms by default.
+	Attack time.Duration
+	// Release is the time constant with which the music comes back after
+	// the voice stops, 500 ms by default.
+	Release time.Duration
+}
+
+func (o DuckOptions) withDefaults() DuckOptions {
+	if o.Threshold <= 0 {
+		o.Threshold = DefaultDuckThreshold
+	}
+	if o.Depth <= 0 {
+		o.Depth = DefaultDuckDepth
+	}
+	if o.Attack <= 0 {
+		o.Attack = DefaultDuckAttack
+	}
+	if o.Release <= 0 {
+		o.Release = DefaultDuckRelease
+	}
+	return o
+}
+
+// DuckingMixer mixes a voice over music, turning the music down while the
+// voice is active (sidechain ducking), so announcements over hold music
+// stay intelligible without riding the levels by hand.
+//
+// Both sources must have the same rate and channel count. The mix ends
+// when both have ended; the one that ends first is replaced by silence.
+// The sum is clipped to [-1, 1].
+type DuckingMixer struct {
+	music, voice Source
+	channels     int
+
+	threshold float32
+	floor     float32 // music gain while ducked
+	attack    float32 // per frame smoothing coefficients
+	release   float32
+	hold      int // frames
+
+	gain     float32
+	held     int // frames the detector stays active
+	vbuf     []float32
+	musicEOF bool
+	voiceEOF bool
+}
+
+// NewDuckingMixer creates a DuckingMixer of voice over music.
+func NewDuckingMixer(music, voice Source, opts DuckOptions) (*DuckingMixer, error) {
+	format := FormatOf(music)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("music: %w", err)
+	}
+	if err := format.Compatible(FormatOf(voice)); err != nil {
+		return nil, fmt.Errorf("voice: %w", err)
+	}
+
+	opts = opts.withDefaults()
+	rate := float64(format.Rate)
+	coeff := func(d time.Duration) float32 {
+		return float32(1 - math.Exp(-1/(d.Seconds()*rate)))
+	}
+
+	return &DuckingMixer{
+		music:     music,
+		voice:     voice,
+		channels:  format.Channels,
+		threshold: opts.Threshold,
+		floor:     float32(math.Pow(10, -opts.Depth/20)),
+		attack:    coeff(opts.Attack),
+		release:   coeff(opts.Release),
+		hold:      FrameLen(format.Rate, duckHold),
+		gain:      1,
+	}, nil
+}
+
+func (m *DuckingMixer) SampleRate() int    { return m.music.SampleRate() }
+func (m *DuckingMixer) Channels() int      { return m.channels }
+func (m *DuckingMixer) BufSize() int       { return m.music.BufSize() }
+func (m *DuckingMixer) Format() Format     { return FormatOf(m.music) }
+func (m *DuckingMixer) Upstream() []Source { return []Source{m.music, m.voice} }
+
+// Gain returns the gain applied to the music at the end of the last read,
+// 1 when not ducked.
+func (m *DuckingMixer) Gain() float32 { return m.gain }
+
+// Close closes both sources.
+func (m *DuckingMixer) Close() error {
+	if err := errors.Join(m.music.Close(), m.voice.Close()); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with the mix. dst length should be a multiple of
+// m.Channels().
+func (m *DuckingMixer) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%m.channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+	if m.musicEOF && m.voiceEOF {
+		return 0, io.EOF
+	}
+
+	if cap(m.vbuf) < len(dst) {
+		m.vbuf = make([]float32, len(dst))
+	}
+	voice := m.vbuf[:len(dst)]
+
+	// Both sources are read to the same length so they stay aligned
+	nm, err := m.fill(m.music, dst, &m.musicEOF)
+	if err != nil {
+		return 0, fmt.Errorf("music: %w", err)
+	}
+	nv, err := m.fill(m.voice, voice, &m.voiceEOF)
+	if err != nil {
+		return 0, fmt.Errorf("voice: %w", err)
+	}
+	n := max(nm, nv)
+	clear(dst[nm:n])
+	clear(voice[nv:n])
+	if n == 0 {
+		return 0, io.EOF
+	}
+
+	ch := m.channels
+	for i := 0; i < n; i += ch {
+		frame := voice[i : i+ch]
+
+		var peak float32
+		for _, v := range frame {
+			peak = max(peak, v, -v)
+		}
+		if peak >= m.threshold {
+			m.held = m.hold
+		}
+
+		if m.held > 0 {
+			m.held--
+			m.gain += (m.floor - m.gain) * m.attack
+		} else {
+			m.gain += (1 - m.gain) * m.release
+		}
+
+		for c, v := range frame {
+			dst[i+c] = min(max(dst[i+c]*m.gain+v, -1), 1)
+		}
+	}
+
+	return n, nil
+}
+
+// fill reads src into buf until it is full or src ends, returning whole
+// frames
+func (m *DuckingMixer) fill(src Source, buf []float32, eof *bool) (int, error) {
+	n := 0
+	for n < len(buf) && !*eof {
+		r, err := src.ReadSamples(buf[n:])
+		n += r
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("%w", err)
+		}
+		*eof = err != nil || r == 0
+	}
+	return n - n%m.channels, nil
+}
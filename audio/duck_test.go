@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestDuckingMixer_Envelope(t *testing.T) {
+	t.Parallel()
+
+	// 2 s of music at 0.5 with voice from 0.5 s to 1 s
+	music := newConstantSource(8000, 1, 16000, 0.5)
+	voice := newMockSource(8000, 1, 16000, func(sample, _ int) float32 {
+		if sample >= 4000 && sample < 8000 {
+			return 0.3
+		}
+		return 0
+	})
+
+	m, err := NewDuckingMixer(music, voice, DuckOptions{Depth: 20})
+	if err != nil {
+		t.Fatalf("NewDuckingMixer() error = %v", err)
+	}
+
+	// Gain at the end of every 10 ms
+	var gains []float32
+	buf := make([]float32, 80)
+	for {
+		n, err := m.ReadSamples(buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+		if n != len(buf) {
+			t.Fatalf("ReadSamples() = %d, want %d", n, len(buf))
+		}
+		gains = append(gains, m.Gain())
+	}
+	if len(gains) != 200 {
+		t.Fatalf("read %d blocks, want 200", len(gains))
+	}
+
+	gainAt := func(d time.Duration) float64 { return float64(gains[d/(10*time.Millisecond)-1]) }
+	tests := []struct {
+		at   time.Duration
+		want float64
+	}{
+		{at: 500 * time.Millisecond, want: 1},
+		// Ten attack time constants into the voice
+		{at: time.Second, want: 0.1},
+		// Still ducked during the hold after the voice
+		{at: 1150 * time.Millisecond, want: 0.1},
+		// One release time constant later
+		{at: 1650 * time.Millisecond, want: 1 - 0.9*math.Exp(-1)},
+	}
+	for _, tt := range tests {
+		if got := gainAt(tt.at); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("gain at %v = %.3f, want %.3f", tt.at, got, tt.want)
+		}
+	}
+
+	// Halfway through the attack the music is between the two levels
+	if g := gainAt(550 * time.Millisecond); g < 0.3 || g > 0.7 {
+		t.Errorf("gain 50 ms into the voice = %.3f, want about 0.43", g)
+	}
+}
+
+func TestDuckingMixer_Mix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		music      Source
+		voice      Source
+		wantFrames int
+		first      float32
+		last       float32
+	}{
+		{
+			name:       "voice ends first",
+			music:      newConstantSource(8000, 2, 1000, 0.25),
+			voice:      newConstantSource(8000, 2, 100, 0),
+			wantFrames: 1000,
+			first:      0.25,
+			last:       0.25,
+		},
+		{
+			name:       "music ends first",
+			music:      newConstantSource(8000, 2, 100, 0.25),
+			voice:      newConstantSource(8000, 2, 500, 0.01),
+			wantFrames: 500,
+			first:      0.26,
+			last:       0.01,
+		},
+		{
+			name:       "clipped",
+			music:      newConstantSource(8000, 2, 10, 1),
+			voice:      newConstantSource(8000, 2, 10, 0.9),
+			wantFrames: 10,
+			first:      1,
+			last:       1,
+		},
+		{
+			name:       "short reads",
+			music:      audiotest.NewFaultySource(newConstantSource(8000, 2, 300, 0.25), audiotest.WithShortReads(7)),
+			voice:      audiotest.NewFaultySource(newConstantSource(8000, 2, 300, 0), audiotest.WithShortReads(13)),
+			wantFrames: 300,
+			first:      0.25,
+			last:       0.25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := NewDuckingMixer(tt.music, tt.voice, DuckOptions{})
+			if err != nil {
+				t.Fatalf("NewDuckingMixer() error = %v", err)
+			}
+
+			out := readAll(t, m, 128)
+			if got := len(out) / 2; got != tt.wantFrames {
+				t.Fatalf("mixed %d frames, want %d", got, tt.wantFrames)
+			}
+			if d := math.Abs(float64(out[0] - tt.first)); d > 1e-6 {
+				t.Errorf("first sample = %v, want %v", out[0], tt.first)
+			}
+			if d := math.Abs(float64(out[len(out)-1] - tt.last)); d > 1e-6 {
+				t.Errorf("last sample = %v, want %v", out[len(out)-1], tt.last)
+			}
+		})
+	}
+}
+
+func TestDuckingMixer_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewDuckingMixer(newSilentSource(8000, 1, 10), newSilentSource(16000, 1, 10), DuckOptions{})
+	if !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("NewDuckingMixer(8 kHz, 16 kHz) error = %v, want ErrFormatMismatch", err)
+	}
+
+	music := audiotest.NewFaultySource(newSilentSource(8000, 2, 100))
+	voice := audiotest.NewFaultySource(newSilentSource(8000, 2, 100), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	m, err := NewDuckingMixer(music, voice, DuckOptions{})
+	if err != nil {
+		t.Fatalf("NewDuckingMixer() error = %v", err)
+	}
+	if _, err := m.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(3) error = %v, want ErrInvalidDstSize", err)
+	}
+	if _, err := m.ReadSamples(make([]float32, 20)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !music.Closed() || !voice.Closed() {
+		t.Error("Close() did not close both sources")
+	}
+}
@@ -5,5 +5,17 @@ package audio
 import "errors"
 
 var (
-	ErrInvalidDstSize = errors.New("dst size must be multiple of channels")
+	ErrInvalidDstSize    = errors.New("dst size must be multiple of channels")
+	ErrInvalidSampleRate = errors.New("invalid sample rate")
+	ErrInvalidChannels   = errors.New("invalid channel count")
+	ErrFormatMismatch    = errors.New("incompatible audio formats")
+	ErrInvalidState      = errors.New("invalid node state")
+	ErrSeekOutOfRange    = errors.New("seek position out of range")
+	ErrFormatChanged     = errors.New("stream format changed")
+	ErrUnknownFormat     = errors.New("unrecognized audio format")
+	ErrUnsupported       = errors.New("not supported by the format")
+	ErrNotApproved       = errors.New("format change not approved")
+	ErrNotReady          = errors.New("sink not ready")
+	ErrNotBitExact       = errors.New("samples changed on the way")
+	ErrRefusedByPolicy   = errors.New("format refused by the codec policy")
 )
@@ -7,7 +7,7 @@ import (
 	"io"
 
 	"github.com/ik5/audpbx/audio"
-	"github.com/ik5/audpbx/internal/audiotest"
+	"github.com/ik5/audpbx/audio/audiotest"
 )
 
 // Example_resampler demonstrates how to use the Resampler to change sample rates.
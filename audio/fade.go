@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// FadeIn ramps src up from silence over its first d, so a prompt or a
+// loop of hold music does not start with a click. Closing the source
+// closes src.
+func FadeIn(src Source, d time.Duration) Source {
+	channels := max(src.Channels(), 1)
+	return &fader{
+		hold:     &holdback{src: src, channels: channels},
+		channels: channels,
+		in:       int64(FrameLen(src.SampleRate(), d)),
+	}
+}
+
+// FadeOut ramps src down to silence over its last d. Which part is the
+// last is only known once src ends, so the source plays d behind it, and
+// holds d of audio in memory. Closing the source closes src.
+func FadeOut(src Source, d time.Duration) Source {
+	channels := max(src.Channels(), 1)
+	return &fader{
+		hold: &holdback{
+			src:      src,
+			channels: channels,
+			keep:     FrameLen(src.SampleRate(), d) * channels,
+			onEnd:    func(tail []float32) error { fadeTail(tail, channels); return nil },
+		},
+		channels: channels,
+	}
+}
+
+// fader is the source of FadeIn and FadeOut
+type fader struct {
+	hold     *holdback
+	channels int
+	in       int64 // frames of the fade in
+	pos      int64 // frames played
+}
+
+func (f *fader) SampleRate() int    { return f.hold.src.SampleRate() }
+func (f *fader) Channels() int      { return f.channels }
+func (f *fader) BufSize() int       { return f.hold.src.BufSize() }
+func (f *fader) Format() Format     { return FormatOf(f.hold.src) }
+func (f *fader) Upstream() []Source { return []Source{f.hold.src} }
+
+func (f *fader) Close() error {
+	if err := f.hold.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (f *fader) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%f.channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	n, err := f.hold.ReadSamples(dst)
+	for i := 0; i+f.channels <= n && f.pos < f.in; i += f.channels {
+		gain := float32(f.pos) / float32(f.in)
+		for c := range f.channels {
+			dst[i+c] *= gain
+		}
+		f.pos++
+	}
+	return n, err
+}
+
+// Crossfade plays a, then b, blending the last d of a into the first d of
+// b, so that prompts are stitched, or a loop of hold music joined to its
+// start, without a click or a gap. The blend keeps the power constant,
+// which suits unrelated audio; a shorter than d is blended whole. The
+// source is d shorter than a and b played one after the other.
+//
+// a and b must have the same rate and channel count. Like FadeOut, the
+// source plays d behind a while a plays. Closing the source closes both.
+func Crossfade(a, b Source, d time.Duration) (Source, error) {
+	format := FormatOf(a)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if err := FormatOf(b).Compatible(format); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	x := &crossfader{b: b, format: format}
+	x.a = &holdback{
+		src:      a,
+		channels: format.Channels,
+		keep:     FrameLen(format.Rate, d) * format.Channels,
+		onEnd:    x.blend,
+	}
+	return x, nil
+}
+
+// crossfader is the source of Crossfade
+type crossfader struct {
+	a      *holdback
+	b      Source
+	format Format
+	onB    bool // a and the blend have been played
+	head   []float32
+}
+
+func (x *crossfader) SampleRate() int    { return x.format.Rate }
+func (x *crossfader) Channels() int      { return x.format.Channels }
+func (x *crossfader) BufSize() int       { return max(x.a.src.BufSize(), x.b.BufSize()) }
+func (x *crossfader) Format() Format     { return x.format }
+func (x *crossfader) Upstream() []Source { return []Source{x.a.src, x.b} }
+
+func (x *crossfader) Close() error {
+	if err := errors.Join(x.a.src.Close(), x.b.Close()); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (x *crossfader) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%x.format.Channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	if !x.onB {
+		n, err := x.a.ReadSamples(dst)
+		if !errors.Is(err, io.EOF) {
+			return n, err
+		}
+		x.onB = true
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	n, err := x.b.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, err
+}
+
+// blend mixes the tail of a with the head of b, read here
+func (x *crossfader) blend(tail []float32) error {
+	ch := x.format.Channels
+	if cap(x.head) < len(tail) {
+		x.head = make([]float32, len(tail))
+	}
+	head := x.head[:len(tail)]
+	clear(head)
+
+	// b may be shorter than the tail, or have nothing yet, as a live
+	// source: what there is of it is blended
+	for got := 0; got < len(head); {
+		n, err := x.b.ReadSamples(head[got:])
+		got += n - n%ch
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	frames := len(tail) / ch
+	for f := range frames {
+		t := (float64(f) + 0.5) / float64(frames) * math.Pi / 2
+		out, in := float32(math.Cos(t)), float32(math.Sin(t))
+		for c := f * ch; c < f*ch+ch; c++ {
+			tail[c] = tail[c]*out + head[c]*in
+		}
+	}
+	return nil
+}
+
+// fadeTail ramps interleaved samples down to silence, the reverse of the
+// ramp of FadeIn
+func fadeTail(tail []float32, channels int) {
+	frames := len(tail) / channels
+	for f := range frames {
+		gain := float32(frames-1-f) / float32(frames)
+		for c := f * channels; c < f*channels+channels; c++ {
+			tail[c] *= gain
+		}
+	}
+}
+
+// holdback plays a source keep samples behind it, so that its last keep
+// samples are known before they are played: when the source ends, onEnd
+// may rework them in place.
+type holdback struct {
+	src      Source
+	channels int
+	keep     int
+	onEnd    func(tail []float32) error
+	buf      []float32
+	head     int // start of the samples not played yet
+	ended    bool
+}
+
+func (h *holdback) ReadSamples(dst []float32) (int, error) {
+	for {
+		avail := len(h.buf) - h.head
+		if h.ended {
+			n := copy(dst, h.buf[h.head:])
+			h.head += n
+			if h.head == len(h.buf) {
+				return n, io.EOF
+			}
+			return n, nil
+		}
+		if avail > h.keep {
+			n := copy(dst, h.buf[h.head:len(h.buf)-h.keep])
+			h.head += n
+			return n, nil
+		}
+		if len(dst) == 0 {
+			return 0, nil
+		}
+
+		// Move what is held to the front and read after it
+		h.buf = h.buf[:copy(h.buf, h.buf[h.head:])]
+		h.head = 0
+		if cap(h.buf) < avail+len(dst) {
+			h.buf = append(make([]float32, 0, avail+len(dst)+h.keep), h.buf...)
+		}
+		n, err := h.src.ReadSamples(h.buf[avail : avail+len(dst)])
+		h.buf = h.buf[:avail+n-n%h.channels]
+
+		if errors.Is(err, io.EOF) {
+			h.ended = true
+			if h.onEnd != nil {
+				if err := h.onEnd(h.buf[max(len(h.buf)-h.keep, 0):]); err != nil {
+					return 0, err
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			return 0, nil
+		}
+	}
+}
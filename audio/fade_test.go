@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestFade(t *testing.T) {
+	t.Parallel()
+
+	// 5 ms is 40 frames at 8 kHz
+	tests := []struct {
+		name   string
+		frames int
+		chunk  int
+		fade   func(Source) Source
+		gain   func(f int) float64
+	}{
+		{
+			name: "in", frames: 100, chunk: 14,
+			fade: func(s Source) Source { return FadeIn(s, 5*time.Millisecond) },
+			gain: func(f int) float64 { return min(float64(f)/40, 1) },
+		},
+		{
+			name: "out", frames: 100, chunk: 14,
+			fade: func(s Source) Source { return FadeOut(s, 5*time.Millisecond) },
+			gain: func(f int) float64 { return min(float64(99-f)/40, 1) },
+		},
+		{
+			name: "out, large reads", frames: 100, chunk: 512,
+			fade: func(s Source) Source { return FadeOut(s, 5*time.Millisecond) },
+			gain: func(f int) float64 { return min(float64(99-f)/40, 1) },
+		},
+		{
+			name: "out, shorter than the fade", frames: 20, chunk: 6,
+			fade: func(s Source) Source { return FadeOut(s, 5*time.Millisecond) },
+			gain: func(f int) float64 { return float64(19-f) / 20 },
+		},
+		{
+			name: "in and out", frames: 100, chunk: 14,
+			fade: func(s Source) Source { return FadeOut(FadeIn(s, 5*time.Millisecond), 5*time.Millisecond) },
+			gain: func(f int) float64 { return min(float64(f)/40, 1) * min(float64(99-f)/40, 1) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			out := readAll(t, tt.fade(newConstantSource(8000, 2, tt.frames, 0.5)), tt.chunk)
+			if len(out) != 2*tt.frames {
+				t.Fatalf("read %d frames, want %d", len(out)/2, tt.frames)
+			}
+			for i, v := range out {
+				if want := 0.5 * tt.gain(i/2); math.Abs(float64(v)-want) > 1e-6 {
+					t.Fatalf("sample %d = %v, want %v", i, v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCrossfade(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		a, b    int // frames
+		overlap int // frames blended
+		live    bool
+	}{
+		{name: "clips", a: 100, b: 100, overlap: 40},
+		{name: "live", a: 100, b: 100, overlap: 40, live: true},
+		{name: "a shorter than the fade", a: 30, b: 100, overlap: 30},
+		{name: "b shorter than the fade", a: 100, b: 10, overlap: 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var a, b Source = newConstantSource(8000, 2, tt.a, 0.5), newConstantSource(8000, 2, tt.b, 0.25)
+			if tt.live {
+				a = &trickleSource{MockSource: a.(*audiotest.MockSource), n: 14}
+			}
+			x, err := Crossfade(a, b, 5*time.Millisecond)
+			if err != nil {
+				t.Fatalf("Crossfade() error = %v", err)
+			}
+			out := readAll(t, x, 32)
+
+			frames := tt.a + max(tt.b-tt.overlap, 0)
+			if len(out) != 2*frames {
+				t.Fatalf("read %d frames, want %d", len(out)/2, frames)
+			}
+			start := tt.a - tt.overlap
+			for i, v := range out {
+				f := i / 2
+				want := 0.5
+				switch {
+				case f >= tt.a:
+					want = 0.25
+				case f >= start:
+					phase := (float64(f-start) + 0.5) / float64(tt.overlap) * math.Pi / 2
+					want = 0.5 * math.Cos(phase)
+					if f-start < tt.b {
+						want += 0.25 * math.Sin(phase)
+					}
+				}
+				if math.Abs(float64(v)-want) > 1e-6 {
+					t.Fatalf("sample %d = %v, want %v", i, v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCrossfade_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Crossfade(newSilentSource(8000, 1, 10), newSilentSource(16000, 1, 10), time.Millisecond); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("Crossfade(8 kHz, 16 kHz) error = %v, want ErrFormatMismatch", err)
+	}
+
+	faulty := audiotest.NewFaultySource(newSilentSource(8000, 2, 100), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	x, err := Crossfade(newSilentSource(8000, 2, 10), faulty, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := x.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+	if _, err := x.ReadSamples(make([]float32, 64)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := x.Close(); err != nil || !faulty.Closed() {
+		t.Errorf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
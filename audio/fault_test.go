@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// Every node must surface an error of its source instead of swallowing it or
+// turning it into io.EOF.
+func TestNodes_PropagateSourceErrors(t *testing.T) {
+	t.Parallel()
+
+	nodes := map[string]func(Source) Source{
+		"resampler down": func(s Source) Source { return NewResampler(s, 8000) },
+		"resampler up":   func(s Source) Source { return NewResampler(s, 32000) },
+		"mono mixer":     func(s Source) Source { return NewMonoMixer(s) },
+		"fir":            func(s Source) Source { return NewFIR(s, []float32{0.25, 0.5, 0.25}) },
+	}
+
+	for name, build := range nodes {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inner := audiotest.NewSineSource(16000, 2, 16000, 440)
+			src := build(audiotest.NewFaultySource(inner,
+				audiotest.WithShortReads(6),
+				audiotest.WithErrorOnCall(5, audiotest.ErrInjected),
+			))
+
+			buf := make([]float32, 512)
+			var err error
+			for range 1000 {
+				if _, err = src.ReadSamples(buf); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, audiotest.ErrInjected) {
+				t.Errorf("got error %v, want %v", err, audiotest.ErrInjected)
+			}
+		})
+	}
+}
+
+// Short reads and an early end of stream must not lose or invent samples.
+func TestNodes_ShortReadsAndEarlyEOF(t *testing.T) {
+	t.Parallel()
+
+	const frames = 1000
+	inner := audiotest.NewConstantSource(8000, 2, 4000, 0.5)
+	mono := NewMonoMixer(audiotest.NewFaultySource(inner,
+		audiotest.WithShortReads(3),
+		audiotest.WithEOFAfter(frames*2),
+	))
+
+	got := readAll(t, mono, 64)
+	if len(got) != frames {
+		t.Fatalf("read %d frames, want %d", len(got), frames)
+	}
+	for i, v := range got {
+		if v != 0.5 {
+			t.Fatalf("sample %d = %v, want 0.5", i, v)
+		}
+	}
+
+	if _, err := mono.ReadSamples(make([]float32, 8)); !errors.Is(err, io.EOF) {
+		t.Errorf("read after end = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/utils"
+)
+
+// FIR filters src with a finite impulse response using FFT based
+// overlap-save convolution. It is meant for long filters (hundreds or
+// thousands of taps) such as windowed-sinc low-pass filters, measured
+// impulse responses and linear-phase EQ curves, where direct convolution
+// costs O(taps) per sample.
+//
+// The filter is causal: output sample n depends on input samples n-taps+1..n,
+// and the output has exactly as many frames as the input. Each channel is
+// filtered independently with the same taps.
+type FIR struct {
+	src      Source
+	channels int
+	numTaps  int
+	fftSize  int
+	block    int // new frames consumed by every FFT block
+
+	spectrum []complex128 // FFT of the zero padded taps
+	work     []complex128
+	scratch  []float32   // history followed by the new samples of a channel
+	history  [][]float32 // last numTaps-1 input samples per channel
+
+	in     []float32 // interleaved input block
+	out    []float32 // interleaved filtered block
+	outPos int
+	eof    bool
+}
+
+// NewFIR creates an overlap-save convolver for src with the given taps.
+// The FFT size is the power of two at or above twice the filter length.
+// It panics when taps is empty.
+func NewFIR(src Source, taps []float32) *FIR {
+	if len(taps) == 0 {
+		panic("audio: NewFIR requires at least one tap")
+	}
+
+	channels := src.Channels()
+	numTaps := len(taps)
+	fftSize := max(utils.NextPowerOfTwo(2*numTaps), 64)
+	block := fftSize - numTaps + 1
+
+	spectrum := make([]complex128, fftSize)
+	for i, t := range taps {
+		spectrum[i] = complex(float64(t), 0)
+	}
+	utils.FFT(spectrum)
+
+	history := make([][]float32, channels)
+	for c := range history {
+		history[c] = make([]float32, numTaps-1)
+	}
+
+	return &FIR{
+		src:      src,
+		channels: channels,
+		numTaps:  numTaps,
+		fftSize:  fftSize,
+		block:    block,
+		spectrum: spectrum,
+		work:     make([]complex128, fftSize),
+		scratch:  make([]float32, fftSize),
+		history:  history,
+		in:       make([]float32, block*channels),
+		out:      make([]float32, 0, block*channels),
+	}
+}
+
+func (f *FIR) SampleRate() int    { return f.src.SampleRate() }
+func (f *FIR) Channels() int      { return f.channels }
+func (f *FIR) BufSize() int       { return f.src.BufSize() }
+func (f *FIR) Format() Format     { return FormatOf(f.src) }
+func (f *FIR) Upstream() []Source { return []Source{f.src} }
+
+// Taps returns the number of taps of the filter.
+func (f *FIR) Taps() int { return f.numTaps }
+
+func (f *FIR) Close() error {
+	err := f.src.Close()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with filtered interleaved samples.
+// dst length should be a multiple of f.Channels().
+func (f *FIR) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%f.channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	written := 0
+	for written < len(dst) {
+		if f.outPos == len(f.out) {
+			if f.eof {
+				return written, io.EOF
+			}
+			if err := f.processBlock(); err != nil {
+				return written, err
+			}
+			if len(f.out) == 0 && !f.eof {
+				// A live source with nothing ready yet: return rather than
+				// spin until it has
+				return written, nil
+			}
+			continue
+		}
+
+		n := copy(dst[written:], f.out[f.outPos:])
+		f.outPos += n
+		written += n
+	}
+
+	return written, nil
+}
+
+// processBlock reads up to f.block frames from the source and filters them
+func (f *FIR) processBlock() error {
+	want := f.block * f.channels
+	got := 0
+	for got < want {
+		n, err := f.src.ReadSamples(f.in[got:want])
+		got += n
+		if errors.Is(err, io.EOF) {
+			f.eof = true
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	frames := got / f.channels
+	f.out = f.out[:frames*f.channels]
+	f.outPos = 0
+	if frames == 0 {
+		return nil
+	}
+
+	hist := f.numTaps - 1
+	for c := range f.channels {
+		// scratch = history | new samples | zero padding
+		copy(f.scratch, f.history[c])
+		for i := range frames {
+			f.scratch[hist+i] = f.in[i*f.channels+c]
+		}
+		clear(f.scratch[hist+frames:])
+
+		for i, v := range f.scratch {
+			f.work[i] = complex(float64(v), 0)
+		}
+		utils.FFT(f.work)
+		for i := range f.work {
+			f.work[i] *= f.spectrum[i]
+		}
+		utils.IFFT(f.work)
+
+		// The first numTaps-1 outputs are circularly aliased; the rest are
+		// the valid linear convolution results
+		for i := range frames {
+			f.out[i*f.channels+c] = float32(real(f.work[hist+i]))
+		}
+
+		copy(f.history[c], f.scratch[frames:frames+hist])
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+// directConvolve is the O(taps) per sample reference for FIR
+func directConvolve(x []float32, channels int, taps []float32) []float32 {
+	out := make([]float32, len(x))
+	frames := len(x) / channels
+	for c := range channels {
+		for n := range frames {
+			var acc float64
+			for k, h := range taps {
+				if n-k < 0 {
+					break
+				}
+				acc += float64(h) * float64(x[(n-k)*channels+c])
+			}
+			out[n*channels+c] = float32(acc)
+		}
+	}
+	return out
+}
+
+func testTaps(n int) []float32 {
+	taps := make([]float32, n)
+	for i := range taps {
+		taps[i] = float32(math.Sin(float64(i)*0.37)) / float32(n)
+	}
+	return taps
+}
+
+func readAll(t testing.TB, src Source, chunk int) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, chunk)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+func TestFIR_MatchesDirectConvolution(t *testing.T) {
+	t.Parallel()
+
+	waveform := func(sample int, channel int) float32 {
+		return float32(math.Sin(float64(sample)*0.05*float64(channel+1))) * 0.5
+	}
+	taps := testTaps(101)
+
+	input := readAll(t, newMockSource(8000, 3, 5000, waveform), 999)
+	want := directConvolve(input, 3, taps)
+
+	// Odd sized reads exercise block boundaries
+	got := readAll(t, NewFIR(newMockSource(8000, 3, 5000, waveform), taps), 3*37)
+
+	if len(got) != len(want) {
+		t.Fatalf("FIR produced %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1e-5 {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFIR_IdentityTap(t *testing.T) {
+	t.Parallel()
+
+	src := newSineSource(8000, 1, 1000, 440)
+	want := readAll(t, newSineSource(8000, 1, 1000, 440), 256)
+	got := readAll(t, NewFIR(src, []float32{1}), 256)
+
+	if len(got) != len(want) {
+		t.Fatalf("FIR produced %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1e-6 {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// stalledSource is a live source that never has anything ready
+type stalledSource struct {
+	Source
+	reads int
+}
+
+func (s *stalledSource) ReadSamples([]float32) (int, error) {
+	s.reads++
+	return 0, nil
+}
+
+func TestFIR_LateSource(t *testing.T) {
+	t.Parallel()
+
+	taps := testTaps(101)
+	want := directConvolve(readAll(t, newSineSource(8000, 1, 1000, 440), 256), 1, taps)
+	got := readAll(t, NewFIR(&trickleSource{MockSource: newSineSource(8000, 1, 1000, 440), n: 100}, taps), 256)
+	if len(got) != len(want) {
+		t.Fatalf("FIR produced %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1e-5 {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	stalled := &stalledSource{Source: newSilentSource(8000, 1, 0)}
+	if n, err := NewFIR(stalled, taps).ReadSamples(make([]float32, 160)); n != 0 || err != nil {
+		t.Errorf("ReadSamples() of a stalled source = %d, %v, want 0, nil", n, err)
+	}
+	if stalled.reads != 1 {
+		t.Errorf("ReadSamples() read a stalled source %d times, want 1", stalled.reads)
+	}
+}
+
+func TestFIR_InvalidDstSize(t *testing.T) {
+	t.Parallel()
+
+	fir := NewFIR(newSilentSource(8000, 2, 100), []float32{1, 0.5})
+	if _, err := fir.ReadSamples(make([]float32, 3)); err != ErrInvalidDstSize {
+		t.Errorf("ReadSamples() error = %v, want ErrInvalidDstSize", err)
+	}
+}
+
+func TestFIR_Metadata(t *testing.T) {
+	t.Parallel()
+
+	src := newSilentSource(16000, 2, 100)
+	fir := NewFIR(src, testTaps(300))
+
+	if fir.SampleRate() != 16000 || fir.Channels() != 2 {
+		t.Errorf("FIR reports %d Hz %d ch, want 16000 Hz 2 ch", fir.SampleRate(), fir.Channels())
+	}
+	if fir.Taps() != 300 {
+		t.Errorf("Taps() = %d, want 300", fir.Taps())
+	}
+	if err := fir.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestNewFIR_PanicsWithoutTaps(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewFIR() with no taps did not panic")
+		}
+	}()
+
+	NewFIR(newSilentSource(8000, 1, 10), nil)
+}
+
+// BenchmarkFIR_OverlapSave_512Taps benchmarks FFT convolution of 1s at 16 kHz
+func BenchmarkFIR_OverlapSave_512Taps(b *testing.B) {
+	src := newSineSource(16000, 1, 16000, 440)
+	fir := NewFIR(src, testTaps(512))
+	buf := make([]float32, 4096)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		src.Reset()
+		fir.eof = false
+		for {
+			_, err := fir.ReadSamples(buf)
+			if err == io.EOF {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkFIR_Direct_512Taps benchmarks direct convolution of the same input
+func BenchmarkFIR_Direct_512Taps(b *testing.B) {
+	input := readAll(b, newSineSource(16000, 1, 16000, 440), 4096)
+	taps := testTaps(512)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		_ = directConvolve(input, 1, taps)
+	}
+}
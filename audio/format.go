@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import "fmt"
+
+// SampleKind describes the native numeric representation of a stream's samples
+// before they are normalized to float32 by ReadSamples.
+type SampleKind int
+
+const (
+	SampleUnknown SampleKind = iota
+	SampleUint8
+	SampleInt16
+	SampleInt24
+	SampleInt32
+	SampleFloat32
+	SampleFloat64
+)
+
+// String returns a short human readable name of the sample kind.
+func (k SampleKind) String() string {
+	switch k {
+	case SampleUint8:
+		return "u8"
+	case SampleInt16:
+		return "s16"
+	case SampleInt24:
+		return "s24"
+	case SampleInt32:
+		return "s32"
+	case SampleFloat32:
+		return "f32"
+	case SampleFloat64:
+		return "f64"
+	default:
+		return "unknown"
+	}
+}
+
+// BitDepth returns the number of bits used by a single sample of this kind,
+// or 0 when the kind is unknown.
+func (k SampleKind) BitDepth() int {
+	switch k {
+	case SampleUint8:
+		return 8
+	case SampleInt16:
+		return 16
+	case SampleInt24:
+		return 24
+	case SampleInt32, SampleFloat32:
+		return 32
+	case SampleFloat64:
+		return 64
+	default:
+		return 0
+	}
+}
+
+// SampleKindForBitDepth maps an integer PCM bit depth to its SampleKind.
+// 8-bit PCM is unsigned as in WAV files. Unknown depths return SampleUnknown.
+func SampleKindForBitDepth(bits int) SampleKind {
+	switch bits {
+	case 8:
+		return SampleUint8
+	case 16:
+		return SampleInt16
+	case 24:
+		return SampleInt24
+	case 32:
+		return SampleInt32
+	default:
+		return SampleUnknown
+	}
+}
+
+// Format describes a PCM stream: its rate, channel count and arrangement and
+// the native representation of its samples.
+type Format struct {
+	// Rate is the sample rate in Hz.
+	Rate int
+	// Channels is the number of interleaved channels.
+	Channels int
+	// Layout is the speaker arrangement of the channels, if known.
+	Layout Layout
+	// SampleKind is the native sample representation of the stream.
+	SampleKind SampleKind
+}
+
+// Formatter is implemented by Sources that can describe themselves with a
+// full Format rather than only a rate and a channel count.
+type Formatter interface {
+	Format() Format
+}
+
+// FormatOf returns the Format of src. When src does not implement Formatter,
+// the format is built from SampleRate and Channels, assuming float32 samples
+// and the default layout for the channel count.
+func FormatOf(src Source) Format {
+	if f, ok := src.(Formatter); ok {
+		return f.Format()
+	}
+
+	return Format{
+		Rate:       src.SampleRate(),
+		Channels:   src.Channels(),
+		Layout:     DefaultLayout(src.Channels()),
+		SampleKind: SampleFloat32,
+	}
+}
+
+// Validate reports whether f describes a usable stream.
+func (f Format) Validate() error {
+	if f.Rate <= 0 {
+		return fmt.Errorf("%w: %d Hz", ErrInvalidSampleRate, f.Rate)
+	}
+
+	if f.Channels <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidChannels, f.Channels)
+	}
+
+	return nil
+}
+
+// Compatible reports whether audio in format f can be combined sample by
+// sample with audio in format other, i.e. rate and channel count match.
+// The returned error names both formats so it can be shown as is.
+func (f Format) Compatible(other Format) error {
+	if f.Rate != other.Rate || f.Channels != other.Channels {
+		return fmt.Errorf("%w: %s vs %s", ErrFormatMismatch, f, other)
+	}
+
+	return nil
+}
+
+// String returns a compact description such as "8000 Hz 1ch mono s16".
+func (f Format) String() string {
+	return fmt.Sprintf("%d Hz %dch %s %s", f.Rate, f.Channels, f.Layout, f.SampleKind)
+}
+
+// FormatChangedError is returned by ReadSamples of sources reading chained
+// streams, such as chained Ogg files or concatenated WAV files, when the
+// next link has a different rate or channel count. The samples returned
+// with it still belong to the previous link. Later reads return samples in
+// the To format, which SampleRate, Channels and Format report from then on,
+// so a caller can rebuild its pipeline and carry on reading. Links of the
+// same rate and channel count are joined without an error.
+//
+// errors.Is matches it against ErrFormatChanged; use errors.As to get the
+// formats.
+type FormatChangedError struct {
+	From, To Format
+}
+
+func (e *FormatChangedError) Error() string {
+	return fmt.Sprintf("%s: %s to %s", ErrFormatChanged, e.From, e.To)
+}
+
+func (e *FormatChangedError) Unwrap() error { return ErrFormatChanged }
+
+// CheckFormatChange returns a *FormatChangedError when audio in format to
+// cannot continue a stream in format from, and nil otherwise. Decoders of
+// chained streams call it at every link.
+func CheckFormatChange(from, to Format) error {
+	if from.Compatible(to) == nil {
+		return nil
+	}
+	return &FormatChangedError{From: from, To: to}
+}
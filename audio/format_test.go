@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFormatOf_PlainSource(t *testing.T) {
+	t.Parallel()
+
+	src := newSilentSource(8000, 2, 10)
+	got := FormatOf(src)
+
+	want := Format{Rate: 8000, Channels: 2, Layout: LayoutStereo, SampleKind: SampleFloat32}
+	if got != want {
+		t.Errorf("FormatOf() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatOf_PipelineNodes(t *testing.T) {
+	t.Parallel()
+
+	src := newSilentSource(44100, 2, 10)
+	mono := NewMonoMixer(NewResampler(src, 8000))
+
+	got := FormatOf(mono)
+	if got.Rate != 8000 {
+		t.Errorf("Rate = %d, want 8000", got.Rate)
+	}
+	if got.Channels != 1 || got.Layout != LayoutMono {
+		t.Errorf("Channels/Layout = %d/%v, want 1/mono", got.Channels, got.Layout)
+	}
+}
+
+func TestFormat_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format Format
+		want   error
+	}{
+		{"valid", Format{Rate: 8000, Channels: 1}, nil},
+		{"zero rate", Format{Rate: 0, Channels: 1}, ErrInvalidSampleRate},
+		{"negative rate", Format{Rate: -8000, Channels: 1}, ErrInvalidSampleRate},
+		{"zero channels", Format{Rate: 8000, Channels: 0}, ErrInvalidChannels},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.format.Validate()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat_Compatible(t *testing.T) {
+	t.Parallel()
+
+	a := Format{Rate: 8000, Channels: 1, SampleKind: SampleInt16}
+	b := Format{Rate: 8000, Channels: 1, SampleKind: SampleFloat32}
+	if err := a.Compatible(b); err != nil {
+		t.Errorf("Compatible() = %v, want nil (sample kind must not matter)", err)
+	}
+
+	c := Format{Rate: 16000, Channels: 1}
+	err := a.Compatible(c)
+	if !errors.Is(err, ErrFormatMismatch) {
+		t.Fatalf("Compatible() = %v, want ErrFormatMismatch", err)
+	}
+	if !strings.Contains(err.Error(), "16000 Hz") {
+		t.Errorf("error %q does not name the offending format", err)
+	}
+}
+
+func TestSampleKind_BitDepth(t *testing.T) {
+	t.Parallel()
+
+	for _, bits := range []int{8, 16, 24, 32} {
+		if got := SampleKindForBitDepth(bits).BitDepth(); got != bits {
+			t.Errorf("SampleKindForBitDepth(%d).BitDepth() = %d", bits, got)
+		}
+	}
+
+	if SampleKindForBitDepth(12) != SampleUnknown {
+		t.Error("SampleKindForBitDepth(12) should be SampleUnknown")
+	}
+}
+
+func TestFormat_String(t *testing.T) {
+	t.Parallel()
+
+	f := Format{Rate: 8000, Channels: 1, Layout: LayoutMono, SampleKind: SampleInt16}
+	if got, want := f.String(), "8000 Hz 1ch mono s16"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckFormatChange(t *testing.T) {
+	t.Parallel()
+
+	mono16 := Format{Rate: 8000, Channels: 1, Layout: LayoutMono, SampleKind: SampleInt16}
+	tests := []struct {
+		name    string
+		to      Format
+		changed bool
+	}{
+		{name: "same", to: mono16},
+		{name: "sample kind only", to: Format{Rate: 8000, Channels: 1, SampleKind: SampleInt24}},
+		{name: "channels", to: Format{Rate: 8000, Channels: 2, Layout: LayoutStereo}, changed: true},
+		{name: "rate", to: Format{Rate: 16000, Channels: 1}, changed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckFormatChange(mono16, tt.to)
+			if !tt.changed {
+				if err != nil {
+					t.Errorf("CheckFormatChange() = %v, want nil", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrFormatChanged) {
+				t.Fatalf("CheckFormatChange() = %v, want ErrFormatChanged", err)
+			}
+			var fc *FormatChangedError
+			if !errors.As(err, &fc) {
+				t.Fatalf("CheckFormatChange() = %T, want *FormatChangedError", err)
+			}
+			if fc.From != mono16 || fc.To != tt.to {
+				t.Errorf("FormatChangedError = %v -> %v, want %v -> %v", fc.From, fc.To, mono16, tt.to)
+			}
+			if !strings.Contains(err.Error(), tt.to.String()) {
+				t.Errorf("error %q does not name the new format", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Framer cuts a Source into frames of a fixed number of sample frames, as
+// codecs, voice activity detectors and speech recognizers expect (for
+// example 20 ms = 160 frames at 8 kHz). The Source may return any amount
+// of samples per read; the Framer regroups them.
+type Framer struct {
+	src      Source
+	channels int
+	size     int // samples per frame, all channels
+	buf      []float32
+	fill     int
+	frames   int64 // frames returned so far
+	eof      bool
+}
+
+// NewFramer creates a Framer returning frameLen sample frames per call,
+// i.e. frameLen*src.Channels() interleaved samples. It panics if frameLen
+// is not positive.
+func NewFramer(src Source, frameLen int) *Framer {
+	if frameLen <= 0 {
+		panic("audio: NewFramer requires a positive frame length")
+	}
+
+	channels := src.Channels()
+	return &Framer{
+		src:      src,
+		channels: channels,
+		size:     frameLen * channels,
+		buf:      make([]float32, frameLen*channels),
+	}
+}
+
+// FrameLen returns the number of sample frames for d at rate.
+func FrameLen(rate int, d time.Duration) int {
+	return int(int64(rate) * int64(d) / int64(time.Second))
+}
+
+// Next returns the next frame. The slice is reused by the following call.
+// The last frame is zero padded up to the full length and returned with a
+// nil error, after which Next returns io.EOF; valid reports how many of its
+// sample frames came from the source.
+func (f *Framer) Next() (frame []float32, valid int, err error) {
+	if f.eof && f.fill == 0 {
+		return nil, 0, io.EOF
+	}
+
+	for f.fill < f.size && !f.eof {
+		n, err := f.src.ReadSamples(f.buf[f.fill:])
+		f.fill += n
+		if errors.Is(err, io.EOF) {
+			f.eof = true
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			f.eof = true
+		}
+	}
+
+	if f.fill == 0 {
+		return nil, 0, io.EOF
+	}
+
+	valid = f.fill / f.channels
+	clear(f.buf[f.fill:])
+	f.fill = 0
+	f.frames++
+
+	return f.buf, valid, nil
+}
+
+// Frames returns the number of frames returned so far.
+func (f *Framer) Frames() int64 { return f.frames }
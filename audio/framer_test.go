@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestFramer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		channels  int
+		total     int
+		frameLen  int
+		shortRead int
+		wantValid []int
+	}{
+		{name: "exact", channels: 1, total: 480, frameLen: 160, wantValid: []int{160, 160, 160}},
+		{name: "padded tail", channels: 1, total: 500, frameLen: 160, wantValid: []int{160, 160, 160, 20}},
+		{name: "stereo short reads", channels: 2, total: 330, frameLen: 160, shortRead: 14, wantValid: []int{160, 160, 10}},
+		{name: "empty", channels: 1, total: 0, frameLen: 160},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Each sample holds its frame index so regrouping can be checked
+			var src Source = newMockSource(8000, tt.channels, tt.total, func(sample, _ int) float32 {
+				return float32(sample + 1)
+			})
+			if tt.shortRead > 0 {
+				src = audiotest.NewFaultySource(src, audiotest.WithShortReads(tt.shortRead))
+			}
+
+			framer := NewFramer(src, tt.frameLen)
+			var reals []int
+			next := 1
+			for {
+				frame, valid, err := framer.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if len(frame) != tt.frameLen*tt.channels {
+					t.Fatalf("frame has %d samples, want %d", len(frame), tt.frameLen*tt.channels)
+				}
+				for i := range tt.frameLen {
+					want := float32(0)
+					if i < valid {
+						want = float32(next + i)
+					}
+					if got := frame[i*tt.channels]; got != want {
+						t.Fatalf("frame %d sample %d = %v, want %v", len(reals), i, got, want)
+					}
+				}
+				next += valid
+				reals = append(reals, valid)
+			}
+
+			if len(reals) != len(tt.wantValid) {
+				t.Fatalf("got frames %v, want %v", reals, tt.wantValid)
+			}
+			for i := range reals {
+				if reals[i] != tt.wantValid[i] {
+					t.Errorf("frame %d has %d valid frames, want %d", i, reals[i], tt.wantValid[i])
+				}
+			}
+			if framer.Frames() != int64(len(tt.wantValid)) {
+				t.Errorf("Frames() = %d, want %d", framer.Frames(), len(tt.wantValid))
+			}
+		})
+	}
+}
+
+func TestFrameLen(t *testing.T) {
+	t.Parallel()
+
+	if got := FrameLen(8000, 20*time.Millisecond); got != 160 {
+		t.Errorf("FrameLen(8000, 20ms) = %d, want 160", got)
+	}
+	if got := FrameLen(48000, 10*time.Millisecond); got != 480 {
+		t.Errorf("FrameLen(48000, 10ms) = %d, want 480", got)
+	}
+}
+
+func TestFramer_PropagatesErrors(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 1000), audiotest.WithErrorOnCall(2, audiotest.ErrInjected), audiotest.WithShortReads(100))
+	framer := NewFramer(src, 160)
+	if _, _, err := framer.Next(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Next() error = %v, want %v", err, audiotest.ErrInjected)
+	}
+}
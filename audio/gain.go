@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// Defaults of NormalizeOptions.
+const (
+	DefaultNormalizePeak = -1.0  // dBFS
+	DefaultNormalizeRMS  = -20.0 // dBFS
+)
+
+// DBToGain converts a gain in dB to a linear one: 6 dB to about 2.
+func DBToGain(db float64) float32 {
+	return float32(math.Pow(10, db/20))
+}
+
+// GainToDB converts a linear gain to dB, -Inf for 0.
+func GainToDB(gain float32) float64 {
+	return 20 * math.Log10(float64(gain))
+}
+
+// Gain scales the samples of a source by a linear gain, clipping the
+// result to [-1, 1]. SetGain is safe to call from any goroutine while
+// another one reads.
+type Gain struct {
+	src Source
+
+	mu   *sync.Mutex
+	gain float32
+}
+
+// NewGain creates a Gain applying the linear gain to src.
+func NewGain(src Source, gain float32) *Gain {
+	return &Gain{src: src, mu: &sync.Mutex{}, gain: gain}
+}
+
+// NewGainDB creates a Gain applying db decibels to src.
+func NewGainDB(src Source, db float64) *Gain {
+	return NewGain(src, DBToGain(db))
+}
+
+func (g *Gain) SampleRate() int    { return g.src.SampleRate() }
+func (g *Gain) Channels() int      { return g.src.Channels() }
+func (g *Gain) BufSize() int       { return g.src.BufSize() }
+func (g *Gain) Format() Format     { return FormatOf(g.src) }
+func (g *Gain) Upstream() []Source { return []Source{g.src} }
+
+func (g *Gain) Close() error {
+	if err := g.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// Gain returns the linear gain applied.
+func (g *Gain) Gain() float32 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.gain
+}
+
+// SetGain changes the linear gain from the next read on.
+func (g *Gain) SetGain(gain float32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.gain = gain
+}
+
+func (g *Gain) ReadSamples(dst []float32) (int, error) {
+	n, err := g.src.ReadSamples(dst)
+	gain := g.Gain()
+	if gain != 1 {
+		for i, v := range dst[:n] {
+			dst[i] = min(max(v*gain, -1), 1)
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, err
+}
+
+// NormalizeMode is the level Normalize brings to its target.
+type NormalizeMode int
+
+const (
+	// NormalizePeak scales the loudest sample to the target.
+	NormalizePeak NormalizeMode = iota
+	// NormalizeRMS scales the RMS level, which follows loudness more
+	// closely, to the target, as far as the peak allows.
+	NormalizeRMS
+)
+
+// NormalizeOptions configures Normalize. Zero values select the defaults.
+type NormalizeOptions struct {
+	// Mode is the level measured, NormalizePeak by default.
+	Mode NormalizeMode
+	// Target is the level, in dBFS, the source is scaled to: -1 dBFS for
+	// peaks and -20 dBFS for RMS by default.
+	Target float64
+}
+
+// Level is the level of a stream, in dBFS, over all its channels.
+type Level struct {
+	Peak float64
+	RMS  float64
+}
+
+// MeasureLevel reads src to the end and returns its level. Silence
+// measures -Inf. It does not close src.
+func MeasureLevel(src Source) (Level, error) {
+	var peak float32
+	var sumSq float64
+	var count int64
+	buf := make([]float32, max(src.BufSize(), 1024))
+	for {
+		n, err := src.ReadSamples(buf)
+		for _, v := range buf[:n] {
+			peak = max(peak, v, -v)
+			sumSq += float64(v) * float64(v)
+		}
+		count += int64(n)
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			break
+		}
+		if err != nil {
+			return Level{}, fmt.Errorf("%w", err)
+		}
+	}
+
+	if count == 0 {
+		return Level{Peak: math.Inf(-1), RMS: math.Inf(-1)}, nil
+	}
+	return Level{
+		Peak: GainToDB(peak),
+		RMS:  10 * math.Log10(sumSq/float64(count)),
+	}, nil
+}
+
+// Normalize scales src to the target level in two passes: it measures the
+// whole of src, then returns a Gain playing it from the start, scaled so
+// the level measured reaches the target, such as prompts recorded at
+// different levels brought to the same one. The gain never makes the
+// peak clip, and silence is left as it is.
+//
+// A src implementing Seeker is read twice; any other is held in memory
+// between the passes.
+func Normalize(src Source, opts NormalizeOptions) (*Gain, error) {
+	if opts.Target == 0 {
+		opts.Target = DefaultNormalizePeak
+		if opts.Mode == NormalizeRMS {
+			opts.Target = DefaultNormalizeRMS
+		}
+	}
+
+	var level Level
+	var err error
+	if s, ok := src.(Seeker); ok {
+		start := s.Position()
+		if level, err = MeasureLevel(src); err != nil {
+			return nil, err
+		}
+		if err := s.SeekFrame(start); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	} else {
+		mem := &memorySource{format: FormatOf(src), src: src, bufSize: src.BufSize()}
+		if err := mem.load(); err != nil {
+			return nil, err
+		}
+		if level, err = MeasureLevel(mem); err != nil {
+			return nil, err
+		}
+		mem.pos = 0
+		src = mem
+	}
+
+	if math.IsInf(level.Peak, -1) {
+		return NewGain(src, 1), nil
+	}
+	measured := level.Peak
+	if opts.Mode == NormalizeRMS {
+		measured = level.RMS
+	}
+	// Never above full scale
+	db := min(opts.Target-measured, -level.Peak)
+	return NewGainDB(src, db), nil
+}
+
+// memorySource plays samples held in memory, such as those of a source
+// read whole
+type memorySource struct {
+	format  Format
+	src     Source // closed with it, if not nil
+	bufSize int
+	samples []float32
+	pos     int
+}
+
+// load reads the whole of src
+func (m *memorySource) load() error {
+	ch := max(m.format.Channels, 1)
+	buf := make([]float32, max(m.src.BufSize()-m.src.BufSize()%ch, ch))
+	for {
+		n, err := m.src.ReadSamples(buf)
+		m.samples = append(m.samples, buf[:n]...)
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+}
+
+func (m *memorySource) SampleRate() int { return m.format.Rate }
+func (m *memorySource) Channels() int   { return m.format.Channels }
+func (m *memorySource) BufSize() int    { return m.bufSize }
+func (m *memorySource) Format() Format  { return m.format }
+
+func (m *memorySource) Upstream() []Source {
+	if m.src == nil {
+		return nil
+	}
+	return []Source{m.src}
+}
+
+func (m *memorySource) Close() error {
+	if m.src == nil {
+		return nil
+	}
+	if err := m.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (m *memorySource) ReadSamples(dst []float32) (int, error) {
+	n := copy(dst, m.samples[m.pos:])
+	m.pos += n
+	if m.pos == len(m.samples) {
+		return n, io.EOF
+	}
+	return n, nil
+}
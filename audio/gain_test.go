@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// seekSource plays samples and can seek in them
+type seekSource struct {
+	*audiotest.MockSource
+	samples []float32
+	pos     int
+}
+
+func newSeekSource(samples []float32) *seekSource {
+	return &seekSource{MockSource: newSilentSource(8000, 1, 0), samples: samples}
+}
+
+func (s *seekSource) ReadSamples(dst []float32) (int, error) {
+	n := copy(dst, s.samples[s.pos:])
+	s.pos += n
+	if s.pos == len(s.samples) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (s *seekSource) SeekFrame(n int64) error {
+	if n < 0 || n > int64(len(s.samples)) {
+		return ErrSeekOutOfRange
+	}
+	s.pos = int(n)
+	return nil
+}
+
+func (s *seekSource) Position() int64 { return int64(s.pos) }
+
+func TestGain_ReadSamples(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value float32
+		gain  func(Source) *Gain
+		want  float32
+	}{
+		{name: "linear", value: 0.25, gain: func(s Source) *Gain { return NewGain(s, 2) }, want: 0.5},
+		{name: "unity", value: -0.3, gain: func(s Source) *Gain { return NewGain(s, 1) }, want: -0.3},
+		{name: "dB", value: 0.5, gain: func(s Source) *Gain { return NewGainDB(s, -20) }, want: 0.05},
+		{name: "clipped", value: -0.6, gain: func(s Source) *Gain { return NewGainDB(s, 12) }, want: -1},
+		{name: "mute", value: 0.7, gain: func(s Source) *Gain { return NewGain(s, 0) }, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := tt.gain(newConstantSource(8000, 2, 300, tt.value))
+			if g.Channels() != 2 || g.SampleRate() != 8000 {
+				t.Fatalf("format = %d Hz x %d, want 8000 Hz x 2", g.SampleRate(), g.Channels())
+			}
+			out := readAll(t, g, 128)
+			if len(out) != 600 {
+				t.Fatalf("read %d samples, want 600", len(out))
+			}
+			for i, v := range out {
+				if math.Abs(float64(v-tt.want)) > 1e-6 {
+					t.Fatalf("sample %d = %v, want %v", i, v, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGain_SetGain(t *testing.T) {
+	t.Parallel()
+
+	g := NewGain(newConstantSource(8000, 1, 20, 0.5), 1)
+	buf := make([]float32, 10)
+	if _, err := g.ReadSamples(buf); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	g.SetGain(0.5)
+	if g.Gain() != 0.5 {
+		t.Fatalf("Gain() = %v, want 0.5", g.Gain())
+	}
+	if _, err := g.ReadSamples(buf); err != nil && !errors.Is(err, io.EOF) {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	if buf[0] != 0.25 {
+		t.Fatalf("sample after SetGain = %v, want 0.25", buf[0])
+	}
+}
+
+func TestGain_Error(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewFaultySource(newConstantSource(8000, 1, 100, 0.1), audiotest.WithErrorOnCall(2, audiotest.ErrInjected))
+	g := NewGain(src, 2)
+	buf := make([]float32, 10)
+	if _, err := g.ReadSamples(buf); err != nil {
+		t.Fatalf("first ReadSamples() error = %v", err)
+	}
+	if _, err := g.ReadSamples(buf); !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("second ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := g.Close(); err != nil || !src.Closed() {
+		t.Fatalf("Close() error = %v, source closed = %v", err, src.Closed())
+	}
+}
+
+func TestDBToGain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		db   float64
+		gain float32
+	}{
+		{0, 1},
+		{-20, 0.1},
+		{20, 10},
+		{-6.0206, 0.5},
+	}
+
+	for _, tt := range tests {
+		if g := DBToGain(tt.db); math.Abs(float64(g-tt.gain)) > 1e-4 {
+			t.Errorf("DBToGain(%v) = %v, want %v", tt.db, g, tt.gain)
+		}
+		if db := GainToDB(tt.gain); math.Abs(db-tt.db) > 1e-3 {
+			t.Errorf("GainToDB(%v) = %v, want %v", tt.gain, db, tt.db)
+		}
+	}
+}
+
+func TestMeasureLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		src     Source
+		peak    float64
+		rms     float64
+		wantErr error
+		inf     bool
+	}{
+		{name: "constant", src: newConstantSource(8000, 1, 100, 0.5), peak: -6.0206, rms: -6.0206},
+		{name: "negative peak", src: newMockSource(8000, 2, 100, func(i, _ int) float32 {
+			if i == 10 {
+				return -1
+			}
+			return 0
+		}), peak: 0, rms: -20},
+		{name: "sine", src: newSineSource(8000, 1, 8000, 1000), peak: 0, rms: -3.0103},
+		{name: "silence", src: newSilentSource(8000, 1, 100), inf: true},
+		{name: "empty", src: newSilentSource(8000, 1, 0), inf: true},
+		{
+			name:    "error",
+			src:     audiotest.NewFaultySource(newConstantSource(8000, 1, 100, 0.5), audiotest.WithErrorOnCall(1, audiotest.ErrInjected)),
+			wantErr: audiotest.ErrInjected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			level, err := MeasureLevel(tt.src)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MeasureLevel() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.inf {
+				if !math.IsInf(level.Peak, -1) || !math.IsInf(level.RMS, -1) {
+					t.Fatalf("level = %+v, want -Inf", level)
+				}
+				return
+			}
+			if math.Abs(level.Peak-tt.peak) > 1e-3 || math.Abs(level.RMS-tt.rms) > 1e-3 {
+				t.Fatalf("level = %+v, want peak %v, RMS %v", level, tt.peak, tt.rms)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	// A quiet tone with one louder sample
+	samples := func() []float32 {
+		s := make([]float32, 4000)
+		for i := range s {
+			s[i] = 0.1
+			if i%2 == 1 {
+				s[i] = -0.1
+			}
+		}
+		s[1000] = 0.2
+		return s
+	}
+
+	tests := []struct {
+		name string
+		src  func() Source
+		opts NormalizeOptions
+		peak float64
+		rms  float64
+	}{
+		{
+			name: "peak default",
+			src:  func() Source { return newMockSource(8000, 1, 4000, func(i, _ int) float32 { return samples()[i] }) },
+			peak: DefaultNormalizePeak,
+			rms:  DefaultNormalizePeak - 6.0206,
+		},
+		{
+			name: "peak seekable",
+			src:  func() Source { return newSeekSource(samples()) },
+			opts: NormalizeOptions{Target: -6},
+			peak: -6,
+			rms:  -12.0206,
+		},
+		{
+			name: "RMS",
+			src:  func() Source { return newSeekSource(samples()) },
+			opts: NormalizeOptions{Mode: NormalizeRMS, Target: -12},
+			peak: -5.9794,
+			rms:  -12,
+		},
+		{
+			name: "RMS limited by peak",
+			src:  func() Source { return newSeekSource(samples()) },
+			opts: NormalizeOptions{Mode: NormalizeRMS, Target: -3},
+			peak: 0,
+			rms:  -6.0206,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			g, err := Normalize(tt.src(), tt.opts)
+			if err != nil {
+				t.Fatalf("Normalize() error = %v", err)
+			}
+			level, err := MeasureLevel(g)
+			if err != nil {
+				t.Fatalf("MeasureLevel() error = %v", err)
+			}
+			if math.Abs(level.Peak-tt.peak) > 0.01 || math.Abs(level.RMS-tt.rms) > 0.01 {
+				t.Fatalf("level = %+v, want peak %v, RMS %v", level, tt.peak, tt.rms)
+			}
+		})
+	}
+}
+
+func TestNormalize_Silence(t *testing.T) {
+	t.Parallel()
+
+	g, err := Normalize(newSilentSource(8000, 1, 100), NormalizeOptions{})
+	if err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if g.Gain() != 1 {
+		t.Fatalf("Gain() = %v, want 1", g.Gain())
+	}
+	if out := readAll(t, g, 64); len(out) != 100 {
+		t.Fatalf("read %d samples, want 100", len(out))
+	}
+}
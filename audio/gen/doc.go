@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package gen generates test tones and signals as audio.Source values:
+// sine, square and sawtooth waves, white and pink noise, frequency sweeps
+// and silence, for calibrating levels, building fixtures and standing in
+// for hold music.
+//
+//	tone := gen.Sine(1000, gen.Options{Duration: time.Second, Amplitude: audio.DBToGain(-10)})
+//	sweep := gen.LogSweep(20, 3400, gen.Options{Rate: 8000, Duration: 5 * time.Second})
+//	hiss := gen.PinkNoise(gen.Options{Amplitude: 0.1}) // endless
+//
+// # Options
+//
+// Every generator takes Options, whose zero values select the defaults:
+// 8 kHz mono at DefaultAmplitude (-6 dBFS), without end. Channels all carry
+// the same signal. Periodic waves start at Options.Phase, and noise is
+// drawn from Options.Seed, so the same options always render the same
+// audio.
+//
+// Square and sawtooth waves are band limited (PolyBLEP), so they do not
+// alias at telephone rates as naive ones would.
+package gen
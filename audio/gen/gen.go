@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gen
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Defaults of Options.
+const (
+	DefaultRate          = 8000
+	DefaultAmplitude     = 0.5
+	DefaultSweepDuration = time.Second
+)
+
+// bufSize is the BufSize of a Generator
+const bufSize = 4096
+
+// Options configures a generator. Zero values select the defaults.
+type Options struct {
+	// Rate is the sample rate, 8000 Hz by default.
+	Rate int
+	// Channels is the channel count, 1 by default.
+	Channels int
+	// Duration is how long the signal lasts. Zero means without end,
+	// except for sweeps, which last DefaultSweepDuration.
+	Duration time.Duration
+	// Amplitude is the peak level, in (0, 1], DefaultAmplitude (-6 dBFS)
+	// by default.
+	Amplitude float64
+	// Phase is where periodic waves start, in radians.
+	Phase float64
+	// Seed seeds the noise generators.
+	Seed int64
+}
+
+func (o Options) withDefaults() Options {
+	if o.Rate <= 0 {
+		o.Rate = DefaultRate
+	}
+	if o.Channels <= 0 {
+		o.Channels = 1
+	}
+	if o.Amplitude <= 0 {
+		o.Amplitude = DefaultAmplitude
+	}
+	return o
+}
+
+// Generator is an audio.Source rendering a signal, as the functions of
+// this package return it.
+type Generator struct {
+	format    audio.Format
+	remaining int64 // frames, -1 without end
+	next      func() float64
+	amplitude float64
+}
+
+// newGenerator creates a Generator of the frames returned by next
+func newGenerator(opts Options, next func() float64) *Generator {
+	remaining := int64(-1)
+	if opts.Duration > 0 {
+		remaining = int64(audio.FrameLen(opts.Rate, opts.Duration))
+	}
+	return &Generator{
+		format:    audio.Format{Rate: opts.Rate, Channels: opts.Channels},
+		remaining: remaining,
+		next:      next,
+		amplitude: opts.Amplitude,
+	}
+}
+
+func (g *Generator) SampleRate() int      { return g.format.Rate }
+func (g *Generator) Channels() int        { return g.format.Channels }
+func (g *Generator) BufSize() int         { return bufSize }
+func (g *Generator) Format() audio.Format { return g.format }
+func (g *Generator) Close() error         { return nil }
+
+// ReadSamples renders the signal into dst. len(dst) must be a multiple of
+// Channels().
+func (g *Generator) ReadSamples(dst []float32) (int, error) {
+	ch := g.format.Channels
+	if len(dst)%ch != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+	if g.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	frames := len(dst) / ch
+	if g.remaining > 0 {
+		frames = int(min(int64(frames), g.remaining))
+		g.remaining -= int64(frames)
+	}
+	for f := range frames {
+		v := float32(g.amplitude * g.next())
+		for c := range ch {
+			dst[f*ch+c] = v
+		}
+	}
+	return frames * ch, nil
+}
+
+// Silence returns digital silence.
+func Silence(opts Options) *Generator {
+	opts = opts.withDefaults()
+	return newGenerator(opts, func() float64 { return 0 })
+}
+
+// Sine returns a sine wave of freq Hz.
+func Sine(freq float64, opts Options) *Generator {
+	opts = opts.withDefaults()
+	osc := newOscillator(freq, opts)
+	return newGenerator(opts, func() float64 {
+		return math.Sin(2 * math.Pi * osc.step())
+	})
+}
+
+// Square returns a square wave of freq Hz.
+func Square(freq float64, opts Options) *Generator {
+	opts = opts.withDefaults()
+	osc := newOscillator(freq, opts)
+	return newGenerator(opts, func() float64 {
+		p := osc.step()
+		v := 1.0
+		if p >= 0.5 {
+			v = -1
+		}
+		return v + polyBLEP(p, osc.inc) - polyBLEP(math.Mod(p+0.5, 1), osc.inc)
+	})
+}
+
+// Sawtooth returns a rising sawtooth wave of freq Hz.
+func Sawtooth(freq float64, opts Options) *Generator {
+	opts = opts.withDefaults()
+	osc := newOscillator(freq, opts)
+	return newGenerator(opts, func() float64 {
+		p := osc.step()
+		return 2*p - 1 - polyBLEP(p, osc.inc)
+	})
+}
+
+// Sweep returns a sine wave gliding linearly from from Hz to to Hz over
+// its duration, DefaultSweepDuration unless Options.Duration is set.
+func Sweep(from, to float64, opts Options) *Generator {
+	return sweep(opts, func(x float64) float64 { return from + (to-from)*x })
+}
+
+// LogSweep returns a sine wave gliding exponentially from from Hz to to
+// Hz over its duration, spending as long on every octave, as measurements
+// of frequency response do. Both frequencies must be positive.
+func LogSweep(from, to float64, opts Options) *Generator {
+	return sweep(opts, func(x float64) float64 { return from * math.Pow(to/from, x) })
+}
+
+// sweep returns a sine wave at freq(x) Hz, x going from 0 to 1 over the
+// duration
+func sweep(opts Options, freq func(x float64) float64) *Generator {
+	opts = opts.withDefaults()
+	if opts.Duration <= 0 {
+		opts.Duration = DefaultSweepDuration
+	}
+
+	frames := float64(max(audio.FrameLen(opts.Rate, opts.Duration), 1))
+	rate := float64(opts.Rate)
+	phase := opts.Phase / (2 * math.Pi)
+	n := 0
+	return newGenerator(opts, func() float64 {
+		v := math.Sin(2 * math.Pi * phase)
+		phase += freq(float64(n)/frames) / rate
+		phase -= math.Floor(phase)
+		n++
+		return v
+	})
+}
+
+// oscillator tracks the phase of a periodic wave, in cycles
+type oscillator struct {
+	phase float64
+	inc   float64
+}
+
+func newOscillator(freq float64, opts Options) *oscillator {
+	phase := opts.Phase / (2 * math.Pi)
+	return &oscillator{phase: phase - math.Floor(phase), inc: freq / float64(opts.Rate)}
+}
+
+// step returns the phase and advances it by a sample
+func (o *oscillator) step() float64 {
+	p := o.phase
+	o.phase += o.inc
+	o.phase -= math.Floor(o.phase)
+	return p
+}
+
+// polyBLEP is the correction of a unit step at phase 0 for phase p, with
+// dt the phase advance per sample
+func polyBLEP(p, dt float64) float64 {
+	switch {
+	case p < dt:
+		p /= dt
+		return p + p - p*p - 1
+	case p > 1-dt:
+		p = (p - 1) / dt
+		return p*p + p + p + 1
+	default:
+		return 0
+	}
+}
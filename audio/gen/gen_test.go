@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gen
+
+import (
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// render reads src to the end, or n samples of an endless one
+func render(t *testing.T, src audio.Source, n int) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, 300*src.Channels())
+	for len(out) < n {
+		m, err := src.ReadSamples(buf)
+		out = append(out, buf[:m]...)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+	return out
+}
+
+// crossings counts the rising zero crossings of samples
+func crossings(samples []float32) int {
+	n := 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i-1] < 0 && samples[i] >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func peak(samples []float32) float64 {
+	var p float64
+	for _, v := range samples {
+		p = max(p, math.Abs(float64(v)))
+	}
+	return p
+}
+
+func TestGenerators(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		src       *Generator
+		crossings int
+		peak      [2]float64
+	}{
+		{name: "sine", src: Sine(440, Options{Duration: time.Second}), crossings: 440, peak: [2]float64{0.49, 0.5}},
+		{name: "square", src: Square(100, Options{Duration: time.Second, Amplitude: 0.25}), crossings: 100, peak: [2]float64{0.25, 0.3}},
+		{name: "sawtooth", src: Sawtooth(50, Options{Duration: time.Second, Amplitude: 0.8}), crossings: 50, peak: [2]float64{0.75, 0.9}},
+		{name: "wideband sine", src: Sine(1000, Options{Rate: 48000, Duration: time.Second}), crossings: 1000, peak: [2]float64{0.49, 0.5}},
+		{name: "silence", src: Silence(Options{Duration: time.Second})},
+		{name: "white noise", src: WhiteNoise(Options{Duration: time.Second, Amplitude: 0.3}), crossings: -1, peak: [2]float64{0.29, 0.3}},
+		{name: "pink noise", src: PinkNoise(Options{Duration: time.Second, Amplitude: 0.3}), crossings: -1, peak: [2]float64{0.05, 0.3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			out := render(t, tt.src, math.MaxInt)
+			if len(out) != tt.src.SampleRate() {
+				t.Fatalf("rendered %d samples, want %d", len(out), tt.src.SampleRate())
+			}
+			if n := crossings(out); tt.crossings >= 0 && (n < tt.crossings-1 || n > tt.crossings+1) {
+				t.Errorf("%d zero crossings, want %d", n, tt.crossings)
+			}
+			if p := peak(out); p < tt.peak[0] || p > tt.peak[1] {
+				t.Errorf("peak = %v, want in %v", p, tt.peak)
+			}
+		})
+	}
+}
+
+func TestGenerator_Options(t *testing.T) {
+	t.Parallel()
+
+	src := Sine(1000, Options{Rate: 16000, Channels: 2, Duration: 10 * time.Millisecond, Phase: math.Pi / 2})
+	if f := src.Format(); f.Rate != 16000 || f.Channels != 2 {
+		t.Fatalf("Format() = %v", f)
+	}
+	out := render(t, src, math.MaxInt)
+	if len(out) != 320 {
+		t.Fatalf("rendered %d samples, want 320", len(out))
+	}
+	if out[0] != 0.5 || out[1] != 0.5 {
+		t.Errorf("first frame = %v, want the peak on both channels", out[:2])
+	}
+	if _, err := src.ReadSamples(make([]float32, 3)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	// Without a duration, a generator does not end
+	if out := render(t, Square(50, Options{}), 100000); len(out) < 100000 {
+		t.Errorf("endless generator ended after %d samples", len(out))
+	}
+}
+
+func TestNoise_Seed(t *testing.T) {
+	t.Parallel()
+
+	a := render(t, WhiteNoise(Options{Seed: 7, Duration: 10 * time.Millisecond}), math.MaxInt)
+	b := render(t, WhiteNoise(Options{Seed: 7, Duration: 10 * time.Millisecond}), math.MaxInt)
+	c := render(t, WhiteNoise(Options{Seed: 8, Duration: 10 * time.Millisecond}), math.MaxInt)
+	if !slices.Equal(a, b) || slices.Equal(a, c) {
+		t.Fatal("noise does not follow its seed")
+	}
+}
+
+func TestPinkNoise_Spectrum(t *testing.T) {
+	t.Parallel()
+
+	// Pink noise changes less from sample to sample than white noise of
+	// the same power, its energy being in the low frequencies
+	ratio := func(samples []float32) float64 {
+		var power, diff float64
+		for i := 1; i < len(samples); i++ {
+			power += float64(samples[i]) * float64(samples[i])
+			d := float64(samples[i] - samples[i-1])
+			diff += d * d
+		}
+		return diff / power
+	}
+
+	white := ratio(render(t, WhiteNoise(Options{Duration: time.Second}), math.MaxInt))
+	pink := ratio(render(t, PinkNoise(Options{Duration: time.Second}), math.MaxInt))
+	if pink > white/2 {
+		t.Fatalf("pink noise difference ratio = %v, white %v", pink, white)
+	}
+}
+
+func TestSweeps(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		src   *Generator
+		first int // crossings in the first and last 100 ms
+		last  int
+	}{
+		{name: "linear", src: Sweep(100, 1000, Options{}), first: 14, last: 95},
+		{name: "log", src: LogSweep(100, 1000, Options{}), first: 11, last: 89},
+		{name: "down", src: Sweep(1000, 100, Options{Duration: 2 * time.Second}), first: 98, last: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			out := render(t, tt.src, math.MaxInt)
+			tenth := tt.src.SampleRate() / 10
+			first, last := crossings(out[:tenth]), crossings(out[len(out)-tenth:])
+			if first < tt.first-2 || first > tt.first+2 || last < tt.last-2 || last > tt.last+2 {
+				t.Fatalf("crossings = %d at the start and %d at the end, want %d and %d", first, last, tt.first, tt.last)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Header is what the header of a stream tells before any of its audio is
+// decoded: its format, its length and its tags.
+type Header struct {
+	Format Format
+	// Frames is the length of the stream in sample frames, or -1 when the
+	// header does not tell it, as Lengther reports it.
+	Frames int64
+	// Tags are the tags read with the header, empty for formats without.
+	Tags Tags
+}
+
+// Duration returns the length of the stream, or -1 when it is not known.
+func (h Header) Duration() time.Duration {
+	return FramesDuration(h.Frames, h.Format.Rate)
+}
+
+// HeaderDecoder is implemented by decoders that read the header of a
+// stream without preparing to decode it, where Decode reads more than
+// the header, as the MP3 decoder indexes every frame of a seekable input.
+// Decoders whose Decode stops after the header need not implement it.
+// Check for it with a type assertion, or use DecodeHeader.
+type HeaderDecoder interface {
+	// DecodeHeader reads the header of the stream in r, and as little
+	// more as it can.
+	DecodeHeader(r io.Reader) (Header, error)
+}
+
+// DecodeHeader reads the header of the stream in r with d, without
+// decoding any audio, as an upload endpoint validating a file does: with
+// DecodeHeader when d is a HeaderDecoder, else by decoding r and looking
+// at the source before its first ReadSamples, through HeaderOf.
+func DecodeHeader(d Decoder, r io.Reader) (Header, error) {
+	if hd, ok := d.(HeaderDecoder); ok {
+		return hd.DecodeHeader(r)
+	}
+
+	src, err := d.Decode(r)
+	if err != nil {
+		return Header{}, err
+	}
+	h := HeaderOf(src)
+	if err := src.Close(); err != nil {
+		return Header{}, fmt.Errorf("%w", err)
+	}
+	return h, nil
+}
+
+// HeaderOf returns the Header of src from what it reports: FormatOf, and
+// Lengther and Metadata when it implements them.
+func HeaderOf(src Source) Header {
+	h := Header{Format: FormatOf(src), Frames: -1}
+	if l, ok := src.(Lengther); ok {
+		h.Frames = l.TotalFrames()
+	}
+	if m, ok := src.(Metadata); ok {
+		h.Tags = m.Metadata()
+	}
+	return h
+}
+
+// DecodeHeader recognizes the format of in as DetectAndDecode does and
+// reads its header with the matching registered decoder, as the
+// package-level DecodeHeader does. Unrecognized streams and formats
+// without a registered decoder fail with ErrUnknownFormat, and formats the
+// policy of the registry refuses with ErrRefusedByPolicy.
+func (r *Registry) DecodeHeader(in io.Reader) (Header, error) {
+	dec, in, err := r.detect(in)
+	if err != nil {
+		return Header{}, err
+	}
+	return DecodeHeader(dec, in)
+}
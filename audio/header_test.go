@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// lengthSource is a source whose container records its length and tags
+type lengthSource struct {
+	Source
+	frames int64
+	tags   Tags
+	closed bool
+}
+
+func (s *lengthSource) TotalFrames() int64 { return s.frames }
+func (s *lengthSource) Duration() time.Duration {
+	return FramesDuration(s.frames, s.SampleRate())
+}
+func (s *lengthSource) Metadata() Tags { return s.tags }
+func (s *lengthSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+// sourceDecoder decodes every input to src, or fails with err
+type sourceDecoder struct {
+	src Source
+	err error
+}
+
+func (d sourceDecoder) Decode(io.Reader) (Source, error) { return d.src, d.err }
+
+// headerDecoder reads headers only: its Decode fails
+type headerDecoder struct {
+	header Header
+}
+
+func (headerDecoder) Decode(io.Reader) (Source, error) { return nil, audiotest.ErrInjected }
+
+func (d headerDecoder) DecodeHeader(r io.Reader) (Header, error) {
+	if _, err := io.ReadAll(r); err != nil {
+		return Header{}, err
+	}
+	return d.header, nil
+}
+
+func TestDecodeHeader(t *testing.T) {
+	t.Parallel()
+
+	stereo := FormatOf(newSilentSource(8000, 2, 0))
+	known := Header{Format: Format{Rate: 48000, Channels: 1}, Frames: 480, Tags: Tags{Title: "Menu"}}
+
+	tests := []struct {
+		name    string
+		dec     func() Decoder
+		want    Header
+		wantErr error
+	}{
+		{
+			name: "plain source",
+			dec:  func() Decoder { return sourceDecoder{src: newSilentSource(8000, 2, 800)} },
+			want: Header{Format: stereo, Frames: -1},
+		},
+		{
+			name: "length and tags",
+			dec: func() Decoder {
+				return sourceDecoder{src: &lengthSource{Source: newSilentSource(8000, 2, 800), frames: 400, tags: Tags{Artist: "IVR"}}}
+			},
+			want: Header{Format: stereo, Frames: 400, Tags: Tags{Artist: "IVR"}},
+		},
+		{
+			name: "header decoder",
+			dec:  func() Decoder { return headerDecoder{header: known} },
+			want: known,
+		},
+		{
+			name:    "decode error",
+			dec:     func() Decoder { return sourceDecoder{err: audiotest.ErrInjected} },
+			wantErr: audiotest.ErrInjected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec := tt.dec()
+			h, err := DecodeHeader(dec, bytes.NewReader(nil))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeHeader() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(h, tt.want) {
+				t.Errorf("DecodeHeader() = %+v, want %+v", h, tt.want)
+			}
+			if s, ok := dec.(sourceDecoder); ok && s.src != nil {
+				if l, ok := s.src.(*lengthSource); ok && !l.closed {
+					t.Error("source not closed")
+				}
+			}
+		})
+	}
+}
+
+func TestHeader_Duration(t *testing.T) {
+	t.Parallel()
+
+	if got := (Header{Format: Format{Rate: 8000, Channels: 1}, Frames: 12000}).Duration(); got != 1500*time.Millisecond {
+		t.Errorf("Duration() = %v, want 1.5s", got)
+	}
+	if got := (Header{Format: Format{Rate: 8000, Channels: 1}, Frames: -1}).Duration(); got != -1 {
+		t.Errorf("Duration() of an unknown length = %v, want -1", got)
+	}
+}
+
+func TestRegistry_DecodeHeader(t *testing.T) {
+	t.Parallel()
+
+	want := Header{Format: Format{Rate: 8000, Channels: 1}, Frames: 8000}
+	reg := NewRegistry()
+	reg.Register("wav", headerDecoder{header: want})
+
+	wav := []byte("RIFF\x24\x00\x00\x00WAVEfmt \x10\x00\x00\x00")
+	for _, in := range []io.Reader{bytes.NewReader(wav), io.MultiReader(bytes.NewReader(wav))} {
+		if h, err := reg.DecodeHeader(in); err != nil || h.Format != want.Format || h.Frames != want.Frames {
+			t.Errorf("DecodeHeader() = %+v, %v, want %+v", h, err, want)
+		}
+	}
+
+	if _, err := reg.DecodeHeader(bytes.NewReader([]byte("not audio at all"))); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("DecodeHeader(unknown) error = %v, want ErrUnknownFormat", err)
+	}
+}
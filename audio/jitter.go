@@ -0,0 +1,360 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Defaults of JitterOptions.
+const (
+	DefaultJitterDelay      = 60 * time.Millisecond
+	DefaultJitterMaxDelay   = 200 * time.Millisecond
+	DefaultJitterMaxConceal = 60 * time.Millisecond
+)
+
+// jitterCrossfade is how long concealed audio fades into the audio
+// received after it, so the end of a loss does not click
+const jitterCrossfade = 5 * time.Millisecond
+
+// Packet is a piece of audio received from the network, for a
+// JitterBuffer.
+type Packet struct {
+	// Timestamp is the frame of the stream the packet starts at, counting
+	// sample frames as RTP timestamps do. It may wrap around.
+	Timestamp uint32
+	// Samples is the decoded audio of the packet, interleaved. The
+	// JitterBuffer keeps it: the sender must not reuse it.
+	Samples []float32
+}
+
+// JitterOptions configures a JitterBuffer. Zero values select the
+// defaults, but Format is required.
+type JitterOptions struct {
+	// Format is the format of the audio of the packets.
+	Format Format
+	// Delay is the audio buffered before playing starts, and again after
+	// the packets stopped coming, 60 ms by default. A longer one rides out
+	// more jitter, at the cost of as much latency.
+	Delay time.Duration
+	// MaxDelay is the most audio kept ahead of the playing position, 200
+	// ms by default. Beyond, as after a burst, playing skips ahead to keep
+	// Delay of it.
+	MaxDelay time.Duration
+	// MaxConceal is how long a loss is concealed before it plays as
+	// silence, 60 ms by default.
+	MaxConceal time.Duration
+}
+
+// JitterStats are what a JitterBuffer counted.
+type JitterStats struct {
+	// Received is the number of packets received.
+	Received int64
+	// Late is the number of packets dropped for coming after their audio
+	// was played, or concealed.
+	Late int64
+	// Duplicates is the number of packets dropped for having been
+	// received before.
+	Duplicates int64
+	// Concealed is the audio lost and concealed, in sample frames.
+	Concealed int64
+	// Silence is the audio played as silence, in sample frames: losses
+	// longer than MaxConceal and the time spent buffering.
+	Silence int64
+	// Skipped is the audio dropped to keep MaxDelay, in sample frames.
+	Skipped int64
+	// Buffered is the audio received ahead of the playing position, in
+	// sample frames.
+	Buffered int64
+}
+
+// JitterBuffer is a steady Source of audio received in packets, as from
+// RTP or a WebSocket, whose packets come late, out of order, twice or not
+// at all. It puts them back in order and plays them Delay behind the
+// newest one, so that packets up to that late still play in time.
+//
+// A lost packet is concealed by repeating the audio before it, fading out
+// over MaxConceal, and crossfading into the audio after it once that
+// plays. When the packets stop coming, as on silence suppression, it
+// plays silence and buffers Delay again once they are back.
+//
+// ReadSamples never blocks: it takes the packets waiting on the channel
+// and fills dst, with silence when there is no audio, so the reader sets
+// the pace, as a Pacer or a sound device does in real time. It returns
+// io.EOF once the channel is closed and all its audio was played. Stats
+// is safe to call from any goroutine while another one reads.
+type JitterBuffer struct {
+	packets    <-chan Packet
+	format     Format
+	delay      int64 // frames
+	maxDelay   int64 // frames
+	maxConceal int64 // frames
+	crossfade  int64 // frames
+
+	mu      *sync.Mutex
+	pending []Packet // by timestamp, the one playing first
+	synced  bool     // next is set, from the first packet
+	playing bool     // false while buffering
+	next    uint32   // timestamp of the next frame to play
+	prev    []float32
+	run     int64 // frames concealed in a row
+	fade    int64 // frames of the crossfade out of concealment done
+	fadeRun int64 // run of the concealment fading out
+	ended   bool  // the channel is closed
+	closed  bool
+	stats   JitterStats
+}
+
+// NewJitterBuffer creates a JitterBuffer of the packets received on
+// packets, whose audio is in opts.Format.
+func NewJitterBuffer(packets <-chan Packet, opts JitterOptions) (*JitterBuffer, error) {
+	if err := opts.Format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = DefaultJitterDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = DefaultJitterMaxDelay
+	}
+	if opts.MaxConceal <= 0 {
+		opts.MaxConceal = DefaultJitterMaxConceal
+	}
+
+	rate := opts.Format.Rate
+	crossfade := int64(max(FrameLen(rate, jitterCrossfade), 1))
+	return &JitterBuffer{
+		packets:    packets,
+		format:     opts.Format,
+		delay:      int64(FrameLen(rate, opts.Delay)),
+		maxDelay:   int64(FrameLen(rate, max(opts.MaxDelay, opts.Delay))),
+		maxConceal: int64(max(FrameLen(rate, opts.MaxConceal), 1)),
+		crossfade:  crossfade,
+		fade:       crossfade, // nothing to fade out of
+		mu:         &sync.Mutex{},
+	}, nil
+}
+
+func (j *JitterBuffer) SampleRate() int { return j.format.Rate }
+func (j *JitterBuffer) Channels() int   { return j.format.Channels }
+func (j *JitterBuffer) BufSize() int    { return 4096 }
+func (j *JitterBuffer) Format() Format  { return j.format }
+
+// Stats returns what was counted so far.
+func (j *JitterBuffer) Stats() JitterStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := j.stats
+	s.Buffered = max(j.depth(), 0)
+	return s
+}
+
+// Close stops playing: ReadSamples returns io.EOF from then on. The
+// channel is left to its sender to close.
+func (j *JitterBuffer) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.closed = true
+	j.pending = nil
+	return nil
+}
+
+// ReadSamples takes the packets received and fills dst with their audio,
+// concealment or silence. len(dst) must be a multiple of Channels().
+func (j *JitterBuffer) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%j.format.Channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return 0, io.EOF
+	}
+	j.receive()
+
+	written := 0
+	for written < len(dst) {
+		n, ok := j.fill(dst[written:])
+		if !ok {
+			break
+		}
+		written += n
+	}
+	if written == 0 && len(dst) > 0 {
+		return 0, io.EOF
+	}
+	return written, nil
+}
+
+// receive queues the packets waiting on the channel
+func (j *JitterBuffer) receive() {
+	for !j.ended {
+		select {
+		case p, ok := <-j.packets:
+			if !ok {
+				j.ended = true
+				return
+			}
+			j.push(p)
+		default:
+			return
+		}
+	}
+}
+
+// push queues p in timestamp order, unless it is late or a duplicate
+func (j *JitterBuffer) push(p Packet) {
+	ch := j.format.Channels
+	j.stats.Received++
+	p.Samples = p.Samples[:len(p.Samples)-len(p.Samples)%ch]
+	if len(p.Samples) == 0 {
+		return
+	}
+	if j.synced && int32(p.Timestamp+uint32(len(p.Samples)/ch)-j.next) <= 0 {
+		j.stats.Late++
+		return
+	}
+
+	i, found := slices.BinarySearchFunc(j.pending, p.Timestamp, func(q Packet, ts uint32) int {
+		return int(int32(q.Timestamp - ts))
+	})
+	if found {
+		j.stats.Duplicates++
+		return
+	}
+	j.pending = slices.Insert(j.pending, i, p)
+}
+
+// fill writes to dst up to the next change of what plays, reporting false
+// once the stream has ended
+func (j *JitterBuffer) fill(dst []float32) (int, bool) {
+	ch := j.format.Channels
+	frames := int64(len(dst) / ch)
+
+	if !j.playing {
+		if len(j.pending) == 0 && j.ended {
+			return 0, false
+		}
+		if !j.ended && j.span() < j.delay {
+			clear(dst)
+			j.stats.Silence += frames
+			return len(dst), true
+		}
+		j.playing = true
+		if !j.synced || int32(j.pending[0].Timestamp-j.next) > 0 {
+			j.next = j.pending[0].Timestamp
+		}
+		j.synced = true
+	}
+
+	// Drop what was played, and skip ahead when too much is queued
+	for len(j.pending) > 0 && int32(j.end(j.pending[0])-j.next) <= 0 {
+		j.pending = j.pending[1:]
+	}
+	if depth := j.depth(); depth > j.maxDelay {
+		skip := depth - j.delay
+		j.next += uint32(skip)
+		j.stats.Skipped += skip
+		for len(j.pending) > 0 && int32(j.end(j.pending[0])-j.next) <= 0 {
+			j.pending = j.pending[1:]
+		}
+	}
+
+	if len(j.pending) == 0 {
+		if j.ended {
+			return 0, false
+		}
+		// Nothing received: conceal, then buffer again
+		n := j.conceal(dst, frames)
+		if j.run >= j.maxConceal {
+			j.playing = false
+		}
+		return n, true
+	}
+
+	p := j.pending[0]
+	if ahead := int64(int32(p.Timestamp - j.next)); ahead > 0 {
+		return j.conceal(dst, min(frames, ahead)), true
+	}
+
+	off := int64(int32(j.next - p.Timestamp))
+	n := min(frames, int64(len(p.Samples)/ch)-off)
+	copy(dst, p.Samples[off*int64(ch):(off+n)*int64(ch)])
+	if j.run > 0 {
+		j.fade, j.fadeRun, j.run = 0, j.run, 0
+	}
+	for f := int64(0); f < n && j.fade < j.crossfade; f++ {
+		x := float32(j.fade+1) / float32(j.crossfade+1)
+		for c := range ch {
+			i := int(f)*ch + c
+			dst[i] = dst[i]*x + j.concealed(j.fadeRun+j.fade, c)*(1-x)
+		}
+		j.fade++
+	}
+	j.prev = p.Samples
+	j.next += uint32(n)
+	return int(n) * ch, true
+}
+
+// conceal writes frames of concealment to dst, in place of the audio from
+// next on
+func (j *JitterBuffer) conceal(dst []float32, frames int64) int {
+	ch := j.format.Channels
+	for f := range frames {
+		for c := range ch {
+			dst[int(f)*ch+c] = j.concealed(j.run+f, c)
+		}
+	}
+
+	concealed := min(frames, max(j.maxConceal-j.run, 0))
+	if j.prev == nil {
+		concealed = 0
+	}
+	j.stats.Concealed += concealed
+	j.stats.Silence += frames - concealed
+	j.run += frames
+	j.fade = j.crossfade
+	j.next += uint32(frames)
+	return int(frames) * ch
+}
+
+// concealed returns sample c of frame run of a concealment: the last
+// packet played over and over, fading out over maxConceal
+func (j *JitterBuffer) concealed(run int64, c int) float32 {
+	if j.prev == nil || run >= j.maxConceal {
+		return 0
+	}
+	ch := int64(j.format.Channels)
+	f := run % (int64(len(j.prev)) / ch)
+	return j.prev[f*ch+int64(c)] * (1 - float32(run)/float32(j.maxConceal))
+}
+
+// end returns the timestamp after the last frame of p
+func (j *JitterBuffer) end(p Packet) uint32 {
+	return p.Timestamp + uint32(len(p.Samples)/j.format.Channels)
+}
+
+// span returns the frames from the first packet queued to the end of the
+// last
+func (j *JitterBuffer) span() int64 {
+	if len(j.pending) == 0 {
+		return 0
+	}
+	return int64(int32(j.end(j.pending[len(j.pending)-1]) - j.pending[0].Timestamp))
+}
+
+// depth returns the frames queued ahead of the playing position
+func (j *JitterBuffer) depth() int64 {
+	if len(j.pending) == 0 || !j.playing {
+		return j.span()
+	}
+	return int64(int32(j.end(j.pending[len(j.pending)-1]) - j.next))
+}
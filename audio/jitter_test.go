@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// jitterPacket returns packet i of a 440 Hz tone sent in 20 ms packets at
+// 8 kHz, starting at timestamp base
+func jitterPacket(i int, base uint32) Packet {
+	samples := make([]float32, 160)
+	for f := range samples {
+		samples[f] = jitterTone(i*160 + f)
+	}
+	return Packet{Timestamp: base + uint32(i*160), Samples: samples}
+}
+
+func jitterTone(frame int) float32 {
+	return float32(0.5 * math.Sin(2*math.Pi*440*float64(frame)/8000+1))
+}
+
+// runJitter sends the packets arriving at each 20 ms step to a
+// JitterBuffer, reading 20 ms of it after each step, then closes the
+// channel and reads it to the end
+func runJitter(t *testing.T, steps [][]Packet, opts JitterOptions) ([]float32, JitterStats) {
+	t.Helper()
+
+	opts.Format = Format{Rate: 8000, Channels: 1}
+	packets := make(chan Packet, 64)
+	jb, err := NewJitterBuffer(packets, opts)
+	if err != nil {
+		t.Fatalf("NewJitterBuffer() error = %v", err)
+	}
+
+	var out []float32
+	buf := make([]float32, 160)
+	for _, step := range steps {
+		for _, p := range step {
+			packets <- p
+		}
+		n, err := jb.ReadSamples(buf)
+		if err != nil || n != len(buf) {
+			t.Fatalf("ReadSamples() = %d, %v, want a full buffer", n, err)
+		}
+		out = append(out, buf[:n]...)
+	}
+	close(packets)
+	return append(out, readAll(t, jb, 160)...), jb.Stats()
+}
+
+// inOrder returns n packets, one arriving at each step
+func inOrder(n int) [][]Packet {
+	steps := make([][]Packet, n)
+	for i := range steps {
+		steps[i] = []Packet{jitterPacket(i, 1000)}
+	}
+	return steps
+}
+
+func TestJitterBuffer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		steps func() [][]Packet
+		stats JitterStats
+	}{
+		{
+			name:  "in order",
+			steps: func() [][]Packet { return inOrder(50) },
+			stats: JitterStats{Received: 50},
+		},
+		{
+			name: "reordered",
+			steps: func() [][]Packet {
+				steps := inOrder(50)
+				for i := 5; i+1 < len(steps); i += 2 {
+					steps[i], steps[i+1] = steps[i+1], steps[i]
+				}
+				return steps
+			},
+			stats: JitterStats{Received: 50},
+		},
+		{
+			name: "jitter",
+			steps: func() [][]Packet {
+				// Past the first ones, each packet up to 40 ms late,
+				// within the 60 ms delay
+				rng := rand.New(rand.NewSource(1))
+				steps := make([][]Packet, 50)
+				for i := range 50 {
+					at := i
+					if i >= 3 {
+						at = min(i+rng.Intn(3), 49)
+					}
+					steps[at] = append(steps[at], jitterPacket(i, 1000))
+				}
+				return steps
+			},
+			stats: JitterStats{Received: 50},
+		},
+		{
+			name: "duplicates",
+			steps: func() [][]Packet {
+				steps := inOrder(50)
+				for i := range steps {
+					steps[i] = append(steps[i], steps[i][0])
+				}
+				return steps
+			},
+			stats: JitterStats{Received: 100, Duplicates: 50},
+		},
+		{
+			name: "wrapping timestamps",
+			steps: func() [][]Packet {
+				steps := make([][]Packet, 50)
+				for i := range steps {
+					steps[i] = []Packet{jitterPacket(i, math.MaxUint32-2000)}
+				}
+				return steps
+			},
+			stats: JitterStats{Received: 50},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			out, stats := runJitter(t, tt.steps(), JitterOptions{})
+
+			// 40 ms of silence, until 60 ms are buffered, then the tone
+			lead := 320
+			if len(out) != lead+50*160 {
+				t.Fatalf("read %d samples, want %d", len(out), lead+50*160)
+			}
+			for i, v := range out {
+				want := float32(0)
+				if i >= lead {
+					want = jitterTone(i - lead)
+				}
+				if v != want {
+					t.Fatalf("sample %d = %v, want %v", i, v, want)
+				}
+			}
+			tt.stats.Silence = int64(lead)
+			if stats != tt.stats {
+				t.Errorf("Stats() = %+v, want %+v", stats, tt.stats)
+			}
+		})
+	}
+}
+
+func TestJitterBuffer_Loss(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		steps func() [][]Packet
+		late  int64
+	}{
+		{
+			name: "lost",
+			steps: func() [][]Packet {
+				steps := inOrder(50)
+				steps[20] = nil
+				return steps
+			},
+		},
+		{
+			name: "too late",
+			steps: func() [][]Packet {
+				steps := inOrder(50)
+				steps[25] = append(steps[25], steps[20]...)
+				steps[20] = nil
+				return steps
+			},
+			late: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			out, stats := runJitter(t, tt.steps(), JitterOptions{})
+			if stats.Concealed != 160 || stats.Late != tt.late {
+				t.Errorf("Stats() = %+v, want 160 frames concealed, %d late", stats, tt.late)
+			}
+
+			// Packet 20 is the end of packet 19 again, fading out, and the
+			// first 5 ms of packet 21 crossfade from it
+			lead := 320
+			lost := out[lead+20*160 : lead+21*160]
+			for f, v := range lost {
+				gain := 1 - float32(f)/480
+				if want := jitterTone(19*160+f) * gain; math.Abs(float64(v-want)) > 1e-6 {
+					t.Fatalf("concealed frame %d = %v, want %v", f, v, want)
+				}
+			}
+			for i := lead + 21*160 + 40; i < len(out); i++ {
+				if want := jitterTone(i - lead); out[i] != want {
+					t.Fatalf("sample %d = %v, want %v after the crossfade", i, out[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestJitterBuffer_Outage(t *testing.T) {
+	t.Parallel()
+
+	// The packets stop for a second, as on silence suppression, then
+	// come back with the timestamps of the time passed
+	steps := inOrder(100)
+	for i := 20; i < 70; i++ {
+		steps[i] = nil
+	}
+	out, stats := runJitter(t, steps, JitterOptions{})
+
+	// 60 ms concealed, silence until 60 ms are buffered again, the rest
+	// of the tone
+	if stats.Concealed != 480 {
+		t.Errorf("Concealed = %d, want 480", stats.Concealed)
+	}
+	resumed := len(out) - 30*160
+	if stats.Silence != int64(resumed-20*160-480) {
+		t.Errorf("Silence = %d, want %d", stats.Silence, resumed-20*160-480)
+	}
+	for i := resumed + 40; i < len(out); i++ {
+		if want := jitterTone(i - resumed + 70*160); out[i] != want {
+			t.Fatalf("sample %d = %v, want %v", i, out[i], want)
+		}
+	}
+}
+
+func TestJitterBuffer_Burst(t *testing.T) {
+	t.Parallel()
+
+	// Packets 10 to 29 held up and delivered at once
+	steps := inOrder(50)
+	for i := 10; i < 30; i++ {
+		steps[i] = nil
+	}
+	for i := 10; i < 30; i++ {
+		steps[29] = append(steps[29], jitterPacket(i, 1000))
+	}
+
+	packets := make(chan Packet, 64)
+	jb, err := NewJitterBuffer(packets, JitterOptions{Format: Format{Rate: 8000, Channels: 1}, MaxDelay: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]float32, 160)
+	for _, step := range steps {
+		for _, p := range step {
+			packets <- p
+		}
+		if _, err := jb.ReadSamples(buf); err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+		if s := jb.Stats(); s.Buffered > 800 {
+			t.Fatalf("Buffered = %d frames, above MaxDelay", s.Buffered)
+		}
+	}
+	if s := jb.Stats(); s.Skipped == 0 || s.Late == 0 {
+		t.Errorf("Stats() = %+v, want audio skipped and late packets", s)
+	}
+}
+
+func TestJitterBuffer_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewJitterBuffer(nil, JitterOptions{}); err == nil {
+		t.Error("NewJitterBuffer() without a format error = nil")
+	}
+
+	packets := make(chan Packet, 1)
+	jb, err := NewJitterBuffer(packets, JitterOptions{Format: Format{Rate: 8000, Channels: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jb.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	// Nothing received: silence, without blocking
+	buf := make([]float32, 320)
+	if n, err := jb.ReadSamples(buf); n != 320 || err != nil {
+		t.Errorf("ReadSamples() = %d, %v, want silence", n, err)
+	}
+
+	if err := jb.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := jb.ReadSamples(buf); err != io.EOF {
+		t.Errorf("ReadSamples() after Close error = %v, want io.EOF", err)
+	}
+}
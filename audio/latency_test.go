@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// The nodes are meant for live calls, so none of them may add noticeable
+// delay to the signal. A linear-phase FIR delays by half its length.
+func TestNodes_Latency(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		rate       int
+		build      func(Source) Source
+		maxLatency time.Duration
+	}{
+		{"resampler 16k->8k", 16000, func(s Source) Source { return NewResampler(s, 8000) }, time.Millisecond},
+		{"resampler 8k->48k", 8000, func(s Source) Source { return NewResampler(s, 48000) }, time.Millisecond},
+		{"mono mixer", 8000, func(s Source) Source { return NewMonoMixer(s) }, 0},
+		{"fir 5 taps", 8000, func(s Source) Source { return NewFIR(s, hannTaps(5)) }, 500 * time.Microsecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			probe := audiotest.NewLatencyProbe(tt.rate)
+			res, err := probe.Measure(tt.build(probe.Source(2, 500*time.Millisecond)))
+			if err != nil {
+				t.Fatalf("Measure() error = %v", err)
+			}
+			if res.Latency < -tt.maxLatency || res.Latency > tt.maxLatency {
+				t.Errorf("latency = %v (%d samples), want within ±%v", res.Latency, res.Samples, tt.maxLatency)
+			}
+		})
+	}
+}
+
+// hannTaps returns a normalized Hann window, a linear-phase low-pass filter
+func hannTaps(n int) []float32 {
+	taps := make([]float32, n)
+	var sum float32
+	for i := range taps {
+		taps[i] = float32(0.5 - 0.5*math.Cos(2*math.Pi*float64(i+1)/float64(n+1)))
+		sum += taps[i]
+	}
+	for i := range taps {
+		taps[i] /= sum
+	}
+	return taps
+}
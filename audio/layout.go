@@ -0,0 +1,283 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// ChannelLabel identifies a single speaker position. The values match the
+// dwChannelMask bits of WAVEFORMATEXTENSIBLE, so a label is a one bit Layout.
+type ChannelLabel uint32
+
+const (
+	ChannelFL  ChannelLabel = 1 << iota // front left
+	ChannelFR                           // front right
+	ChannelFC                           // front center
+	ChannelLFE                          // low frequency effects
+	ChannelBL                           // back left
+	ChannelBR                           // back right
+	ChannelFLC                          // front left of center
+	ChannelFRC                          // front right of center
+	ChannelBC                           // back center
+	ChannelSL                           // side left
+	ChannelSR                           // side right
+	ChannelTC                           // top center
+	ChannelTFL                          // top front left
+	ChannelTFC                          // top front center
+	ChannelTFR                          // top front right
+	ChannelTBL                          // top back left
+	ChannelTBC                          // top back center
+	ChannelTBR                          // top back right
+)
+
+var channelLabelNames = [...]string{
+	"FL", "FR", "C", "LFE", "BL", "BR", "FLC", "FRC", "BC",
+	"SL", "SR", "TC", "TFL", "TFC", "TFR", "TBL", "TBC", "TBR",
+}
+
+// String returns the short speaker name (FL, FR, C, LFE, ...).
+func (c ChannelLabel) String() string {
+	if bits.OnesCount32(uint32(c)) == 1 {
+		idx := bits.TrailingZeros32(uint32(c))
+		if idx < len(channelLabelNames) {
+			return channelLabelNames[idx]
+		}
+	}
+	return "0x" + strconv.FormatUint(uint64(c), 16)
+}
+
+// Layout describes how the channels of a stream are arranged.
+// It is a bit mask of ChannelLabel values; interleaved channels appear in
+// ascending bit order, as in WAVEFORMATEXTENSIBLE.
+type Layout uint32
+
+const (
+	// LayoutUnknown means the positions of the channels are not known.
+	LayoutUnknown Layout = 0
+	// LayoutMono is a single center channel.
+	LayoutMono = Layout(ChannelFC)
+	// LayoutStereo is a left/right pair.
+	LayoutStereo = Layout(ChannelFL | ChannelFR)
+	// Layout2_1 is stereo with a low frequency channel.
+	Layout2_1 = LayoutStereo | Layout(ChannelLFE)
+	// LayoutSurround is left, right and center (3.0).
+	LayoutSurround = LayoutStereo | Layout(ChannelFC)
+	// LayoutQuad is front and back pairs.
+	LayoutQuad = LayoutStereo | Layout(ChannelBL|ChannelBR)
+	// Layout5_1 is the common back-surround 5.1 arrangement.
+	Layout5_1 = LayoutSurround | Layout(ChannelLFE|ChannelBL|ChannelBR)
+	// Layout5_1Side is 5.1 using side instead of back surrounds.
+	Layout5_1Side = LayoutSurround | Layout(ChannelLFE|ChannelSL|ChannelSR)
+	// Layout7_1 is 5.1 with both back and side surrounds.
+	Layout7_1 = Layout5_1 | Layout(ChannelSL|ChannelSR)
+)
+
+// DefaultLayout returns the conventional layout for a channel count,
+// or LayoutUnknown when there is no single obvious layout.
+func DefaultLayout(channels int) Layout {
+	switch channels {
+	case 1:
+		return LayoutMono
+	case 2:
+		return LayoutStereo
+	case 3:
+		return LayoutSurround
+	case 4:
+		return LayoutQuad
+	case 6:
+		return Layout5_1
+	case 8:
+		return Layout7_1
+	default:
+		return LayoutUnknown
+	}
+}
+
+// LayoutFromMask converts a WAVEFORMATEXTENSIBLE dwChannelMask to a Layout.
+// Reserved bits are dropped.
+func LayoutFromMask(mask uint32) Layout {
+	return Layout(mask & (uint32(ChannelTBR)<<1 - 1))
+}
+
+// vorbisLayouts are the layouts of 1 to 8 channels in the Vorbis channel
+// order, and the index in that order of each channel of the layout, in
+// interleaving order, when the two orders differ
+var vorbisLayouts = [...]struct {
+	layout Layout
+	order  []int
+}{
+	1: {layout: LayoutMono},
+	2: {layout: LayoutStereo},
+	// L C R
+	3: {layout: LayoutSurround, order: []int{0, 2, 1}},
+	// FL FR BL BR
+	4: {layout: LayoutQuad},
+	// FL C FR BL BR
+	5: {layout: LayoutSurround | Layout(ChannelBL|ChannelBR), order: []int{0, 2, 1, 3, 4}},
+	// FL C FR BL BR LFE
+	6: {layout: Layout5_1, order: []int{0, 2, 1, 5, 3, 4}},
+	// FL C FR SL SR BC LFE
+	7: {
+		layout: LayoutSurround | Layout(ChannelLFE|ChannelBC|ChannelSL|ChannelSR),
+		order:  []int{0, 2, 1, 6, 5, 3, 4},
+	},
+	// FL C FR SL SR BL BR LFE
+	8: {layout: Layout7_1, order: []int{0, 2, 1, 7, 5, 6, 3, 4}},
+}
+
+// VorbisLayout returns the layout of channels in the channel order of the
+// Vorbis specification, which Ogg Opus channel mapping families 0 and 1
+// share. That order is not the interleaving order of Layout from 3
+// channels on (5.1 is FL C FR BL BR LFE), so it also returns the order to
+// pass to ReorderChannels, or nil when the two orders agree. Beyond 8
+// channels it returns LayoutUnknown and nil. The order must not be
+// modified.
+func VorbisLayout(channels int) (Layout, []int) {
+	if channels < 1 || channels >= len(vorbisLayouts) {
+		return LayoutUnknown, nil
+	}
+	v := vorbisLayouts[channels]
+	return v.layout, v.order
+}
+
+// ReorderChannels rearranges the interleaved frames of samples in place, so
+// channel i of every frame takes the sample of channel order[i]. A partial
+// frame at the end is left alone.
+func ReorderChannels(samples []float32, order []int) {
+	var buf [8]float32
+	frame := buf[:0]
+	if len(order) > len(buf) {
+		frame = make([]float32, len(order))
+	}
+	frame = frame[:len(order)]
+
+	for i := 0; i+len(order) <= len(samples); i += len(order) {
+		f := samples[i : i+len(order)]
+		copy(frame, f)
+		for c, from := range order {
+			f[c] = frame[from]
+		}
+	}
+}
+
+// cafLabels maps CoreAudio (CAF) kAudioChannelLabel_* values to labels.
+var cafLabels = map[uint32]ChannelLabel{
+	1:  ChannelFL,
+	2:  ChannelFR,
+	3:  ChannelFC,
+	4:  ChannelLFE,
+	5:  ChannelBL, // left surround
+	6:  ChannelBR, // right surround
+	7:  ChannelFLC,
+	8:  ChannelFRC,
+	9:  ChannelBC,
+	10: ChannelSL, // left surround direct
+	11: ChannelSR, // right surround direct
+	12: ChannelTC,
+	13: ChannelTFL,
+	14: ChannelTFC,
+	15: ChannelTFR,
+	16: ChannelTBL,
+	17: ChannelTBC,
+	18: ChannelTBR,
+	42: ChannelFC, // mono
+}
+
+// LayoutFromCAFLabels builds a Layout from CoreAudio channel labels as found
+// in a CAF "chan" chunk. It returns LayoutUnknown when a label has no
+// equivalent or is repeated, since the mask could not describe the stream.
+func LayoutFromCAFLabels(labels []uint32) Layout {
+	var l Layout
+	for _, v := range labels {
+		c, ok := cafLabels[v]
+		if !ok || l.Has(c) {
+			return LayoutUnknown
+		}
+		l |= Layout(c)
+	}
+	return l
+}
+
+// Count returns the number of channels described by the layout.
+func (l Layout) Count() int {
+	return bits.OnesCount32(uint32(l))
+}
+
+// Has reports whether the layout contains the given speaker.
+func (l Layout) Has(c ChannelLabel) bool {
+	return uint32(l)&uint32(c) != 0
+}
+
+// Labels returns the speakers of the layout in interleaving order.
+func (l Layout) Labels() []ChannelLabel {
+	labels := make([]ChannelLabel, 0, l.Count())
+	for m := uint32(l); m != 0; m &= m - 1 {
+		labels = append(labels, ChannelLabel(m&-m))
+	}
+	return labels
+}
+
+// String returns the name of common layouts or the joined labels otherwise.
+func (l Layout) String() string {
+	switch l {
+	case LayoutUnknown:
+		return "unknown"
+	case LayoutMono:
+		return "mono"
+	case LayoutStereo:
+		return "stereo"
+	case Layout5_1:
+		return "5.1"
+	case Layout7_1:
+		return "7.1"
+	}
+
+	labels := l.Labels()
+	names := make([]string, len(labels))
+	for i, c := range labels {
+		names[i] = c.String()
+	}
+	return strings.Join(names, "+")
+}
+
+// monoDownmixGain returns the weight of a speaker when folding to mono,
+// following the ITU-R BS.775 convention: fronts at unity, surrounds at -3 dB
+// and the LFE channel dropped.
+func monoDownmixGain(c ChannelLabel) float32 {
+	switch c {
+	case ChannelFL, ChannelFR, ChannelFC, ChannelFLC, ChannelFRC:
+		return 1
+	case ChannelLFE:
+		return 0
+	default:
+		return 0.70710677
+	}
+}
+
+// MonoDownmixWeights returns per-channel weights that fold the given layout
+// to mono. The weights sum to 1 so a signal common to all weighted channels
+// keeps its level. It returns nil when the layout does not describe exactly
+// channels speakers.
+func MonoDownmixWeights(l Layout, channels int) []float32 {
+	if l == LayoutUnknown || l.Count() != channels {
+		return nil
+	}
+
+	labels := l.Labels()
+	weights := make([]float32, len(labels))
+	var sum float32
+	for i, c := range labels {
+		weights[i] = monoDownmixGain(c)
+		sum += weights[i]
+	}
+	if sum == 0 {
+		return nil
+	}
+	for i := range weights {
+		weights[i] /= sum
+	}
+	return weights
+}
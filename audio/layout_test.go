@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// layoutSource attaches an explicit speaker layout to a mock source
+type layoutSource struct {
+	*audiotest.MockSource
+	layout Layout
+}
+
+func (s layoutSource) Format() Format {
+	return Format{
+		Rate:       s.SampleRate(),
+		Channels:   s.Channels(),
+		Layout:     s.layout,
+		SampleKind: SampleFloat32,
+	}
+}
+
+func TestLayout_Labels(t *testing.T) {
+	t.Parallel()
+
+	got := Layout5_1.Labels()
+	want := []ChannelLabel{ChannelFL, ChannelFR, ChannelFC, ChannelLFE, ChannelBL, ChannelBR}
+	if len(got) != len(want) {
+		t.Fatalf("Labels() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Labels()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if Layout5_1.Count() != 6 {
+		t.Errorf("Count() = %d, want 6", Layout5_1.Count())
+	}
+	if !Layout5_1.Has(ChannelLFE) || Layout5_1.Has(ChannelSL) {
+		t.Error("Has() returned wrong membership for 5.1")
+	}
+}
+
+func TestLayout_String(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		layout Layout
+		want   string
+	}{
+		{LayoutMono, "mono"},
+		{LayoutStereo, "stereo"},
+		{Layout5_1, "5.1"},
+		{LayoutQuad, "FL+FR+BL+BR"},
+		{LayoutUnknown, "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.layout.String(); got != tt.want {
+			t.Errorf("Layout(%#x).String() = %q, want %q", uint32(tt.layout), got, tt.want)
+		}
+	}
+}
+
+func TestLayoutFromMask(t *testing.T) {
+	t.Parallel()
+
+	// 5.1 mask as written by most WAVEFORMATEXTENSIBLE encoders
+	if got := LayoutFromMask(0x3F); got != Layout5_1 {
+		t.Errorf("LayoutFromMask(0x3F) = %v, want 5.1", got)
+	}
+
+	// Reserved high bits are dropped
+	if got := LayoutFromMask(0x80000003); got != LayoutStereo {
+		t.Errorf("LayoutFromMask(0x80000003) = %v, want stereo", got)
+	}
+}
+
+func TestLayoutFromCAFLabels(t *testing.T) {
+	t.Parallel()
+
+	if got := LayoutFromCAFLabels([]uint32{1, 2, 3, 4, 5, 6}); got != Layout5_1 {
+		t.Errorf("LayoutFromCAFLabels(5.1) = %v, want 5.1", got)
+	}
+
+	if got := LayoutFromCAFLabels([]uint32{1, 1}); got != LayoutUnknown {
+		t.Errorf("LayoutFromCAFLabels(duplicate) = %v, want unknown", got)
+	}
+
+	if got := LayoutFromCAFLabels([]uint32{1, 999}); got != LayoutUnknown {
+		t.Errorf("LayoutFromCAFLabels(unknown label) = %v, want unknown", got)
+	}
+}
+
+func TestVorbisLayout(t *testing.T) {
+	t.Parallel()
+
+	// The channel orders of the Vorbis I specification, section 4.3.9
+	orders := [][]ChannelLabel{
+		1: {ChannelFC},
+		2: {ChannelFL, ChannelFR},
+		3: {ChannelFL, ChannelFC, ChannelFR},
+		4: {ChannelFL, ChannelFR, ChannelBL, ChannelBR},
+		5: {ChannelFL, ChannelFC, ChannelFR, ChannelBL, ChannelBR},
+		6: {ChannelFL, ChannelFC, ChannelFR, ChannelBL, ChannelBR, ChannelLFE},
+		7: {ChannelFL, ChannelFC, ChannelFR, ChannelSL, ChannelSR, ChannelBC, ChannelLFE},
+		8: {ChannelFL, ChannelFC, ChannelFR, ChannelSL, ChannelSR, ChannelBL, ChannelBR, ChannelLFE},
+	}
+
+	for channels := 1; channels < len(orders); channels++ {
+		layout, order := VorbisLayout(channels)
+
+		// Two frames, each sample holding the label of its channel
+		var frames []float32
+		for range 2 {
+			for _, c := range orders[channels] {
+				frames = append(frames, float32(c))
+			}
+		}
+		if order != nil {
+			ReorderChannels(frames, order)
+		}
+
+		labels := layout.Labels()
+		if len(labels) != channels {
+			t.Fatalf("VorbisLayout(%d) = %v", channels, layout)
+		}
+		for i, v := range frames {
+			if got := ChannelLabel(v); got != labels[i%channels] {
+				t.Errorf("VorbisLayout(%d): sample %d is %v, want %v of %v", channels, i, got, labels[i%channels], layout)
+			}
+		}
+	}
+
+	if l, order := VorbisLayout(9); l != LayoutUnknown || order != nil {
+		t.Errorf("VorbisLayout(9) = %v, %v, want unknown", l, order)
+	}
+}
+
+func TestMonoDownmixWeights(t *testing.T) {
+	t.Parallel()
+
+	w := MonoDownmixWeights(Layout5_1, 6)
+	if len(w) != 6 {
+		t.Fatalf("MonoDownmixWeights() len = %d, want 6", len(w))
+	}
+
+	var sum float32
+	for _, v := range w {
+		sum += v
+	}
+	if math.Abs(float64(sum-1)) > 1e-6 {
+		t.Errorf("weights sum = %v, want 1", sum)
+	}
+
+	if w[3] != 0 {
+		t.Errorf("LFE weight = %v, want 0", w[3])
+	}
+	if w[4] >= w[0] {
+		t.Errorf("surround weight %v should be below front weight %v", w[4], w[0])
+	}
+
+	if MonoDownmixWeights(Layout5_1, 2) != nil {
+		t.Error("MonoDownmixWeights() with mismatched channel count should be nil")
+	}
+}
+
+func TestMonoMixer_LayoutDropsLFE(t *testing.T) {
+	t.Parallel()
+
+	// Only the LFE channel carries signal; a 5.1 aware downmix must drop it
+	src := layoutSource{
+		MockSource: audiotest.NewMockSource(8000, 6, 100, func(sample int, channel int) float32 {
+			if channel == 3 {
+				return 1.0
+			}
+			return 0
+		}),
+		layout: Layout5_1,
+	}
+
+	mixer := NewMonoMixer(src)
+	buf := make([]float32, 10)
+	n, err := mixer.ReadSamples(buf)
+	if err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+
+	for i := range n {
+		if buf[i] != 0 {
+			t.Fatalf("buf[%d] = %v, want 0 (LFE must be dropped)", i, buf[i])
+		}
+	}
+}
+
+func TestMonoMixer_StereoLayoutUsesAverage(t *testing.T) {
+	t.Parallel()
+
+	src := layoutSource{
+		MockSource: audiotest.NewConstantSource(8000, 2, 100, 0.5),
+		layout:     LayoutStereo,
+	}
+
+	mixer := NewMonoMixer(src)
+	if mixer.weights != nil {
+		t.Error("stereo layout should keep the plain averaging path")
+	}
+}
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import "time"
+
+// Lengther is implemented by sources whose container records the length of
+// the stream, so it can be known without decoding it: the data size of a
+// WAV file, the frame count of an AIFF file, the last granule position of
+// an Ogg file, ...
+type Lengther interface {
+	// TotalFrames returns the length of the stream in sample frames, or
+	// -1 when this particular stream does not record it, such as a WAV
+	// file written to a pipe or an MP3 read from a network stream.
+	TotalFrames() int64
+	// Duration returns the length of the stream, or -1 when it is not
+	// known.
+	Duration() time.Duration
+}
+
+// FramesDuration returns the time spanned by frames at rate, or -1 when
+// frames is negative, as it is for unknown lengths.
+func FramesDuration(frames int64, rate int) time.Duration {
+	if frames < 0 || rate <= 0 {
+		return -1
+	}
+
+	// Split off whole seconds so long streams do not overflow
+	r := int64(rate)
+	return time.Duration(frames/r)*time.Second + time.Duration(frames%r)*time.Second/time.Duration(r)
+}
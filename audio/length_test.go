@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFramesDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		frames int64
+		rate   int
+		want   time.Duration
+	}{
+		{name: "zero", frames: 0, rate: 8000, want: 0},
+		{name: "one second", frames: 8000, rate: 8000, want: time.Second},
+		{name: "fraction", frames: 22050 + 441, rate: 44100, want: 510 * time.Millisecond},
+		{name: "one frame at 48 kHz", frames: 1, rate: 48000, want: 20833 * time.Nanosecond},
+		// frames*time.Second alone would overflow
+		{name: "a week at 192 kHz", frames: 7 * 24 * 3600 * 192000, rate: 192000, want: 7 * 24 * time.Hour},
+		{name: "unknown", frames: -1, rate: 8000, want: -1},
+		{name: "invalid rate", frames: 100, rate: 0, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := FramesDuration(tt.frames, tt.rate); got != tt.want {
+				t.Errorf("FramesDuration(%d, %d) = %v, want %v", tt.frames, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LoopRegion is the part of a sound a sampler repeats, from frame Start up
+// to, not including, frame End.
+type LoopRegion struct {
+	Start, End int64
+}
+
+// LoopPoints is implemented by sources whose container marks a loop, such
+// as the WAV decoder for a smpl chunk and the AIFF decoder for INST and
+// MARK chunks. Looper honors it.
+type LoopPoints interface {
+	// LoopPoints returns the loop marked in the file, and false when
+	// there is none.
+	LoopPoints() (LoopRegion, bool)
+}
+
+// Looper is a Source playing another one over and over, such as hold
+// music or an announcement repeated until the caller is answered. It
+// rewinds the source by seeking, so the source must implement Seeker, as
+// the decoders do when reading from a file.
+//
+// When the source implements LoopPoints, as music made to loop does, the
+// Looper plays it up to the end of the loop and then repeats only the
+// loop, so that an intro is not replayed; each play of the loop is a pass.
+// After the last pass it plays on to the end of the source.
+type Looper struct {
+	src    Source
+	seeker Seeker
+	start  int64       // frame each pass starts at
+	region *LoopRegion // loop marked in the file, nil for the whole source
+	times  int         // passes to play, 0 for no end
+	passes int         // passes ended
+	played bool        // the current pass has played a sample
+	ended  bool
+}
+
+// Loop creates a Looper playing src times times, from the frame it is at.
+// It fails with ErrUnsupported when src cannot seek, and with
+// ErrInvalidState when times is below 1.
+func Loop(src Source, times int) (*Looper, error) {
+	if times < 1 {
+		return nil, fmt.Errorf("%w: loop of %d passes", ErrInvalidState, times)
+	}
+	return newLooper(src, times)
+}
+
+// LoopForever creates a Looper playing src with no end, from the frame it
+// is at. It fails with ErrUnsupported when src cannot seek.
+func LoopForever(src Source) (*Looper, error) {
+	return newLooper(src, 0)
+}
+
+func newLooper(src Source, times int) (*Looper, error) {
+	if err := FormatOf(src).Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	s, ok := src.(Seeker)
+	if !ok {
+		return nil, fmt.Errorf("%w: looping a source that cannot seek", ErrUnsupported)
+	}
+	l := &Looper{src: src, seeker: s, start: s.Position(), times: times}
+	if lp, ok := src.(LoopPoints); ok {
+		// A loop already behind the position is never reached
+		if r, ok := lp.LoopPoints(); ok && r.Start >= 0 && r.Start < r.End && r.End > l.start {
+			l.region = &r
+			l.start = r.Start
+		}
+	}
+	return l, nil
+}
+
+func (l *Looper) SampleRate() int    { return l.src.SampleRate() }
+func (l *Looper) Channels() int      { return l.src.Channels() }
+func (l *Looper) BufSize() int       { return l.src.BufSize() }
+func (l *Looper) Format() Format     { return FormatOf(l.src) }
+func (l *Looper) Upstream() []Source { return []Source{l.src} }
+
+// Passes returns the number of passes played to the end so far. Call it
+// from the goroutine that reads.
+func (l *Looper) Passes() int { return l.passes }
+
+func (l *Looper) Close() error {
+	if err := l.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst from the source, rewinding it at the end of the
+// loop until the last pass has played. An empty source, or loop, ends the
+// loop at once.
+func (l *Looper) ReadSamples(dst []float32) (int, error) {
+	ch := l.src.Channels()
+	if len(dst)%ch != 0 {
+		return 0, ErrInvalidDstSize
+	}
+	if l.ended {
+		return 0, io.EOF
+	}
+
+	written := 0
+	for written < len(dst) {
+		want := dst[written:]
+		looping := l.region != nil && (l.times == 0 || l.passes < l.times)
+		if looping {
+			left := l.region.End - l.seeker.Position()
+			if left <= 0 {
+				if err := l.endPass(); err != nil {
+					return written, err
+				}
+				continue
+			}
+			want = want[:min(int64(len(want)), left*int64(ch))]
+		}
+
+		n, err := l.src.ReadSamples(want)
+		written += n - n%ch
+		l.played = l.played || n > 0
+
+		if errors.Is(err, io.EOF) {
+			// Past the last pass of a loop, the source plays out
+			if l.region != nil && !looping {
+				l.ended = true
+				return written, io.EOF
+			}
+			if err := l.endPass(); err != nil {
+				return written, err
+			}
+			if l.ended {
+				return written, io.EOF
+			}
+			continue
+		}
+		if err != nil {
+			return written, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return written, nil
+}
+
+// endPass counts a pass played to its end and rewinds for the next one,
+// unless it was the last or played nothing. Past the last pass of a loop
+// region, the source is left to play on.
+func (l *Looper) endPass() error {
+	l.passes++
+	if !l.played || l.passes == l.times {
+		l.ended = l.region == nil || !l.played
+		return nil
+	}
+	if err := l.seeker.SeekFrame(l.start); err != nil {
+		return fmt.Errorf("rewinding: %w", err)
+	}
+	l.played = false
+	return nil
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestLoop(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		times  int // 0 for LoopForever
+		start  int64
+		chunk  int
+		read   int // samples to read of an endless loop
+		want   []float32
+		passes int
+	}{
+		{name: "three times", times: 3, chunk: 2, want: []float32{1, 2, 3, 1, 2, 3, 1, 2, 3}, passes: 3},
+		{name: "once", times: 1, chunk: 100, want: []float32{1, 2, 3}, passes: 1},
+		{name: "from the position", times: 2, start: 1, chunk: 5, want: []float32{2, 3, 2, 3}, passes: 2},
+		{name: "forever", chunk: 4, read: 20, want: []float32{1, 2, 3, 1, 2, 3, 1, 2, 3, 1, 2, 3, 1, 2, 3, 1, 2, 3, 1, 2}, passes: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src := newSeekSource([]float32{1, 2, 3})
+			if err := src.SeekFrame(tt.start); err != nil {
+				t.Fatal(err)
+			}
+			var (
+				l   *Looper
+				err error
+			)
+			if tt.times == 0 {
+				l, err = LoopForever(src)
+			} else {
+				l, err = Loop(src, tt.times)
+			}
+			if err != nil {
+				t.Fatalf("Loop() error = %v", err)
+			}
+
+			var got []float32
+			if tt.read > 0 {
+				got = make([]float32, tt.read)
+				for n := 0; n < len(got); {
+					k, err := l.ReadSamples(got[n:min(n+tt.chunk, len(got))])
+					if err != nil {
+						t.Fatalf("ReadSamples() error = %v", err)
+					}
+					n += k
+				}
+			} else {
+				got = readAll(t, l, tt.chunk)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("played %v, want %v", got, tt.want)
+			}
+			if l.Passes() != tt.passes {
+				t.Errorf("Passes() = %d, want %d", l.Passes(), tt.passes)
+			}
+		})
+	}
+}
+
+// loopSource is a seekSource with loop points
+type loopSource struct {
+	*seekSource
+	region LoopRegion
+}
+
+func (s *loopSource) LoopPoints() (LoopRegion, bool) { return s.region, true }
+
+func TestLoop_Region(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		region LoopRegion
+		times  int // 0 for LoopForever
+		start  int64
+		chunk  int
+		read   int // samples to read of an endless loop
+		want   []float32
+		passes int
+	}{
+		{name: "intro, loop, outro", region: LoopRegion{Start: 2, End: 4}, times: 3, chunk: 3, want: []float32{1, 2, 3, 4, 3, 4, 3, 4, 5, 6}, passes: 3},
+		{name: "once", region: LoopRegion{Start: 2, End: 4}, times: 1, chunk: 100, want: []float32{1, 2, 3, 4, 5, 6}, passes: 1},
+		{name: "to the end", region: LoopRegion{Start: 4, End: 6}, times: 2, chunk: 4, want: []float32{1, 2, 3, 4, 5, 6, 5, 6}, passes: 2},
+		{name: "past the end", region: LoopRegion{Start: 4, End: 10}, times: 2, chunk: 4, want: []float32{1, 2, 3, 4, 5, 6, 5, 6}, passes: 2},
+		{name: "from inside the loop", region: LoopRegion{Start: 1, End: 4}, times: 2, start: 2, chunk: 1, want: []float32{3, 4, 2, 3, 4, 5, 6}, passes: 2},
+		{name: "behind the position", region: LoopRegion{Start: 0, End: 2}, times: 2, start: 3, chunk: 2, want: []float32{4, 5, 6, 4, 5, 6}, passes: 2},
+		{name: "empty", region: LoopRegion{Start: 3, End: 3}, times: 2, chunk: 8, want: []float32{1, 2, 3, 4, 5, 6, 1, 2, 3, 4, 5, 6}, passes: 2},
+		{name: "forever", region: LoopRegion{Start: 1, End: 3}, chunk: 4, read: 9, want: []float32{1, 2, 3, 2, 3, 2, 3, 2, 3}, passes: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src := &loopSource{seekSource: newSeekSource([]float32{1, 2, 3, 4, 5, 6}), region: tt.region}
+			if err := src.SeekFrame(tt.start); err != nil {
+				t.Fatal(err)
+			}
+			var (
+				l   *Looper
+				err error
+			)
+			if tt.times == 0 {
+				l, err = LoopForever(src)
+			} else {
+				l, err = Loop(src, tt.times)
+			}
+			if err != nil {
+				t.Fatalf("Loop() error = %v", err)
+			}
+
+			var got []float32
+			if tt.read > 0 {
+				got = make([]float32, tt.read)
+				for n := 0; n < len(got); {
+					k, err := l.ReadSamples(got[n:min(n+tt.chunk, len(got))])
+					if err != nil {
+						t.Fatalf("ReadSamples() error = %v", err)
+					}
+					n += k
+				}
+			} else {
+				got = readAll(t, l, tt.chunk)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("played %v, want %v", got, tt.want)
+			}
+			if l.Passes() != tt.passes {
+				t.Errorf("Passes() = %d, want %d", l.Passes(), tt.passes)
+			}
+		})
+	}
+}
+
+func TestLoop_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := LoopForever(newConstantSource(8000, 1, 10, 0.5)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("LoopForever(no Seeker) error = %v, want ErrUnsupported", err)
+	}
+	if _, err := Loop(newSeekSource([]float32{1}), 0); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Loop(0 times) error = %v, want ErrInvalidState", err)
+	}
+
+	// An empty source must not spin
+	l, err := LoopForever(newSeekSource(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, l, 8); len(got) != 0 {
+		t.Errorf("played %d samples of an empty source", len(got))
+	}
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FormatSupport is a row of the supported format matrix of a Registry:
+// a registered format key and what its decoder reports.
+type FormatSupport struct {
+	// Format is the key the decoder is registered under, such as "wav".
+	Format string
+	// MIMETypes are the MIME types the same decoder is registered for,
+	// sorted.
+	MIMETypes []string
+	// Capabilities is what the decoder reports, when Reported.
+	Capabilities Capabilities
+	// Reported is false for decoders that do not implement
+	// CapabilityReporter, of which only the key is known.
+	Reported bool
+}
+
+// Matrix returns the supported format matrix of r: a row for every
+// registered format key the policy allows, sorted by key, from the
+// Capabilities its decoder reports. Applications list the formats they support from it, and
+// WriteMatrix renders it, rather than keeping a list of their own.
+func (r *Registry) Matrix() []FormatSupport {
+	r.mtx.Lock()
+	codecs := maps.Clone(r.codecs)
+	maps.DeleteFunc(codecs, func(k string, d Decoder) bool { return !r.allowed(k, d) })
+	mimes := maps.Clone(r.mimes)
+	maps.DeleteFunc(mimes, func(t string, d Decoder) bool { return !r.allowed(t, d) })
+	r.mtx.Unlock()
+
+	types := slices.Sorted(maps.Keys(mimes))
+	rows := make([]FormatSupport, 0, len(codecs))
+	for _, format := range slices.Sorted(maps.Keys(codecs)) {
+		d := codecs[format]
+		row := FormatSupport{Format: format}
+		row.Capabilities, row.Reported = CapabilitiesOf(d)
+		for _, t := range types {
+			if sameDecoder(mimes[t], d) {
+				row.MIMETypes = append(row.MIMETypes, t)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// sameDecoder reports whether a and b are the same decoder. Decoders are
+// often values, such as wav.Decoder{}, registered for a key and a MIME
+// type apart, so they are compared by value.
+func sameDecoder(a, b Decoder) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.DeepEqual(a, b)
+}
+
+// WriteMatrix writes rows as a Markdown table, such as:
+//
+//	| Format | Name | MIME types | Decode | Encode | Channels | Rates | Seek | Tags |
+//	|---|---|---|---|---|---|---|---|---|
+//	| wav | WAV | audio/wav, audio/x-wav | 8, 16, 24, 32-bit | 16-bit | up to 2 | any | yes | yes |
+//
+// which reads as well in a terminal as in documentation. Decoders that do
+// not report their capabilities have a row with only their key and MIME
+// types.
+func WriteMatrix(w io.Writer, rows []FormatSupport) error {
+	var b strings.Builder
+	b.WriteString("| Format | Name | MIME types | Decode | Encode | Channels | Rates | Seek | Tags |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+
+	for _, row := range rows {
+		cells := []string{row.Format, "-", strings.Join(row.MIMETypes, ", "), "-", "-", "-", "-", "-", "-"}
+		if row.Reported {
+			c := row.Capabilities
+			cells[1] = c.Name
+			cells[3] = supportCell(c.Decode, c.BitDepths)
+			cells[4] = supportCell(c.Encode, c.EncodeBitDepths)
+			cells[5] = "any"
+			if c.MaxChannels > 0 {
+				cells[5] = "up to " + strconv.Itoa(c.MaxChannels)
+			}
+			cells[6] = "any"
+			if c.Rates != nil {
+				cells[6] = joinInts(c.Rates) + " Hz"
+			}
+			cells[7] = yesNo(c.Seekable)
+			cells[8] = yesNo(c.Metadata)
+		}
+		if cells[2] == "" {
+			cells[2] = "-"
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// supportCell describes whether a format is read or written, and at
+// which bit depths when it has PCM samples
+func supportCell(supported bool, depths []int) string {
+	switch {
+	case !supported:
+		return "no"
+	case len(depths) == 0:
+		return "yes"
+	}
+	return joinInts(depths) + "-bit"
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// errWriter fails every write
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, audiotest.ErrInjected }
+
+func TestRegistry_Matrix(t *testing.T) {
+	t.Parallel()
+
+	wavLike := &reportingDecoder{caps: Capabilities{
+		Name:            "WAV",
+		Decode:          true,
+		Encode:          true,
+		BitDepths:       []int{8, 16},
+		EncodeBitDepths: []int{16},
+		MaxChannels:     2,
+		Seekable:        true,
+	}}
+	registry := NewRegistry()
+	registry.Register("wav", wavLike)
+	registry.Register("wave", wavLike)
+	registry.Register("raw", &mockDecoder{name: "raw"})
+	registry.RegisterMIME("audio/x-wav", wavLike)
+	registry.RegisterMIME("audio/wav", wavLike)
+	registry.RegisterMIME("audio/mpeg", &mockDecoder{name: "mp3"})
+
+	rows := registry.Matrix()
+	formats := make([]string, len(rows))
+	for i, row := range rows {
+		formats[i] = row.Format
+	}
+	if want := []string{"raw", "wav", "wave"}; !slices.Equal(formats, want) {
+		t.Fatalf("Matrix() formats = %v, want %v", formats, want)
+	}
+
+	if rows[0].Reported || rows[0].MIMETypes != nil {
+		t.Errorf("raw row = %+v, want nothing reported", rows[0])
+	}
+	for _, row := range rows[1:] {
+		if !row.Reported || row.Capabilities.Name != "WAV" {
+			t.Errorf("%s row = %+v, want WAV", row.Format, row)
+		}
+		if want := []string{"audio/wav", "audio/x-wav"}; !slices.Equal(row.MIMETypes, want) {
+			t.Errorf("%s MIME types = %v, want %v", row.Format, row.MIMETypes, want)
+		}
+	}
+}
+
+func TestWriteMatrix(t *testing.T) {
+	t.Parallel()
+
+	rows := []FormatSupport{
+		{Format: "opus", Reported: true, Capabilities: Capabilities{Name: "Opus", Decode: true, MaxChannels: 8, Rates: []int{48000}}},
+		{Format: "raw"},
+		{
+			Format:    "wav",
+			MIMETypes: []string{"audio/wav", "audio/x-wav"},
+			Reported:  true,
+			Capabilities: Capabilities{
+				Name:            "WAV",
+				Decode:          true,
+				Encode:          true,
+				BitDepths:       []int{8, 16, 24, 32},
+				EncodeBitDepths: []int{16},
+				MaxChannels:     2,
+				Seekable:        true,
+				Metadata:        true,
+			},
+		},
+	}
+
+	var b strings.Builder
+	if err := WriteMatrix(&b, rows); err != nil {
+		t.Fatalf("WriteMatrix() error = %v", err)
+	}
+	want := "| Format | Name | MIME types | Decode | Encode | Channels | Rates | Seek | Tags |\n" +
+		"|---|---|---|---|---|---|---|---|---|\n" +
+		"| opus | Opus | - | yes | no | up to 8 | 48000 Hz | no | no |\n" +
+		"| raw | - | - | - | - | - | - | - | - |\n" +
+		"| wav | WAV | audio/wav, audio/x-wav | 8, 16, 24, 32-bit | 16-bit | up to 2 | any | yes | yes |\n"
+	if b.String() != want {
+		t.Errorf("WriteMatrix() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	if err := WriteMatrix(errWriter{}, rows); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("WriteMatrix(failing) error = %v, want ErrInjected", err)
+	}
+}
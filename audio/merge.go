@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// SenderReport ties the RTP timestamps of a stream to wall clock time, as
+// the RTCP sender reports of its sender do (RFC 3550 section 6.4.1).
+type SenderReport struct {
+	// RTP is the RTP timestamp sampled at NTP.
+	RTP uint32
+	// NTP is the wall clock time of the sender at RTP.
+	NTP time.Time
+}
+
+// Track is a source decoded from an RTP stream, for a TrackMerger.
+type Track struct {
+	Source Source
+	// Timestamp is the RTP timestamp of the first sample of Source.
+	Timestamp uint32
+	// ClockRate is the RTP clock rate of the stream, the sample rate of
+	// Source when zero. It differs for G.722, whose 16 kHz audio runs an
+	// 8 kHz RTP clock.
+	ClockRate int
+	// Report is a sender report of the stream.
+	Report SenderReport
+}
+
+// start returns the wall clock time of the first sample of the track
+func (t Track) start() time.Time {
+	rate := t.ClockRate
+	if rate <= 0 {
+		rate = t.Source.SampleRate()
+	}
+	// RTP timestamps wrap around: the difference to the report is signed
+	ticks := int64(int32(t.Timestamp - t.Report.RTP))
+	return t.Report.NTP.Add(time.Duration(ticks * int64(time.Second) / int64(rate)))
+}
+
+// TrackMerger records the parties of a conference as one multi-track
+// stream, each track on its own channels, in the order given, aligned by
+// the wall clock times their RTP timestamps map to rather than by when
+// their packets arrived. A party that joined later starts with silence up
+// to the sample it joined at.
+//
+// Only the starts are aligned: sources must fill the gaps of lost packets,
+// as a jitter buffer does, so that they stay in step with their RTP clock.
+// Reads are sample accurate: a track with nothing to give yet holds the
+// others back, and ReadSamples returns fewer frames, or none, until it
+// catches up. A track that ended is silent until all have ended.
+type TrackMerger struct {
+	tracks   []*mergeTrack
+	format   Format
+	channels int
+	bufSize  int
+}
+
+type mergeTrack struct {
+	src      Source
+	channels int
+	offset   int // first channel in the output
+	start    time.Duration
+	lead     int64     // frames of silence still to play
+	queue    []float32 // read ahead of the other tracks
+	ended    bool
+}
+
+// NewTrackMerger creates a TrackMerger of tracks, which must all have the
+// same sample rate. On error the sources are left open.
+func NewTrackMerger(tracks ...Track) (*TrackMerger, error) {
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%w: no tracks to merge", ErrInvalidState)
+	}
+
+	rate := tracks[0].Source.SampleRate()
+	first := tracks[0].start()
+	for i, t := range tracks {
+		if err := FormatOf(t.Source).Validate(); err != nil {
+			return nil, fmt.Errorf("track %d: %w", i, err)
+		}
+		if r := t.Source.SampleRate(); r != rate {
+			return nil, fmt.Errorf("track %d: %w: %d Hz vs %d Hz", i, ErrFormatMismatch, r, rate)
+		}
+		if t.Report.NTP.IsZero() {
+			return nil, fmt.Errorf("track %d: %w: no sender report", i, ErrInvalidState)
+		}
+		if s := t.start(); s.Before(first) {
+			first = s
+		}
+	}
+
+	m := &TrackMerger{}
+	for _, t := range tracks {
+		start := t.start().Sub(first)
+		ch := t.Source.Channels()
+		m.tracks = append(m.tracks, &mergeTrack{
+			src:      t.Source,
+			channels: ch,
+			offset:   m.channels,
+			start:    start,
+			lead:     int64(math.Round(start.Seconds() * float64(rate))),
+		})
+		m.channels += ch
+		m.bufSize = max(m.bufSize, t.Source.BufSize()/ch)
+	}
+	m.format = Format{Rate: rate, Channels: m.channels, Layout: DefaultLayout(m.channels), SampleKind: SampleFloat32}
+	m.bufSize *= m.channels
+	return m, nil
+}
+
+func (m *TrackMerger) SampleRate() int { return m.format.Rate }
+func (m *TrackMerger) Channels() int   { return m.channels }
+func (m *TrackMerger) BufSize() int    { return m.bufSize }
+func (m *TrackMerger) Format() Format  { return m.format }
+
+func (m *TrackMerger) Upstream() []Source {
+	srcs := make([]Source, len(m.tracks))
+	for i, t := range m.tracks {
+		srcs[i] = t.src
+	}
+	return srcs
+}
+
+// Offsets returns when each track starts in the merged stream.
+func (m *TrackMerger) Offsets() []time.Duration {
+	offsets := make([]time.Duration, len(m.tracks))
+	for i, t := range m.tracks {
+		offsets[i] = t.start
+	}
+	return offsets
+}
+
+// Close closes every source.
+func (m *TrackMerger) Close() error {
+	var errs []error
+	for _, t := range m.tracks {
+		errs = append(errs, t.src.Close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with as many frames as every track has to give,
+// and returns io.EOF once every track has ended and been played.
+func (m *TrackMerger) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%m.channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	frames := int64(len(dst) / m.channels)
+	n, left, active := frames, int64(0), false
+	for i, t := range m.tracks {
+		if err := t.fill(frames); err != nil {
+			return 0, fmt.Errorf("track %d: %w", i, err)
+		}
+		have := t.lead + int64(len(t.queue)/t.channels)
+		if !t.ended {
+			n = min(n, have)
+			active = true
+		}
+		left = max(left, have)
+	}
+	if !active {
+		// Every track ended: play what they still have
+		if left == 0 {
+			return 0, io.EOF
+		}
+		n = min(n, left)
+	}
+
+	for _, t := range m.tracks {
+		t.play(dst[:n*int64(m.channels)], m.channels)
+	}
+	return int(n) * m.channels, nil
+}
+
+// fill reads the source until the track has frames to give, it has nothing
+// more yet, or it ends
+func (t *mergeTrack) fill(frames int64) error {
+	for !t.ended {
+		need := int((frames - t.lead) * int64(t.channels))
+		if need <= len(t.queue) {
+			return nil
+		}
+
+		n := len(t.queue)
+		t.queue = slices.Grow(t.queue, need-n)[:need]
+		r, err := t.src.ReadSamples(t.queue[n:])
+		t.queue = t.queue[:n+r]
+		if errors.Is(err, io.EOF) {
+			t.ended = true
+		} else if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		if r == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// play writes the next frames of the track to its channels of dst
+func (t *mergeTrack) play(dst []float32, stride int) {
+	frames := len(dst) / stride
+	q := 0
+	for f := range frames {
+		out := dst[f*stride+t.offset : f*stride+t.offset+t.channels]
+		switch {
+		case t.lead > 0:
+			clear(out)
+			t.lead--
+		case q+t.channels <= len(t.queue):
+			q += copy(out, t.queue[q:])
+		default:
+			clear(out)
+		}
+	}
+	t.queue = append(t.queue[:0], t.queue[q:]...)
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// conference returns three tracks of constant 0.1, 0.2 and 0.3, of 1000
+// frames at 8 kHz, joining at 0, 100 ms and 25 ms, the last one across an
+// RTP timestamp wrap; the second one is stereo
+func conference(live bool) []Track {
+	t0 := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	srcs := []Source{newConstantSource(8000, 1, 1000, 0.1), newConstantSource(8000, 2, 1000, 0.2), newConstantSource(8000, 1, 1000, 0.3)}
+	if live {
+		for i, s := range srcs {
+			srcs[i] = &trickleSource{MockSource: s.(*audiotest.MockSource), n: 70 * s.Channels()}
+		}
+	}
+	return []Track{
+		{Source: srcs[0], Timestamp: 1000, Report: SenderReport{RTP: 1000, NTP: t0}},
+		{Source: srcs[1], Timestamp: ticksBefore(5000, 7200), Report: SenderReport{RTP: 5000, NTP: t0.Add(time.Second)}},
+		{Source: srcs[2], Timestamp: ticksBefore(10, 200), Report: SenderReport{RTP: 10, NTP: t0.Add(50 * time.Millisecond)}},
+	}
+}
+
+// ticksBefore returns the RTP timestamp ticks before rtp, wrapping around
+func ticksBefore(rtp, ticks uint32) uint32 { return rtp - ticks }
+
+// firstSound returns the first frame of each channel that is not silent
+func firstSound(samples []float32, channels int) []int {
+	first := make([]int, channels)
+	for c := range first {
+		first[c] = -1
+		for f := 0; f*channels+c < len(samples); f++ {
+			if samples[f*channels+c] != 0 {
+				first[c] = f
+				break
+			}
+		}
+	}
+	return first
+}
+
+func TestTrackMerger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		live bool
+	}{
+		{name: "files"},
+		{name: "live"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := NewTrackMerger(conference(tt.live)...)
+			if err != nil {
+				t.Fatalf("NewTrackMerger() error = %v", err)
+			}
+			if m.Channels() != 4 || m.SampleRate() != 8000 {
+				t.Fatalf("merged format = %v", m.Format())
+			}
+			if got, want := m.Offsets(), []time.Duration{0, 100 * time.Millisecond, 25 * time.Millisecond}; !slices.Equal(got, want) {
+				t.Errorf("Offsets() = %v, want %v", got, want)
+			}
+
+			var out []float32
+			buf := make([]float32, 4*128)
+			for range 1000 {
+				n, err := m.ReadSamples(buf)
+				out = append(out, buf[:n]...)
+				if err != nil {
+					break
+				}
+			}
+			if len(out) != 1800*4 {
+				t.Fatalf("merged %d frames, want 1800", len(out)/4)
+			}
+			if got, want := firstSound(out, 4), []int{0, 800, 800, 200}; !slices.Equal(got, want) {
+				t.Errorf("tracks start at frames %v, want %v", got, want)
+			}
+			for f := range 1800 {
+				frame := out[f*4 : f*4+4]
+				want := []float32{0, 0, 0, 0}
+				if f < 1000 {
+					want[0] = 0.1
+				}
+				if f >= 800 {
+					want[1], want[2] = 0.2, 0.2
+				}
+				if f >= 200 && f < 1200 {
+					want[3] = 0.3
+				}
+				if !slices.Equal(frame, want) {
+					t.Fatalf("frame %d = %v, want %v", f, frame, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrackMerger_ClockRate(t *testing.T) {
+	t.Parallel()
+
+	// G.722: 16 kHz audio with an 8 kHz RTP clock
+	t0 := time.Unix(1700000000, 0)
+	m, err := NewTrackMerger(
+		Track{Source: newConstantSource(16000, 1, 100, 0.5), Timestamp: 0, ClockRate: 8000, Report: SenderReport{RTP: 0, NTP: t0}},
+		Track{Source: newConstantSource(16000, 1, 100, 0.5), Timestamp: 800, ClockRate: 8000, Report: SenderReport{RTP: 0, NTP: t0}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Offsets()[1]; got != 100*time.Millisecond {
+		t.Fatalf("offset = %v, want 100ms", got)
+	}
+	if got := firstSound(readAll(t, m, 256), 2); got[1] != 1600 {
+		t.Fatalf("second track starts at frame %d, want 1600", got[1])
+	}
+}
+
+func TestTrackMerger_Errors(t *testing.T) {
+	t.Parallel()
+
+	report := SenderReport{NTP: time.Unix(1700000000, 0)}
+	tests := []struct {
+		name   string
+		tracks []Track
+		want   error
+	}{
+		{name: "no tracks", want: ErrInvalidState},
+		{
+			name:   "rate mismatch",
+			tracks: []Track{{Source: newSilentSource(8000, 1, 10), Report: report}, {Source: newSilentSource(16000, 1, 10), Report: report}},
+			want:   ErrFormatMismatch,
+		},
+		{
+			name:   "no sender report",
+			tracks: []Track{{Source: newSilentSource(8000, 1, 10), Report: report}, {Source: newSilentSource(8000, 1, 10)}},
+			want:   ErrInvalidState,
+		},
+		{
+			name:   "no channels",
+			tracks: []Track{{Source: newSilentSource(8000, 0, 10), Report: report}},
+			want:   ErrInvalidChannels,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := NewTrackMerger(tt.tracks...); !errors.Is(err, tt.want) {
+				t.Fatalf("NewTrackMerger() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	faulty := audiotest.NewFaultySource(newSilentSource(8000, 1, 1000), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	m, err := NewTrackMerger(Track{Source: newSilentSource(8000, 1, 1000), Report: report}, Track{Source: faulty, Report: report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+	if _, err := m.ReadSamples(make([]float32, 64)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := m.Close(); err != nil || !faulty.Closed() {
+		t.Errorf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
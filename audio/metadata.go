@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import "time"
+
+// Tags is the descriptive metadata of a stream as its container records
+// it: ID3 tags, Vorbis comments, ... Fields the container does not record
+// are empty, and fields it records more than once hold the values joined
+// with "/".
+type Tags struct {
+	Title  string
+	Artist string
+	Album  string
+	// Duration is the length the tags state, such as the ID3 TLEN frame,
+	// or 0 when they do not. Sources that also implement Lengther measure
+	// the stream itself, which is more reliable.
+	Duration time.Duration
+	// Comments holds every field of containers with free-form ones, such
+	// as Vorbis comments, by upper-case name, custom ones included; nil
+	// for other containers.
+	Comments map[string][]string
+}
+
+// Metadata is implemented by sources whose container carries descriptive
+// tags, such as the MP3 decoder. Check for it with a type assertion:
+//
+//	if m, ok := src.(audio.Metadata); ok {
+//		fmt.Println(m.Metadata().Title)
+//	}
+type Metadata interface {
+	// Metadata returns the tags read with the header of the stream.
+	Metadata() Tags
+}
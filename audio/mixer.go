@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Mixer sums sources sample by sample into one stream: the parties of a
+// conference bridge, music on hold under a prompt, both legs of a call for
+// recording. Each input has a gain, 1 unless set with SetGain.
+//
+// All sources must have the rate and channel count of the first one. The
+// mix ends when every source has ended; a source that has ended, or has
+// nothing to give yet, adds silence. The sum is clipped to [-1, 1].
+//
+// SetGain and Gain are safe to call from any goroutine while another one
+// reads.
+type Mixer struct {
+	srcs    []Source
+	format  Format
+	bufSize int
+	ended   []bool
+	buf     []float32
+
+	mu    *sync.Mutex
+	gains []float32
+}
+
+// NewMixer creates a Mixer of srcs. On error the sources are left open.
+func NewMixer(srcs ...Source) (*Mixer, error) {
+	if len(srcs) == 0 {
+		return nil, fmt.Errorf("%w: no sources to mix", ErrInvalidState)
+	}
+
+	format := FormatOf(srcs[0])
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("source 0: %w", err)
+	}
+	bufSize := 0
+	gains := make([]float32, len(srcs))
+	for i, src := range srcs {
+		if err := FormatOf(src).Compatible(format); err != nil {
+			return nil, fmt.Errorf("source %d: %w", i, err)
+		}
+		bufSize = max(bufSize, src.BufSize())
+		gains[i] = 1
+	}
+
+	return &Mixer{
+		srcs:    srcs,
+		format:  format,
+		bufSize: bufSize,
+		ended:   make([]bool, len(srcs)),
+		mu:      &sync.Mutex{},
+		gains:   gains,
+	}, nil
+}
+
+func (m *Mixer) SampleRate() int    { return m.format.Rate }
+func (m *Mixer) Channels() int      { return m.format.Channels }
+func (m *Mixer) BufSize() int       { return m.bufSize }
+func (m *Mixer) Format() Format     { return m.format }
+func (m *Mixer) Upstream() []Source { return slices.Clone(m.srcs) }
+
+// SetGain sets the linear gain of source i, as given to NewMixer: 0 mutes
+// it, 0.5 takes it down 6 dB. Indexes out of range are ignored.
+func (m *Mixer) SetGain(i int, gain float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i >= 0 && i < len(m.gains) {
+		m.gains[i] = gain
+	}
+}
+
+// Gain returns the gain of source i, or 0 when i is out of range.
+func (m *Mixer) Gain(i int) float32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i < 0 || i >= len(m.gains) {
+		return 0
+	}
+	return m.gains[i]
+}
+
+// Close closes every source.
+func (m *Mixer) Close() error {
+	var errs []error
+	for _, src := range m.srcs {
+		errs = append(errs, src.Close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with the mix of as many samples as the source
+// with the most to give returned, and returns io.EOF once every source
+// has ended.
+func (m *Mixer) ReadSamples(dst []float32) (int, error) {
+	ch := m.format.Channels
+	if len(dst)%ch != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	if cap(m.buf) < len(dst) {
+		m.buf = make([]float32, len(dst))
+	}
+	buf := m.buf[:len(dst)]
+
+	n := 0
+	for i, src := range m.srcs {
+		if m.ended[i] {
+			continue
+		}
+		r, err := m.fill(i, src, buf)
+		if err != nil {
+			return 0, fmt.Errorf("source %d: %w", i, err)
+		}
+
+		m.mu.Lock()
+		gain := m.gains[i]
+		m.mu.Unlock()
+
+		// Samples past n are still stale
+		clear(dst[n:max(n, r)])
+		for j, v := range buf[:r] {
+			dst[j] += v * gain
+		}
+		n = max(n, r)
+	}
+
+	for i := range dst[:n] {
+		dst[i] = min(max(dst[i], -1), 1)
+	}
+	if n == 0 && !slices.Contains(m.ended, false) {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// fill reads source i into buf until it is full, ends, or has nothing to
+// give, returning whole frames
+func (m *Mixer) fill(i int, src Source, buf []float32) (int, error) {
+	n := 0
+	for n < len(buf) {
+		r, err := src.ReadSamples(buf[n:])
+		n += r
+		if errors.Is(err, io.EOF) {
+			m.ended[i] = true
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+		if r == 0 {
+			break
+		}
+	}
+	return n - n%m.format.Channels, nil
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestMixer_ReadSamples(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		srcs  func() []Source
+		gains map[int]float32
+		chunk int
+		want  map[int]float32 // sample index to value
+		len   int
+	}{
+		{
+			name: "sum",
+			srcs: func() []Source {
+				return []Source{newConstantSource(8000, 1, 100, 0.25), newConstantSource(8000, 1, 100, 0.5)}
+			},
+			chunk: 32,
+			want:  map[int]float32{0: 0.75, 99: 0.75},
+			len:   100,
+		},
+		{
+			name: "shorter input",
+			srcs: func() []Source {
+				return []Source{newConstantSource(8000, 1, 50, 0.25), newConstantSource(8000, 1, 120, 0.5), newConstantSource(8000, 1, 80, -0.125)}
+			},
+			chunk: 64,
+			want:  map[int]float32{0: 0.625, 49: 0.625, 50: 0.375, 79: 0.375, 80: 0.5, 119: 0.5},
+			len:   120,
+		},
+		{
+			name: "gain",
+			srcs: func() []Source {
+				return []Source{newConstantSource(8000, 2, 100, 0.5), newConstantSource(8000, 2, 100, 0.5)}
+			},
+			gains: map[int]float32{0: 0.5, 1: 0},
+			chunk: 50,
+			want:  map[int]float32{0: 0.25, 199: 0.25},
+			len:   200,
+		},
+		{
+			name: "clipped",
+			srcs: func() []Source {
+				return []Source{newConstantSource(8000, 1, 10, 0.75), newConstantSource(8000, 1, 10, 0.75), newConstantSource(8000, 1, 10, -0.9)}
+			},
+			gains: map[int]float32{2: 0.5},
+			chunk: 10,
+			want:  map[int]float32{0: 1, 9: 1},
+			len:   10,
+		},
+		{
+			name: "short reads",
+			srcs: func() []Source {
+				return []Source{
+					audiotest.NewFaultySource(newConstantSource(8000, 1, 100, 0.25), audiotest.WithShortReads(7)),
+					newConstantSource(8000, 1, 100, 0.25),
+				}
+			},
+			chunk: 30,
+			want:  map[int]float32{0: 0.5, 50: 0.5, 99: 0.5},
+			len:   100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := NewMixer(tt.srcs()...)
+			if err != nil {
+				t.Fatalf("NewMixer() error = %v", err)
+			}
+			for i, g := range tt.gains {
+				m.SetGain(i, g)
+			}
+
+			got := readAll(t, m, tt.chunk)
+			if len(got) != tt.len {
+				t.Fatalf("read %d samples, want %d", len(got), tt.len)
+			}
+			for i, want := range tt.want {
+				if got[i] != want {
+					t.Errorf("sample %d = %v, want %v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestMixer_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewMixer(); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("NewMixer() error = %v, want ErrInvalidState", err)
+	}
+	if _, err := NewMixer(newSilentSource(8000, 1, 10), newSilentSource(16000, 1, 10)); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("NewMixer(8 kHz, 16 kHz) error = %v, want ErrFormatMismatch", err)
+	}
+
+	a := audiotest.NewFaultySource(newSilentSource(8000, 2, 10))
+	b := audiotest.NewFaultySource(newSilentSource(8000, 2, 10), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	m, err := NewMixer(a, b)
+	if err != nil {
+		t.Fatalf("NewMixer() error = %v", err)
+	}
+	if _, err := m.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+	if _, err := m.ReadSamples(make([]float32, 4)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if m.Gain(1) != 1 || m.Gain(2) != 0 {
+		t.Errorf("Gain() = %v, %v, want 1 and 0 out of range", m.Gain(1), m.Gain(2))
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !a.Closed() || !b.Closed() {
+		t.Error("Close() did not close every source")
+	}
+}
@@ -3,10 +3,10 @@
 package audio
 
 import (
-	"github.com/ik5/audpbx/internal/audiotest"
+	"github.com/ik5/audpbx/audio/audiotest"
 )
 
-// Convenience wrappers for test helpers from internal/audiotest
+// Convenience wrappers for test helpers from audio/audiotest
 // These maintain backward compatibility with existing tests in this package
 // The return type is *audiotest.MockSource which implements audio.Source interface
 
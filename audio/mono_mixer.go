@@ -7,18 +7,54 @@ import "fmt"
 type MonoMixer struct {
     src      Source
     tmp      []float32
+    weights  []float32 // per-channel downmix weights, nil means plain average
 }
 
+// NewMonoMixer folds src to mono. Channels are averaged, unless src reports
+// an explicit speaker Layout through Formatter, in which case the weights
+// from MonoDownmixWeights are used (LFE dropped, surrounds at -3 dB).
 func NewMonoMixer(src Source) *MonoMixer {
-    return &MonoMixer{
+    m := &MonoMixer{
         src: src,
         tmp: make([]float32, 4096),
     }
+
+    if f, ok := src.(Formatter); ok {
+        format := f.Format()
+        m.weights = MonoDownmixWeights(format.Layout, format.Channels)
+        if uniformWeights(m.weights) {
+            m.weights = nil
+        }
+    }
+
+    return m
+}
+
+// uniformWeights reports whether all weights are equal, meaning the plain
+// average path produces the same result.
+func uniformWeights(w []float32) bool {
+    for i := 1; i < len(w); i++ {
+        if w[i] != w[0] {
+            return false
+        }
+    }
+    return true
 }
 
 func (m *MonoMixer) SampleRate() int { return m.src.SampleRate() }
 func (m *MonoMixer) Channels() int   { return 1 }
 func (m *MonoMixer) BufSize() int    { return m.src.BufSize() }
+
+// Format returns the source format collapsed to a single mono channel.
+func (m *MonoMixer) Format() Format {
+	f := FormatOf(m.src)
+	f.Channels = 1
+	f.Layout = LayoutMono
+	return f
+}
+
+func (m *MonoMixer) Upstream() []Source { return []Source{m.src} }
+
 func (m *MonoMixer) Close() error    {
 	err := m.src.Close()
 	if err != nil {
@@ -65,6 +101,19 @@ func (m *MonoMixer) ReadSamples(dst []float32) (int, error) {
     // Optimize: cache division result
     invChannels := float32(1.0) / float32(channels)
 
+    if len(m.weights) == channels {
+        // Layout aware downmix
+        for f := range frames {
+            sum := float32(0)
+            baseIdx := f * channels
+            for c, w := range m.weights {
+                sum += m.tmp[baseIdx+c] * w
+            }
+            dst[f] = sum
+        }
+        return frames, err
+    }
+
     // Unrolled loop for common cases
     switch channels {
     case 2: // Stereo (most common)
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Adaptation is the nearest format an encoder can write to the one
+// requested of it, as proposed by Capabilities.Negotiate.
+type Adaptation struct {
+	// Requested is the format asked for.
+	Requested Format
+	// Format is the format proposed instead, Requested itself when the
+	// encoder writes it as it is.
+	Format Format
+}
+
+// Exact reports whether the encoder writes the requested format as it is.
+func (a Adaptation) Exact() bool {
+	return a.Format == a.Requested
+}
+
+// Changes describes what the adaptation changes, one line per change, such
+// as "downmix 5.1 to stereo", to show before approving it.
+func (a Adaptation) Changes() []string {
+	var changes []string
+	if a.Format.Channels != a.Requested.Channels {
+		changes = append(changes, fmt.Sprintf("downmix %s to %s", a.Requested.Layout, a.Format.Layout))
+	}
+	if a.Format.Rate != a.Requested.Rate {
+		changes = append(changes, fmt.Sprintf("resample %d Hz to %d Hz", a.Requested.Rate, a.Format.Rate))
+	}
+	if a.Format.SampleKind != a.Requested.SampleKind {
+		changes = append(changes, fmt.Sprintf("store %d-bit samples as %d-bit", a.Requested.SampleKind.BitDepth(), a.Format.SampleKind.BitDepth()))
+	}
+	return changes
+}
+
+// Apply converts src, a source of the requested format, to the proposed
+// one: it downmixes it with RemixTo and resamples it with Resample.
+// Sample sizes are left to the encoder, which quantizes as it writes.
+func (a Adaptation) Apply(src Source) (Source, error) {
+	if err := FormatOf(src).Compatible(a.Requested); err != nil {
+		return nil, err
+	}
+
+	if a.Format.Channels != a.Requested.Channels {
+		m, err := RemixTo(src, a.Format.Layout)
+		if err != nil {
+			return nil, err
+		}
+		src = m
+	}
+	if a.Format.Rate != a.Requested.Rate {
+		src = Resample(src, a.Format.Rate)
+	}
+	return src, nil
+}
+
+// Negotiate returns the format nearest to want that the encoder writes,
+// rather than failing as CheckEncode does:
+//
+//   - too many channels are downmixed to the largest standard layout the
+//     format holds, 5.1 for a format holding 7 channels;
+//   - a rate the format does not hold is changed to the nearest rate
+//     above it, or else the highest one;
+//   - a sample size it does not hold is changed to the nearest size above
+//     it, or else the largest one.
+//
+// Any change must be approved: approve is called with the adaptation and
+// Negotiate fails with ErrNotApproved, wrapping the error of CheckEncode,
+// when it returns false or is nil. The adaptation is returned all the
+// same, so the refusal can say what would have worked. Formats that are
+// not encoded at all, or cannot be downmixed for lack of a known layout,
+// fail with ErrUnsupported.
+func (c Capabilities) Negotiate(want Format, approve func(Adaptation) bool) (Adaptation, error) {
+	a := Adaptation{Requested: want, Format: want}
+	err := c.CheckEncode(want)
+	if err == nil {
+		return a, nil
+	}
+	if !c.Encode || !errors.Is(err, ErrUnsupported) {
+		return a, err
+	}
+
+	if c.MaxChannels > 0 && want.Channels > c.MaxChannels {
+		if a.Requested.Layout.Count() != want.Channels {
+			a.Requested.Layout = DefaultLayout(want.Channels)
+		}
+		a.Format.Channels, a.Format.Layout = downmixLayout(a.Requested.Layout, c.MaxChannels)
+		if a.Format.Layout == LayoutUnknown {
+			return a, fmt.Errorf("%w: %s holds at most %d channels and %d of unknown layout cannot be downmixed", ErrUnsupported, c.Name, c.MaxChannels, want.Channels)
+		}
+	}
+	if c.Rates != nil && !slices.Contains(c.Rates, want.Rate) {
+		a.Format.Rate = nearestAbove(c.Rates, want.Rate)
+	}
+	if bits := want.SampleKind.BitDepth(); bits > 0 && c.EncodeBitDepths != nil && !slices.Contains(c.EncodeBitDepths, bits) {
+		a.Format.SampleKind = SampleKindForBitDepth(nearestAbove(c.EncodeBitDepths, bits))
+	}
+
+	if approve == nil || !approve(a) {
+		return a, fmt.Errorf("%w: %w", ErrNotApproved, err)
+	}
+	return a, nil
+}
+
+// downmixLayout returns the largest standard layout of at most limit
+// channels that from can be remixed to
+func downmixLayout(from Layout, limit int) (int, Layout) {
+	if from == LayoutUnknown {
+		return limit, LayoutUnknown
+	}
+	for n := limit; n > 0; n-- {
+		if l := DefaultLayout(n); l != LayoutUnknown {
+			return n, l
+		}
+	}
+	return limit, LayoutUnknown
+}
+
+// nearestAbove returns the smallest of values not below v, or else the
+// largest of values
+func nearestAbove(values []int, v int) int {
+	best, largest := 0, 0
+	for _, x := range values {
+		if x >= v && (best == 0 || x < best) {
+			best = x
+		}
+		largest = max(largest, x)
+	}
+	if best == 0 {
+		return largest
+	}
+	return best
+}
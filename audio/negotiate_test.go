@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCapabilities_Negotiate(t *testing.T) {
+	t.Parallel()
+
+	opusLike := Capabilities{Name: "Opus", Encode: true, MaxChannels: 2, Rates: []int{8000, 16000, 48000}}
+	wavLike := Capabilities{Name: "WAV", Encode: true, EncodeBitDepths: []int{16}, MaxChannels: 7}
+
+	tests := []struct {
+		name     string
+		caps     Capabilities
+		want     Format
+		approve  bool
+		wantFmt  Format
+		changes  []string
+		wantErr  error
+		approved bool // approve was called
+	}{
+		{
+			name:    "exact",
+			caps:    opusLike,
+			want:    Format{Rate: 16000, Channels: 1, Layout: LayoutMono},
+			wantFmt: Format{Rate: 16000, Channels: 1, Layout: LayoutMono},
+		},
+		{
+			name:     "downmix and resample",
+			caps:     opusLike,
+			want:     Format{Rate: 44100, Channels: 6, Layout: Layout5_1},
+			approve:  true,
+			wantFmt:  Format{Rate: 48000, Channels: 2, Layout: LayoutStereo},
+			changes:  []string{"downmix 5.1 to stereo", "resample 44100 Hz to 48000 Hz"},
+			approved: true,
+		},
+		{
+			name:     "rate down",
+			caps:     Capabilities{Name: "G.722", Encode: true, Rates: []int{8000, 16000}},
+			want:     Format{Rate: 44100, Channels: 1},
+			approve:  true,
+			wantFmt:  Format{Rate: 16000, Channels: 1},
+			changes:  []string{"resample 44100 Hz to 16000 Hz"},
+			approved: true,
+		},
+		{
+			name:     "largest layout",
+			caps:     wavLike,
+			want:     Format{Rate: 8000, Channels: 8, SampleKind: SampleInt24},
+			approve:  true,
+			wantFmt:  Format{Rate: 8000, Channels: 6, Layout: Layout5_1, SampleKind: SampleInt16},
+			changes:  []string{"downmix 7.1 to 5.1", "store 24-bit samples as 16-bit"},
+			approved: true,
+		},
+		{
+			name:     "refused",
+			caps:     opusLike,
+			want:     Format{Rate: 44100, Channels: 1},
+			wantFmt:  Format{Rate: 48000, Channels: 1},
+			changes:  []string{"resample 44100 Hz to 48000 Hz"},
+			wantErr:  ErrNotApproved,
+			approved: true,
+		},
+		{
+			name:    "unknown layout",
+			caps:    opusLike,
+			want:    Format{Rate: 48000, Channels: 5},
+			wantErr: ErrUnsupported,
+		},
+		{
+			name:    "no encoder",
+			caps:    Capabilities{Name: "MP3"},
+			want:    Format{Rate: 48000, Channels: 1},
+			wantFmt: Format{Rate: 48000, Channels: 1},
+			wantErr: ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			called := false
+			a, err := tt.caps.Negotiate(tt.want, func(Adaptation) bool {
+				called = true
+				return tt.approve
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Negotiate() error = %v, want %v", err, tt.wantErr)
+			}
+			if called != tt.approved {
+				t.Errorf("approve called = %v, want %v", called, tt.approved)
+			}
+			if errors.Is(err, ErrUnsupported) && tt.wantFmt == (Format{}) {
+				return
+			}
+			if a.Format != tt.wantFmt {
+				t.Errorf("Format = %s, want %s", a.Format, tt.wantFmt)
+			}
+			if a.Exact() != (tt.changes == nil) {
+				t.Errorf("Exact() = %v with changes %v", a.Exact(), tt.changes)
+			}
+			if got := a.Changes(); !slices.Equal(got, tt.changes) {
+				t.Errorf("Changes() = %q, want %q", got, tt.changes)
+			}
+		})
+	}
+}
+
+func TestCapabilities_NegotiateNilApprove(t *testing.T) {
+	t.Parallel()
+
+	caps := Capabilities{Name: "Opus", Encode: true, MaxChannels: 2}
+	if _, err := caps.Negotiate(Format{Rate: 48000, Channels: 6}, nil); !errors.Is(err, ErrNotApproved) || !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Negotiate() error = %v, want ErrNotApproved wrapping ErrUnsupported", err)
+	}
+}
+
+func TestAdaptation_Apply(t *testing.T) {
+	t.Parallel()
+
+	caps := Capabilities{Name: "Opus", Encode: true, MaxChannels: 2, Rates: []int{16000}}
+	src := newConstantSource(8000, 6, 800, 0.25)
+	a, err := caps.Negotiate(FormatOf(src), func(Adaptation) bool { return true })
+	if err != nil {
+		t.Fatalf("Negotiate() error = %v", err)
+	}
+
+	out, err := a.Apply(src)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if out.SampleRate() != 16000 || out.Channels() != 2 {
+		t.Fatalf("Apply() source = %d Hz x %d, want 16000 Hz x 2", out.SampleRate(), out.Channels())
+	}
+	if samples := readAll(t, out, 256); len(samples) < 3000 {
+		t.Errorf("read %d samples, want about 3200", len(samples))
+	}
+
+	if _, err := a.Apply(newConstantSource(8000, 1, 10, 0)); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("Apply(mono) error = %v, want ErrFormatMismatch", err)
+	}
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ik5/audpbx/clock"
+)
+
+// Defaults of PacerOptions.
+const (
+	DefaultPacerFrame       = 20 * time.Millisecond
+	DefaultPacerMaxBuffered = 200 * time.Millisecond
+)
+
+// Sink receives audio. The WAV and G.711 writers implement it, as does
+// anything else with the same method.
+//
+// A sink that cannot take audio right now, such as a network stream whose
+// send buffer is full, returns an error matching ErrNotReady, having taken
+// none of the samples, instead of blocking until it can. Sinks must not
+// keep samples after WriteSamples returns.
+type Sink interface {
+	WriteSamples(samples []float32) error
+}
+
+// BackpressurePolicy tells a Pacer what to do with the audio a sink is not
+// ready for.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBuffer keeps the audio to write it once the sink is
+	// ready again, up to PacerOptions.MaxBuffered, dropping the oldest
+	// audio beyond. It suits recordings, which should lose nothing to a
+	// short stall.
+	BackpressureBuffer BackpressurePolicy = iota
+	// BackpressureDrop drops the audio, so the sink gets the live audio as
+	// soon as it is ready again. It suits calls, where late audio is
+	// worse than lost audio.
+	BackpressureDrop
+)
+
+// PacerOptions configures a Pacer. Zero values select the defaults.
+type PacerOptions struct {
+	// Clock paces the writes, clock.Real() by default.
+	Clock clock.Clock
+	// Frame is the duration of audio written at each tick, 20 ms by
+	// default.
+	Frame time.Duration
+	// Policy is what to do with audio the sink is not ready for.
+	Policy BackpressurePolicy
+	// MaxBuffered is how much audio BackpressureBuffer keeps, 200 ms by
+	// default.
+	MaxBuffered time.Duration
+}
+
+// PacerStats are what a Pacer counted, in sample frames.
+type PacerStats struct {
+	// Written is the audio the sink took.
+	Written int64
+	// Dropped is the audio lost to backpressure.
+	Dropped int64
+	// Buffered is the audio waiting for the sink.
+	Buffered int64
+	// Underruns is the audio the source was late for, written as silence.
+	Underruns int64
+	// NotReady is the number of writes the sink refused.
+	NotReady int64
+}
+
+// Pacer writes a source to a sink in real time, a frame at every tick of
+// its clock, as a stream to a network peer must be. When the sink signals
+// it is not ready, the Pacer buffers or drops the audio as its policy
+// says and goes on reading the source at the same pace, so a congested
+// sink never stalls the pipeline feeding it, as a blocking io.Writer
+// would.
+//
+// Audio the source is late for is written as silence so the sink keeps
+// receiving frames in time. Stats is safe to call from any goroutine
+// while another one runs the Pacer.
+type Pacer struct {
+	src      Source
+	sink     Sink
+	clk      clock.Clock
+	frame    time.Duration
+	channels int
+	policy   BackpressurePolicy
+
+	buf       []float32 // frame being read
+	queue     []float32 // frames waiting for the sink
+	frameLen  int       // samples
+	maxQueued int       // samples
+	ended     bool
+
+	mu    *sync.Mutex
+	stats PacerStats
+}
+
+// NewPacer creates a Pacer writing src to sink.
+func NewPacer(src Source, sink Sink, opts PacerOptions) (*Pacer, error) {
+	format := FormatOf(src)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+	if opts.Frame <= 0 {
+		opts.Frame = DefaultPacerFrame
+	}
+	if opts.MaxBuffered <= 0 {
+		opts.MaxBuffered = DefaultPacerMaxBuffered
+	}
+
+	frameLen := max(FrameLen(format.Rate, opts.Frame), 1) * format.Channels
+	return &Pacer{
+		src:       src,
+		sink:      sink,
+		clk:       opts.Clock,
+		frame:     opts.Frame,
+		channels:  format.Channels,
+		policy:    opts.Policy,
+		buf:       make([]float32, frameLen),
+		frameLen:  frameLen,
+		maxQueued: max(FrameLen(format.Rate, opts.MaxBuffered)*format.Channels, frameLen),
+		mu:        &sync.Mutex{},
+	}, nil
+}
+
+// Stats returns what was counted so far.
+func (p *Pacer) Stats() PacerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stats
+}
+
+// Run writes a frame at every tick until the source ends and all its audio
+// was written or dropped, returning nil, or until ctx is done, returning
+// its error. It does not close the source.
+func (p *Pacer) Run(ctx context.Context) error {
+	ticker := p.clk.NewTicker(p.frame)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w", ctx.Err())
+		case <-ticker.C():
+		}
+
+		if err := p.Step(); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Step does the work of one tick: it reads a frame from the source and
+// writes what the sink is ready for. It returns io.EOF once the source
+// ended and nothing is left to write. Run calls Step; call it directly to
+// pace the Pacer with another timer, such as that of an RTP session.
+func (p *Pacer) Step() error {
+	if !p.ended {
+		if err := p.read(); err != nil {
+			return err
+		}
+	}
+
+	written, refused := 0, false
+	for written < len(p.queue) {
+		n := min(p.frameLen, len(p.queue)-written)
+		err := p.sink.WriteSamples(p.queue[written : written+n])
+		if errors.Is(err, ErrNotReady) {
+			refused = true
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		written += n
+	}
+
+	dropped := 0
+	if refused {
+		switch p.policy {
+		case BackpressureDrop:
+			dropped = len(p.queue) - written
+		default:
+			dropped = max(len(p.queue)-written-p.maxQueued, 0)
+		}
+	}
+	p.queue = append(p.queue[:0], p.queue[written+dropped:]...)
+
+	p.mu.Lock()
+	p.stats.Written += int64(written / p.channels)
+	p.stats.Dropped += int64(dropped / p.channels)
+	p.stats.Buffered = int64(len(p.queue) / p.channels)
+	if refused {
+		p.stats.NotReady++
+	}
+	p.mu.Unlock()
+
+	if p.ended && len(p.queue) == 0 {
+		return io.EOF
+	}
+	return nil
+}
+
+// read reads a frame from the source into the queue, completing it with
+// silence when the source is late
+func (p *Pacer) read() error {
+	filled := 0
+	for filled < len(p.buf) {
+		n, err := p.src.ReadSamples(p.buf[filled:])
+		filled += n
+		if errors.Is(err, io.EOF) {
+			p.ended = true
+			filled -= filled % p.channels
+			p.queue = append(p.queue, p.buf[:filled]...)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	if late := len(p.buf) - filled; late > 0 {
+		clear(p.buf[filled:])
+		p.mu.Lock()
+		p.stats.Underruns += int64(late / p.channels)
+		p.mu.Unlock()
+	}
+	p.queue = append(p.queue, p.buf...)
+	return nil
+}
@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+	"github.com/ik5/audpbx/clock"
+)
+
+// congestedSink refuses the writes of the steps in busy
+type congestedSink struct {
+	step    int
+	busy    map[int]bool
+	err     error
+	written int
+}
+
+func (s *congestedSink) WriteSamples(samples []float32) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.busy[s.step] {
+		return ErrNotReady
+	}
+	s.written += len(samples)
+	return nil
+}
+
+// trickleSource returns n samples a read, then nothing, as a live source
+// that is late
+type trickleSource struct {
+	*audiotest.MockSource
+	n    int
+	late bool
+}
+
+func (s *trickleSource) ReadSamples(dst []float32) (int, error) {
+	if s.late = !s.late; !s.late {
+		return 0, nil
+	}
+	return s.MockSource.ReadSamples(dst[:min(len(dst), s.n)])
+}
+
+func TestPacer_Step(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		policy  BackpressurePolicy
+		busy    []int
+		want    PacerStats
+		written int // samples
+	}{
+		{name: "ready", want: PacerStats{Written: 1600}, written: 1600},
+		{
+			name:    "buffer",
+			busy:    []int{2, 3, 4},
+			want:    PacerStats{Written: 1440, Dropped: 160, NotReady: 3},
+			written: 1440,
+		},
+		{
+			name:    "drop",
+			policy:  BackpressureDrop,
+			busy:    []int{2, 3, 4},
+			want:    PacerStats{Written: 1120, Dropped: 480, NotReady: 3},
+			written: 1120,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink := &congestedSink{busy: make(map[int]bool)}
+			for _, s := range tt.busy {
+				sink.busy[s] = true
+			}
+			p, err := NewPacer(newConstantSource(8000, 1, 1600, 0.5), sink, PacerOptions{Policy: tt.policy, MaxBuffered: 40 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for sink.step = 1; sink.step < 20; sink.step++ {
+				if err = p.Step(); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("Step() error = %v, want io.EOF", err)
+			}
+			if got := p.Stats(); got != tt.want {
+				t.Errorf("Stats() = %+v, want %+v", got, tt.want)
+			}
+			if sink.written != tt.written {
+				t.Errorf("sink took %d samples, want %d", sink.written, tt.written)
+			}
+		})
+	}
+}
+
+func TestPacer_Buffered(t *testing.T) {
+	t.Parallel()
+
+	sink := &congestedSink{busy: map[int]bool{0: true}}
+	p, err := NewPacer(newConstantSource(8000, 2, 8000, 0.5), sink, PacerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 15 {
+		if err := p.Step(); err != nil {
+			t.Fatalf("Step() error = %v", err)
+		}
+	}
+	// 200 ms are kept, the oldest 100 ms dropped
+	if got := p.Stats(); got.Buffered != 1600 || got.Dropped != 800 || got.NotReady != 15 {
+		t.Fatalf("Stats() = %+v", got)
+	}
+
+	sink.step = 1
+	if err := p.Step(); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if got := p.Stats(); got.Buffered != 0 || got.Written != 1760 || sink.written != 3520 {
+		t.Fatalf("Stats() = %+v, sink took %d samples", got, sink.written)
+	}
+}
+
+func TestPacer_Underrun(t *testing.T) {
+	t.Parallel()
+
+	sink := &congestedSink{}
+	src := &trickleSource{MockSource: newConstantSource(8000, 1, 1000, 0.5), n: 100}
+	p, err := NewPacer(src, sink, PacerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Step(); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if got := p.Stats(); got.Written != 160 || got.Underruns != 60 {
+		t.Fatalf("Stats() = %+v", got)
+	}
+}
+
+func TestPacer_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewPacer(newConstantSource(0, 1, 10, 0), &congestedSink{}, PacerOptions{}); !errors.Is(err, ErrInvalidSampleRate) {
+		t.Errorf("NewPacer(0 Hz) error = %v, want ErrInvalidSampleRate", err)
+	}
+
+	p, _ := NewPacer(newConstantSource(8000, 1, 1000, 0.5), &congestedSink{err: io.ErrShortWrite}, PacerOptions{})
+	if err := p.Step(); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Step() error = %v, want the sink error", err)
+	}
+
+	src := audiotest.NewFaultySource(newConstantSource(8000, 1, 1000, 0.5), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	p, _ = NewPacer(src, &congestedSink{}, PacerOptions{})
+	if err := p.Step(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Step() error = %v, want the source error", err)
+	}
+}
+
+func TestPacer_Run(t *testing.T) {
+	t.Parallel()
+
+	fc := clock.NewFake(time.Unix(0, 0))
+	sink := &congestedSink{}
+	p, err := NewPacer(newConstantSource(8000, 1, 480, 0.5), sink, PacerOptions{Clock: fc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.Run(context.Background()) }()
+
+	for {
+		fc.Advance(DefaultPacerFrame)
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got := p.Stats(); got.Written != 480 {
+				t.Fatalf("Stats() = %+v", got)
+			}
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestPacer_RunCanceled(t *testing.T) {
+	t.Parallel()
+
+	fc := clock.NewFake(time.Unix(0, 0))
+	p, err := NewPacer(newConstantSource(8000, 1, 8000, 0.5), &congestedSink{}, PacerOptions{Clock: fc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+}
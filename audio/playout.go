@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Playout is a never ending Source that plays clips at scheduled times and
+// silence in between, for feeding announcements into a bridge or stream
+// that runs continuously.
+//
+// Times are positions in the output stream, which advances with every
+// sample read: a Playout read in real time by a bridge keeps in step with
+// the wall clock. To play a clip at a wall clock time t:
+//
+//	p.Schedule(p.Elapsed()+time.Until(t), clip)
+//
+// Clips play one at a time. One that is due while another is still
+// playing starts right after it. Clips are closed once played.
+//
+// Schedule and Queue are safe to call from any goroutine while another
+// one reads.
+type Playout struct {
+	format Format
+
+	mu      *sync.Mutex
+	pos     int64 // frames produced so far
+	queue   []scheduledClip
+	current Source
+	closed  bool
+}
+
+type scheduledClip struct {
+	start int64 // frame
+	clip  Source
+}
+
+// NewPlayout creates a Playout producing audio in format. Clips must have
+// its rate and channel count.
+func NewPlayout(format Format) (*Playout, error) {
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return &Playout{format: format, mu: &sync.Mutex{}}, nil
+}
+
+func (p *Playout) SampleRate() int { return p.format.Rate }
+func (p *Playout) Channels() int   { return p.format.Channels }
+func (p *Playout) BufSize() int    { return 4096 }
+func (p *Playout) Format() Format  { return p.format }
+
+// Upstream returns the clip playing, if any, then those scheduled.
+func (p *Playout) Upstream() []Source {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var clips []Source
+	if p.current != nil {
+		clips = append(clips, p.current)
+	}
+	for _, c := range p.queue {
+		clips = append(clips, c.clip)
+	}
+	return clips
+}
+
+// Elapsed returns the stream time played so far.
+func (p *Playout) Elapsed() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return FramesDuration(p.pos, p.format.Rate)
+}
+
+// Pending returns the number of clips playing or waiting to play.
+func (p *Playout) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.queue)
+	if p.current != nil {
+		n++
+	}
+	return n
+}
+
+// Schedule plays clip at stream time at, or as soon as possible when at
+// has passed. Clips scheduled for the same time play in the order they
+// were scheduled.
+func (p *Playout) Schedule(at time.Duration, clip Source) error {
+	if err := FormatOf(clip).Compatible(p.format); err != nil {
+		return fmt.Errorf("clip: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return fmt.Errorf("%w: playout is closed", ErrInvalidState)
+	}
+
+	start := max(int64(FrameLen(p.format.Rate, at)), p.pos)
+	i, _ := slices.BinarySearchFunc(p.queue, start, func(c scheduledClip, start int64) int {
+		if c.start <= start {
+			return -1
+		}
+		return 1
+	})
+	p.queue = slices.Insert(p.queue, i, scheduledClip{start: start, clip: clip})
+	return nil
+}
+
+// Queue plays clip as soon as the clips due before it have played.
+func (p *Playout) Queue(clip Source) error {
+	return p.Schedule(0, clip)
+}
+
+// ReadSamples fills dst with the playing clip, or silence when none is
+// due; it only returns io.EOF after Close. A clip that fails is closed and
+// dropped, and its error is returned along with the samples read before
+// it; reading on continues with the next clip.
+func (p *Playout) ReadSamples(dst []float32) (int, error) {
+	ch := p.format.Channels
+	if len(dst)%ch != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, io.EOF
+	}
+
+	written := 0
+	for written < len(dst) {
+		if p.current == nil && len(p.queue) > 0 && p.queue[0].start <= p.pos {
+			p.current = p.queue[0].clip
+			p.queue = p.queue[1:]
+		}
+
+		if p.current == nil {
+			// Silence up to the next clip or the end of dst
+			n := len(dst) - written
+			if len(p.queue) > 0 {
+				n = min(n, int(p.queue[0].start-p.pos)*ch)
+			}
+			clear(dst[written : written+n])
+			written += n
+			p.pos += int64(n / ch)
+			continue
+		}
+
+		n, err := p.current.ReadSamples(dst[written:])
+		n -= n % ch
+		written += n
+		p.pos += int64(n / ch)
+
+		if err != nil {
+			cerr := p.current.Close()
+			p.current = nil
+			if !errors.Is(err, io.EOF) {
+				return written, fmt.Errorf("playout clip: %w", err)
+			}
+			if cerr != nil {
+				return written, fmt.Errorf("closing playout clip: %w", cerr)
+			}
+			continue
+		}
+		if n == 0 {
+			// The clip has nothing yet: fill with silence, it resumes
+			// on the next read
+			clear(dst[written:])
+			p.pos += int64((len(dst) - written) / ch)
+			written = len(dst)
+		}
+	}
+
+	return written, nil
+}
+
+// Close closes the playing clip and every pending one. Reads then return
+// io.EOF.
+func (p *Playout) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	var errs []error
+	if p.current != nil {
+		errs = append(errs, p.current.Close())
+		p.current = nil
+	}
+	for _, c := range p.queue {
+		errs = append(errs, c.clip.Close())
+	}
+	p.queue = nil
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
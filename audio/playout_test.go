@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// playoutClip is a 1 kHz mono clip of frames samples at value
+func playoutClip(frames int, value float32, opts ...audiotest.FaultOption) *audiotest.FaultySource {
+	return audiotest.NewFaultySource(newConstantSource(1000, 1, frames, value), opts...)
+}
+
+// readPlayout reads frames samples from p in reads of chunk samples
+func readPlayout(t *testing.T, p *Playout, frames, chunk int) []float32 {
+	t.Helper()
+
+	out := make([]float32, 0, frames)
+	buf := make([]float32, chunk)
+	for len(out) < frames {
+		n, err := p.ReadSamples(buf[:min(chunk, frames-len(out))])
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+		out = append(out, buf[:n]...)
+	}
+	return out
+}
+
+func TestPlayout(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPlayout(Format{Rate: 1000, Channels: 1})
+	if err != nil {
+		t.Fatalf("NewPlayout() error = %v", err)
+	}
+
+	a := playoutClip(100, 0.5)
+	b := playoutClip(50, 0.25)
+	c := playoutClip(10, 0.75, audiotest.WithShortReads(3))
+	for _, s := range []struct {
+		at   time.Duration
+		clip Source
+	}{
+		{200 * time.Millisecond, a},
+		// Overlaps a, so it follows it
+		{250 * time.Millisecond, b},
+	} {
+		if err := p.Schedule(s.at, s.clip); err != nil {
+			t.Fatalf("Schedule() error = %v", err)
+		}
+	}
+	if err := p.Queue(c); err != nil {
+		t.Fatalf("Queue() error = %v", err)
+	}
+	if got := p.Pending(); got != 3 {
+		t.Errorf("Pending() = %d, want 3", got)
+	}
+
+	out := readPlayout(t, p, 500, 64)
+
+	segments := []struct {
+		from, to int
+		want     float32
+	}{
+		{0, 10, 0.75},
+		{10, 200, 0},
+		{200, 300, 0.5},
+		{300, 350, 0.25},
+		{350, 500, 0},
+	}
+	for _, seg := range segments {
+		for i := seg.from; i < seg.to; i++ {
+			if out[i] != seg.want {
+				t.Fatalf("sample %d = %v, want %v", i, out[i], seg.want)
+			}
+		}
+	}
+
+	for name, clip := range map[string]*audiotest.FaultySource{"a": a, "b": b, "c": c} {
+		if !clip.Closed() {
+			t.Errorf("clip %s was not closed after playing", name)
+		}
+	}
+	if got := p.Pending(); got != 0 {
+		t.Errorf("Pending() = %d after playing, want 0", got)
+	}
+	if got := p.Elapsed(); got != 500*time.Millisecond {
+		t.Errorf("Elapsed() = %v, want 500ms", got)
+	}
+
+	// A time that has passed plays at once
+	if err := p.Schedule(100*time.Millisecond, playoutClip(5, 1)); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	if out := readPlayout(t, p, 6, 6); out[4] != 1 || out[5] != 0 {
+		t.Errorf("late clip = %v, want 5 samples of 1 then silence", out)
+	}
+}
+
+func TestPlayout_ClipError(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPlayout(Format{Rate: 1000, Channels: 1})
+	if err != nil {
+		t.Fatalf("NewPlayout() error = %v", err)
+	}
+
+	bad := playoutClip(100, 0.5, audiotest.WithErrorOnCall(2, audiotest.ErrInjected), audiotest.WithShortReads(10))
+	if err := p.Queue(bad); err != nil {
+		t.Fatalf("Queue() error = %v", err)
+	}
+	if err := p.Queue(playoutClip(10, 0.25)); err != nil {
+		t.Fatalf("Queue() error = %v", err)
+	}
+
+	buf := make([]float32, 50)
+	n, err := p.ReadSamples(buf)
+	if !errors.Is(err, audiotest.ErrInjected) || n != 10 {
+		t.Fatalf("ReadSamples() = %d, %v, want 10, ErrInjected", n, err)
+	}
+	if !bad.Closed() {
+		t.Error("failed clip was not closed")
+	}
+
+	// Playout goes on with the next clip
+	if out := readPlayout(t, p, 20, 20); out[0] != 0.25 || out[9] != 0.25 || out[10] != 0 {
+		t.Errorf("after the failure = %v, want the next clip then silence", out)
+	}
+}
+
+func TestPlayout_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewPlayout(Format{Rate: 0, Channels: 1}); !errors.Is(err, ErrInvalidSampleRate) {
+		t.Errorf("NewPlayout(0 Hz) error = %v, want ErrInvalidSampleRate", err)
+	}
+
+	p, err := NewPlayout(Format{Rate: 1000, Channels: 2})
+	if err != nil {
+		t.Fatalf("NewPlayout() error = %v", err)
+	}
+
+	if err := p.Queue(playoutClip(10, 1)); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("Queue(mono clip) error = %v, want ErrFormatMismatch", err)
+	}
+	if _, err := p.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(3) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	pending := audiotest.NewFaultySource(newConstantSource(1000, 2, 20, 1))
+	if err := p.Schedule(time.Second, pending); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !pending.Closed() {
+		t.Error("Close() did not close the pending clip")
+	}
+	if n, err := p.ReadSamples(make([]float32, 4)); n != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("ReadSamples() after Close = %d, %v, want 0, EOF", n, err)
+	}
+	if err := p.Queue(audiotest.NewFaultySource(newConstantSource(1000, 2, 20, 1))); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Queue() after Close error = %v, want ErrInvalidState", err)
+	}
+}
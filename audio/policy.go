@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy decides which formats a Registry may decode, for deployments
+// where some codecs must not be used, such as patent encumbered ones or
+// ones under a license the product cannot take. A Registry with a policy
+// keeps the refused decoders registered but out of reach: Get and
+// GetByMIME do not return them, Formats, MIMETypes and Matrix do not list
+// them, and Lookup, DetectAndDecode and DecodeHeader fail with an error
+// naming the policy.
+type Policy interface {
+	// Name names the policy in the errors of the formats it refuses, such
+	// as "license-safe".
+	Name() string
+	// Allows reports whether d, registered under key, may be used. The
+	// key is a format key, or a MIME type for decoders registered with
+	// RegisterMIME.
+	Allows(key string, d Decoder) bool
+}
+
+// DenyFormats returns a Policy named name refusing the given formats. A
+// format is matched without case against the key or MIME type a decoder
+// is registered under, and against the Name of its Capabilities, so that
+// refusing "MP3" refuses it under "mp3" and "audio/mpeg" alike.
+func DenyFormats(name string, formats ...string) Policy {
+	p := denyList{name: name, formats: make(map[string]bool, len(formats))}
+	for _, f := range formats {
+		p.formats[strings.ToLower(f)] = true
+	}
+	return p
+}
+
+// denyList is the policy of DenyFormats
+type denyList struct {
+	name    string
+	formats map[string]bool
+}
+
+func (p denyList) Name() string { return p.name }
+
+func (p denyList) Allows(key string, d Decoder) bool {
+	if p.formats[strings.ToLower(key)] {
+		return false
+	}
+	c, ok := CapabilitiesOf(d)
+	return !ok || !p.formats[strings.ToLower(c.Name)]
+}
+
+// SetPolicy restricts the registry to the formats p allows, or lifts the
+// restriction when p is nil. It applies to decoders registered before and
+// after.
+func (r *Registry) SetPolicy(p Policy) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.policy = p
+}
+
+// Policy returns the policy of the registry, nil when there is none.
+func (r *Registry) Policy() Policy {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.policy
+}
+
+// Lookup returns the decoder registered for format, like Get, but tells
+// why there is none: ErrUnknownFormat when nothing is registered, and
+// ErrRefusedByPolicy, naming the policy, when the policy refuses it.
+func (r *Registry) Lookup(format string) (Decoder, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	d, ok := r.codecs[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: no decoder registered for %s", ErrUnknownFormat, format)
+	}
+	if err := r.check(format, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// allowed reports whether the policy allows d under key. Call it with
+// the lock held.
+func (r *Registry) allowed(key string, d Decoder) bool {
+	return r.policy == nil || r.policy.Allows(key, d)
+}
+
+// check returns the error of the policy refusing d under key. Call it
+// with the lock held.
+func (r *Registry) check(key string, d Decoder) error {
+	if r.allowed(key, d) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s refused by the %q policy", ErrRefusedByPolicy, key, r.policy.Name())
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_Policy(t *testing.T) {
+	t.Parallel()
+
+	mp3 := &reportingDecoder{caps: Capabilities{Name: "MP3", Decode: true}}
+	wav := &reportingDecoder{caps: Capabilities{Name: "WAV", Decode: true}}
+	raw := &mockDecoder{name: "raw"}
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		formats []string
+		mimes   []string
+	}{
+		{name: "none", formats: []string{"mp3", "raw", "wav"}, mimes: []string{"audio/mpeg", "audio/wav"}},
+		{name: "by capabilities name", policy: DenyFormats("no-mp3", "mp3"), formats: []string{"raw", "wav"}, mimes: []string{"audio/wav"}},
+		{name: "by key", policy: DenyFormats("no-raw", "RAW"), formats: []string{"mp3", "wav"}, mimes: []string{"audio/mpeg", "audio/wav"}},
+		{name: "by MIME type", policy: DenyFormats("no-wav-mime", "audio/wav"), formats: []string{"mp3", "raw", "wav"}, mimes: []string{"audio/mpeg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := NewRegistry()
+			reg.SetPolicy(tt.policy)
+			// Registered after the policy is set, and still refused
+			reg.Register("mp3", mp3)
+			reg.Register("wav", wav)
+			reg.Register("raw", raw)
+			reg.RegisterMIME("audio/mpeg", mp3)
+			reg.RegisterMIME("audio/wav", wav)
+
+			if got := reg.Formats(); !slices.Equal(got, tt.formats) {
+				t.Errorf("Formats() = %v, want %v", got, tt.formats)
+			}
+			if got := reg.MIMETypes(); !slices.Equal(got, tt.mimes) {
+				t.Errorf("MIMETypes() = %v, want %v", got, tt.mimes)
+			}
+			var matrix, mimes []string
+			for _, row := range reg.Matrix() {
+				matrix = append(matrix, row.Format)
+				mimes = append(mimes, row.MIMETypes...)
+			}
+			slices.Sort(mimes)
+			if !slices.Equal(matrix, tt.formats) || !slices.Equal(mimes, tt.mimes) {
+				t.Errorf("Matrix() lists %v and %v, want %v and %v", matrix, mimes, tt.formats, tt.mimes)
+			}
+
+			for _, f := range []string{"mp3", "wav", "raw"} {
+				allowed := slices.Contains(tt.formats, f)
+				if _, ok := reg.Get(f); ok != allowed {
+					t.Errorf("Get(%q) ok = %v, want %v", f, ok, allowed)
+				}
+				_, err := reg.Lookup(f)
+				switch {
+				case allowed && err != nil:
+					t.Errorf("Lookup(%q) error = %v", f, err)
+				case !allowed && !errors.Is(err, ErrRefusedByPolicy):
+					t.Errorf("Lookup(%q) error = %v, want ErrRefusedByPolicy", f, err)
+				case !allowed && !strings.Contains(err.Error(), tt.policy.Name()):
+					t.Errorf("Lookup(%q) error = %q, does not name the policy", f, err)
+				}
+			}
+			for _, m := range []string{"audio/mpeg", "audio/wav"} {
+				if _, ok := reg.GetByMIME(m); ok != slices.Contains(tt.mimes, m) {
+					t.Errorf("GetByMIME(%q) ok = %v", m, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistry_PolicyDetect(t *testing.T) {
+	t.Parallel()
+
+	reg := NewRegistry()
+	reg.Register("mp3", &reportingDecoder{caps: Capabilities{Name: "MP3", Decode: true}})
+	reg.SetPolicy(DenyFormats("license-safe", "mp3"))
+
+	id3 := []byte("ID3\x04\x00\x00\x00\x00\x00\x00")
+	_, err := reg.DetectAndDecode(bytes.NewReader(id3))
+	if !errors.Is(err, ErrRefusedByPolicy) || !strings.Contains(err.Error(), `"license-safe"`) {
+		t.Errorf("DetectAndDecode(mp3) error = %v, want ErrRefusedByPolicy naming the policy", err)
+	}
+	if _, err := reg.DecodeHeader(bytes.NewReader(id3)); !errors.Is(err, ErrRefusedByPolicy) {
+		t.Errorf("DecodeHeader(mp3) error = %v, want ErrRefusedByPolicy", err)
+	}
+	if _, err := reg.Lookup("ogg"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Lookup(unregistered) error = %v, want ErrUnknownFormat", err)
+	}
+
+	reg.SetPolicy(nil)
+	if _, err := reg.DetectAndDecode(bytes.NewReader(id3)); err != nil {
+		t.Errorf("DetectAndDecode() without a policy error = %v", err)
+	}
+	if reg.Policy() != nil {
+		t.Errorf("Policy() = %v after SetPolicy(nil)", reg.Policy())
+	}
+}
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Interruption tells a PriorityQueue what to do with a clip that a higher
+// priority one interrupts.
+type Interruption int
+
+const (
+	// Resume pauses the clip and continues it where it stopped once the
+	// higher priority clips have played, as hold music does.
+	Resume Interruption = iota
+	// Discard stops and closes the clip, as a prompt that no longer makes
+	// sense after an emergency page.
+	Discard
+)
+
+// PriorityQueue is a never ending Source that plays one clip at a time,
+// always the one with the highest priority, and silence when it has none.
+// A clip pushed with a higher priority than the playing one interrupts it
+// at the next read; the interrupted clip is paused and resumed afterwards,
+// or dropped, as its Interruption says. Clips of equal priority play in
+// the order they were pushed. Clips are closed once played or dropped.
+//
+// Push is safe to call from any goroutine while another one reads.
+type PriorityQueue struct {
+	format Format
+
+	mu     *sync.Mutex
+	clips  []*queuedClip // by priority, highest first
+	closed bool
+}
+
+type queuedClip struct {
+	priority int
+	clip     Source
+	onIntr   Interruption
+	started  bool
+}
+
+// NewPriorityQueue creates a PriorityQueue producing audio in format. Clips
+// must have its rate and channel count.
+func NewPriorityQueue(format Format) (*PriorityQueue, error) {
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return &PriorityQueue{format: format, mu: &sync.Mutex{}}, nil
+}
+
+func (q *PriorityQueue) SampleRate() int { return q.format.Rate }
+func (q *PriorityQueue) Channels() int   { return q.format.Channels }
+func (q *PriorityQueue) BufSize() int    { return 4096 }
+func (q *PriorityQueue) Format() Format  { return q.format }
+
+// Upstream returns the clips queued, by priority.
+func (q *PriorityQueue) Upstream() []Source {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	clips := make([]Source, len(q.clips))
+	for i, c := range q.clips {
+		clips[i] = c.clip
+	}
+	return clips
+}
+
+// Pending returns the number of clips playing, paused or waiting to play.
+func (q *PriorityQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.clips)
+}
+
+// Playing returns the priority of the clip that plays next, and false when
+// there is none.
+func (q *PriorityQueue) Playing() (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.clips) == 0 {
+		return 0, false
+	}
+	return q.clips[0].priority, true
+}
+
+// Push adds clip with priority. If it is higher than the priority of the
+// playing clip, that one is interrupted and treated as onInterrupt says;
+// otherwise clip waits for the clips with the same or a higher priority.
+func (q *PriorityQueue) Push(priority int, clip Source, onInterrupt Interruption) error {
+	if err := FormatOf(clip).Compatible(q.format); err != nil {
+		return fmt.Errorf("clip: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return fmt.Errorf("%w: priority queue is closed", ErrInvalidState)
+	}
+
+	var err error
+	if len(q.clips) > 0 {
+		cur := q.clips[0]
+		if priority > cur.priority && cur.started && cur.onIntr == Discard {
+			q.clips = q.clips[1:]
+			if cerr := cur.clip.Close(); cerr != nil {
+				err = fmt.Errorf("closing interrupted clip: %w", cerr)
+			}
+		}
+	}
+
+	i, _ := slices.BinarySearchFunc(q.clips, priority, func(c *queuedClip, priority int) int {
+		if c.priority >= priority {
+			return -1
+		}
+		return 1
+	})
+	q.clips = slices.Insert(q.clips, i, &queuedClip{priority: priority, clip: clip, onIntr: onInterrupt})
+	return err
+}
+
+// ReadSamples fills dst with the highest priority clip, or silence when
+// there is none; it only returns io.EOF after Close. A clip that fails is
+// closed and dropped, and its error is returned along with the samples
+// read before it; reading on continues with the next clip.
+func (q *PriorityQueue) ReadSamples(dst []float32) (int, error) {
+	ch := q.format.Channels
+	if len(dst)%ch != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return 0, io.EOF
+	}
+
+	written := 0
+	for written < len(dst) {
+		if len(q.clips) == 0 {
+			clear(dst[written:])
+			written = len(dst)
+			break
+		}
+
+		cur := q.clips[0]
+		cur.started = true
+		n, err := cur.clip.ReadSamples(dst[written:])
+		n -= n % ch
+		written += n
+
+		if err != nil {
+			q.clips = q.clips[1:]
+			cerr := cur.clip.Close()
+			if !errors.Is(err, io.EOF) {
+				return written, fmt.Errorf("priority queue clip: %w", err)
+			}
+			if cerr != nil {
+				return written, fmt.Errorf("closing priority queue clip: %w", cerr)
+			}
+			continue
+		}
+		if n == 0 {
+			// The clip has nothing yet: fill with silence, it resumes
+			// on the next read
+			clear(dst[written:])
+			written = len(dst)
+		}
+	}
+
+	return written, nil
+}
+
+// Close closes every clip, playing, paused or waiting. Reads then return
+// io.EOF.
+func (q *PriorityQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+
+	errs := make([]error, 0, len(q.clips))
+	for _, c := range q.clips {
+		errs = append(errs, c.clip.Close())
+	}
+	q.clips = nil
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
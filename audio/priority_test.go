@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// readQueue reads frames samples from q in reads of chunk samples
+func readQueue(t *testing.T, q *PriorityQueue, frames, chunk int) []float32 {
+	t.Helper()
+
+	out := make([]float32, 0, frames)
+	buf := make([]float32, chunk)
+	for len(out) < frames {
+		n, err := q.ReadSamples(buf[:min(chunk, frames-len(out))])
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+		out = append(out, buf[:n]...)
+	}
+	return out
+}
+
+// checkSegments fails t unless out holds want in each segment
+func checkSegments(t *testing.T, out []float32, segments []struct {
+	from, to int
+	want     float32
+}) {
+	t.Helper()
+
+	for _, seg := range segments {
+		for i := seg.from; i < seg.to; i++ {
+			if out[i] != seg.want {
+				t.Fatalf("sample %d = %v, want %v", i, out[i], seg.want)
+			}
+		}
+	}
+}
+
+func TestPriorityQueue_Interrupt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		onIntr   Interruption
+		segments []struct {
+			from, to int
+			want     float32
+		}
+		musicClosed bool // right after the interruption
+	}{
+		{
+			name:   "resume",
+			onIntr: Resume,
+			segments: []struct {
+				from, to int
+				want     float32
+			}{
+				{0, 40, 0.1},
+				{40, 60, 0.9},
+				{60, 70, 0.5},
+				// The rest of the music
+				{70, 130, 0.1},
+				{130, 150, 0},
+			},
+		},
+		{
+			name:   "discard",
+			onIntr: Discard,
+			segments: []struct {
+				from, to int
+				want     float32
+			}{
+				{0, 40, 0.1},
+				{40, 60, 0.9},
+				{60, 70, 0.5},
+				{70, 150, 0},
+			},
+			musicClosed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			q, err := NewPriorityQueue(Format{Rate: 1000, Channels: 1})
+			if err != nil {
+				t.Fatalf("NewPriorityQueue() error = %v", err)
+			}
+
+			music := playoutClip(100, 0.1)
+			if err := q.Push(0, music, tt.onIntr); err != nil {
+				t.Fatalf("Push(music) error = %v", err)
+			}
+			out := readQueue(t, q, 40, 20)
+
+			page := playoutClip(20, 0.9)
+			prompt := playoutClip(10, 0.5)
+			// The prompt outranks the music but waits for the page
+			if err := q.Push(5, prompt, Discard); err != nil {
+				t.Fatalf("Push(prompt) error = %v", err)
+			}
+			if err := q.Push(10, page, Discard); err != nil {
+				t.Fatalf("Push(page) error = %v", err)
+			}
+			if p, ok := q.Playing(); !ok || p != 10 {
+				t.Errorf("Playing() = %d, %v, want 10, true", p, ok)
+			}
+			if music.Closed() != tt.musicClosed {
+				t.Errorf("interrupted music closed = %v, want %v", music.Closed(), tt.musicClosed)
+			}
+
+			out = append(out, readQueue(t, q, 110, 16)...)
+			checkSegments(t, out, tt.segments)
+
+			if !page.Closed() || !prompt.Closed() || !music.Closed() {
+				t.Error("played clips were not closed")
+			}
+			if got := q.Pending(); got != 0 {
+				t.Errorf("Pending() = %d after playing, want 0", got)
+			}
+			if _, ok := q.Playing(); ok {
+				t.Error("Playing() reports a clip after playing")
+			}
+		})
+	}
+}
+
+func TestPriorityQueue_EqualPriority(t *testing.T) {
+	t.Parallel()
+
+	q, err := NewPriorityQueue(Format{Rate: 1000, Channels: 1})
+	if err != nil {
+		t.Fatalf("NewPriorityQueue() error = %v", err)
+	}
+
+	a := playoutClip(10, 0.25, audiotest.WithShortReads(3))
+	b := playoutClip(10, 0.5)
+	for _, c := range []Source{a, b} {
+		if err := q.Push(1, c, Discard); err != nil {
+			t.Fatalf("Push() error = %v", err)
+		}
+	}
+	if got := q.Pending(); got != 2 {
+		t.Errorf("Pending() = %d, want 2", got)
+	}
+
+	out := readQueue(t, q, 30, 30)
+	checkSegments(t, out, []struct {
+		from, to int
+		want     float32
+	}{
+		{0, 10, 0.25},
+		{10, 20, 0.5},
+		{20, 30, 0},
+	})
+}
+
+func TestPriorityQueue_ClipError(t *testing.T) {
+	t.Parallel()
+
+	q, err := NewPriorityQueue(Format{Rate: 1000, Channels: 1})
+	if err != nil {
+		t.Fatalf("NewPriorityQueue() error = %v", err)
+	}
+
+	bad := playoutClip(100, 0.5, audiotest.WithErrorOnCall(2, audiotest.ErrInjected), audiotest.WithShortReads(10))
+	if err := q.Push(1, bad, Resume); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if err := q.Push(0, playoutClip(10, 0.25), Resume); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	n, err := q.ReadSamples(make([]float32, 50))
+	if !errors.Is(err, audiotest.ErrInjected) || n != 10 {
+		t.Fatalf("ReadSamples() = %d, %v, want 10, ErrInjected", n, err)
+	}
+	if !bad.Closed() {
+		t.Error("failed clip was not closed")
+	}
+
+	if out := readQueue(t, q, 20, 20); out[0] != 0.25 || out[9] != 0.25 || out[10] != 0 {
+		t.Errorf("after the failure = %v, want the next clip then silence", out)
+	}
+}
+
+func TestPriorityQueue_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewPriorityQueue(Format{Rate: 1000, Channels: 0}); !errors.Is(err, ErrInvalidChannels) {
+		t.Errorf("NewPriorityQueue(0 channels) error = %v, want ErrInvalidChannels", err)
+	}
+
+	q, err := NewPriorityQueue(Format{Rate: 1000, Channels: 2})
+	if err != nil {
+		t.Fatalf("NewPriorityQueue() error = %v", err)
+	}
+
+	if err := q.Push(0, playoutClip(10, 1), Resume); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("Push(mono clip) error = %v, want ErrFormatMismatch", err)
+	}
+	if _, err := q.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(3) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	waiting := audiotest.NewFaultySource(newConstantSource(1000, 2, 20, 1))
+	if err := q.Push(0, waiting, Resume); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !waiting.Closed() {
+		t.Error("Close() did not close the waiting clip")
+	}
+	if n, err := q.ReadSamples(make([]float32, 4)); n != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("ReadSamples() after Close = %d, %v, want 0, EOF", n, err)
+	}
+	if err := q.Push(0, audiotest.NewFaultySource(newConstantSource(1000, 2, 20, 1)), Resume); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Push() after Close error = %v, want ErrInvalidState", err)
+	}
+}
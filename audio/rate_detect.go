@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"slices"
+
+	"github.com/ik5/audpbx/utils"
+)
+
+const (
+	rateDetectFFTSize = 512
+
+	// Below this fraction of energy under rate/32 the low band is considered
+	// empty. Telephony audio is high-passed at 300 Hz, which lands at 600 Hz
+	// when 8 kHz audio is read at 16 kHz, while real wideband speech always
+	// carries its fundamental and first formant below 500 Hz.
+	rateDetectEmptyBand = 1e-3
+
+	// Apparent median pitch outside of this range is not human speech.
+	rateDetectMinPitch = 65.0
+	rateDetectMaxPitch = 400.0
+)
+
+// RateGuess is the result of DetectSampleRate.
+type RateGuess struct {
+	// Declared is the sample rate the stream claimed to have.
+	Declared int
+	// Rate is the most likely actual sample rate.
+	Rate int
+	// Confidence is a rough 0..1 score; 0 means there was nothing to analyze.
+	Confidence float64
+	// Reason explains the decision in a human readable way.
+	Reason string
+}
+
+// Mismatch reports whether the detected rate differs from the declared one.
+func (g RateGuess) Mismatch() bool {
+	return g.Rate != g.Declared
+}
+
+// DetectSampleRate guesses the real sample rate of headerless speech PCM
+// that is read through src at src.SampleRate(). It reads up to maxFrames
+// frames from src (consuming them) and looks at two cues:
+//
+//   - pitch: audio read at twice its real rate sounds an octave up, so the
+//     median voiced pitch is too high for speech, and an octave down when
+//     read at half its rate.
+//   - band energy: telephony audio recorded at 8 kHz and read at 16 kHz has
+//     no energy below rate/32, where wideband speech is always present.
+//
+// Only halving and doubling of the declared rate are considered, which
+// covers the common 8 kHz capture labeled as 16 kHz (and the opposite).
+// A maxFrames <= 0 analyzes up to 10 seconds.
+func DetectSampleRate(src Source, maxFrames int) (RateGuess, error) {
+	declared := src.SampleRate()
+	guess := RateGuess{Declared: declared, Rate: declared}
+	if err := FormatOf(src).Validate(); err != nil {
+		return guess, fmt.Errorf("%w", err)
+	}
+
+	if maxFrames <= 0 {
+		maxFrames = declared * 10
+	}
+
+	samples, err := readMono(src, maxFrames)
+	if err != nil {
+		return guess, err
+	}
+
+	lowFrac, total := lowBandFraction(samples, declared/32)
+	if total == 0 {
+		guess.Reason = "no signal to analyze"
+		return guess, nil
+	}
+
+	pitch := medianPitch(samples, declared)
+
+	switch {
+	case declared >= 16000 && pitch > rateDetectMaxPitch:
+		guess.Rate = declared / 2
+		guess.Confidence = math.Min(1, 0.6+(pitch-rateDetectMaxPitch)/rateDetectMaxPitch)
+		guess.Reason = fmt.Sprintf("median pitch %.0f Hz is too high for speech", pitch)
+	case declared >= 16000 && lowFrac < rateDetectEmptyBand:
+		guess.Rate = declared / 2
+		guess.Confidence = 0.6
+		if pitch > 0 {
+			guess.Confidence = 0.8
+		}
+		guess.Reason = fmt.Sprintf("no energy below %d Hz", declared/32)
+	case pitch > 0 && pitch < rateDetectMinPitch:
+		guess.Rate = declared * 2
+		guess.Confidence = math.Min(1, 0.6+(rateDetectMinPitch-pitch)/rateDetectMinPitch)
+		guess.Reason = fmt.Sprintf("median pitch %.0f Hz is too low for speech", pitch)
+	default:
+		guess.Confidence = 0.5
+		if pitch > 0 {
+			guess.Confidence = 0.8
+		}
+		guess.Reason = "spectrum and pitch consistent with declared rate"
+	}
+
+	return guess, nil
+}
+
+// readMono reads up to maxFrames frames of src folded to mono
+func readMono(src Source, maxFrames int) ([]float32, error) {
+	mono := NewMonoMixer(src)
+	out := make([]float32, 0, min(maxFrames, 1<<20))
+	buf := make([]float32, 4096)
+
+	for len(out) < maxFrames {
+		want := min(len(buf), maxFrames-len(out))
+		n, err := mono.ReadSamples(buf[:want])
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	return out, nil
+}
+
+// lowBandFraction returns the fraction of spectral energy below cutoff Hz
+// (with the stream read at rate = 32*cutoff), plus the total energy with DC
+// excluded.
+func lowBandFraction(samples []float32, cutoff int) (float64, float64) {
+	const n = rateDetectFFTSize
+	if len(samples) < n || cutoff <= 0 {
+		return 0, 0
+	}
+
+	// Bins are rate/n wide and cutoff is rate/32
+	lastLowBin := n / 32
+
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+
+	buf := make([]complex128, n)
+	var low, total float64
+	for start := 0; start+n <= len(samples); start += n / 2 {
+		for i := range n {
+			buf[i] = complex(float64(samples[start+i])*window[i], 0)
+		}
+		utils.FFT(buf)
+
+		// Skip DC and its window leakage
+		for k := 2; k <= n/2; k++ {
+			p := cmplx.Abs(buf[k])
+			p *= p
+			total += p
+			if k < lastLowBin {
+				low += p
+			}
+		}
+	}
+
+	if total < 1e-12 {
+		return 0, 0
+	}
+	return low / total, total
+}
+
+// medianPitch estimates the median fundamental frequency (at rate) of the
+// voiced blocks of samples using normalized autocorrelation. It returns 0
+// when no block looks voiced.
+func medianPitch(samples []float32, rate int) float64 {
+	block := rate / 10 // 100 ms, enough for 4 periods at 40 Hz
+	minLag := max(rate/1000, 1)
+	maxLag := rate / 40
+	if block <= maxLag || len(samples) < block {
+		return 0
+	}
+
+	corr := make([]float64, maxLag+1)
+	var pitches []float64
+	for start := 0; start+block <= len(samples); start += block {
+		frame := samples[start : start+block]
+
+		var energy float64
+		for _, s := range frame {
+			energy += float64(s) * float64(s)
+		}
+		if energy/float64(block) < 1e-5 {
+			continue // silence
+		}
+
+		best := 0.0
+		for lag := minLag; lag <= maxLag; lag++ {
+			var c, e1, e2 float64
+			for i := 0; i+lag < block; i++ {
+				a, b := float64(frame[i]), float64(frame[i+lag])
+				c += a * b
+				e1 += a * a
+				e2 += b * b
+			}
+			corr[lag] = 0
+			if e1 > 0 && e2 > 0 {
+				corr[lag] = c / math.Sqrt(e1*e2)
+			}
+			best = max(best, corr[lag])
+		}
+
+		// The shortest local peak close to the best one is the period;
+		// longer ones are its multiples
+		bestLag := 0
+		for lag := minLag + 1; lag < maxLag; lag++ {
+			if corr[lag] >= 0.9*best && corr[lag] >= corr[lag-1] && corr[lag] >= corr[lag+1] {
+				bestLag = lag
+				break
+			}
+		}
+
+		if best > 0.6 && bestLag > 0 {
+			pitches = append(pitches, float64(rate)/float64(bestLag))
+		}
+	}
+
+	if len(pitches) == 0 {
+		return 0
+	}
+	slices.Sort(pitches)
+	return pitches[len(pitches)/2]
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// voiceAt renders a harmonic "voice" with fundamental f0 sampled at rate,
+// keeping only harmonics between minFreq and maxFreq, then declares it at
+// declaredRate.
+func voiceAt(rate, declaredRate int, f0, minFreq, maxFreq float64, seconds int) *layoutSource {
+	src := newMockSource(declaredRate, 1, rate*seconds, func(sample int, channel int) float32 {
+		t := float64(sample) / float64(rate)
+		var v float64
+		for h := int(math.Ceil(minFreq / f0)); float64(h)*f0 < maxFreq; h++ {
+			v += math.Sin(2*math.Pi*f0*float64(h)*t) / float64(h)
+		}
+		return float32(v * 0.3)
+	})
+	return &layoutSource{MockSource: src, layout: LayoutMono}
+}
+
+func TestDetectSampleRate_NarrowbandLabeledWideband(t *testing.T) {
+	t.Parallel()
+
+	// 8 kHz telephony audio (300-3400 Hz) written with a 16 kHz header
+	src := voiceAt(8000, 16000, 140, 300, 3400, 2)
+
+	guess, err := DetectSampleRate(src, 0)
+	if err != nil {
+		t.Fatalf("DetectSampleRate() error = %v", err)
+	}
+
+	if guess.Rate != 8000 || !guess.Mismatch() {
+		t.Errorf("DetectSampleRate() = %+v, want Rate 8000", guess)
+	}
+}
+
+func TestDetectSampleRate_GenuineWideband(t *testing.T) {
+	t.Parallel()
+
+	src := voiceAt(16000, 16000, 140, 100, 7000, 2)
+
+	guess, err := DetectSampleRate(src, 0)
+	if err != nil {
+		t.Fatalf("DetectSampleRate() error = %v", err)
+	}
+
+	if guess.Rate != 16000 || guess.Mismatch() {
+		t.Errorf("DetectSampleRate() = %+v, want Rate 16000", guess)
+	}
+}
+
+func TestDetectSampleRate_WidebandLabeledNarrowband(t *testing.T) {
+	t.Parallel()
+
+	// 16 kHz audio read as 8 kHz sounds an octave down
+	src := voiceAt(16000, 8000, 100, 50, 7000, 2)
+
+	guess, err := DetectSampleRate(src, 0)
+	if err != nil {
+		t.Fatalf("DetectSampleRate() error = %v", err)
+	}
+
+	if guess.Rate != 16000 {
+		t.Errorf("DetectSampleRate() = %+v, want Rate 16000", guess)
+	}
+}
+
+func TestDetectSampleRate_Silence(t *testing.T) {
+	t.Parallel()
+
+	guess, err := DetectSampleRate(newSilentSource(16000, 1, 16000), 0)
+	if err != nil {
+		t.Fatalf("DetectSampleRate() error = %v", err)
+	}
+
+	if guess.Rate != 16000 || guess.Confidence != 0 {
+		t.Errorf("DetectSampleRate() on silence = %+v, want declared rate with 0 confidence", guess)
+	}
+}
+
+func TestDetectSampleRate_InvalidSource(t *testing.T) {
+	t.Parallel()
+
+	if _, err := DetectSampleRate(newSilentSource(0, 1, 10), 0); err == nil {
+		t.Error("DetectSampleRate() with 0 Hz source should fail")
+	}
+}
+
+func TestDetectSampleRate_HighPitchedNarrowband(t *testing.T) {
+	t.Parallel()
+
+	// Full band 8 kHz audio read at 16 kHz: only the pitch gives it away
+	src := voiceAt(8000, 16000, 220, 100, 3900, 2)
+
+	guess, err := DetectSampleRate(src, 0)
+	if err != nil {
+		t.Fatalf("DetectSampleRate() error = %v", err)
+	}
+
+	if guess.Rate != 8000 {
+		t.Errorf("DetectSampleRate() = %+v, want Rate 8000", guess)
+	}
+}
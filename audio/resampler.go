@@ -72,9 +72,17 @@ func NewResampler(src Source, dstRate int) *Resampler {
 	return r
 }
 
-func (r *Resampler) SampleRate() int { return int(r.dstRate) }
-func (r *Resampler) Channels() int   { return r.channels }
-func (r *Resampler) BufSize() int    { return r.src.BufSize() }
+func (r *Resampler) SampleRate() int    { return int(r.dstRate) }
+func (r *Resampler) Channels() int      { return r.channels }
+func (r *Resampler) BufSize() int       { return r.src.BufSize() }
+func (r *Resampler) Upstream() []Source { return []Source{r.src} }
+
+// Format returns the source format with the rate replaced by the target rate.
+func (r *Resampler) Format() Format {
+	f := FormatOf(r.src)
+	f.Rate = int(r.dstRate)
+	return f
+}
 
 func (r *Resampler) Close() error {
 	err := r.src.Close()
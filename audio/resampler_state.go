@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const resamplerStateVersion = 1
+
+// MarshalBinary captures the interpolation state of the resampler: the
+// frame ring, the fractional position and the anti-aliasing filter state.
+// Together with the number of frames consumed from the source it is enough
+// to continue resampling a stream from the middle, as checkpointed batch
+// jobs do.
+func (r *Resampler) MarshalBinary() ([]byte, error) {
+	size := 1 + 4 + 8 + 1 + 4 + (4+1)*r.channels*4
+	b := make([]byte, 0, size)
+
+	b = append(b, resamplerStateVersion)
+	b = binary.LittleEndian.AppendUint32(b, uint32(r.channels))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(r.pos))
+	b = append(b, boolByte(r.eof))
+	for _, has := range r.hasFrame {
+		b = append(b, boolByte(has))
+	}
+	for _, frame := range r.frames {
+		for _, v := range frame {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+		}
+	}
+	for _, v := range r.filterState {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary restores state captured by MarshalBinary. The resampler
+// must have been created for the same channel count, and its source must be
+// positioned right after the frames consumed when the state was captured.
+func (r *Resampler) UnmarshalBinary(data []byte) error {
+	want := 1 + 4 + 8 + 1 + 4 + (4+1)*r.channels*4
+	if len(data) != want || data[0] != resamplerStateVersion {
+		return fmt.Errorf("%w: %d bytes for resampler state", ErrInvalidState, len(data))
+	}
+	if ch := int(binary.LittleEndian.Uint32(data[1:5])); ch != r.channels {
+		return fmt.Errorf("%w: state has %d channels, resampler %d", ErrInvalidState, ch, r.channels)
+	}
+
+	r.pos = math.Float64frombits(binary.LittleEndian.Uint64(data[5:13]))
+	r.eof = data[13] != 0
+	for i := range r.hasFrame {
+		r.hasFrame[i] = data[14+i] != 0
+	}
+
+	rest := data[18:]
+	next := func() float32 {
+		v := math.Float32frombits(binary.LittleEndian.Uint32(rest))
+		rest = rest[4:]
+		return v
+	}
+	for _, frame := range r.frames {
+		for c := range frame {
+			frame[c] = next()
+		}
+	}
+	for c := range r.filterState {
+		r.filterState[c] = next()
+	}
+
+	return nil
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
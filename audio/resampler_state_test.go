@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// Restoring a snapshot into a new resampler over a source positioned at the
+// same frame continues the stream exactly.
+func TestResampler_StateRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		srcRate  int
+		dstRate  int
+		channels int
+	}{
+		{"downsample stereo", 44100, 8000, 2},
+		{"upsample mono", 8000, 48000, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			const frames = 4000
+			want := readAll(t, NewResampler(audiotest.NewSineSource(tt.srcRate, tt.channels, frames, 300), tt.dstRate), 64)
+
+			src := audiotest.NewSineSource(tt.srcRate, tt.channels, frames, 300)
+			first := NewResampler(src, tt.dstRate)
+			head := make([]float32, 500*tt.channels)
+			n, err := first.ReadSamples(head)
+			if err != nil {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+			state, err := first.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() error = %v", err)
+			}
+
+			// src is where first left it; the new resampler takes over
+			second := NewResampler(src, tt.dstRate)
+			if err := second.UnmarshalBinary(state); err != nil {
+				t.Fatalf("UnmarshalBinary() error = %v", err)
+			}
+			got := append(head[:n], readAll(t, second, 64)...)
+
+			if !slices.Equal(got, want) {
+				t.Errorf("resumed stream differs: %d samples vs %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestResampler_UnmarshalBinaryErrors(t *testing.T) {
+	t.Parallel()
+
+	stereo := NewResampler(audiotest.NewSilentSource(8000, 2, 100), 16000)
+	state, _ := stereo.MarshalBinary()
+
+	mono := NewResampler(audiotest.NewSilentSource(8000, 1, 100), 16000)
+	for name, data := range map[string][]byte{
+		"wrong channels": state,
+		"truncated":      state[:10],
+		"empty":          nil,
+	} {
+		if err := mono.UnmarshalBinary(data); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%s: UnmarshalBinary() error = %v, want %v", name, err, ErrInvalidState)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// ResamplerBackend is an implementation of sample rate conversion. The
+// built-in ones trade quality for speed: LinearResampling, CubicResampling
+// and SincResampling. Others, such as bindings to libsamplerate or
+// zita-resampler, plug in by implementing it, or with ResamplerFunc.
+//
+// Pick one for a pipeline by calling it where the pipeline is built, or
+// for all the pipelines of the process, including those the library
+// builds itself, with SetDefaultResampler.
+type ResamplerBackend interface {
+	// Resample returns a source playing src at rate, with the channels
+	// of src. Closing it closes src.
+	Resample(src Source, rate int) Source
+}
+
+// ResamplerFunc adapts a function to ResamplerBackend.
+type ResamplerFunc func(src Source, rate int) Source
+
+func (f ResamplerFunc) Resample(src Source, rate int) Source { return f(src, rate) }
+
+var (
+	defaultResamplerMu sync.Mutex
+	defaultResampler   ResamplerBackend = CubicResampling{}
+)
+
+// SetDefaultResampler makes b the backend of Resample, and so of the
+// pipelines the library builds, such as those of prompts, announcements,
+// speech recognition and Adaptation.Apply. nil restores CubicResampling.
+// Pipelines built before keep their backend.
+//
+// Checkpointed transcoding keeps the *Resampler of NewResampler, whose
+// state it saves.
+func SetDefaultResampler(b ResamplerBackend) {
+	defaultResamplerMu.Lock()
+	defer defaultResamplerMu.Unlock()
+
+	if b == nil {
+		b = CubicResampling{}
+	}
+	defaultResampler = b
+}
+
+// DefaultResampler returns the backend of Resample.
+func DefaultResampler() ResamplerBackend {
+	defaultResamplerMu.Lock()
+	defer defaultResamplerMu.Unlock()
+
+	return defaultResampler
+}
+
+// Resample returns a source playing src at rate with the default backend
+// (see SetDefaultResampler).
+func Resample(src Source, rate int) Source {
+	return DefaultResampler().Resample(src, rate)
+}
+
+// CubicResampling is the backend of NewResampler: cubic interpolation
+// with a one-pole anti-aliasing filter when downsampling. It is the
+// default, a fair trade for speech.
+type CubicResampling struct{}
+
+func (CubicResampling) Resample(src Source, rate int) Source { return NewResampler(src, rate) }
+
+// LinearResampling interpolates linearly between neighbouring frames,
+// without filtering: the cheapest backend, for previews and for rates
+// close to each other.
+type LinearResampling struct{}
+
+func (LinearResampling) Resample(src Source, rate int) Source {
+	return &linearResampler{
+		src:      src,
+		rate:     rate,
+		channels: max(src.Channels(), 1),
+		step:     float64(src.SampleRate()) / float64(rate),
+	}
+}
+
+// linearResampler is the source of LinearResampling
+type linearResampler struct {
+	src      Source
+	rate     int
+	channels int
+	step     float64 // source frames per output frame
+
+	buf  []float32
+	in   []float32 // input from the frame before the next output on
+	pos  float64   // of the next output frame, from the first of in
+	eof  bool
+	done bool
+}
+
+func (r *linearResampler) SampleRate() int    { return r.rate }
+func (r *linearResampler) Channels() int      { return r.src.Channels() }
+func (r *linearResampler) BufSize() int       { return r.src.BufSize() }
+func (r *linearResampler) Upstream() []Source { return []Source{r.src} }
+
+func (r *linearResampler) Format() Format {
+	f := FormatOf(r.src)
+	f.Rate = r.rate
+	return f
+}
+
+func (r *linearResampler) Close() error {
+	if err := r.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *linearResampler) ReadSamples(dst []float32) (int, error) {
+	ch := r.channels
+	if len(dst)%ch != 0 {
+		return 0, ErrInvalidDstSize
+	}
+	if r.done {
+		return 0, io.EOF
+	}
+
+	written := 0
+	for written < len(dst) {
+		i := int(r.pos)
+		frames := len(r.in) / ch
+		if i+1 >= frames && !r.eof {
+			ok, err := r.pull()
+			if err != nil {
+				return written, err
+			}
+			if !ok {
+				return written, nil
+			}
+			continue
+		}
+		if i >= frames {
+			r.done = true
+			if written == 0 {
+				return 0, io.EOF
+			}
+			return written, nil
+		}
+
+		frac := float32(r.pos - float64(i))
+		for c := range ch {
+			a := r.in[i*ch+c]
+			b := a
+			if i+1 < frames {
+				b = r.in[(i+1)*ch+c]
+			}
+			dst[written+c] = a + (b-a)*frac
+		}
+		written += ch
+		r.pos += r.step
+	}
+	return written, nil
+}
+
+// pull drops the input behind the position and reads more, reporting
+// false when the source has nothing to give yet
+func (r *linearResampler) pull() (bool, error) {
+	ch := r.channels
+	if drop := int(r.pos); drop > 0 {
+		drop = min(drop, len(r.in)/ch)
+		r.in = r.in[:copy(r.in, r.in[drop*ch:])]
+		r.pos -= float64(drop)
+	}
+
+	if r.buf == nil {
+		r.buf = make([]float32, max(r.src.BufSize(), ch)/ch*ch)
+	}
+	n, err := r.src.ReadSamples(r.buf)
+	r.in = append(r.in, r.buf[:n-n%ch]...)
+	if errors.Is(err, io.EOF) {
+		r.eof = true
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	return n > 0, nil
+}
+
+// DefaultSincTaps is the number of zero crossings of the filter of
+// SincResampling on each side.
+const DefaultSincTaps = 16
+
+// sincPhases is the number of fractional positions the filter of
+// SincResampling is tabulated at; positions in between are interpolated
+const sincPhases = 256
+
+// SincResampling convolves the source with a Blackman windowed sinc,
+// cutting at the Nyquist frequency of the lower rate: the backend for
+// music and measurements, where the aliasing and droop of interpolation
+// show, at several times the cost of CubicResampling. It is the
+// band-limited interpolation of resamplers such as zita-resampler and
+// libsamplerate.
+type SincResampling struct {
+	// Taps is the number of zero crossings of the filter on each side,
+	// DefaultSincTaps when zero. More sharpen the cutoff and add delay to
+	// the start.
+	Taps int
+}
+
+func (s SincResampling) Resample(src Source, rate int) Source {
+	taps := s.Taps
+	if taps <= 0 {
+		taps = DefaultSincTaps
+	}
+	step := float64(src.SampleRate()) / float64(rate)
+	cutoff := min(1/step, 1)
+	half := int(math.Ceil(float64(taps) / cutoff)) // input frames each side
+
+	// Row p holds the filter for an output at p/sincPhases past an input
+	// frame, over the frames from half-1 before it to half after
+	table := make([][]float32, sincPhases+1)
+	for p := range table {
+		row := make([]float32, 2*half)
+		var sum float64
+		coefs := make([]float64, 2*half)
+		for j := range coefs {
+			t := float64(j-half+1) - float64(p)/sincPhases
+			x := t / float64(half)
+			if math.Abs(x) >= 1 {
+				continue
+			}
+			w := 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+			coefs[j] = cutoff * sinc(cutoff*t) * w
+			sum += coefs[j]
+		}
+		for j, c := range coefs {
+			row[j] = float32(c / sum) // unity gain at DC
+		}
+		table[p] = row
+	}
+
+	ch := max(src.Channels(), 1)
+	return &sincResampler{
+		src:      src,
+		rate:     rate,
+		channels: ch,
+		step:     step,
+		half:     half,
+		table:    table,
+		in:       make([]float32, (half-1)*ch), // silence before the start
+		pos:      float64(half - 1),
+		total:    -1,
+	}
+}
+
+// sinc is the normalized sinc function
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// sincResampler is the source of SincResampling
+type sincResampler struct {
+	src      Source
+	rate     int
+	channels int
+	step     float64 // source frames per output frame
+	half     int     // filter frames on each side
+	table    [][]float32
+
+	buf     []float32
+	in      []float32 // input, from frame dropped-half+1 of the source on
+	pos     float64   // of the next output frame, in frames of in
+	dropped int64     // frames dropped from the front of in
+	total   int64     // frames of the source once it ended, else -1
+}
+
+func (r *sincResampler) SampleRate() int    { return r.rate }
+func (r *sincResampler) Channels() int      { return r.src.Channels() }
+func (r *sincResampler) BufSize() int       { return r.src.BufSize() }
+func (r *sincResampler) Upstream() []Source { return []Source{r.src} }
+
+func (r *sincResampler) Format() Format {
+	f := FormatOf(r.src)
+	f.Rate = r.rate
+	return f
+}
+
+func (r *sincResampler) Close() error {
+	if err := r.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (r *sincResampler) ReadSamples(dst []float32) (int, error) {
+	ch := r.channels
+	if len(dst)%ch != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	written := 0
+	for written < len(dst) {
+		// Frame of the source the output is at
+		at := float64(r.dropped) + r.pos - float64(r.half-1)
+		if r.total >= 0 && at >= float64(r.total) {
+			if written == 0 {
+				return 0, io.EOF
+			}
+			return written, nil
+		}
+
+		i := int(r.pos)
+		if i+r.half >= len(r.in)/ch {
+			if r.total >= 0 {
+				// Silence past the end, for the last outputs
+				r.in = append(r.in, make([]float32, (r.half+1)*ch)...)
+				continue
+			}
+			ok, err := r.pull()
+			if err != nil {
+				return written, err
+			}
+			if !ok {
+				return written, nil
+			}
+			continue
+		}
+
+		phase := (r.pos - float64(i)) * sincPhases
+		p := int(phase)
+		mix := float32(phase - float64(p))
+		lo, hi := r.table[p], r.table[min(p+1, sincPhases)]
+		frames := r.in[(i-r.half+1)*ch:]
+		for c := range ch {
+			var acc float32
+			for j := range lo {
+				acc += frames[j*ch+c] * (lo[j] + (hi[j]-lo[j])*mix)
+			}
+			dst[written+c] = acc
+		}
+		written += ch
+		r.pos += r.step
+	}
+	return written, nil
+}
+
+// pull drops the input no output needs any more and reads more, reporting
+// false when the source has nothing to give yet
+func (r *sincResampler) pull() (bool, error) {
+	ch := r.channels
+	if drop := int(r.pos) - r.half + 1; drop > 0 {
+		drop = min(drop, len(r.in)/ch)
+		r.in = r.in[:copy(r.in, r.in[drop*ch:])]
+		r.pos -= float64(drop)
+		r.dropped += int64(drop)
+	}
+
+	if r.buf == nil {
+		r.buf = make([]float32, max(r.src.BufSize(), ch)/ch*ch)
+	}
+	n, err := r.src.ReadSamples(r.buf)
+	r.in = append(r.in, r.buf[:n-n%ch]...)
+	if errors.Is(err, io.EOF) {
+		r.total = r.dropped + int64(len(r.in)/ch) - int64(r.half-1)
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+var resamplingBackends = map[string]ResamplerBackend{
+	"linear": LinearResampling{},
+	"cubic":  CubicResampling{},
+	"sinc":   SincResampling{},
+	"sinc8":  SincResampling{Taps: 8},
+}
+
+func TestResamplerBackends(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		srcRate, dstRate int
+		channels         int
+		chunk            int
+	}{
+		{name: "down", srcRate: 48000, dstRate: 8000, channels: 1, chunk: 256},
+		{name: "up", srcRate: 8000, dstRate: 16000, channels: 1, chunk: 100},
+		{name: "odd ratio", srcRate: 44100, dstRate: 16000, channels: 2, chunk: 64},
+		{name: "same", srcRate: 8000, dstRate: 8000, channels: 2, chunk: 512},
+	}
+
+	for name, backend := range resamplingBackends {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				t.Parallel()
+
+				src := backend.Resample(newSineSource(tt.srcRate, tt.channels, tt.srcRate, 440), tt.dstRate)
+				if src.SampleRate() != tt.dstRate || src.Channels() != tt.channels {
+					t.Fatalf("format = %d Hz, %d channels, want %d Hz, %d channels",
+						src.SampleRate(), src.Channels(), tt.dstRate, tt.channels)
+				}
+				out := readAll(t, src, tt.chunk*tt.channels)
+
+				// One second in, one second out, give or take a millisecond
+				if got := len(out) / tt.channels; math.Abs(float64(got-tt.dstRate)) > float64(tt.dstRate/1000) {
+					t.Errorf("read %d frames, want %d", got, tt.dstRate)
+				}
+				edge := tt.dstRate / 100 * tt.channels
+				if f := frequency(out[edge:len(out)-edge], tt.channels, tt.dstRate); math.Abs(f-440) > 1 {
+					t.Errorf("frequency = %.1f Hz, want 440", f)
+				}
+				if got := rms(out[edge : len(out)-edge]); math.Abs(got-math.Sqrt2/2) > 0.01 {
+					t.Errorf("RMS = %.3f, want %.3f", got, math.Sqrt2/2)
+				}
+			})
+		}
+	}
+}
+
+func TestResamplerBackends_Errors(t *testing.T) {
+	t.Parallel()
+
+	for name, backend := range resamplingBackends {
+		src := backend.Resample(newSilentSource(8000, 2, 100), 16000)
+		if _, err := src.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+			t.Errorf("%s: ReadSamples(odd) error = %v, want ErrInvalidDstSize", name, err)
+		}
+
+		faulty := audiotest.NewFaultySource(newConstantSource(8000, 1, 8000, 0.5), audiotest.WithShortReads(50), audiotest.WithErrorOnCall(5, audiotest.ErrInjected))
+		src = backend.Resample(faulty, 16000)
+		buf := make([]float32, 256)
+		var err error
+		for range 100 {
+			if _, err = src.ReadSamples(buf); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("%s: ReadSamples() error = %v, want ErrInjected", name, err)
+		}
+		if err := src.Close(); err != nil || !faulty.Closed() {
+			t.Errorf("%s: Close() error = %v, source closed = %v", name, err, faulty.Closed())
+		}
+	}
+}
+
+func TestResamplerFunc(t *testing.T) {
+	t.Parallel()
+
+	// A third-party backend, here wrapping a built-in one
+	var calls int
+	var backend ResamplerBackend = ResamplerFunc(func(src Source, rate int) Source {
+		calls++
+		return LinearResampling{}.Resample(src, rate)
+	})
+
+	out := readAll(t, backend.Resample(newConstantSource(8000, 1, 800, 0.5), 16000), 256)
+	if calls != 1 || len(out) != 1600 {
+		t.Errorf("calls = %d, read %d samples, want 1 call and 1600 samples", calls, len(out))
+	}
+}
+
+// Not parallel: it swaps the process-wide default
+func TestSetDefaultResampler(t *testing.T) {
+	defer SetDefaultResampler(nil)
+
+	if _, ok := DefaultResampler().(CubicResampling); !ok {
+		t.Fatalf("DefaultResampler() = %T, want CubicResampling", DefaultResampler())
+	}
+	if _, ok := Resample(newSilentSource(8000, 1, 100), 16000).(*Resampler); !ok {
+		t.Error("Resample() does not use CubicResampling by default")
+	}
+
+	SetDefaultResampler(SincResampling{})
+	if _, ok := Resample(newSilentSource(8000, 1, 100), 16000).(*sincResampler); !ok {
+		t.Error("Resample() does not use the backend set")
+	}
+
+	// Stages that convert rates pick it up
+	in := newSilentSource(8000, 1, 100)
+	want := FormatOf(in)
+	want.Rate = 16000
+	adapted, err := Adaptation{Requested: FormatOf(in), Format: want}.Apply(in)
+	if err != nil {
+		t.Fatalf("Adaptation.Apply() error = %v", err)
+	}
+	if _, ok := adapted.(*sincResampler); !ok {
+		t.Errorf("Adaptation.Apply() = %T, want the backend set", adapted)
+	}
+
+	SetDefaultResampler(nil)
+	if _, ok := DefaultResampler().(CubicResampling); !ok {
+		t.Errorf("DefaultResampler() after nil = %T, want CubicResampling", DefaultResampler())
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import "time"
+
+// Retimer is implemented by sources that move their input in time, by
+// cutting parts of it or playing it at another speed, as TrimSilence,
+// ShortenSilence and TimeStretch do. Sources that keep time, such as
+// gains, filters and resamplers, do not implement it.
+type Retimer interface {
+	// MapTime returns the time of the output at which the input at t
+	// plays, and false when it was cut, with the time at which the cut
+	// is. Only the input the source has read so far is known.
+	MapTime(t time.Duration) (time.Duration, bool)
+}
+
+// MapTime returns the time of the output of src at which its input at t
+// plays, and false when a stage cut it, with the time at which the cut is.
+// It follows src through Upstreamer down to its input, and maps t through
+// every stage implementing Retimer on the way, from the input up. Where a
+// stage reads several sources, such as a Mixer or a Concat, it follows
+// the first one.
+//
+// It maps the times of an external transcript of the input, such as the
+// word timestamps of a speech recognizer, onto the edited audio, once
+// src was read past them.
+func MapTime(src Source, t time.Duration) (time.Duration, bool) {
+	var chain []Retimer
+	for src != nil {
+		if r, ok := src.(Retimer); ok {
+			chain = append(chain, r)
+		}
+		u, ok := src.(Upstreamer)
+		if !ok {
+			break
+		}
+		up := u.Upstream()
+		if len(up) == 0 {
+			break
+		}
+		src = up[0]
+	}
+
+	kept := true
+	for i := len(chain) - 1; i >= 0; i-- {
+		var ok bool
+		t, ok = chain[i].MapTime(t)
+		kept = kept && ok
+	}
+	return t, kept
+}
+
+// cutList records the parts of its input a source drops, for MapTime
+type cutList struct {
+	rate int
+	cuts []cut
+}
+
+// cut is a part of the input dropped, in frames
+type cut struct {
+	at, frames int64
+}
+
+// add records that frames of the input were dropped from frame at on
+func (l *cutList) add(at, frames int64) {
+	if frames <= 0 {
+		return
+	}
+	if n := len(l.cuts); n > 0 && l.cuts[n-1].at+l.cuts[n-1].frames == at {
+		l.cuts[n-1].frames += frames
+		return
+	}
+	l.cuts = append(l.cuts, cut{at: at, frames: frames})
+}
+
+// mapTime maps t past the cuts
+func (l *cutList) mapTime(t time.Duration) (time.Duration, bool) {
+	var shift time.Duration
+	for _, c := range l.cuts {
+		start, end := FramesDuration(c.at, l.rate), FramesDuration(c.at+c.frames, l.rate)
+		if t < start {
+			break
+		}
+		if t < end {
+			return start - shift, false
+		}
+		shift += end - start
+	}
+	return t - shift, true
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMapTime(t *testing.T) {
+	t.Parallel()
+
+	ms := time.Millisecond
+	type mapping struct {
+		in, out time.Duration
+		kept    bool
+	}
+	tests := []struct {
+		name  string
+		build func() Source
+		want  []mapping
+	}{
+		{
+			// 500 ms of dead air trimmed off each end of 2 s
+			name: "trimmed",
+			build: func() Source {
+				return TrimSilence(newMockSource(8000, 1, 16000, soundAt([2]int{4000, 12000})), -40, 100*ms)
+			},
+			want: []mapping{{250 * ms, 0, false}, {500 * ms, 0, true}, {ms * 1000, 500 * ms, true}, {1750 * ms, ms * 1000, false}},
+		},
+		{
+			// The pause from 650 to 1350 ms is cut, then played 1.25 times
+			// as fast, resampled and amplified on the way
+			name: "speed read",
+			build: func() Source {
+				fast, err := SpeedRead(newMockSource(8000, 2, 20000, soundAt([2]int{0, 4000}, [2]int{12000, 16000})), SpeedReadOptions{Speed: 1.25})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return NewGain(NewMonoMixer(NewResampler(fast, 16000)), 2)
+			},
+			want: []mapping{{400 * ms, 320 * ms, true}, {ms * 1000, 520 * ms, false}, {1600 * ms, 720 * ms, true}},
+		},
+		{
+			name:  "no retiming",
+			build: func() Source { return NewGain(newSilentSource(8000, 1, 8000), 2) },
+			want:  []mapping{{300 * ms, 300 * ms, true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src := tt.build()
+			readAll(t, src, 512)
+			for _, m := range tt.want {
+				if got, kept := MapTime(src, m.in); got != m.out || kept != m.kept {
+					t.Errorf("MapTime(%v) = %v, %v, want %v, %v", m.in, got, kept, m.out, m.kept)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+// Seeker is implemented by sources that support random access, such as the
+// decoders in formats/... when they read from an io.ReadSeeker. Check for it
+// with a type assertion:
+//
+//	if s, ok := src.(audio.Seeker); ok {
+//		err = s.SeekFrame(int64(30 * src.SampleRate())) // skip to 0:30
+//	}
+//
+// Positions are in sample frames (one sample per channel) from the start of
+// the stream.
+type Seeker interface {
+	// SeekFrame moves the read position to frame n, so the next
+	// ReadSamples starts with it. Seeking to the end of the stream is
+	// allowed; seeking before the start or, when the length is known,
+	// past the end fails with ErrSeekOutOfRange.
+	SeekFrame(n int64) error
+	// Position returns the frame the next ReadSamples starts with.
+	Position() int64
+}
@@ -0,0 +1,365 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// silenceWindow is the stretch of audio classified as silent or not at
+// once: long enough for any voiced sound to peak in it, short enough to
+// cut close to speech
+const silenceWindow = 10 * time.Millisecond
+
+// TrimSilence returns a source playing src without its leading and
+// trailing dead air: silence, where no sample of a 10 ms window reaches
+// thresholdDB dBFS (-40 suits most recordings), lasting at least
+// minDuration. Shorter silences, and all those between sounds, are kept.
+//
+// Leading silence is dropped as it is read. Silence after a sound is held
+// until the next sound, or until src ends and it turns out to be trailing,
+// so a long pause in the middle is held in memory until it is over.
+// Closing the source closes src.
+func TrimSilence(src Source, thresholdDB float64, minDuration time.Duration) Source {
+	channels := max(src.Channels(), 1)
+	return &silenceTrimmer{
+		src:        src,
+		channels:   channels,
+		threshold:  DBToGain(thresholdDB),
+		window:     make([]float32, max(FrameLen(src.SampleRate(), silenceWindow), 1)*channels),
+		minSilence: FrameLen(src.SampleRate(), minDuration) * channels,
+		cuts:       cutList{rate: src.SampleRate()},
+	}
+}
+
+// silenceTrimmer is the source of TrimSilence
+type silenceTrimmer struct {
+	src        Source
+	channels   int
+	threshold  float32
+	window     []float32
+	filled     int
+	minSilence int // samples
+
+	started  bool      // a sound was found
+	leadLong bool      // the leading silence is dead air
+	held     []float32 // silence not known to be dead air yet
+	out      []float32 // ready to be read
+	err      error     // of the source, io.EOF once it ended
+	read     int64     // samples classified
+	cuts     cutList
+}
+
+func (t *silenceTrimmer) SampleRate() int    { return t.src.SampleRate() }
+func (t *silenceTrimmer) Channels() int      { return t.src.Channels() }
+func (t *silenceTrimmer) BufSize() int       { return t.src.BufSize() }
+func (t *silenceTrimmer) Format() Format     { return FormatOf(t.src) }
+func (t *silenceTrimmer) Upstream() []Source { return []Source{t.src} }
+
+// MapTime maps a time of the source past the dead air trimmed so far.
+func (t *silenceTrimmer) MapTime(at time.Duration) (time.Duration, bool) {
+	return t.cuts.mapTime(at)
+}
+
+func (t *silenceTrimmer) Close() error {
+	if err := t.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with the trimmed audio. len(dst) must be a
+// multiple of Channels().
+func (t *silenceTrimmer) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%t.channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	for len(t.out) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		ok, err := t.fill()
+		if err != nil {
+			t.end()
+			t.err = err
+			continue
+		}
+		if !ok {
+			// Nothing yet from the source: let the caller come back
+			return 0, nil
+		}
+		t.classify(t.window)
+		t.filled = 0
+	}
+
+	n := copy(dst, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+// fill reads the source into the window, reporting whether it is full.
+// When the source ends, the part of the window it filled is classified
+// first.
+func (t *silenceTrimmer) fill() (bool, error) {
+	for t.filled < len(t.window) {
+		n, err := t.src.ReadSamples(t.window[t.filled:])
+		t.filled += n
+		if err != nil {
+			t.filled -= t.filled % t.channels
+			t.classify(t.window[:t.filled])
+			t.filled = 0
+			if errors.Is(err, io.EOF) {
+				return false, io.EOF
+			}
+			return false, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// classify passes a window on, holds it or drops it
+func (t *silenceTrimmer) classify(win []float32) {
+	if len(win) == 0 {
+		return
+	}
+	t.read += int64(len(win))
+
+	if !silent(win, t.threshold) {
+		if t.started || !t.leadLong {
+			t.out = append(t.out, t.held...)
+		} else {
+			t.dropHeld(int64(len(win)))
+		}
+		t.held = t.held[:0]
+		t.out = append(t.out, win...)
+		t.started = true
+		return
+	}
+
+	t.held = append(t.held, win...)
+	if !t.started && len(t.held) >= t.minSilence {
+		// Dead air before the first sound: no need to keep it
+		t.leadLong = true
+		t.dropHeld(0)
+		t.held = t.held[:0]
+	}
+}
+
+// dropHeld records the held silence, followed by after samples, as cut
+func (t *silenceTrimmer) dropHeld(after int64) {
+	ch := int64(t.channels)
+	t.cuts.add((t.read-after-int64(len(t.held)))/ch, int64(len(t.held))/ch)
+}
+
+// end passes on the silence held at the end of the source when it is too
+// short to be dead air
+func (t *silenceTrimmer) end() {
+	if len(t.held) < t.minSilence && (t.started || !t.leadLong) {
+		t.out = append(t.out, t.held...)
+	} else {
+		t.dropHeld(0)
+	}
+	t.held = nil
+}
+
+// SplitOnSilence reads src to the end and splits it on the silences, as
+// TrimSilence finds them, lasting at least minSilence, such as the pauses
+// between the messages of a recorded announcement. It returns a source
+// for each part, without the silence around it, playing from memory.
+// Silences shorter than minSilence stay within their part. It does not
+// close src.
+func SplitOnSilence(src Source, thresholdDB float64, minSilence time.Duration) ([]Source, error) {
+	format := FormatOf(src)
+	mem := &memorySource{format: format, src: src, bufSize: src.BufSize()}
+	if err := mem.load(); err != nil {
+		return nil, err
+	}
+
+	channels := max(src.Channels(), 1)
+	threshold := DBToGain(thresholdDB)
+	window := max(FrameLen(src.SampleRate(), silenceWindow), 1) * channels
+	gap := FrameLen(src.SampleRate(), minSilence) * channels
+	samples := mem.samples[:len(mem.samples)-len(mem.samples)%channels]
+
+	var parts []Source
+	start, end := -1, 0 // of the part being found, in samples
+	for at := 0; at < len(samples); at += window {
+		win := samples[at:min(at+window, len(samples))]
+		if silent(win, threshold) {
+			continue
+		}
+		if start >= 0 && at-end >= gap {
+			parts = append(parts, samplesSource(format, samples[start:end], mem.bufSize))
+			start = -1
+		}
+		if start < 0 {
+			start = at
+		}
+		end = at + len(win)
+	}
+	if start >= 0 {
+		parts = append(parts, samplesSource(format, samples[start:end], mem.bufSize))
+	}
+	return parts, nil
+}
+
+// samplesSource returns a source playing samples
+func samplesSource(format Format, samples []float32, bufSize int) Source {
+	return &memorySource{format: format, bufSize: bufSize, samples: samples}
+}
+
+// silent reports whether no sample of win reaches threshold
+func silent(win []float32, threshold float32) bool {
+	for _, v := range win {
+		if v >= threshold || -v >= threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// ShortenSilence returns a source playing src with every silence, as
+// TrimSilence finds them, cut down to maxSilence: the first half of it and
+// the last half are kept, so that pauses still sound like pauses and the
+// soft start of the next word is not clipped. Leading and trailing silence
+// is shortened too. It is the first step of SpeedRead.
+//
+// At most half of maxSilence of audio is held in memory. Closing the
+// source closes src.
+func ShortenSilence(src Source, thresholdDB float64, maxSilence time.Duration) Source {
+	channels := max(src.Channels(), 1)
+	keep := FrameLen(src.SampleRate(), maxSilence)
+	return &silenceShortener{
+		src:       src,
+		channels:  channels,
+		threshold: DBToGain(thresholdDB),
+		window:    make([]float32, max(FrameLen(src.SampleRate(), silenceWindow), 1)*channels),
+		head:      keep / 2 * channels,
+		tail:      (keep - keep/2) * channels,
+		cuts:      cutList{rate: src.SampleRate()},
+	}
+}
+
+// silenceShortener is the source of ShortenSilence
+type silenceShortener struct {
+	src       Source
+	channels  int
+	threshold float32
+	window    []float32
+	head      int // samples of silence kept at its start
+	tail      int // and at its end
+
+	run  int       // samples of the current silence so far
+	held []float32 // end of the current silence, at most tail samples
+	out  []float32 // ready to be read
+	err  error     // of the source, io.EOF once it ended
+	read int64     // samples classified
+	cuts cutList
+}
+
+func (s *silenceShortener) SampleRate() int    { return s.src.SampleRate() }
+func (s *silenceShortener) Channels() int      { return s.src.Channels() }
+func (s *silenceShortener) BufSize() int       { return s.src.BufSize() }
+func (s *silenceShortener) Format() Format     { return FormatOf(s.src) }
+func (s *silenceShortener) Upstream() []Source { return []Source{s.src} }
+
+// MapTime maps a time of the source past the silence cut so far.
+func (s *silenceShortener) MapTime(t time.Duration) (time.Duration, bool) {
+	return s.cuts.mapTime(t)
+}
+
+func (s *silenceShortener) Close() error {
+	if err := s.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples fills dst with the shortened audio. len(dst) must be a
+// multiple of Channels().
+func (s *silenceShortener) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%s.channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	for len(s.out) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		n, err := s.fill()
+		s.classify(s.window[:n])
+		if err != nil {
+			s.out = append(s.out, s.held...)
+			s.held = nil
+			s.err = err
+			continue
+		}
+		if n == 0 {
+			// Nothing yet from the source: let the caller come back
+			return 0, nil
+		}
+	}
+
+	n := copy(dst, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// fill reads a window of the source and returns its length, short only
+// when the source ended or had nothing to give
+func (s *silenceShortener) fill() (int, error) {
+	filled := 0
+	for filled < len(s.window) {
+		n, err := s.src.ReadSamples(s.window[filled:])
+		filled += n
+		if err != nil {
+			filled -= filled % s.channels
+			if errors.Is(err, io.EOF) {
+				return filled, io.EOF
+			}
+			return filled, fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return filled, nil
+}
+
+// classify passes a window on, or keeps as much of it as the silence it
+// is part of is allowed
+func (s *silenceShortener) classify(win []float32) {
+	if len(win) == 0 {
+		return
+	}
+	s.read += int64(len(win))
+
+	if !silent(win, s.threshold) {
+		s.out = append(s.out, s.held...)
+		s.out = append(s.out, win...)
+		s.held = s.held[:0]
+		s.run = 0
+		return
+	}
+
+	if s.run < s.head {
+		n := min(s.head-s.run, len(win))
+		s.out = append(s.out, win[:n]...)
+		s.run += n
+		win = win[n:]
+	}
+	s.run += len(win)
+	s.held = append(s.held, win...)
+	if extra := len(s.held) - s.tail; extra > 0 {
+		ch := int64(s.channels)
+		s.cuts.add((s.read-int64(len(s.held)))/ch, int64(extra)/ch)
+		s.held = s.held[:copy(s.held, s.held[extra:])]
+	}
+}
@@ -0,0 +1,234 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// soundAt returns a waveform at 8 kHz, of constant 0.5 over the given
+// frame ranges and silent elsewhere
+func soundAt(ranges ...[2]int) func(int, int) float32 {
+	return func(i, _ int) float32 {
+		for _, r := range ranges {
+			if i >= r[0] && i < r[1] {
+				return 0.5
+			}
+		}
+		return 0.001 // -60 dBFS
+	}
+}
+
+// soundRanges returns the frame ranges of values of 0.5 in samples
+func soundRanges(samples []float32, channels int) [][2]int {
+	var ranges [][2]int
+	for f := 0; f*channels < len(samples); f++ {
+		if samples[f*channels] != 0.5 {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1][1] == f {
+			ranges[n-1][1]++
+			continue
+		}
+		ranges = append(ranges, [2]int{f, f + 1})
+	}
+	return ranges
+}
+
+func TestTrimSilence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		channels int
+		total    int
+		wave     func(int, int) float32
+		min      time.Duration
+		chunk    int
+		len      int // frames
+	}{
+		{name: "both ends", total: 16000, wave: soundAt([2]int{4000, 12000}), min: 100 * time.Millisecond, chunk: 256, len: 8000},
+		{name: "stereo", channels: 2, total: 16000, wave: soundAt([2]int{4000, 12000}), min: 100 * time.Millisecond, chunk: 256, len: 8000},
+		{name: "pause kept", total: 16000, wave: soundAt([2]int{4000, 6000}, [2]int{10000, 12000}), min: 100 * time.Millisecond, chunk: 100, len: 8000},
+		{name: "short lead kept", total: 8000, wave: soundAt([2]int{400, 4000}), min: 100 * time.Millisecond, chunk: 512, len: 4000},
+		{name: "short tail kept", total: 4400, wave: soundAt([2]int{800, 4000}), min: 100 * time.Millisecond, chunk: 512, len: 3600},
+		{name: "any silence", total: 8000, wave: soundAt([2]int{400, 4000}), chunk: 512, len: 3600},
+		{name: "all silence", total: 8000, wave: soundAt(), min: 100 * time.Millisecond, chunk: 512, len: 0},
+		{name: "no silence", total: 8000, wave: soundAt([2]int{0, 8000}), min: 100 * time.Millisecond, chunk: 512, len: 8000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			channels := max(tt.channels, 1)
+			src := TrimSilence(newMockSource(8000, channels, tt.total, tt.wave), -40, tt.min)
+			out := readAll(t, src, tt.chunk*channels)
+			if len(out) != tt.len*channels {
+				t.Fatalf("read %d frames, want %d", len(out)/channels, tt.len)
+			}
+		})
+	}
+}
+
+func TestTrimSilence_Errors(t *testing.T) {
+	t.Parallel()
+
+	src := TrimSilence(newMockSource(8000, 2, 100, soundAt([2]int{0, 100})), -40, 0)
+	if _, err := src.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Fatalf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	faulty := audiotest.NewFaultySource(newConstantSource(8000, 1, 8000, 0.5), audiotest.WithShortReads(50), audiotest.WithErrorOnCall(5, audiotest.ErrInjected))
+	src = TrimSilence(faulty, -40, 0)
+	buf := make([]float32, 256)
+	var err error
+	for range 10 {
+		if _, err = src.ReadSamples(buf); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := src.Close(); err != nil || !faulty.Closed() {
+		t.Fatalf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
+
+func TestSplitOnSilence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		channels int
+		wave     func(int, int) float32
+		min      time.Duration
+		want     [][2]int // frames of sound in each part
+	}{
+		{
+			name: "two messages",
+			wave: soundAt([2]int{800, 4000}, [2]int{8000, 12000}),
+			min:  200 * time.Millisecond,
+			want: [][2]int{{0, 3200}, {0, 4000}},
+		},
+		{
+			name:     "stereo",
+			channels: 2,
+			wave:     soundAt([2]int{800, 4000}, [2]int{8000, 12000}),
+			min:      200 * time.Millisecond,
+			want:     [][2]int{{0, 3200}, {0, 4000}},
+		},
+		{
+			name: "short pause within",
+			wave: soundAt([2]int{800, 4000}, [2]int{4800, 12000}),
+			min:  200 * time.Millisecond,
+			want: [][2]int{{0, 3200}},
+		},
+		{
+			name: "silence",
+			wave: soundAt(),
+			min:  200 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			channels := max(tt.channels, 1)
+			parts, err := SplitOnSilence(newMockSource(8000, channels, 16000, tt.wave), -40, tt.min)
+			if err != nil {
+				t.Fatalf("SplitOnSilence() error = %v", err)
+			}
+			if len(parts) != len(tt.want) {
+				t.Fatalf("SplitOnSilence() returned %d parts, want %d", len(parts), len(tt.want))
+			}
+			for i, p := range parts {
+				if p.Channels() != channels || p.SampleRate() != 8000 {
+					t.Fatalf("part %d is %d Hz x %d", i, p.SampleRate(), p.Channels())
+				}
+				got := soundRanges(readAll(t, p, 512*channels), channels)
+				if len(got) == 0 || got[0] != tt.want[i] {
+					t.Errorf("part %d sound = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitOnSilence_Error(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewFaultySource(newConstantSource(8000, 1, 8000, 0.5), audiotest.WithShortReads(100), audiotest.WithErrorOnCall(3, audiotest.ErrInjected))
+	if _, err := SplitOnSilence(src, -40, time.Second); !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("SplitOnSilence() error = %v, want ErrInjected", err)
+	}
+}
+
+func TestShortenSilence(t *testing.T) {
+	t.Parallel()
+
+	// 300 ms is 2400 frames at 8 kHz, 1200 kept at each end of a silence
+	tests := []struct {
+		name     string
+		channels int
+		total    int
+		wave     func(int, int) float32
+		chunk    int
+		want     [][2]int // frames of sound in the output
+		len      int      // frames
+	}{
+		{name: "long pause", total: 20000, wave: soundAt([2]int{0, 4000}, [2]int{12000, 16000}), chunk: 256, want: [][2]int{{0, 4000}, {6400, 10400}}, len: 12800},
+		{name: "stereo", channels: 2, total: 20000, wave: soundAt([2]int{0, 4000}, [2]int{12000, 16000}), chunk: 100, want: [][2]int{{0, 4000}, {6400, 10400}}, len: 12800},
+		{name: "short pause kept", total: 9000, wave: soundAt([2]int{0, 4000}, [2]int{5000, 9000}), chunk: 512, want: [][2]int{{0, 4000}, {5000, 9000}}, len: 9000},
+		{name: "leading and trailing", total: 16000, wave: soundAt([2]int{6000, 10000}), chunk: 512, want: [][2]int{{2400, 6400}}, len: 8800},
+		{name: "all silence", total: 16000, wave: soundAt(), chunk: 512, len: 2400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			channels := max(tt.channels, 1)
+			src := ShortenSilence(newMockSource(8000, channels, tt.total, tt.wave), -40, 300*time.Millisecond)
+			out := readAll(t, src, tt.chunk*channels)
+			if len(out) != tt.len*channels {
+				t.Fatalf("read %d frames, want %d", len(out)/channels, tt.len)
+			}
+			if got := soundRanges(out, channels); !slices.Equal(got, tt.want) {
+				t.Errorf("sound at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortenSilence_Errors(t *testing.T) {
+	t.Parallel()
+
+	src := ShortenSilence(newMockSource(8000, 2, 100, soundAt([2]int{0, 100})), -40, time.Second)
+	if _, err := src.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Fatalf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	faulty := audiotest.NewFaultySource(newConstantSource(8000, 1, 8000, 0.5), audiotest.WithShortReads(50), audiotest.WithErrorOnCall(5, audiotest.ErrInjected))
+	src = ShortenSilence(faulty, -40, time.Second)
+	buf := make([]float32, 256)
+	var err error
+	for range 10 {
+		if _, err = src.ReadSamples(buf); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := src.Close(); err != nil || !faulty.Closed() {
+		t.Fatalf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
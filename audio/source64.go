@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Source64 is a Source of float64 samples, for measurement pipelines where
+// the rounding of float32 between the stages of a long chain of filters
+// adds up to an error that shows. Samples are in [-1, 1] as for Source.
+//
+// It is opt in: To64 turns a Source into a Source64 and From64 back, and
+// stages taking a Source64, such as dsp.Biquad64, keep the samples in
+// float64 from one to the next.
+type Source64 interface {
+	SampleRate() int
+	Channels() int
+	ReadSamples64(dst []float64) (int, error)
+	BufSize() int
+	Close() error
+}
+
+// Format64Of returns the Format of src, as FormatOf does of a Source,
+// assuming float64 samples when src does not implement Formatter.
+func Format64Of(src Source64) Format {
+	if f, ok := src.(Formatter); ok {
+		return f.Format()
+	}
+
+	return Format{
+		Rate:       src.SampleRate(),
+		Channels:   src.Channels(),
+		Layout:     DefaultLayout(src.Channels()),
+		SampleKind: SampleFloat64,
+	}
+}
+
+// To64 returns src as a Source64. A source that implements Source64 as
+// well, as those of From64 do, is returned as it is; others are widened,
+// which is exact. Closing it closes src.
+func To64(src Source) Source64 {
+	if s, ok := src.(Source64); ok {
+		return s
+	}
+	return &widener{src: src}
+}
+
+// From64 returns src as a Source, rounding its samples to float32. The
+// source implements Source64 too, reading src unrounded, so To64 gets the
+// float64 samples back. A source of To64 is unwrapped instead. Closing it
+// closes src.
+func From64(src Source64) Source {
+	if w, ok := src.(*widener); ok {
+		return w.src
+	}
+	return &narrower{src: src}
+}
+
+// widener is the source of To64
+type widener struct {
+	src Source
+	buf []float32
+}
+
+func (w *widener) SampleRate() int    { return w.src.SampleRate() }
+func (w *widener) Channels() int      { return w.src.Channels() }
+func (w *widener) BufSize() int       { return w.src.BufSize() }
+func (w *widener) Upstream() []Source { return []Source{w.src} }
+
+func (w *widener) Format() Format {
+	f := FormatOf(w.src)
+	if f.SampleKind == SampleFloat32 {
+		f.SampleKind = SampleFloat64
+	}
+	return f
+}
+
+func (w *widener) Close() error {
+	if err := w.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (w *widener) ReadSamples64(dst []float64) (int, error) {
+	if cap(w.buf) < len(dst) {
+		w.buf = make([]float32, len(dst))
+	}
+	n, err := w.src.ReadSamples(w.buf[:len(dst)])
+	for i, v := range w.buf[:n] {
+		dst[i] = float64(v)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, err
+}
+
+// narrower is the source of From64
+type narrower struct {
+	src Source64
+	buf []float64
+}
+
+func (n *narrower) SampleRate() int { return n.src.SampleRate() }
+func (n *narrower) Channels() int   { return n.src.Channels() }
+func (n *narrower) BufSize() int    { return n.src.BufSize() }
+
+func (n *narrower) Format() Format {
+	f := Format64Of(n.src)
+	if f.SampleKind == SampleFloat64 {
+		f.SampleKind = SampleFloat32
+	}
+	return f
+}
+
+func (n *narrower) Close() error {
+	if err := n.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples64 reads the source unrounded.
+func (n *narrower) ReadSamples64(dst []float64) (int, error) {
+	return n.src.ReadSamples64(dst)
+}
+
+func (n *narrower) ReadSamples(dst []float32) (int, error) {
+	if cap(n.buf) < len(dst) {
+		n.buf = make([]float64, len(dst))
+	}
+	k, err := n.src.ReadSamples64(n.buf[:len(dst)])
+	for i, v := range n.buf[:k] {
+		dst[i] = float32(v)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return k, fmt.Errorf("%w", err)
+	}
+	return k, err
+}
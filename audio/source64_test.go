@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// thirdSource64 is a Source64 of frames samples of 1/3, which float32 cannot
+// hold exactly
+type thirdSource64 struct {
+	frames int
+}
+
+func (s *thirdSource64) SampleRate() int { return 8000 }
+func (s *thirdSource64) Channels() int   { return 1 }
+func (s *thirdSource64) BufSize() int    { return 64 }
+func (s *thirdSource64) Close() error    { return nil }
+
+func (s *thirdSource64) ReadSamples64(dst []float64) (int, error) {
+	n := min(len(dst), s.frames)
+	for i := range dst[:n] {
+		dst[i] = 1.0 / 3
+	}
+	s.frames -= n
+	if s.frames == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestSource64(t *testing.T) {
+	t.Parallel()
+
+	// Widening is exact
+	wide := To64(newSineSource(8000, 2, 1000, 440))
+	if f := Format64Of(wide); f.SampleKind != SampleFloat64 || f.Channels != 2 {
+		t.Errorf("Format64Of(To64()) = %v, want float64 stereo", f)
+	}
+	want := readAll(t, newSineSource(8000, 2, 1000, 440), 100)
+	var got []float64
+	buf := make([]float64, 100)
+	for {
+		n, err := wide.ReadSamples64(buf)
+		got = append(got, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples64() error = %v", err)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("read %d samples, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != float64(want[i]) {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// Narrowing rounds, but keeps the float64 samples for To64
+	narrow := From64(&thirdSource64{frames: 10})
+	if f := FormatOf(narrow); f.SampleKind != SampleFloat32 {
+		t.Errorf("FormatOf(From64()) = %v, want float32", f)
+	}
+	if s := readAll(t, narrow, 4); len(s) != 10 || s[0] != float32(1.0/3) {
+		t.Errorf("From64() read %v", s)
+	}
+	again := To64(From64(&thirdSource64{frames: 10}))
+	if n, _ := again.ReadSamples64(buf[:4]); n != 4 || buf[0] != 1.0/3 {
+		t.Errorf("To64(From64()) read %v, want 1/3 unrounded", buf[:n])
+	}
+
+	src := newConstantSource(8000, 1, 10, 0.5)
+	if From64(To64(src)) != Source(src) {
+		t.Error("From64(To64(src)) is not src")
+	}
+}
+
+func TestSource64_Errors(t *testing.T) {
+	t.Parallel()
+
+	faulty := audiotest.NewFaultySource(newSilentSource(8000, 1, 100), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	wide := To64(faulty)
+	if _, err := wide.ReadSamples64(make([]float64, 8)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples64() error = %v, want ErrInjected", err)
+	}
+	if err := From64(wide).Close(); err != nil || !faulty.Closed() {
+		t.Errorf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
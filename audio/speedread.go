@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults of SpeedReadOptions.
+const (
+	DefaultSpeedReadMaxSilence = 300 * time.Millisecond
+	DefaultSpeedReadThreshold  = -40.0 // dBFS
+)
+
+// SpeedReadOptions configures SpeedRead. Zero values select the defaults.
+type SpeedReadOptions struct {
+	// MaxSilence is how long a silence may last, 300 ms by default.
+	MaxSilence time.Duration
+	// ThresholdDB is the level, in dBFS, below which a 10 ms window is
+	// silent, -40 by default.
+	ThresholdDB float64
+	// Speed stretches the shortened audio to play that many times as
+	// fast, keeping its pitch. 0 and 1 leave it as is; reviewers of calls
+	// like 1.25.
+	Speed float64
+}
+
+// SpeedRead returns the speed-reader version of a recording, for
+// listening to calls in QA review: src with its silences shortened to
+// opts.MaxSilence by ShortenSilence, then played opts.Speed times as fast
+// by TimeStretch. Export it as any source:
+//
+//	fast, err := audio.SpeedRead(call, audio.SpeedReadOptions{Speed: 1.25})
+//	if err != nil {
+//	    return err
+//	}
+//	err = wav.Encode(out, fast)
+//
+// It fails with ErrInvalidState when opts.Speed is negative. Closing the
+// source closes src.
+func SpeedRead(src Source, opts SpeedReadOptions) (Source, error) {
+	if opts.MaxSilence == 0 {
+		opts.MaxSilence = DefaultSpeedReadMaxSilence
+	}
+	if opts.ThresholdDB == 0 {
+		opts.ThresholdDB = DefaultSpeedReadThreshold
+	}
+	if opts.Speed == 0 {
+		opts.Speed = 1
+	}
+
+	out, err := TimeStretch(ShortenSilence(src, opts.ThresholdDB, opts.MaxSilence), opts.Speed)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return out, nil
+}
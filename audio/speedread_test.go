@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSpeedRead(t *testing.T) {
+	t.Parallel()
+
+	// 20000 frames at 8 kHz with a pause of 1 s, and 500 ms of silence at
+	// the end: 12800 frames once they are cut to 300 ms, 16000 at 500 ms
+	wave := soundAt([2]int{0, 4000}, [2]int{12000, 16000})
+
+	tests := []struct {
+		name string
+		opts SpeedReadOptions
+		want int // frames, within a stretch window
+	}{
+		{name: "defaults", want: 12800},
+		{name: "faster", opts: SpeedReadOptions{Speed: 1.25}, want: 10240},
+		{name: "longer silences", opts: SpeedReadOptions{MaxSilence: 500 * time.Millisecond, Speed: 2}, want: 8000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := SpeedRead(newMockSource(8000, 1, 20000, wave), tt.opts)
+			if err != nil {
+				t.Fatalf("SpeedRead() error = %v", err)
+			}
+			got := len(readAll(t, src, 256))
+			if math.Abs(float64(got-tt.want)) > float64(FrameLen(8000, stretchWindow)) {
+				t.Errorf("read %d frames, want about %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := SpeedRead(newSilentSource(8000, 1, 100), SpeedReadOptions{Speed: -1}); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("SpeedRead(speed -1) error = %v, want ErrInvalidState", err)
+	}
+}
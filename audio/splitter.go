@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ChannelSplitter splits a multichannel source into one mono source per
+// channel, so each can be processed on its own: the caller on the left
+// channel of a call recording and the agent on the right, transcribed
+// apart. MonoMixer, by contrast, averages them together.
+//
+// The channel sources read the source in turn, as they need samples, and
+// keep what the others have not read yet, so they should be read at about
+// the same pace; a closed channel source keeps nothing. They are safe for
+// use from different goroutines. The source is closed once all of them
+// are.
+type ChannelSplitter struct {
+	src     Source
+	outputs []*splitChannel
+
+	mu     *sync.Mutex
+	buf    []float32
+	queues [][]float32 // samples not yet read, per channel
+	open   int
+	err    error // of the source, io.EOF once it ended
+}
+
+// Split returns one mono source per channel of src, as a ChannelSplitter
+// splits it.
+func Split(src Source) []Source {
+	return NewChannelSplitter(src).Outputs()
+}
+
+// NewChannelSplitter creates a ChannelSplitter of src.
+func NewChannelSplitter(src Source) *ChannelSplitter {
+	channels := max(src.Channels(), 1)
+	s := &ChannelSplitter{
+		src:     src,
+		outputs: make([]*splitChannel, channels),
+		mu:      &sync.Mutex{},
+		queues:  make([][]float32, channels),
+		open:    channels,
+	}
+
+	format := FormatOf(src)
+	format.Channels = 1
+	format.Layout = LayoutMono
+	for i := range s.outputs {
+		s.outputs[i] = &splitChannel{s: s, index: i, format: format}
+	}
+	return s
+}
+
+// Channel returns the mono source of channel i, or nil when src has no
+// such channel.
+func (s *ChannelSplitter) Channel(i int) Source {
+	if i < 0 || i >= len(s.outputs) {
+		return nil
+	}
+	return s.outputs[i]
+}
+
+// Outputs returns the mono sources of all channels, in order.
+func (s *ChannelSplitter) Outputs() []Source {
+	out := make([]Source, len(s.outputs))
+	for i, o := range s.outputs {
+		out[i] = o
+	}
+	return out
+}
+
+// read fills dst from the queue of channel i, reading the source when the
+// queue is empty
+func (s *ChannelSplitter) read(i int, dst []float32) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.outputs[i].closed {
+		return 0, fmt.Errorf("%w: channel %d is closed", ErrInvalidState, i)
+	}
+	if len(dst) == 0 {
+		return 0, nil
+	}
+
+	for len(s.queues[i]) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		n, err := s.fill(len(dst))
+		if err != nil {
+			s.err = err
+		}
+		if n == 0 && err == nil {
+			// Nothing yet from the source: let the caller come back
+			return 0, nil
+		}
+	}
+
+	n := copy(dst, s.queues[i])
+	s.queues[i] = s.queues[i][n:]
+	return n, nil
+}
+
+// fill reads about frames sample frames from the source into the queues of
+// the open channels
+func (s *ChannelSplitter) fill(frames int) (int, error) {
+	ch := len(s.queues)
+	size := max(frames, 1) * ch
+	if cap(s.buf) < size {
+		s.buf = make([]float32, size)
+	}
+
+	n, err := s.src.ReadSamples(s.buf[:size])
+	n -= n % ch
+	for i, out := range s.outputs {
+		if out.closed {
+			continue
+		}
+		q := s.queues[i]
+		if len(q) == 0 {
+			q = q[:0]
+		}
+		for j := i; j < n; j += ch {
+			q = append(q, s.buf[j])
+		}
+		s.queues[i] = q
+	}
+
+	if errors.Is(err, io.EOF) {
+		return n, io.EOF
+	}
+	if err != nil {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, nil
+}
+
+// close drops channel i, closing the source with the last one
+func (s *ChannelSplitter) close(i int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := s.outputs[i]
+	if out.closed {
+		return nil
+	}
+	out.closed = true
+	s.queues[i] = nil
+	s.open--
+
+	if s.open > 0 {
+		return nil
+	}
+	if err := s.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// splitChannel is the mono source of one channel of a ChannelSplitter
+type splitChannel struct {
+	s      *ChannelSplitter
+	index  int
+	format Format
+	closed bool // guarded by s.mu
+}
+
+func (c *splitChannel) SampleRate() int    { return c.format.Rate }
+func (c *splitChannel) Channels() int      { return 1 }
+func (c *splitChannel) BufSize() int       { return c.s.src.BufSize() }
+func (c *splitChannel) Format() Format     { return c.format }
+func (c *splitChannel) Upstream() []Source { return []Source{c.s.src} }
+func (c *splitChannel) Close() error       { return c.s.close(c.index) }
+
+func (c *splitChannel) ReadSamples(dst []float32) (int, error) {
+	return c.s.read(c.index, dst)
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// callLegs is a stereo call recording: the caller at 0.25 on the left,
+// the agent at -0.5 on the right, and the sample frame added to both
+func callLegs(frames int) Source {
+	return newMockSource(8000, 2, frames, func(sample, channel int) float32 {
+		if channel == 0 {
+			return 0.25 + float32(sample)/1e6
+		}
+		return -0.5 + float32(sample)/1e6
+	})
+}
+
+func TestChannelSplitter_ReadSamples(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		chunk []int // samples per read of each channel
+	}{
+		{name: "in step", chunk: []int{160, 160}},
+		{name: "different reads", chunk: []int{7, 300}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			chans := Split(callLegs(1000))
+			if len(chans) != 2 {
+				t.Fatalf("Split() returned %d sources, want 2", len(chans))
+			}
+			if f := FormatOf(chans[1]); f.Channels != 1 || f.Rate != 8000 || f.Layout != LayoutMono {
+				t.Errorf("channel format = %s, want 8000 Hz mono", f)
+			}
+
+			// Read in turn, a chunk of each at a time
+			got := make([][]float32, 2)
+			done := make([]bool, 2)
+			for !done[0] || !done[1] {
+				for i, c := range chans {
+					if done[i] {
+						continue
+					}
+					buf := make([]float32, tt.chunk[i])
+					n, err := c.ReadSamples(buf)
+					got[i] = append(got[i], buf[:n]...)
+					if errors.Is(err, io.EOF) {
+						done[i] = true
+					} else if err != nil {
+						t.Fatalf("channel %d ReadSamples() error = %v", i, err)
+					}
+				}
+			}
+
+			for i, want := range []float32{0.25, -0.5} {
+				if len(got[i]) != 1000 {
+					t.Fatalf("channel %d read %d samples, want 1000", i, len(got[i]))
+				}
+				for j, v := range got[i] {
+					if w := want + float32(j)/1e6; v != w {
+						t.Fatalf("channel %d sample %d = %v, want %v", i, j, v, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChannelSplitter_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	s := NewChannelSplitter(callLegs(8000))
+	var wg sync.WaitGroup
+	counts := make([]int, 2)
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts[i] = len(readAll(t, s.Channel(i), 160))
+		}()
+	}
+	wg.Wait()
+
+	if counts[0] != 8000 || counts[1] != 8000 {
+		t.Errorf("read %v samples, want 8000 each", counts)
+	}
+}
+
+func TestChannelSplitter_Close(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewFaultySource(callLegs(1000))
+	s := NewChannelSplitter(src)
+	if s.Channel(2) != nil || s.Channel(-1) != nil {
+		t.Error("Channel() of a missing channel is not nil")
+	}
+
+	left, right := s.Channel(0), s.Channel(1)
+	if err := right.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if src.Closed() {
+		t.Fatal("source closed with a channel still open")
+	}
+	if _, err := right.ReadSamples(make([]float32, 10)); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("ReadSamples() of a closed channel error = %v, want ErrInvalidState", err)
+	}
+	if got := readAll(t, left, 100); len(got) != 1000 {
+		t.Errorf("left channel read %d samples, want 1000", len(got))
+	}
+	if err := left.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !src.Closed() {
+		t.Error("source not closed with the last channel")
+	}
+}
+
+func TestChannelSplitter_Errors(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewFaultySource(callLegs(1000), audiotest.WithErrorOnCall(2, audiotest.ErrInjected))
+	chans := Split(src)
+	buf := make([]float32, 100)
+	if _, err := chans[0].ReadSamples(buf); err != nil {
+		t.Fatalf("first ReadSamples() error = %v", err)
+	}
+	// Both channels get the error, after what was read before it
+	for i, c := range chans {
+		if i == 1 {
+			if n, err := c.ReadSamples(buf); n != 100 || err != nil {
+				t.Fatalf("channel 1 ReadSamples() = %d, %v, want the queued samples", n, err)
+			}
+		}
+		if _, err := c.ReadSamples(buf); !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("channel %d ReadSamples() error = %v, want ErrInjected", i, err)
+		}
+	}
+}
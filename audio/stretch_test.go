@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// frequency estimates the frequency of the sine on the first channel of
+// samples from its zero crossings
+func frequency(samples []float32, channels, rate int) float64 {
+	crossings, first, last := 0, -1, 0
+	for f := 1; f*channels < len(samples); f++ {
+		if (samples[(f-1)*channels] < 0) != (samples[f*channels] < 0) {
+			if first < 0 {
+				first = f
+			}
+			crossings, last = crossings+1, f
+		}
+	}
+	if crossings < 2 {
+		return 0
+	}
+	return float64(crossings-1) / 2 / (float64(last-first) / float64(rate))
+}
+
+// rms returns the RMS level of samples
+func rms(samples []float32) float64 {
+	var sum float64
+	for _, v := range samples {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func TestTimeStretch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+		speed    float64
+		chunk    int
+	}{
+		{name: "faster", rate: 8000, speed: 1.25, chunk: 256},
+		{name: "slower", rate: 8000, speed: 0.8, chunk: 100},
+		{name: "double", rate: 16000, speed: 2, chunk: 512},
+		{name: "stereo", rate: 8000, channels: 2, speed: 1.25, chunk: 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			channels := max(tt.channels, 1)
+			src, err := TimeStretch(newSineSource(tt.rate, channels, tt.rate, 440), tt.speed)
+			if err != nil {
+				t.Fatalf("TimeStretch() error = %v", err)
+			}
+			out := readAll(t, src, tt.chunk*channels)
+
+			// One second, sped up, and the tail of the last window
+			want := float64(tt.rate) / tt.speed
+			if got := float64(len(out) / channels); math.Abs(got-want) > float64(FrameLen(tt.rate, stretchWindow)) {
+				t.Errorf("read %v frames, want about %v", got, want)
+			}
+			// The pitch is kept, away from the fades at both ends
+			edge := FrameLen(tt.rate, stretchWindow) * channels
+			if f := frequency(out[edge:len(out)-edge], channels, tt.rate); math.Abs(f-440) > 440*0.02 {
+				t.Errorf("frequency = %.1f Hz, want 440", f)
+			}
+			// Windows overlap in phase, so the level does not dip
+			in := readAll(t, newSineSource(tt.rate, channels, tt.rate, 440), 512*channels)
+			if got, want := rms(out[edge:len(out)-edge]), rms(in); math.Abs(got-want) > want*0.1 {
+				t.Errorf("RMS = %.3f, want %.3f", got, want)
+			}
+			if channels == 2 {
+				for i := 0; i < len(out); i += 2 {
+					if out[i] != out[i+1] {
+						t.Fatalf("frame %d = %v, %v, channels differ", i/2, out[i], out[i+1])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTimeStretch_Errors(t *testing.T) {
+	t.Parallel()
+
+	for _, speed := range []float64{0, -1, math.Inf(1), math.NaN()} {
+		if _, err := TimeStretch(newSilentSource(8000, 1, 100), speed); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("TimeStretch(%v) error = %v, want ErrInvalidState", speed, err)
+		}
+	}
+	src := newSilentSource(8000, 1, 100)
+	if s, err := TimeStretch(src, 1); err != nil || s != Source(src) {
+		t.Errorf("TimeStretch(1) = %v, %v, want the source as it is", s, err)
+	}
+
+	s, err := TimeStretch(newSilentSource(8000, 2, 100), 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	faulty := audiotest.NewFaultySource(newConstantSource(8000, 1, 8000, 0.5), audiotest.WithShortReads(50), audiotest.WithErrorOnCall(5, audiotest.ErrInjected))
+	if s, err = TimeStretch(faulty, 1.25); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]float32, 256)
+	for range 100 {
+		if _, err = s.ReadSamples(buf); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := s.Close(); err != nil || !faulty.Closed() {
+		t.Errorf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// NewTee returns n sources that each play all of src, so one decoded
+// stream feeds several pipelines, such as recording to disk and live
+// analysis (VAD, DTMF), without opening and decoding it again. It returns
+// nil when n is below 1.
+//
+// The sources read src in turn, as they need samples, into a ring buffer
+// they share. It holds the samples between the slowest open source and the
+// fastest, growing as needed, so they should be read at about the same
+// pace; a closed source holds nothing back. They are safe for use from
+// different goroutines. src is closed once all of them are.
+func NewTee(src Source, n int) []Source {
+	if n < 1 {
+		return nil
+	}
+
+	t := &tee{
+		src:      src,
+		channels: max(src.Channels(), 1),
+		format:   FormatOf(src),
+		outputs:  make([]*teeOutput, n),
+		open:     n,
+	}
+	out := make([]Source, n)
+	for i := range t.outputs {
+		t.outputs[i] = &teeOutput{t: t}
+		out[i] = t.outputs[i]
+	}
+	return out
+}
+
+// tee is the ring buffer the sources of NewTee share. Positions count
+// samples from the start of src.
+type tee struct {
+	src      Source
+	channels int
+	format   Format
+	outputs  []*teeOutput
+
+	mu    sync.Mutex
+	ring  []float32 // samples from start to end, at their position modulo len(ring)
+	start int64     // of the oldest sample an open output has not read
+	end   int64     // after the last sample read from src
+	buf   []float32
+	open  int
+	err   error // of the source, io.EOF once it ended
+}
+
+// read fills dst for o from the ring, reading the source when o has read
+// all of it
+func (t *tee) read(o *teeOutput, dst []float32) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if o.closed {
+		return 0, fmt.Errorf("%w: tee output is closed", ErrInvalidState)
+	}
+	if len(dst)%t.channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+	if len(dst) == 0 {
+		return 0, nil
+	}
+
+	for o.pos == t.end {
+		if t.err != nil {
+			return 0, t.err
+		}
+		n, err := t.fill(len(dst))
+		if err != nil {
+			t.err = err
+		}
+		if n == 0 && err == nil {
+			// Nothing yet from the source: let the caller come back
+			return 0, nil
+		}
+	}
+
+	n := int(min(int64(len(dst)), t.end-o.pos))
+	at := int(o.pos % int64(len(t.ring)))
+	copied := copy(dst[:n], t.ring[at:])
+	copy(dst[copied:n], t.ring)
+	o.pos += int64(n)
+	t.release()
+	return n, nil
+}
+
+// fill reads up to size samples from the source onto the ring
+func (t *tee) fill(size int) (int, error) {
+	if cap(t.buf) < size {
+		t.buf = make([]float32, size)
+	}
+
+	n, err := t.src.ReadSamples(t.buf[:size])
+	n -= n % t.channels
+	if n > 0 {
+		t.grow(n)
+		at := int(t.end % int64(len(t.ring)))
+		copied := copy(t.ring[at:], t.buf[:n])
+		copy(t.ring, t.buf[copied:n])
+		t.end += int64(n)
+	}
+
+	if errors.Is(err, io.EOF) {
+		return n, io.EOF
+	}
+	if err != nil {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, nil
+}
+
+// grow makes room on the ring for n more samples
+func (t *tee) grow(n int) {
+	held := int(t.end - t.start)
+	if held+n <= len(t.ring) {
+		return
+	}
+
+	// Samples keep their position modulo the new length
+	ring := make([]float32, max(2*len(t.ring), held+n, 4096))
+	for p := t.start; p < t.end; p++ {
+		ring[p%int64(len(ring))] = t.ring[p%int64(len(t.ring))]
+	}
+	t.ring = ring
+}
+
+// release lets the ring reuse the samples every open output has read
+func (t *tee) release() {
+	start := t.end
+	for _, o := range t.outputs {
+		if !o.closed {
+			start = min(start, o.pos)
+		}
+	}
+	t.start = start
+}
+
+// close drops o, closing the source with the last output
+func (t *tee) close(o *teeOutput) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	t.open--
+	t.release()
+
+	if t.open > 0 {
+		return nil
+	}
+	t.ring = nil
+	if err := t.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// teeOutput is one of the sources of NewTee
+type teeOutput struct {
+	t      *tee
+	pos    int64 // guarded by t.mu
+	closed bool  // guarded by t.mu
+}
+
+func (o *teeOutput) SampleRate() int    { return o.t.src.SampleRate() }
+func (o *teeOutput) Channels() int      { return o.t.src.Channels() }
+func (o *teeOutput) BufSize() int       { return o.t.src.BufSize() }
+func (o *teeOutput) Format() Format     { return o.t.format }
+func (o *teeOutput) Upstream() []Source { return []Source{o.t.src} }
+func (o *teeOutput) Close() error       { return o.t.close(o) }
+
+func (o *teeOutput) ReadSamples(dst []float32) (int, error) {
+	return o.t.read(o, dst)
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestTee_ReadSamples(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		chunk []int // samples per read of each output
+	}{
+		{name: "in step", chunk: []int{320, 320, 320}},
+		{name: "different reads", chunk: []int{14, 600, 4098}},
+	}
+
+	want := readAll(t, callLegs(10000), 512)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			outs := NewTee(callLegs(10000), len(tt.chunk))
+			if len(outs) != len(tt.chunk) {
+				t.Fatalf("NewTee() returned %d sources, want %d", len(outs), len(tt.chunk))
+			}
+			if f := FormatOf(outs[1]); f.Channels != 2 || f.Rate != 8000 {
+				t.Errorf("output format = %s, want 8000 Hz stereo", f)
+			}
+
+			// Read in turn, a chunk of each at a time
+			got := make([][]float32, len(outs))
+			done := make([]bool, len(outs))
+			for slices.Contains(done, false) {
+				for i, o := range outs {
+					if done[i] {
+						continue
+					}
+					buf := make([]float32, tt.chunk[i])
+					n, err := o.ReadSamples(buf)
+					got[i] = append(got[i], buf[:n]...)
+					if errors.Is(err, io.EOF) {
+						done[i] = true
+					} else if err != nil {
+						t.Fatalf("output %d ReadSamples() error = %v", i, err)
+					}
+				}
+			}
+
+			for i := range got {
+				if !slices.Equal(got[i], want) {
+					t.Errorf("output %d read %d samples, not the %d of the source", i, len(got[i]), len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestTee_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	want := readAll(t, callLegs(16000), 512)
+	outs := NewTee(callLegs(16000), 3)
+	var wg sync.WaitGroup
+	got := make([][]float32, len(outs))
+	for i, o := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i] = readAll(t, o, 160*(i+1))
+		}()
+	}
+	wg.Wait()
+
+	for i := range got {
+		if !slices.Equal(got[i], want) {
+			t.Errorf("output %d read %d samples, not the %d of the source", i, len(got[i]), len(want))
+		}
+	}
+}
+
+func TestTee_Ring(t *testing.T) {
+	t.Parallel()
+
+	// Read in step, the ring holds one read, however long the source
+	outs := NewTee(callLegs(80000), 2)
+	buf := make([]float32, 320)
+	for {
+		_, err := outs[0].ReadSamples(buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if _, err := outs[1].ReadSamples(buf); err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+	if size := len(outs[0].(*teeOutput).t.ring); size > 4096 {
+		t.Errorf("ring of %d samples, want 4096 at most", size)
+	}
+}
+
+func TestTee_Close(t *testing.T) {
+	t.Parallel()
+
+	if NewTee(callLegs(10), 0) != nil {
+		t.Error("NewTee(0) is not nil")
+	}
+
+	src := audiotest.NewFaultySource(callLegs(1000))
+	outs := NewTee(src, 2)
+	live, recorder := outs[0], outs[1]
+	if _, err := recorder.ReadSamples(make([]float32, 100)); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if src.Closed() {
+		t.Fatal("source closed with an output still open")
+	}
+	if _, err := recorder.ReadSamples(make([]float32, 10)); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("ReadSamples() of a closed output error = %v, want ErrInvalidState", err)
+	}
+	if got := readAll(t, live, 100); len(got) != 2000 {
+		t.Errorf("open output read %d samples, want 2000", len(got))
+	}
+	if err := live.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !src.Closed() {
+		t.Error("source not closed with the last output")
+	}
+}
+
+func TestTee_Errors(t *testing.T) {
+	t.Parallel()
+
+	outs := NewTee(callLegs(100), 2)
+	if _, err := outs[0].ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	src := audiotest.NewFaultySource(callLegs(1000), audiotest.WithErrorOnCall(2, audiotest.ErrInjected))
+	outs = NewTee(src, 2)
+	buf := make([]float32, 100)
+	if _, err := outs[0].ReadSamples(buf); err != nil {
+		t.Fatalf("first ReadSamples() error = %v", err)
+	}
+	// Both outputs get the error, after what was read before it
+	for i, o := range outs {
+		if i == 1 {
+			if n, err := o.ReadSamples(buf); n != 100 || err != nil {
+				t.Fatalf("output 1 ReadSamples() = %d, %v, want the buffered samples", n, err)
+			}
+		}
+		if _, err := o.ReadSamples(buf); !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("output %d ReadSamples() error = %v, want ErrInjected", i, err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Upstreamer is implemented by sources that read other sources, the stages
+// of a pipeline such as Resampler or Mixer, so Snapshot can walk from the
+// end of a pipeline to its inputs.
+type Upstreamer interface {
+	// Upstream returns the sources read, in order.
+	Upstream() []Source
+}
+
+// ProbeCounters are what a Probe counted of the reads through it.
+type ProbeCounters struct {
+	// Reads is the number of ReadSamples calls.
+	Reads int64 `json:"reads"`
+	// Samples is the number of samples returned.
+	Samples int64 `json:"samples"`
+	// Empty is the number of reads that returned nothing without ending
+	// the stream: underruns of a live source.
+	Empty int64 `json:"empty"`
+	// Errors is the number of reads that failed, and LastError the error
+	// of the last one.
+	Errors    int64  `json:"errors"`
+	LastError string `json:"last_error,omitempty"`
+	// EOF reports whether the stream ended.
+	EOF bool `json:"eof"`
+}
+
+// Probe passes a source through unchanged, counting the reads through it,
+// so Snapshot can report how audio flows at that point of a pipeline. Put
+// one wherever a count helps to diagnose a problem, such as before and
+// after a resampler.
+//
+// Counters is safe to call from any goroutine while another one reads.
+type Probe struct {
+	src  Source
+	name string
+
+	mu       *sync.Mutex
+	counters ProbeCounters
+}
+
+// NewProbe creates a Probe of src, named name in snapshots.
+func NewProbe(src Source, name string) *Probe {
+	return &Probe{src: src, name: name, mu: &sync.Mutex{}}
+}
+
+func (p *Probe) SampleRate() int    { return p.src.SampleRate() }
+func (p *Probe) Channels() int      { return p.src.Channels() }
+func (p *Probe) BufSize() int       { return p.src.BufSize() }
+func (p *Probe) Format() Format     { return FormatOf(p.src) }
+func (p *Probe) Upstream() []Source { return []Source{p.src} }
+func (p *Probe) Name() string       { return p.name }
+
+func (p *Probe) Close() error {
+	if err := p.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// Counters returns what was counted so far.
+func (p *Probe) Counters() ProbeCounters {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.counters
+}
+
+func (p *Probe) ReadSamples(dst []float32) (int, error) {
+	n, err := p.src.ReadSamples(dst)
+
+	p.mu.Lock()
+	c := &p.counters
+	c.Reads++
+	c.Samples += int64(n)
+	switch {
+	case errors.Is(err, io.EOF):
+		c.EOF = true
+	case err != nil:
+		c.Errors++
+		c.LastError = err.Error()
+	case n == 0:
+		c.Empty++
+	}
+	p.mu.Unlock()
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, err
+}
+
+// Node is a source of a pipeline in a Topology.
+type Node struct {
+	// ID numbers the node within its Topology.
+	ID int `json:"id"`
+	// Type is the Go type of the source, such as "*audio.Resampler".
+	Type string `json:"type"`
+	// Name is the name of a Probe.
+	Name string `json:"name,omitempty"`
+	// Format describes the audio the source returns.
+	Format   string `json:"format"`
+	Rate     int    `json:"rate"`
+	Channels int    `json:"channels"`
+	BufSize  int    `json:"buf_size"`
+	// Counters are those of a Probe.
+	Counters *ProbeCounters `json:"counters,omitempty"`
+	// Upstream are the IDs of the nodes the source reads, in order.
+	Upstream []int `json:"upstream,omitempty"`
+}
+
+// Topology is the graph of the sources of a pipeline, as taken by
+// Snapshot. It encodes to JSON as is, and to Graphviz with WriteDOT, so a
+// single file describes the pipeline a problem was seen in.
+type Topology struct {
+	// Nodes are the sources, the one Snapshot was given first.
+	Nodes []Node `json:"nodes"`
+}
+
+// Snapshot returns the topology of the pipeline ending in src: src and,
+// through Upstreamer, every source it reads, down to the inputs. Sources
+// read by several others, such as the source of a ChannelSplitter, appear
+// once.
+//
+// Snapshot reads the state of the stages without locking them, so take
+// it from the goroutine that reads src, or while reading is paused; the
+// counters of a Probe are safe to read at any time.
+func Snapshot(src Source) Topology {
+	var t Topology
+	ids := make(map[Source]int)
+	var visit func(Source) int
+	visit = func(src Source) int {
+		canKey := reflect.TypeOf(src).Comparable()
+		if canKey {
+			if id, ok := ids[src]; ok {
+				return id
+			}
+		}
+
+		id := len(t.Nodes)
+		if canKey {
+			ids[src] = id
+		}
+		format := FormatOf(src)
+		t.Nodes = append(t.Nodes, Node{
+			ID:       id,
+			Type:     fmt.Sprintf("%T", src),
+			Format:   format.String(),
+			Rate:     format.Rate,
+			Channels: format.Channels,
+			BufSize:  src.BufSize(),
+		})
+		if p, ok := src.(*Probe); ok {
+			counters := p.Counters()
+			t.Nodes[id].Name = p.Name()
+			t.Nodes[id].Counters = &counters
+		}
+
+		if u, ok := src.(Upstreamer); ok {
+			var upstream []int
+			for _, s := range u.Upstream() {
+				if s != nil {
+					upstream = append(upstream, visit(s))
+				}
+			}
+			t.Nodes[id].Upstream = upstream
+		}
+		return id
+	}
+
+	visit(src)
+	return t
+}
+
+// WriteDOT writes the topology to w as a Graphviz digraph, with arrows in
+// the direction audio flows.
+func (t Topology) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph pipeline {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	for _, n := range t.Nodes {
+		label := []string{n.Type}
+		if n.Name != "" {
+			label[0] = n.Name + " (" + n.Type + ")"
+		}
+		label = append(label, n.Format, fmt.Sprintf("buf %d", n.BufSize))
+		if c := n.Counters; c != nil {
+			label = append(label, fmt.Sprintf("reads %d, samples %d, empty %d, errors %d", c.Reads, c.Samples, c.Empty, c.Errors))
+			if c.LastError != "" {
+				label = append(label, "last error: "+c.LastError)
+			}
+			if c.EOF {
+				label = append(label, "EOF")
+			}
+		}
+		fmt.Fprintf(bw, "\tn%d [label=%s];\n", n.ID, dotQuote(strings.Join(label, "\n")))
+	}
+	for _, n := range t.Nodes {
+		for _, u := range n.Upstream {
+			fmt.Fprintf(bw, "\tn%d -> n%d;\n", u, n.ID)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// dotQuote quotes s as a DOT string
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
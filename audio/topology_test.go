@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestProbe_Counters(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewFaultySource(newConstantSource(8000, 1, 1000, 0.5), audiotest.WithShortReads(100), audiotest.WithErrorOnCall(3, audiotest.ErrInjected))
+	p := NewProbe(src, "line")
+	buf := make([]float32, 256)
+	for range 2 {
+		if _, err := p.ReadSamples(buf); err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+	if _, err := p.ReadSamples(buf); !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	readAll(t, p, 256)
+
+	c := p.Counters()
+	if c.Samples != 1000 || c.Errors != 1 || !c.EOF || !strings.Contains(c.LastError, "injected") {
+		t.Fatalf("Counters() = %+v", c)
+	}
+	if p.Name() != "line" {
+		t.Errorf("Name() = %q, want line", p.Name())
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	stereo := NewProbe(newConstantSource(16000, 2, 1000, 0.25), "caller")
+	legs := Split(stereo)
+	left := NewResampler(legs[0], 8000)
+	right := NewGain(NewResampler(legs[1], 8000), 0.5)
+	mix, err := NewMixer(left, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := NewProbe(mix, "out")
+	buf := make([]float32, 80)
+	if _, err := out.ReadSamples(buf); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+
+	topo := Snapshot(out)
+	types := make([]string, len(topo.Nodes))
+	for i, n := range topo.Nodes {
+		types[i] = n.Type
+	}
+	want := []string{"*audio.Probe", "*audio.Mixer", "*audio.Resampler", "*audio.splitChannel", "*audio.Probe", "*audiotest.MockSource", "*audio.Gain", "*audio.Resampler", "*audio.splitChannel"}
+	if !slices.Equal(types, want) {
+		t.Fatalf("node types = %v, want %v", types, want)
+	}
+
+	// The stereo probe is shared by both channels of the splitter
+	if got := topo.Nodes[8].Upstream; !slices.Equal(got, []int{4}) {
+		t.Errorf("right channel upstream = %v, want [4]", got)
+	}
+	if n := topo.Nodes[4]; n.Name != "caller" || n.Counters == nil || n.Counters.Reads == 0 || n.Rate != 16000 || n.Channels != 2 {
+		t.Errorf("caller node = %+v", n)
+	}
+	if n := topo.Nodes[0]; n.Counters.Samples != 80 || n.Format != "8000 Hz 1ch mono f32" {
+		t.Errorf("out node = %+v, counters %+v", n, *n.Counters)
+	}
+
+	b, err := json.Marshal(topo)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var back Topology
+	if err := json.Unmarshal(b, &back); err != nil || len(back.Nodes) != len(topo.Nodes) || back.Nodes[0].Name != "out" {
+		t.Fatalf("JSON round trip = %+v, %v", back, err)
+	}
+
+	var dot bytes.Buffer
+	if err := topo.WriteDOT(&dot); err != nil {
+		t.Fatalf("WriteDOT() error = %v", err)
+	}
+	for _, s := range []string{"digraph pipeline {", `n0 [label="out (*audio.Probe)\n8000 Hz 1ch mono f32`, "n4 -> n3;", "n4 -> n8;", "n1 -> n0;"} {
+		if !strings.Contains(dot.String(), s) {
+			t.Errorf("WriteDOT() lacks %q:\n%s", s, dot.String())
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTriggerWindow is the analysis window of a Trigger when
+// TriggerOptions.Window is zero.
+const DefaultTriggerWindow = 20 * time.Millisecond
+
+// TriggerOptions configures a Trigger.
+type TriggerOptions struct {
+	// Threshold is the RMS level, in [0, 1] over all channels of a window,
+	// at or above which the trigger fires. About 0.03 (-30 dBFS) separates
+	// speech from a quiet line. Zero disables the energy trigger, leaving
+	// only Fire (e.g. from a wake-word detector).
+	Threshold float32
+	// Window is the duration over which the level is measured. Zero means
+	// DefaultTriggerWindow.
+	Window time.Duration
+	// PreRoll is how much audio from before the firing window is passed on,
+	// so the onset of speech is not lost. Zero passes none.
+	PreRoll time.Duration
+}
+
+// Trigger is a gate for always-on lines: it consumes its source without
+// passing anything on until it fires, then passes the last PreRoll of
+// audio before the trigger followed by everything after it.
+//
+// It fires when a window reaches the energy threshold or after Fire was
+// called, and stays open until Reset. While closed, ReadSamples keeps
+// reading the source (blocking as long as the source does) and returns
+// io.EOF if the source ends without the trigger firing.
+type Trigger struct {
+	src       Source
+	channels  int
+	threshold float32
+
+	window []float32 // one analysis window, interleaved
+	ring   []float32 // pre-roll ring buffer, interleaved
+	ringAt int
+	full   bool
+
+	fire    atomic.Bool
+	reset   atomic.Bool
+	open    bool
+	pending []float32 // pre-roll and firing window not yet read
+	eof     bool      // the source ended while filling the firing window
+	skipped int64     // frames discarded while closed
+}
+
+// NewTrigger creates a closed Trigger over src.
+func NewTrigger(src Source, opts TriggerOptions) *Trigger {
+	channels := max(src.Channels(), 1)
+	window := opts.Window
+	if window <= 0 {
+		window = DefaultTriggerWindow
+	}
+
+	return &Trigger{
+		src:       src,
+		channels:  channels,
+		threshold: opts.Threshold,
+		window:    make([]float32, max(FrameLen(src.SampleRate(), window), 1)*channels),
+		ring:      make([]float32, max(FrameLen(src.SampleRate(), opts.PreRoll), 0)*channels),
+	}
+}
+
+func (t *Trigger) SampleRate() int    { return t.src.SampleRate() }
+func (t *Trigger) Channels() int      { return t.src.Channels() }
+func (t *Trigger) BufSize() int       { return t.src.BufSize() }
+func (t *Trigger) Format() Format     { return FormatOf(t.src) }
+func (t *Trigger) Upstream() []Source { return []Source{t.src} }
+
+func (t *Trigger) Close() error {
+	err := t.src.Close()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// Fire opens the gate at the next window, as if the threshold was reached.
+// It is safe to call from any goroutine.
+func (t *Trigger) Fire() { t.fire.Store(true) }
+
+// Reset closes the gate again before the next read, discarding the pre-roll
+// collected so far. It is safe to call from any goroutine.
+func (t *Trigger) Reset() { t.reset.Store(true) }
+
+// Open reports whether the gate was open at the last read.
+func (t *Trigger) Open() bool { return t.open }
+
+// Skipped returns the number of sample frames of the source that were
+// discarded while the gate was closed. A position in the passed audio
+// plus Skipped is the position in the source.
+func (t *Trigger) Skipped() int64 { return t.skipped }
+
+// ReadSamples fills dst with gated interleaved samples.
+// dst length should be a multiple of t.Channels().
+func (t *Trigger) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%t.channels != 0 {
+		return 0, ErrInvalidDstSize
+	}
+
+	if t.reset.Swap(false) {
+		t.open = false
+		t.fire.Store(false)
+		t.skipped += int64(len(t.pending) / t.channels)
+		t.pending = nil
+		t.ringAt, t.full = 0, false
+	}
+
+	if !t.open {
+		if err := t.wait(); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(t.pending) > 0 {
+		n := copy(dst, t.pending)
+		t.pending = t.pending[n:]
+		return n, nil
+	}
+	if t.eof {
+		return 0, io.EOF
+	}
+
+	n, err := t.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, err
+}
+
+// wait consumes the source window by window until the trigger fires, then
+// queues the pre-roll and the firing window
+func (t *Trigger) wait() error {
+	var consumed int64
+	for {
+		n, eof, err := t.fillWindow()
+		if err != nil {
+			t.skipped += consumed
+			return err
+		}
+		t.eof = eof
+		consumed += int64(n / t.channels)
+
+		win := t.window[:n]
+		if n > 0 && (t.fire.Swap(false) || (t.threshold > 0 && windowRMS(win) >= t.threshold)) {
+			t.open = true
+			t.pending = append(t.preRoll(), win...)
+			t.skipped += consumed - int64(len(t.pending)/t.channels)
+			return nil
+		}
+
+		t.push(win)
+		if eof {
+			t.skipped += consumed
+			return io.EOF
+		}
+	}
+}
+
+// fillWindow reads up to one analysis window of whole frames
+func (t *Trigger) fillWindow() (int, bool, error) {
+	fill, eof := 0, false
+	for fill < len(t.window) && !eof {
+		n, err := t.src.ReadSamples(t.window[fill:])
+		fill += n
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, false, fmt.Errorf("%w", err)
+		}
+		eof = err != nil || n == 0
+	}
+	return fill - fill%t.channels, eof, nil
+}
+
+// push appends win to the pre-roll ring
+func (t *Trigger) push(win []float32) {
+	if len(t.ring) == 0 {
+		return
+	}
+	for len(win) > 0 {
+		n := copy(t.ring[t.ringAt:], win)
+		win = win[n:]
+		t.ringAt += n
+		if t.ringAt == len(t.ring) {
+			t.ringAt, t.full = 0, true
+		}
+	}
+}
+
+// preRoll returns the ring contents oldest first and empties the ring
+func (t *Trigger) preRoll() []float32 {
+	var out []float32
+	if t.full {
+		out = append(out, t.ring[t.ringAt:]...)
+	}
+	out = append(out, t.ring[:t.ringAt]...)
+	t.ringAt, t.full = 0, false
+	return out
+}
+
+func windowRMS(x []float32) float32 {
+	var p float64
+	for _, v := range x {
+		p += float64(v) * float64(v)
+	}
+	return float32(math.Sqrt(p / float64(len(x))))
+}
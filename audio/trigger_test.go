@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// triggerSource is 8 kHz mono with a quiet ramp encoding the frame index
+// (below 0.01) outside of [burstStart, burstEnd), where it is loud
+func triggerSource(total int, bursts ...[2]int) Source {
+	return newMockSource(8000, 1, total, func(sample, _ int) float32 {
+		for _, b := range bursts {
+			if sample >= b[0] && sample < b[1] {
+				return 0.5
+			}
+		}
+		return float32(sample) * 1e-6
+	})
+}
+
+// readAllTrigger drains tr, failing on errors other than io.EOF
+func readAllTrigger(t *testing.T, tr *Trigger, bufLen int) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, bufLen)
+	for {
+		n, err := tr.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		opts        TriggerOptions
+		fire        bool
+		bursts      [][2]int
+		wantSkipped int64
+	}{
+		// The burst starts in the 51st 160 frame window, which opens the
+		// gate; 100 ms (800 frames) before that window are passed on
+		{
+			name:        "energy with pre-roll",
+			opts:        TriggerOptions{Threshold: 0.1, PreRoll: 100 * time.Millisecond},
+			bursts:      [][2]int{{8100, 9000}},
+			wantSkipped: 8000 - 800,
+		},
+		{
+			name:        "energy without pre-roll",
+			opts:        TriggerOptions{Threshold: 0.1},
+			bursts:      [][2]int{{8100, 9000}},
+			wantSkipped: 8000,
+		},
+		{
+			name:        "pre-roll longer than the silence",
+			opts:        TriggerOptions{Threshold: 0.1, PreRoll: time.Second},
+			bursts:      [][2]int{{500, 9000}},
+			wantSkipped: 0,
+		},
+		{
+			name:        "external fire",
+			opts:        TriggerOptions{PreRoll: 100 * time.Millisecond},
+			fire:        true,
+			wantSkipped: 0,
+		},
+		{
+			name:        "longer window",
+			opts:        TriggerOptions{Threshold: 0.1, Window: 100 * time.Millisecond, PreRoll: 50 * time.Millisecond},
+			bursts:      [][2]int{{8000, 16000}},
+			wantSkipped: 8000 - 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			const total = 16000
+			tr := NewTrigger(triggerSource(total, tt.bursts...), tt.opts)
+			if tt.fire {
+				tr.Fire()
+			}
+
+			out := readAllTrigger(t, tr, 333)
+			if !tr.Open() {
+				t.Error("Open() = false after firing")
+			}
+			if tr.Skipped() != tt.wantSkipped {
+				t.Errorf("Skipped() = %d, want %d", tr.Skipped(), tt.wantSkipped)
+			}
+			if want := total - int(tt.wantSkipped); len(out) != want {
+				t.Fatalf("passed %d frames, want %d", len(out), want)
+			}
+
+			// The passed audio is the source from Skipped on, without gaps
+			src := triggerSource(total, tt.bursts...)
+			ref := make([]float32, total)
+			_, _ = src.ReadSamples(ref)
+			for i, v := range out {
+				if want := ref[int(tt.wantSkipped)+i]; v != want {
+					t.Fatalf("sample %d = %v, want %v", i, v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrigger_NeverFires(t *testing.T) {
+	t.Parallel()
+
+	tr := NewTrigger(triggerSource(8000), TriggerOptions{Threshold: 0.1, PreRoll: 200 * time.Millisecond})
+	n, err := tr.ReadSamples(make([]float32, 160))
+	if n != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("ReadSamples() = %d, %v, want 0, io.EOF", n, err)
+	}
+	if tr.Open() || tr.Skipped() != 8000 {
+		t.Errorf("Open() = %v, Skipped() = %d, want false, 8000", tr.Open(), tr.Skipped())
+	}
+}
+
+func TestTrigger_Reset(t *testing.T) {
+	t.Parallel()
+
+	// Two bursts separated by a second of quiet
+	tr := NewTrigger(triggerSource(32000, [2]int{8000, 9600}, [2]int{24000, 25600}), TriggerOptions{Threshold: 0.1})
+
+	// readBurst reads 1600 frames, which must all be loud
+	readBurst := func(name string) {
+		t.Helper()
+		buf := make([]float32, 400)
+		for got := 0; got < 1600; {
+			n, err := tr.ReadSamples(buf[:min(len(buf), 1600-got)])
+			if err != nil {
+				t.Fatalf("%s: ReadSamples() error = %v", name, err)
+			}
+			for i, v := range buf[:n] {
+				if v != 0.5 {
+					t.Fatalf("%s: frame %d = %v, want 0.5", name, got+i, v)
+				}
+			}
+			got += n
+		}
+	}
+
+	// Read the first burst, then rearm
+	readBurst("first burst")
+	tr.Reset()
+	readBurst("second burst")
+
+	if tr.Skipped() != 8000+14400 {
+		t.Errorf("Skipped() = %d, want %d", tr.Skipped(), 8000+14400)
+	}
+}
+
+func TestTrigger_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("dst size", func(t *testing.T) {
+		t.Parallel()
+
+		tr := NewTrigger(newSilentSource(8000, 2, 100), TriggerOptions{})
+		if _, err := tr.ReadSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidDstSize) {
+			t.Errorf("ReadSamples() error = %v, want ErrInvalidDstSize", err)
+		}
+	})
+
+	t.Run("source error while closed", func(t *testing.T) {
+		t.Parallel()
+
+		src := audiotest.NewFaultySource(triggerSource(8000), audiotest.WithErrorOnCall(3, audiotest.ErrInjected))
+		tr := NewTrigger(src, TriggerOptions{Threshold: 0.1})
+		if _, err := tr.ReadSamples(make([]float32, 160)); !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("ReadSamples() error = %v, want %v", err, audiotest.ErrInjected)
+		}
+	})
+
+	t.Run("short reads", func(t *testing.T) {
+		t.Parallel()
+
+		src := audiotest.NewFaultySource(triggerSource(8000, [2]int{4000, 8000}), audiotest.WithShortReads(7))
+		tr := NewTrigger(src, TriggerOptions{Threshold: 0.1, PreRoll: 10 * time.Millisecond})
+		if out := readAllTrigger(t, tr, 64); len(out) != 4000+80 {
+			t.Errorf("passed %d frames, want %d", len(out), 4000+80)
+		}
+	})
+}
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Defaults of VADOptions.
+const (
+	DefaultVADThreshold     = 0.03
+	DefaultVADZeroCrossings = 0.35
+	DefaultVADMinSpeech     = 100 * time.Millisecond
+	DefaultVADHangover      = 300 * time.Millisecond
+)
+
+// VADOptions configures a VAD. Zero values select the defaults.
+type VADOptions struct {
+	// Threshold is the RMS level, in [0, 1] over all channels of a window,
+	// at or above which a window may be speech. The default, 0.03 (-30
+	// dBFS), separates speech from a quiet line.
+	Threshold float32
+	// ZeroCrossings is the share of samples, in (0, 1], at which the
+	// signal may change sign in a window of speech: voiced speech crosses
+	// zero far less often than hiss and static, which cross at about half
+	// the samples. Loud windows crossing more often are noise. The default
+	// is 0.35; 1 disables the check.
+	ZeroCrossings float64
+	// Window is the duration classified at once, DefaultTriggerWindow
+	// (20 ms) by default.
+	Window time.Duration
+	// MinSpeech is how long speech must last, without a quiet window, to
+	// start a segment, 100 ms by default, so clicks do not.
+	MinSpeech time.Duration
+	// Hangover is how long quiet must last to end a segment, 300 ms by
+	// default, so the pauses between words do not.
+	Hangover time.Duration
+	// OnSegment, when set, is called from ReadSamples with each segment of
+	// speech as it ends, and from Close with the one still open.
+	OnSegment func(SpeechSegment)
+}
+
+// SpeechSegment is a stretch of speech found by a VAD, in sample frames
+// from the start of the stream: Start is its first frame and End the frame
+// after its last one.
+type SpeechSegment struct {
+	Start int64
+	End   int64
+}
+
+// Duration returns the length of the segment at rate.
+func (s SpeechSegment) Duration(rate int) time.Duration {
+	return FramesDuration(s.End-s.Start, rate)
+}
+
+// VAD passes audio through unchanged while classifying it, window by
+// window, as speech or not, from its energy and zero-crossing rate. It
+// groups speech into segments, from which recordings can be trimmed of
+// silence and the talk time of a call measured.
+//
+// Segments are reported by OnSegment as they end and kept for Segments.
+// Speaking and Segments are safe to call from any goroutine while another
+// one reads.
+type VAD struct {
+	src           Source
+	rate          int
+	channels      int
+	threshold     float32
+	zeroCrossings float64
+	window        int // sample frames
+	minSpeech     int64
+	hangover      int64
+	onSegment     func(SpeechSegment)
+
+	// Window being measured
+	sumSq    float64
+	crosses  int
+	prev     float32
+	count    int
+	position int64 // frames read
+
+	run   int64 // frames of speech in a row, before a segment starts
+	quiet int64 // frames of quiet in a row, in a segment
+
+	mu       *sync.Mutex
+	speaking bool
+	current  SpeechSegment // open segment while speaking
+	segments []SpeechSegment
+}
+
+// NewVAD creates a VAD over src.
+func NewVAD(src Source, opts VADOptions) *VAD {
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultVADThreshold
+	}
+	if opts.ZeroCrossings <= 0 {
+		opts.ZeroCrossings = DefaultVADZeroCrossings
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultTriggerWindow
+	}
+	if opts.MinSpeech <= 0 {
+		opts.MinSpeech = DefaultVADMinSpeech
+	}
+	if opts.Hangover <= 0 {
+		opts.Hangover = DefaultVADHangover
+	}
+
+	rate := src.SampleRate()
+	return &VAD{
+		src:           src,
+		rate:          rate,
+		channels:      max(src.Channels(), 1),
+		threshold:     opts.Threshold,
+		zeroCrossings: opts.ZeroCrossings,
+		window:        max(FrameLen(rate, opts.Window), 1),
+		minSpeech:     int64(FrameLen(rate, opts.MinSpeech)),
+		hangover:      int64(FrameLen(rate, opts.Hangover)),
+		onSegment:     opts.OnSegment,
+		mu:            &sync.Mutex{},
+	}
+}
+
+// DetectSpeech reads src to the end and returns its segments of speech. It
+// does not close src.
+func DetectSpeech(src Source, opts VADOptions) ([]SpeechSegment, error) {
+	v := NewVAD(src, opts)
+	buf := make([]float32, max(src.BufSize()-src.BufSize()%v.channels, v.channels))
+	for {
+		n, err := v.ReadSamples(buf)
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	v.finish()
+	return v.Segments(), nil
+}
+
+func (v *VAD) SampleRate() int    { return v.src.SampleRate() }
+func (v *VAD) Channels() int      { return v.src.Channels() }
+func (v *VAD) BufSize() int       { return v.src.BufSize() }
+func (v *VAD) Format() Format     { return FormatOf(v.src) }
+func (v *VAD) Upstream() []Source { return []Source{v.src} }
+
+// Close ends the open segment, if any, and closes the source.
+func (v *VAD) Close() error {
+	v.finish()
+	if err := v.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// Speaking reports whether a segment of speech is open.
+func (v *VAD) Speaking() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.speaking
+}
+
+// Segments returns the segments of speech that have ended so far.
+func (v *VAD) Segments() []SpeechSegment {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return append([]SpeechSegment(nil), v.segments...)
+}
+
+// ReadSamples passes the audio through, classifying it as it goes. The
+// open segment is ended when the source ends.
+func (v *VAD) ReadSamples(dst []float32) (int, error) {
+	n, err := v.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	if n > 0 {
+		v.measure(dst[:n-n%v.channels])
+	}
+	if errors.Is(err, io.EOF) {
+		v.finish()
+	}
+	return n, err
+}
+
+// measure classifies frames window by window
+func (v *VAD) measure(samples []float32) {
+	scale := 1 / float32(v.channels)
+	for f := 0; f < len(samples); f += v.channels {
+		var mix float32
+		for _, s := range samples[f : f+v.channels] {
+			v.sumSq += float64(s) * float64(s)
+			mix += s
+		}
+		mix *= scale
+		if (mix < 0) != (v.prev < 0) && v.count > 0 {
+			v.crosses++
+		}
+		v.prev = mix
+		v.count++
+		if v.count < v.window {
+			continue
+		}
+
+		level := float32(math.Sqrt(v.sumSq / float64(v.count*v.channels)))
+		rate := float64(v.crosses) / float64(v.count)
+		v.sumSq, v.crosses = 0, 0
+		v.position += int64(v.count)
+		v.classify(int64(v.count), level >= v.threshold && rate <= v.zeroCrossings)
+		v.count = 0
+	}
+}
+
+// classify counts a window of frames ending at v.position as speech or
+// not, opening and ending segments
+func (v *VAD) classify(frames int64, speech bool) {
+	v.mu.Lock()
+	var ended *SpeechSegment
+	switch {
+	case !v.speaking && speech:
+		v.run += frames
+		if v.run >= v.minSpeech {
+			v.speaking = true
+			v.current = SpeechSegment{Start: v.position - v.run, End: v.position}
+			v.run = 0
+		}
+	case !v.speaking:
+		v.run = 0
+	case speech:
+		v.quiet = 0
+		v.current.End = v.position
+	default:
+		v.quiet += frames
+		if v.quiet >= v.hangover {
+			ended = v.end()
+		}
+	}
+	v.mu.Unlock()
+
+	if ended != nil && v.onSegment != nil {
+		v.onSegment(*ended)
+	}
+}
+
+// end closes the open segment; v.mu must be held
+func (v *VAD) end() *SpeechSegment {
+	seg := v.current
+	v.segments = append(v.segments, seg)
+	v.speaking, v.quiet = false, 0
+	return &seg
+}
+
+// finish ends the segment still open at the end of the stream
+func (v *VAD) finish() {
+	v.mu.Lock()
+	var ended *SpeechSegment
+	if v.speaking {
+		ended = v.end()
+	}
+	v.mu.Unlock()
+
+	if ended != nil && v.onSegment != nil {
+		v.onSegment(*ended)
+	}
+}
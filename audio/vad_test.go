@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audio
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// bursts returns a waveform of 200 Hz tone bursts at 8 kHz over the given
+// frame ranges, silent elsewhere
+func bursts(ranges ...[2]int) func(int, int) float32 {
+	return func(i, _ int) float32 {
+		for _, r := range ranges {
+			if i >= r[0] && i < r[1] {
+				return float32(0.5 * math.Sin(2*math.Pi*200*float64(i)/8000))
+			}
+		}
+		return 0
+	}
+}
+
+func TestDetectSpeech(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		channels int
+		total    int
+		wave     func(int, int) float32
+		opts     VADOptions
+		want     []SpeechSegment
+	}{
+		{
+			name:  "one segment",
+			total: 20000,
+			wave:  bursts([2]int{4000, 12000}),
+			want:  []SpeechSegment{{4000, 12000}},
+		},
+		{
+			name:     "stereo",
+			channels: 2,
+			total:    20000,
+			wave:     bursts([2]int{4000, 12000}),
+			want:     []SpeechSegment{{4000, 12000}},
+		},
+		{
+			name:  "click",
+			total: 8000,
+			wave:  bursts([2]int{1600, 2000}),
+		},
+		{
+			name:  "pause between words",
+			total: 16000,
+			wave:  bursts([2]int{1600, 4800}, [2]int{6400, 9600}),
+			want:  []SpeechSegment{{1600, 9600}},
+		},
+		{
+			name:  "two sentences",
+			total: 16000,
+			wave:  bursts([2]int{1600, 4800}, [2]int{9600, 12800}),
+			want:  []SpeechSegment{{1600, 4800}, {9600, 12800}},
+		},
+		{
+			name:  "until the end",
+			total: 8000,
+			wave:  bursts([2]int{3200, 8000}),
+			want:  []SpeechSegment{{3200, 8000}},
+		},
+		{
+			name:  "hiss",
+			total: 8000,
+			wave: func(i, _ int) float32 {
+				return float32(0.2 * float64(1-2*(i%2)))
+			},
+		},
+		{
+			name:  "hiss without zero-crossing check",
+			total: 8000,
+			wave: func(i, _ int) float32 {
+				return float32(0.2 * float64(1-2*(i%2)))
+			},
+			opts: VADOptions{ZeroCrossings: 1},
+			want: []SpeechSegment{{0, 8000}},
+		},
+		{
+			name:  "quiet tone",
+			total: 8000,
+			wave:  func(i, c int) float32 { return 0.1 * bursts([2]int{0, 8000})(i, c) },
+			opts:  VADOptions{Threshold: 0.1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := DetectSpeech(newMockSource(8000, max(tt.channels, 1), tt.total, tt.wave), tt.opts)
+			if err != nil {
+				t.Fatalf("DetectSpeech() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("DetectSpeech() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVAD_OnSegment(t *testing.T) {
+	t.Parallel()
+
+	var got []SpeechSegment
+	src := newMockSource(8000, 1, 16000, bursts([2]int{1600, 4800}, [2]int{9600, 16000}))
+	v := NewVAD(src, VADOptions{OnSegment: func(s SpeechSegment) { got = append(got, s) }})
+
+	buf := make([]float32, 800)
+	for range 14 {
+		if _, err := v.ReadSamples(buf); err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+	// 1.4 s read: the first sentence ended, the second is open
+	if !v.Speaking() || len(got) != 1 || got[0] != (SpeechSegment{1600, 4800}) {
+		t.Fatalf("after 1.4 s: speaking = %v, segments = %v", v.Speaking(), got)
+	}
+	if d := got[0].Duration(8000); d != 400*time.Millisecond {
+		t.Errorf("Duration() = %v, want 400ms", d)
+	}
+
+	if err := v.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if v.Speaking() || len(got) != 2 || got[1].Start != 9600 {
+		t.Fatalf("after Close: speaking = %v, segments = %v", v.Speaking(), got)
+	}
+	if segs := v.Segments(); !slices.Equal(segs, got) {
+		t.Errorf("Segments() = %v, want %v", segs, got)
+	}
+}
+
+func TestVAD_Passthrough(t *testing.T) {
+	t.Parallel()
+
+	wave := bursts([2]int{100, 900})
+	out := readAll(t, NewVAD(newMockSource(8000, 2, 1000, wave), VADOptions{}), 300)
+	if len(out) != 2000 {
+		t.Fatalf("read %d samples, want 2000", len(out))
+	}
+	for i, v := range out {
+		if want := wave(i/2, i%2); v != want {
+			t.Fatalf("sample %d = %v, want %v", i, v, want)
+		}
+	}
+
+	src := audiotest.NewFaultySource(newSilentSource(8000, 1, 10000), audiotest.WithErrorOnCall(2, audiotest.ErrInjected))
+	if _, err := DetectSpeech(src, VADOptions{}); !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("DetectSpeech() error = %v, want ErrInjected", err)
+	}
+}
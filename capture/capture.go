@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Defaults of Options
+const (
+	DefaultRate     = 8000
+	DefaultChannels = 1
+	DefaultBuffer   = 100 * time.Millisecond
+)
+
+// Device is a sound input a Source reads, such as a microphone.
+//
+// Open starts an input of interleaved 16-bit signed little-endian samples
+// in format f, buffering about buffer frames. Reads from it block until
+// the input has audio; Close stops it, and ends a read blocked on it.
+type Device interface {
+	Open(f audio.Format, buffer int) (io.ReadCloser, error)
+}
+
+// Options configures a Source.
+type Options struct {
+	// Format is the rate and channel count to capture at; the device
+	// converts to it. A zero Rate selects DefaultRate and zero Channels
+	// DefaultChannels.
+	Format audio.Format
+
+	// Device is the input. Defaults to Arecord(""), the default ALSA
+	// input.
+	Device Device
+
+	// Buffer is the audio the device buffers, which bounds how late a
+	// read returns it. Defaults to DefaultBuffer.
+	Buffer time.Duration
+}
+
+// Source is a live audio.Source of a sound input.
+type Source struct {
+	in     io.ReadCloser
+	format audio.Format
+	frames int // buffer, in frames
+	raw    []byte
+	carry  int // bytes of a partial frame at the start of raw
+
+	mu     *sync.Mutex
+	closed bool
+}
+
+// Open starts capturing and returns a source of the input.
+func Open(opts Options) (*Source, error) {
+	format := audio.Format{Rate: opts.Format.Rate, Channels: opts.Format.Channels}
+	if format.Rate == 0 {
+		format.Rate = DefaultRate
+	}
+	if format.Channels == 0 {
+		format.Channels = DefaultChannels
+	}
+	format.Layout = audio.DefaultLayout(format.Channels)
+	format.SampleKind = audio.SampleInt16
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if opts.Device == nil {
+		opts.Device = Arecord("")
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+
+	frames := max(audio.FrameLen(format.Rate, opts.Buffer), 1)
+	in, err := opts.Device.Open(format, frames)
+	if err != nil {
+		return nil, fmt.Errorf("opening device: %w", err)
+	}
+	return &Source{in: in, format: format, frames: frames, mu: &sync.Mutex{}}, nil
+}
+
+func (s *Source) SampleRate() int      { return s.format.Rate }
+func (s *Source) Channels() int        { return s.format.Channels }
+func (s *Source) BufSize() int         { return s.frames * s.format.Channels }
+func (s *Source) Format() audio.Format { return s.format }
+
+// Close stops the input. It is safe to call from another goroutine than
+// the one reading, and more than once.
+func (s *Source) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if err := s.in.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// ReadSamples waits for at least one frame of the input, and reads what
+// there is of it into dst, in whole frames. It returns io.EOF once the
+// source is closed or the input ends.
+func (s *Source) ReadSamples(dst []float32) (int, error) {
+	ch := s.format.Channels
+	if len(dst) < ch {
+		return 0, audio.ErrInvalidDstSize
+	}
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return 0, io.EOF
+	}
+
+	frameSize := 2 * ch
+	want := len(dst) / ch * frameSize
+	if cap(s.raw) < want {
+		s.raw = append(make([]byte, 0, want), s.raw[:s.carry]...)
+	}
+	raw := s.raw[:want]
+
+	m, err := io.ReadAtLeast(s.in, raw[s.carry:], frameSize-s.carry)
+	m += s.carry
+	n := m / frameSize * ch
+	for i := range n {
+		dst[i] = float32(int16(binary.LittleEndian.Uint16(raw[2*i:]))) / 32768
+	}
+	s.carry = copy(raw, raw[n*2:m])
+
+	if err != nil {
+		s.mu.Lock()
+		closed := s.closed
+		s.mu.Unlock()
+		// A partial frame at the end is dropped
+		if closed || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return n, io.EOF
+		}
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// fakeDevice plays back PCM as an input
+type fakeDevice struct {
+	r       io.Reader
+	openErr error
+	format  audio.Format
+	buffer  int
+	closed  bool
+}
+
+func (d *fakeDevice) Open(f audio.Format, buffer int) (io.ReadCloser, error) {
+	if d.openErr != nil {
+		return nil, d.openErr
+	}
+	d.format, d.buffer = f, buffer
+	return d, nil
+}
+
+func (d *fakeDevice) Read(p []byte) (int, error) { return d.r.Read(p) }
+
+func (d *fakeDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+// pcm16 renders samples as 16-bit little-endian PCM
+func pcm16(samples ...int16) []byte {
+	var b []byte
+	for _, s := range samples {
+		b = binary.LittleEndian.AppendUint16(b, uint16(s))
+	}
+	return b
+}
+
+// readAll reads src to its end, chunk samples at a time
+func readAll(t *testing.T, src audio.Source, chunk int) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, chunk)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	samples := []int16{16384, -16384, 8192, -8192, 0, 32767, -32768, 1}
+	want := make([]float32, len(samples))
+	for i, s := range samples {
+		want[i] = float32(s) / 32768
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		r        func(io.Reader) io.Reader
+		data     []byte
+		chunk    int
+		want     []float32
+		format   audio.Format
+		frameBuf int
+	}{
+		{
+			name: "defaults", chunk: 4, data: pcm16(samples...), want: want,
+			format: audio.Format{Rate: 8000, Channels: 1}, frameBuf: 800,
+		},
+		{
+			name: "stereo, byte by byte", opts: Options{Format: audio.Format{Rate: 16000, Channels: 2}, Buffer: 20 * time.Millisecond},
+			r: iotest.OneByteReader, chunk: 6, data: pcm16(samples...), want: want,
+			format: audio.Format{Rate: 16000, Channels: 2}, frameBuf: 320,
+		},
+		{
+			name: "partial frame at the end", opts: Options{Format: audio.Format{Channels: 2}},
+			r: iotest.HalfReader, chunk: 2, data: pcm16(samples[:3]...), want: want[:2],
+			format: audio.Format{Rate: 8000, Channels: 2}, frameBuf: 800,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r io.Reader = bytes.NewReader(tt.data)
+			if tt.r != nil {
+				r = tt.r(r)
+			}
+			dev := &fakeDevice{r: r}
+			tt.opts.Device = dev
+			src, err := Open(tt.opts)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if dev.format.Rate != tt.format.Rate || dev.format.Channels != tt.format.Channels || dev.buffer != tt.frameBuf {
+				t.Errorf("device opened for %v, %d frames", dev.format, dev.buffer)
+			}
+			if f := src.Format(); f.SampleKind != audio.SampleInt16 || f.Rate != tt.format.Rate {
+				t.Errorf("Format() = %v", f)
+			}
+
+			if got := readAll(t, src, tt.chunk); !slices.Equal(got, tt.want) {
+				t.Errorf("read %v, want %v", got, tt.want)
+			}
+			if err := src.Close(); err != nil || !dev.closed {
+				t.Errorf("Close() error = %v, device closed = %v", err, dev.closed)
+			}
+			if _, err := src.ReadSamples(make([]float32, 4)); !errors.Is(err, io.EOF) {
+				t.Errorf("ReadSamples() after Close error = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestSource_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Open(Options{Format: audio.Format{Rate: -1}, Device: &fakeDevice{}}); err == nil {
+		t.Error("Open(-1 Hz) error = nil")
+	}
+	if _, err := Open(Options{Device: &fakeDevice{openErr: audiotest.ErrInjected}}); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Open() error = %v, want ErrInjected", err)
+	}
+
+	src, err := Open(Options{Format: audio.Format{Channels: 2}, Device: &fakeDevice{r: iotest.ErrReader(audiotest.ErrInjected)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.ReadSamples(make([]float32, 1)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(1 of 2 channels) error = %v, want ErrInvalidDstSize", err)
+	}
+	if _, err := src.ReadSamples(make([]float32, 4)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+
+	// A program that ends
+	in := filepath.Join(t.TempDir(), "in.raw")
+	if err := os.WriteFile(in, pcm16(16384, -16384, 8192), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := Open(Options{Device: Command{Name: "sh", Args: func(audio.Format, int) []string {
+		return []string{"-c", "cat " + in}
+	}}})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got := readAll(t, src, 8); !slices.Equal(got, []float32{0.5, -0.5, 0.25}) {
+		t.Errorf("read %v", got)
+	}
+	if err := src.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	// A program that records until it is stopped, as arecord does
+	src, err = Open(Options{Device: Command{Name: "sh", Args: func(audio.Format, int) []string {
+		return []string{"-c", "exec cat /dev/zero"}
+	}}})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if n, err := src.ReadSamples(make([]float32, 64)); n == 0 || err != nil {
+		t.Fatalf("ReadSamples() = %d, %v", n, err)
+	}
+	done := make(chan error)
+	go func() {
+		buf := make([]float32, 64)
+		for {
+			if _, err := src.ReadSamples(buf); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	if err := src.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("ReadSamples() while closing error = %v, want io.EOF", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not end a blocked read")
+	}
+
+	if _, err := (Command{Name: "no-such-recorder"}).Open(audio.Format{Rate: 8000, Channels: 1}, 800); err == nil {
+		t.Error("Open() of a missing program error = nil")
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Command is a Device recording through a program that writes raw
+// samples to its standard output, such as arecord of ALSA or parec of
+// PulseAudio. It needs no cgo and no audio library; the program does the
+// talking to the sound system.
+type Command struct {
+	// Name is the program, looked up in PATH.
+	Name string
+
+	// Args returns the arguments telling the program the format of its
+	// output, 16-bit signed little-endian PCM, and the frames to buffer.
+	Args func(f audio.Format, buffer int) []string
+}
+
+// Arecord records from an ALSA input with arecord, such as "hw:1,0" or
+// "plughw:CARD=USB", or from the default one for "".
+func Arecord(device string) Command {
+	return Command{
+		Name: "arecord",
+		Args: func(f audio.Format, buffer int) []string {
+			args := []string{
+				"-q", "-t", "raw", "-f", "S16_LE",
+				"-r", strconv.Itoa(f.Rate),
+				"-c", strconv.Itoa(f.Channels),
+				"--buffer-size", strconv.Itoa(buffer),
+			}
+			if device != "" {
+				args = append(args, "-D", device)
+			}
+			return args
+		},
+	}
+}
+
+// Parec records from a PulseAudio or PipeWire source with parec, or from
+// the default one for "".
+func Parec(device string) Command {
+	return Command{
+		Name: "parec",
+		Args: func(f audio.Format, buffer int) []string {
+			args := []string{
+				"--raw", "--format=s16le",
+				"--rate=" + strconv.Itoa(f.Rate),
+				"--channels=" + strconv.Itoa(f.Channels),
+				"--latency=" + strconv.Itoa(buffer*f.Channels*2),
+			}
+			if device != "" {
+				args = append(args, "--device="+device)
+			}
+			return args
+		},
+	}
+}
+
+// Open starts the program, whose output is read.
+func (c Command) Open(f audio.Format, buffer int) (io.ReadCloser, error) {
+	var args []string
+	if c.Args != nil {
+		args = c.Args(f, buffer)
+	}
+	cmd := exec.Command(c.Name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &commandInput{stdout: stdout, cmd: cmd}, nil
+}
+
+// commandInput is the input of a Command
+type commandInput struct {
+	stdout io.ReadCloser
+	cmd    *exec.Cmd
+}
+
+func (i *commandInput) Read(p []byte) (int, error) {
+	n, err := i.stdout.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, err
+}
+
+// Close stops the program, which records until it is told to.
+func (i *commandInput) Close() error {
+	if err := i.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("%w", err)
+	}
+	// Killed, the program exits with an error of no interest
+	_ = i.cmd.Wait()
+	return nil
+}
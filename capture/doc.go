@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package capture reads a microphone, or any other sound input, as an
+// audio.Source, so that it can be recorded to a file or injected into a
+// call like any decoded prompt.
+//
+//	mic, err := capture.Open(capture.Options{
+//	    Format: audio.Format{Rate: 8000, Channels: 1},
+//	})
+//	w, err := wav.NewWriter(file, mic.Format())
+//	time.AfterFunc(30*time.Second, func() { mic.Close() })
+//	_, err = w.WriteSource(mic) // until mic is closed
+//
+// The source is live: ReadSamples blocks until the input has produced
+// some audio, and returns what it has rather than waiting for dst to be
+// full. It never ends on its own; Close stops the input, from any
+// goroutine, and a read blocked on it then returns io.EOF.
+//
+// # Devices
+//
+// The sound system is reached through a Device, which yields interleaved
+// 16-bit PCM. Command records through a program writing raw samples to
+// its standard output, so no cgo or audio library is needed: Arecord,
+// the default, for ALSA, and Parec for PulseAudio and PipeWire. Both take
+// the name of an input, such as "hw:1,0" or a PulseAudio source, or ""
+// for the default one. Another program, or a binding to a library such as
+// PortAudio, can be used by implementing Device.
+package capture
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Unix(1_700_000_000, 0)
+
+func TestFake_Timer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		advance time.Duration
+		fired   bool
+	}{
+		{name: "not yet due", delay: 20 * time.Millisecond, advance: 19 * time.Millisecond, fired: false},
+		{name: "exactly due", delay: 20 * time.Millisecond, advance: 20 * time.Millisecond, fired: true},
+		{name: "overdue", delay: 20 * time.Millisecond, advance: time.Second, fired: true},
+		{name: "zero delay", delay: 0, advance: 0, fired: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fc := NewFake(epoch)
+			timer := fc.NewTimer(tt.delay)
+			fc.Advance(tt.advance)
+
+			select {
+			case at := <-timer.C():
+				if !tt.fired {
+					t.Fatal("timer fired early")
+				}
+				if want := epoch.Add(tt.delay); !at.Equal(want) {
+					t.Errorf("fired at %v, want %v", at, want)
+				}
+			default:
+				if tt.fired {
+					t.Fatal("timer did not fire")
+				}
+			}
+
+			if got := fc.Now(); !got.Equal(epoch.Add(tt.advance)) {
+				t.Errorf("Now() = %v, want %v", got, epoch.Add(tt.advance))
+			}
+		})
+	}
+}
+
+func TestFake_TimerStopAndReset(t *testing.T) {
+	t.Parallel()
+
+	fc := NewFake(epoch)
+	timer := fc.NewTimer(time.Second)
+
+	if !timer.Stop() {
+		t.Error("Stop() on an active timer = false")
+	}
+	if timer.Stop() {
+		t.Error("Stop() on a stopped timer = true")
+	}
+	fc.Advance(2 * time.Second)
+	if len(timer.C()) != 0 {
+		t.Fatal("stopped timer fired")
+	}
+
+	timer.Reset(time.Second)
+	fc.Advance(time.Second)
+	if len(timer.C()) != 1 {
+		t.Fatal("reset timer did not fire")
+	}
+	if fc.Waiters() != 0 {
+		t.Errorf("Waiters() = %d after firing, want 0", fc.Waiters())
+	}
+}
+
+func TestFake_Ticker(t *testing.T) {
+	t.Parallel()
+
+	fc := NewFake(epoch)
+	ticker := fc.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	var ticks []time.Duration
+	for range 5 {
+		fc.Advance(20 * time.Millisecond)
+		at := <-ticker.C()
+		ticks = append(ticks, at.Sub(epoch))
+	}
+
+	for i, got := range ticks {
+		if want := time.Duration(i+1) * 20 * time.Millisecond; got != want {
+			t.Errorf("tick %d at %v, want %v", i, got, want)
+		}
+	}
+
+	// A slow receiver gets a single pending tick, not a backlog
+	fc.Advance(100 * time.Millisecond)
+	if n := len(ticker.C()); n != 1 {
+		t.Errorf("pending ticks = %d, want 1", n)
+	}
+}
+
+func TestFake_FiresInDeadlineOrder(t *testing.T) {
+	t.Parallel()
+
+	fc := NewFake(epoch)
+	late := fc.NewTimer(30 * time.Millisecond)
+	early := fc.NewTimer(10 * time.Millisecond)
+
+	fc.Advance(time.Second)
+	a, b := <-early.C(), <-late.C()
+	if !a.Before(b) {
+		t.Errorf("early fired at %v, late at %v", a, b)
+	}
+}
+
+func TestFake_SleepWithBlockUntil(t *testing.T) {
+	t.Parallel()
+
+	fc := NewFake(epoch)
+	done := make(chan time.Time)
+	go func() {
+		fc.Sleep(time.Minute)
+		done <- fc.Now()
+	}()
+
+	fc.BlockUntil(1)
+	fc.Advance(time.Minute)
+
+	if got := <-done; !got.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("woke at %v, want %v", got, epoch.Add(time.Minute))
+	}
+}
+
+func TestReal(t *testing.T) {
+	t.Parallel()
+
+	c := Real()
+	start := c.Now()
+	<-c.After(time.Millisecond)
+	if c.Since(start) < time.Millisecond {
+		t.Error("After returned early")
+	}
+
+	ticker := c.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+}
@@ -0,0 +1,15 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package clock abstracts the passage of time for the real-time parts of
+// audpbx, such as pacers, jitter buffers and music-on-hold engines.
+//
+// Components take a Clock instead of calling the time package directly, so
+// production code uses Real while tests drive a Fake deterministically:
+//
+//	fc := clock.NewFake(time.Unix(0, 0))
+//	ticker := fc.NewTicker(20 * time.Millisecond)
+//	fc.Advance(20 * time.Millisecond) // ticker.C() fires exactly once
+//
+// Timers and tickers of a Fake fire only from Advance or Set, in deadline
+// order, with Now set to each deadline while firing.
+package clock
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to. It is safe for concurrent
+// use, so the code under test may run in its own goroutine while the test
+// advances time.
+type Fake struct {
+	mu      *sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending timer or ticker
+type fakeWaiter struct {
+	when   time.Time
+	period time.Duration // 0 for one-shot timers
+	ch     chan time.Time
+	active bool
+}
+
+// NewFake creates a Fake clock set to start.
+func NewFake(start time.Time) *Fake {
+	mu := &sync.Mutex{}
+	return &Fake{mu: mu, cond: sync.NewCond(mu), now: start}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep blocks until another goroutine advances the clock by d.
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	w := &fakeWaiter{ch: make(chan time.Time, 1)}
+	f.schedule(w, d, 0)
+	return &fakeTimer{clock: f, w: w}
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{ch: make(chan time.Time, 1)}
+	f.schedule(w, d, d)
+	return &fakeTicker{clock: f, w: w}
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker that
+// becomes due on the way.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the clock to t, firing every timer and ticker due by then.
+// Moving backwards only changes Now.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for {
+		w := f.nextDue(t)
+		if w == nil {
+			break
+		}
+
+		f.now = w.when
+		// Like the time package, a ticker drops ticks for slow receivers
+		select {
+		case w.ch <- f.now:
+		default:
+		}
+
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			f.remove(w)
+		}
+	}
+
+	if t.After(f.now) {
+		f.now = t
+	}
+}
+
+// Waiters returns the number of active timers and tickers.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil blocks until at least n timers and tickers are active. It lets
+// a test wait for the code under test to reach a Sleep before advancing.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+func (f *Fake) schedule(w *fakeWaiter, d, period time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.when = f.now.Add(d)
+	w.period = period
+	if !w.active {
+		w.active = true
+		f.waiters = append(f.waiters, w)
+		f.cond.Broadcast()
+	}
+
+	// Zero and negative durations fire immediately, as in the time package
+	if d <= 0 && period == 0 {
+		select {
+		case w.ch <- f.now:
+		default:
+		}
+		f.remove(w)
+	}
+}
+
+// nextDue returns the waiter with the earliest deadline not after t
+func (f *Fake) nextDue(t time.Time) *fakeWaiter {
+	var next *fakeWaiter
+	for _, w := range f.waiters {
+		if w.when.After(t) {
+			continue
+		}
+		if next == nil || w.when.Before(next.when) {
+			next = w
+		}
+	}
+	return next
+}
+
+// remove deactivates w and reports whether it was active
+func (f *Fake) remove(w *fakeWaiter) bool {
+	if !w.active {
+		return false
+	}
+	w.active = false
+	f.waiters = slices.DeleteFunc(f.waiters, func(o *fakeWaiter) bool { return o == w })
+	return true
+}
+
+func (f *Fake) stop(w *fakeWaiter) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.remove(w)
+}
+
+type fakeTimer struct {
+	clock *Fake
+	w     *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.w.ch }
+func (t *fakeTimer) Stop() bool          { return t.clock.stop(t.w) }
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.clock.stop(t.w)
+	t.clock.schedule(t.w, d, 0)
+	return active
+}
+
+type fakeTicker struct {
+	clock *Fake
+	w     *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.ch }
+func (t *fakeTicker) Stop()               { t.clock.stop(t.w) }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	t.clock.stop(t.w)
+	t.clock.schedule(t.w, d, d)
+}
@@ -9,19 +9,27 @@
 // # Supported Formats
 //
 // The package supports decoding the following audio formats:
-//   - WAV (PCM 16-bit) via formats/wav
+//   - WAV (PCM 8, 16, 24 and 32-bit, IEEE float) via formats/wav
 //   - MP3 via formats/mp3
 //   - Ogg Vorbis via formats/vorbis
-//   - AIFF (PCM 16-bit) via formats/aiff
+//   - AIFF (PCM 8 to 32-bit, 16-bit written) and AIFF-C via formats/aiff
+//   - Ogg Opus headers and tags via formats/opus, and its audio with an
+//     Opus packet decoder you supply, as the module has no Opus codec
+//   - Headerless PCM and G.711 via formats/pcm
+//   - Compressed PCM for intermediate files, read and write, via
+//     formats/pcmz
+//   - G.711 µ-law and A-law WAV files, read and write, via formats/g711
+//   - Asterisk signed linear (.sln to .sln192), read and write, via
+//     formats/sln
+//   - GSM 06.10 .gsm and WAV49 files, read and write, via formats/gsm
 //
 // # Quick Start
 //
-// The simplest way to process audio is using ResampleToMono16:
+// The simplest way to process audio is using Open and ResampleToMono16:
 //
-//	// Decode an audio file
-//	decoder := wav.Decoder{}
-//	file, _ := os.Open("audio.wav")
-//	src, _ := decoder.Decode(file)
+//	// Decode an audio file; the format comes from its content
+//	src, _ := audpbx.Open("audio.wav")
+//	defer src.Close() // closes the file too
 //
 //	// Resample to 8kHz mono, 16-bit PCM
 //	samples, rate, _ := audpbx.ResampleToMono16(src, 8000, 4096)
@@ -66,6 +74,59 @@
 // All decoders return an audio.Source interface which can be used with
 // the audio processing functions.
 //
+// Open and OpenReader pick the decoder themselves, from the content, using
+// DefaultRegistry. Extend a registry of your own for formats it lacks:
+//
+//	reg := audpbx.DefaultRegistry()
+//	reg.Register("opus", opus.Decoder{NewPacketDecoder: newLibopus})
+//	src, err := reg.DetectAndDecode(resp.Body)
+//
+// DecodeHeader reads only the header of a stream, returning its format,
+// length and tags in milliseconds however large it is:
+//
+//	h, err := audpbx.DecodeHeader(upload)
+//	fmt.Println(h.Format, h.Duration())
+//
+// # Codec Policy
+//
+// Deployments with legal constraints can keep codecs out of use with an
+// audio.Policy. SetPolicy restricts Open, OpenReader and PromptLoader, and
+// Registry.SetPolicy a registry of your own; formats the policy refuses
+// fail with audio.ErrRefusedByPolicy, naming it:
+//
+//	audpbx.SetPolicy(audio.DenyFormats("no-mp3", "MP3"))
+//	_, err := audpbx.Open("hold.mp3") // hold.mp3: format refused by the codec policy: mp3 refused by the "no-mp3" policy
+//
+// Building with the audpbx_licensesafe tag starts every registry of
+// DefaultRegistry, and Open, with LicenseSafePolicy instead of no policy.
+//
+// # Prompt Files
+//
+// Like Asterisk, a PromptLoader plays each prompt from whichever of its
+// files needs the least work on the channel: main-menu.ulaw as is on a
+// µ-law channel, main-menu.sln16 on a 16 kHz one, main-menu.wav only when
+// nothing closer is there:
+//
+//	prompts := audpbx.NewPromptLoader(os.DirFS("/var/lib/sounds"))
+//	src, err := prompts.Open("en/main-menu", audpbx.PromptTarget{
+//	    Format: audio.Format{Rate: 8000, Channels: 1},
+//	    Codec:  "ulaw",
+//	})
+//
+// A PromptSet adds locales on top, with fallback chains, so prompts are
+// asked for by key: "vm-intro" for a fr_CA caller plays fr_CA/vm-intro,
+// or fr/vm-intro, or en/vm-intro, whichever is found first:
+//
+//	set := audpbx.NewPromptSet(prompts, audpbx.PromptSetOptions{})
+//	src, err := set.Open("vm-intro", "fr_CA", target)
+//
+// Hot paths, such as the hold message every caller in a queue hears, play
+// from a PromptCache: each sequence of prompts is decoded, converted and
+// joined once, then every call reads the same samples from memory:
+//
+//	cache := audpbx.NewPromptCache(set, target, audpbx.PromptCacheOptions{})
+//	src, err := cache.Open([]string{"please-hold", "queue-thankyou"}, "en")
+//
 // # Writing WAV Files
 //
 // The package can write PCM WAV files:
@@ -74,6 +135,21 @@
 //	file, _ := os.Create("output.wav")
 //	wav.WriteWAV16(file, 8000, samples)
 //
+// Long recordings are streamed instead of collected in memory:
+//
+//	err := wav.Encode(file, src)
+//
+// # Resumable Transcoding
+//
+// For very large inputs, TranscodeResumable streams a source into a mono
+// 16-bit WAV and checkpoints its progress. Running an interrupted job again
+// with the same output and checkpoint path continues where it stopped:
+//
+//	out, _ := os.OpenFile("out.wav", os.O_RDWR|os.O_CREATE, 0o644)
+//	err := audpbx.TranscodeResumable(src, out, 8000, audpbx.TranscodeOptions{
+//	    CheckpointPath: "out.wav.checkpoint",
+//	})
+//
 // # Performance
 //
 // The package is optimized for performance with minimal allocations:
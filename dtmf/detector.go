@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dtmf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// DefaultMinLevel is the level, in dBFS, each tone of a key must reach
+// when Options.MinLevel is zero.
+const DefaultMinLevel = -36.0
+
+const (
+	// minRate is the lowest sample rate above twice the highest tone
+	minRate = 4000
+	// blockLen is the number of samples analyzed at once at 8 kHz
+	blockLen = 205
+	// minBlocks is the number of blocks in a row that make a key, or end
+	// it
+	minBlocks = 2
+	// maxForwardTwist and maxReverseTwist are how much stronger, in dB,
+	// the high tone and the low tone may be than the other
+	maxForwardTwist = 4.0
+	maxReverseTwist = 8.0
+	// minToneShare is the share of the power of a block the two tones
+	// must carry
+	minToneShare = 0.6
+)
+
+var (
+	rowFreqs = [4]float64{697, 770, 852, 941}
+	colFreqs = [4]float64{1209, 1336, 1477, 1633}
+	keypad   = [4][4]rune{
+		{'1', '2', '3', 'A'},
+		{'4', '5', '6', 'B'},
+		{'7', '8', '9', 'C'},
+		{'*', '0', '#', 'D'},
+	}
+)
+
+// Options configures a Detector. Zero values select the defaults.
+type Options struct {
+	// MinLevel is the level, in dBFS, each of the two tones of a key must
+	// reach, -36 dBFS by default.
+	MinLevel float64
+	// OnDigit, when set, is called from ReadSamples with each key as it is
+	// detected.
+	OnDigit func(Digit)
+}
+
+// Digit is a key detected by a Detector.
+type Digit struct {
+	// Key is one of 0-9, *, # and A-D.
+	Key rune
+	// At is when the key was pressed, from the start of the stream.
+	At time.Duration
+}
+
+// Detector passes audio through unchanged while detecting the DTMF keys
+// in it, with Goertzel filters over blocks of mono audio.
+//
+// Keys are reported by OnDigit as they are detected and kept for Digits,
+// which is safe to call from any goroutine while another one reads.
+type Detector struct {
+	src      audio.Source
+	rate     int
+	channels int
+	minAmp   float64
+	onDigit  func(Digit)
+	coeffs   [8]float64 // Goertzel coefficients, rows then columns
+
+	block    []float64 // mono samples of the block being filled
+	position int64     // frames before the block
+
+	candidate rune // key of the last blocks, not reported yet
+	count     int  // blocks in a row holding candidate
+	start     int64
+	active    rune // key reported and still held
+	misses    int  // blocks in a row without active
+
+	mu     *sync.Mutex
+	digits []Digit
+}
+
+// NewDetector creates a Detector over src. It fails with ErrRateTooLow when
+// the sample rate of src cannot carry the tones.
+func NewDetector(src audio.Source, opts Options) (*Detector, error) {
+	format := audio.FormatOf(src)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if format.Rate < minRate {
+		return nil, fmt.Errorf("%w: %d Hz", ErrRateTooLow, format.Rate)
+	}
+	if opts.MinLevel == 0 {
+		opts.MinLevel = DefaultMinLevel
+	}
+
+	d := &Detector{
+		src:      src,
+		rate:     format.Rate,
+		channels: format.Channels,
+		minAmp:   math.Pow(10, opts.MinLevel/20),
+		onDigit:  opts.OnDigit,
+		block:    make([]float64, 0, (blockLen*format.Rate+4000)/8000),
+		mu:       &sync.Mutex{},
+	}
+	for i, f := range append(rowFreqs[:], colFreqs[:]...) {
+		d.coeffs[i] = 2 * math.Cos(2*math.Pi*f/float64(format.Rate))
+	}
+	return d, nil
+}
+
+func (d *Detector) SampleRate() int          { return d.src.SampleRate() }
+func (d *Detector) Channels() int            { return d.src.Channels() }
+func (d *Detector) BufSize() int             { return d.src.BufSize() }
+func (d *Detector) Format() audio.Format     { return audio.FormatOf(d.src) }
+func (d *Detector) Upstream() []audio.Source { return []audio.Source{d.src} }
+
+func (d *Detector) Close() error {
+	if err := d.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// Digits returns the keys detected so far.
+func (d *Detector) Digits() []Digit {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]Digit(nil), d.digits...)
+}
+
+// ReadSamples passes the audio through, detecting keys as it goes.
+func (d *Detector) ReadSamples(dst []float32) (int, error) {
+	n, err := d.src.ReadSamples(dst)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w", err)
+	}
+	if n > 0 {
+		d.analyze(dst[:n-n%d.channels])
+	}
+	return n, err
+}
+
+// analyze mixes samples down into blocks and classifies each full one
+func (d *Detector) analyze(samples []float32) {
+	scale := 1 / float64(d.channels)
+	for f := 0; f < len(samples); f += d.channels {
+		var mix float64
+		for _, s := range samples[f : f+d.channels] {
+			mix += float64(s)
+		}
+		d.block = append(d.block, mix*scale)
+		if len(d.block) < cap(d.block) {
+			continue
+		}
+
+		d.track(d.classify(d.block))
+		d.position += int64(len(d.block))
+		d.block = d.block[:0]
+	}
+}
+
+// classify returns the key held by block, or 0
+func (d *Detector) classify(block []float64) rune {
+	var energy float64
+	for _, x := range block {
+		energy += x * x
+	}
+	if energy == 0 {
+		return 0
+	}
+
+	var amps [8]float64
+	n := float64(len(block))
+	for i, coeff := range d.coeffs {
+		var s1, s2 float64
+		for _, x := range block {
+			s1, s2 = x+coeff*s1-s2, s1
+		}
+		power := max(s1*s1+s2*s2-coeff*s1*s2, 0)
+		amps[i] = 2 * math.Sqrt(power) / n
+	}
+
+	row, rowAmp, ok := dominant(amps[:4])
+	if !ok {
+		return 0
+	}
+	col, colAmp, ok := dominant(amps[4:])
+	if !ok {
+		return 0
+	}
+	if rowAmp < d.minAmp || colAmp < d.minAmp {
+		return 0
+	}
+	twist := 20 * math.Log10(colAmp/rowAmp)
+	if twist > maxForwardTwist || -twist > maxReverseTwist {
+		return 0
+	}
+	if (rowAmp*rowAmp+colAmp*colAmp)/2 < minToneShare*energy/n {
+		return 0
+	}
+	return keypad[row][col]
+}
+
+// dominant returns the strongest of amps, when the others are at least 6
+// dB below it
+func dominant(amps []float64) (int, float64, bool) {
+	best := 0
+	for i, a := range amps {
+		if a > amps[best] {
+			best = i
+		}
+	}
+	for i, a := range amps {
+		if i != best && a > amps[best]/2 {
+			return 0, 0, false
+		}
+	}
+	return best, amps[best], true
+}
+
+// track debounces the key of each block, reporting keys held long enough
+func (d *Detector) track(key rune) {
+	switch {
+	case key == 0:
+		d.candidate, d.count = 0, 0
+	case key == d.candidate:
+		d.count++
+	default:
+		d.candidate, d.count, d.start = key, 1, d.position
+	}
+
+	if d.active != 0 {
+		if key == d.active {
+			d.misses = 0
+			return
+		}
+		if d.misses++; d.misses < minBlocks {
+			return
+		}
+		d.active, d.misses = 0, 0
+	}
+	if d.candidate == 0 || d.count < minBlocks {
+		return
+	}
+
+	digit := Digit{Key: d.candidate, At: audio.FramesDuration(d.start, d.rate)}
+	d.active, d.candidate, d.count = d.candidate, 0, 0
+	d.mu.Lock()
+	d.digits = append(d.digits, digit)
+	d.mu.Unlock()
+
+	if d.onDigit != nil {
+		d.onDigit(digit)
+	}
+}
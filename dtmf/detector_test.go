@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dtmf
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// press is a key held from start for length
+type press struct {
+	key    rune
+	start  time.Duration
+	length time.Duration
+}
+
+// keyTones returns a waveform at rate playing presses at amp per tone,
+// over noise at noise peak
+func keyTones(rate int, amp, noise float64, presses ...press) func(int, int) float32 {
+	rng := rand.New(rand.NewSource(1))
+	return func(i, _ int) float32 {
+		t := float64(i) / float64(rate)
+		v := noise * (2*rng.Float64() - 1)
+		for _, p := range presses {
+			if t < p.start.Seconds() || t >= (p.start+p.length).Seconds() {
+				continue
+			}
+			for r, row := range keypad {
+				for c, k := range row {
+					if k == p.key {
+						v += amp * (math.Sin(2*math.Pi*rowFreqs[r]*t) + math.Sin(2*math.Pi*colFreqs[c]*t))
+					}
+				}
+			}
+		}
+		return float32(v)
+	}
+}
+
+func TestDetector(t *testing.T) {
+	t.Parallel()
+
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+		amp      float64
+		noise    float64
+		presses  []press
+		want     string
+	}{
+		{
+			name:    "sequence",
+			rate:    8000,
+			amp:     0.25,
+			presses: []press{{'1', 100 * ms, 100 * ms}, {'#', 300 * ms, 100 * ms}, {'9', 500 * ms, 100 * ms}},
+			want:    "1#9",
+		},
+		{
+			name:     "all keys wideband stereo",
+			rate:     16000,
+			channels: 2,
+			amp:      0.25,
+			presses: []press{
+				{'0', 0, 60 * ms}, {'1', 120 * ms, 60 * ms}, {'2', 240 * ms, 60 * ms}, {'3', 360 * ms, 60 * ms},
+				{'4', 480 * ms, 60 * ms}, {'5', 600 * ms, 60 * ms}, {'6', 720 * ms, 60 * ms}, {'7', 840 * ms, 60 * ms},
+				{'8', 960 * ms, 60 * ms}, {'9', 1080 * ms, 60 * ms}, {'*', 1200 * ms, 60 * ms}, {'#', 1320 * ms, 60 * ms},
+				{'A', 1440 * ms, 60 * ms}, {'B', 1560 * ms, 60 * ms}, {'C', 1680 * ms, 60 * ms}, {'D', 1800 * ms, 60 * ms},
+			},
+			want: "0123456789*#ABCD",
+		},
+		{
+			name:    "repeated key",
+			rate:    8000,
+			amp:     0.25,
+			presses: []press{{'5', 100 * ms, 100 * ms}, {'5', 300 * ms, 100 * ms}},
+			want:    "55",
+		},
+		{
+			name:    "long press",
+			rate:    8000,
+			amp:     0.25,
+			presses: []press{{'7', 100 * ms, time.Second}},
+			want:    "7",
+		},
+		{
+			name:    "noisy line",
+			rate:    8000,
+			amp:     0.1,
+			noise:   0.02,
+			presses: []press{{'3', 100 * ms, 80 * ms}, {'8', 300 * ms, 80 * ms}},
+			want:    "38",
+		},
+		{
+			name:    "too short",
+			rate:    8000,
+			amp:     0.25,
+			presses: []press{{'1', 100 * ms, 30 * ms}},
+		},
+		{
+			name:    "too quiet",
+			rate:    8000,
+			amp:     0.005,
+			presses: []press{{'1', 100 * ms, 100 * ms}},
+		},
+		{
+			name:  "noise",
+			rate:  8000,
+			noise: 0.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			channels := max(tt.channels, 1)
+			total := tt.rate * 2
+			src := audiotest.NewMockSource(tt.rate, channels, total, keyTones(tt.rate, tt.amp, tt.noise, tt.presses...))
+			var called []Digit
+			det, err := NewDetector(src, Options{OnDigit: func(d Digit) { called = append(called, d) }})
+			if err != nil {
+				t.Fatalf("NewDetector() error = %v", err)
+			}
+
+			read := 0
+			buf := make([]float32, 300*channels)
+			for {
+				n, err := det.ReadSamples(buf)
+				read += n
+				if err != nil {
+					break
+				}
+			}
+			if read != total*channels {
+				t.Fatalf("read %d samples, want %d", read, total*channels)
+			}
+
+			digits := det.Digits()
+			var keys []rune
+			for i, d := range digits {
+				keys = append(keys, d.Key)
+				// A key is timed by the block it starts in
+				if at := tt.presses[i].start; d.At < at-30*ms || d.At > at+30*ms {
+					t.Errorf("key %c at %v, pressed at %v", d.Key, d.At, at)
+				}
+			}
+			if string(keys) != tt.want {
+				t.Errorf("Digits() = %q, want %q", string(keys), tt.want)
+			}
+			if !slices.Equal(called, digits) {
+				t.Errorf("OnDigit got %v, Digits() = %v", called, digits)
+			}
+		})
+	}
+}
+
+func TestNewDetector_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		src  audio.Source
+		want error
+	}{
+		{name: "low rate", src: audiotest.NewSilentSource(3000, 1, 10), want: ErrRateTooLow},
+		{name: "no channels", src: audiotest.NewSilentSource(8000, 0, 10), want: audio.ErrInvalidChannels},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := NewDetector(tt.src, Options{}); !errors.Is(err, tt.want) {
+				t.Fatalf("NewDetector() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetector_SourceError(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 1000), audiotest.WithErrorOnCall(2, audiotest.ErrInjected))
+	det, err := NewDetector(src, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]float32, 160)
+	if _, err := det.ReadSamples(buf); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	if _, err := det.ReadSamples(buf); !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("ReadSamples() error = %v, want ErrInjected", err)
+	}
+	if err := det.Close(); err != nil || !src.Closed() {
+		t.Fatalf("Close() error = %v, source closed = %v", err, src.Closed())
+	}
+}
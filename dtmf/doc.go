@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package dtmf detects the keys callers press on their phones when they
+// reach audpbx in-band, as the dual tones of the keypad in the audio, for
+// IVR menus behind trunks and gateways that do not send them out of band.
+//
+// A Detector passes its source through unchanged while listening for
+// keys, reporting each one once, with its time in the stream:
+//
+//	det, err := dtmf.NewDetector(leg, dtmf.Options{
+//	    OnDigit: func(d dtmf.Digit) { log.Printf("%c at %v", d.Key, d.At) },
+//	})
+//	// ... read from det as from leg ...
+//
+// # Detection
+//
+// The audio, mixed down to mono, is cut into blocks of 205 samples at 8
+// kHz (25.6 ms, the same duration at other rates), and Goertzel filters
+// measure the eight tones of the keypad in each one. A block holds a key
+// when the strongest tone of each group reaches Options.MinLevel, clearly
+// dominates the rest of its group and of the block, and the two are within
+// the twist a phone line allows. A key is reported after two blocks in a
+// row hold it, so tones shorter than about 40 ms are ignored, and once
+// more only after two blocks without it.
+package dtmf
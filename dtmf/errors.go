@@ -0,0 +1,7 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package dtmf
+
+import "errors"
+
+var ErrRateTooLow = errors.New("sample rate too low for DTMF")
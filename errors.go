@@ -0,0 +1,13 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import "errors"
+
+var (
+	ErrCheckpointMismatch = errors.New("checkpoint does not match the transcode job")
+	ErrSourceTooShort     = errors.New("source ended before the checkpoint offset")
+	ErrOutputTooLarge     = errors.New("transcode output too large for a WAV file")
+	ErrPromptNotFound     = errors.New("no usable file for the prompt")
+	ErrPromptTooLong      = errors.New("prompt sequence too long to cache")
+)
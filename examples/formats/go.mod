@@ -0,0 +1,17 @@
+module github.com/ik5/audpbx/examples/formats
+
+go 1.25.5
+
+require github.com/ik5/audpbx v0.0.0-20251229164644-5068b63c6958
+
+require (
+	github.com/go-audio/aiff v1.1.0 // indirect
+	github.com/go-audio/audio v1.0.0 // indirect
+	github.com/go-audio/riff v1.0.0 // indirect
+	github.com/go-audio/wav v1.1.0 // indirect
+	github.com/hajimehoshi/go-mp3 v0.3.4 // indirect
+	github.com/jfreymuth/oggvorbis v1.0.5 // indirect
+	github.com/jfreymuth/vorbis v1.0.2 // indirect
+)
+
+replace github.com/ik5/audpbx => ../..
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Command formats prints the formats audpbx reads and writes, from what
+// the decoders of its default registry report, as a Markdown table, or as
+// JSON with -json.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ik5/audpbx"
+	"github.com/ik5/audpbx/audio"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the matrix as JSON")
+	flag.Parse()
+
+	rows := audpbx.DefaultRegistry().Matrix()
+
+	var err error
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(rows)
+	} else {
+		err = audio.WriteMatrix(os.Stdout, rows)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -3,15 +3,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/ik5/audpbx"
 	"github.com/ik5/audpbx/audio"
-	"github.com/ik5/audpbx/formats/aiff"
-	"github.com/ik5/audpbx/formats/mp3"
-	"github.com/ik5/audpbx/formats/vorbis"
 	"github.com/ik5/audpbx/formats/wav"
 )
 
@@ -23,31 +20,12 @@ func main() {
     inPath := os.Args[1]
     outPath := os.Args[2]
 
-    // Registry
-    reg := audio.NewRegistry()
-    reg.Register("wav", wav.Decoder{})
-    reg.Register("mp3", mp3.Decoder{})
-    reg.Register("ogg", vorbis.Decoder{})
-    reg.Register("aif", aiff.Decoder{})
-    reg.Register("aiff", aiff.Decoder{})
-
-    ext := filepath.Ext(inPath)
-    if len(ext) > 0 {
-        ext = ext[1:] // drop dot
-    }
-    dec, ok := reg.Get(ext)
-    if !ok {
-        fmt.Println("unsupported format:", ext)
+    // The format comes from the content, not the file name
+    src, err := audpbx.Open(inPath)
+    if errors.Is(err, audio.ErrUnknownFormat) {
+        fmt.Println("unsupported format:", err)
         os.Exit(1)
     }
-
-    inFile, err := os.Open(inPath)
-    if err != nil {
-        panic(err)
-    }
-    defer inFile.Close()
-
-    src, err := dec.Decode(inFile)
     if err != nil {
         panic(err)
     }
@@ -0,0 +1,334 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package aiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// maxCommSize bounds the COMM chunk read into memory; AIFF-C adds a
+// compression type and name to its 18 bytes
+const maxCommSize = 1024
+
+// readChunks reads the chunks of an AIFF or AIFF-C file from r up to the
+// sample data, and returns a source of it. It serves the files go-audio
+// does not read: AIFF-C ones, and any from inputs that cannot seek. Only a
+// read buffer is held in memory, whatever the size of the file, but the
+// COMM chunk must come before the SSND chunk, as every writer puts it.
+// When seekable, r is an io.ReadSeeker and the source implements
+// audio.Seeker. The sustain loop is found wherever it is in a seekable
+// file, and before the SSND chunk in others.
+func readChunks(r io.Reader, seekable bool) (audio.Source, error) {
+	var (
+		loops loopChunks
+		loop  audio.LoopRegion
+	)
+	if seekable {
+		rs := r.(io.ReadSeeker)
+		origin, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		if loop, err = readLoop(rs, origin); err != nil {
+			return nil, err
+		}
+	}
+
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNotAiffFile
+		}
+		return nil, fmt.Errorf("reading FORM header: %w", err)
+	}
+	form := string(hdr[8:12])
+	if string(hdr[0:4]) != "FORM" || (form != "AIFF" && form != "AIFC") {
+		return nil, ErrNotAiffFile
+	}
+
+	var comm *commChunk
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("%w: no SSND chunk", ErrUnsupportedAiffChunks)
+			}
+			return nil, fmt.Errorf("reading chunk header: %w", err)
+		}
+		size := int64(binary.BigEndian.Uint32(chunk[4:8]))
+
+		switch string(chunk[0:4]) {
+		case "COMM":
+			var err error
+			if comm, err = readComm(r, size, form == "AIFC"); err != nil {
+				return nil, err
+			}
+		case "SSND":
+			if comm == nil {
+				return nil, fmt.Errorf("%w: SSND chunk before COMM in a stream", ErrUnsupportedAiffChunks)
+			}
+			if !seekable {
+				loop = loops.region()
+			}
+			return newPCMSource(r, size, comm, loop, seekable)
+		default:
+			ok, err := loops.read(r, string(chunk[0:4]), size)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				continue
+			}
+			if _, err := io.CopyN(io.Discard, r, size+size&1); err != nil {
+				return nil, fmt.Errorf("skipping chunk: %w", err)
+			}
+		}
+	}
+}
+
+// commChunk is what the COMM chunk says of the sample data
+type commChunk struct {
+	channels int
+	frames   int64
+	rate     int
+	enc      sampleEncoding
+}
+
+// readComm reads a COMM chunk of size bytes, and its pad byte
+func readComm(r io.Reader, size int64, aifc bool) (*commChunk, error) {
+	if size < 18 || size > maxCommSize {
+		return nil, fmt.Errorf("%w: COMM chunk of %d bytes", ErrUnsupportedAiffChunks, size)
+	}
+	b := make([]byte, size+size&1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("reading COMM chunk: %w", err)
+	}
+
+	bits := int(binary.BigEndian.Uint16(b[6:8]))
+	var enc sampleEncoding
+	switch {
+	case !aifc:
+		if bits < 1 || bits > 32 {
+			return nil, fmt.Errorf("%w: %d-bit", ErrOnlyPCM16bitSupported, bits)
+		}
+		enc = sampleEncoding{size: (bits + 7) / 8, order: binary.BigEndian}
+	case size < 22:
+		return nil, fmt.Errorf("%w: AIFF-C COMM chunk of %d bytes", ErrUnsupportedAiffChunks, size)
+	default:
+		var ok bool
+		if enc, ok = aifcEncoding(string(b[18:22]), bits); !ok {
+			return nil, fmt.Errorf("%w: AIFF-C compression %q, %d-bit", ErrUnsupportedAiffLayout, b[18:22], bits)
+		}
+	}
+
+	c := &commChunk{
+		channels: int(binary.BigEndian.Uint16(b[0:2])),
+		frames:   int64(binary.BigEndian.Uint32(b[2:6])),
+		rate:     int(math.Round(extendedToFloat64([10]byte(b[8:18])))),
+		enc:      enc,
+	}
+	if err := (audio.Format{Rate: c.rate, Channels: c.channels}).Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedAiffLayout, err)
+	}
+	return c, nil
+}
+
+// sampleEncoding is how the SSND chunk stores samples
+type sampleEncoding struct {
+	size  int // bytes per sample
+	order binary.ByteOrder
+	float bool
+}
+
+// aifcEncoding returns the encoding of the AIFF-C compression type, for
+// the uncompressed ones: big-endian integers ("NONE", "twos", "in24",
+// "in32"), little-endian ones ("sowt") and big-endian floats ("fl32").
+// Integers take bits rounded up to whole bytes, as they are stored.
+func aifcEncoding(compression string, bits int) (sampleEncoding, bool) {
+	intSize := (bits + 7) / 8
+	switch compression {
+	case "NONE", "twos":
+		return sampleEncoding{size: intSize, order: binary.BigEndian}, intSize >= 1 && intSize <= 4
+	case "sowt":
+		return sampleEncoding{size: intSize, order: binary.LittleEndian}, intSize >= 1 && intSize <= 4
+	case "in24":
+		return sampleEncoding{size: 3, order: binary.BigEndian}, true
+	case "in32":
+		return sampleEncoding{size: 4, order: binary.BigEndian}, true
+	case "fl32", "FL32":
+		return sampleEncoding{size: 4, order: binary.BigEndian, float: true}, true
+	default:
+		return sampleEncoding{}, false
+	}
+}
+
+func (e sampleEncoding) kind() audio.SampleKind {
+	if e.float {
+		return audio.SampleFloat32
+	}
+	return audio.SampleKindForBitDepth(e.size * 8)
+}
+
+// decode returns the sample at the start of b in [-1, 1]. Integers are
+// signed and left-justified, so they are scaled by their stored size.
+func (e sampleEncoding) decode(b []byte) float32 {
+	if e.float {
+		return math.Float32frombits(binary.BigEndian.Uint32(b))
+	}
+
+	var v int32
+	if e.order == binary.BigEndian {
+		for i := range e.size {
+			v = v<<8 | int32(b[i])
+		}
+	} else {
+		for i := e.size - 1; i >= 0; i-- {
+			v = v<<8 | int32(b[i])
+		}
+	}
+	shift := 32 - e.size*8
+	return float32(v<<shift>>shift) / float32(int64(1)<<(e.size*8-1))
+}
+
+// newPCMSource returns a source of the SSND chunk of size bytes whose
+// header is next in r
+func newPCMSource(r io.Reader, size int64, c *commChunk, loop audio.LoopRegion, seekable bool) (audio.Source, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("reading SSND header: %w", err)
+	}
+	offset := int64(binary.BigEndian.Uint32(hdr[0:4]))
+	if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedAiffChunks, err)
+	}
+
+	// numSampleFrames wins over a chunk size left at its maximum by a
+	// writer that streamed the file
+	frameSize := int64(c.channels * c.enc.size)
+	frames := min(max(size-8-offset, 0)/frameSize, c.frames)
+	src := &pcmSource{
+		r:      io.LimitReader(r, frames*frameSize),
+		format: c,
+		frames: frames,
+		loop:   loop,
+	}
+	if !seekable {
+		return src, nil
+	}
+
+	rs := r.(io.ReadSeeker)
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &pcmSeekSource{pcmSource: src, rs: rs, start: start}, nil
+}
+
+// pcmSource reads the sample data of a file read by readChunks
+type pcmSource struct {
+	r      io.Reader
+	format *commChunk
+	frames int64
+	buf    []byte
+	read   int64 // frames returned so far
+	loop   audio.LoopRegion
+}
+
+func (s *pcmSource) SampleRate() int { return s.format.rate }
+func (s *pcmSource) Channels() int   { return s.format.channels }
+func (s *pcmSource) BufSize() int    { return 4096 }
+func (s *pcmSource) Close() error    { return nil }
+
+func (s *pcmSource) Format() audio.Format {
+	return audio.Format{
+		Rate:       s.format.rate,
+		Channels:   s.format.channels,
+		Layout:     audio.DefaultLayout(s.format.channels),
+		SampleKind: s.format.enc.kind(),
+	}
+}
+
+// TotalFrames returns the frames of the SSND chunk, numSampleFrames from
+// the COMM chunk unless the chunk is shorter.
+func (s *pcmSource) TotalFrames() int64 { return s.frames }
+
+func (s *pcmSource) Duration() time.Duration {
+	return audio.FramesDuration(s.frames, s.format.rate)
+}
+
+// ReadSamples reads whole frames into dst, so dst must hold at least one.
+func (s *pcmSource) ReadSamples(dst []float32) (int, error) {
+	channels := s.format.channels
+	if len(dst) < channels {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	size := s.format.enc.size
+	want := len(dst) / channels * channels * size
+	if cap(s.buf) < want {
+		s.buf = make([]byte, want)
+	}
+	b := s.buf[:want]
+
+	n, err := io.ReadFull(s.r, b)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("%w", err)
+	}
+
+	frames := n / (channels * size)
+	for i := range frames * channels {
+		dst[i] = s.format.enc.decode(b[i*size:])
+	}
+	s.read += int64(frames)
+	return frames * channels, err
+}
+
+// pcmSeekSource is a pcmSource with random access to the SSND data
+type pcmSeekSource struct {
+	*pcmSource
+	rs    io.ReadSeeker
+	start int64 // offset of the first sample
+}
+
+// SeekFrame moves to frame n.
+func (s *pcmSeekSource) SeekFrame(n int64) error {
+	if n < 0 || n > s.frames {
+		return fmt.Errorf("%w: frame %d", audio.ErrSeekOutOfRange, n)
+	}
+
+	frameSize := int64(s.format.channels * s.format.enc.size)
+	if _, err := s.rs.Seek(s.start+n*frameSize, io.SeekStart); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	s.r = io.LimitReader(s.rs, (s.frames-n)*frameSize)
+	s.read = n
+	return nil
+}
+
+func (s *pcmSeekSource) Position() int64 { return s.read }
+
+// extendedToFloat64 decodes an 80-bit extended float, as the COMM chunk
+// stores the sample rate.
+func extendedToFloat64(b [10]byte) float64 {
+	se := binary.BigEndian.Uint16(b[0:2])
+	mantissa := binary.BigEndian.Uint64(b[2:10])
+	if mantissa == 0 {
+		return 0
+	}
+
+	f := math.Ldexp(float64(mantissa), int(se&0x7FFF)-16383-63)
+	if se&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
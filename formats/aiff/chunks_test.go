@@ -0,0 +1,293 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package aiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// aiffChunk encodes a chunk, with its pad byte
+func aiffChunk(id string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32([]byte(id), uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// aiffFile encodes a FORM of chunks
+func aiffFile(form string, chunks ...[]byte) []byte {
+	var body []byte
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	b := binary.BigEndian.AppendUint32([]byte("FORM"), uint32(len(body)+4))
+	b = append(b, form...)
+	return append(b, body...)
+}
+
+// comm encodes a COMM chunk body at 8 kHz, with an AIFF-C compression
+// type when given
+func comm(channels, frames, bits int, compression string) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(channels))
+	b = binary.BigEndian.AppendUint32(b, uint32(frames))
+	b = binary.BigEndian.AppendUint16(b, uint16(bits))
+	rate := float64ToExtended(8000)
+	b = append(b, rate[:]...)
+	if compression != "" {
+		b = append(b, compression...)
+		b = append(b, 0, 0) // empty pascal string name
+	}
+	return b
+}
+
+// ssnd encodes an SSND chunk body of samples after offset unused bytes
+func ssnd(offset int, samples ...int16) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(offset))
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = append(b, make([]byte, offset)...)
+	for _, s := range samples {
+		b = binary.BigEndian.AppendUint16(b, uint16(s))
+	}
+	return b
+}
+
+func TestDecoder_Stream(t *testing.T) {
+	t.Parallel()
+
+	samples := []int16{1000, -1000, 2000, -2000, 3000, -3000}
+	want := []float32{1000. / 32768, -1000. / 32768, 2000. / 32768, -2000. / 32768, 3000. / 32768, -3000. / 32768}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []float32
+		wantErr error
+	}{
+		{
+			name: "other chunks",
+			data: aiffFile("AIFF",
+				aiffChunk("NAME", []byte("odd")),
+				aiffChunk("COMM", comm(2, 3, 16, "")),
+				aiffChunk("ANNO", []byte("prompt")),
+				aiffChunk("SSND", ssnd(4, samples...)),
+			),
+			want: want,
+		},
+		{
+			name: "AIFF-C",
+			data: aiffFile("AIFC", aiffChunk("COMM", comm(2, 3, 16, "NONE")), aiffChunk("SSND", ssnd(0, samples...))),
+			want: want,
+		},
+		{
+			name: "trailing chunk",
+			data: aiffFile("AIFF", aiffChunk("COMM", comm(2, 2, 16, "")), aiffChunk("SSND", ssnd(0, samples...)), aiffChunk("ANNO", []byte("x"))),
+			want: want[:4],
+		},
+		{name: "not AIFF", data: []byte("RIFF\x00\x00\x00\x00WAVE"), wantErr: ErrNotAiffFile},
+		{name: "empty", wantErr: ErrNotAiffFile},
+		{
+			name:    "SSND first",
+			data:    aiffFile("AIFF", aiffChunk("SSND", ssnd(0, samples...)), aiffChunk("COMM", comm(2, 3, 16, ""))),
+			wantErr: ErrUnsupportedAiffChunks,
+		},
+		{name: "no SSND", data: aiffFile("AIFF", aiffChunk("COMM", comm(2, 3, 16, ""))), wantErr: ErrUnsupportedAiffChunks},
+		{name: "64-bit", data: aiffFile("AIFF", aiffChunk("COMM", comm(1, 3, 64, ""))), wantErr: ErrOnlyPCM16bitSupported},
+		{name: "compressed", data: aiffFile("AIFC", aiffChunk("COMM", comm(1, 3, 16, "ulaw"))), wantErr: ErrUnsupportedAiffLayout},
+		{name: "no channels", data: aiffFile("AIFF", aiffChunk("COMM", comm(0, 3, 16, ""))), wantErr: ErrUnsupportedAiffLayout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := Decoder{}.Decode(struct{ io.Reader }{bytes.NewReader(tt.data)})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if src.SampleRate() != 8000 || src.Channels() != 2 {
+				t.Errorf("format = %d Hz, %d channels, want 8000 Hz, 2", src.SampleRate(), src.Channels())
+			}
+			if _, ok := src.(audio.Seeker); ok {
+				t.Error("streamed source implements audio.Seeker")
+			}
+			if l, ok := src.(audio.Lengther); !ok || l.TotalFrames() != int64(len(tt.want)/2) {
+				t.Errorf("source does not implement audio.Lengther with %d frames", len(tt.want)/2)
+			}
+
+			var got []float32
+			buf := make([]float32, 3) // not whole frames
+			for {
+				n, err := src.ReadSamples(buf)
+				got = append(got, buf[:n]...)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d samples, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("sample %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_StreamMatchesSeekable(t *testing.T) {
+	t.Parallel()
+
+	samples := make([]int16, 20000)
+	for i := range samples {
+		samples[i] = int16(i * 3)
+	}
+	var file bytes.Buffer
+	if err := WriteAIFF16Interleaved(&file, 16000, 2, samples); err != nil {
+		t.Fatalf("WriteAIFF16Interleaved() error = %v", err)
+	}
+
+	read := func(r io.Reader) []float32 {
+		t.Helper()
+
+		src, err := Decoder{}.Decode(r)
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		var out []float32
+		buf := make([]float32, 4096)
+		for {
+			n, err := src.ReadSamples(buf)
+			out = append(out, buf[:n]...)
+			if errors.Is(err, io.EOF) {
+				return out
+			}
+			if err != nil {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+		}
+	}
+
+	seekable := read(bytes.NewReader(file.Bytes()))
+	streamed := read(struct{ io.Reader }{bytes.NewReader(file.Bytes())})
+	if len(seekable) != len(samples) || len(streamed) != len(samples) {
+		t.Fatalf("read %d samples seeking and %d streaming, want %d", len(seekable), len(streamed), len(samples))
+	}
+	for i := range seekable {
+		if seekable[i] != streamed[i] {
+			t.Fatalf("sample %d = %v streamed, %v seeking", i, streamed[i], seekable[i])
+		}
+	}
+}
+
+func TestDecoder_AIFC(t *testing.T) {
+	t.Parallel()
+
+	// Two stereo frames: 0.5, -0.5, 0.25, -1
+	values := []float64{0.5, -0.5, 0.25, -1}
+	ints := func(size int, order binary.ByteOrder) []byte {
+		var b []byte
+		for _, v := range values {
+			x := uint32(int32(v * float64(int64(1)<<(size*8-1))))
+			be := binary.BigEndian.AppendUint32(nil, x)[4-size:]
+			if order == binary.LittleEndian {
+				slices.Reverse(be)
+			}
+			b = append(b, be...)
+		}
+		return b
+	}
+	var fl32 []byte
+	for _, v := range values {
+		fl32 = binary.BigEndian.AppendUint32(fl32, math.Float32bits(float32(v)))
+	}
+
+	tests := []struct {
+		name        string
+		compression string
+		bits        int
+		data        []byte
+		kind        audio.SampleKind
+	}{
+		{name: "sowt", compression: "sowt", bits: 16, data: ints(2, binary.LittleEndian), kind: audio.SampleInt16},
+		{name: "sowt 24-bit", compression: "sowt", bits: 24, data: ints(3, binary.LittleEndian), kind: audio.SampleInt24},
+		{name: "in24", compression: "in24", bits: 24, data: ints(3, binary.BigEndian), kind: audio.SampleInt24},
+		{name: "in32", compression: "in32", bits: 32, data: ints(4, binary.BigEndian), kind: audio.SampleInt32},
+		{name: "fl32", compression: "fl32", bits: 32, data: fl32, kind: audio.SampleFloat32},
+		{name: "twos", compression: "twos", bits: 16, data: ints(2, binary.BigEndian), kind: audio.SampleInt16},
+		{name: "NONE 20-bit", compression: "NONE", bits: 20, data: ints(3, binary.BigEndian), kind: audio.SampleInt24},
+	}
+
+	for _, tt := range tests {
+		for _, seekable := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/seekable=%v", tt.name, seekable), func(t *testing.T) {
+				t.Parallel()
+
+				ssndBody := append(binary.BigEndian.AppendUint64(nil, 0), tt.data...)
+				file := aiffFile("AIFC",
+					aiffChunk("FVER", []byte{0xA2, 0x80, 0x51, 0x40}),
+					aiffChunk("COMM", comm(2, 2, tt.bits, tt.compression)),
+					aiffChunk("SSND", ssndBody),
+				)
+				var r io.Reader = bytes.NewReader(file)
+				if !seekable {
+					r = struct{ io.Reader }{r}
+				}
+
+				src, err := Decoder{}.Decode(r)
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if f := audio.FormatOf(src); f.Rate != 8000 || f.Channels != 2 || f.SampleKind != tt.kind {
+					t.Errorf("format = %s, want 8000 Hz 2ch %s", f, tt.kind)
+				}
+
+				got := make([]float32, 8)
+				n, err := src.ReadSamples(got)
+				if n != 4 || (err != nil && !errors.Is(err, io.EOF)) {
+					t.Fatalf("ReadSamples() = %d, %v, want 4 samples", n, err)
+				}
+				for i, v := range values {
+					if got[i] != float32(v) {
+						t.Errorf("sample %d = %v, want %v", i, got[i], v)
+					}
+				}
+
+				s, ok := src.(audio.Seeker)
+				if ok != seekable {
+					t.Fatalf("source implements audio.Seeker = %v, want %v", ok, seekable)
+				}
+				if !seekable {
+					return
+				}
+				if err := s.SeekFrame(1); err != nil {
+					t.Fatalf("SeekFrame(1) error = %v", err)
+				}
+				if n, _ := src.ReadSamples(got); n != 2 || got[0] != 0.25 || got[1] != -1 || s.Position() != 2 {
+					t.Errorf("after SeekFrame(1) read %v, position %d, want [0.25 -1], 2", got[:n], s.Position())
+				}
+			})
+		}
+	}
+}
@@ -3,8 +3,11 @@
 package aiff
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"time"
 
 	"github.com/go-audio/aiff"
 	goaudio "github.com/go-audio/audio"
@@ -24,11 +27,22 @@ type source struct {
 	channels   int
 	bitDepth   int
 	intBuf     *goaudio.IntBuffer
+	read       int64 // samples returned so far
+	loop       audio.LoopRegion
 }
 
 func (s *source) SampleRate() int { return s.sampleRate }
 func (s *source) Channels() int   { return s.channels }
 func (s *source) Close() error    { return nil }
+
+func (s *source) Format() audio.Format {
+	return audio.Format{
+		Rate:       s.sampleRate,
+		Channels:   s.channels,
+		Layout:     audio.DefaultLayout(s.channels),
+		SampleKind: audio.SampleKindForBitDepth(s.bitDepth),
+	}
+}
 func (s *source) BufSize() int {
 	if s.intBuf != nil {
 		return cap(s.intBuf.Data)
@@ -77,8 +91,15 @@ func (s *source) ReadSamples(dst []float32) (int, error) {
 	}
 
 	for i := 0; i < n; i++ {
-		dst[i] = float32(s.intBuf.Data[i]) / maxVal
+		v := s.intBuf.Data[i]
+		if s.bitDepth == 8 {
+			// AIFF samples are signed, go-audio returns 8-bit ones as
+			// unsigned bytes
+			v = int(int8(v))
+		}
+		dst[i] = float32(v) / maxVal
 	}
+	s.read += int64(n)
 
 	// If we got fewer samples than requested and no error, we're at EOF
 	if n < len(dst) && err == nil {
@@ -90,19 +111,32 @@ func (s *source) ReadSamples(dst []float32) (int, error) {
 
 type Decoder struct{}
 
+// Decode returns a source of the samples of the AIFF or AIFF-C file in r:
+// 8 to 32-bit PCM for AIFF, and for AIFF-C any uncompressed encoding, such as
+// the little-endian "sowt", 24-bit "in24" and float "fl32" of macOS. It
+// implements audio.Lengther, audio.LoopPoints from the INST and MARK
+// chunks, and audio.Seeker when r is an io.ReadSeeker. Other inputs are streamed, as from a network connection, holding no
+// more than a read buffer in memory.
 func (Decoder) Decode(r io.Reader) (audio.Source, error) {
 	// go-audio requires io.ReadSeeker
 	rs, ok := r.(io.ReadSeeker)
 	if !ok {
-		// If not a ReadSeeker, we need to read all data into memory
-		// This is a limitation of go-audio
-		data, err := io.ReadAll(r)
-		if err != nil {
-			return nil, fmt.Errorf("reading aiff data: %w", err)
-		}
-		rs = &readSeeker{data: data, offset: 0}
+		return readChunks(r, false)
 	}
 
+	// and reads AIFF-C files as AIFF ones, whatever their encoding
+	aifc, err := isAIFC(rs)
+	if err != nil {
+		return nil, err
+	}
+	if aifc {
+		return readChunks(rs, true)
+	}
+
+	origin, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
 	dec := aiff.NewDecoder(rs)
 	if !dec.IsValidFile() {
 		return nil, ErrNotAiffFile
@@ -111,9 +145,15 @@ func (Decoder) Decode(r io.Reader) (audio.Source, error) {
 	// Read file info
 	dec.ReadInfo()
 
-	// Check bit depth - only support 16-bit for now
-	if dec.BitDepth != 16 {
-		return nil, ErrOnlyPCM16bitSupported
+	// go-audio reads whole bytes of 8 to 32 bits; other sample sizes,
+	// such as 20-bit, are padded to whole bytes, which readChunks reads
+	switch dec.BitDepth {
+	case 8, 16, 24, 32:
+	default:
+		if _, err := rs.Seek(origin, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+		return readChunks(rs, true)
 	}
 
 	format := dec.Format()
@@ -121,46 +161,93 @@ func (Decoder) Decode(r io.Reader) (audio.Source, error) {
 		return nil, ErrUnsupportedAiffLayout
 	}
 
-	return &source{
-		dec:        dec,
-		sampleRate: format.SampleRate,
-		channels:   format.NumChannels,
-		bitDepth:   int(dec.BitDepth),
+	// Locate the sample data now, so seeks have an origin
+	if err := dec.FwdToPCM(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedAiffChunks, err)
+	}
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	loop, err := readLoop(rs, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &seekSource{
+		source: &source{
+			dec:        dec,
+			sampleRate: format.SampleRate,
+			channels:   format.NumChannels,
+			bitDepth:   int(dec.BitDepth),
+			loop:       loop,
+		},
+		rs:     rs,
+		pcm:    dec,
+		start:  start,
+		frames: int64(dec.NumSampleFrames),
 	}, nil
 }
 
-// readSeeker implements io.ReadSeeker for in-memory data
-type readSeeker struct {
-	data   []byte
-	offset int64
+// Capabilities reports what the package reads and writes: PCM of 8 to 32
+// bits, written back as 16-bit PCM.
+func (Decoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{
+		Name:            "AIFF",
+		Decode:          true,
+		Encode:          true,
+		BitDepths:       []int{8, 16, 20, 24, 32},
+		EncodeBitDepths: []int{16},
+		MaxChannels:     math.MaxInt16,
+		Seekable:        true,
+	}
 }
 
-func (rs *readSeeker) Read(p []byte) (n int, err error) {
-	if rs.offset >= int64(len(rs.data)) {
-		return 0, io.EOF
+// isAIFC reports whether rs holds an AIFF-C file, leaving it where it was
+func isAIFC(rs io.ReadSeeker) (bool, error) {
+	var hdr [12]byte
+	n, err := io.ReadFull(rs, hdr[:])
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, fmt.Errorf("%w", err)
+	}
+	if _, err := rs.Seek(int64(-n), io.SeekCurrent); err != nil {
+		return false, fmt.Errorf("%w", err)
 	}
-	n = copy(p, rs.data[rs.offset:])
-	rs.offset += int64(n)
-	return n, nil
+	return string(hdr[0:4]) == "FORM" && string(hdr[8:12]) == "AIFC", nil
 }
 
-func (rs *readSeeker) Seek(offset int64, whence int) (int64, error) {
-	var newOffset int64
-	switch whence {
-	case io.SeekStart:
-		newOffset = offset
-	case io.SeekCurrent:
-		newOffset = rs.offset + offset
-	case io.SeekEnd:
-		newOffset = int64(len(rs.data)) + offset
-	default:
-		return 0, fmt.Errorf("invalid whence: %d", whence)
+// seekSource is a source with random access to the SSND data and a known
+// length, read from an io.ReadSeeker.
+type seekSource struct {
+	*source
+	rs     io.ReadSeeker
+	pcm    *aiff.Decoder
+	start  int64 // offset of the first sample
+	frames int64 // numSampleFrames from the COMM chunk
+}
+
+// SeekFrame moves to frame n.
+func (s *seekSource) SeekFrame(n int64) error {
+	if n < 0 || n > s.frames {
+		return fmt.Errorf("%w: frame %d", audio.ErrSeekOutOfRange, n)
 	}
 
-	if newOffset < 0 {
-		return 0, fmt.Errorf("negative position")
+	frameSize := int64(s.channels * s.bitDepth / 8)
+	if _, err := s.rs.Seek(s.start+n*frameSize, io.SeekStart); err != nil {
+		return fmt.Errorf("%w", err)
 	}
+	// go-audio reads the samples through the SSND chunk reader, which
+	// is limited to the bytes left in the chunk
+	s.pcm.PCMChunk.R = io.LimitReader(s.rs, (s.frames-n)*frameSize)
+	s.read = n * int64(s.channels)
+	return nil
+}
+
+func (s *seekSource) Position() int64 { return s.read / int64(s.channels) }
+
+// TotalFrames returns numSampleFrames from the COMM chunk.
+func (s *seekSource) TotalFrames() int64 { return s.frames }
 
-	rs.offset = newOffset
-	return newOffset, nil
+func (s *seekSource) Duration() time.Duration {
+	return audio.FramesDuration(s.frames, s.sampleRate)
 }
@@ -4,11 +4,15 @@ package aiff
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"testing"
 
 	goaudio "github.com/go-audio/audio"
+	"github.com/ik5/audpbx/audio"
 )
 
 // mockAiffReader simulates the aiff.Decoder for testing
@@ -428,6 +432,7 @@ func TestErrors_AreErrors(t *testing.T) {
 		ErrOnlyPCM16bitSupported,
 		ErrUnsupportedAiffLayout,
 		ErrUnsupportedAiffChunks,
+		ErrInvalidChannelCount,
 	}
 
 	for _, err := range testErrors {
@@ -455,6 +460,7 @@ func TestErrors_IsComparison(t *testing.T) {
 		{"ErrOnlyPCM16bitSupported matches itself", ErrOnlyPCM16bitSupported, ErrOnlyPCM16bitSupported, true},
 		{"ErrUnsupportedAiffLayout matches itself", ErrUnsupportedAiffLayout, ErrUnsupportedAiffLayout, true},
 		{"ErrUnsupportedAiffChunks matches itself", ErrUnsupportedAiffChunks, ErrUnsupportedAiffChunks, true},
+		{"ErrInvalidChannelCount matches itself", ErrInvalidChannelCount, ErrInvalidChannelCount, true},
 	}
 
 	for _, tt := range tests {
@@ -474,6 +480,7 @@ func TestErrors_Wrapping(t *testing.T) {
 		ErrOnlyPCM16bitSupported,
 		ErrUnsupportedAiffLayout,
 		ErrUnsupportedAiffChunks,
+		ErrInvalidChannelCount,
 	}
 
 	for _, baseErr := range baseErrors {
@@ -495,6 +502,7 @@ func TestErrors_Uniqueness(t *testing.T) {
 		ErrOnlyPCM16bitSupported,
 		ErrUnsupportedAiffLayout,
 		ErrUnsupportedAiffChunks,
+		ErrInvalidChannelCount,
 	}
 
 	// Check that all error messages are unique
@@ -519,6 +527,7 @@ func TestErrors_Messages(t *testing.T) {
 		{ErrOnlyPCM16bitSupported, "only 16-bit PCM AIFF is supported"},
 		{ErrUnsupportedAiffLayout, "unsupported AIFF layout"},
 		{ErrUnsupportedAiffChunks, "unsupported or malformed AIFF chunks"},
+		{ErrInvalidChannelCount, "invalid AIFF channel count"},
 	}
 
 	for _, tt := range tests {
@@ -631,3 +640,83 @@ func BenchmarkSource_ReadSamples_LargeBuffer(b *testing.B) {
 		}
 	}
 }
+
+func TestDecoder_BitDepths(t *testing.T) {
+	t.Parallel()
+
+	// A 24-bit studio master: a ramp over the whole range, and its ends
+	const frames = 1000
+	values := make([]float64, 0, frames*2)
+	for i := range frames {
+		v := float64(i)/frames*2 - 1
+		values = append(values, v, -v)
+	}
+	values[len(values)-1] = 1 - 1.0/(1<<23)
+
+	tests := []struct {
+		name string
+		bits int
+	}{
+		{name: "8-bit", bits: 8},
+		{name: "20-bit", bits: 20},
+		{name: "24-bit", bits: 24},
+		{name: "32-bit", bits: 32},
+	}
+
+	for _, tt := range tests {
+		// Samples are left-justified in whole bytes, low bits zero
+		size := (tt.bits + 7) / 8
+		scale := float64(int64(1) << (tt.bits - 1))
+		var data []byte
+		want := make([]float32, len(values))
+		for i, v := range values {
+			q := min(math.Round(v*scale), scale-1)
+			want[i] = float32(q / scale)
+			x := uint32(int32(q) << (size*8 - tt.bits))
+			data = append(data, binary.BigEndian.AppendUint32(nil, x)[4-size:]...)
+		}
+		file := aiffFile("AIFF",
+			aiffChunk("COMM", comm(2, frames, tt.bits, "")),
+			aiffChunk("SSND", append(make([]byte, 8), data...)),
+		)
+
+		for _, seekable := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/seekable=%v", tt.name, seekable), func(t *testing.T) {
+				t.Parallel()
+
+				var r io.Reader = bytes.NewReader(file)
+				if !seekable {
+					r = struct{ io.Reader }{r}
+				}
+				src, err := Decoder{}.Decode(r)
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if _, ok := src.(audio.Seeker); ok != seekable {
+					t.Errorf("source implements audio.Seeker = %v, want %v", ok, seekable)
+				}
+
+				var got []float32
+				buf := make([]float32, 256)
+				for {
+					n, err := src.ReadSamples(buf)
+					got = append(got, buf[:n]...)
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						t.Fatalf("ReadSamples() error = %v", err)
+					}
+				}
+				if len(got) != len(want) {
+					t.Fatalf("read %d samples, want %d", len(got), len(want))
+				}
+				for i := range got {
+					if got[i] != want[i] {
+						t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+					}
+				}
+			})
+		}
+	}
+}
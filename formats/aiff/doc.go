@@ -1,15 +1,21 @@
 // SPDX-License-Identifier: EPL-2.0
 
-// Package aiff provides AIFF (Audio Interchange File Format) decoding.
+// Package aiff provides AIFF (Audio Interchange File Format) decoding and
+// encoding.
 //
-// This package uses github.com/go-audio/aiff to decode AIFF files.
+// This package uses github.com/go-audio/aiff to decode AIFF files, reads
+// AIFF-C files and streamed input itself, and writes 16-bit PCM AIFF files.
 // AIFF is Apple's standard audio file format, commonly used on macOS.
 //
 // # Supported Formats
 //
 // Currently supported:
 //   - AIFF (Audio Interchange File Format)
-//   - PCM 16-bit (most common)
+//   - PCM of 8 to 32 bits: 16-bit (most common), and the 24-bit of studio
+//     masters
+//   - AIFF-C (.aifc) with uncompressed audio, as macOS apps export it:
+//     big-endian "NONE", "twos", "in24" and "in32", little-endian "sowt"
+//     PCM of 8 to 32 bits, and 32-bit float "fl32"
 //   - Mono and multi-channel
 //   - Any sample rate
 //
@@ -31,6 +37,28 @@
 // The decoder returns an audio.Source that provides samples as float32
 // values normalized to the range [-1.0, 1.0].
 //
+// The source implements audio.Lengther, and audio.Seeker when the input
+// is an io.ReadSeeker. Other inputs, like a file piped over the network,
+// are streamed with constant memory; their COMM chunk must come before
+// the SSND chunk, as it does in files from every common writer.
+//
+// Music made to loop marks its loop as the sustain loop of an INST chunk,
+// between two markers of a MARK chunk. The source implements
+// audio.LoopPoints with it when it plays forward, so audio.Loop and
+// audio.LoopForever repeat the loop rather than the whole file. In a
+// stream, only chunks before the SSND chunk are seen.
+//
+// # Writing AIFF Files
+//
+// WriteAIFF16 mirrors wav.WriteWAV16, and WriteAIFF16Interleaved writes
+// stereo and multi-channel audio from interleaved samples:
+//
+//	file, _ := os.Create("output.aif")
+//	err := aiff.WriteAIFF16(file, 44100, samples)
+//
+// The file holds FORM, COMM and SSND chunks with big-endian samples and the
+// sample rate as an 80-bit extended float, which every AIFF reader accepts.
+//
 // # Output Format
 //
 // AIFF decoder output:
@@ -42,8 +70,11 @@
 //
 // The package defines several error types:
 //   - ErrNotAiffFile: The input is not a valid AIFF file
-//   - ErrOnlyPCM16bitSupported: Only 16-bit PCM is currently supported
-//   - ErrUnsupportedAiffLayout: Unsupported AIFF file structure
+//   - ErrOnlyPCM16bitSupported: The samples are over 32 bits
+//   - ErrUnsupportedAiffLayout: Unsupported AIFF file structure, or
+//     compressed AIFF-C audio
+//   - ErrInvalidChannelCount: The writer cannot store the channel count, or
+//     samples do not form whole frames
 //
 // Example:
 //
@@ -73,9 +104,10 @@
 // # Limitations
 //
 // Note:
-//   - AIFF writing is not supported (decoding only)
-//   - Only 16-bit PCM is supported (no 8-bit, 24-bit, or compressed formats)
-//   - For other bit depths, you'll get ErrOnlyPCM16bitSupported
+//   - AIFF-C files must be uncompressed (no µ-law, A-law, IMA ADPCM or
+//     fl64)
+//   - Only 16-bit PCM is written
+//   - Writing collects all samples in memory, like wav.WriteWAV16
 //
 // # Use Cases
 //
@@ -8,7 +8,8 @@ var (
 	// ErrNotAiffFile indicates the file is not a valid AIFF file
 	ErrNotAiffFile = errors.New("not an AIFF file")
 
-	// ErrOnlyPCM16bitSupported indicates only 16-bit PCM is supported
+	// ErrOnlyPCM16bitSupported indicates a sample size the decoder cannot
+	// read, over 32 bits. Its name dates from when only 16-bit PCM was.
 	ErrOnlyPCM16bitSupported = errors.New("only 16-bit PCM AIFF is supported")
 
 	// ErrUnsupportedAiffLayout indicates an unsupported AIFF layout
@@ -16,4 +17,8 @@ var (
 
 	// ErrUnsupportedAiffChunks indicates unsupported or malformed AIFF chunks
 	ErrUnsupportedAiffChunks = errors.New("unsupported or malformed AIFF chunks")
+
+	// ErrInvalidChannelCount indicates a channel count the writer cannot
+	// store, or samples that do not form whole frames
+	ErrInvalidChannelCount = errors.New("invalid AIFF channel count")
 )
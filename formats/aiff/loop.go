@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package aiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// maxLoopChunkSize bounds the MARK and INST chunks read into memory
+const maxLoopChunkSize = 1 << 16
+
+// forwardLooping is the play mode of a loop played forward; loops played
+// forward and backward cannot be played by seeking and are ignored
+const forwardLooping = 1
+
+// loopChunks collects the MARK and INST chunks of a file: the sustain loop
+// of the INST chunk runs between two of the markers.
+type loopChunks struct {
+	marks      map[uint16]int64 // marker ID to the frame it is before
+	mode       uint16
+	begin, end uint16 // marker IDs of the sustain loop
+}
+
+// read reads the body of a MARK or INST chunk of size bytes, and its pad
+// byte, and reports whether the chunk was one. As metadata, a malformed
+// chunk does not stop the samples from being decoded: it yields no loop.
+func (l *loopChunks) read(r io.Reader, id string, size int64) (bool, error) {
+	if (id != "MARK" && id != "INST") || size > maxLoopChunkSize {
+		return false, nil
+	}
+	b := make([]byte, size+size&1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return true, fmt.Errorf("reading %s chunk: %w", id, err)
+	}
+
+	if id == "INST" {
+		if size >= 14 {
+			l.mode = binary.BigEndian.Uint16(b[8:10])
+			l.begin = binary.BigEndian.Uint16(b[10:12])
+			l.end = binary.BigEndian.Uint16(b[12:14])
+		}
+		return true, nil
+	}
+
+	l.marks = map[uint16]int64{}
+	if len(b) < 2 {
+		return true, nil
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	for p := b[2:]; n > 0 && len(p) >= 7; n-- {
+		l.marks[binary.BigEndian.Uint16(p)] = int64(binary.BigEndian.Uint32(p[2:]))
+		// The name is a pascal string padded to an even length
+		name := 1 + int(p[6])
+		p = p[min(6+name+name&1, len(p)):]
+	}
+	return true, nil
+}
+
+// region returns the sustain loop, or an empty region when there is none
+func (l *loopChunks) region() audio.LoopRegion {
+	if l.mode != forwardLooping {
+		return audio.LoopRegion{}
+	}
+	start, ok1 := l.marks[l.begin]
+	end, ok2 := l.marks[l.end]
+	if !ok1 || !ok2 || start >= end {
+		return audio.LoopRegion{}
+	}
+	return audio.LoopRegion{Start: start, End: end}
+}
+
+// LoopPoints returns the sustain loop of the INST chunk, which
+// audio.Looper repeats instead of the whole file. The loop is only found
+// after the SSND chunk when the file can seek.
+func (s *source) LoopPoints() (audio.LoopRegion, bool) {
+	return s.loop, s.loop.End > s.loop.Start
+}
+
+// LoopPoints returns the sustain loop of the INST chunk, as for the files
+// go-audio reads.
+func (s *pcmSource) LoopPoints() (audio.LoopRegion, bool) {
+	return s.loop, s.loop.End > s.loop.Start
+}
+
+// readLoop walks the chunks of the file starting at origin for its sustain
+// loop, wherever the MARK and INST chunks are, and seeks back to where rs
+// was.
+func readLoop(rs io.ReadSeeker, origin int64) (audio.LoopRegion, error) {
+	pos, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return audio.LoopRegion{}, fmt.Errorf("%w", err)
+	}
+	if _, err := rs.Seek(origin+12, io.SeekStart); err != nil {
+		return audio.LoopRegion{}, fmt.Errorf("%w", err)
+	}
+
+	var loops loopChunks
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(rs, chunk[:]); err != nil {
+			// Whatever is malformed is left to the decoder to find
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return audio.LoopRegion{}, fmt.Errorf("%w", err)
+			}
+			break
+		}
+		id, size := string(chunk[0:4]), int64(binary.BigEndian.Uint32(chunk[4:8]))
+		ok, err := loops.read(rs, id, size)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return audio.LoopRegion{}, err
+		}
+		if !ok {
+			if _, err := rs.Seek(size+size&1, io.SeekCurrent); err != nil {
+				return audio.LoopRegion{}, fmt.Errorf("%w", err)
+			}
+		}
+	}
+
+	if _, err := rs.Seek(pos, io.SeekStart); err != nil {
+		return audio.LoopRegion{}, fmt.Errorf("%w", err)
+	}
+	return loops.region(), nil
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package aiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// mark encodes a MARK chunk body of markers at the given frames, with IDs
+// from 1 and odd and even length names
+func mark(frames ...uint32) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(frames)))
+	for i, f := range frames {
+		b = binary.BigEndian.AppendUint16(b, uint16(i+1))
+		b = binary.BigEndian.AppendUint32(b, f)
+		name := []string{"begin", "end"}[i%2]
+		b = append(b, byte(len(name)))
+		b = append(b, name...)
+		if len(name)%2 == 0 {
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+// inst encodes an INST chunk body whose sustain loop runs between two
+// markers
+func inst(mode, begin, end uint16) []byte {
+	b := make([]byte, 8)
+	b = binary.BigEndian.AppendUint16(b, mode)
+	b = binary.BigEndian.AppendUint16(b, begin)
+	b = binary.BigEndian.AppendUint16(b, end)
+	return append(b, make([]byte, 6)...) // release loop
+}
+
+func TestDecoder_LoopPoints(t *testing.T) {
+	t.Parallel()
+
+	samples := []int16{0, 1, 2, 3, 4, 5, 6, 7}
+	var (
+		commChunk = aiffChunk("COMM", comm(1, len(samples), 16, ""))
+		ssndChunk = aiffChunk("SSND", ssnd(0, samples...))
+		markChunk = aiffChunk("MARK", mark(2, 5))
+		instChunk = aiffChunk("INST", inst(forwardLooping, 1, 2))
+		loop      = audio.LoopRegion{Start: 2, End: 5}
+	)
+
+	tests := []struct {
+		name   string
+		file   []byte
+		stream bool
+		want   audio.LoopRegion
+	}{
+		{name: "none", file: aiffFile("AIFF", commChunk, ssndChunk)},
+		{name: "before the samples", file: aiffFile("AIFF", commChunk, markChunk, instChunk, ssndChunk), want: loop},
+		{name: "before the samples, streamed", file: aiffFile("AIFF", commChunk, markChunk, instChunk, ssndChunk), stream: true, want: loop},
+		{name: "after the samples", file: aiffFile("AIFF", commChunk, ssndChunk, instChunk, markChunk), want: loop},
+		{name: "after the samples, streamed", file: aiffFile("AIFF", commChunk, ssndChunk, instChunk, markChunk), stream: true},
+		{name: "AIFF-C", file: aiffFile("AIFC", aiffChunk("COMM", comm(1, len(samples), 16, "NONE")), ssndChunk, markChunk, instChunk), want: loop},
+		{name: "no loop", file: aiffFile("AIFF", commChunk, markChunk, aiffChunk("INST", inst(0, 1, 2)), ssndChunk)},
+		{name: "forward and backward", file: aiffFile("AIFF", commChunk, markChunk, aiffChunk("INST", inst(2, 1, 2)), ssndChunk)},
+		{name: "missing marker", file: aiffFile("AIFF", commChunk, aiffChunk("MARK", mark(2)), instChunk, ssndChunk)},
+		{name: "malformed", file: aiffFile("AIFF", commChunk, aiffChunk("MARK", []byte{0, 9, 0}), aiffChunk("INST", []byte{1}), ssndChunk)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r io.Reader = bytes.NewReader(tt.file)
+			if tt.stream {
+				r = struct{ io.Reader }{r}
+			}
+			src, err := Decoder{}.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			got, found := src.(audio.LoopPoints).LoopPoints()
+			if got != tt.want || found != (tt.want != audio.LoopRegion{}) {
+				t.Errorf("LoopPoints() = %v, %v, want %v", got, found, tt.want)
+			}
+
+			// The samples read the same
+			buf := make([]float32, 16)
+			n, _ := src.ReadSamples(buf)
+			if n != len(samples) || buf[n-1] != 7.0/32768 {
+				t.Errorf("read %v", buf[:n])
+			}
+		})
+	}
+}
+
+func TestDecoder_LoopPoints_Looper(t *testing.T) {
+	t.Parallel()
+
+	file := aiffFile("AIFF",
+		aiffChunk("COMM", comm(1, 6, 16, "")),
+		aiffChunk("MARK", mark(1, 3)),
+		aiffChunk("INST", inst(forwardLooping, 1, 2)),
+		aiffChunk("SSND", ssnd(0, 0, 1, 2, 3, 4, 5)),
+	)
+	src, err := Decoder{}.Decode(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := audio.Loop(src, 2)
+	if err != nil {
+		t.Fatalf("Loop() error = %v", err)
+	}
+
+	var got []int
+	buf := make([]float32, 4)
+	for {
+		n, err := l.ReadSamples(buf)
+		for _, v := range buf[:n] {
+			got = append(got, int(v*32768))
+		}
+		if err != nil {
+			break
+		}
+	}
+	if want := []int{0, 1, 2, 1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+}
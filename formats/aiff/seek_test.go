@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package aiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+func TestSource_SeekFrame(t *testing.T) {
+	t.Parallel()
+
+	// 10 stereo frames where frame i holds 1000*i and -1000*i
+	const frames = 10
+	samples := make([]int16, 0, frames*2)
+	for i := range frames {
+		samples = append(samples, int16(1000*i), int16(-1000*i))
+	}
+	var file bytes.Buffer
+	if err := WriteAIFF16Interleaved(&file, 8000, 2, samples); err != nil {
+		t.Fatalf("WriteAIFF16Interleaved() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		r    func() io.Reader
+	}{
+		{name: "read seeker", r: func() io.Reader { return bytes.NewReader(file.Bytes()) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := Decoder{}.Decode(tt.r())
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			s, ok := src.(audio.Seeker)
+			if !ok {
+				t.Fatal("source does not implement audio.Seeker")
+			}
+
+			buf := make([]float32, 4)
+			if _, err := src.ReadSamples(buf); err != nil {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+			if got := s.Position(); got != 2 {
+				t.Errorf("Position() = %d, want 2", got)
+			}
+
+			for _, frame := range []int64{8, 1, 0, 9} {
+				if err := s.SeekFrame(frame); err != nil {
+					t.Fatalf("SeekFrame(%d) error = %v", frame, err)
+				}
+				if got := s.Position(); got != frame {
+					t.Errorf("Position() = %d, want %d", got, frame)
+				}
+				n, err := src.ReadSamples(buf[:2])
+				if n != 2 || (err != nil && !errors.Is(err, io.EOF)) {
+					t.Fatalf("ReadSamples() after SeekFrame(%d) = %d, %v", frame, n, err)
+				}
+				want := float32(1000*frame) / 32768
+				if buf[0] != want || buf[1] != -want {
+					t.Errorf("frame %d = %v, want [%v %v]", frame, buf[:2], want, -want)
+				}
+			}
+
+			if err := s.SeekFrame(frames); err != nil {
+				t.Fatalf("SeekFrame(end) error = %v", err)
+			}
+			if n, err := src.ReadSamples(buf); n != 0 || !errors.Is(err, io.EOF) {
+				t.Errorf("ReadSamples() at end = %d, %v, want 0, EOF", n, err)
+			}
+
+			for _, frame := range []int64{-1, frames + 1} {
+				if err := s.SeekFrame(frame); !errors.Is(err, audio.ErrSeekOutOfRange) {
+					t.Errorf("SeekFrame(%d) error = %v, want ErrSeekOutOfRange", frame, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSource_TotalFrames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+		frames   int
+		want     time.Duration
+	}{
+		{name: "mono", rate: 8000, channels: 1, frames: 4000, want: 500 * time.Millisecond},
+		{name: "stereo", rate: 44100, channels: 2, frames: 44100, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var file bytes.Buffer
+			if err := WriteAIFF16Interleaved(&file, tt.rate, tt.channels, make([]int16, tt.frames*tt.channels)); err != nil {
+				t.Fatalf("WriteAIFF16Interleaved() error = %v", err)
+			}
+			src, err := Decoder{}.Decode(bytes.NewReader(file.Bytes()))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			l, ok := src.(audio.Lengther)
+			if !ok {
+				t.Fatal("source does not implement audio.Lengther")
+			}
+			if got := l.TotalFrames(); got != int64(tt.frames) {
+				t.Errorf("TotalFrames() = %d, want %d", got, tt.frames)
+			}
+			if got := l.Duration(); got != tt.want {
+				t.Errorf("Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package aiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// headerSize is the size of the FORM, COMM and SSND headers written by
+// WriteAIFF16Interleaved
+const headerSize = 54
+
+// WriteAIFF16 writes a mono 16-bit PCM AIFF at sampleRate. samples must be
+// int16 PCM. It mirrors wav.WriteWAV16.
+func WriteAIFF16(w io.Writer, sampleRate int, samples []int16) error {
+	return WriteAIFF16Interleaved(w, sampleRate, 1, samples)
+}
+
+// WriteAIFF16Interleaved writes a 16-bit PCM AIFF with the given number of
+// channels at sampleRate. samples holds interleaved frames and its length
+// must be a multiple of channels. Samples are stored big-endian, with FORM,
+// COMM and SSND chunks, as every AIFF reader expects.
+func WriteAIFF16Interleaved(w io.Writer, sampleRate, channels int, samples []int16) error {
+	if channels <= 0 || channels > math.MaxInt16 || len(samples)%channels != 0 {
+		return fmt.Errorf("%w: %d samples for %d channels", ErrInvalidChannelCount, len(samples), channels)
+	}
+	if sampleRate <= 0 {
+		return fmt.Errorf("%w: %d Hz", ErrUnsupportedAiffLayout, sampleRate)
+	}
+
+	dataSize := uint32(len(samples) * 2)
+	header := make([]byte, headerSize)
+
+	// FORM container (12 bytes); 16-bit data never needs a pad byte
+	copy(header[0:4], "FORM")
+	binary.BigEndian.PutUint32(header[4:8], headerSize-8+dataSize)
+	copy(header[8:12], "AIFF")
+
+	// COMM chunk (26 bytes)
+	copy(header[12:16], "COMM")
+	binary.BigEndian.PutUint32(header[16:20], 18)
+	binary.BigEndian.PutUint16(header[20:22], uint16(channels))
+	binary.BigEndian.PutUint32(header[22:26], uint32(len(samples)/channels))
+	binary.BigEndian.PutUint16(header[26:28], 16)
+	rate := float64ToExtended(float64(sampleRate))
+	copy(header[28:38], rate[:])
+
+	// SSND chunk header (16 bytes): no offset, no block alignment
+	copy(header[38:42], "SSND")
+	binary.BigEndian.PutUint32(header[42:46], 8+dataSize)
+
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	const chunkSize = 8192
+	buf := make([]byte, min(len(samples), chunkSize)*2)
+	for i := 0; i < len(samples); i += chunkSize {
+		chunk := samples[i:min(i+chunkSize, len(samples))]
+		out := buf[:len(chunk)*2]
+		for j, s := range chunk {
+			binary.BigEndian.PutUint16(out[j*2:], uint16(s))
+		}
+		if _, err := w.Write(out); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// float64ToExtended encodes a positive f as an IEEE 754 80-bit extended
+// float (sign and 15-bit exponent, then a 64-bit mantissa with an explicit
+// integer bit), big-endian, as AIFF stores its sample rate.
+func float64ToExtended(f float64) [10]byte {
+	var b [10]byte
+	if f <= 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return b
+	}
+
+	// f = frac * 2^exp with frac in [0.5, 1): the mantissa is frac scaled
+	// to 64 bits, its top bit being the integer bit of 1.x * 2^(exp-1)
+	frac, exp := math.Frexp(f)
+	binary.BigEndian.PutUint16(b[0:2], uint16(exp-1+16383))
+	binary.BigEndian.PutUint64(b[2:10], uint64(math.Ldexp(frac, 64)))
+	return b
+}
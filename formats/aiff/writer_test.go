@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package aiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestFloat64ToExtended(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rate float64
+		want string
+	}{
+		{8000, "400bfa00000000000000"},
+		{22050, "400dac44000000000000"},
+		{44100, "400eac44000000000000"},
+		{48000, "400ebb80000000000000"},
+		{96000, "400fbb80000000000000"},
+		{1, "3fff8000000000000000"},
+		{0, "00000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		got := float64ToExtended(tt.rate)
+		if hex.EncodeToString(got[:]) != tt.want {
+			t.Errorf("float64ToExtended(%v) = %x, want %s", tt.rate, got, tt.want)
+		}
+		if back := extendedToFloat64(got); back != tt.rate {
+			t.Errorf("extendedToFloat64(%x) = %v, want %v", got, back, tt.rate)
+		}
+	}
+
+	// Non-integer rates survive the round trip as well
+	for _, rate := range []float64{44056.0, 11025.5, 7999.999} {
+		if back := extendedToFloat64(float64ToExtended(rate)); back != rate {
+			t.Errorf("round trip of %v = %v", rate, back)
+		}
+	}
+}
+
+func TestWriteAIFF16(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+		samples  []int16
+	}{
+		{"mono", 8000, 1, []int16{0, 100, -100, math.MaxInt16, math.MinInt16}},
+		{"stereo", 44100, 2, []int16{1, -1, 2, -2, 3, -3}},
+		{"empty", 48000, 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			var err error
+			if tt.channels == 1 {
+				err = WriteAIFF16(&buf, tt.rate, tt.samples)
+			} else {
+				err = WriteAIFF16Interleaved(&buf, tt.rate, tt.channels, tt.samples)
+			}
+			if err != nil {
+				t.Fatalf("write error = %v", err)
+			}
+
+			data := buf.Bytes()
+			if len(data) != headerSize+len(tt.samples)*2 {
+				t.Fatalf("file is %d bytes, want %d", len(data), headerSize+len(tt.samples)*2)
+			}
+			if string(data[0:4]) != "FORM" || string(data[8:12]) != "AIFF" || string(data[12:16]) != "COMM" || string(data[38:42]) != "SSND" {
+				t.Fatalf("unexpected chunk layout % x", data[:headerSize])
+			}
+			if got := binary.BigEndian.Uint32(data[4:8]); int(got) != len(data)-8 {
+				t.Errorf("FORM size = %d, want %d", got, len(data)-8)
+			}
+			if got := binary.BigEndian.Uint32(data[22:26]); int(got) != len(tt.samples)/tt.channels {
+				t.Errorf("sample frames = %d, want %d", got, len(tt.samples)/tt.channels)
+			}
+			if got := extendedToFloat64([10]byte(data[28:38])); got != float64(tt.rate) {
+				t.Errorf("sample rate = %v, want %d", got, tt.rate)
+			}
+			for i, want := range tt.samples {
+				if got := int16(binary.BigEndian.Uint16(data[headerSize+i*2:])); got != want {
+					t.Errorf("sample %d = %d, want %d", i, got, want)
+				}
+			}
+
+			if len(tt.samples) == 0 {
+				return
+			}
+
+			// Round trip through the decoder
+			src, err := Decoder{}.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if src.SampleRate() != tt.rate || src.Channels() != tt.channels {
+				t.Errorf("decoded %d Hz %d ch, want %d Hz %d ch", src.SampleRate(), src.Channels(), tt.rate, tt.channels)
+			}
+			got := make([]float32, len(tt.samples)+2)
+			n, err := src.ReadSamples(got)
+			if err != nil && err != io.EOF {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+			if n != len(tt.samples) {
+				t.Fatalf("decoded %d samples, want %d", n, len(tt.samples))
+			}
+			for i, want := range tt.samples {
+				if int16(math.Round(float64(got[i])*32768)) != want {
+					t.Errorf("decoded sample %d = %v, want %d", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteAIFF16_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+		samples  []int16
+		wantErr  error
+	}{
+		{"zero channels", 8000, 0, nil, ErrInvalidChannelCount},
+		{"partial frame", 8000, 2, []int16{1}, ErrInvalidChannelCount},
+		{"zero rate", 0, 1, nil, ErrUnsupportedAiffLayout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := WriteAIFF16Interleaved(io.Discard, tt.rate, tt.channels, tt.samples)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("WriteAIFF16Interleaved() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
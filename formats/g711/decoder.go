@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package g711
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+const (
+	formatExtensible = 0xFFFE
+
+	// maxFmtSize bounds the fmt chunk read into memory
+	maxFmtSize = 1024
+
+	// unknownSize and above mark a data chunk running to the end of a
+	// streamed file
+	unknownSize = 0xFFFFFFFE
+)
+
+// Decoder decodes G.711 WAV files.
+type Decoder struct{}
+
+// Decode reads the header of a µ-law or A-law WAV file from r and returns
+// a source for its data chunk. Files with other format tags fail with
+// ErrUnsupportedFormat; the wav package reads PCM ones. The source does
+// not close r. It implements audio.Lengther, from the size of the data
+// chunk.
+func (Decoder) Decode(r io.Reader) (audio.Source, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNotWavFile
+		}
+		return nil, fmt.Errorf("reading RIFF header: %w", err)
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, ErrNotWavFile
+	}
+
+	var (
+		law            Law
+		rate, channels int
+	)
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("%w: no data chunk", ErrUnsupportedFormat)
+			}
+			return nil, fmt.Errorf("reading chunk header: %w", err)
+		}
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			var err error
+			if law, rate, channels, err = parseFmt(r, size); err != nil {
+				return nil, err
+			}
+		case "data":
+			if law == LawUnknown {
+				return nil, fmt.Errorf("%w: data chunk before fmt", ErrUnsupportedFormat)
+			}
+			if size >= unknownSize {
+				return newSource(r, nil, rate, channels, law), nil
+			}
+			src := newSource(io.LimitReader(r, int64(size)), nil, rate, channels, law)
+			src.frames = int64(size) / int64(channels)
+			return src, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size&1)); err != nil {
+				return nil, fmt.Errorf("skipping chunk: %w", err)
+			}
+		}
+	}
+}
+
+// Capabilities reports what the package reads and writes. G.711 stores
+// 8-bit codes rather than PCM samples, so no bit depth is listed.
+func (Decoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{
+		Name:        "G.711",
+		Decode:      true,
+		Encode:      true,
+		MaxChannels: math.MaxUint16,
+	}
+}
+
+// parseFmt decodes a fmt chunk body of the given size, including its pad
+// byte.
+func parseFmt(r io.Reader, size uint32) (Law, int, int, error) {
+	if size < 16 || size > maxFmtSize {
+		return 0, 0, 0, fmt.Errorf("%w: fmt chunk of %d bytes", ErrUnsupportedFormat, size)
+	}
+
+	buf := make([]byte, size+size&1)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, 0, 0, fmt.Errorf("reading fmt chunk: %w", err)
+	}
+
+	tag := binary.LittleEndian.Uint16(buf[0:2])
+	channels := int(binary.LittleEndian.Uint16(buf[2:4]))
+	rate := int(binary.LittleEndian.Uint32(buf[4:8]))
+	bitDepth := binary.LittleEndian.Uint16(buf[14:16])
+
+	// WAVE_FORMAT_EXTENSIBLE stores the real format tag in the first two
+	// bytes of the sub-format GUID
+	if tag == formatExtensible && size >= 40 {
+		tag = binary.LittleEndian.Uint16(buf[24:26])
+	}
+
+	var law Law
+	switch tag {
+	case formatMuLaw:
+		law = MuLaw
+	case formatALaw:
+		law = ALaw
+	default:
+		return 0, 0, 0, fmt.Errorf("%w: format tag %d", ErrUnsupportedFormat, tag)
+	}
+	if bitDepth != 8 {
+		return 0, 0, 0, fmt.Errorf("%w: %d bits per sample", ErrUnsupportedFormat, bitDepth)
+	}
+	if err := (audio.Format{Rate: rate, Channels: channels}).Validate(); err != nil {
+		return 0, 0, 0, fmt.Errorf("%w", err)
+	}
+
+	return law, rate, channels, nil
+}
+
+// NewRawSource reads headerless G.711 codes from r, one byte per sample.
+// If r is an io.Closer it is closed by the source's Close.
+func NewRawSource(r io.Reader, rate, channels int, law Law) (audio.Source, error) {
+	if law != MuLaw && law != ALaw {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownLaw, law)
+	}
+	if err := (audio.Format{Rate: rate, Channels: channels}).Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	c, _ := r.(io.Closer)
+	return newSource(r, c, rate, channels, law), nil
+}
+
+// CodeReader is implemented by sources that can return the G.711 codes of
+// their stream instead of decoding them, as the sources of this package
+// do. Writer.WriteSource and EncodeRaw copy the codes of a CodeReader, and
+// wrappers that pass samples through unchanged, such as the sources
+// audpbx.Open returns, implement it when the source they wrap does.
+type CodeReader interface {
+	// Law returns the law of the codes, MuLaw or ALaw.
+	Law() Law
+	// ReadCodes reads whole frames of codes into p in place of
+	// ReadSamples, and returns the number of codes read; len(p) must be a
+	// multiple of the channel count.
+	ReadCodes(p []byte) (int, error)
+}
+
+type source struct {
+	r        io.Reader
+	closer   io.Closer
+	rate     int
+	channels int
+	law      Law
+	buf      []byte // codes ReadSamples decodes
+	partial  []byte // a partial frame kept for the next read
+	frames   int64  // length from the data chunk, -1 when unknown
+	eof      bool
+}
+
+func newSource(r io.Reader, c io.Closer, rate, channels int, law Law) *source {
+	return &source{r: r, closer: c, rate: rate, channels: channels, law: law, buf: make([]byte, 4096), frames: -1}
+}
+
+func (s *source) SampleRate() int { return s.rate }
+func (s *source) Channels() int   { return s.channels }
+func (s *source) BufSize() int    { return 4096 }
+
+// Format reports 16-bit samples, the precision G.711 expands to.
+func (s *source) Format() audio.Format {
+	return audio.Format{
+		Rate:       s.rate,
+		Channels:   s.channels,
+		Layout:     audio.DefaultLayout(s.channels),
+		SampleKind: audio.SampleInt16,
+	}
+}
+
+// TotalFrames returns the length recorded by the data chunk of a WAV file,
+// or -1 for raw streams and WAV files written to a pipe.
+func (s *source) TotalFrames() int64 { return s.frames }
+
+func (s *source) Duration() time.Duration {
+	return audio.FramesDuration(s.frames, s.rate)
+}
+
+func (s *source) Close() error {
+	if s.closer != nil {
+		if err := s.closer.Close(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+	return nil
+}
+
+// ReadSamples decodes whole frames into dst. A partial frame at the end of
+// the stream is dropped.
+func (s *source) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%s.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+	if cap(s.buf) < len(dst) {
+		s.buf = make([]byte, len(dst))
+	}
+
+	n, err := s.ReadCodes(s.buf[:len(dst)])
+	for i, b := range s.buf[:n] {
+		dst[i] = float32(s.law.decode(b)) / 32768
+	}
+	return n, err
+}
+
+// Law returns the law of the codes of the stream.
+func (s *source) Law() Law { return s.law }
+
+// ReadCodes reads whole frames of codes into p, as ReadSamples decodes
+// them. A partial frame at the end of the stream is dropped.
+func (s *source) ReadCodes(p []byte) (int, error) {
+	if len(p)%s.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+	if s.eof {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// Read at least one whole frame unless the stream ends
+	have := copy(p, s.partial)
+	var err error
+	for have < s.channels && err == nil {
+		var n int
+		n, err = s.r.Read(p[have:])
+		have += n
+	}
+	if errors.Is(err, io.EOF) {
+		s.eof = true
+		err = nil
+	} else if err != nil {
+		err = fmt.Errorf("%w", err)
+	}
+
+	// Keep the partial frame for the next call
+	n := have - have%s.channels
+	s.partial = append(s.partial[:0], p[n:have]...)
+
+	if s.eof {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// copyCodes passes the codes of src to write as codes of law without
+// decoding them, whole frames at a time, and returns the number of frames.
+// Codes of the same law come out bit for bit, including the negative zero
+// of µ-law that decoding folds into zero.
+func copyCodes(src audio.Source, codes CodeReader, law Law, write func([]byte) error) (int64, error) {
+	ch := src.Channels()
+	table := transcodeTable(codes.Law(), law)
+	size := max(src.BufSize(), 1024)
+	buf := make([]byte, max(size-size%ch, ch))
+
+	var frames int64
+	for {
+		n, err := codes.ReadCodes(buf)
+		n -= n % ch
+		if n > 0 {
+			if table != nil {
+				for i, b := range buf[:n] {
+					buf[i] = table[b]
+				}
+			}
+			if werr := write(buf[:n]); werr != nil {
+				return frames, werr
+			}
+			frames += int64(n / ch)
+		}
+
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, fmt.Errorf("reading source: %w", err)
+		}
+	}
+}
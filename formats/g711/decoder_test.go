@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package g711
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func readAll(t *testing.T, src audio.Source, chunk int) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, chunk)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+// wavFile builds a G.711 WAV file with a fmt chunk of tag and bitDepth,
+// the given extra chunks before the data and data codes
+func wavFile(tag, bitDepth uint16, channels int, extra []byte, data []byte) []byte {
+	var b []byte
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, "WAVE"...)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, tag)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, 8000)
+	b = binary.LittleEndian.AppendUint32(b, uint32(8000*channels))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint16(b, bitDepth)
+	b = append(b, extra...)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+func TestDecoder(t *testing.T) {
+	t.Parallel()
+
+	// A LIST chunk of odd size with its pad byte
+	list := append([]byte("LIST\x03\x00\x00\x00abc"), 0)
+
+	tests := []struct {
+		name     string
+		file     []byte
+		channels int
+		want     []float32
+	}{
+		{
+			name:     "mulaw",
+			file:     wavFile(formatMuLaw, 8, 1, nil, []byte{0xff, 0x00, 0x80}),
+			channels: 1,
+			want:     []float32{0, -32124.0 / 32768, 32124.0 / 32768},
+		},
+		{
+			name:     "alaw stereo with chunks",
+			file:     append(wavFile(formatALaw, 8, 2, list, []byte{0xd5, 0x55, 0xaa, 0x2a}), "JUNK\x00\x00\x00\x00"...),
+			channels: 2,
+			want:     []float32{8.0 / 32768, -8.0 / 32768, 32256.0 / 32768, -32256.0 / 32768},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := Decoder{}.Decode(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if src.SampleRate() != 8000 || src.Channels() != tt.channels {
+				t.Errorf("format = %d Hz %d ch, want 8000 Hz %d ch", src.SampleRate(), src.Channels(), tt.channels)
+			}
+			if kind := audio.FormatOf(src).SampleKind; kind != audio.SampleInt16 {
+				t.Errorf("SampleKind = %v, want %v", kind, audio.SampleInt16)
+			}
+			if l, ok := src.(audio.Lengther); !ok || l.TotalFrames() != int64(len(tt.want)/tt.channels) {
+				t.Errorf("source is not an audio.Lengther of %d frames", len(tt.want)/tt.channels)
+			}
+
+			got := readAll(t, src, 2*tt.channels)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d samples, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("sample %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		file []byte
+		want error
+	}{
+		{"empty", nil, ErrNotWavFile},
+		{"not riff", []byte("RIFX\x00\x00\x00\x00WAVE"), ErrNotWavFile},
+		{"pcm", wavFile(1, 16, 1, nil, []byte{0, 0}), ErrUnsupportedFormat},
+		{"16-bit mulaw", wavFile(formatMuLaw, 16, 1, nil, []byte{0, 0}), ErrUnsupportedFormat},
+		{"no channels", wavFile(formatMuLaw, 8, 0, nil, []byte{0}), audio.ErrInvalidChannels},
+		{"no data", wavFile(formatALaw, 8, 1, nil, nil)[:36], ErrUnsupportedFormat},
+		{"data before fmt", []byte("RIFF\x00\x00\x00\x00WAVEdata\x01\x00\x00\x00\xff"), ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := (Decoder{}).Decode(bytes.NewReader(tt.file)); !errors.Is(err, tt.want) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// Short reads from the underlying reader must not split frames, and a
+// trailing partial frame is dropped.
+func TestRawSource_ShortReads(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 0, 2*100+1)
+	for i := range 100 {
+		data = append(data, EncodeAlaw(int16(i*100)), EncodeAlaw(int16(-i*100)))
+	}
+	data = append(data, 0xd5) // partial frame
+
+	r := audiotest.NewFaultyReader(iotest.OneByteReader(bytes.NewReader(data)), audiotest.WithShortReads(3))
+	src, err := NewRawSource(r, 8000, 2, ALaw)
+	if err != nil {
+		t.Fatalf("NewRawSource() error = %v", err)
+	}
+
+	got := readAll(t, src, 14)
+	if len(got) != 200 {
+		t.Fatalf("got %d samples, want 200", len(got))
+	}
+	for i := range 200 {
+		if want := float32(DecodeAlaw(data[i])) / 32768; got[i] != want {
+			t.Fatalf("sample %d = %v, want %v", i, got[i], want)
+		}
+	}
+}
+
+func TestRawSource_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewRawSource(bytes.NewReader(nil), 8000, 1, LawUnknown); !errors.Is(err, ErrUnknownLaw) {
+		t.Errorf("NewRawSource(unknown) error = %v, want %v", err, ErrUnknownLaw)
+	}
+	if _, err := NewRawSource(bytes.NewReader(nil), 0, 1, MuLaw); !errors.Is(err, audio.ErrInvalidSampleRate) {
+		t.Errorf("NewRawSource(0 Hz) error = %v, want %v", err, audio.ErrInvalidSampleRate)
+	}
+
+	r := audiotest.NewFaultyReader(bytes.NewReader(make([]byte, 100)), audiotest.WithErrorOnCall(2, audiotest.ErrInjected), audiotest.WithShortReads(10))
+	src, err := NewRawSource(r, 8000, 1, MuLaw)
+	if err != nil {
+		t.Fatalf("NewRawSource() error = %v", err)
+	}
+	if _, err := src.ReadSamples(make([]float32, 3)); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	if _, err := src.ReadSamples(make([]float32, 3)); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want %v", err, audiotest.ErrInjected)
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package g711 reads and writes ITU-T G.711 audio, the µ-law and A-law
+// companding used by the telephone network: µ-law in North America and
+// Japan, A-law everywhere else. Each 8-bit code expands to a 14 or 13-bit
+// linear sample, so a 64 kbit/s channel carries 8 kHz speech.
+//
+// # Decoding
+//
+// Decoder reads WAV files whose fmt chunk has format tag 6 (A-law) or 7
+// (µ-law), as written by PBXs and voicemail systems:
+//
+//	source, err := g711.Decoder{}.Decode(file)
+//
+// Headerless streams, such as RTP payloads or .ul/.al files, carry no
+// format, so the caller gives it:
+//
+//	source, err := g711.NewRawSource(conn, 8000, 1, g711.MuLaw)
+//
+// Samples are returned as float32 in [-1, 1).
+//
+// # Encoding
+//
+// EncodeMulaw and EncodeAlaw compress one 16-bit sample, and DecodeMulaw
+// and DecodeAlaw expand one code, for callers that frame the bytes
+// themselves. Writer streams WAV files with format tag 6 or 7:
+//
+//	w, _ := g711.NewWriter(file, audio.Format{Rate: 8000, Channels: 1}, g711.ALaw)
+//	_, err = w.WriteSource(source)
+//	err = w.Close()
+//
+// Encode does the same in one call, and EncodeRaw writes headerless codes.
+// G.711 is defined for 8 kHz mono; the writer accepts other rates and
+// channel counts, which most players read too.
+//
+// # Passthrough
+//
+// When the source given to Writer.WriteSource, Encode or EncodeRaw is a
+// CodeReader, as the sources of this package and those audpbx.Open returns
+// for G.711 files are, its codes are copied instead of being decoded and
+// encoded again. Re-containerizing, raw to WAV or WAV to raw, is then
+// bit-exact and costs little more than the copy; converting between
+// µ-law and A-law maps each code through a table:
+//
+//	src, _ := audpbx.Open("call.wav") // µ-law
+//	_, err := g711.EncodeRaw(out, src, g711.MuLaw)
+package g711
@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package g711
+
+import "errors"
+
+var (
+	ErrNotWavFile        = errors.New("not a WAV file")
+	ErrUnsupportedFormat = errors.New("not a G.711 WAV file")
+	ErrUnknownLaw        = errors.New("unknown G.711 law")
+	ErrWriterClosed      = errors.New("G.711 writer is closed")
+)
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package g711
+
+import "math/bits"
+
+// Law is a G.711 companding law.
+type Law int
+
+const (
+	LawUnknown Law = iota
+	MuLaw
+	ALaw
+)
+
+// WAVE format tags of G.711
+const (
+	formatALaw  = 6
+	formatMuLaw = 7
+)
+
+// String returns the common short name of the law.
+func (l Law) String() string {
+	switch l {
+	case MuLaw:
+		return "mulaw"
+	case ALaw:
+		return "alaw"
+	default:
+		return "unknown"
+	}
+}
+
+// formatTag returns the WAVE format tag of the law
+func (l Law) formatTag() uint16 {
+	if l == ALaw {
+		return formatALaw
+	}
+	return formatMuLaw
+}
+
+// decode expands one code of the law
+func (l Law) decode(b byte) int16 {
+	if l == ALaw {
+		return aLawTable[b]
+	}
+	return muLawTable[b]
+}
+
+// encode compresses one sample with the law
+func (l Law) encode(s int16) byte {
+	if l == ALaw {
+		return EncodeAlaw(s)
+	}
+	return EncodeMulaw(s)
+}
+
+// transcodeTable returns the table mapping codes of from to the nearest
+// codes of to, or nil when the laws are the same
+func transcodeTable(from, to Law) *[256]byte {
+	switch {
+	case from == to:
+		return nil
+	case to == ALaw:
+		return &muLawToALaw
+	default:
+		return &aLawToMuLaw
+	}
+}
+
+var muLawToALaw, aLawToMuLaw = func() (ma, am [256]byte) {
+	for i := range 256 {
+		ma[i] = EncodeAlaw(muLawTable[i])
+		am[i] = EncodeMulaw(aLawTable[i])
+	}
+	return ma, am
+}()
+
+// DecodeMulaw expands a µ-law code to a 16-bit sample.
+func DecodeMulaw(b byte) int16 { return muLawTable[b] }
+
+// DecodeAlaw expands an A-law code to a 16-bit sample.
+func DecodeAlaw(b byte) int16 { return aLawTable[b] }
+
+// EncodeMulaw compresses a 16-bit sample to µ-law. Samples beyond the
+// 14-bit range of the law are clipped.
+func EncodeMulaw(s int16) byte {
+	const (
+		bias = 0x84
+		clip = 32635
+	)
+
+	v := int32(s)
+	var sign byte
+	if v < 0 {
+		sign = 0x80
+		v = -v
+	}
+	v = min(v, clip) + bias
+
+	// The segment is the position of the highest set bit above bit 7
+	exp := max(bits.Len32(uint32(v>>7))-1, 0)
+	mantissa := byte(v>>(exp+3)) & 0x0f
+	return ^(sign | byte(exp)<<4 | mantissa)
+}
+
+// EncodeAlaw compresses a 16-bit sample to A-law, keeping the 13 most
+// significant bits.
+func EncodeAlaw(s int16) byte {
+	v := int32(s) >> 3
+	mask := byte(0xd5)
+	if v < 0 {
+		mask = 0x55
+		v = -v - 1
+	}
+
+	// 13 bits fit the 8 segments, no clipping is needed
+	seg := bits.Len32(uint32(v >> 5))
+	code := byte(seg) << 4
+	if seg < 2 {
+		code |= byte(v>>1) & 0x0f
+	} else {
+		code |= byte(v>>seg) & 0x0f
+	}
+	return code ^ mask
+}
+
+// G.711 expansion tables
+var muLawTable, aLawTable = func() (mu, a [256]int16) {
+	for i := range 256 {
+		// μ-law: bits are inverted, then sign, 3 bit exponent, 4 bit mantissa
+		u := ^byte(i)
+		exp := (u >> 4) & 0x07
+		v := (int16(u&0x0f)<<3 + 0x84) << exp
+		v -= 0x84
+		if u&0x80 != 0 {
+			v = -v
+		}
+		mu[i] = v
+
+		// A-law: even bits are inverted
+		x := byte(i) ^ 0x55
+		exp = (x >> 4) & 0x07
+		m := int16(x & 0x0f)
+		switch exp {
+		case 0:
+			v = m<<4 + 8
+		default:
+			v = (m<<4 + 0x108) << (exp - 1)
+		}
+		if x&0x80 == 0 {
+			v = -v
+		}
+		a[i] = v
+	}
+	return mu, a
+}()
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package g711
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		law  Law
+		code byte
+		want int16
+	}{
+		{"mulaw zero", MuLaw, 0xff, 0},
+		{"mulaw negative zero", MuLaw, 0x7f, 0},
+		{"mulaw min", MuLaw, 0x00, -32124},
+		{"mulaw max", MuLaw, 0x80, 32124},
+		{"alaw smallest", ALaw, 0xd5, 8},
+		{"alaw smallest negative", ALaw, 0x55, -8},
+		{"alaw max", ALaw, 0xaa, 32256},
+		{"alaw min", ALaw, 0x2a, -32256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.law.decode(tt.code); got != tt.want {
+				t.Errorf("decode(%#x) = %d, want %d", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+// Every code decodes to a value that encodes back to it, except the
+// negative zero of µ-law.
+func TestEncode_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, law := range []Law{MuLaw, ALaw} {
+		for i := range 256 {
+			code := byte(i)
+			want := code
+			if law == MuLaw && code == 0x7f {
+				want = 0xff
+			}
+			if got := law.encode(law.decode(code)); got != want {
+				t.Errorf("%v: encode(decode(%#x)) = %#x, want %#x", law, code, got, want)
+			}
+		}
+	}
+}
+
+// The quantization error grows with the level but stays within a step of
+// the segment, about 6% of the value.
+func TestEncode_Error(t *testing.T) {
+	t.Parallel()
+
+	for _, law := range []Law{MuLaw, ALaw} {
+		for s := math.MinInt16; s <= math.MaxInt16; s += 7 {
+			got := float64(law.decode(law.encode(int16(s))))
+			bound := max(math.Abs(float64(s))/16, 16)
+			// µ-law clips at 32635 and saturates at 32124
+			if law == MuLaw && math.Abs(float64(s)) > 32124 {
+				bound = math.Abs(float64(s)) - 32124 + bound
+			}
+			if d := math.Abs(got - float64(s)); d > bound {
+				t.Fatalf("%v: %d encodes to %v, off by %v", law, s, got, d)
+			}
+		}
+	}
+}
+
+func TestLaw_String(t *testing.T) {
+	t.Parallel()
+
+	for law, want := range map[Law]string{MuLaw: "mulaw", ALaw: "alaw", LawUnknown: "unknown"} {
+		if got := law.String(); got != want {
+			t.Errorf("Law(%d).String() = %q, want %q", law, got, want)
+		}
+	}
+}
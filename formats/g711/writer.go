@@ -0,0 +1,322 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package g711
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/utils"
+)
+
+// HeaderSize is the size of the header written by Writer: RIFF, an
+// 18-byte fmt chunk, the fact chunk that non-PCM WAV files carry and the
+// data chunk header.
+const HeaderSize = 58
+
+// Offsets of the sizes Writer.Close fills in
+const (
+	riffSizeOffset = 4
+	factOffset     = 46
+	dataSizeOffset = 54
+)
+
+// header returns the header of a G.711 WAV file of frames frames. A
+// negative frames marks the sizes as unknown.
+func header(law Law, rate, channels int, frames int64) []byte {
+	riffSize, factFrames, dataSize := uint32(unknownSize), uint32(unknownSize), uint32(unknownSize)
+	if frames >= 0 {
+		dataSize = uint32(frames * int64(channels))
+		riffSize = HeaderSize - 8 + dataSize + dataSize&1
+		factFrames = uint32(frames)
+	}
+
+	h := make([]byte, 0, HeaderSize)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, riffSize)
+	h = append(h, "WAVE"...)
+
+	h = append(h, "fmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 18)
+	h = binary.LittleEndian.AppendUint16(h, law.formatTag())
+	h = binary.LittleEndian.AppendUint16(h, uint16(channels))
+	h = binary.LittleEndian.AppendUint32(h, uint32(rate))
+	h = binary.LittleEndian.AppendUint32(h, uint32(rate*channels)) // byte rate
+	h = binary.LittleEndian.AppendUint16(h, uint16(channels))      // block align
+	h = binary.LittleEndian.AppendUint16(h, 8)
+	h = binary.LittleEndian.AppendUint16(h, 0) // no extension
+
+	h = append(h, "fact"...)
+	h = binary.LittleEndian.AppendUint32(h, 4)
+	h = binary.LittleEndian.AppendUint32(h, factFrames)
+
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, dataSize)
+	return h
+}
+
+// Writer encodes µ-law or A-law WAV files incrementally.
+//
+// The header is written by NewWriter with the sizes marked as unknown. If
+// the underlying writer is an io.WriteSeeker, Close seeks back and fills
+// them in; otherwise the markers stay, which Decoder reads as "until the
+// end of the file".
+type Writer struct {
+	w        io.Writer
+	law      Law
+	channels int
+	rate     int
+	start    int64 // offset of the header, -1 when w cannot seek
+	data     int64 // bytes of sample data written
+	buf      []byte
+	err      error
+	closed   bool
+}
+
+// NewWriter writes a G.711 WAV header for format to w and returns a Writer
+// for its samples. Only format.Rate and format.Channels are used.
+func NewWriter(w io.Writer, format audio.Format, law Law) (*Writer, error) {
+	if law != MuLaw && law != ALaw {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownLaw, law)
+	}
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if format.Channels > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: %d channels", audio.ErrInvalidChannels, format.Channels)
+	}
+
+	ww := &Writer{w: w, law: law, channels: format.Channels, rate: format.Rate, start: -1}
+	if s, ok := w.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			ww.start = pos
+		}
+	}
+
+	if _, err := w.Write(header(law, format.Rate, format.Channels, -1)); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return ww, nil
+}
+
+func (w *Writer) SampleRate() int { return w.rate }
+func (w *Writer) Channels() int   { return w.channels }
+
+// Frames returns the number of sample frames written so far.
+func (w *Writer) Frames() int64 { return w.data / int64(w.channels) }
+
+// WriteSamples encodes interleaved float32 samples in [-1, 1]; values
+// outside are clipped. len(samples) must be a multiple of Channels().
+func (w *Writer) WriteSamples(samples []float32) error {
+	if err := w.check(len(samples)); err != nil {
+		return err
+	}
+
+	buf := w.buffer(len(samples))
+	for i, s := range samples {
+		buf[i] = w.law.encode(utils.Float32ToInt16(s))
+	}
+	return w.write(buf)
+}
+
+// WriteInt16 encodes interleaved int16 samples. len(samples) must be a
+// multiple of Channels().
+func (w *Writer) WriteInt16(samples []int16) error {
+	if err := w.check(len(samples)); err != nil {
+		return err
+	}
+
+	buf := w.buffer(len(samples))
+	for i, s := range samples {
+		buf[i] = w.law.encode(s)
+	}
+	return w.write(buf)
+}
+
+// WriteSource copies src to the end and returns the number of sample
+// frames written. src must have the rate and channel count of the Writer.
+// It does not close src.
+//
+// A CodeReader, such as a source of this package, is copied without
+// decoding and encoding it, which is faster and keeps same-law codes
+// bit-exact; A-law and µ-law are converted code by code.
+func (w *Writer) WriteSource(src audio.Source) (int64, error) {
+	if src.SampleRate() != w.rate || src.Channels() != w.channels {
+		return 0, fmt.Errorf("%w: source is %d Hz %d ch, writer %d Hz %d ch",
+			audio.ErrFormatMismatch, src.SampleRate(), src.Channels(), w.rate, w.channels)
+	}
+	if codes, ok := src.(CodeReader); ok {
+		return copyCodes(src, codes, w.law, func(b []byte) error {
+			if err := w.check(len(b)); err != nil {
+				return err
+			}
+			return w.write(b)
+		})
+	}
+
+	size := max(src.BufSize(), 1024)
+	buf := make([]float32, size-size%w.channels)
+	var frames int64
+	for {
+		n, err := src.ReadSamples(buf)
+		n -= n % w.channels
+		if n > 0 {
+			if werr := w.WriteSamples(buf[:n]); werr != nil {
+				return frames, werr
+			}
+			frames += int64(n / w.channels)
+		}
+
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, fmt.Errorf("reading source: %w", err)
+		}
+	}
+}
+
+// Close pads the data chunk to an even size and fills in the sizes in the
+// header when the underlying writer can seek, leaving it positioned at the
+// end of the file. It does not close the underlying writer. Writes after
+// Close fail with ErrWriterClosed.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+	if w.data&1 != 0 {
+		if _, err := w.w.Write([]byte{0}); err != nil {
+			w.err = fmt.Errorf("%w", err)
+			return w.err
+		}
+	}
+
+	// Files beyond 4 GiB keep the unknown size markers
+	if w.start < 0 || w.data >= unknownSize-HeaderSize {
+		return nil
+	}
+	ws, ok := w.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+
+	h := header(w.law, w.rate, w.channels, w.Frames())
+	for _, off := range []int64{riffSizeOffset, factOffset, dataSizeOffset} {
+		if _, err := ws.Seek(w.start+off, io.SeekStart); err != nil {
+			return fmt.Errorf("patching header: %w", err)
+		}
+		if _, err := ws.Write(h[off : off+4]); err != nil {
+			return fmt.Errorf("patching header: %w", err)
+		}
+	}
+
+	if _, err := ws.Seek(w.start+HeaderSize+w.data+w.data&1, io.SeekStart); err != nil {
+		return fmt.Errorf("patching header: %w", err)
+	}
+	return nil
+}
+
+// check validates a write of n samples
+func (w *Writer) check(n int) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if n%w.channels != 0 {
+		return fmt.Errorf("%w: %d samples for %d channels", audio.ErrInvalidDstSize, n, w.channels)
+	}
+	return nil
+}
+
+func (w *Writer) buffer(size int) []byte {
+	if cap(w.buf) < size {
+		w.buf = make([]byte, size)
+	}
+	return w.buf[:size]
+}
+
+// write writes encoded samples. A failed write leaves the file in an
+// unknown state, so the error is returned by every later call.
+func (w *Writer) write(buf []byte) error {
+	n, err := w.w.Write(buf)
+	w.data += int64(n)
+	if err != nil {
+		w.err = fmt.Errorf("%w", err)
+		return w.err
+	}
+	return nil
+}
+
+// Encode writes src to w as a G.711 WAV file using law. It does not close
+// src or w.
+func Encode(w io.Writer, src audio.Source, law Law) error {
+	ww, err := NewWriter(w, audio.FormatOf(src), law)
+	if err != nil {
+		return err
+	}
+	if _, err := ww.WriteSource(src); err != nil {
+		return err
+	}
+	return ww.Close()
+}
+
+// EncodeRaw writes src to w as headerless G.711 codes of law and returns
+// the number of sample frames written. Like Writer.WriteSource it copies
+// a CodeReader without decoding it, so a WAV file is turned into a raw one
+// bit for bit. It does not close src or w.
+func EncodeRaw(w io.Writer, src audio.Source, law Law) (int64, error) {
+	if law != MuLaw && law != ALaw {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownLaw, law)
+	}
+	ch := src.Channels()
+	if ch <= 0 {
+		return 0, fmt.Errorf("%w: %d", audio.ErrInvalidChannels, ch)
+	}
+
+	write := func(b []byte) error {
+		if _, err := w.Write(b); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		return nil
+	}
+	if codes, ok := src.(CodeReader); ok {
+		return copyCodes(src, codes, law, write)
+	}
+
+	size := max(src.BufSize(), 1024)
+	buf := make([]float32, size-size%ch)
+	codes := make([]byte, len(buf))
+	var frames int64
+	for {
+		n, err := src.ReadSamples(buf)
+		n -= n % ch
+		if n > 0 {
+			for i, v := range buf[:n] {
+				codes[i] = law.encode(utils.Float32ToInt16(v))
+			}
+			if werr := write(codes[:n]); werr != nil {
+				return frames, werr
+			}
+			frames += int64(n / ch)
+		}
+
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, fmt.Errorf("reading source: %w", err)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package g711
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// errWriter fails every write after the first ok ones
+type errWriter struct {
+	ok int
+}
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.ok == 0 {
+		return 0, audiotest.ErrInjected
+	}
+	w.ok--
+	return len(p), nil
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		law      Law
+		channels int
+		frames   int
+		seekable bool
+	}{
+		{"mulaw file", MuLaw, 1, 1000, true},
+		{"alaw file odd size", ALaw, 1, 333, true},
+		{"alaw stereo pipe", ALaw, 2, 500, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src := audiotest.NewSineSource(8000, tt.channels, tt.frames, 440)
+			var (
+				file *os.File
+				buf  bytes.Buffer
+				err  error
+			)
+			if tt.seekable {
+				file, err = os.Create(filepath.Join(t.TempDir(), "out.wav"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+				err = Encode(file, src, tt.law)
+			} else {
+				err = Encode(&buf, src, tt.law)
+			}
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			data := buf.Bytes()
+			if tt.seekable {
+				if data, err = os.ReadFile(file.Name()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			size := tt.frames * tt.channels
+			if got, want := len(data), HeaderSize+size+size%2; got != want {
+				t.Fatalf("file is %d bytes, want %d", got, want)
+			}
+			if tag := binary.LittleEndian.Uint16(data[20:22]); tag != tt.law.formatTag() {
+				t.Errorf("format tag = %d, want %d", tag, tt.law.formatTag())
+			}
+
+			wantData, wantFact := uint32(unknownSize), uint32(unknownSize)
+			if tt.seekable {
+				wantData, wantFact = uint32(size), uint32(tt.frames)
+			}
+			if got := binary.LittleEndian.Uint32(data[dataSizeOffset:]); got != wantData {
+				t.Errorf("data size = %d, want %d", got, wantData)
+			}
+			if got := binary.LittleEndian.Uint32(data[factOffset:]); got != wantFact {
+				t.Errorf("fact frames = %d, want %d", got, wantFact)
+			}
+
+			dec, err := Decoder{}.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			got := readAll(t, dec, 256)
+			// Without a known size the pad byte reads as a sample
+			want := size
+			if !tt.seekable {
+				want += size % 2
+			}
+			if len(got) != want {
+				t.Fatalf("decoded %d samples, want %d", len(got), want)
+			}
+
+			ref := audiotest.NewSineSource(8000, tt.channels, tt.frames, 440)
+			orig := readAll(t, ref, 256)
+			for i := range orig {
+				if d := got[i] - orig[i]; d > 0.04 || d < -0.04 {
+					t.Fatalf("sample %d = %v, want about %v", i, got[i], orig[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	t.Parallel()
+
+	format := audio.Format{Rate: 8000, Channels: 2}
+	if _, err := NewWriter(&bytes.Buffer{}, format, LawUnknown); !errors.Is(err, ErrUnknownLaw) {
+		t.Errorf("NewWriter(unknown) error = %v, want %v", err, ErrUnknownLaw)
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, audio.Format{Rate: 8000}, MuLaw); !errors.Is(err, audio.ErrInvalidChannels) {
+		t.Errorf("NewWriter(0 channels) error = %v, want %v", err, audio.ErrInvalidChannels)
+	}
+
+	w, err := NewWriter(&bytes.Buffer{}, format, MuLaw)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.WriteInt16([]int16{1, 2, 3}); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("WriteInt16(3 samples) error = %v, want %v", err, audio.ErrInvalidDstSize)
+	}
+	if _, err := w.WriteSource(audiotest.NewSilentSource(16000, 2, 10)); !errors.Is(err, audio.ErrFormatMismatch) {
+		t.Errorf("WriteSource(16 kHz) error = %v, want %v", err, audio.ErrFormatMismatch)
+	}
+	if err := w.WriteInt16([]int16{1, 2}); err != nil {
+		t.Fatalf("WriteInt16() error = %v", err)
+	}
+	if got := w.Frames(); got != 1 {
+		t.Errorf("Frames() = %d, want 1", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := w.WriteSamples([]float32{0, 0}); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("WriteSamples() after Close error = %v, want %v", err, ErrWriterClosed)
+	}
+
+	w, err = NewWriter(&errWriter{ok: 1}, format, ALaw)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.WriteSamples([]float32{0, 0}); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("WriteSamples() error = %v, want %v", err, audiotest.ErrInjected)
+	}
+	if err := w.Close(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Close() error = %v, want %v", err, audiotest.ErrInjected)
+	}
+}
+
+// G.711 sources are copied code for code, so every code survives, even
+// the µ-law negative zero that decoding would turn into 0xff.
+func TestWriter_Passthrough(t *testing.T) {
+	t.Parallel()
+
+	codes := make([]byte, 0, 2*256+1)
+	for i := range 256 {
+		codes = append(codes, byte(i), byte(255-i))
+	}
+	codes = append(codes, 0x7f) // partial frame, dropped
+	whole := codes[:512]
+
+	convert := func(table *[256]byte) []byte {
+		out := make([]byte, len(whole))
+		for i, b := range whole {
+			out[i] = table[b]
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		from  Law
+		to    Law
+		wav   bool // WAV output, raw otherwise
+		short bool // short reads from the input
+		want  []byte
+	}{
+		{name: "mulaw raw to wav", from: MuLaw, to: MuLaw, wav: true, want: whole},
+		{name: "alaw raw to raw", from: ALaw, to: ALaw, short: true, want: whole},
+		{name: "mulaw to alaw", from: MuLaw, to: ALaw, wav: true, short: true, want: convert(&muLawToALaw)},
+		{name: "alaw to mulaw", from: ALaw, to: MuLaw, want: convert(&aLawToMuLaw)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var in io.Reader = bytes.NewReader(codes)
+			if tt.short {
+				in = iotest.OneByteReader(in)
+			}
+			src, err := NewRawSource(in, 8000, 2, tt.from)
+			if err != nil {
+				t.Fatalf("NewRawSource() error = %v", err)
+			}
+
+			var out bytes.Buffer
+			var frames int64
+			if tt.wav {
+				w, err := NewWriter(&out, audio.FormatOf(src), tt.to)
+				if err != nil {
+					t.Fatalf("NewWriter() error = %v", err)
+				}
+				if frames, err = w.WriteSource(src); err != nil {
+					t.Fatalf("WriteSource() error = %v", err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatalf("Close() error = %v", err)
+				}
+			} else if frames, err = EncodeRaw(&out, src, tt.to); err != nil {
+				t.Fatalf("EncodeRaw() error = %v", err)
+			}
+
+			if frames != 256 {
+				t.Errorf("wrote %d frames, want 256", frames)
+			}
+			got := out.Bytes()
+			if tt.wav {
+				got = got[HeaderSize:]
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("output differs from the expected codes")
+			}
+
+			// Converting between laws matches decoding and encoding
+			if tt.from != tt.to {
+				for i, b := range whole {
+					if want := tt.to.encode(tt.from.decode(b)); got[i] != want {
+						t.Fatalf("code %#x converted to %#x, want %#x", b, got[i], want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeRaw(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewConstantSource(8000, 2, 100, 0.5)
+	var out bytes.Buffer
+	frames, err := EncodeRaw(&out, src, ALaw)
+	if err != nil {
+		t.Fatalf("EncodeRaw() error = %v", err)
+	}
+	if frames != 100 || out.Len() != 200 {
+		t.Fatalf("EncodeRaw() = %d frames, %d bytes, want 100, 200", frames, out.Len())
+	}
+	if want := EncodeAlaw(16384); out.Bytes()[199] != want {
+		t.Errorf("code = %#x, want %#x", out.Bytes()[199], want)
+	}
+
+	if _, err := EncodeRaw(&out, src, LawUnknown); !errors.Is(err, ErrUnknownLaw) {
+		t.Errorf("EncodeRaw(unknown) error = %v, want %v", err, ErrUnknownLaw)
+	}
+	if _, err := EncodeRaw(&errWriter{}, audiotest.NewConstantSource(8000, 1, 10, 0), MuLaw); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("EncodeRaw() write error = %v, want %v", err, audiotest.ErrInjected)
+	}
+}
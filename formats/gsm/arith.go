@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+import (
+	"math"
+	"math/bits"
+)
+
+// The codec is specified in 16 and 32-bit fixed point, down to how each
+// operation rounds and saturates, so that every implementation decodes a
+// frame to the same samples. These are the basic operations of GSM 06.10
+// section 5.1.
+
+func add(a, b int16) int16 { return sat16(int32(a) + int32(b)) }
+func sub(a, b int16) int16 { return sat16(int32(a) - int32(b)) }
+
+func sat16(v int32) int16 {
+	return int16(min(max(v, math.MinInt16), math.MaxInt16))
+}
+
+// addL is add for 32-bit values
+func addL(a, b int64) int64 {
+	return min(max(a+b, math.MinInt32), math.MaxInt32)
+}
+
+// mult and multR are a*b in Q15, truncated and rounded. The only product
+// out of range, -1 * -1, wraps as in the reference code; multRSat
+// saturates it, for the synthesis filter that the standard defines so.
+func mult(a, b int16) int16  { return int16(int32(a) * int32(b) >> 15) }
+func multR(a, b int16) int16 { return int16((int32(a)*int32(b) + 16384) >> 15) }
+
+func multRSat(a, b int16) int16 {
+	if a == math.MinInt16 && b == math.MinInt16 {
+		return math.MaxInt16
+	}
+	return multR(a, b)
+}
+
+func abs16(a int16) int16 {
+	switch {
+	case a == math.MinInt16:
+		return math.MaxInt16
+	case a < 0:
+		return -a
+	}
+	return a
+}
+
+// norm returns the left shift that normalizes the 32-bit value a, so that
+// its bit 30 differs from its sign
+func norm(a int64) int {
+	if a < 0 {
+		if a <= -1<<30 {
+			return 0
+		}
+		a = ^a
+	}
+	return bits.LeadingZeros32(uint32(a)) - 1
+}
+
+// div returns num/denum in Q15, for 0 <= num <= denum
+func div(num, denum int16) int16 {
+	if num == 0 {
+		return 0
+	}
+	n, d := int32(num), int32(denum)
+	var q int16
+	for range 15 {
+		q <<= 1
+		n <<= 1
+		if n >= d {
+			n -= d
+			q++
+		}
+	}
+	return q
+}
+
+// asl and asr shift a left and right by n, a shift by a negative n being
+// one the other way
+func asl(a int16, n int) int16 {
+	switch {
+	case n >= 16:
+		return 0
+	case n <= -16:
+		return a >> 15
+	case n < 0:
+		return a >> -n
+	}
+	return a << n
+}
+
+func asr(a int16, n int) int16 {
+	switch {
+	case n >= 16:
+		return a >> 15
+	case n <= -16:
+		return 0
+	case n < 0:
+		return a << -n
+	}
+	return a >> n
+}
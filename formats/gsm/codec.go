@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+import "fmt"
+
+const (
+	// FrameSamples is the number of samples in a frame: 20 ms at 8 kHz
+	FrameSamples = 160
+	// FrameSize is the size of a frame in a .gsm file or an RTP payload:
+	// the 260 bits of the frame after a 4-bit signature
+	FrameSize = 33
+	// Rate is the sample rate of GSM 06.10
+	Rate = 8000
+)
+
+// FrameDecoder decodes frames one at a time, for callers that frame the
+// bytes themselves, such as an RTP endpoint with payload type 3. Each
+// frame is decoded from the state the previous ones left, so use one
+// FrameDecoder per stream. The zero FrameDecoder is ready to use.
+type FrameDecoder struct {
+	synthesis
+	dp0 [280]int16 // reconstructed residual, the last 120 samples then the subframe
+	nrp int16      // last valid lag
+	msr int16      // de-emphasis filter state
+}
+
+// Decode decodes a frame of FrameSize bytes into FrameSamples 16-bit
+// samples of dst. Frames without the signature of GSM 06.10 fail with
+// ErrInvalidFrame.
+func (d *FrameDecoder) Decode(dst []int16, frame []byte) error {
+	if len(frame) < FrameSize || len(dst) < FrameSamples {
+		return fmt.Errorf("%w: %d bytes into %d samples", ErrInvalidFrame, len(frame), len(dst))
+	}
+	var p params
+	if !p.unpack(frame) {
+		return fmt.Errorf("%w: signature %#x", ErrInvalidFrame, frame[0]>>4)
+	}
+	d.decode(&p, (*[FrameSamples]int16)(dst))
+	return nil
+}
+
+func (d *FrameDecoder) decode(p *params, s *[FrameSamples]int16) {
+	if d.nrp == 0 {
+		d.nrp = minLag
+	}
+
+	var (
+		erp [40]int16
+		wt  [FrameSamples]int16
+	)
+	for j := range 4 {
+		rpeDecode(p.xmaxc[j], p.mc[j], &p.xmc[j], erp[:])
+		ltpSynthesis(p.nc[j], p.bc[j], &d.nrp, erp[:], d.dp0[:])
+		copy(wt[j*40:], d.dp0[maxLag:maxLag+40])
+	}
+	d.filter(&p.larc, &wt, s)
+
+	// De-emphasis, truncation to 13 bits and upscaling
+	msr := d.msr
+	for k, v := range s {
+		msr = add(v, multR(msr, 28180))
+		s[k] = add(msr, msr) &^ 7
+	}
+	d.msr = msr
+}
+
+// FrameEncoder encodes frames one at a time, the counterpart of
+// FrameDecoder. The zero FrameEncoder is ready to use.
+type FrameEncoder struct {
+	analysis
+	dp0 [280]int16 // reconstructed residual, the last 120 samples then the frame
+	e   [50]int16  // residual of a subframe, with 5 zero samples each side
+	z1  int16      // offset compensation filter state
+	lz2 int64
+	mp  int16 // pre-emphasis filter state
+}
+
+// Encode encodes FrameSamples 16-bit samples of src into a frame of
+// FrameSize bytes of dst.
+func (e *FrameEncoder) Encode(dst []byte, src []int16) error {
+	if len(dst) < FrameSize || len(src) < FrameSamples {
+		return fmt.Errorf("%w: %d samples into %d bytes", ErrInvalidFrame, len(src), len(dst))
+	}
+	var p params
+	e.encode(&p, (*[FrameSamples]int16)(src))
+	p.pack(dst)
+	return nil
+}
+
+func (e *FrameEncoder) encode(p *params, s *[FrameSamples]int16) {
+	var so [FrameSamples]int16
+	e.preprocess(s, &so)
+	lpcAnalysis(&so, &p.larc)
+	e.filter(&p.larc, &so)
+
+	for k := range 4 {
+		// dp[:120] is the residual before the subframe; dpp, the
+		// prediction, and then the reconstructed residual follow it
+		dp := e.dp0[k*40:]
+		dpp := dp[maxLag : maxLag+40]
+		d := so[k*40 : k*40+40]
+
+		p.nc[k], p.bc[k] = ltpParams(d, dp)
+		ltpAnalysis(p.bc[k], p.nc[k], dp, d, dpp, e.e[5:45])
+		rpeEncode(e.e[:], &p.xmaxc[k], &p.mc[k], &p.xmc[k])
+		for i := range dpp {
+			dpp[i] = add(e.e[5+i], dpp[i])
+		}
+	}
+	copy(e.dp0[:maxLag], e.dp0[FrameSamples:])
+}
+
+// preprocess scales the input down, removes its offset and pre-emphasizes
+// it
+func (e *FrameEncoder) preprocess(s, so *[FrameSamples]int16) {
+	z1, lz2, mp := e.z1, e.lz2, e.mp
+	for k, v := range s {
+		// Downscaling and offset compensation
+		v = v >> 3 << 2
+		s1 := v - z1
+		z1 = v
+
+		ls2 := int64(s1) << 15
+		msp := int16(lz2 >> 15)
+		lsp := int16(lz2 - int64(msp)<<15)
+		ls2 += int64(multR(lsp, 32735))
+		lz2 = addL(int64(msp)*32735, ls2)
+		ltemp := addL(lz2, 16384)
+
+		// Pre-emphasis
+		msp = multR(mp, -28180)
+		mp = int16(ltemp >> 15)
+		so[k] = add(mp, msp)
+	}
+	e.z1, e.lz2, e.mp = z1, lz2, mp
+}
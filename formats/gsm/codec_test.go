@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestParams_Pack(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 100 {
+		var p params
+		p.fields(func(v *int16, size uint) { *v = int16(rng.IntN(1 << size)) })
+
+		var frame [FrameSize]byte
+		p.pack(frame[:])
+		var got params
+		if !got.unpack(frame[:]) || got != p {
+			t.Fatalf("unpack(pack(%v)) = %v", p, got)
+		}
+
+		// Both frames of a WAV49 block, the second starting mid-byte
+		var block [BlockSize]byte
+		p.pack49(block[:], 0)
+		got.pack49(block[:], frameBits)
+		var first, second params
+		first.unpack49(block[:], 0)
+		second.unpack49(block[:], frameBits)
+		if first != p || second != p {
+			t.Fatalf("unpack49(pack49(%v)) = %v, %v", p, first, second)
+		}
+	}
+}
+
+func TestFrameCodec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		sample func(i int) int16
+		minSNR float64 // dB, 0 for silence
+	}{
+		// Silence codes to pulses of the smallest step, which decode to
+		// a hiss far below -40 dBFS
+		{name: "silence", sample: func(int) int16 { return 0 }},
+		{name: "200 Hz", sample: tone(200, 0.5), minSNR: 20},
+		{name: "440 Hz", sample: tone(440, 0.5), minSNR: 20},
+		{name: "1 kHz, loud", sample: tone(1000, 0.99), minSNR: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				enc   FrameEncoder
+				dec   FrameDecoder
+				frame [FrameSize]byte
+				in    = make([]int16, FrameSamples)
+				out   = make([]int16, FrameSamples)
+			)
+			var signal, noise float64
+			var peak int16
+			for f := range 50 {
+				for i := range in {
+					in[i] = tt.sample(f*FrameSamples + i)
+				}
+				if err := enc.Encode(frame[:], in); err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				if err := dec.Decode(out, frame[:]); err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if f < 5 {
+					continue // the predictors settle
+				}
+				for i := range in {
+					peak = max(peak, abs16(out[i]))
+					d := float64(in[i]) - float64(out[i])
+					signal += float64(in[i]) * float64(in[i])
+					noise += d * d
+				}
+			}
+
+			if tt.minSNR == 0 {
+				if peak > 327 {
+					t.Errorf("silence decoded with peaks of %d", peak)
+				}
+				return
+			}
+			if snr := 10 * math.Log10(signal/noise); snr < tt.minSNR {
+				t.Errorf("SNR = %.1f dB, want at least %v dB", snr, tt.minSNR)
+			}
+		})
+	}
+}
+
+func TestFrameCodec_Errors(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dec FrameDecoder
+		enc FrameEncoder
+		pcm = make([]int16, FrameSamples)
+	)
+	frame := make([]byte, FrameSize)
+	if err := dec.Decode(pcm, frame); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Decode(no signature) error = %v, want ErrInvalidFrame", err)
+	}
+	if err := dec.Decode(pcm, frame[:FrameSize-1]); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Decode(short frame) error = %v, want ErrInvalidFrame", err)
+	}
+	if err := enc.Encode(frame, pcm[:100]); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Encode(100 samples) error = %v, want ErrInvalidFrame", err)
+	}
+}
+
+// tone returns the samples of a sine of freq Hz at amplitude amp
+func tone(freq, amp float64) func(i int) int16 {
+	return func(i int) int16 {
+		return int16(amp * 32767 * math.Sin(2*math.Pi*freq*float64(i)/Rate))
+	}
+}
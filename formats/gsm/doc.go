@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package gsm reads and writes GSM 06.10 full-rate audio, the 13 kbit/s
+// speech codec of the first mobile networks that Asterisk still uses for
+// voicemail and prompts. Each 20 ms frame of 160 samples at 8 kHz is
+// coded in 260 bits: a short-term LPC filter, a long-term pitch predictor
+// and a regular pulse excitation. The codec is specified in fixed point,
+// which the package follows, so that frames decode the same everywhere.
+//
+// # Files
+//
+// Two containers are read and written:
+//   - .gsm files are frames of 33 bytes, each the 260 bits after a 4-bit
+//     signature, with no header; Decoder reads them and Encode writes them
+//   - WAV files with format tag 0x0031 pack 2 frames into blocks of 65
+//     bytes, in another bit order; Asterisk calls them wav49 and Windows
+//     Microsoft GSM. WAV49Decoder reads them and EncodeWAV49 writes them
+//
+// For example:
+//
+//	src, err := gsm.Decoder{}.Decode(file)
+//	n, err := gsm.EncodeWAV49(out, src)
+//
+// Sources decode to 16-bit samples, mono at 8 kHz. The encoders take only
+// that format, and fail with audio.ErrFormatMismatch on others: resample
+// and downmix first with audio.NewResampler and audio.NewMonoMixer.
+//
+// # Frames
+//
+// FrameDecoder and FrameEncoder code one 33-byte frame at a time, for
+// callers that frame the bytes themselves, such as RTP payload type 3:
+//
+//	var dec gsm.FrameDecoder
+//	pcm := make([]int16, gsm.FrameSamples)
+//	err := dec.Decode(pcm, packet.Payload)
+//
+// Each frame is coded from the state the previous ones left, so a stream
+// needs its own FrameDecoder or FrameEncoder.
+package gsm
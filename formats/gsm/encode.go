@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/utils"
+)
+
+// Encode writes src to w as a .gsm file and returns the number of samples
+// written. src must be mono at 8 kHz; others fail with
+// audio.ErrFormatMismatch. The last frame is padded with silence. It does
+// not close src or w.
+func Encode(w io.Writer, src audio.Source) (int64, error) {
+	var (
+		enc   FrameEncoder
+		p     params
+		frame [FrameSize]byte
+	)
+	return encodeFrames(src, func(pcm *[FrameSamples]int16) error {
+		enc.encode(&p, pcm)
+		p.pack(frame[:])
+		if _, err := w.Write(frame[:]); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		return nil
+	})
+}
+
+// EncodeWAV49 writes src to w as a WAV file of GSM 06.10, which Asterisk
+// calls wav49, and returns the number of samples written. Like Encode it
+// takes mono 8 kHz sources and does not close src or w.
+//
+// The sizes in the header are filled in when w is an io.WriteSeeker, and
+// marked as unknown otherwise, which WAV49Decoder reads as "until the end
+// of the file".
+func EncodeWAV49(w io.Writer, src audio.Source) (int64, error) {
+	if err := check(src); err != nil {
+		return 0, err
+	}
+
+	start := int64(-1)
+	if s, ok := w.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			start = pos
+		}
+	}
+	if _, err := w.Write(wav49Header(-1)); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	var (
+		enc    FrameEncoder
+		p      params
+		block  [BlockSize]byte
+		frames int
+	)
+	write := func() error {
+		if _, err := w.Write(block[:]); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		clear(block[:])
+		return nil
+	}
+	samples, err := encodeFrames(src, func(pcm *[FrameSamples]int16) error {
+		enc.encode(&p, pcm)
+		p.pack49(block[:], uint(frames%2)*frameBits)
+		if frames++; frames%2 == 0 {
+			return write()
+		}
+		return nil
+	})
+	if err != nil {
+		return samples, err
+	}
+
+	// A last odd frame is paired with one of silence
+	if frames%2 != 0 {
+		var silence [FrameSamples]int16
+		enc.encode(&p, &silence)
+		p.pack49(block[:], frameBits)
+		if err := write(); err != nil {
+			return samples, err
+		}
+		frames++
+	}
+	if frames/2%2 != 0 {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return samples, fmt.Errorf("%w", err)
+		}
+	}
+
+	ws, ok := w.(io.WriteSeeker)
+	if !ok || start < 0 || int64(frames/2)*BlockSize >= unknownSize-wav49HeaderSize {
+		return samples, nil
+	}
+	end := start + wav49HeaderSize + int64(frames/2)*BlockSize + int64(frames/2%2)
+	if _, err := ws.Seek(start, io.SeekStart); err != nil {
+		return samples, fmt.Errorf("patching header: %w", err)
+	}
+	if _, err := ws.Write(wav49Header(samples)); err != nil {
+		return samples, fmt.Errorf("patching header: %w", err)
+	}
+	if _, err := ws.Seek(end, io.SeekStart); err != nil {
+		return samples, fmt.Errorf("patching header: %w", err)
+	}
+	return samples, nil
+}
+
+// check reports whether src can be encoded
+func check(src audio.Source) error {
+	if src.SampleRate() != Rate || src.Channels() != 1 {
+		return fmt.Errorf("%w: GSM 06.10 is 8000 Hz mono, source is %d Hz %d ch",
+			audio.ErrFormatMismatch, src.SampleRate(), src.Channels())
+	}
+	return nil
+}
+
+// encodeFrames reads src in frames of 16-bit samples, the last padded with
+// silence, and returns the number of samples read
+func encodeFrames(src audio.Source, frame func(pcm *[FrameSamples]int16) error) (int64, error) {
+	if err := check(src); err != nil {
+		return 0, err
+	}
+
+	var (
+		buf     = make([]float32, FrameSamples)
+		pcm     [FrameSamples]int16
+		have    int
+		samples int64
+	)
+	for {
+		n, err := src.ReadSamples(buf[have:])
+		for i, v := range buf[have : have+n] {
+			pcm[have+i] = utils.Float32ToInt16(v)
+		}
+		have += n
+		samples += int64(n)
+
+		end := errors.Is(err, io.EOF) || (err == nil && n == 0)
+		if have == FrameSamples || (end && have > 0) {
+			clear(pcm[have:])
+			if ferr := frame(&pcm); ferr != nil {
+				return samples, ferr
+			}
+			have = 0
+		}
+		if end {
+			return samples, nil
+		}
+		if err != nil {
+			return samples, fmt.Errorf("reading source: %w", err)
+		}
+	}
+}
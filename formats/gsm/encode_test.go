@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		samples    int
+		wantFrames int
+	}{
+		{name: "empty", samples: 0},
+		{name: "whole frames", samples: 1600, wantFrames: 10},
+		{name: "padded", samples: 1601, wantFrames: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			n, err := Encode(&buf, audiotest.NewSineSource(Rate, 1, tt.samples, 440))
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if n != int64(tt.samples) || buf.Len() != tt.wantFrames*FrameSize {
+				t.Fatalf("Encode() = %d samples in %d bytes, want %d in %d frames", n, buf.Len(), tt.samples, tt.wantFrames)
+			}
+
+			src, err := Decoder{}.Decode(&buf)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if f := audio.FormatOf(src); f.Rate != Rate || f.Channels != 1 || f.SampleKind != audio.SampleInt16 {
+				t.Errorf("Format() = %v", f)
+			}
+			if got := readAll(t, src); len(got) != tt.wantFrames*FrameSamples {
+				t.Errorf("decoded %d samples, want %d", len(got), tt.wantFrames*FrameSamples)
+			}
+		})
+	}
+}
+
+func TestEncodeWAV49(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		samples int
+		seek    bool
+		want    int // samples decoded
+	}{
+		{name: "file", samples: 1000, seek: true, want: 1000},
+		{name: "file of whole blocks", samples: 1280, seek: true, want: 1280},
+		{name: "file of an odd frame count", samples: 100, seek: true, want: 100},
+		{name: "stream", samples: 1000, want: 1280},
+		{name: "empty file", seek: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var w io.Writer
+			var buf bytes.Buffer
+			var file *os.File
+			if tt.seek {
+				var err error
+				if file, err = os.Create(filepath.Join(t.TempDir(), "out.wav")); err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+				w = file
+			} else {
+				w = &buf
+			}
+
+			n, err := EncodeWAV49(w, audiotest.NewSineSource(Rate, 1, tt.samples, 440))
+			if err != nil || n != int64(tt.samples) {
+				t.Fatalf("EncodeWAV49() = %d, %v, want %d samples", n, err, tt.samples)
+			}
+			data := buf.Bytes()
+			if tt.seek {
+				if data, err = os.ReadFile(file.Name()); err != nil {
+					t.Fatal(err)
+				}
+				if pos, _ := file.Seek(0, io.SeekCurrent); pos != int64(len(data)) {
+					t.Errorf("left at offset %d of %d bytes", pos, len(data))
+				}
+			}
+
+			blocks := (tt.samples + BlockSamples - 1) / BlockSamples
+			if size := wav49HeaderSize + blocks*BlockSize + blocks%2; len(data) != size {
+				t.Fatalf("wrote %d bytes, want %d", len(data), size)
+			}
+			if got := audio.DetectFormat(data); len(got) == 0 || got[0] != "wav49" {
+				t.Errorf("DetectFormat() = %v, want wav49 first", got)
+			}
+			if tt.seek && int(binary.LittleEndian.Uint32(data[4:8])) != len(data)-8 {
+				t.Errorf("RIFF size = %d for %d bytes", binary.LittleEndian.Uint32(data[4:8]), len(data))
+			}
+
+			src, err := WAV49Decoder{}.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got := readAll(t, src); len(got) != tt.want {
+				t.Errorf("decoded %d samples, want %d", len(got), tt.want)
+			}
+			wantFrames := int64(tt.samples)
+			if !tt.seek {
+				wantFrames = -1
+			}
+			if total := src.(audio.Lengther).TotalFrames(); total != wantFrames {
+				t.Errorf("TotalFrames() = %d, want %d", total, wantFrames)
+			}
+		})
+	}
+}
+
+func TestEncode_Errors(t *testing.T) {
+	t.Parallel()
+
+	for _, src := range []audio.Source{
+		audiotest.NewSilentSource(16000, 1, 10),
+		audiotest.NewSilentSource(Rate, 2, 10),
+	} {
+		if _, err := Encode(io.Discard, src); !errors.Is(err, audio.ErrFormatMismatch) {
+			t.Errorf("Encode(%d Hz %d ch) error = %v, want ErrFormatMismatch", src.SampleRate(), src.Channels(), err)
+		}
+		if _, err := EncodeWAV49(io.Discard, src); !errors.Is(err, audio.ErrFormatMismatch) {
+			t.Errorf("EncodeWAV49(%d Hz %d ch) error = %v, want ErrFormatMismatch", src.SampleRate(), src.Channels(), err)
+		}
+	}
+
+	faulty := audiotest.NewFaultySource(audiotest.NewSilentSource(Rate, 1, 1000), audiotest.WithErrorOnCall(2, audiotest.ErrInjected))
+	if _, err := Encode(io.Discard, faulty); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Encode(faulty) error = %v, want ErrInjected", err)
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if _, err := Encode(&buf, audiotest.NewSilentSource(Rate, 1, 320)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[FrameSize] = 0 // signature of the second frame
+
+	src := NewSource(bytes.NewReader(data))
+	out := make([]float32, 2*FrameSamples)
+	if n, err := src.ReadSamples(out); n != FrameSamples || err != nil {
+		t.Errorf("ReadSamples() = %d, %v, want the first frame", n, err)
+	}
+	if _, err := src.ReadSamples(out); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("ReadSamples() error = %v, want ErrInvalidFrame", err)
+	}
+
+	pcm := []byte("RIFF\x24\x00\x00\x00WAVEfmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\x80\x3e\x00\x00\x02\x00\x10\x00data\x00\x00\x00\x00")
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "not RIFF", data: []byte("not a wav file"), want: ErrNotWavFile},
+		{name: "PCM", data: pcm, want: ErrUnsupportedFormat},
+		{name: "no data", data: pcm[:12], want: ErrUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		if _, err := (WAV49Decoder{}).Decode(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Decode() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// readAll reads src to the end
+func readAll(t *testing.T, src audio.Source) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, 100)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
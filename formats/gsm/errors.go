@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+import "errors"
+
+var (
+	ErrInvalidFrame      = errors.New("invalid GSM 06.10 frame")
+	ErrNotWavFile        = errors.New("not a WAV file")
+	ErrUnsupportedFormat = errors.New("not a GSM 06.10 WAV file")
+)
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+// The long-term predictor models the pitch of a subframe as a copy of the
+// reconstructed residual 40 to 120 samples back, scaled by one of 4 gains.
+// dp holds the last 120 samples of that residual, then the subframe.
+const (
+	minLag = 40
+	maxLag = 120
+)
+
+// ltpParams searches the lag and gain of the long-term predictor of the
+// residual d of a subframe, dp[:120] being the reconstructed residual
+// before it
+func ltpParams(d, dp []int16) (nc, bc int16) {
+	// Scaling of d so the correlations fit
+	var dmax int16
+	for _, v := range d[:40] {
+		dmax = max(dmax, abs16(v))
+	}
+	temp := 0
+	if dmax != 0 {
+		temp = norm(int64(dmax) << 16)
+	}
+	scal := 0
+	if temp <= 6 {
+		scal = 6 - temp
+	}
+	var wt [40]int16
+	for k := range wt {
+		wt[k] = d[k] >> scal
+	}
+
+	// Lag of the highest cross-correlation
+	var lmax int64
+	nc = minLag
+	for lambda := minLag; lambda <= maxLag; lambda++ {
+		var l int64
+		for k, w := range wt {
+			l += int64(w) * int64(dp[maxLag+k-lambda])
+		}
+		if l > lmax {
+			nc, lmax = int16(lambda), l
+		}
+	}
+	lmax <<= 1
+	lmax >>= 6 - scal
+
+	// Power of the reconstructed residual at that lag
+	var lpower int64
+	for k := range 40 {
+		t := int64(dp[maxLag+k-int(nc)] >> 3)
+		lpower += t * t
+	}
+	lpower <<= 1
+
+	switch {
+	case lmax <= 0:
+		return nc, 0
+	case lmax >= lpower:
+		return nc, 3
+	}
+	temp = norm(lpower)
+	r := int16(lmax << temp >> 16)
+	s := int16(lpower << temp >> 16)
+	for bc = 0; bc <= 2; bc++ {
+		if r <= mult(s, dlb[bc]) {
+			break
+		}
+	}
+	return nc, bc
+}
+
+// ltpAnalysis writes the prediction of a subframe to dpp and what is left
+// of d to e
+func ltpAnalysis(bc, nc int16, dp, d, dpp, e []int16) {
+	bp := qlb[bc]
+	for k := range 40 {
+		dpp[k] = multR(bp, dp[maxLag+k-int(nc)])
+		e[k] = sub(d[k], dpp[k])
+	}
+}
+
+// ltpSynthesis adds the prediction to the excitation erp of a subframe,
+// writing the reconstructed residual to drp[120:160], and shifts drp by
+// the subframe. An out of range lag repeats the last one, nrp.
+func ltpSynthesis(ncr, bcr int16, nrp *int16, erp, drp []int16) {
+	nr := ncr
+	if ncr < minLag || ncr > maxLag {
+		nr = *nrp
+	}
+	*nrp = nr
+
+	brp := qlb[bcr]
+	for k := range 40 {
+		drpp := multR(brp, drp[maxLag+k-int(nr)])
+		drp[maxLag+k] = add(erp[k], drpp)
+	}
+	copy(drp[:maxLag], drp[40:40+maxLag])
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+// lpcAnalysis computes the coded log area ratios of the short-term filter
+// of a frame. s is rounded by the scaling of the autocorrelation, as the
+// standard does, and the filter reads it so.
+func lpcAnalysis(s *[FrameSamples]int16, larc *[8]int16) {
+	var acf [9]int64
+	autocorrelation(s, &acf)
+	reflection(&acf, larc)
+
+	// Transformation to log area ratios
+	for i, r := range larc {
+		temp := abs16(r)
+		switch {
+		case temp < 22118:
+			temp >>= 1
+		case temp < 31130:
+			temp -= 11059
+		default:
+			temp = (temp - 26112) << 2
+		}
+		if r < 0 {
+			temp = -temp
+		}
+		larc[i] = temp
+	}
+
+	// Quantization and coding
+	for i, lar := range larc {
+		temp := mult(larA[i], lar)
+		temp = add(temp, larB[i])
+		temp = add(temp, 256) >> 9
+		switch {
+		case temp > larMAC[i]:
+			larc[i] = larMAC[i] - larMIC[i]
+		case temp < larMIC[i]:
+			larc[i] = 0
+		default:
+			larc[i] = temp - larMIC[i]
+		}
+	}
+}
+
+// autocorrelation computes the first 9 autocorrelations of s, scaled
+func autocorrelation(s *[FrameSamples]int16, acf *[9]int64) {
+	var smax int16
+	for _, v := range s {
+		smax = max(smax, abs16(v))
+	}
+	scalauto := 0
+	if smax != 0 {
+		scalauto = 4 - norm(int64(smax)<<16)
+	}
+	if scalauto > 0 {
+		for k, v := range s {
+			s[k] = multR(v, 16384>>(scalauto-1))
+		}
+	}
+
+	for k := range acf {
+		var l int64
+		for i := k; i < FrameSamples; i++ {
+			l += int64(s[i]) * int64(s[i-k])
+		}
+		acf[k] = l << 1
+	}
+
+	if scalauto > 0 {
+		for k := range s {
+			s[k] <<= scalauto
+		}
+	}
+}
+
+// reflection computes the reflection coefficients from the
+// autocorrelations with the Schur recursion
+func reflection(lacf *[9]int64, r *[8]int16) {
+	*r = [8]int16{}
+	if lacf[0] == 0 {
+		return
+	}
+
+	temp := norm(lacf[0])
+	var acf, p, k [9]int16
+	for i, v := range lacf {
+		acf[i] = int16(v << temp >> 16)
+	}
+	copy(k[1:8], acf[1:8])
+	p = acf
+
+	for n := 1; n <= 8; n++ {
+		t := abs16(p[1])
+		if p[0] < t {
+			return
+		}
+		rn := div(t, p[0])
+		if p[1] > 0 {
+			rn = -rn
+		}
+		r[n-1] = rn
+		if n == 8 {
+			return
+		}
+
+		p[0] = add(p[0], multR(p[1], rn))
+		for m := 1; m <= 8-n; m++ {
+			p[m] = add(p[m+1], multR(k[m], rn))
+			k[m] = add(k[m], multR(p[m+1], rn))
+		}
+	}
+}
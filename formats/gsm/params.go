@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+// params are the parameters of a frame, in the order they are sent: the
+// coded log area ratios of the short-term filter, then for each of the 4
+// subframes of 40 samples, the lag and gain of the long-term predictor
+// and the grid, block maximum and pulses of the RPE sequence.
+type params struct {
+	larc  [8]int16
+	nc    [4]int16
+	bc    [4]int16
+	mc    [4]int16
+	xmaxc [4]int16
+	xmc   [4][13]int16
+}
+
+// larBits are the sizes of the coded log area ratios
+var larBits = [8]uint{6, 6, 5, 5, 4, 4, 3, 3}
+
+// fields calls f with each parameter in order and its size in bits
+func (p *params) fields(f func(v *int16, size uint)) {
+	for i := range p.larc {
+		f(&p.larc[i], larBits[i])
+	}
+	for j := range 4 {
+		f(&p.nc[j], 7)
+		f(&p.bc[j], 2)
+		f(&p.mc[j], 2)
+		f(&p.xmaxc[j], 6)
+		for i := range p.xmc[j] {
+			f(&p.xmc[j][i], 3)
+		}
+	}
+}
+
+// frameBits is the size of the parameters of a frame
+const frameBits = 260
+
+// magic is the signature in the high nibble of a frame of a .gsm file
+const magic = 0xD
+
+// unpack reads a .gsm frame: the signature, then the parameters most
+// significant bit first. It reports whether the signature is there.
+func (p *params) unpack(frame []byte) bool {
+	pos := uint(4)
+	p.fields(func(v *int16, size uint) {
+		var x int16
+		for range size {
+			x = x<<1 | int16(frame[pos/8]>>(7-pos%8)&1)
+			pos++
+		}
+		*v = x
+	})
+	return frame[0]>>4 == magic
+}
+
+// pack writes p as a .gsm frame
+func (p *params) pack(frame []byte) {
+	clear(frame[:FrameSize])
+	frame[0] = magic << 4
+	pos := uint(4)
+	p.fields(func(v *int16, size uint) {
+		for i := int(size) - 1; i >= 0; i-- {
+			frame[pos/8] |= byte(*v>>i&1) << (7 - pos%8)
+			pos++
+		}
+	})
+}
+
+// unpack49 reads the parameters at bit off of a WAV49 block, where they
+// are packed least significant bit first, with no signature
+func (p *params) unpack49(block []byte, off uint) {
+	pos := off
+	p.fields(func(v *int16, size uint) {
+		var x int16
+		for i := range size {
+			x |= int16(block[pos/8]>>(pos%8)&1) << i
+			pos++
+		}
+		*v = x
+	})
+}
+
+// pack49 writes p at bit off of a WAV49 block, whose bits from off on
+// must be clear
+func (p *params) pack49(block []byte, off uint) {
+	pos := off
+	p.fields(func(v *int16, size uint) {
+		for i := range size {
+			block[pos/8] |= byte(*v>>i&1) << (pos % 8)
+			pos++
+		}
+	})
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+// The regular pulse excitation (RPE) of a subframe is 13 pulses on one of
+// 4 grids of every third sample, quantized as 3-bit values scaled by a
+// block maximum.
+
+// xmaxcToExpMant splits a coded block maximum into its exponent and
+// mantissa
+func xmaxcToExpMant(xmaxc int16) (exp, mant int16) {
+	if xmaxc > 15 {
+		exp = xmaxc>>3 - 1
+	}
+	mant = xmaxc - exp<<3
+	if mant == 0 {
+		return -4, 7
+	}
+	for mant <= 7 {
+		mant = mant<<1 | 1
+		exp--
+	}
+	return exp, mant - 8
+}
+
+// apcmInverse dequantizes the pulses of a subframe
+func apcmInverse(xmc *[13]int16, exp, mant int16, xmp *[13]int16) {
+	temp1 := fac[mant]
+	temp2 := sub(6, exp)
+	temp3 := asl(1, int(sub(temp2, 1)))
+	for i, c := range xmc {
+		temp := (c<<1 - 7) << 12 // restore the sign
+		temp = multR(temp1, temp)
+		temp = add(temp, temp3)
+		xmp[i] = asr(temp, int(temp2))
+	}
+}
+
+// gridPosition places the pulses on grid mc of a subframe
+func gridPosition(mc int16, xmp *[13]int16, ep []int16) {
+	clear(ep[:40])
+	for i, v := range xmp {
+		ep[int(mc)+3*i] = v
+	}
+}
+
+// rpeDecode returns the excitation of a subframe
+func rpeDecode(xmaxc, mc int16, xmc *[13]int16, erp []int16) {
+	var xmp [13]int16
+	exp, mant := xmaxcToExpMant(xmaxc)
+	apcmInverse(xmc, exp, mant, &xmp)
+	gridPosition(mc, &xmp, erp)
+}
+
+// rpeEncode codes the residual e[5:45] of a subframe, e[0:5] and e[45:50]
+// being zero, and replaces it by its decoded version
+func rpeEncode(e []int16, xmaxc, mc *int16, xmc *[13]int16) {
+	var x [40]int16
+	weight(e, &x)
+
+	// Grid selection: the one of most energy
+	var em int64
+	*mc = 0
+	for m := range 4 {
+		var l int64
+		for i := range 13 {
+			t := int64(x[m+3*i] >> 2)
+			l += t * t
+		}
+		if l <<= 1; l > em {
+			*mc, em = int16(m), l
+		}
+	}
+	var xm [13]int16
+	for i := range xm {
+		xm[i] = x[int(*mc)+3*i]
+	}
+
+	exp, mant := apcmQuantize(&xm, xmc, xmaxc)
+	var xmp [13]int16
+	apcmInverse(xmc, exp, mant, &xmp)
+	gridPosition(*mc, &xmp, e[5:])
+}
+
+// weight filters the residual of a subframe with the weighting filter
+func weight(e []int16, x *[40]int16) {
+	for k := range x {
+		l := int64(8192 >> 1)
+		for i, c := range h {
+			l += int64(e[k+i]) * int64(c)
+		}
+		x[k] = int16(min(max(l>>13, -32768), 32767))
+	}
+}
+
+// apcmQuantize codes the block maximum and the pulses of the selected
+// grid, and returns the exponent and mantissa of the coded maximum
+func apcmQuantize(xm, xmc *[13]int16, xmaxc *int16) (exp, mant int16) {
+	var xmax int16
+	for _, v := range xm {
+		xmax = max(xmax, abs16(v))
+	}
+
+	// Quantizing and coding of the maximum
+	temp := xmax >> 9
+	stop := false
+	for range 6 {
+		stop = stop || temp <= 0
+		temp >>= 1
+		if !stop {
+			exp++
+		}
+	}
+	*xmaxc = add(xmax>>(exp+5), exp<<3)
+
+	// The pulses are scaled by the inverse of the decoded maximum, whose
+	// exponent and mantissa avoid a division
+	exp, mant = xmaxcToExpMant(*xmaxc)
+	temp1 := 6 - exp
+	temp2 := nrfac[mant]
+	for i, v := range xm {
+		t := v << temp1
+		t = mult(t, temp2)
+		xmc[i] = t>>12 + 4 // made positive
+	}
+	return exp, mant
+}
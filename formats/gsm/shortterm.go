@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+// lars holds the decoded log area ratios of the current and previous
+// frames, which the short-term filters interpolate between over the first
+// 40 samples of a frame
+type lars struct {
+	larpp [2][8]int16
+	j     int
+}
+
+// segment is a run of samples of a frame that the short-term filter
+// processes with the same coefficients
+type segment struct {
+	start, end int
+	coeffs     func(prev, cur, larp *[8]int16)
+}
+
+var segments = [4]segment{
+	{0, 13, func(prev, cur, larp *[8]int16) {
+		for i := range larp {
+			larp[i] = add(add(prev[i]>>2, cur[i]>>2), prev[i]>>1)
+		}
+	}},
+	{13, 27, func(prev, cur, larp *[8]int16) {
+		for i := range larp {
+			larp[i] = add(prev[i]>>1, cur[i]>>1)
+		}
+	}},
+	{27, 40, func(prev, cur, larp *[8]int16) {
+		for i := range larp {
+			larp[i] = add(add(prev[i]>>2, cur[i]>>2), cur[i]>>1)
+		}
+	}},
+	{40, FrameSamples, func(_, cur, larp *[8]int16) {
+		*larp = *cur
+	}},
+}
+
+// next decodes the coded log area ratios of a frame and calls filter with
+// the reflection coefficients of each segment
+func (l *lars) next(larc *[8]int16, filter func(rp *[8]int16, start, end int)) {
+	cur := &l.larpp[l.j]
+	l.j ^= 1
+	prev := &l.larpp[l.j]
+
+	for i, c := range larc {
+		temp := add(c, larMIC[i]) << 10
+		temp = sub(temp, larB[i]<<1)
+		temp = multR(larINVA[i], temp)
+		cur[i] = add(temp, temp)
+	}
+
+	var larp [8]int16
+	for _, s := range segments {
+		s.coeffs(prev, cur, &larp)
+		larpToRp(&larp)
+		filter(&larp, s.start, s.end)
+	}
+}
+
+// larpToRp turns interpolated log area ratios into reflection
+// coefficients, in place
+func larpToRp(larp *[8]int16) {
+	for i, v := range larp {
+		temp := abs16(v)
+		switch {
+		case temp < 11059:
+			temp <<= 1
+		case temp < 20070:
+			temp += 11059
+		default:
+			temp = add(temp>>2, 26112)
+		}
+		if v < 0 {
+			temp = -temp
+		}
+		larp[i] = temp
+	}
+}
+
+// analysis is the short-term analysis filter of the encoder
+type analysis struct {
+	lars
+	u [8]int16
+}
+
+func (a *analysis) filter(larc *[8]int16, s *[FrameSamples]int16) {
+	a.next(larc, func(rp *[8]int16, start, end int) {
+		for k := start; k < end; k++ {
+			di, sav := s[k], s[k]
+			for i := range 8 {
+				ui := a.u[i]
+				a.u[i] = sav
+				sav = add(ui, multR(rp[i], di))
+				di = add(di, multR(rp[i], ui))
+			}
+			s[k] = di
+		}
+	})
+}
+
+// synthesis is the short-term synthesis filter of the decoder
+type synthesis struct {
+	lars
+	v [9]int16
+}
+
+func (y *synthesis) filter(larc *[8]int16, wt, s *[FrameSamples]int16) {
+	y.next(larc, func(rp *[8]int16, start, end int) {
+		for k := start; k < end; k++ {
+			sri := wt[k]
+			for i := 7; i >= 0; i-- {
+				sri = sub(sri, multRSat(rp[i], y.v[i]))
+				y.v[i+1] = add(y.v[i], multRSat(rp[i], sri))
+			}
+			y.v[0] = sri
+			s[k] = sri
+		}
+	})
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Decoder decodes .gsm files: GSM 06.10 frames of FrameSize bytes, back to
+// back, with no header, as Asterisk records them.
+type Decoder struct{}
+
+// Decode returns a source for the frames of r. The source does not close
+// r.
+func (Decoder) Decode(r io.Reader) (audio.Source, error) {
+	return newSource(r, nil, false, -1), nil
+}
+
+// Capabilities reports what the package reads and writes. GSM stores
+// frames of parameters rather than PCM samples, so no bit depth is listed.
+func (Decoder) Capabilities() audio.Capabilities {
+	return capabilities
+}
+
+var capabilities = audio.Capabilities{
+	Name:        "GSM 06.10",
+	Decode:      true,
+	Encode:      true,
+	MaxChannels: 1,
+	Rates:       []int{Rate},
+}
+
+// NewSource reads .gsm frames from r. If r is an io.Closer it is closed by
+// the source's Close.
+func NewSource(r io.Reader) audio.Source {
+	c, _ := r.(io.Closer)
+	return newSource(r, c, false, -1)
+}
+
+type source struct {
+	r      io.Reader
+	closer io.Closer
+	wav49  bool  // blocks of 2 frames packed as in WAV files
+	total  int64 // samples in the stream, -1 when not known
+	done   int64 // samples decoded so far
+	dec    FrameDecoder
+	block  []byte
+	pcm    [2 * FrameSamples]int16
+	head   int // start of the samples of pcm not returned yet
+	tail   int // end of the decoded samples of pcm
+	eof    bool
+}
+
+func newSource(r io.Reader, c io.Closer, wav49 bool, total int64) *source {
+	size := FrameSize
+	if wav49 {
+		size = BlockSize
+	}
+	return &source{r: r, closer: c, wav49: wav49, total: total, block: make([]byte, size)}
+}
+
+func (s *source) SampleRate() int { return Rate }
+func (s *source) Channels() int   { return 1 }
+func (s *source) BufSize() int    { return 2 * FrameSamples }
+
+// Format reports 16-bit samples, the precision GSM decodes to.
+func (s *source) Format() audio.Format {
+	return audio.Format{
+		Rate:       Rate,
+		Channels:   1,
+		Layout:     audio.DefaultLayout(1),
+		SampleKind: audio.SampleInt16,
+	}
+}
+
+// TotalFrames returns the sample count of the fact chunk of a WAV file,
+// and -1 for .gsm files, which do not record it.
+func (s *source) TotalFrames() int64 { return s.total }
+
+func (s *source) Duration() time.Duration {
+	if s.total < 0 {
+		return -1
+	}
+	return time.Duration(s.total) * time.Second / Rate
+}
+
+func (s *source) Close() error {
+	if s.closer != nil {
+		if err := s.closer.Close(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+	return nil
+}
+
+// ReadSamples decodes frames into dst, reading no more frames once it has
+// samples to return. A partial frame at the end of the stream is dropped. The samples of WAV files stop at the count of their
+// fact chunk, which the last block is padded to.
+func (s *source) ReadSamples(dst []float32) (int, error) {
+	n := 0
+	for n < len(dst) {
+		if s.head == s.tail {
+			if s.eof || n > 0 {
+				break
+			}
+			if err := s.next(); err != nil {
+				return n, err
+			}
+			continue
+		}
+		for ; n < len(dst) && s.head < s.tail; n++ {
+			dst[n] = float32(s.pcm[s.head]) / 32768
+			s.head++
+		}
+	}
+	if s.eof && s.head == s.tail {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// next decodes the next frame, or block of a WAV file
+func (s *source) next() error {
+	if _, err := io.ReadFull(s.r, s.block); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			s.eof = true
+			return nil
+		}
+		return fmt.Errorf("%w", err)
+	}
+
+	tail := FrameSamples
+	if s.wav49 {
+		var p params
+		p.unpack49(s.block, 0)
+		s.dec.decode(&p, (*[FrameSamples]int16)(s.pcm[:FrameSamples]))
+		p = params{}
+		p.unpack49(s.block, frameBits)
+		s.dec.decode(&p, (*[FrameSamples]int16)(s.pcm[FrameSamples:]))
+		tail = 2 * FrameSamples
+	} else if err := s.dec.Decode(s.pcm[:], s.block); err != nil {
+		return err
+	}
+	s.head, s.tail = 0, tail
+
+	s.done += int64(s.tail)
+	if s.total >= 0 && s.done >= s.total {
+		s.tail -= int(min(s.done-s.total, int64(s.tail)))
+		s.eof = true
+	}
+	return nil
+}
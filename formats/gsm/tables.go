@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+// Tables of GSM 06.10 section 5.2
+var (
+	// larA, larB, larMIC, larMAC and larINVA quantize the log area ratios
+	larA    = [8]int16{20480, 20480, 20480, 20480, 13964, 15360, 8534, 9036}
+	larB    = [8]int16{0, 0, 2048, -2560, 94, -1792, -341, -1144}
+	larMIC  = [8]int16{-32, -32, -16, -16, -8, -8, -4, -4}
+	larMAC  = [8]int16{31, 31, 15, 15, 7, 7, 3, 3}
+	larINVA = [8]int16{13107, 13107, 13107, 13107, 19223, 17476, 31454, 29708}
+
+	// dlb are the decision levels and qlb the quantized levels of the
+	// long-term predictor gain
+	dlb = [4]int16{6554, 16384, 26214, 32767}
+	qlb = [4]int16{3277, 11469, 21299, 32767}
+
+	// nrfac are the inverses and fac the values of the RPE block maximum
+	// mantissas
+	nrfac = [8]int16{29128, 26215, 23832, 21846, 20165, 18725, 17476, 16384}
+	fac   = [8]int16{18431, 20479, 22527, 24575, 26623, 28671, 30719, 32767}
+)
+
+// h is the impulse response of the RPE weighting filter
+var h = [11]int16{-134, -374, 0, 2054, 5741, 8192, 5741, 2054, 0, -374, -134}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package gsm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+const (
+	// BlockSize is the size of a block of a WAV49 file: 2 frames packed
+	// into 65 bytes, without their signatures
+	BlockSize = 65
+	// BlockSamples is the number of samples in a block of a WAV49 file
+	BlockSamples = 2 * FrameSamples
+
+	// formatGSM is the WAV format tag of GSM 06.10
+	formatGSM = 0x0031
+
+	// wav49HeaderSize is the size of the header EncodeWAV49 writes: RIFF,
+	// the 20-byte fmt chunk, the fact chunk and the data chunk header
+	wav49HeaderSize = 60
+
+	// maxFmtSize bounds the fmt chunk read into memory
+	maxFmtSize = 1024
+
+	// unknownSize and above mark a data chunk running to the end of a
+	// streamed file
+	unknownSize = 0xFFFFFFFE
+)
+
+// WAV49Decoder decodes WAV files of GSM 06.10, format tag 0x0031, which
+// Asterisk writes for its wav49 format and Windows calls Microsoft GSM.
+type WAV49Decoder struct{}
+
+// Decode reads the header of a GSM WAV file from r and returns a source for
+// its data chunk. Files with other format tags fail with
+// ErrUnsupportedFormat. The source does not close r.
+func (WAV49Decoder) Decode(r io.Reader) (audio.Source, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNotWavFile
+		}
+		return nil, fmt.Errorf("reading RIFF header: %w", err)
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, ErrNotWavFile
+	}
+
+	var (
+		fmtFound bool
+		total    int64 = -1
+	)
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("%w: no data chunk", ErrUnsupportedFormat)
+			}
+			return nil, fmt.Errorf("reading chunk header: %w", err)
+		}
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			if err := parseFmt(r, size); err != nil {
+				return nil, err
+			}
+			fmtFound = true
+		case "fact":
+			if size < 4 {
+				return nil, fmt.Errorf("%w: fact chunk of %d bytes", ErrUnsupportedFormat, size)
+			}
+			var fact [4]byte
+			if _, err := io.ReadFull(r, fact[:]); err != nil {
+				return nil, fmt.Errorf("reading fact chunk: %w", err)
+			}
+			if n := binary.LittleEndian.Uint32(fact[:]); n < unknownSize {
+				total = int64(n)
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(size-4)+int64(size&1)); err != nil {
+				return nil, fmt.Errorf("skipping chunk: %w", err)
+			}
+		case "data":
+			if !fmtFound {
+				return nil, fmt.Errorf("%w: data chunk before fmt", ErrUnsupportedFormat)
+			}
+			data := r
+			if size < unknownSize {
+				data = io.LimitReader(r, int64(size))
+			}
+			return newSource(data, nil, true, total), nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size&1)); err != nil {
+				return nil, fmt.Errorf("skipping chunk: %w", err)
+			}
+		}
+	}
+}
+
+// Capabilities reports what the package reads and writes.
+func (WAV49Decoder) Capabilities() audio.Capabilities {
+	c := capabilities
+	c.Name = "GSM 06.10 WAV"
+	return c
+}
+
+// parseFmt checks a fmt chunk body of the given size, including its pad
+// byte, is that of mono GSM 06.10 at 8 kHz in blocks of 2 frames.
+func parseFmt(r io.Reader, size uint32) error {
+	if size < 16 || size > maxFmtSize {
+		return fmt.Errorf("%w: fmt chunk of %d bytes", ErrUnsupportedFormat, size)
+	}
+
+	buf := make([]byte, size+size&1)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fmt.Errorf("reading fmt chunk: %w", err)
+	}
+
+	tag := binary.LittleEndian.Uint16(buf[0:2])
+	channels := binary.LittleEndian.Uint16(buf[2:4])
+	rate := binary.LittleEndian.Uint32(buf[4:8])
+	align := binary.LittleEndian.Uint16(buf[12:14])
+
+	switch {
+	case tag != formatGSM:
+		return fmt.Errorf("%w: format tag %d", ErrUnsupportedFormat, tag)
+	case channels != 1:
+		return fmt.Errorf("%w: %d channels", ErrUnsupportedFormat, channels)
+	case rate != Rate:
+		return fmt.Errorf("%w: %d Hz", ErrUnsupportedFormat, rate)
+	case align != BlockSize:
+		return fmt.Errorf("%w: blocks of %d bytes", ErrUnsupportedFormat, align)
+	}
+	return nil
+}
+
+// wav49Header returns the header of a WAV49 file of samples samples. A
+// negative samples marks the sizes as unknown.
+func wav49Header(samples int64) []byte {
+	riffSize, factSamples, dataSize := uint32(unknownSize), uint32(unknownSize), uint32(unknownSize)
+	if samples >= 0 {
+		blocks := (samples + BlockSamples - 1) / BlockSamples
+		dataSize = uint32(blocks * BlockSize)
+		riffSize = wav49HeaderSize - 8 + dataSize + dataSize&1
+		factSamples = uint32(samples)
+	}
+
+	h := make([]byte, 0, wav49HeaderSize)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, riffSize)
+	h = append(h, "WAVE"...)
+
+	h = append(h, "fmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 20)
+	h = binary.LittleEndian.AppendUint16(h, formatGSM)
+	h = binary.LittleEndian.AppendUint16(h, 1)
+	h = binary.LittleEndian.AppendUint32(h, Rate)
+	h = binary.LittleEndian.AppendUint32(h, Rate*BlockSize/BlockSamples) // byte rate
+	h = binary.LittleEndian.AppendUint16(h, BlockSize)
+	h = binary.LittleEndian.AppendUint16(h, 0) // no bits per sample
+	h = binary.LittleEndian.AppendUint16(h, 2) // extension size
+	h = binary.LittleEndian.AppendUint16(h, BlockSamples)
+
+	h = append(h, "fact"...)
+	h = binary.LittleEndian.AppendUint32(h, 4)
+	h = binary.LittleEndian.AppendUint32(h, factSamples)
+
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, dataSize)
+	return h
+}
@@ -3,24 +3,42 @@
 package mp3
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"time"
 
 	gomp3 "github.com/hajimehoshi/go-mp3"
 	"github.com/ik5/audpbx/audio"
 )
 
+// outFrameSize is the size of a frame of go-mp3 output: two channels of
+// 16-bit samples, whatever the stream has
+const outFrameSize = 4
+
 // mp3Reader is an interface for gomp3.Decoder to allow testing
 type mp3Reader interface {
 	Read([]byte) (int, error)
 	SampleRate() int
 }
 
+// mp3Seeker is the random access part of gomp3.Decoder, available when
+// it reads from an io.Seeker
+type mp3Seeker interface {
+	mp3Reader
+	io.Seeker
+	Length() int64
+}
+
 type source struct {
 	dec        mp3Reader
 	sampleRate int
 	channels   int
 	buf        []byte
+	read       int64 // samples returned so far
+	length     int64 // frames, -1 when unknown
+	tags       audio.Tags
 }
 
 func (s *source) SampleRate() int { return s.sampleRate }
@@ -28,50 +46,189 @@ func (s *source) Channels() int   { return s.channels }
 func (s *source) Close() error    { return nil }
 func (s *source) BufSize() int    { return cap(s.buf) / 2 } // return sample capacity, not bytes
 
+func (s *source) Format() audio.Format {
+	return audio.Format{
+		Rate:       s.sampleRate,
+		Channels:   s.channels,
+		Layout:     audio.DefaultLayout(s.channels),
+		SampleKind: audio.SampleInt16,
+	}
+}
+
+// Metadata returns the title, artist, album and length from the ID3v2
+// tags at the start of the stream, completed from an ID3v1 tag at the end
+// when the input is seekable.
+func (s *source) Metadata() audio.Tags { return s.tags }
+
+// TotalFrames returns the length go-mp3 found by indexing the frames of a
+// seekable input, or -1 for other inputs.
+func (s *source) TotalFrames() int64 { return s.length }
+
+func (s *source) Duration() time.Duration {
+	return audio.FramesDuration(s.length, s.sampleRate)
+}
+
+// ReadSamples fills dst with whole frames, rounding its length down to a
+// multiple of the channel count. A dst shorter than a frame fails with
+// audio.ErrInvalidDstSize.
 func (s *source) ReadSamples(dst []float32) (int, error) {
-	// go-mp3 returns 16-bit little-endian PCM bytes (stereo interleaved)
-	// Each sample is 2 bytes, so we need len(dst) * 2 bytes
-	bytesNeeded := len(dst) * 2
-	if cap(s.buf) < bytesNeeded {
-		s.buf = make([]byte, bytesNeeded)
-	}
-	s.buf = s.buf[:bytesNeeded]
-
-	n, err := s.dec.Read(s.buf)
-	if n == 0 {
-		if err != nil {
-			return 0, err
-		}
+	if len(dst) == 0 {
 		return 0, nil
 	}
+	if len(dst) < s.channels {
+		return 0, audio.ErrInvalidDstSize
+	}
 
-	// Convert bytes to samples
-	// Each sample is 2 bytes (int16 little-endian)
-	samples := n / 2
+	// go-mp3 returns 16-bit little-endian PCM bytes, always stereo
+	// interleaved; mono streams have the same sample in both channels, of
+	// which the left one is kept
+	frames, err := s.readFrames(len(dst) / s.channels)
+	step := outFrameSize / 2 * (3 - s.channels) // bytes between samples kept
+	samples := frames * s.channels
 	for i := range samples {
-		// Read int16 little-endian
-		low := uint16(s.buf[2*i])
-		high := uint16(s.buf[2*i+1])
-		val := int16(low | (high << 8))
-		dst[i] = float32(val) / 32768.0
+		dst[i] = pcmSample(s.buf[step*i:])
 	}
+	s.read += int64(samples)
 
 	return samples, err
 }
 
+// readFrames reads up to n frames of go-mp3 output into buf and returns
+// how many it read. go-mp3 is a plain io.Reader that may stop within a
+// frame, so the rest of a frame it began is read before returning.
+func (s *source) readFrames(n int) (int, error) {
+	size := n * outFrameSize
+	if cap(s.buf) < size {
+		s.buf = make([]byte, size)
+	}
+	s.buf = s.buf[:size]
+
+	got, err := s.dec.Read(s.buf)
+	if part := got % outFrameSize; part != 0 && err == nil {
+		var k int
+		k, err = io.ReadFull(s.dec, s.buf[got:got-part+outFrameSize])
+		got += k
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) && got%outFrameSize != 0 {
+		// the stream ended within a frame, which is dropped
+		err = io.EOF
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return got / outFrameSize, fmt.Errorf("%w", err)
+	}
+	return got / outFrameSize, err
+}
+
+// pcmSample converts the int16 little-endian sample at the start of b
+func pcmSample(b []byte) float32 {
+	return float32(int16(uint16(b[0])|uint16(b[1])<<8)) / 32768.0
+}
+
+// seekSource is a source over a seekable MP3 stream
+type seekSource struct {
+	*source
+	seeker mp3Seeker
+}
+
+// SeekFrame moves to frame n. go-mp3 decodes the MP3 frame before the
+// target as well, so the first samples after a seek are glitch free.
+func (s *seekSource) SeekFrame(n int64) error {
+	if n < 0 || n*outFrameSize > s.seeker.Length() {
+		return fmt.Errorf("%w: frame %d", audio.ErrSeekOutOfRange, n)
+	}
+
+	if _, err := s.seeker.Seek(n*outFrameSize, io.SeekStart); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	s.read = n * int64(s.channels)
+	return nil
+}
+
+func (s *seekSource) Position() int64 { return s.read / int64(s.channels) }
+
 type Decoder struct{}
 
+// Decode returns a source of the decoded MP3 stream, with the channel
+// count of its first frame: mono streams are decoded to one channel rather
+// than the duplicated stereo go-mp3 outputs. It implements audio.Lengther;
+// when r is an io.ReadSeeker, go-mp3 indexes the frames up front, so the
+// length is known and the source implements audio.Seeker.
 func (Decoder) Decode(r io.Reader) (audio.Source, error) {
+	info, r, err := probe(r)
+	if err != nil {
+		return nil, err
+	}
+
 	dec, err := gomp3.NewDecoder(r)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
 
-	// go-mp3 outputs stereo (2 channels) for most MP3 files
-	return &source{
+	src := &source{
 		dec:        dec,
 		sampleRate: dec.SampleRate(),
-		channels:   2,
+		channels:   info.channels,
+		tags:       info.tags,
 		buf:        make([]byte, 8192),
-	}, nil
+		length:     -1,
+	}
+	if dec.Length() >= 0 {
+		src.length = dec.Length() / outFrameSize
+	}
+	if _, ok := r.(io.ReadSeeker); ok && dec.Length() >= 0 {
+		return &seekSource{source: src, seeker: dec}, nil
+	}
+	return src, nil
+}
+
+// DecodeHeader reads the ID3 tags and the first frame header of the MP3
+// stream in r, without indexing its frames as Decode does for a seekable
+// r, so it returns in the same time for a file of any size. The length
+// comes from the Xing, Info or VBRI header of VBR files, else, when r is
+// an io.ReadSeeker, it is estimated from the size of the stream and the
+// bitrate of the first frame, which is exact for CBR files; it is -1
+// otherwise. It implements audio.HeaderDecoder.
+func (Decoder) DecodeHeader(r io.Reader) (audio.Header, error) {
+	info, _, err := probe(r)
+	if err != nil {
+		return audio.Header{}, err
+	}
+	first := info.first
+	if first.rate == 0 {
+		return audio.Header{}, ErrNoFrame
+	}
+
+	h := audio.Header{
+		Format: audio.Format{
+			Rate:       first.rate,
+			Channels:   info.channels,
+			Layout:     audio.DefaultLayout(info.channels),
+			SampleKind: audio.SampleInt16,
+		},
+		Frames: -1,
+		Tags:   info.tags,
+	}
+	switch {
+	case first.frames >= 0:
+		// go-mp3 decodes the frame of the Xing header too, as silence
+		h.Frames = (first.frames + 1) * int64(first.samples)
+	case info.size >= 0 && first.bitrate > 0:
+		bits := float64(info.size) * 8
+		frames := math.Round(bits / float64(first.bitrate) * float64(first.rate) / float64(first.samples))
+		h.Frames = int64(frames) * int64(first.samples)
+	}
+	return h, nil
+}
+
+// Capabilities reports what the package reads: the rates of MPEG-1, 2 and
+// 2.5, in mono or stereo. It does not encode.
+func (Decoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{
+		Name:        "MP3",
+		Decode:      true,
+		MaxChannels: 2,
+		Rates:       []int{8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100, 48000},
+		Seekable:    true,
+		Metadata:    true,
+	}
 }
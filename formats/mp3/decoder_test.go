@@ -5,9 +5,15 @@ package mp3
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 	"math"
+	"reflect"
 	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/ik5/audpbx/audio"
 )
 
 // mockMP3Reader simulates the gomp3.Decoder for testing
@@ -16,6 +22,7 @@ type mockMP3Reader struct {
 	samples      []int16 // PCM samples (16-bit)
 	offset       int
 	returnErrors bool
+	maxRead      int // bytes a Read returns at most, 0 for no limit
 }
 
 func (m *mockMP3Reader) SampleRate() int {
@@ -34,6 +41,9 @@ func (m *mockMP3Reader) Read(buf []byte) (int, error) {
 	// Calculate how many samples we can fit in the buffer
 	bytesAvailable := (len(m.samples) - m.offset) * 2
 	bytesToRead := len(buf)
+	if m.maxRead > 0 {
+		bytesToRead = min(bytesToRead, m.maxRead)
+	}
 	if bytesToRead > bytesAvailable {
 		bytesToRead = bytesAvailable
 	}
@@ -57,6 +67,18 @@ func (m *mockMP3Reader) Read(buf []byte) (int, error) {
 	return bytesToRead, nil
 }
 
+// Seek implements io.SeekStart seeks in bytes, like gomp3.Decoder
+func (m *mockMP3Reader) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, io.ErrUnexpectedEOF
+	}
+	m.offset = int(offset / 2)
+	return offset, nil
+}
+
+// Length returns the size of the decoded stream in bytes
+func (m *mockMP3Reader) Length() int64 { return int64(len(m.samples) * 2) }
+
 func TestDecoder_InvalidInput(t *testing.T) {
 	t.Parallel()
 
@@ -283,6 +305,7 @@ func TestSource_ReadSamples_ConversionAccuracy(t *testing.T) {
 		-32768, // Maximum negative (exactly -1.0)
 		16384,  // Quarter scale
 		-16384, // Negative quarter
+		0,      // Completes the last stereo frame
 	}
 
 	mockReader := &mockMP3Reader{
@@ -309,7 +332,7 @@ func TestSource_ReadSamples_ConversionAccuracy(t *testing.T) {
 	}
 
 	// Verify conversion accuracy
-	expected := []float32{0.0, 1.0 / 32768.0, -1.0 / 32768.0, 1.0, -1.0, 0.5, -0.5}
+	expected := []float32{0.0, 1.0 / 32768.0, -1.0 / 32768.0, 1.0, -1.0, 0.5, -0.5, 0.0}
 	for i := range n {
 		diff := math.Abs(float64(dst[i] - expected[i]))
 		if diff > 0.0001 {
@@ -521,6 +544,73 @@ func TestSource_StereoInterleaving(t *testing.T) {
 	}
 }
 
+func TestSource_ReadSamples_FrameBudget(t *testing.T) {
+	t.Parallel()
+
+	// go-mp3 output is stereo whatever the stream; sample i holds i
+	out := make([]int16, 40)
+	for i := range out {
+		out[i] = int16(i)
+	}
+
+	tests := []struct {
+		name     string
+		channels int
+		dst      int
+		maxRead  int // bytes per Read of go-mp3
+	}{
+		{name: "stereo, odd dst", channels: 2, dst: 5},
+		{name: "stereo, Read within a frame", channels: 2, dst: 8, maxRead: 6},
+		{name: "stereo, odd dst and Read within a frame", channels: 2, dst: 7, maxRead: 10},
+		{name: "mono, Read within a frame", channels: 1, dst: 3, maxRead: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src := &source{
+				dec:        &mockMP3Reader{sampleRate: 8000, samples: out, maxRead: tt.maxRead},
+				sampleRate: 8000,
+				channels:   tt.channels,
+			}
+			var got []float32
+			dst := make([]float32, tt.dst)
+			for {
+				n, err := src.ReadSamples(dst)
+				if n%tt.channels != 0 {
+					t.Fatalf("ReadSamples() = %d samples, not whole frames", n)
+				}
+				got = append(got, dst[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+
+			if len(got) != 20*tt.channels {
+				t.Fatalf("read %d samples, want %d", len(got), 20*tt.channels)
+			}
+			for i, v := range got {
+				want := i
+				if tt.channels == 1 {
+					want = 2 * i // the left channel
+				}
+				if v != float32(want)/32768 {
+					t.Fatalf("sample %d = %v, want %v", i, v*32768, want)
+				}
+			}
+		})
+	}
+
+	src := &source{dec: &mockMP3Reader{sampleRate: 8000, samples: out}, sampleRate: 8000, channels: 2}
+	if _, err := src.ReadSamples(make([]float32, 1)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(half a frame) error = %v, want ErrInvalidDstSize", err)
+	}
+}
+
 // BenchmarkSource_ReadSamples benchmarks reading samples
 func BenchmarkSource_ReadSamples(b *testing.B) {
 	samples := make([]int16, 44100*10) // 10 seconds
@@ -665,3 +755,455 @@ func BenchmarkSource_FullRead(b *testing.B) {
 		}
 	}
 }
+
+func TestSeekSource_SeekFrame(t *testing.T) {
+	t.Parallel()
+
+	// 6 stereo frames where frame i holds i/8 on both channels
+	samples := make([]int16, 12)
+	for i := range samples {
+		samples[i] = int16(i / 2 * 4096)
+	}
+	mock := &mockMP3Reader{sampleRate: 8000, samples: samples}
+	src := &seekSource{
+		source: &source{dec: mock, sampleRate: 8000, channels: 2, buf: make([]byte, 64)},
+		seeker: mock,
+	}
+
+	dst := make([]float32, 4)
+	if _, err := src.ReadSamples(dst); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	if got := src.Position(); got != 2 {
+		t.Errorf("Position() = %d, want 2", got)
+	}
+
+	tests := []struct {
+		frame   int64
+		want    float32
+		wantErr error
+	}{
+		{frame: 4, want: 0.5},
+		{frame: 0, want: 0},
+		{frame: 5, want: 0.625},
+		{frame: 6, wantErr: nil},
+		{frame: 7, wantErr: audio.ErrSeekOutOfRange},
+		{frame: -1, wantErr: audio.ErrSeekOutOfRange},
+	}
+
+	for _, tt := range tests {
+		err := src.SeekFrame(tt.frame)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("SeekFrame(%d) error = %v, want %v", tt.frame, err, tt.wantErr)
+		}
+		if err != nil || tt.frame == 6 {
+			continue
+		}
+		if got := src.Position(); got != tt.frame {
+			t.Errorf("Position() = %d, want %d", got, tt.frame)
+		}
+		if _, err := src.ReadSamples(dst[:2]); err != nil && !errors.Is(err, io.EOF) {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+		if dst[0] != tt.want || dst[1] != tt.want {
+			t.Errorf("frame %d = %v, want %v", tt.frame, dst[:2], tt.want)
+		}
+	}
+}
+
+func TestDecoder_NotSeekable(t *testing.T) {
+	t.Parallel()
+
+	var _ audio.Seeker = (*seekSource)(nil)
+	if _, ok := any(&source{}).(audio.Seeker); ok {
+		t.Error("source implements audio.Seeker without a seekable reader")
+	}
+}
+
+func TestSource_TotalFrames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		length int64
+		want   time.Duration
+	}{
+		{name: "known", length: 66150, want: 1500 * time.Millisecond},
+		{name: "unknown", length: -1, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var l audio.Lengther = &source{sampleRate: 44100, channels: 2, length: tt.length}
+			if got := l.TotalFrames(); got != tt.length {
+				t.Errorf("TotalFrames() = %d, want %d", got, tt.length)
+			}
+			if got := l.Duration(); got != tt.want {
+				t.Errorf("Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// silentMP3 returns frames silent MPEG-1 layer III frames, 128 kbit/s at
+// 44.1 kHz, in channel mode mode (0 stereo, 1 joint stereo, 3 mono)
+func silentMP3(frames int, mode byte) []byte {
+	var b bytes.Buffer
+	for range frames {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, mode << 6})
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func TestDecoder_Channels(t *testing.T) {
+	t.Parallel()
+
+	// An ID3v2 tag of 300 bytes, with a fake frame sync inside
+	tag := append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 2, 44}, make([]byte, 300)...)
+	tag[50], tag[51], tag[52], tag[53] = 0xFF, 0xFB, 0x90, 0xC0
+
+	tests := []struct {
+		name     string
+		data     []byte
+		seekable bool
+		want     int
+	}{
+		{name: "mono", data: silentMP3(5, 3), seekable: true, want: 1},
+		{name: "mono stream", data: silentMP3(5, 3), want: 1},
+		{name: "stereo", data: silentMP3(5, 0), seekable: true, want: 2},
+		{name: "joint stereo", data: silentMP3(5, 1), want: 2},
+		{name: "tagged mono", data: append(tag[:len(tag):len(tag)], silentMP3(5, 3)...), seekable: true, want: 1},
+		{name: "tagged mono stream", data: append(tag[:len(tag):len(tag)], silentMP3(5, 3)...), want: 1},
+		{name: "tagged stereo stream", data: append(tag[:len(tag):len(tag)], silentMP3(5, 0)...), want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r io.Reader = bytes.NewReader(tt.data)
+			if !tt.seekable {
+				r = io.MultiReader(r)
+			}
+			src, err := Decoder{}.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got := src.Channels(); got != tt.want {
+				t.Errorf("Channels() = %d, want %d", got, tt.want)
+			}
+			if got := audio.FormatOf(src).Channels; got != tt.want {
+				t.Errorf("Format().Channels = %d, want %d", got, tt.want)
+			}
+			if _, ok := src.(audio.Seeker); ok != tt.seekable {
+				t.Errorf("source is audio.Seeker = %v, want %v", ok, tt.seekable)
+			}
+
+			// 5 frames of 1152 samples per channel
+			samples := 0
+			buf := make([]float32, 1000*tt.want)
+			for {
+				n, err := src.ReadSamples(buf)
+				samples += n
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+			if want := 5 * 1152 * tt.want; samples != want {
+				t.Errorf("read %d samples, want %d", samples, want)
+			}
+		})
+	}
+}
+
+func TestSource_Mono(t *testing.T) {
+	t.Parallel()
+
+	// go-mp3 duplicates mono into both channels
+	mock := &mockMP3Reader{sampleRate: 8000, samples: []int16{0, 0, 4096, 4096, 8192, 8192, 16384, 16384}}
+	src := &seekSource{
+		source: &source{dec: mock, sampleRate: 8000, channels: 1, buf: make([]byte, 4), length: 4},
+		seeker: mock,
+	}
+
+	dst := make([]float32, 3)
+	n, err := src.ReadSamples(dst)
+	if err != nil || n != 3 {
+		t.Fatalf("ReadSamples() = %d, %v, want 3, nil", n, err)
+	}
+	if want := []float32{0, 0.125, 0.25}; dst[0] != want[0] || dst[1] != want[1] || dst[2] != want[2] {
+		t.Errorf("ReadSamples() = %v, want %v", dst, want)
+	}
+	if got := src.Position(); got != 3 {
+		t.Errorf("Position() = %d, want 3", got)
+	}
+
+	if err := src.SeekFrame(1); err != nil {
+		t.Fatalf("SeekFrame(1) error = %v", err)
+	}
+	if n, _ := src.ReadSamples(dst[:1]); n != 1 || dst[0] != 0.125 {
+		t.Errorf("after SeekFrame(1) = %v, want 0.125", dst[:n])
+	}
+	if err := src.SeekFrame(5); !errors.Is(err, audio.ErrSeekOutOfRange) {
+		t.Errorf("SeekFrame(5) error = %v, want ErrSeekOutOfRange", err)
+	}
+}
+
+// id3Frame encodes an ID3v2 frame for version 2, 3 or 4
+func id3Frame(version byte, id string, data []byte) []byte {
+	n := len(data)
+	var hdr []byte
+	switch version {
+	case 2:
+		hdr = append([]byte(id), byte(n>>16), byte(n>>8), byte(n))
+	case 3:
+		hdr = binary.BigEndian.AppendUint32([]byte(id), uint32(n))
+		hdr = append(hdr, 0, 0)
+	default:
+		hdr = append([]byte(id), byte(n>>21&0x7F), byte(n>>14&0x7F), byte(n>>7&0x7F), byte(n&0x7F), 0, 0)
+	}
+	return append(hdr, data...)
+}
+
+// id3Tag encodes an ID3v2 tag of frames with 16 bytes of padding
+func id3Tag(version, flags byte, frames ...[]byte) []byte {
+	var body []byte
+	for _, f := range frames {
+		body = append(body, f...)
+	}
+	body = append(body, make([]byte, 16)...)
+	n := len(body)
+	hdr := []byte{'I', 'D', '3', version, 0, flags, byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+	return append(hdr, body...)
+}
+
+// id3v1 encodes an ID3v1 tag
+func id3v1(title, artist, album string) []byte {
+	b := make([]byte, 128)
+	copy(b, "TAG")
+	copy(b[3:33], title)
+	copy(b[33:63], artist)
+	copy(b[63:93], album)
+	return b
+}
+
+// utf16Text is an ID3v2 text frame body in UTF-16 with a little-endian BOM
+func utf16Text(s string) []byte {
+	b := []byte{1, 0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+func TestDecoder_Metadata(t *testing.T) {
+	t.Parallel()
+
+	join := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, p := range parts {
+			b = append(b, p...)
+		}
+		return b
+	}
+	audio5 := silentMP3(5, 3)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		seekable bool
+		want     audio.Tags
+	}{
+		{
+			name: "ID3v2.3",
+			data: join(id3Tag(3, 0,
+				id3Frame(3, "TIT2", []byte("\x00Main Menu")),
+				id3Frame(3, "APIC", make([]byte, 5000)),
+				id3Frame(3, "TPE1", utf16Text("Zoë")),
+				id3Frame(3, "TALB", []byte("\x00IVR\x00")),
+				id3Frame(3, "TLEN", []byte("\x00150")),
+			), audio5),
+			want: audio.Tags{Title: "Main Menu", Artist: "Zoë", Album: "IVR", Duration: 150 * time.Millisecond},
+		},
+		{
+			name: "ID3v2.4",
+			data: join(id3Tag(4, 0,
+				id3Frame(4, "TIT2", []byte("\x03Café")),
+				id3Frame(4, "TPE1", []byte("\x03A\x00B")),
+				id3Frame(4, "TALB", append([]byte{2}, 0, 'P', 0, 'B', 0, 'X')),
+			), audio5),
+			seekable: true,
+			want:     audio.Tags{Title: "Café", Artist: "A/B", Album: "PBX"},
+		},
+		{
+			name: "ID3v2.2",
+			data: join(id3Tag(2, 0,
+				id3Frame(2, "TT2", []byte("\x00Hold")),
+				id3Frame(2, "TP1", []byte("\x00Band")),
+				id3Frame(2, "TAL", []byte("\x00Music")),
+			), audio5),
+			want: audio.Tags{Title: "Hold", Artist: "Band", Album: "Music"},
+		},
+		{
+			name: "unsynchronised",
+			// ÿ is 0xFF in Latin-1, followed by the inserted zero
+			data: join(id3Tag(3, 0x80, id3Frame(3, "TIT2", []byte("\x00\xFF\x00s"))), audio5),
+			want: audio.Tags{Title: "ÿs"},
+		},
+		{
+			name:     "ID3v1",
+			data:     join(audio5, id3v1("Title", "Artist", "Album")),
+			seekable: true,
+			want:     audio.Tags{Title: "Title", Artist: "Artist", Album: "Album"},
+		},
+		{
+			name: "ID3v1 in a stream",
+			data: join(audio5, id3v1("Title", "Artist", "Album")),
+		},
+		{
+			name: "ID3v2 before ID3v1",
+			data: join(id3Tag(3, 0, id3Frame(3, "TIT2", []byte("\x00Long title of the prompt"))),
+				audio5, id3v1("Long title of", "Artist", "Album")),
+			seekable: true,
+			want:     audio.Tags{Title: "Long title of the prompt", Artist: "Artist", Album: "Album"},
+		},
+		{
+			name: "two ID3v2 tags",
+			data: join(id3Tag(4, 0, id3Frame(4, "TIT2", []byte("\x03First"))),
+				id3Tag(3, 0, id3Frame(3, "TIT2", []byte("\x00Second")), id3Frame(3, "TPE1", []byte("\x00Artist"))),
+				audio5),
+			want: audio.Tags{Title: "First", Artist: "Artist"},
+		},
+		{
+			name: "no tags",
+			data: audio5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r io.Reader = bytes.NewReader(tt.data)
+			if !tt.seekable {
+				r = io.MultiReader(r)
+			}
+			src, err := Decoder{}.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			m, ok := src.(audio.Metadata)
+			if !ok {
+				t.Fatal("source does not implement audio.Metadata")
+			}
+			if got := m.Metadata(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Metadata() = %+v, want %+v", got, tt.want)
+			}
+
+			// The tags are not decoded as audio
+			if src.Channels() != 1 {
+				t.Errorf("Channels() = %d, want 1", src.Channels())
+			}
+			if l := src.(audio.Lengther); tt.seekable && l.TotalFrames() != 5*1152 {
+				t.Errorf("TotalFrames() = %d, want %d", l.TotalFrames(), 5*1152)
+			}
+			samples := 0
+			buf := make([]float32, 1000)
+			for {
+				n, err := src.ReadSamples(buf)
+				samples += n
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+			if samples != 5*1152 {
+				t.Errorf("read %d samples, want %d", samples, 5*1152)
+			}
+		})
+	}
+}
+
+// xingMP3 returns silentMP3(frames, 3) after a frame holding a Xing header
+// counting them
+func xingMP3(frames int) []byte {
+	first := silentMP3(1, 3)
+	copy(first[4+17:], "Xing\x00\x00\x00\x01")
+	binary.BigEndian.PutUint32(first[4+17+8:], uint32(frames))
+	return append(first, silentMP3(frames, 3)...)
+}
+
+func TestDecoder_DecodeHeader(t *testing.T) {
+	t.Parallel()
+
+	tag := id3Tag(3, 0, id3Frame(3, "TIT2", []byte("\x00Main Menu")))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		seekable bool
+		channels int
+		frames   int64
+		title    string
+	}{
+		{name: "CBR", data: silentMP3(5, 0), seekable: true, channels: 2, frames: 5 * 1152},
+		{name: "CBR stream", data: silentMP3(5, 0), channels: 2, frames: -1},
+		{name: "Xing", data: xingMP3(7), seekable: true, channels: 1, frames: 8 * 1152},
+		{name: "Xing stream", data: xingMP3(7), channels: 1, frames: 8 * 1152},
+		{
+			name:     "tagged",
+			data:     append(append(tag, silentMP3(9, 3)...), id3v1("Title", "Artist", "Album")...),
+			seekable: true,
+			channels: 1,
+			frames:   9 * 1152,
+			title:    "Main Menu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r io.Reader = bytes.NewReader(tt.data)
+			if !tt.seekable {
+				r = io.MultiReader(r)
+			}
+			h, err := Decoder{}.DecodeHeader(r)
+			if err != nil {
+				t.Fatalf("DecodeHeader() error = %v", err)
+			}
+			want := audio.Format{Rate: 44100, Channels: tt.channels, Layout: audio.DefaultLayout(tt.channels), SampleKind: audio.SampleInt16}
+			if h.Format != want {
+				t.Errorf("Format = %v, want %v", h.Format, want)
+			}
+			if h.Frames != tt.frames || h.Tags.Title != tt.title {
+				t.Errorf("Frames, Title = %d, %q, want %d, %q", h.Frames, h.Tags.Title, tt.frames, tt.title)
+			}
+
+			// As long as what Decode indexes
+			if tt.seekable {
+				src, err := Decoder{}.Decode(bytes.NewReader(tt.data))
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if got := audio.HeaderOf(src); got.Frames != h.Frames || got.Format != h.Format {
+					t.Errorf("HeaderOf(Decode()) = %+v, want %+v", got, h)
+				}
+			}
+		})
+	}
+
+	if _, err := (Decoder{}).DecodeHeader(bytes.NewReader(make([]byte, 1000))); !errors.Is(err, ErrNoFrame) {
+		t.Errorf("DecodeHeader(no frame) error = %v, want ErrNoFrame", err)
+	}
+}
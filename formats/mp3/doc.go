@@ -10,7 +10,7 @@
 // The decoder supports:
 //   - MP3 (MPEG-1 Audio Layer 3)
 //   - Various bitrates
-//   - Stereo output (most MP3 files)
+//   - Mono and stereo streams
 //
 // # Decoding MP3 Files
 //
@@ -30,16 +30,45 @@
 // The decoder returns an audio.Source that provides samples as float32
 // values normalized to the range [-1.0, 1.0].
 //
+// When the input is an io.ReadSeeker, go-mp3 indexes the frames while
+// decoding the header and the source implements audio.Seeker, and its
+// audio.Lengther methods report the length. Indexing reads the whole file
+// once, which is quick since nothing is decoded.
+//
+// DecodeHeader reads the tags and the first frame header only, for
+// validating large uploads: the length comes from the Xing or VBRI header
+// of VBR files, and is computed from the bitrate of CBR ones.
+//
+// # Tags
+//
+// ID3v2 tags (v2.2 to v2.4) at the start of the stream, and an ID3v1 tag
+// at the end of seekable input, are parsed and skipped, so they are never
+// decoded as audio nor taken for its first frame. The source implements
+// audio.Metadata with the title, artist, album and, from TLEN, duration
+// they carry; ID3v2 fields take precedence over ID3v1 ones:
+//
+//	if m, ok := source.(audio.Metadata); ok {
+//	    tags := m.Metadata()
+//	    fmt.Println(tags.Artist, "-", tags.Title)
+//	}
+//
+// Pictures and other frames are skipped without being read into memory.
+//
 // # Output Format
 //
 // MP3 decoder output:
 //   - Sample format: float32 in range [-1.0, 1.0]
-//   - Channels: 2 (stereo)
+//   - Channels: 1 for mono streams, 2 for the stereo modes
 //   - Sample rate: Depends on the MP3 file (typically 44.1kHz or 48kHz)
 //
+// go-mp3 itself always decodes to stereo, duplicating mono streams; the
+// decoder reads the mode of the first frame header and returns the left
+// channel only for mono streams, so they are not mixed down twice or taken
+// for stereo.
+//
 // To convert to mono or resample, use the audio package:
 //
-//	// Convert stereo MP3 to mono 8kHz
+//	// Convert MP3 to mono 8kHz (MonoMixer passes mono through)
 //	mp3Source, _ := decoder.Decode(file)
 //	resampled := audio.NewResampler(mp3Source, 8000)
 //	mono := audio.NewMonoMixer(resampled)
@@ -55,7 +84,8 @@
 //
 // Note:
 //   - MP3 writing is not supported (decoding only)
-//   - Output is always stereo (use MonoMixer to convert)
+//   - The channel count comes from the first frame; streams that change
+//     mode midway keep it
 //   - Requires reading entire frames for decoding
 //
 // # Use Cases
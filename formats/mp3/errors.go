@@ -0,0 +1,9 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package mp3
+
+import "errors"
+
+// ErrNoFrame indicates a stream without an MPEG audio layer III frame
+// header where one is looked for.
+var ErrNoFrame = errors.New("no MP3 frame header found")
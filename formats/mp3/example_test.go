@@ -208,9 +208,9 @@ func ExampleDecoder_Decode_streaming() {
 	fmt.Printf("Streamed %d samples from MP3\n", totalSamples)
 }
 
-// ExampleDecoder_Decode_metadata shows how MP3 decoding handles stereo output.
+// ExampleDecoder_Decode_metadata shows how MP3 decoding reports channels.
 func ExampleDecoder_Decode_metadata() {
-	// MP3 decoder always outputs stereo
+	// input.mp3 is a stereo file
 	f, err := os.Open("input.mp3")
 	if err != nil {
 		log.Fatal(err)
@@ -223,16 +223,14 @@ func ExampleDecoder_Decode_metadata() {
 		log.Fatal(err)
 	}
 
-	// MP3 decoder provides stereo output
-	if src.Channels() == 2 {
-		fmt.Println("MP3 decoded as stereo")
-	}
+	// Mono MP3s decode to one channel, the others to two
+	fmt.Printf("MP3 decoded with %d channel(s)\n", src.Channels())
 
 	// Use MonoMixer if mono output is needed
 	mono := audio.NewMonoMixer(src)
 	fmt.Printf("Converted to %d channel(s)\n", mono.Channels())
 
 	// Output:
-	// MP3 decoded as stereo
+	// MP3 decoded with 2 channel(s)
 	// Converted to 1 channel(s)
 }
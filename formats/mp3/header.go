@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package mp3
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// maxSync is how far past the tags the first frame header is looked for
+const maxSync = 64 * 1024
+
+// streamInfo is what is known of an MP3 stream before go-mp3 decodes it
+type streamInfo struct {
+	channels int
+	tags     audio.Tags
+	first    frameHeader // zero when no frame header is found
+	size     int64       // bytes of audio from the first frame, -1 when unknown
+}
+
+// frameHeader is what the first frame of a stream tells
+type frameHeader struct {
+	rate    int
+	samples int // per channel, per frame
+	bitrate int // bit/s
+	frames  int64
+	offset  int // from the end of the tags
+}
+
+// Bitrates of layer III by index, in kbit/s, for MPEG-1 and for MPEG-2 and
+// 2.5
+var bitrates = [2][15]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// Sample rates by version bits (MPEG-2.5, reserved, MPEG-2, MPEG-1) and
+// index
+var frameRates = [4][3]int{
+	{11025, 12000, 8000},
+	{},
+	{22050, 24000, 16000},
+	{44100, 48000, 32000},
+}
+
+// probe reads the ID3 tags and the header of the first MPEG audio frame of
+// r, whose channel mode gives the channels: 1 for mono and 2 for the
+// stereo modes, or 2 when no frame header is found, leaving the error to
+// go-mp3. It returns a reader of the audio alone, without the tags, so
+// they can never be taken for audio: a section of r when it is seekable,
+// or a buffered reader of r past the tags.
+func probe(r io.Reader) (streamInfo, io.Reader, error) {
+	rs, seekable := r.(io.ReadSeeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = rs.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	br := bufio.NewReaderSize(r, maxSync)
+
+	info := streamInfo{size: -1}
+	tagLen, err := skipID3v2(br, &info.tags)
+	if err != nil {
+		return streamInfo{}, nil, err
+	}
+	if info.channels, info.first, err = scanFrame(br); err != nil {
+		return streamInfo{}, nil, err
+	}
+
+	if !seekable {
+		return info, br, nil
+	}
+
+	// An ID3v1 tag takes the last 128 bytes
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return streamInfo{}, nil, fmt.Errorf("%w", err)
+	}
+	if end-start-tagLen >= id3v1Size {
+		v1 := make([]byte, id3v1Size)
+		if _, err := rs.Seek(end-id3v1Size, io.SeekStart); err != nil {
+			return streamInfo{}, nil, fmt.Errorf("%w", err)
+		}
+		if _, err := io.ReadFull(rs, v1); err != nil {
+			return streamInfo{}, nil, fmt.Errorf("%w", err)
+		}
+		if string(v1[:3]) == "TAG" {
+			parseID3v1(v1, &info.tags)
+			end -= id3v1Size
+		}
+	}
+
+	sec := &section{rs: rs, base: start + tagLen, size: max(end-start-tagLen, 0)}
+	info.size = max(sec.size-int64(info.first.offset), 0)
+	if _, err := sec.Seek(0, io.SeekStart); err != nil {
+		return streamInfo{}, nil, err
+	}
+	return info, sec, nil
+}
+
+// scanFrame reads the first frame header in br, without consuming
+// anything, and returns the channels of its mode
+func scanFrame(br *bufio.Reader) (int, frameHeader, error) {
+	b, err := br.Peek(maxSync)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return 0, frameHeader{}, fmt.Errorf("%w", err)
+	}
+
+	for i := 0; i+4 <= len(b); i++ {
+		if validHeader(b[i:]) {
+			channels := 2
+			if b[i+3]>>6 == 3 {
+				channels = 1
+			}
+			h := parseHeader(b[i:], channels)
+			h.offset = i
+			return channels, h, nil
+		}
+	}
+	return 2, frameHeader{}, nil
+}
+
+// parseHeader parses the valid frame header at the start of b, and the
+// frame count of a Xing, Info or VBRI header in the frame, which encoders
+// put in a silent first frame. Without one, frames is -1.
+func parseHeader(b []byte, channels int) frameHeader {
+	version := (b[1] >> 3) & 0x03
+	mpeg1 := version == 3
+	h := frameHeader{
+		rate:    frameRates[version][(b[2]>>2)&0x03],
+		samples: 576,
+		frames:  -1,
+	}
+	if mpeg1 {
+		h.samples = 1152
+		h.bitrate = bitrates[0][b[2]>>4] * 1000
+	} else {
+		h.bitrate = bitrates[1][b[2]>>4] * 1000
+	}
+
+	// The Xing header follows the side information
+	side := 17
+	switch {
+	case mpeg1 && channels == 2:
+		side = 32
+	case !mpeg1 && channels == 1:
+		side = 9
+	}
+	xing := 4 + side
+	if b[1]&0x01 == 0 {
+		xing += 2 // CRC
+	}
+	const vbri = 4 + 32
+
+	switch {
+	case len(b) >= xing+12 && (string(b[xing:xing+4]) == "Xing" || string(b[xing:xing+4]) == "Info"):
+		if b[xing+7]&0x01 != 0 {
+			h.frames = int64(binary.BigEndian.Uint32(b[xing+8:]))
+		}
+	case len(b) >= vbri+18 && string(b[vbri:vbri+4]) == "VBRI":
+		h.frames = int64(binary.BigEndian.Uint32(b[vbri+14:]))
+	}
+	return h
+}
+
+// validHeader reports whether b starts with an MPEG audio layer III frame
+// header: frame sync, a defined version, a usable bitrate and a defined
+// sample rate
+func validHeader(b []byte) bool {
+	return b[0] == 0xFF && b[1]&0xE0 == 0xE0 &&
+		(b[1]>>3)&0x03 != 1 && // reserved version
+		(b[1]>>1)&0x03 == 1 && // layer III
+		b[2]>>4 != 15 && // bad bitrate
+		(b[2]>>2)&0x03 != 3 // reserved sample rate
+}
+
+// section is the part of a ReadSeeker holding the audio, addressed from
+// its start, like io.SectionReader without needing io.ReaderAt
+type section struct {
+	rs   io.ReadSeeker
+	base int64
+	size int64
+	pos  int64
+}
+
+func (s *section) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	p = p[:min(int64(len(p)), s.size-s.pos)]
+	n, err := s.rs.Read(p)
+	s.pos += int64(n)
+	return n, err
+}
+
+func (s *section) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: negative position", audio.ErrSeekOutOfRange)
+	}
+	if _, err := s.rs.Seek(s.base+offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	s.pos = offset
+	return offset, nil
+}
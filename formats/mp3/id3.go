@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package mp3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+const (
+	// id3v1Size is the size of an ID3v1 tag at the end of a file
+	id3v1Size = 128
+
+	// maxTextFrame bounds the text frames that are read; longer ones,
+	// like all other frames, are skipped without being read into memory
+	maxTextFrame = 64 * 1024
+)
+
+// ID3v2 header flags
+const (
+	flagUnsync    = 0x80
+	flagExtHeader = 0x40
+	flagFooter    = 0x10
+)
+
+// id3Fields maps the ID3v2.3/2.4 and ID3v2.2 frame IDs that are read to
+// the Tags field they fill
+var id3Fields = map[string]func(*audio.Tags) *string{
+	"TIT2": func(t *audio.Tags) *string { return &t.Title },
+	"TPE1": func(t *audio.Tags) *string { return &t.Artist },
+	"TALB": func(t *audio.Tags) *string { return &t.Album },
+	"TT2":  func(t *audio.Tags) *string { return &t.Title },
+	"TP1":  func(t *audio.Tags) *string { return &t.Artist },
+	"TAL":  func(t *audio.Tags) *string { return &t.Album },
+}
+
+// skipID3v2 reads the ID3v2 tags at the start of br, filling tags, and
+// returns the number of bytes they take. Fields already set are kept, so
+// the first tag wins. Tags that cannot be parsed are skipped whole.
+func skipID3v2(br *bufio.Reader, tags *audio.Tags) (int64, error) {
+	var skipped int64
+	for {
+		hdr, err := br.Peek(10)
+		if errors.Is(err, io.EOF) || (err == nil && string(hdr[:3]) != "ID3") {
+			return skipped, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+
+		version, flags := hdr[3], hdr[5]
+		size := int64(syncsafe(hdr[6:10]))
+		if flags&flagFooter != 0 {
+			size += 10
+		}
+		if _, err := br.Discard(10); err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+
+		body := io.LimitReader(br, size)
+		if version >= 2 && version <= 4 {
+			var r io.Reader = body
+			if flags&flagUnsync != 0 && version < 4 {
+				r = &unsyncReader{r: bufio.NewReader(body)}
+			}
+			readID3Frames(r, version, flags, tags)
+		}
+		// Whatever the frames did not read
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return 0, fmt.Errorf("%w", err)
+		}
+		skipped += 10 + size
+	}
+}
+
+// readID3Frames reads the frames of an ID3v2 tag body from r, up to the
+// padding, the end of the tag or a malformed frame
+func readID3Frames(r io.Reader, version, flags byte, tags *audio.Tags) {
+	if flags&flagExtHeader != 0 && version >= 3 {
+		var b [4]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return
+		}
+		// v2.4 counts the size field in, v2.3 does not
+		n := int64(binary.BigEndian.Uint32(b[:]))
+		if version == 4 {
+			n = int64(syncsafe(b[:])) - 4
+		}
+		if _, err := io.CopyN(io.Discard, r, n); err != nil {
+			return
+		}
+	}
+
+	idLen, hdrLen := 4, 10
+	if version == 2 {
+		idLen, hdrLen = 3, 6
+	}
+	hdr := make([]byte, hdrLen)
+	for {
+		if _, err := io.ReadFull(r, hdr); err != nil || hdr[0] == 0 {
+			return // end of the tag or padding
+		}
+
+		id := string(hdr[:idLen])
+		var size int64
+		var format byte
+		switch version {
+		case 2:
+			size = int64(hdr[3])<<16 | int64(hdr[4])<<8 | int64(hdr[5])
+		case 3:
+			size = int64(binary.BigEndian.Uint32(hdr[4:8]))
+			// Compressed or encrypted
+			if hdr[9]&0xC0 != 0 {
+				format = 0xFF
+			}
+		default:
+			size = int64(syncsafe(hdr[4:8]))
+			format = hdr[9]
+		}
+
+		field, wanted := id3Fields[id]
+		if id == "TLEN" || id == "TLE" {
+			wanted = true
+		}
+		if !wanted || size > maxTextFrame || (version == 4 && format&0x0C != 0) || format == 0xFF {
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				return
+			}
+			continue
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return
+		}
+		if version == 4 {
+			if format&0x02 != 0 {
+				data = unsync(data)
+			}
+			if format&0x01 != 0 && len(data) >= 4 {
+				data = data[4:] // data length indicator
+			}
+		}
+
+		text := decodeText(data)
+		switch {
+		case field != nil:
+			if p := field(tags); *p == "" {
+				*p = text
+			}
+		case tags.Duration == 0:
+			if ms, err := strconv.ParseInt(text, 10, 64); err == nil && ms > 0 {
+				tags.Duration = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// decodeText decodes an ID3v2 text frame: an encoding byte followed by
+// one or more NUL separated strings, which are joined with "/"
+func decodeText(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	enc, data := data[0], data[1:]
+
+	var s string
+	switch enc {
+	case 1, 2: // UTF-16 with a BOM, UTF-16BE
+		order := binary.ByteOrder(binary.BigEndian)
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			u := order.Uint16(data[i:])
+			switch {
+			case u == 0xFFFE && enc == 1:
+				order = binary.LittleEndian
+			case u == 0xFEFF && enc == 1:
+				order = binary.BigEndian
+			default:
+				units = append(units, order.Uint16(data[i:]))
+			}
+		}
+		s = string(utf16.Decode(units))
+	case 3: // UTF-8
+		s = string(data)
+	default: // ISO-8859-1
+		s = latin1(data)
+	}
+
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == 0 })
+	return strings.TrimSpace(strings.Join(parts, "/"))
+}
+
+// parseID3v1 fills the empty fields of tags from an ID3v1 tag
+func parseID3v1(b []byte, tags *audio.Tags) {
+	if len(b) != id3v1Size || string(b[:3]) != "TAG" {
+		return
+	}
+
+	field := func(p []byte) string {
+		if i := bytes.IndexByte(p, 0); i >= 0 {
+			p = p[:i]
+		}
+		return strings.TrimSpace(latin1(p))
+	}
+	for p, v := range map[*string]string{
+		&tags.Title:  field(b[3:33]),
+		&tags.Artist: field(b[33:63]),
+		&tags.Album:  field(b[63:93]),
+	} {
+		if *p == "" {
+			*p = v
+		}
+	}
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// syncsafe decodes a 28-bit ID3v2 size: 7 bits per byte
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// unsync undoes ID3v2 unsynchronisation, which inserts a zero byte after
+// every 0xFF
+func unsync(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	var prev byte
+	for _, c := range b {
+		if c != 0 || prev != 0xFF {
+			out = append(out, c)
+		}
+		prev = c
+	}
+	return out
+}
+
+// unsyncReader undoes ID3v2 unsynchronisation on a whole tag
+type unsyncReader struct {
+	r      *bufio.Reader
+	prevFF bool
+}
+
+func (u *unsyncReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c, err := u.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if c == 0 && u.prevFF {
+			u.prevFF = false
+			continue
+		}
+		u.prevFF = c == 0xFF
+		p[n] = c
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,312 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package opus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// maxPacketSamples is the longest packet duration, 120 ms at 48 kHz.
+const maxPacketSamples = 5760
+
+// PacketDecoder decodes single Opus packets into 48 kHz float32 PCM.
+type PacketDecoder interface {
+	// Decode decodes packet into pcm as interleaved samples and returns the
+	// number of samples per channel. pcm always has room for 120 ms. A nil
+	// packet asks for packet loss concealment of one packet duration.
+	Decode(packet []byte, pcm []float32) (int, error)
+}
+
+// Decoder decodes Ogg Opus streams. NewPacketDecoder must be set; it is
+// called once per stream with its OpusHead.
+type Decoder struct {
+	NewPacketDecoder func(head Head) (PacketDecoder, error)
+}
+
+// ReadHeaders reads the OpusHead and OpusTags headers from the start of r.
+// It does not need a PacketDecoder.
+func ReadHeaders(r io.Reader) (Head, Tags, error) {
+	_, head, tags, err := readHeaders(r)
+	return head, tags, err
+}
+
+func readHeaders(r io.Reader) (*oggReader, Head, Tags, error) {
+	ogg := newOggReader(r)
+	head, tags, err := readStreamHeaders(ogg)
+	if err != nil {
+		return nil, Head{}, Tags{}, err
+	}
+	return ogg, head, tags, nil
+}
+
+// readStreamHeaders reads the OpusHead and OpusTags packets that start
+// every logical stream
+func readStreamHeaders(ogg *oggReader) (Head, Tags, error) {
+	p, err := ogg.nextPacket()
+	if err == io.EOF {
+		return Head{}, Tags{}, ErrNotOggOpus
+	}
+	if err != nil {
+		return Head{}, Tags{}, err
+	}
+	head, err := parseHead(p)
+	if err != nil {
+		return Head{}, Tags{}, err
+	}
+
+	p, err = ogg.nextPacket()
+	if err == io.EOF {
+		return Head{}, Tags{}, ErrInvalidTags
+	}
+	if err != nil {
+		return Head{}, Tags{}, err
+	}
+	tags, err := parseTags(p)
+	if err != nil {
+		return Head{}, Tags{}, err
+	}
+
+	return head, tags, nil
+}
+
+func (d Decoder) Decode(r io.Reader) (audio.Source, error) {
+	if d.NewPacketDecoder == nil {
+		return nil, ErrNoPacketDecoder
+	}
+
+	ogg, head, tags, err := readHeaders(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := d.NewPacketDecoder(head)
+	if err != nil {
+		return nil, fmt.Errorf("creating packet decoder: %w", err)
+	}
+
+	_, order := audio.VorbisLayout(head.Channels)
+	return &source{
+		ogg:    ogg,
+		newDec: d.NewPacketDecoder,
+		dec:    dec,
+		head:   head,
+		tags:   tags,
+		gain:   head.gain(),
+		order:  order,
+		skip:   head.PreSkip,
+		pcm:    make([]float32, maxPacketSamples*head.Channels),
+		out:    make([]float32, 0, maxPacketSamples*head.Channels),
+		valid:  -1,
+	}, nil
+}
+
+// DecodeHeader reads the OpusHead and OpusTags headers of the stream in r,
+// without a PacketDecoder, so uploads can be checked where none is
+// configured. When r is an io.ReadSeeker, the length comes from the
+// granule position of the last page, read from the end of r; it is -1
+// otherwise, and for chained streams. It implements audio.HeaderDecoder.
+func (Decoder) DecodeHeader(r io.Reader) (audio.Header, error) {
+	ogg, head, tags, err := readHeaders(r)
+	if err != nil {
+		return audio.Header{}, err
+	}
+
+	layout, _ := audio.VorbisLayout(head.Channels)
+	h := audio.Header{
+		Format: audio.Format{
+			Rate:       SampleRate,
+			Channels:   head.Channels,
+			Layout:     layout,
+			SampleKind: audio.SampleFloat32,
+		},
+		Frames: -1,
+		Tags:   tags.metadata(),
+	}
+	if rs, ok := r.(io.ReadSeeker); ok {
+		granule, found, err := lastGranule(rs, ogg.serial)
+		if err != nil {
+			return audio.Header{}, err
+		}
+		if found {
+			h.Frames = max(granule-int64(head.PreSkip), 0)
+		}
+	}
+	return h, nil
+}
+
+// Capabilities reports what the package reads: up to 8 channels, always
+// decoded at 48 kHz, and only with NewPacketDecoder set. It does not
+// encode.
+func (d Decoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{
+		Name:        "Opus",
+		Decode:      d.NewPacketDecoder != nil,
+		MaxChannels: 8,
+		Rates:       []int{SampleRate},
+	}
+}
+
+type source struct {
+	ogg    *oggReader
+	newDec func(head Head) (PacketDecoder, error)
+	dec    PacketDecoder
+	head   Head
+	tags   Tags
+	gain   float32
+	order  []int // from the Vorbis channel order to the layout, nil when the same
+
+	skip    int   // pre-skip samples per channel still to drop
+	decoded int64 // samples per channel decoded so far, pre-skip included
+	valid   int64 // granule of the end of stream, -1 until known
+
+	pcm    []float32
+	out    []float32
+	outPos int
+	eof    bool
+}
+
+func (s *source) SampleRate() int { return SampleRate }
+func (s *source) Channels() int   { return s.head.Channels }
+func (s *source) BufSize() int    { return cap(s.out) }
+func (s *source) Close() error    { return nil }
+
+// Head returns the OpusHead of the current link of the stream.
+func (s *source) Head() Head { return s.head }
+
+// Tags returns the OpusTags of the current link of the stream.
+func (s *source) Tags() Tags { return s.tags }
+
+// Format reports the layout of the Vorbis channel order that mapping
+// families 0 and 1 use; the samples are reordered to follow it.
+func (s *source) Format() audio.Format {
+	layout, _ := audio.VorbisLayout(s.head.Channels)
+	return audio.Format{
+		Rate:       SampleRate,
+		Channels:   s.head.Channels,
+		Layout:     layout,
+		SampleKind: audio.SampleFloat32,
+	}
+}
+
+func (s *source) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%s.head.Channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	written := 0
+	for written < len(dst) {
+		if s.outPos == len(s.out) {
+			if s.eof {
+				return written, io.EOF
+			}
+			if err := s.decodePacket(); err != nil {
+				return written, err
+			}
+			continue
+		}
+
+		n := copy(dst[written:], s.out[s.outPos:])
+		s.outPos += n
+		written += n
+	}
+
+	return written, nil
+}
+
+// decodePacket decodes the next packet into s.out, dropping pre-skip and
+// anything past the final granule position
+func (s *source) decodePacket() error {
+	s.out = s.out[:0]
+	s.outPos = 0
+
+	packet, err := s.ogg.nextPacket()
+	if err == io.EOF {
+		return s.nextLink()
+	}
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	toc, err := ParseTOC(packet)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.dec.Decode(packet, s.pcm)
+	if err != nil {
+		return fmt.Errorf("decoding packet: %w", err)
+	}
+	if n < 0 || n > maxPacketSamples || n != toc.Duration() {
+		return fmt.Errorf("%w: %d samples for a %d sample packet", ErrPacketDecoderOutput, n, toc.Duration())
+	}
+
+	// The granule of the last page is the exact length of the stream
+	if g := s.ogg.pageGranule(); g >= 0 && s.ogg.eos {
+		s.valid = g
+	}
+
+	start := 0
+	if s.skip > 0 {
+		start = min(s.skip, n)
+		s.skip -= start
+	}
+	end := n
+	if s.valid >= 0 {
+		end = int(max(min(int64(n), s.valid-s.decoded), int64(start)))
+	}
+	s.decoded += int64(n)
+
+	ch := s.head.Channels
+	samples := s.pcm[start*ch : end*ch]
+	if s.gain != 1 {
+		for _, v := range samples {
+			s.out = append(s.out, v*s.gain)
+		}
+	} else {
+		s.out = append(s.out, samples...)
+	}
+	if s.order != nil {
+		audio.ReorderChannels(s.out, s.order)
+	}
+
+	return nil
+}
+
+// nextLink starts decoding the next stream of a chained file, as written by
+// Icecast sources and "cat a.opus b.opus", with a fresh PacketDecoder. It
+// returns an *audio.FormatChangedError when the channel count changes.
+func (s *source) nextLink() error {
+	s.ogg.nextStream()
+	head, tags, err := readStreamHeaders(s.ogg)
+	if errors.Is(err, ErrNotOggOpus) {
+		// End of file, or a link in another codec
+		s.eof = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("chained stream: %w", err)
+	}
+
+	dec, err := s.newDec(head)
+	if err != nil {
+		return fmt.Errorf("creating packet decoder: %w", err)
+	}
+
+	prev := s.Format()
+	s.dec, s.head, s.tags = dec, head, tags
+	s.gain = head.gain()
+	_, s.order = audio.VorbisLayout(head.Channels)
+	s.skip = head.PreSkip
+	s.decoded = 0
+	s.valid = -1
+	if size := maxPacketSamples * head.Channels; len(s.pcm) < size {
+		s.pcm = make([]float32, size)
+		s.out = make([]float32, 0, size)
+	}
+
+	return audio.CheckFormatChange(prev, s.Format())
+}
@@ -0,0 +1,466 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// oggPageBytes renders one Ogg page holding whole packets
+func oggPageBytes(headerType byte, granule int64, serial, seq uint32, packets ...[]byte) []byte {
+	var segments, body []byte
+	for _, p := range packets {
+		n := len(p)
+		for n >= 255 {
+			segments = append(segments, 255)
+			n -= 255
+		}
+		segments = append(segments, byte(n))
+		body = append(body, p...)
+	}
+
+	h := make([]byte, oggHeaderSize, oggHeaderSize+len(segments)+len(body))
+	copy(h, "OggS")
+	h[5] = headerType
+	binary.LittleEndian.PutUint64(h[6:14], uint64(granule))
+	binary.LittleEndian.PutUint32(h[14:18], serial)
+	binary.LittleEndian.PutUint32(h[18:22], seq)
+	h[26] = byte(len(segments))
+	h = append(h, segments...)
+	h = append(h, body...)
+
+	binary.LittleEndian.PutUint32(h[22:26], oggCRC(0, h))
+	return h
+}
+
+func opusHead(channels, preSkip int, gainQ8 int16) []byte {
+	p := make([]byte, 19)
+	copy(p, "OpusHead")
+	p[8] = 1
+	p[9] = byte(channels)
+	binary.LittleEndian.PutUint16(p[10:12], uint16(preSkip))
+	binary.LittleEndian.PutUint32(p[12:16], 8000)
+	binary.LittleEndian.PutUint16(p[16:18], uint16(gainQ8))
+	return p
+}
+
+func opusTags(vendor string, comments ...string) []byte {
+	p := []byte("OpusTags")
+	p = binary.LittleEndian.AppendUint32(p, uint32(len(vendor)))
+	p = append(p, vendor...)
+	p = binary.LittleEndian.AppendUint32(p, uint32(len(comments)))
+	for _, c := range comments {
+		p = binary.LittleEndian.AppendUint32(p, uint32(len(c)))
+		p = append(p, c...)
+	}
+	return p
+}
+
+// testStream builds an Ogg Opus stream with 20 ms SILK packets whose first
+// payload byte is the packet number, two packets per page
+func testStream(channels, preSkip, packets int, finalGranule int64, gainQ8 int16) []byte {
+	var out []byte
+	out = append(out, oggPageBytes(oggBOS, 0, 7, 0, opusHead(channels, preSkip, gainQ8))...)
+	out = append(out, oggPageBytes(0, 0, 7, 1, opusTags("test", "TITLE=call"))...)
+
+	seq := uint32(2)
+	granule := int64(0)
+	for i := 0; i < packets; i += 2 {
+		var page [][]byte
+		for j := i; j < min(i+2, packets); j++ {
+			page = append(page, []byte{1 << 3, byte(j)})
+			granule += 960
+		}
+		headerType := byte(0)
+		g := granule
+		if i+2 >= packets {
+			headerType = oggEOS
+			g = finalGranule
+		}
+		out = append(out, oggPageBytes(headerType, g, 7, seq, page...)...)
+		seq++
+	}
+
+	return out
+}
+
+// fakePacketDecoder outputs the packet number divided by 100 on every sample
+type fakePacketDecoder struct {
+	channels int
+}
+
+func (f fakePacketDecoder) Decode(packet []byte, pcm []float32) (int, error) {
+	toc, err := ParseTOC(packet)
+	if err != nil {
+		return 0, err
+	}
+	n := toc.Duration()
+	for i := range n * f.channels {
+		pcm[i] = float32(packet[1]) / 100
+	}
+	return n, nil
+}
+
+func testDecoder() Decoder {
+	return Decoder{NewPacketDecoder: func(h Head) (PacketDecoder, error) {
+		return fakePacketDecoder{channels: h.Channels}, nil
+	}}
+}
+
+func readAllSamples(t *testing.T, r io.Reader, d Decoder) []float32 {
+	t.Helper()
+
+	src, err := d.Decode(r)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var out []float32
+	buf := make([]float32, 1000*src.Channels())
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		channels  int
+		preSkip   int
+		packets   int
+		granule   int64
+		gainQ8    int16
+		wantFrame int
+		first     float32
+	}{
+		{name: "no trimming", channels: 1, packets: 4, granule: 4 * 960, wantFrame: 4 * 960},
+		{name: "pre-skip", channels: 1, preSkip: 312, packets: 4, granule: 4 * 960, wantFrame: 4*960 - 312},
+		{name: "pre-skip over a whole packet", channels: 2, preSkip: 1000, packets: 3, granule: 3 * 960, wantFrame: 3*960 - 1000, first: 0.01},
+		{name: "end trimming", channels: 2, preSkip: 312, packets: 5, granule: 4*960 + 100, wantFrame: 4*960 + 100 - 312},
+		{name: "output gain", channels: 1, packets: 2, granule: 2 * 960, gainQ8: 6 * 256, wantFrame: 2 * 960},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := testStream(tt.channels, tt.preSkip, tt.packets, tt.granule, tt.gainQ8)
+			samples := readAllSamples(t, bytes.NewReader(data), testDecoder())
+
+			if got := len(samples) / tt.channels; got != tt.wantFrame {
+				t.Errorf("decoded %d frames, want %d", got, tt.wantFrame)
+			}
+			if samples[0] != tt.first {
+				t.Errorf("first sample = %v, want %v", samples[0], tt.first)
+			}
+
+			if tt.gainQ8 != 0 {
+				want := float32(0.01 * math.Pow(10, float64(tt.gainQ8)/256/20))
+				last := samples[len(samples)-1]
+				if math.Abs(float64(last-want)) > 1e-6 {
+					t.Errorf("last sample = %v, want %v", last, want)
+				}
+			}
+		})
+	}
+}
+
+// spreadPacketDecoder outputs 0.5 on channel i%channels of frame i alone
+type spreadPacketDecoder struct {
+	channels int
+}
+
+func (f spreadPacketDecoder) Decode(packet []byte, pcm []float32) (int, error) {
+	toc, err := ParseTOC(packet)
+	if err != nil {
+		return 0, err
+	}
+	n := toc.Duration()
+	clear(pcm[:n*f.channels])
+	for i := range n {
+		pcm[i*f.channels+i%f.channels] = 0.5
+	}
+	return n, nil
+}
+
+func TestDecoder_Downmix5_1(t *testing.T) {
+	t.Parallel()
+
+	// Mapping family 1, 5.1 in the Vorbis order: FL C FR BL BR LFE
+	head := append(opusHead(6, 0, 0), 4, 2, 0, 4, 1, 2, 3, 5)
+	head[18] = 1
+	data := slices.Concat(
+		oggPageBytes(oggBOS, 0, 7, 0, head),
+		oggPageBytes(0, 0, 7, 1, opusTags("test")),
+		oggPageBytes(oggEOS, 960, 7, 2, []byte{1 << 3, 0}),
+	)
+	d := Decoder{NewPacketDecoder: func(h Head) (PacketDecoder, error) {
+		return spreadPacketDecoder{channels: h.Channels}, nil
+	}}
+
+	src, err := d.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got := audio.FormatOf(src).Layout; got != audio.Layout5_1 {
+		t.Errorf("Layout = %v, want 5.1", got)
+	}
+
+	got := make([]float32, 6)
+	if n, err := audio.NewMonoMixer(src).ReadSamples(got); n != 6 || err != nil {
+		t.Fatalf("ReadSamples() = %d, %v", n, err)
+	}
+
+	// Fronts at unity, surrounds at -3 dB and no LFE
+	front := 0.5 / (3 + math.Sqrt2)
+	back := front / math.Sqrt2
+	want := []float64{front, front, front, back, back, 0}
+	for i := range want {
+		if math.Abs(float64(got[i])-want[i]) > 1e-6 {
+			t.Errorf("mono sample of channel %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecoder_DecodeHeader(t *testing.T) {
+	t.Parallel()
+
+	// 80 ms, trimmed at both ends
+	data := testStream(2, 312, 5, 4*960+100, 0)
+	decoded := len(readAllSamples(t, bytes.NewReader(data), testDecoder())) / 2
+
+	tests := []struct {
+		name     string
+		seekable bool
+		frames   int64
+	}{
+		{name: "seekable", seekable: true, frames: int64(decoded)},
+		{name: "stream", frames: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r io.Reader = bytes.NewReader(data)
+			if !tt.seekable {
+				r = io.MultiReader(r)
+			}
+			// No packet decoder is needed
+			h, err := Decoder{}.DecodeHeader(r)
+			if err != nil {
+				t.Fatalf("DecodeHeader() error = %v", err)
+			}
+			if h.Format.Rate != SampleRate || h.Format.Channels != 2 {
+				t.Errorf("Format = %v, want 48 kHz stereo", h.Format)
+			}
+			if h.Frames != tt.frames {
+				t.Errorf("Frames = %d, want %d", h.Frames, tt.frames)
+			}
+			if h.Tags.Title != "call" || h.Tags.Comments["TITLE"][0] != "call" {
+				t.Errorf("Tags = %+v, want the title call", h.Tags)
+			}
+		})
+	}
+
+	if _, err := (Decoder{}).DecodeHeader(bytes.NewReader([]byte("not ogg at all"))); err == nil {
+		t.Error("DecodeHeader(not Ogg) error = nil")
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	t.Parallel()
+
+	valid := testStream(1, 0, 2, 1920, 0)
+	corrupt := bytes.Clone(valid)
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	tests := []struct {
+		name    string
+		decoder Decoder
+		data    []byte
+		wantErr error
+	}{
+		{name: "no packet decoder", decoder: Decoder{}, data: valid, wantErr: ErrNoPacketDecoder},
+		{name: "not ogg", decoder: testDecoder(), data: []byte("RIFF....WAVEfmt "), wantErr: ErrCorruptPage},
+		{name: "empty", decoder: testDecoder(), data: nil, wantErr: ErrNotOggOpus},
+		{name: "vorbis stream", decoder: testDecoder(), data: oggPageBytes(oggBOS, 0, 1, 0, []byte("\x01vorbis\x00\x00\x00\x00\x01")), wantErr: ErrNotOggOpus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tt.decoder.Decode(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("checksum", func(t *testing.T) {
+		t.Parallel()
+
+		src, err := testDecoder().Decode(bytes.NewReader(corrupt))
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		_, err = src.ReadSamples(make([]float32, 4096))
+		if !errors.Is(err, ErrCorruptPage) {
+			t.Errorf("ReadSamples() error = %v, want %v", err, ErrCorruptPage)
+		}
+	})
+}
+
+func TestDecoder_Chained(t *testing.T) {
+	t.Parallel()
+
+	// Pages of a multiplexed stream that outlives the Opus one
+	theora := oggPageBytes(0, 0, 9, 3, []byte("theora tail"))
+
+	tests := []struct {
+		name string
+		data []byte
+		// frames holds the frame count of each run of samples between
+		// format changes, and channels their channel counts
+		frames   []int
+		channels []int
+	}{
+		{
+			name:     "same format joins",
+			data:     slices.Concat(testStream(1, 0, 2, 1920, 0), testStream(1, 312, 2, 1920, 0)),
+			frames:   []int{1920 + 1920 - 312},
+			channels: []int{1},
+		},
+		{
+			name:     "channel change",
+			data:     slices.Concat(testStream(1, 0, 2, 1920, 0), testStream(2, 0, 3, 2880, 0), testStream(1, 0, 1, 960, 0)),
+			frames:   []int{1920, 2880, 960},
+			channels: []int{1, 2, 1},
+		},
+		{
+			name:     "multiplexed tail",
+			data:     slices.Concat(testStream(1, 0, 2, 1920, 0), theora),
+			frames:   []int{1920},
+			channels: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := testDecoder().Decode(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			frames, channels := []int{0}, []int{src.Channels()}
+			buf := make([]float32, 1000)
+			for {
+				ch := src.Channels()
+				n, err := src.ReadSamples(buf[:len(buf)-len(buf)%ch])
+				frames[len(frames)-1] += n / ch
+
+				var fc *audio.FormatChangedError
+				if errors.As(err, &fc) {
+					if fc.From.Channels != ch || fc.To.Channels != src.Channels() {
+						t.Errorf("FormatChangedError = %v, want %d to %d channels", fc, ch, src.Channels())
+					}
+					frames, channels = append(frames, 0), append(channels, src.Channels())
+					continue
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+
+			if !slices.Equal(frames, tt.frames) || !slices.Equal(channels, tt.channels) {
+				t.Errorf("runs = %v frames of %v channels, want %v of %v", frames, channels, tt.frames, tt.channels)
+			}
+		})
+	}
+}
+
+func TestDecoder_Capabilities(t *testing.T) {
+	t.Parallel()
+
+	if (Decoder{}).Capabilities().Decode {
+		t.Error("Capabilities().Decode = true without a packet decoder")
+	}
+	if !testDecoder().Capabilities().Decode {
+		t.Error("Capabilities().Decode = false with a packet decoder")
+	}
+}
+
+func TestReadHeaders(t *testing.T) {
+	t.Parallel()
+
+	head, tags, err := ReadHeaders(bytes.NewReader(testStream(2, 312, 2, 1920, -256)))
+	if err != nil {
+		t.Fatalf("ReadHeaders() error = %v", err)
+	}
+
+	if head.Channels != 2 || head.PreSkip != 312 || head.InputRate != 8000 || head.OutputGain != -1 {
+		t.Errorf("head = %+v", head)
+	}
+	if head.Streams != 1 || head.CoupledStreams != 1 {
+		t.Errorf("streams = %d/%d, want 1/1", head.Streams, head.CoupledStreams)
+	}
+	if tags.Vendor != "test" || tags.Get("title") != "call" {
+		t.Errorf("tags = %+v", tags)
+	}
+}
+
+func TestParseTOC(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		packet  []byte
+		want    TOC
+		wantErr error
+	}{
+		{name: "SILK NB 20 ms", packet: []byte{1 << 3}, want: TOC{Mode: ModeSILK, Bandwidth: 4000, FrameSize: 960, Frames: 1}},
+		{name: "SILK WB 60 ms stereo", packet: []byte{11<<3 | 0x04}, want: TOC{Mode: ModeSILK, Bandwidth: 8000, FrameSize: 2880, Frames: 1, Stereo: true}},
+		{name: "hybrid FB two frames", packet: []byte{15<<3 | 1}, want: TOC{Mode: ModeHybrid, Bandwidth: 20000, FrameSize: 960, Frames: 2}},
+		{name: "CELT FB 2.5 ms x 10", packet: []byte{28<<3 | 3, 10}, want: TOC{Mode: ModeCELT, Bandwidth: 20000, FrameSize: 120, Frames: 10}},
+		{name: "empty", packet: nil, wantErr: ErrInvalidPacket},
+		{name: "code 3 without count", packet: []byte{3}, wantErr: ErrInvalidPacket},
+		{name: "longer than 120 ms", packet: []byte{3<<3 | 3, 3}, wantErr: ErrInvalidPacket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseTOC(tt.packet)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseTOC() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTOC() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package opus reads the Ogg Opus container (RFC 7845). It does not
+// include an Opus codec: it decodes audio only with a PacketDecoder the
+// caller supplies, and on its own it reads headers, tags and lengths.
+//
+// Opus is the codec of WebRTC and of most modern VoIP trunks, and it is
+// what browsers record with MediaRecorder (.opus / .ogg files).
+//
+// # Container and Codec
+//
+// The package fully handles the Ogg Opus container: page reading with CRC
+// checks, packet reassembly, the OpusHead and OpusTags headers, pre-skip,
+// output gain and end trimming by granule position. Output is always at
+// 48 kHz, the rate Opus decodes at regardless of the input rate it was
+// encoded from.
+//
+// Decoding the Opus packets themselves (SILK, CELT and hybrid modes) needs
+// a codec implementation, which is plugged in through PacketDecoder. There
+// is no complete pure Go Opus codec, so the usual choice is a thin wrapper
+// around libopus via cgo (for example gopkg.in/hraban/opus.v2), kept out of
+// this module so it stays free of cgo:
+//
+//	decoder := opus.Decoder{
+//	    NewPacketDecoder: func(head opus.Head) (opus.PacketDecoder, error) {
+//	        return newLibopusDecoder(head.Channels) // decodes at 48 kHz
+//	    },
+//	}
+//	file, _ := os.Open("call.opus")
+//	source, err := decoder.Decode(file)
+//
+// Without a PacketDecoder, Decode returns ErrNoPacketDecoder. ReadHeaders
+// works without one and is enough to probe a file's channels, original
+// rate and tags. So does DecodeHeader, which also reads the length of a
+// seekable file from its last page, as audio.DecodeHeader reports it.
+//
+// # Output Format
+//
+// Opus decoder output:
+//   - Sample format: float32 in range [-1.0, 1.0]
+//   - Channels: as declared in OpusHead (1 or 2 for mapping family 0)
+//   - Sample rate: always 48 kHz
+//
+// Mapping family 1 orders channels as Vorbis does, 5.1 as FL C FR BL BR
+// LFE. The source interleaves them in the order of the audio.Layout it
+// reports instead, FL FR C LFE BL BR for 5.1, so MonoMixer and RemixTo
+// weight each speaker right.
+//
+// Use audio.NewResampler to reach telephony rates:
+//
+//	pcm16, rate, _ := audpbx.ResampleToMono16(source, 8000, 4096)
+//
+// # Limitations
+//
+// Only the first logical stream of a multiplexed Ogg file is decoded, and
+// only channel mapping families 0 and 1 are accepted.
+//
+// # Chained Streams
+//
+// The links of a chained file, such as an Icecast recording, are decoded
+// one after the other, each with a new PacketDecoder; Head and Tags report
+// the current link. When the channel count changes, ReadSamples returns an
+// *audio.FormatChangedError and continues with the new layout. Decoding
+// stops at the first link that is not Opus.
+package opus
@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package opus
+
+import "errors"
+
+var (
+	ErrNotOggOpus          = errors.New("not an Ogg Opus stream")
+	ErrNoPacketDecoder     = errors.New("no Opus packet decoder configured")
+	ErrInvalidHead         = errors.New("invalid OpusHead header")
+	ErrInvalidTags         = errors.New("invalid OpusTags header")
+	ErrUnsupportedMapping  = errors.New("unsupported Opus channel mapping family")
+	ErrCorruptPage         = errors.New("corrupt Ogg page")
+	ErrInvalidPacket       = errors.New("invalid Opus packet")
+	ErrPacketDecoderOutput = errors.New("packet decoder returned a wrong number of samples")
+)
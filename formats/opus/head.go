@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package opus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// SampleRate is the rate Opus always decodes at.
+const SampleRate = 48000
+
+// Head is the OpusHead identification header.
+type Head struct {
+	Version  int
+	Channels int
+	// PreSkip is the number of 48 kHz samples (per channel) to discard from
+	// the start of the decoded output.
+	PreSkip int
+	// InputRate is the rate of the audio before encoding, for information
+	// only; 0 when unknown.
+	InputRate int
+	// OutputGain is the gain to apply to the decoded output, in dB.
+	OutputGain float64
+	// MappingFamily is the channel mapping family, 0 or 1.
+	MappingFamily int
+	// Streams and CoupledStreams describe the multistream layout; for
+	// family 0 they are 1 and Channels-1.
+	Streams        int
+	CoupledStreams int
+	// Mapping maps output channels to decoded streams.
+	Mapping []byte
+}
+
+// Tags is the OpusTags comment header.
+type Tags struct {
+	Vendor   string
+	Comments []string
+}
+
+// Get returns the value of the first comment named key (case insensitive),
+// for example Get("TITLE").
+func (t Tags) Get(key string) string {
+	for _, c := range t.Comments {
+		name, value, ok := strings.Cut(c, "=")
+		if ok && strings.EqualFold(name, key) {
+			return value
+		}
+	}
+	return ""
+}
+
+// metadata returns the comments as audio.Tags, by upper-case name, with
+// the title, artist and album
+func (t Tags) metadata() audio.Tags {
+	var tags audio.Tags
+	for _, c := range t.Comments {
+		name, value, ok := strings.Cut(c, "=")
+		if !ok || name == "" {
+			continue
+		}
+		if tags.Comments == nil {
+			tags.Comments = make(map[string][]string)
+		}
+		name = strings.ToUpper(name)
+		tags.Comments[name] = append(tags.Comments[name], value)
+	}
+
+	field := func(name string) string { return strings.Join(tags.Comments[name], "/") }
+	tags.Title, tags.Artist, tags.Album = field("TITLE"), field("ARTIST"), field("ALBUM")
+	return tags
+}
+
+// parseHead parses an OpusHead packet
+func parseHead(p []byte) (Head, error) {
+	if len(p) < 19 || string(p[0:8]) != "OpusHead" {
+		return Head{}, ErrNotOggOpus
+	}
+
+	h := Head{
+		Version:       int(p[8]),
+		Channels:      int(p[9]),
+		PreSkip:       int(binary.LittleEndian.Uint16(p[10:12])),
+		InputRate:     int(binary.LittleEndian.Uint32(p[12:16])),
+		OutputGain:    float64(int16(binary.LittleEndian.Uint16(p[16:18]))) / 256,
+		MappingFamily: int(p[18]),
+	}
+
+	// Only the major version (upper nibble) breaks compatibility
+	if h.Version>>4 != 0 {
+		return Head{}, fmt.Errorf("%w: version %d", ErrInvalidHead, h.Version)
+	}
+	if h.Channels == 0 {
+		return Head{}, fmt.Errorf("%w: zero channels", ErrInvalidHead)
+	}
+
+	switch h.MappingFamily {
+	case 0:
+		if h.Channels > 2 {
+			return Head{}, fmt.Errorf("%w: %d channels in family 0", ErrInvalidHead, h.Channels)
+		}
+		h.Streams = 1
+		h.CoupledStreams = h.Channels - 1
+		h.Mapping = []byte{0, 1}[:h.Channels]
+	case 1:
+		if h.Channels > 8 || len(p) < 21+h.Channels {
+			return Head{}, fmt.Errorf("%w: bad mapping table", ErrInvalidHead)
+		}
+		h.Streams = int(p[19])
+		h.CoupledStreams = int(p[20])
+		h.Mapping = append([]byte(nil), p[21:21+h.Channels]...)
+		if h.Streams == 0 || h.CoupledStreams > h.Streams {
+			return Head{}, fmt.Errorf("%w: %d streams, %d coupled", ErrInvalidHead, h.Streams, h.CoupledStreams)
+		}
+	default:
+		return Head{}, fmt.Errorf("%w: %d", ErrUnsupportedMapping, h.MappingFamily)
+	}
+
+	return h, nil
+}
+
+// gain returns the linear factor for OutputGain
+func (h Head) gain() float32 {
+	return float32(math.Pow(10, h.OutputGain/20))
+}
+
+// parseTags parses an OpusTags packet
+func parseTags(p []byte) (Tags, error) {
+	if len(p) < 16 || string(p[0:8]) != "OpusTags" {
+		return Tags{}, ErrInvalidTags
+	}
+	p = p[8:]
+
+	readString := func() (string, bool) {
+		if len(p) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(p)
+		p = p[4:]
+		if uint64(n) > uint64(len(p)) {
+			return "", false
+		}
+		s := string(p[:n])
+		p = p[n:]
+		return s, true
+	}
+
+	vendor, ok := readString()
+	if !ok || len(p) < 4 {
+		return Tags{}, ErrInvalidTags
+	}
+	tags := Tags{Vendor: vendor}
+
+	count := binary.LittleEndian.Uint32(p)
+	p = p[4:]
+	for range count {
+		c, ok := readString()
+		if !ok {
+			return Tags{}, ErrInvalidTags
+		}
+		tags.Comments = append(tags.Comments, c)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package opus
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	oggHeaderSize = 27
+
+	oggContinued = 0x01
+	oggBOS       = 0x02
+	oggEOS       = 0x04
+)
+
+// oggPage is a parsed Ogg page header with its body
+type oggPage struct {
+	headerType byte
+	granule    int64
+	serial     uint32
+	sequence   uint32
+	segments   []byte // lacing values
+	body       []byte
+}
+
+// oggReader reassembles the packets of one logical Ogg stream
+type oggReader struct {
+	r      *bufio.Reader
+	serial uint32
+	locked bool // serial is known
+
+	// packets completed on the current page, not yet returned
+	pending [][]byte
+	partial []byte
+
+	// granule of the page the last returned packet ended on, -1 when the
+	// page did not end a packet
+	granule    int64
+	lastOnPage bool
+	eos        bool
+	chained    bool // looking for the next link of a chain
+
+	header [oggHeaderSize]byte
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{r: bufio.NewReader(r), granule: -1}
+}
+
+// readPage reads the next page of any stream
+func (o *oggReader) readPage() (*oggPage, error) {
+	if _, err := io.ReadFull(o.r, o.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: truncated header", ErrCorruptPage)
+		}
+		return nil, err
+	}
+
+	h := o.header[:]
+	if string(h[0:4]) != "OggS" || h[4] != 0 {
+		return nil, fmt.Errorf("%w: bad capture pattern", ErrCorruptPage)
+	}
+
+	page := &oggPage{
+		headerType: h[5],
+		granule:    int64(binary.LittleEndian.Uint64(h[6:14])),
+		serial:     binary.LittleEndian.Uint32(h[14:18]),
+		sequence:   binary.LittleEndian.Uint32(h[18:22]),
+	}
+	crc := binary.LittleEndian.Uint32(h[22:26])
+
+	page.segments = make([]byte, h[26])
+	if _, err := io.ReadFull(o.r, page.segments); err != nil {
+		return nil, fmt.Errorf("%w: truncated segment table", ErrCorruptPage)
+	}
+
+	size := 0
+	for _, l := range page.segments {
+		size += int(l)
+	}
+	page.body = make([]byte, size)
+	if _, err := io.ReadFull(o.r, page.body); err != nil {
+		return nil, fmt.Errorf("%w: truncated body", ErrCorruptPage)
+	}
+
+	// The CRC is computed with its own field zeroed
+	clear(h[22:26])
+	sum := oggCRC(0, h)
+	sum = oggCRC(sum, page.segments)
+	sum = oggCRC(sum, page.body)
+	if sum != crc {
+		return nil, fmt.Errorf("%w: checksum mismatch on page %d", ErrCorruptPage, page.sequence)
+	}
+
+	return page, nil
+}
+
+// nextPacket returns the next complete packet of the stream. It returns
+// io.EOF after the last packet.
+func (o *oggReader) nextPacket() ([]byte, error) {
+	for len(o.pending) == 0 {
+		if o.eos {
+			return nil, io.EOF
+		}
+
+		page, err := o.readPage()
+		if err == io.EOF {
+			if len(o.partial) > 0 {
+				return nil, fmt.Errorf("%w: stream ends inside a packet", ErrCorruptPage)
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !o.locked {
+			if page.headerType&oggBOS == 0 {
+				if o.chained {
+					continue // tail of another multiplexed stream
+				}
+				return nil, ErrNotOggOpus
+			}
+			o.serial = page.serial
+			o.locked = true
+		}
+		if page.serial != o.serial {
+			continue // another multiplexed stream
+		}
+
+		o.splitPage(page)
+	}
+
+	packet := o.pending[0]
+	o.pending = o.pending[1:]
+	o.lastOnPage = len(o.pending) == 0
+	return packet, nil
+}
+
+// nextStream prepares for the next link of a chained file after the end
+// of the current stream: nextPacket then returns the packets of the first
+// stream that begins after it, or io.EOF when none does.
+func (o *oggReader) nextStream() {
+	o.locked = false
+	o.chained = true
+	o.eos = false
+	o.pending = nil
+	o.partial = nil
+	o.granule = -1
+	o.lastOnPage = false
+}
+
+// splitPage cuts the page body into packets using its lacing values
+func (o *oggReader) splitPage(page *oggPage) {
+	if page.headerType&oggContinued == 0 {
+		o.partial = o.partial[:0]
+	}
+
+	body := page.body
+	completed := false
+	for _, l := range page.segments {
+		o.partial = append(o.partial, body[:l]...)
+		body = body[l:]
+		if l < 255 {
+			o.pending = append(o.pending, o.partial)
+			o.partial = nil
+			completed = true
+		}
+	}
+
+	o.granule = -1
+	if completed {
+		o.granule = page.granule
+	}
+	if page.headerType&oggEOS != 0 {
+		o.eos = true
+	}
+}
+
+// pageGranule returns the granule position that applies after the packet
+// last returned by nextPacket, or -1 when it is not the last packet of its
+// page.
+func (o *oggReader) pageGranule() int64 {
+	if !o.lastOnPage {
+		return -1
+	}
+	return o.granule
+}
+
+var oggCRCTable = func() [256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+// maxPageSize is the largest size of an Ogg page: a full header with 255
+// segments of 255 bytes
+const maxPageSize = oggHeaderSize + 255 + 255*255
+
+// lastGranule returns the granule position of the last page of stream
+// serial that ends a packet, looking for it in the last pages of rs
+func lastGranule(rs io.ReadSeeker, serial uint32) (int64, bool, error) {
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w", err)
+	}
+	start := max(end-2*maxPageSize, 0)
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return 0, false, fmt.Errorf("%w", err)
+	}
+	tail := make([]byte, end-start)
+	if _, err := io.ReadFull(rs, tail); err != nil {
+		return 0, false, fmt.Errorf("%w", err)
+	}
+
+	// The last capture pattern that starts a valid page of the stream;
+	// the checksum rules out patterns inside packets
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		page, err := newOggReader(bytes.NewReader(tail[i:])).readPage()
+		if err == nil && page.serial == serial && page.granule >= 0 {
+			return page.granule, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// oggCRC updates the Ogg checksum (CRC-32, polynomial 0x04c11db7, no
+// reflection, no final xor) with p
+func oggCRC(crc uint32, p []byte) uint32 {
+	for _, b := range p {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package opus
+
+import "fmt"
+
+// Mode is the coding mode of an Opus packet.
+type Mode int
+
+const (
+	ModeSILK Mode = iota
+	ModeHybrid
+	ModeCELT
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeSILK:
+		return "SILK"
+	case ModeHybrid:
+		return "hybrid"
+	default:
+		return "CELT"
+	}
+}
+
+// TOC describes an Opus packet as given by its table-of-contents byte
+// (RFC 6716 section 3.1).
+type TOC struct {
+	Mode Mode
+	// Bandwidth is the audio bandwidth in Hz (4000 for narrowband up to
+	// 20000 for fullband).
+	Bandwidth int
+	// FrameSize is the duration of each frame in 48 kHz samples.
+	FrameSize int
+	// Frames is the number of frames in the packet.
+	Frames int
+	Stereo bool
+}
+
+// Duration returns the packet duration in 48 kHz samples per channel.
+func (t TOC) Duration() int {
+	return t.FrameSize * t.Frames
+}
+
+// frame sizes in 48 kHz samples for the 32 TOC configurations
+var tocFrameSizes = [32]int{
+	480, 960, 1920, 2880, // SILK NB
+	480, 960, 1920, 2880, // SILK MB
+	480, 960, 1920, 2880, // SILK WB
+	480, 960, // hybrid SWB
+	480, 960, // hybrid FB
+	120, 240, 480, 960, // CELT NB
+	120, 240, 480, 960, // CELT WB
+	120, 240, 480, 960, // CELT SWB
+	120, 240, 480, 960, // CELT FB
+}
+
+// ParseTOC parses the TOC byte and frame count of packet.
+func ParseTOC(packet []byte) (TOC, error) {
+	if len(packet) == 0 {
+		return TOC{}, fmt.Errorf("%w: empty packet", ErrInvalidPacket)
+	}
+
+	toc := packet[0]
+	config := int(toc >> 3)
+	t := TOC{
+		FrameSize: tocFrameSizes[config],
+		Stereo:    toc&0x04 != 0,
+	}
+
+	switch {
+	case config < 12:
+		t.Mode = ModeSILK
+		t.Bandwidth = []int{4000, 6000, 8000}[config/4]
+	case config < 16:
+		t.Mode = ModeHybrid
+		t.Bandwidth = []int{12000, 20000}[(config-12)/2]
+	default:
+		t.Mode = ModeCELT
+		t.Bandwidth = []int{4000, 8000, 12000, 20000}[(config-16)/4]
+	}
+
+	switch toc & 0x03 {
+	case 0:
+		t.Frames = 1
+	case 1, 2:
+		t.Frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return TOC{}, fmt.Errorf("%w: missing frame count", ErrInvalidPacket)
+		}
+		t.Frames = int(packet[1] & 0x3f)
+		if t.Frames == 0 {
+			return TOC{}, fmt.Errorf("%w: zero frames", ErrInvalidPacket)
+		}
+	}
+
+	// A packet holds at most 120 ms of audio
+	if t.Duration() > 5760 {
+		return TOC{}, fmt.Errorf("%w: %d samples exceed 120 ms", ErrInvalidPacket, t.Duration())
+	}
+
+	return t, nil
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Decoder decodes headerless PCM of a fixed format, so raw files can be
+// registered in an audio.Registry like any other format:
+//
+//	reg.Register("sln", pcm.Decoder{Rate: 8000, Channels: 1, Encoding: pcm.S16LE})
+//	reg.Register("sln16", pcm.Decoder{Rate: 16000, Channels: 1, Encoding: pcm.S16LE})
+type Decoder struct {
+	Rate     int
+	Channels int
+	Encoding Encoding
+}
+
+// Decode returns a source reading r as NewSource does.
+func (d Decoder) Decode(r io.Reader) (audio.Source, error) {
+	return NewSource(r, d.Rate, d.Channels, d.Encoding)
+}
+
+// Capabilities reports the format d reads: its rate, channel count and
+// encoding alone, since raw PCM does not describe itself. The package does
+// not encode.
+func (d Decoder) Capabilities() audio.Capabilities {
+	c := audio.Capabilities{
+		Name:        "PCM " + d.Encoding.String(),
+		Decode:      true,
+		MaxChannels: d.Channels,
+	}
+	if d.Rate > 0 {
+		c.Rates = []int{d.Rate}
+	}
+	if d.Encoding != MuLaw && d.Encoding != ALaw {
+		c.BitDepths = []int{8 * d.Encoding.BytesPerSample()}
+	}
+	return c
+}
+
+// NewDecoder returns the Decoder of integer PCM with the given bit depth
+// and byte order: 8-bit samples are unsigned, 16-bit ones signed in either
+// order, and 24 and 32-bit ones signed little endian.
+func NewDecoder(rate, channels, bitDepth int, order binary.ByteOrder) (Decoder, error) {
+	format := audio.Format{Rate: rate, Channels: channels}
+	if err := format.Validate(); err != nil {
+		return Decoder{}, fmt.Errorf("%w", err)
+	}
+
+	little := order == binary.LittleEndian
+	var enc Encoding
+	switch {
+	case bitDepth == 8:
+		enc = U8
+	case bitDepth == 16 && little:
+		enc = S16LE
+	case bitDepth == 16 && order == binary.BigEndian:
+		enc = S16BE
+	case bitDepth == 24 && little:
+		enc = S24LE
+	case bitDepth == 32 && little:
+		enc = S32LE
+	default:
+		return Decoder{}, fmt.Errorf("%w: %d-bit %v", ErrUnknownEncoding, bitDepth, order)
+	}
+
+	return Decoder{Rate: rate, Channels: channels, Encoding: enc}, nil
+}
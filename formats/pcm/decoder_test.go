@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+func TestNewDecoder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		bitDepth int
+		order    binary.ByteOrder
+		want     Encoding
+		wantErr  error
+	}{
+		{"8-bit", 8, binary.LittleEndian, U8, nil},
+		{"16-bit little endian", 16, binary.LittleEndian, S16LE, nil},
+		{"16-bit big endian", 16, binary.BigEndian, S16BE, nil},
+		{"24-bit", 24, binary.LittleEndian, S24LE, nil},
+		{"32-bit", 32, binary.LittleEndian, S32LE, nil},
+		{"24-bit big endian", 24, binary.BigEndian, EncodingUnknown, ErrUnknownEncoding},
+		{"12-bit", 12, binary.LittleEndian, EncodingUnknown, ErrUnknownEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := NewDecoder(16000, 2, tt.bitDepth, tt.order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewDecoder() error = %v, want %v", err, tt.wantErr)
+			}
+			if dec.Encoding != tt.want {
+				t.Errorf("Encoding = %v, want %v", dec.Encoding, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewDecoder(0, 1, 16, binary.LittleEndian); !errors.Is(err, audio.ErrInvalidSampleRate) {
+		t.Errorf("NewDecoder(0 Hz) error = %v, want %v", err, audio.ErrInvalidSampleRate)
+	}
+}
+
+func TestDecoder_Registry(t *testing.T) {
+	t.Parallel()
+
+	reg := audio.NewRegistry()
+	reg.Register("sln16", Decoder{Rate: 16000, Channels: 1, Encoding: S16LE})
+
+	dec, ok := reg.Get("sln16")
+	if !ok {
+		t.Fatal("sln16 decoder not registered")
+	}
+	src, err := dec.Decode(bytes.NewReader([]byte{0x00, 0x40, 0x00, 0xc0}))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if f := audio.FormatOf(src); f.Rate != 16000 || f.Channels != 1 || f.SampleKind != audio.SampleInt16 {
+		t.Errorf("format = %+v, want 16 kHz mono int16", f)
+	}
+	if got := readAll(t, src, 8); len(got) != 2 || got[0] != 0.5 || got[1] != -0.5 {
+		t.Errorf("samples = %v, want [0.5 -0.5]", got)
+	}
+}
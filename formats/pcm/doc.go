@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package pcm reads headerless PCM streams, such as raw telephony captures,
+// RTP payloads dumped to disk or the output of text-to-speech engines.
+//
+// Raw PCM carries no header, so the rate, channel count and sample encoding
+// must be given by the caller:
+//
+//	src := pcm.NewSource(resp.Body, 8000, 1, pcm.MuLaw)
+//	pcm16, rate, _ := audpbx.ResampleToMono16(src, 16000, 4096)
+//
+// # Encodings
+//
+// Supported sample encodings:
+//   - S16LE, S16BE: signed 16-bit
+//   - S24LE, S32LE: signed 24 and 32-bit little endian
+//   - U8: unsigned 8-bit
+//   - F32LE: 32-bit IEEE float
+//   - MuLaw, ALaw: ITU-T G.711 companded 8-bit
+//
+// A partial frame at the end of the stream is dropped.
+//
+// # Registering Raw Formats
+//
+// Decoder fixes the format of a raw stream so it can be registered like a
+// file format, as for the signed linear files of Asterisk:
+//
+//	reg.Register("sln", pcm.Decoder{Rate: 8000, Channels: 1, Encoding: pcm.S16LE})
+//
+// Package formats/sln has them ready, with a writer, at every rate
+// Asterisk uses.
+//
+// NewDecoder picks the encoding from a bit depth and byte order instead:
+//
+//	dec, err := pcm.NewDecoder(48000, 2, 24, binary.LittleEndian)
+//	src, err := dec.Decode(os.Stdin)
+package pcm
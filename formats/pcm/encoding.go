@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcm
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/g711"
+)
+
+// Encoding is the byte representation of raw PCM samples.
+type Encoding int
+
+const (
+	EncodingUnknown Encoding = iota
+	S16LE
+	S16BE
+	U8
+	F32LE
+	MuLaw
+	ALaw
+	S24LE
+	S32LE
+)
+
+// String returns the common short name of the encoding.
+func (e Encoding) String() string {
+	switch e {
+	case S16LE:
+		return "s16le"
+	case S16BE:
+		return "s16be"
+	case U8:
+		return "u8"
+	case F32LE:
+		return "f32le"
+	case MuLaw:
+		return "mulaw"
+	case ALaw:
+		return "alaw"
+	case S24LE:
+		return "s24le"
+	case S32LE:
+		return "s32le"
+	default:
+		return "unknown"
+	}
+}
+
+// BytesPerSample returns the size of one sample, or 0 for unknown encodings.
+func (e Encoding) BytesPerSample() int {
+	switch e {
+	case S16LE, S16BE:
+		return 2
+	case U8, MuLaw, ALaw:
+		return 1
+	case S24LE:
+		return 3
+	case F32LE, S32LE:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// SampleKind returns the audio.SampleKind matching the encoding. G.711 is
+// reported as 16-bit, the precision it expands to.
+func (e Encoding) SampleKind() audio.SampleKind {
+	switch e {
+	case S16LE, S16BE, MuLaw, ALaw:
+		return audio.SampleInt16
+	case U8:
+		return audio.SampleUint8
+	case S24LE:
+		return audio.SampleInt24
+	case S32LE:
+		return audio.SampleInt32
+	case F32LE:
+		return audio.SampleFloat32
+	default:
+		return audio.SampleUnknown
+	}
+}
+
+// decode converts one sample
+func (e Encoding) decode(b []byte) float32 {
+	switch e {
+	case S16LE:
+		return float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+	case S16BE:
+		return float32(int16(binary.BigEndian.Uint16(b))) / 32768
+	case S24LE:
+		return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+	case S32LE:
+		return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	case U8:
+		return float32(int(b[0])-128) / 128
+	case F32LE:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	case MuLaw:
+		return float32(g711.DecodeMulaw(b[0])) / 32768
+	case ALaw:
+		return float32(g711.DecodeAlaw(b[0])) / 32768
+	default:
+		return 0
+	}
+}
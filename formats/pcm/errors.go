@@ -0,0 +1,10 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcm
+
+import "errors"
+
+var (
+	// ErrUnknownEncoding indicates an Encoding value this package does not know
+	ErrUnknownEncoding = errors.New("unknown PCM encoding")
+)
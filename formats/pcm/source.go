@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+type source struct {
+	r        io.Reader
+	rate     int
+	channels int
+	enc      Encoding
+	buf      []byte
+	pending  int // bytes of a partial sample kept at the start of buf
+	eof      bool
+}
+
+// NewSource reads raw PCM from r. If r is an io.Closer it is closed by the
+// source's Close.
+func NewSource(r io.Reader, rate, channels int, enc Encoding) (audio.Source, error) {
+	if enc.BytesPerSample() == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownEncoding, enc)
+	}
+
+	format := audio.Format{Rate: rate, Channels: channels}
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return &source{
+		r:        r,
+		rate:     rate,
+		channels: channels,
+		enc:      enc,
+		buf:      make([]byte, 4096*enc.BytesPerSample()),
+	}, nil
+}
+
+func (s *source) SampleRate() int { return s.rate }
+func (s *source) Channels() int   { return s.channels }
+func (s *source) BufSize() int    { return len(s.buf) / s.enc.BytesPerSample() }
+
+func (s *source) Format() audio.Format {
+	return audio.Format{
+		Rate:       s.rate,
+		Channels:   s.channels,
+		Layout:     audio.DefaultLayout(s.channels),
+		SampleKind: s.enc.SampleKind(),
+	}
+}
+
+func (s *source) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+	return nil
+}
+
+func (s *source) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%s.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+	if s.eof {
+		return 0, io.EOF
+	}
+	if len(dst) == 0 {
+		return 0, nil
+	}
+
+	size := s.enc.BytesPerSample()
+	frameBytes := size * s.channels
+	want := len(dst) * size
+	if cap(s.buf) < want {
+		buf := make([]byte, want)
+		copy(buf, s.buf[:s.pending])
+		s.buf = buf
+	}
+	s.buf = s.buf[:want]
+
+	// Read at least one whole frame unless the stream ends
+	have := s.pending
+	var err error
+	for have < frameBytes && err == nil {
+		var n int
+		n, err = s.r.Read(s.buf[have:want])
+		have += n
+	}
+	if errors.Is(err, io.EOF) {
+		s.eof = true
+		err = nil
+	} else if err != nil {
+		err = fmt.Errorf("%w", err)
+	}
+
+	frames := have / frameBytes
+	used := frames * frameBytes
+	for i := range frames * s.channels {
+		dst[i] = s.enc.decode(s.buf[i*size:])
+	}
+
+	// Keep the partial frame for the next call
+	s.pending = copy(s.buf, s.buf[used:have])
+
+	n := frames * s.channels
+	if s.eof {
+		return n, io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func readAll(t *testing.T, src audio.Source, chunk int) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, chunk)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+func TestSource_Encodings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		enc  Encoding
+		data []byte
+		want []float32
+	}{
+		{"s16le", S16LE, []byte{0x00, 0x40, 0x00, 0x80}, []float32{0.5, -1}},
+		{"s16be", S16BE, []byte{0x40, 0x00, 0x80, 0x00}, []float32{0.5, -1}},
+		{"s24le", S24LE, []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0x80}, []float32{0.5, -1}},
+		{"s32le", S32LE, []byte{0x00, 0x00, 0x00, 0x40, 0x00, 0x00, 0x00, 0x80}, []float32{0.5, -1}},
+		{"u8", U8, []byte{128, 0, 192}, []float32{0, -1, 0.5}},
+		{"f32le", F32LE, []byte{0, 0, 0, 0x3f, 0, 0, 0x80, 0xbf}, []float32{0.5, -1}},
+		{"mulaw", MuLaw, []byte{0xff, 0x7f, 0x00, 0x80}, []float32{0, 0, -32124.0 / 32768, 32124.0 / 32768}},
+		{"alaw", ALaw, []byte{0xd5, 0x55, 0xaa, 0x2a}, []float32{8.0 / 32768, -8.0 / 32768, 32256.0 / 32768, -32256.0 / 32768}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := NewSource(bytes.NewReader(tt.data), 8000, 1, tt.enc)
+			if err != nil {
+				t.Fatalf("NewSource() error = %v", err)
+			}
+
+			got := readAll(t, src, 16)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d samples, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("sample %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// Short reads from the underlying reader must not split samples or frames,
+// and a trailing partial frame is dropped.
+func TestSource_ShortReads(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 0, 4*100+3)
+	for i := range 100 {
+		l, r := int16(i*100), int16(-i*100)
+		data = append(data, byte(l), byte(l>>8), byte(r), byte(r>>8))
+	}
+	data = append(data, 1, 2, 3) // partial frame
+
+	r := audiotest.NewFaultyReader(iotest.OneByteReader(bytes.NewReader(data)), audiotest.WithShortReads(3))
+	src, err := NewSource(r, 8000, 2, S16LE)
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+
+	got := readAll(t, src, 14)
+	if len(got) != 200 {
+		t.Fatalf("got %d samples, want 200", len(got))
+	}
+	for i := range 100 {
+		if l := got[2*i] * 32768; l != float32(i*100) {
+			t.Fatalf("frame %d left = %v, want %d", i, l, i*100)
+		}
+		if r := got[2*i+1] * 32768; r != float32(-i*100) {
+			t.Fatalf("frame %d right = %v, want %d", i, r, -i*100)
+		}
+	}
+}
+
+func TestSource_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewSource(bytes.NewReader(nil), 8000, 1, EncodingUnknown); !errors.Is(err, ErrUnknownEncoding) {
+		t.Errorf("NewSource(unknown) error = %v, want %v", err, ErrUnknownEncoding)
+	}
+	if _, err := NewSource(bytes.NewReader(nil), 0, 1, S16LE); !errors.Is(err, audio.ErrInvalidSampleRate) {
+		t.Errorf("NewSource(0 Hz) error = %v, want %v", err, audio.ErrInvalidSampleRate)
+	}
+
+	r := audiotest.NewFaultyReader(bytes.NewReader(make([]byte, 100)), audiotest.WithErrorOnCall(2, audiotest.ErrInjected), audiotest.WithShortReads(10))
+	src, err := NewSource(r, 8000, 1, S16LE)
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	var readErr error
+	for readErr == nil {
+		_, readErr = src.ReadSamples(make([]float32, 4))
+	}
+	if !errors.Is(readErr, audiotest.ErrInjected) {
+		t.Errorf("ReadSamples() error = %v, want %v", readErr, audiotest.ErrInjected)
+	}
+}
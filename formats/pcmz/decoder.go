@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcmz
+
+import (
+	"bufio"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/pcm"
+)
+
+type Decoder struct{}
+
+// Decode returns a source of the samples of the pcmz file in r, streamed
+// through the decompressor. A file cut short ends with an error wrapping
+// io.ErrUnexpectedEOF after the samples before the cut. Files written
+// WithSilence play their silence markers as noise.
+func (Decoder) Decode(r io.Reader) (audio.Source, error) {
+	b := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNotPCMZ
+		}
+		return nil, fmt.Errorf("%w", err)
+	}
+	h, err := parseHeader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	zr := flate.NewReader(bufio.NewReader(r))
+	if h.silence {
+		src, err := newExpander(zr, h)
+		if err != nil {
+			return nil, err
+		}
+		return src, nil
+	}
+	src, err := pcm.NewSource(zr, h.rate, h.channels, h.enc)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return src, nil
+}
+
+// Capabilities reports what the package reads and writes: 16-bit integer
+// and 32-bit float samples.
+func (Decoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{
+		Name:            "pcmz",
+		Decode:          true,
+		Encode:          true,
+		BitDepths:       []int{16, 32},
+		EncodeBitDepths: []int{16, 32},
+		MaxChannels:     math.MaxUint16,
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package pcmz stores PCM compactly between the stages of a pipeline,
+// where a standard codec would be overkill: a 16-byte header giving the
+// format, followed by the samples as a DEFLATE stream.
+//
+// It is meant for intermediate files, such as a recording decoded once and
+// then analyzed by several jobs, or audio handed from one worker to the
+// next over a queue. Float samples are kept exactly, so a stage reads
+// what the one before wrote; 16-bit samples halve the size again.
+//
+//	w, err := pcmz.NewWriter(file, audio.FormatOf(src))
+//	_, err = w.WriteSource(src)
+//	err = w.Close()
+//
+//	src, err := pcmz.Decoder{}.Decode(file)
+//
+// Encode does the same in one call. Files are recognized by
+// audio.DetectFormat, as "pcmz".
+//
+// # Archival
+//
+// Long recordings of trunks are mostly idle. WithSilence stores each run
+// of silence as a marker of its length and noise level, and the Decoder
+// plays it back as noise at that level, so the line does not go dead:
+//
+//	err := pcmz.Encode(file, trunk, pcmz.WithSilence(-50, time.Second))
+//
+// # File Format
+//
+// The header, little endian:
+//
+//	offset size
+//	0      4    "PCMZ"
+//	4      1    version: 1, or 2 WithSilence
+//	5      1    compression, 1 for DEFLATE
+//	6      1    sample encoding: 1 for 32-bit float, 2 for signed 16-bit
+//	7      1    reserved, 0
+//	8      4    sample rate in Hz
+//	12     2    channel count
+//	14     2    reserved, 0
+//
+// The DEFLATE stream holds the interleaved samples, little endian. Its end
+// marks the end of the audio, so files can be written to pipes. Zstandard
+// would compress faster still, but is not in the standard library.
+//
+// In version 2 the stream is a sequence of records instead, each starting
+// with a type byte and a frame count:
+//
+//	size
+//	1    record type: 1 for audio, 2 for silence
+//	4    frame count
+//	...  audio: the interleaved samples; silence: the RMS level, 32-bit float
+//
+// # Error Handling
+//
+// The package defines:
+//   - ErrNotPCMZ: The input does not start with a pcmz header
+//   - ErrUnsupported: The header has an unknown version, compression or
+//     sample encoding, or the stream an unknown record type
+//   - ErrInvalidChannelCount: Samples do not form whole frames, or there
+//     are more channels than the header holds
+//   - ErrWriterClosed: The Writer was written to after Close
+package pcmz
@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcmz
+
+import "errors"
+
+var (
+	ErrNotPCMZ             = errors.New("not a pcmz file")
+	ErrUnsupported         = errors.New("unsupported pcmz file")
+	ErrInvalidChannelCount = errors.New("invalid channel count")
+	ErrWriterClosed        = errors.New("pcmz writer is closed")
+)
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcmz
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ik5/audpbx/formats/pcm"
+)
+
+const (
+	// Magic starts every pcmz file.
+	Magic = "PCMZ"
+
+	// HeaderSize is the size of the header before the compressed samples.
+	HeaderSize = 16
+
+	version         = 1
+	versionSilence  = 2 // the stream holds records, see silence.go
+	compressDeflate = 1
+)
+
+// Sample encodings as stored in the header
+var encodings = map[byte]pcm.Encoding{
+	1: pcm.F32LE,
+	2: pcm.S16LE,
+}
+
+// header is the format stored at the start of a file
+type header struct {
+	rate     int
+	channels int
+	enc      pcm.Encoding
+	silence  bool
+}
+
+func (h header) marshal() []byte {
+	b := make([]byte, HeaderSize)
+	copy(b, Magic)
+	b[4] = version
+	if h.silence {
+		b[4] = versionSilence
+	}
+	b[5] = compressDeflate
+	for code, enc := range encodings {
+		if enc == h.enc {
+			b[6] = code
+		}
+	}
+	binary.LittleEndian.PutUint32(b[8:], uint32(h.rate))
+	binary.LittleEndian.PutUint16(b[12:], uint16(h.channels))
+	return b
+}
+
+func parseHeader(b []byte) (header, error) {
+	if len(b) < HeaderSize || string(b[:4]) != Magic {
+		return header{}, ErrNotPCMZ
+	}
+	if b[4] != version && b[4] != versionSilence {
+		return header{}, fmt.Errorf("%w: version %d", ErrUnsupported, b[4])
+	}
+	if b[5] != compressDeflate {
+		return header{}, fmt.Errorf("%w: compression %d", ErrUnsupported, b[5])
+	}
+	enc, ok := encodings[b[6]]
+	if !ok {
+		return header{}, fmt.Errorf("%w: sample encoding %d", ErrUnsupported, b[6])
+	}
+	return header{
+		rate:     int(binary.LittleEndian.Uint32(b[8:])),
+		channels: int(binary.LittleEndian.Uint16(b[12:])),
+		enc:      enc,
+		silence:  b[4] == versionSilence,
+	}, nil
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcmz
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+	"github.com/ik5/audpbx/formats/pcm"
+)
+
+func readAll(t *testing.T, src audio.Source) ([]float32, error) {
+	t.Helper()
+
+	var got []float32
+	buf := make([]float32, 1000)
+	for {
+		n, err := src.ReadSamples(buf)
+		got = append(got, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return got, nil
+		}
+		if err != nil {
+			return got, err
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		opts     []WriterOption
+		kind     audio.SampleKind
+		maxError float64
+	}{
+		{name: "float", kind: audio.SampleFloat32},
+		{name: "16-bit", opts: []WriterOption{WithEncoding(pcm.S16LE)}, kind: audio.SampleInt16, maxError: 1.0 / 32767},
+		{name: "best compression", opts: []WriterOption{WithLevel(9)}, kind: audio.SampleFloat32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			want, err := readAll(t, audiotest.NewSineSource(16000, 2, 16000, 440))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var file bytes.Buffer
+			if err := Encode(&file, audiotest.NewSineSource(16000, 2, 16000, 440), tt.opts...); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if keys := audio.DetectFormat(file.Bytes()); len(keys) == 0 || keys[0] != "pcmz" {
+				t.Errorf("DetectFormat() = %v, want pcmz", keys)
+			}
+
+			src, err := Decoder{}.Decode(&file)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			f := audio.FormatOf(src)
+			if f.Rate != 16000 || f.Channels != 2 || f.SampleKind != tt.kind {
+				t.Errorf("format = %s, want 16000 Hz stereo %s", f, tt.kind)
+			}
+			got, err := readAll(t, src)
+			if err != nil {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("read %d samples, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if math.Abs(float64(got[i]-want[i])) > tt.maxError {
+					t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+			if err := src.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWriter_Compresses(t *testing.T) {
+	t.Parallel()
+
+	// 10 s of silence
+	var file bytes.Buffer
+	if err := Encode(&file, audiotest.NewSilentSource(8000, 1, 80000)); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if file.Len() > 4000 {
+		t.Errorf("10 s of silence took %d bytes", file.Len())
+	}
+}
+
+func TestSilence(t *testing.T) {
+	t.Parallel()
+
+	// A tone, 10 s of line noise at about -63 dBFS, and a tone again,
+	// ending on a partial window
+	const frames = 12*8000 + 37
+	noisy := func(i int) bool { return i >= 8000 && i < 88000 }
+	line := func() audio.Source {
+		return audiotest.NewMockSource(8000, 2, frames, func(i, c int) float32 {
+			if noisy(i) {
+				return float32(0.001 * math.Sin(1.7*float64(i+c)))
+			}
+			return float32(0.5 * math.Sin(2*math.Pi*440*float64(i)/8000))
+		})
+	}
+
+	tests := []struct {
+		name     string
+		opts     []WriterOption
+		maxError float64
+	}{
+		{name: "float", opts: []WriterOption{WithSilence(-50, 0)}},
+		{name: "16-bit", opts: []WriterOption{WithSilence(-50, time.Second), WithEncoding(pcm.S16LE)}, maxError: 1.0 / 32767},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var plain, file bytes.Buffer
+			if err := Encode(&plain, line(), tt.opts[1:]...); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if err := Encode(&file, line(), tt.opts...); err != nil {
+				t.Fatalf("Encode(WithSilence) error = %v", err)
+			}
+			if file.Len()*5 > plain.Len() {
+				t.Errorf("took %d bytes, %d without WithSilence", file.Len(), plain.Len())
+			}
+
+			src, err := Decoder{}.Decode(&file)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			want, err := readAll(t, line())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := readAll(t, src)
+			if err != nil {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("read %d frames, want %d", len(got)/2, len(want)/2)
+			}
+
+			var sum, wantSum float64
+			for i := range got {
+				if noisy(i / 2) {
+					sum += float64(got[i]) * float64(got[i])
+					wantSum += float64(want[i]) * float64(want[i])
+					continue
+				}
+				if math.Abs(float64(got[i]-want[i])) > tt.maxError {
+					t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+			if db := 10 * math.Log10(sum/wantSum); math.Abs(db) > 0.5 {
+				t.Errorf("silence played %.2f dB off its level", db)
+			}
+		})
+	}
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	t.Parallel()
+
+	var file bytes.Buffer
+	if err := Encode(&file, audiotest.NewSineSource(8000, 1, 8000, 440)); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	data := file.Bytes()
+	with := func(i int, b byte) []byte {
+		d := bytes.Clone(data)
+		d[i] = b
+		return d
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "empty", wantErr: ErrNotPCMZ},
+		{name: "WAV", data: []byte("RIFF\x24\x00\x00\x00WAVEfmt "), wantErr: ErrNotPCMZ},
+		{name: "version", data: with(4, 3), wantErr: ErrUnsupported},
+		{name: "compression", data: with(5, 9), wantErr: ErrUnsupported},
+		{name: "encoding", data: with(6, 7), wantErr: ErrUnsupported},
+		{name: "no channels", data: with(12, 0), wantErr: audio.ErrInvalidChannels},
+		{name: "truncated", data: data[:len(data)/2], wantErr: io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := Decoder{}.Decode(bytes.NewReader(tt.data))
+			if err == nil {
+				_, err = readAll(t, src)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 1}, WithEncoding(pcm.MuLaw)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("NewWriter(µ-law) error = %v, want ErrUnsupported", err)
+	}
+	if _, err := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 1 << 16}); !errors.Is(err, ErrInvalidChannelCount) {
+		t.Errorf("NewWriter(65536 channels) error = %v, want ErrInvalidChannelCount", err)
+	}
+
+	w, err := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 2})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.WriteSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidChannelCount) {
+		t.Errorf("WriteSamples(3) error = %v, want ErrInvalidChannelCount", err)
+	}
+	if _, err := w.WriteSource(audiotest.NewSilentSource(8000, 1, 10)); !errors.Is(err, audio.ErrFormatMismatch) {
+		t.Errorf("WriteSource(mono) error = %v, want ErrFormatMismatch", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := w.WriteSamples(make([]float32, 2)); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("WriteSamples() after Close error = %v, want ErrWriterClosed", err)
+	}
+}
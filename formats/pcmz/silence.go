@@ -0,0 +1,358 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcmz
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/pcm"
+)
+
+const (
+	// silenceWindow is the span over which audio is judged silent, as
+	// audio.TrimSilence judges it
+	silenceWindow = 10 * time.Millisecond
+
+	// DefaultMinSilence is the shortest run of silence WithSilence stores
+	// as a marker when given none.
+	DefaultMinSilence = 500 * time.Millisecond
+
+	recordAudio   = 1
+	recordSilence = 2
+
+	// noiseSeed seeds the comfort noise, so a file plays the same each time
+	noiseSeed = 1
+)
+
+// WithSilence makes an archival file: runs of at least minDuration whose
+// peaks stay under thresholdDB dBFS, such as -50, are stored as a marker
+// giving their length and RMS level instead of their samples, and are
+// played back as noise at that level. A minDuration of 0 selects
+// DefaultMinSilence.
+//
+// Idle stretches of a trunk recording then take a few bytes each, however
+// long they are. The audio between them is kept as WithEncoding stores it.
+func WithSilence(thresholdDB float64, minDuration time.Duration) WriterOption {
+	return func(w *Writer) {
+		if minDuration <= 0 {
+			minDuration = DefaultMinSilence
+		}
+		w.silence = &squeezer{
+			threshold:   float32(math.Pow(10, thresholdDB/20)),
+			minDuration: minDuration,
+		}
+	}
+}
+
+// squeezer holds the state of a Writer storing silence as markers.
+// Samples are judged a window at a time; silent windows are held until
+// there are enough of them for a marker, and then only counted.
+type squeezer struct {
+	threshold   float32
+	minDuration time.Duration
+	channels    int
+	window      int // samples
+	minRun      int // samples
+
+	win    []float32 // partial window
+	held   []float32 // silent windows, fewer than minRun samples
+	loud   []float32 // audio not yet written
+	run    int64     // samples of the current marker
+	energy float64   // sum of squares over the run
+	maxRun int64     // samples a marker holds at most
+}
+
+// init sizes the windows to format
+func (s *squeezer) init(format audio.Format) {
+	s.channels = format.Channels
+	s.window = max(audio.FrameLen(format.Rate, silenceWindow), 1) * format.Channels
+	s.minRun = max(audio.FrameLen(format.Rate, s.minDuration)*format.Channels, s.window)
+	s.maxRun = (math.MaxUint32 / int64(s.window/format.Channels)) * int64(s.window)
+}
+
+// squeeze writes samples as audio records and silence markers
+func (w *Writer) squeeze(samples []float32) error {
+	s := w.silence
+	for len(samples) > 0 {
+		k := min(s.window-len(s.win), len(samples))
+		s.win = append(s.win, samples[:k]...)
+		samples = samples[k:]
+		if len(s.win) < s.window {
+			break
+		}
+		if err := w.judge(s.win); err != nil {
+			return err
+		}
+		s.win = s.win[:0]
+	}
+
+	// Hand the audio to the compressor, so it is never held long
+	return w.writeAudio()
+}
+
+// judge adds a window to the audio or to the current run of silence
+func (w *Writer) judge(win []float32) error {
+	s := w.silence
+	if !silent(win, s.threshold) {
+		if s.run > 0 {
+			if err := w.writeMarker(); err != nil {
+				return err
+			}
+		}
+		s.loud = append(s.loud, s.held...)
+		s.loud = append(s.loud, win...)
+		s.held = s.held[:0]
+		return nil
+	}
+
+	if s.run > 0 {
+		s.run += int64(len(win))
+		s.energy += energy(win)
+		if s.run >= s.maxRun {
+			return w.writeMarker()
+		}
+		return nil
+	}
+	s.held = append(s.held, win...)
+	if len(s.held) < s.minRun {
+		return nil
+	}
+
+	// Long enough: the audio before goes out, and the run becomes a marker
+	if err := w.writeAudio(); err != nil {
+		return err
+	}
+	s.run = int64(len(s.held))
+	s.energy = energy(s.held)
+	s.held = s.held[:0]
+	return nil
+}
+
+// flushSilence writes what is pending at Close, judging the last partial
+// window as a whole one
+func (w *Writer) flushSilence() error {
+	s := w.silence
+	if len(s.win) > 0 {
+		if err := w.judge(s.win); err != nil {
+			return err
+		}
+		s.win = s.win[:0]
+	}
+	if s.run > 0 {
+		if err := w.writeMarker(); err != nil {
+			return err
+		}
+	}
+	s.loud = append(s.loud, s.held...)
+	s.held = s.held[:0]
+	return w.writeAudio()
+}
+
+// writeAudio writes the pending audio as a record
+func (w *Writer) writeAudio() error {
+	s := w.silence
+	if len(s.loud) == 0 {
+		return nil
+	}
+	if err := w.writeRecord(recordAudio, len(s.loud)/s.channels, nil); err != nil {
+		return err
+	}
+	if err := w.writePCM(s.loud); err != nil {
+		return err
+	}
+	s.loud = s.loud[:0]
+	return nil
+}
+
+// writeMarker writes the current run of silence as a record
+func (w *Writer) writeMarker() error {
+	s := w.silence
+	level := math.Sqrt(s.energy / float64(s.run))
+	b := binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(level)))
+	if err := w.writeRecord(recordSilence, int(s.run)/s.channels, b); err != nil {
+		return err
+	}
+	s.run, s.energy = 0, 0
+	return nil
+}
+
+// writeRecord writes a record header of kind for frames, followed by extra
+func (w *Writer) writeRecord(kind byte, frames int, extra []byte) error {
+	b := make([]byte, 5, 5+len(extra))
+	b[0] = kind
+	binary.LittleEndian.PutUint32(b[1:], uint32(frames))
+	b = append(b, extra...)
+
+	if _, err := w.zw.Write(b); err != nil {
+		w.err = fmt.Errorf("%w", err)
+		return w.err
+	}
+	return nil
+}
+
+// silent reports whether no sample of win reaches threshold
+func silent(win []float32, threshold float32) bool {
+	for _, v := range win {
+		if v >= threshold || -v >= threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// energy returns the sum of squares of win
+func energy(win []float32) float64 {
+	var e float64
+	for _, v := range win {
+		e += float64(v) * float64(v)
+	}
+	return e
+}
+
+// expander plays a file written WithSilence, expanding its markers into
+// noise at their level
+type expander struct {
+	r        io.ReadCloser
+	br       *bufio.Reader
+	rate     int
+	channels int
+	enc      pcm.Encoding
+	rng      *rand.Rand
+	buf      []byte
+
+	kind  byte
+	left  int64   // samples left in the current record
+	level float32 // noise peak of the current marker
+	eof   bool
+}
+
+func newExpander(r io.ReadCloser, h header) (*expander, error) {
+	format := audio.Format{Rate: h.rate, Channels: h.channels}
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &expander{
+		r:        r,
+		br:       bufio.NewReader(r),
+		rate:     h.rate,
+		channels: h.channels,
+		enc:      h.enc,
+		rng:      rand.New(rand.NewSource(noiseSeed)),
+	}, nil
+}
+
+func (e *expander) SampleRate() int { return e.rate }
+func (e *expander) Channels() int   { return e.channels }
+func (e *expander) BufSize() int    { return 4096 }
+
+func (e *expander) Format() audio.Format {
+	return audio.Format{
+		Rate:       e.rate,
+		Channels:   e.channels,
+		Layout:     audio.DefaultLayout(e.channels),
+		SampleKind: e.enc.SampleKind(),
+	}
+}
+
+func (e *expander) Close() error {
+	if err := e.r.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+func (e *expander) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%e.channels != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	n := 0
+	for n < len(dst) {
+		if e.eof {
+			return n, io.EOF
+		}
+		if e.left == 0 {
+			if err := e.next(); err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		k := int(min(e.left, int64(len(dst)-n)))
+		if e.kind == recordSilence {
+			for i := range dst[n : n+k] {
+				dst[n+i] = e.level * float32(2*e.rng.Float64()-1)
+			}
+		} else if err := e.readAudio(dst[n : n+k]); err != nil {
+			return n, err
+		}
+		n += k
+		e.left -= int64(k)
+	}
+	return n, nil
+}
+
+// next reads the header of the next record
+func (e *expander) next() error {
+	var b [9]byte
+	if _, err := io.ReadFull(e.br, b[:5]); err != nil {
+		if errors.Is(err, io.EOF) {
+			e.eof = true
+			return nil
+		}
+		return fmt.Errorf("%w", unexpected(err))
+	}
+	e.kind = b[0]
+	e.left = int64(binary.LittleEndian.Uint32(b[1:])) * int64(e.channels)
+
+	switch e.kind {
+	case recordAudio:
+	case recordSilence:
+		if _, err := io.ReadFull(e.br, b[5:]); err != nil {
+			return fmt.Errorf("%w", unexpected(err))
+		}
+		// Uniform noise peaks at √3 times its RMS level
+		e.level = math.Float32frombits(binary.LittleEndian.Uint32(b[5:])) * float32(math.Sqrt(3))
+	default:
+		return fmt.Errorf("%w: record type %d", ErrUnsupported, e.kind)
+	}
+	return nil
+}
+
+// readAudio decodes samples of an audio record into dst
+func (e *expander) readAudio(dst []float32) error {
+	size := e.enc.BytesPerSample()
+	if cap(e.buf) < len(dst)*size {
+		e.buf = make([]byte, len(dst)*size)
+	}
+	buf := e.buf[:len(dst)*size]
+	if _, err := io.ReadFull(e.br, buf); err != nil {
+		return fmt.Errorf("%w", unexpected(err))
+	}
+
+	for i := range dst {
+		if e.enc == pcm.S16LE {
+			dst[i] = float32(int16(binary.LittleEndian.Uint16(buf[i*2:]))) / 32768
+		} else {
+			dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+		}
+	}
+	return nil
+}
+
+// unexpected turns the end of the stream inside a record into
+// io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package pcmz
+
+import (
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/pcm"
+	"github.com/ik5/audpbx/utils"
+)
+
+// Writer writes a pcmz file, streaming the samples through the compressor
+// so they are never held in memory.
+type Writer struct {
+	zw       *flate.Writer
+	channels int
+	enc      pcm.Encoding
+	level    int
+	buf      []byte
+	err      error
+	closed   bool
+	silence  *squeezer // nil unless WithSilence
+}
+
+// WriterOption configures a Writer.
+type WriterOption func(*Writer)
+
+// WithEncoding stores samples as enc: pcm.F32LE, the default, which keeps
+// them exactly, or pcm.S16LE, half the size before compression.
+func WithEncoding(enc pcm.Encoding) WriterOption {
+	return func(w *Writer) {
+		w.enc = enc
+	}
+}
+
+// WithLevel sets the DEFLATE compression level, from flate.BestSpeed, the
+// default, to flate.BestCompression.
+func WithLevel(level int) WriterOption {
+	return func(w *Writer) {
+		w.level = level
+	}
+}
+
+// NewWriter writes a pcmz header for format to w and returns a Writer for
+// its samples. Only format.Rate and format.Channels are stored.
+func NewWriter(w io.Writer, format audio.Format, opts ...WriterOption) (*Writer, error) {
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if format.Channels > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidChannelCount, format.Channels)
+	}
+
+	pw := &Writer{channels: format.Channels, enc: pcm.F32LE, level: flate.BestSpeed}
+	for _, opt := range opts {
+		opt(pw)
+	}
+	if pw.enc != pcm.F32LE && pw.enc != pcm.S16LE {
+		return nil, fmt.Errorf("%w: sample encoding %s", ErrUnsupported, pw.enc)
+	}
+
+	zw, err := flate.NewWriter(w, pw.level)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	pw.zw = zw
+
+	if pw.silence != nil {
+		pw.silence.init(format)
+	}
+
+	h := header{rate: format.Rate, channels: format.Channels, enc: pw.enc, silence: pw.silence != nil}
+	if _, err := w.Write(h.marshal()); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return pw, nil
+}
+
+// WriteSamples writes interleaved float32 samples; 16-bit files clip them
+// to [-1, 1]. len(samples) must be a multiple of the channel count.
+func (w *Writer) WriteSamples(samples []float32) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if len(samples)%w.channels != 0 {
+		return fmt.Errorf("%w: %d samples for %d channels", ErrInvalidChannelCount, len(samples), w.channels)
+	}
+
+	if w.silence != nil {
+		return w.squeeze(samples)
+	}
+	return w.writePCM(samples)
+}
+
+// writePCM encodes samples into the compressed stream
+func (w *Writer) writePCM(samples []float32) error {
+	size := w.enc.BytesPerSample()
+	if cap(w.buf) < len(samples)*size {
+		w.buf = make([]byte, len(samples)*size)
+	}
+	buf := w.buf[:len(samples)*size]
+	for i, s := range samples {
+		if w.enc == pcm.S16LE {
+			binary.LittleEndian.PutUint16(buf[i*2:], uint16(utils.Float32ToInt16(s)))
+		} else {
+			binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(s))
+		}
+	}
+
+	// A failed write leaves the stream broken, so the error sticks
+	if _, err := w.zw.Write(buf); err != nil {
+		w.err = fmt.Errorf("%w", err)
+		return w.err
+	}
+	return nil
+}
+
+// WriteSource copies src to the end and returns the number of sample
+// frames written. src must have the channel count of the Writer. It does
+// not close src.
+func (w *Writer) WriteSource(src audio.Source) (int64, error) {
+	if src.Channels() != w.channels {
+		return 0, fmt.Errorf("%w: source has %d channels, writer %d",
+			audio.ErrFormatMismatch, src.Channels(), w.channels)
+	}
+
+	size := max(src.BufSize(), 1024)
+	buf := make([]float32, size-size%w.channels)
+	var frames int64
+	for {
+		n, err := src.ReadSamples(buf)
+		n -= n % w.channels
+		if n > 0 {
+			if werr := w.WriteSamples(buf[:n]); werr != nil {
+				return frames, werr
+			}
+			frames += int64(n / w.channels)
+		}
+
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, fmt.Errorf("reading source: %w", err)
+		}
+	}
+}
+
+// Close ends the compressed stream. It does not close the underlying
+// writer. Writes after Close fail with ErrWriterClosed.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+	if w.silence != nil {
+		if err := w.flushSilence(); err != nil {
+			return err
+		}
+	}
+	if err := w.zw.Close(); err != nil {
+		w.err = fmt.Errorf("%w", err)
+	}
+	return w.err
+}
+
+// Encode writes src to w as a pcmz file, streaming it with a Writer
+// configured by opts. It does not close src or w.
+func Encode(w io.Writer, src audio.Source, opts ...WriterOption) error {
+	pw, err := NewWriter(w, audio.FormatOf(src), opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := pw.WriteSource(src); err != nil {
+		return err
+	}
+	return pw.Close()
+}
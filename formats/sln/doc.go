@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package sln reads and writes the signed linear files of Asterisk: mono
+// 16-bit little endian PCM with no header, the rate given by the extension.
+// They are what Asterisk records and plays without transcoding, for
+// prompts, Record() and MixMonitor:
+//
+//	.sln    8 kHz     .sln32   32 kHz
+//	.sln12  12 kHz    .sln44   44.1 kHz
+//	.sln16  16 kHz    .sln48   48 kHz
+//	.sln24  24 kHz    .sln96   96 kHz
+//	                  .sln192  192 kHz
+//
+// # Decoding
+//
+// The file does not describe itself, so the rate comes from its name:
+//
+//	rate, err := sln.RateOf(filepath.Ext(path))
+//	src, err := sln.NewSource(file, rate)
+//
+// Decoder fixes the rate so the formats can be registered by extension;
+// the zero Decoder reads .sln:
+//
+//	reg.Register("sln16", sln.Decoder{Rate: 16000})
+//
+// # Encoding
+//
+// WriteSLN writes a mono source at one of the rates above, and Extension
+// names the file it makes:
+//
+//	mono := audio.NewResampler(audio.NewMonoMixer(src), 16000)
+//	ext, _ := sln.Extension(16000) // "sln16"
+//	frames, err := sln.WriteSLN(file, mono)
+//
+// Sources of other rates or channel counts fail with
+// audio.ErrFormatMismatch rather than being converted behind the caller's
+// back, since the rate of the file could not be told from its contents.
+package sln
@@ -0,0 +1,14 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package sln
+
+import "errors"
+
+var (
+	// ErrUnsupportedRate indicates a rate Asterisk has no signed linear
+	// format for
+	ErrUnsupportedRate = errors.New("no signed linear format at this rate")
+	// ErrUnknownExtension indicates a file extension that is not one of
+	// the signed linear formats
+	ErrUnknownExtension = errors.New("not a signed linear file extension")
+)
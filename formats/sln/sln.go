@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package sln
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/pcm"
+	"github.com/ik5/audpbx/utils"
+)
+
+// DefaultRate is the rate of .sln, and of the zero Decoder
+const DefaultRate = 8000
+
+// rates are the rates of the signed linear formats, and extensions the
+// file extensions Asterisk gives them
+var (
+	rates      = []int{8000, 12000, 16000, 24000, 32000, 44100, 48000, 96000, 192000}
+	extensions = []string{"sln", "sln12", "sln16", "sln24", "sln32", "sln44", "sln48", "sln96", "sln192"}
+)
+
+// Rates returns the rates Asterisk has a signed linear format for, lowest
+// first.
+func Rates() []int { return slices.Clone(rates) }
+
+// RateOf returns the rate of the signed linear files with extension ext,
+// with or without its dot and in any case: 16000 for ".sln16". Other
+// extensions fail with ErrUnknownExtension.
+func RateOf(ext string) (int, error) {
+	i := slices.Index(extensions, strings.ToLower(strings.TrimPrefix(ext, ".")))
+	if i < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownExtension, ext)
+	}
+	return rates[i], nil
+}
+
+// Extension returns the file extension, without its dot, of signed linear
+// files at rate: "sln16" for 16000. Other rates fail with
+// ErrUnsupportedRate.
+func Extension(rate int) (string, error) {
+	i := slices.Index(rates, rate)
+	if i < 0 {
+		return "", fmt.Errorf("%w: %d Hz", ErrUnsupportedRate, rate)
+	}
+	return extensions[i], nil
+}
+
+// NewSource reads signed linear audio at rate from r. If r is an io.Closer
+// it is closed by the source's Close. Rates without a signed linear format
+// fail with ErrUnsupportedRate.
+func NewSource(r io.Reader, rate int) (audio.Source, error) {
+	if !slices.Contains(rates, rate) {
+		return nil, fmt.Errorf("%w: %d Hz", ErrUnsupportedRate, rate)
+	}
+	src, err := pcm.NewSource(r, rate, 1, pcm.S16LE)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return src, nil
+}
+
+// Decoder decodes signed linear files of one rate, 8 kHz when Rate is
+// zero, so each extension can be registered in an audio.Registry:
+//
+//	reg.Register("sln", sln.Decoder{})
+//	reg.Register("sln48", sln.Decoder{Rate: 48000})
+type Decoder struct {
+	Rate int
+}
+
+// Decode returns a source reading r as NewSource does.
+func (d Decoder) Decode(r io.Reader) (audio.Source, error) {
+	return NewSource(r, d.rate())
+}
+
+// Capabilities reports the format d reads and WriteSLN writes: 16-bit mono
+// at the rate of d.
+func (d Decoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{
+		Name:            "SLN",
+		Decode:          true,
+		Encode:          true,
+		BitDepths:       []int{16},
+		EncodeBitDepths: []int{16},
+		MaxChannels:     1,
+		Rates:           []int{d.rate()},
+	}
+}
+
+func (d Decoder) rate() int {
+	if d.Rate == 0 {
+		return DefaultRate
+	}
+	return d.Rate
+}
+
+// WriteSLN writes src to w as signed linear audio and returns the number of
+// frames written. src must be mono, at one of the rates of Rates, which
+// names the file through Extension; other sources fail with
+// audio.ErrFormatMismatch. It does not close src or w.
+func WriteSLN(w io.Writer, src audio.Source) (int64, error) {
+	if ch := src.Channels(); ch != 1 {
+		return 0, fmt.Errorf("%w: signed linear is mono, source has %d channels", audio.ErrFormatMismatch, ch)
+	}
+	if rate := src.SampleRate(); !slices.Contains(rates, rate) {
+		return 0, fmt.Errorf("%w: %w: %d Hz", audio.ErrFormatMismatch, ErrUnsupportedRate, rate)
+	}
+
+	buf := make([]float32, max(src.BufSize(), 1024))
+	out := make([]byte, 2*len(buf))
+	var frames int64
+	for {
+		n, err := src.ReadSamples(buf)
+		if n > 0 {
+			for i, v := range buf[:n] {
+				s := uint16(utils.Float32ToInt16(v))
+				out[2*i], out[2*i+1] = byte(s), byte(s>>8)
+			}
+			if _, werr := w.Write(out[:2*n]); werr != nil {
+				return frames, fmt.Errorf("%w", werr)
+			}
+			frames += int64(n)
+		}
+
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, fmt.Errorf("reading source: %w", err)
+		}
+	}
+}
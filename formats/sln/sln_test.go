@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package sln
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestRateOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ext     string
+		want    int
+		wantErr error
+	}{
+		{ext: ".sln", want: 8000},
+		{ext: "sln16", want: 16000},
+		{ext: ".SLN48", want: 48000},
+		{ext: ".sln44", want: 44100},
+		{ext: ".sln192", want: 192000},
+		{ext: ".slin", wantErr: ErrUnknownExtension},
+		{ext: ".wav", wantErr: ErrUnknownExtension},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ext, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := RateOf(tt.ext)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RateOf() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RateOf() = %d, want %d", got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			if ext, err := RateOf(mustExtension(t, got)); err != nil || ext != got {
+				t.Errorf("RateOf(Extension(%d)) = %d, %v", got, ext, err)
+			}
+		})
+	}
+
+	if _, err := Extension(22050); !errors.Is(err, ErrUnsupportedRate) {
+		t.Errorf("Extension(22050) error = %v, want ErrUnsupportedRate", err)
+	}
+}
+
+func TestWriteSLN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		src     func() audio.Source
+		frames  int64
+		wantErr error
+	}{
+		{name: "8 kHz", src: func() audio.Source { return audiotest.NewSineSource(8000, 1, 1000, 440) }, frames: 1000},
+		{name: "16 kHz", src: func() audio.Source { return audiotest.NewSineSource(16000, 1, 3000, 440) }, frames: 3000},
+		{name: "48 kHz", src: func() audio.Source { return audiotest.NewSineSource(48000, 1, 5000, 440) }, frames: 5000},
+		{name: "empty", src: func() audio.Source { return audiotest.NewSilentSource(8000, 1, 0) }},
+		{name: "stereo", src: func() audio.Source { return audiotest.NewSilentSource(8000, 2, 10) }, wantErr: audio.ErrFormatMismatch},
+		{name: "22.05 kHz", src: func() audio.Source { return audiotest.NewSilentSource(22050, 1, 10) }, wantErr: ErrUnsupportedRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rate := tt.src().SampleRate()
+			want := readAll(t, tt.src())
+
+			var buf bytes.Buffer
+			frames, err := WriteSLN(&buf, tt.src())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WriteSLN() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if frames != tt.frames || int64(buf.Len()) != 2*tt.frames {
+				t.Fatalf("WriteSLN() = %d frames in %d bytes, want %d frames", frames, buf.Len(), tt.frames)
+			}
+
+			// Headerless 16-bit little endian, decoded back within rounding
+			src, err := Decoder{Rate: rate}.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if src.SampleRate() != rate || src.Channels() != 1 {
+				t.Errorf("decoded %d Hz %d ch, want %d Hz mono", src.SampleRate(), src.Channels(), rate)
+			}
+			got := readAll(t, src)
+			if len(got) != len(want) {
+				t.Fatalf("decoded %d samples, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if d := got[i] - want[i]; d > 1.0/32768 || d < -1.0/32768 {
+					t.Fatalf("sample %d = %v, want %v", i, got[i], want[i])
+				}
+				if s := int16(binary.LittleEndian.Uint16(buf.Bytes()[2*i:])); float32(s)/32768 != got[i] {
+					t.Fatalf("sample %d decoded as %v from %d", i, got[i], s)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	t.Parallel()
+
+	data := binary.LittleEndian.AppendUint16(nil, uint16(0x8000))
+	data = binary.LittleEndian.AppendUint16(data, 0x4000)
+	data = append(data, 0x7f) // partial sample, dropped
+
+	src, err := Decoder{}.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if src.SampleRate() != DefaultRate || src.Channels() != 1 {
+		t.Errorf("zero Decoder reads %d Hz %d ch, want 8 kHz mono", src.SampleRate(), src.Channels())
+	}
+	if got := readAll(t, src); len(got) != 2 || got[0] != -1 || got[1] != 0.5 {
+		t.Errorf("decoded %v, want [-1 0.5]", got)
+	}
+
+	if _, err := (Decoder{Rate: 11025}).Decode(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedRate) {
+		t.Errorf("Decode(11025 Hz) error = %v, want ErrUnsupportedRate", err)
+	}
+
+	c := Decoder{Rate: 48000}.Capabilities()
+	if err := c.CheckEncode(audio.Format{Rate: 48000, Channels: 1, SampleKind: audio.SampleInt16}); err != nil {
+		t.Errorf("CheckEncode(48 kHz mono) error = %v", err)
+	}
+	if err := c.CheckDecode(audio.Format{Rate: 48000, Channels: 2}); !errors.Is(err, audio.ErrUnsupported) {
+		t.Errorf("CheckDecode(stereo) error = %v, want ErrUnsupported", err)
+	}
+}
+
+// readAll reads src to the end
+func readAll(t *testing.T, src audio.Source) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, 256)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+// mustExtension returns the extension of rate
+func mustExtension(t *testing.T, rate int) string {
+	t.Helper()
+
+	ext, err := Extension(rate)
+	if err != nil {
+		t.Fatalf("Extension(%d) error = %v", rate, err)
+	}
+	return ext
+}
@@ -5,6 +5,8 @@ package vorbis
 import (
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/ik5/audpbx/audio"
 	"github.com/jfreymuth/oggvorbis"
@@ -17,11 +19,24 @@ type oggReader interface {
 	Read([]float32) (int, error)
 }
 
+// oggSeeker is the random access part of oggvorbis.Reader, available when
+// it reads from an io.Seeker
+type oggSeeker interface {
+	oggReader
+	SetPosition(pos int64) error
+	Length() int64
+}
+
 type source struct {
 	dec        oggReader
 	sampleRate int
 	channels   int
 	frameBuf   []float32 // buffer for reading frames from decoder
+	read       int64     // samples returned so far
+	length     int64     // frames, -1 when unknown
+	tags       audio.Tags
+	layout     audio.Layout
+	order      []int // from the Vorbis channel order to layout, nil when the same
 }
 
 func (s *source) SampleRate() int { return s.sampleRate }
@@ -29,50 +44,137 @@ func (s *source) Channels() int   { return s.channels }
 func (s *source) Close() error    { return nil }
 func (s *source) BufSize() int    { return cap(s.frameBuf) }
 
+func (s *source) Format() audio.Format {
+	return audio.Format{
+		Rate:       s.sampleRate,
+		Channels:   s.channels,
+		Layout:     s.layout,
+		SampleKind: audio.SampleFloat32,
+	}
+}
+
+// TotalFrames returns the length oggvorbis read from the last page of a
+// seekable input, or -1 for other inputs.
+func (s *source) TotalFrames() int64 { return s.length }
+
+func (s *source) Duration() time.Duration {
+	return audio.FramesDuration(s.length, s.sampleRate)
+}
+
+// Metadata returns the Vorbis comments of the stream. Title, Artist and
+// Album come from the TITLE, ARTIST and ALBUM fields, and Comments holds
+// them all.
+func (s *source) Metadata() audio.Tags { return s.tags }
+
+// ReadSamples fills dst with whole frames, rounding its length down to a
+// multiple of the channel count. A dst shorter than a frame fails with
+// audio.ErrInvalidDstSize.
 func (s *source) ReadSamples(dst []float32) (int, error) {
 	if len(dst) == 0 {
 		return 0, nil
 	}
-
-	// oggvorbis.Reader.Read() expects a buffer sized in frames (not samples)
-	// and returns the number of frames read
-	framesRequested := len(dst) / s.channels
-
-	// Ensure our frame buffer is large enough
-	if cap(s.frameBuf) < framesRequested*s.channels {
-		s.frameBuf = make([]float32, framesRequested*s.channels)
+	if len(dst) < s.channels {
+		return 0, audio.ErrInvalidDstSize
 	}
-	s.frameBuf = s.frameBuf[:framesRequested*s.channels]
-
-	// Read frames from decoder
-	// The oggvorbis library's Read method takes a []float32 and returns frames read
-	framesRead, err := s.dec.Read(s.frameBuf)
-	if framesRead == 0 {
-		if err != nil {
-			return 0, err
-		}
-		return 0, nil
+
+	// Ensure our frame buffer holds the whole frames dst has room for
+	size := len(dst) / s.channels * s.channels
+	if cap(s.frameBuf) < size {
+		s.frameBuf = make([]float32, size)
 	}
+	s.frameBuf = s.frameBuf[:size]
 
-	// Copy the interleaved samples to dst
-	samplesRead := framesRead * s.channels
+	// oggvorbis.Reader.Read returns the number of samples, all channels
+	// counted, and always whole frames of them
+	samplesRead, err := s.dec.Read(s.frameBuf)
+	samplesRead -= samplesRead % s.channels
 	copy(dst, s.frameBuf[:samplesRead])
+	if s.order != nil {
+		audio.ReorderChannels(dst[:samplesRead], s.order)
+	}
+	s.read += int64(samplesRead)
 
 	return samplesRead, err
 }
 
+// seekSource is a source over a seekable Ogg Vorbis stream
+type seekSource struct {
+	*source
+	seeker oggSeeker
+}
+
+// SeekFrame moves to frame n. oggvorbis bisects the pages for the one
+// holding n and decodes from there, so seeking does not read the stream
+// from the start.
+func (s *seekSource) SeekFrame(n int64) error {
+	if n < 0 || n > s.seeker.Length() {
+		return fmt.Errorf("%w: frame %d", audio.ErrSeekOutOfRange, n)
+	}
+
+	if err := s.seeker.SetPosition(n); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	s.read = n * int64(s.channels)
+	return nil
+}
+
+func (s *seekSource) Position() int64 { return s.read / int64(s.channels) }
+
 type Decoder struct{}
 
+// Decode returns a source of the decoded Vorbis stream. It implements
+// audio.Metadata with the Vorbis comments and audio.Lengther; when r is an io.ReadSeeker, oggvorbis reads the length
+// from the last page and the source implements audio.Seeker.
 func (Decoder) Decode(r io.Reader) (audio.Source, error) {
 	dec, err := oggvorbis.NewReader(r)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
 
-	return &source{
+	src := &source{
 		dec:        dec,
 		sampleRate: dec.SampleRate(),
 		channels:   dec.Channels(),
 		frameBuf:   make([]float32, 4096),
-	}, nil
+		length:     -1,
+		tags:       parseComments(dec.CommentHeader().Comments),
+	}
+	src.layout, src.order = audio.VorbisLayout(src.channels)
+	if _, ok := r.(io.ReadSeeker); ok {
+		src.length = dec.Length()
+		return &seekSource{source: src, seeker: dec}, nil
+	}
+	return src, nil
+}
+
+// Capabilities reports what the package reads. It does not encode.
+func (Decoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{
+		Name:        "Vorbis",
+		Decode:      true,
+		MaxChannels: 255,
+		Seekable:    true,
+		Metadata:    true,
+	}
+}
+
+// parseComments reads Vorbis comments, "NAME=value" fields whose names are
+// case insensitive and may repeat. Fields without "=" are ignored.
+func parseComments(comments []string) audio.Tags {
+	var tags audio.Tags
+	for _, c := range comments {
+		name, value, ok := strings.Cut(c, "=")
+		if !ok || name == "" {
+			continue
+		}
+		if tags.Comments == nil {
+			tags.Comments = make(map[string][]string)
+		}
+		name = strings.ToUpper(name)
+		tags.Comments[name] = append(tags.Comments[name], value)
+	}
+
+	field := func(name string) string { return strings.Join(tags.Comments[name], "/") }
+	tags.Title, tags.Artist, tags.Album = field("TITLE"), field("ARTIST"), field("ALBUM")
+	return tags
 }
@@ -4,8 +4,14 @@ package vorbis
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"math"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
 )
 
 // mockOggVorbisReader simulates the oggvorbis.Reader for testing
@@ -34,7 +40,7 @@ func (m *mockOggVorbisReader) Read(buf []float32) (int, error) {
 		return 0, io.EOF
 	}
 
-	// Calculate frames (not samples)
+	// Like oggvorbis, read whole frames and count the samples
 	framesRequested := len(buf) / m.channels
 	samplesAvailable := len(m.samples) - m.offset
 	framesAvailable := samplesAvailable / m.channels
@@ -49,12 +55,21 @@ func (m *mockOggVorbisReader) Read(buf []float32) (int, error) {
 	m.offset += samplesToRead
 
 	if m.offset >= len(m.samples) {
-		return framesToRead, io.EOF
+		return samplesToRead, io.EOF
 	}
 
-	return framesToRead, nil
+	return samplesToRead, nil
+}
+
+// SetPosition seeks to a frame, like oggvorbis.Reader
+func (m *mockOggVorbisReader) SetPosition(pos int64) error {
+	m.offset = int(pos) * m.channels
+	return nil
 }
 
+// Length returns the length of the stream in frames
+func (m *mockOggVorbisReader) Length() int64 { return int64(len(m.samples) / m.channels) }
+
 func TestDecoder_InvalidInput(t *testing.T) {
 	t.Parallel()
 
@@ -347,6 +362,41 @@ func TestSource_ReadSamples_Stereo(t *testing.T) {
 	}
 }
 
+func TestSource_ReadSamples_FrameBudget(t *testing.T) {
+	t.Parallel()
+
+	// 10 stereo frames where sample i holds i
+	samples := make([]float32, 20)
+	for i := range samples {
+		samples[i] = float32(i)
+	}
+	mock := &mockOggVorbisReader{sampleRate: 8000, channels: 2, samples: samples}
+	src := &source{dec: mock, sampleRate: 8000, channels: 2}
+
+	if _, err := src.ReadSamples(make([]float32, 1)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(half a frame) error = %v, want ErrInvalidDstSize", err)
+	}
+
+	var got []float32
+	dst := make([]float32, 7)
+	for {
+		n, err := src.ReadSamples(dst)
+		if n%2 != 0 {
+			t.Fatalf("ReadSamples() = %d samples, not whole frames", n)
+		}
+		got = append(got, dst[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+	if !reflect.DeepEqual(got, samples) {
+		t.Errorf("read %v, want %v", got, samples)
+	}
+}
+
 func TestSource_ReadSamples_MultipleChannels(t *testing.T) {
 	t.Parallel()
 
@@ -401,6 +451,43 @@ func TestSource_ReadSamples_MultipleChannels(t *testing.T) {
 	}
 }
 
+func TestSource_Downmix5_1(t *testing.T) {
+	t.Parallel()
+
+	// Frame k holds 0.5 in channel k alone, in the Vorbis order of 5.1:
+	// FL C FR BL BR LFE
+	samples := make([]float32, 6*6)
+	for k := range 6 {
+		samples[k*6+k] = 0.5
+	}
+	src := &source{
+		dec:        &mockOggVorbisReader{sampleRate: 48000, channels: 6, samples: samples},
+		sampleRate: 48000,
+		channels:   6,
+		frameBuf:   make([]float32, 4096),
+	}
+	src.layout, src.order = audio.VorbisLayout(6)
+
+	if got := src.Format().Layout; got != audio.Layout5_1 {
+		t.Errorf("Layout = %v, want 5.1", got)
+	}
+
+	got := make([]float32, 6)
+	if n, err := audio.NewMonoMixer(src).ReadSamples(got); n != 6 || (err != nil && err != io.EOF) {
+		t.Fatalf("ReadSamples() = %d, %v", n, err)
+	}
+
+	// Fronts at unity, surrounds at -3 dB and no LFE
+	front := 0.5 / (3 + math.Sqrt2)
+	back := front / math.Sqrt2
+	want := []float64{front, front, front, back, back, 0}
+	for i := range want {
+		if math.Abs(float64(got[i])-want[i]) > 1e-6 {
+			t.Errorf("mono sample of channel %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
 func TestSource_ReadSamples_LargeBuffer(t *testing.T) {
 	t.Parallel()
 
@@ -669,3 +756,140 @@ func BenchmarkSource_ReadSamples_Stereo(b *testing.B) {
 		_, _ = src.ReadSamples(dst)
 	}
 }
+
+func TestSeekSource_SeekFrame(t *testing.T) {
+	t.Parallel()
+
+	// 8 mono frames where frame i holds i/8
+	samples := make([]float32, 8)
+	for i := range samples {
+		samples[i] = float32(i) / 8
+	}
+	mock := &mockOggVorbisReader{sampleRate: 8000, channels: 1, samples: samples}
+	src := &seekSource{
+		source: &source{dec: mock, sampleRate: 8000, channels: 1, frameBuf: make([]float32, 16)},
+		seeker: mock,
+	}
+
+	dst := make([]float32, 3)
+	if _, err := src.ReadSamples(dst); err != nil {
+		t.Fatalf("ReadSamples() error = %v", err)
+	}
+	if got := src.Position(); got != 3 {
+		t.Errorf("Position() = %d, want 3", got)
+	}
+
+	tests := []struct {
+		frame   int64
+		wantErr error
+	}{
+		{frame: 6},
+		{frame: 1},
+		{frame: 8},
+		{frame: 9, wantErr: audio.ErrSeekOutOfRange},
+		{frame: -1, wantErr: audio.ErrSeekOutOfRange},
+	}
+
+	for _, tt := range tests {
+		err := src.SeekFrame(tt.frame)
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("SeekFrame(%d) error = %v, want %v", tt.frame, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if got := src.Position(); got != tt.frame {
+			t.Errorf("Position() = %d, want %d", got, tt.frame)
+		}
+		n, _ := src.ReadSamples(dst[:1])
+		if tt.frame == 8 {
+			if n != 0 {
+				t.Errorf("ReadSamples() at end = %d, want 0", n)
+			}
+			continue
+		}
+		if want := float32(tt.frame) / 8; n != 1 || dst[0] != want {
+			t.Errorf("frame %d = %v (n=%d), want %v", tt.frame, dst[0], n, want)
+		}
+	}
+}
+
+func TestSource_TotalFrames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		length int64
+		want   time.Duration
+	}{
+		{name: "known", length: 66150, want: 1500 * time.Millisecond},
+		{name: "unknown", length: -1, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var l audio.Lengther = &source{sampleRate: 44100, channels: 2, length: tt.length}
+			if got := l.TotalFrames(); got != tt.length {
+				t.Errorf("TotalFrames() = %d, want %d", got, tt.length)
+			}
+			if got := l.Duration(); got != tt.want {
+				t.Errorf("Duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		comments []string
+		want     audio.Tags
+	}{
+		{name: "none"},
+		{
+			name:     "standard and custom",
+			comments: []string{"TITLE=Main Menu", "artist=IVR Voice", "Album=Prompts", "LANGUAGE=en=US"},
+			want: audio.Tags{
+				Title:  "Main Menu",
+				Artist: "IVR Voice",
+				Album:  "Prompts",
+				Comments: map[string][]string{
+					"TITLE":    {"Main Menu"},
+					"ARTIST":   {"IVR Voice"},
+					"ALBUM":    {"Prompts"},
+					"LANGUAGE": {"en=US"},
+				},
+			},
+		},
+		{
+			name:     "repeated",
+			comments: []string{"ARTIST=A", "Artist=B"},
+			want:     audio.Tags{Artist: "A/B", Comments: map[string][]string{"ARTIST": {"A", "B"}}},
+		},
+		{
+			name:     "malformed",
+			comments: []string{"no separator", "=no name", "TITLE="},
+			want:     audio.Tags{Comments: map[string][]string{"TITLE": {""}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := parseComments(tt.comments)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseComments() = %+v, want %+v", got, tt.want)
+			}
+
+			var m audio.Metadata = &source{tags: got}
+			if !reflect.DeepEqual(m.Metadata(), got) {
+				t.Error("Metadata() does not return the parsed comments")
+			}
+		})
+	}
+}
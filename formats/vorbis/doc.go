@@ -31,6 +31,22 @@
 // The decoder returns an audio.Source that provides samples as float32
 // values normalized to the range [-1.0, 1.0].
 //
+// When the input is an io.ReadSeeker, the source implements audio.Seeker
+// and its audio.Lengther methods report the length. Seeks bisect the Ogg
+// pages rather than decoding from the start.
+//
+// # Comments
+//
+// The source implements audio.Metadata with the Vorbis comments of the
+// stream. Title, Artist and Album come from the standard fields, and
+// Comments holds every field, custom ones included, by upper-case name:
+//
+//	tags := source.(audio.Metadata).Metadata()
+//	fmt.Println(tags.Artist, "-", tags.Title)
+//	for _, lang := range tags.Comments["LANGUAGE"] {
+//	    fmt.Println("language:", lang)
+//	}
+//
 // # Output Format
 //
 // Vorbis decoder output:
@@ -44,7 +60,11 @@
 //
 //	[L0, R0, L1, R1, L2, R2, ...]
 //
-// To convert to mono:
+// Vorbis orders more channels its own way, 5.1 as FL C FR BL BR LFE. The
+// source interleaves them in the order of the audio.Layout it reports
+// instead, FL FR C LFE BL BR for 5.1, as audio.VorbisLayout gives it.
+//
+// To convert to mono, with the LFE channel dropped and surrounds at -3 dB:
 //
 //	vorbisSource, _ := decoder.Decode(file)
 //	mono := audio.NewMonoMixer(vorbisSource)
@@ -61,6 +81,8 @@
 // Note:
 //   - Vorbis encoding is not supported (decoding only)
 //   - Reading is frame-based (decode entire frames)
+//   - Only the first link of a chained Ogg file is decoded; oggvorbis
+//     stops at its end of stream page
 //
 // # Use Cases
 //
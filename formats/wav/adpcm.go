@@ -0,0 +1,361 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// ADPCM files code each sample in 4 bits, as its difference from a
+// prediction, in blocks that each start from a header holding the full
+// state of the decoder. Blocks decode on their own, so they can be seeked
+// to; the last one may be cut short.
+
+// imaStep is the quantizer step of IMA ADPCM, and imaIndex how a code
+// moves through it
+var (
+	imaStep = [89]int32{
+		7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 19, 21, 23, 25, 28, 31,
+		34, 37, 41, 45, 50, 55, 60, 66, 73, 80, 88, 97, 107, 118, 130, 143,
+		157, 173, 190, 209, 230, 253, 279, 307, 337, 371, 408, 449, 494, 544, 598, 658,
+		724, 796, 876, 963, 1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066, 2272, 2499, 2749, 3024,
+		3327, 3660, 4026, 4428, 4871, 5358, 5894, 6484, 7132, 7845, 8630, 9493, 10442, 11487, 12635, 13899,
+		15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794, 32767,
+	}
+	imaIndex = [16]int{-1, -1, -1, -1, 2, 4, 6, 8, -1, -1, -1, -1, 2, 4, 6, 8}
+)
+
+// msAdapt scales the step of Microsoft ADPCM by the last code, and
+// msCoefs are the predictors of files that do not list their own
+var (
+	msAdapt = [16]int32{230, 230, 230, 230, 307, 409, 512, 614, 768, 614, 512, 409, 307, 230, 230, 230}
+	msCoefs = [][2]int32{{256, 0}, {512, -256}, {0, 0}, {192, 64}, {240, 0}, {460, -208}, {392, -232}}
+)
+
+// adpcm reports whether the samples are ADPCM
+func (f waveFormat) adpcm() bool {
+	return f.tag == formatIMAADPCM || f.tag == formatMSADPCM
+}
+
+// headerSize returns the size of the header of a block
+func (f waveFormat) headerSize() int {
+	if f.tag == formatMSADPCM {
+		return 7 * f.channels
+	}
+	return 4 * f.channels
+}
+
+// blockFrames returns the frames in the first n bytes of a block: the
+// samples of the header, then 2 per byte. IMA ADPCM codes the samples of
+// each channel in groups of 8, so only whole groups count.
+func (f waveFormat) blockFrames(n int) int {
+	n -= f.headerSize()
+	switch {
+	case n < 0:
+		return 0
+	case f.tag == formatMSADPCM:
+		return 2 + 2*n/f.channels
+	}
+	return 1 + 8*(n/(4*f.channels))
+}
+
+// coefs returns the predictors of a Microsoft ADPCM file, those of its
+// fmt chunk or the 7 standard ones
+func (f waveFormat) coefs() [][2]int32 {
+	if len(f.extra) < 4 {
+		return msCoefs
+	}
+	n := int(binary.LittleEndian.Uint16(f.extra[2:4]))
+	if n == 0 || len(f.extra) < 4+4*n {
+		return msCoefs
+	}
+	coefs := make([][2]int32, n)
+	for i := range coefs {
+		b := f.extra[4+4*i:]
+		coefs[i] = [2]int32{
+			int32(int16(binary.LittleEndian.Uint16(b[0:2]))),
+			int32(int16(binary.LittleEndian.Uint16(b[2:4]))),
+		}
+	}
+	return coefs
+}
+
+// validateADPCM checks the block layout of an ADPCM format
+func (f waveFormat) validateADPCM() error {
+	if f.bitDepth != 4 {
+		return fmt.Errorf("%w: %d-bit ADPCM", ErrUnsupportedBitDepth, f.bitDepth)
+	}
+	if f.blockAlign <= f.headerSize() || f.blockFrames(f.blockAlign) < 2 {
+		return fmt.Errorf("%w: ADPCM blocks of %d bytes for %d channels", ErrUnsupportedWavLayout, f.blockAlign, f.channels)
+	}
+	return nil
+}
+
+// adpcmSource decodes the blocks of an ADPCM data chunk
+type adpcmSource struct {
+	data   *chunkWalker
+	format waveFormat
+	coefs  [][2]int32
+	size   int64 // data chunk size, possibly UnknownSize
+	loop   audio.LoopRegion
+	block  []byte
+	pcm    []int16 // samples of the current block
+	head   int     // start of the samples of pcm not returned yet
+	pos    int64   // frames returned
+	eof    bool
+}
+
+func newADPCMSource(r io.Reader, walker *chunkWalker, format waveFormat, size int64, loop audio.LoopRegion) (audio.Source, error) {
+	src := &adpcmSource{
+		data:   walker,
+		format: format,
+		size:   size,
+		loop:   loop,
+		block:  make([]byte, format.blockAlign),
+		pcm:    make([]int16, 0, format.blockFrames(format.blockAlign)*format.channels),
+	}
+	if format.tag == formatMSADPCM {
+		src.coefs = format.coefs()
+	}
+
+	if rs, ok := r.(io.ReadSeeker); ok {
+		// Pipes and sockets may implement Seeker and still fail
+		if start, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			if src.loop.End == 0 && src.size < UnknownSize {
+				var err error
+				if src.loop, err = trailingLoop(rs, start, src.size); err != nil {
+					return nil, err
+				}
+			}
+			return &adpcmSeekSource{adpcmSource: src, rs: rs, start: start}, nil
+		}
+	}
+	return src, nil
+}
+
+func (s *adpcmSource) SampleRate() int { return s.format.sampleRate }
+func (s *adpcmSource) Channels() int   { return s.format.channels }
+func (s *adpcmSource) Close() error    { return nil }
+func (s *adpcmSource) BufSize() int    { return cap(s.pcm) }
+
+// Format reports 16-bit samples, the precision ADPCM decodes to.
+func (s *adpcmSource) Format() audio.Format {
+	f := s.format.audioFormat()
+	f.SampleKind = audio.SampleInt16
+	return f
+}
+
+// TotalFrames returns the frame count of the fact chunk or, without one,
+// the frames of the data chunk; -1 when its size is marked as unknown.
+func (s *adpcmSource) TotalFrames() int64 {
+	if s.format.frames >= 0 {
+		return s.format.frames
+	}
+	if s.size >= UnknownSize {
+		return -1
+	}
+	align := int64(s.format.blockAlign)
+	return s.size/align*int64(s.format.blockFrames(s.format.blockAlign)) + int64(s.format.blockFrames(int(s.size%align)))
+}
+
+func (s *adpcmSource) Duration() time.Duration {
+	return audio.FramesDuration(s.TotalFrames(), s.format.sampleRate)
+}
+
+// Position returns the frames returned so far.
+func (s *adpcmSource) Position() int64 { return s.pos }
+
+// LoopPoints returns the first forward loop of the smpl chunk, as for PCM
+// files.
+func (s *adpcmSource) LoopPoints() (audio.LoopRegion, bool) {
+	return s.loop, s.loop.End > s.loop.Start
+}
+
+// ReadSamples decodes blocks into dst, reading no more blocks once it has
+// samples to return.
+func (s *adpcmSource) ReadSamples(dst []float32) (int, error) {
+	ch := s.format.channels
+	if len(dst)%ch != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+
+	n := 0
+	for n < len(dst) {
+		if s.head == len(s.pcm) {
+			if s.eof || n > 0 {
+				break
+			}
+			if err := s.next(); err != nil {
+				return n, err
+			}
+			continue
+		}
+		k := min(len(dst)-n, len(s.pcm)-s.head)
+		if total := s.format.frames; total >= 0 {
+			k = int(min(int64(k), (total-s.pos)*int64(ch)))
+		}
+		for i, v := range s.pcm[s.head : s.head+k] {
+			dst[n+i] = float32(v) / 32768
+		}
+		s.head += k
+		n += k
+		s.pos += int64(k / ch)
+
+		// The last block is padded up to the count of the fact chunk
+		if s.format.frames >= 0 && s.pos >= s.format.frames {
+			s.eof, s.head = true, len(s.pcm)
+		}
+	}
+	if s.eof && s.head == len(s.pcm) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// next decodes the next block
+func (s *adpcmSource) next() error {
+	m, err := io.ReadFull(s.data, s.block)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w", err)
+	}
+	if err != nil {
+		s.eof = true
+	}
+
+	s.pcm = s.pcm[:s.format.blockFrames(m)*s.format.channels]
+	s.head = 0
+	if s.format.tag == formatMSADPCM {
+		return decodeMS(s.block[:m], s.format.channels, s.coefs, s.pcm)
+	}
+	decodeIMA(s.block[:m], s.format.channels, s.pcm)
+	return nil
+}
+
+// decodeIMA decodes an IMA ADPCM block into pcm, sized to its frames.
+// After the header, each channel has 4 bytes in turn, 8 samples low nibble
+// first.
+func decodeIMA(block []byte, ch int, pcm []int16) {
+	if len(pcm) == 0 {
+		return
+	}
+	for c := range ch {
+		pred := int32(int16(binary.LittleEndian.Uint16(block[4*c:])))
+		index := min(int(block[4*c+2]), len(imaStep)-1)
+		pcm[c] = int16(pred)
+
+		for i := c; ch+i < len(pcm); i += ch {
+			// Sample i/ch+1 of the channel: group, byte in the group,
+			// nibble in the byte
+			k := i / ch
+			b := block[4*ch+(k/8*ch+c)*4+k%8/2]
+			code := b & 0x0F
+			if k%2 == 1 {
+				code = b >> 4
+			}
+
+			step := imaStep[index]
+			diff := step >> 3
+			if code&1 != 0 {
+				diff += step >> 2
+			}
+			if code&2 != 0 {
+				diff += step >> 1
+			}
+			if code&4 != 0 {
+				diff += step
+			}
+			if code&8 != 0 {
+				diff = -diff
+			}
+			pred = clamp16(pred + diff)
+			index = min(max(index+imaIndex[code], 0), len(imaStep)-1)
+			pcm[ch+i] = int16(pred)
+		}
+	}
+}
+
+// decodeMS decodes a Microsoft ADPCM block into pcm, sized to its frames.
+// The header holds, each for every channel, the predictor, the step and
+// the second and first samples; the codes follow high nibble first, one
+// channel after the other.
+func decodeMS(block []byte, ch int, coefs [][2]int32, pcm []int16) error {
+	if len(pcm) == 0 {
+		return nil
+	}
+
+	var (
+		coef   = make([][2]int32, ch)
+		delta  = make([]int32, ch)
+		s1, s2 = make([]int32, ch), make([]int32, ch)
+	)
+	for c := range ch {
+		p := int(block[c])
+		if p >= len(coefs) {
+			return fmt.Errorf("%w: MS ADPCM predictor %d of %d", ErrUnsupportedWavLayout, p, len(coefs))
+		}
+		coef[c] = coefs[p]
+		delta[c] = int32(int16(binary.LittleEndian.Uint16(block[ch+2*c:])))
+		s1[c] = int32(int16(binary.LittleEndian.Uint16(block[3*ch+2*c:])))
+		s2[c] = int32(int16(binary.LittleEndian.Uint16(block[5*ch+2*c:])))
+		pcm[c], pcm[ch+c] = int16(s2[c]), int16(s1[c])
+	}
+
+	codes := block[7*ch:]
+	for i := range len(pcm) - 2*ch {
+		c := i % ch
+		code := int32(codes[i/2] >> 4)
+		if i%2 == 1 {
+			code = int32(codes[i/2] & 0x0F)
+		}
+		signed := code
+		if signed >= 8 {
+			signed -= 16
+		}
+
+		pred := clamp16((s1[c]*coef[c][0]+s2[c]*coef[c][1])>>8 + signed*delta[c])
+		s2[c], s1[c] = s1[c], pred
+		delta[c] = max(msAdapt[code]*delta[c]>>8, 16)
+		pcm[2*ch+i] = int16(pred)
+	}
+	return nil
+}
+
+func clamp16(v int32) int32 { return min(max(v, -32768), 32767) }
+
+// adpcmSeekSource is an adpcmSource whose data chunk can be read at
+// random
+type adpcmSeekSource struct {
+	*adpcmSource
+	rs    io.ReadSeeker
+	start int64 // offset of the first block
+}
+
+// SeekFrame moves to frame n, decoding the block it falls in.
+func (s *adpcmSeekSource) SeekFrame(n int64) error {
+	if total := s.TotalFrames(); n < 0 || (total >= 0 && n > total) {
+		return fmt.Errorf("%w: frame %d", audio.ErrSeekOutOfRange, n)
+	}
+
+	perBlock := int64(s.format.blockFrames(s.format.blockAlign))
+	offset := n / perBlock * int64(s.format.blockAlign)
+	if _, err := s.rs.Seek(s.start+offset, io.SeekStart); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	s.data.left, s.data.pad = s.size-offset, 0
+	s.eof = false
+	s.pos = n
+	if err := s.next(); err != nil {
+		return err
+	}
+	s.head = min(int(n%perBlock)*s.format.channels, len(s.pcm))
+	if s.format.frames >= 0 && n >= s.format.frames {
+		s.eof, s.head = true, len(s.pcm)
+	}
+	return nil
+}
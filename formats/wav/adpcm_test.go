@@ -0,0 +1,320 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// adpcmFile encodes interleaved samples as an IMA or MS ADPCM WAV file in
+// blocks of align bytes, and returns it with the samples as a decoder
+// reconstructs them. A fact chunk is written when fact is set.
+func adpcmFile(tag uint16, channels, align int, samples []int16, fact bool) ([]byte, []int16) {
+	f := waveFormat{tag: tag, channels: channels, blockAlign: align}
+	perBlock := f.blockFrames(align)
+	frames := len(samples) / channels
+
+	var data []byte
+	var recon []int16
+	imaIdx := make([]int, channels)
+	for start := 0; start < frames; start += perBlock {
+		n := min(perBlock, frames-start)
+		block := samples[start*channels : (start+n)*channels]
+		if tag == formatIMAADPCM {
+			b, r := imaBlock(block, channels, imaIdx)
+			data, recon = append(data, b...), append(recon, r...)
+		} else {
+			b, r := msBlock(block, channels)
+			data, recon = append(data, b...), append(recon, r...)
+		}
+	}
+
+	body := binary.LittleEndian.AppendUint16(nil, tag)
+	body = binary.LittleEndian.AppendUint16(body, uint16(channels))
+	body = binary.LittleEndian.AppendUint32(body, 8000)
+	body = binary.LittleEndian.AppendUint32(body, uint32(8000*align/perBlock))
+	body = binary.LittleEndian.AppendUint16(body, uint16(align))
+	body = binary.LittleEndian.AppendUint16(body, 4)
+	if tag == formatIMAADPCM {
+		body = binary.LittleEndian.AppendUint16(body, 2)
+		body = binary.LittleEndian.AppendUint16(body, uint16(perBlock))
+	} else {
+		body = binary.LittleEndian.AppendUint16(body, 4+4*uint16(len(msCoefs)))
+		body = binary.LittleEndian.AppendUint16(body, uint16(perBlock))
+		body = binary.LittleEndian.AppendUint16(body, uint16(len(msCoefs)))
+		for _, c := range msCoefs {
+			body = binary.LittleEndian.AppendUint16(body, uint16(int16(c[0])))
+			body = binary.LittleEndian.AppendUint16(body, uint16(int16(c[1])))
+		}
+	}
+
+	chunks := [][]byte{riffChunk("fmt ", body)}
+	if fact {
+		chunks = append(chunks, riffChunk("fact", binary.LittleEndian.AppendUint32(nil, uint32(frames))))
+	}
+	chunks = append(chunks, riffChunk("data", data))
+	return riffFile(chunks...), recon[:len(samples)]
+}
+
+// imaBlock codes a block of IMA ADPCM, the last one padded to whole
+// groups of 8 samples, carrying the step index of each channel over
+func imaBlock(block []int16, ch int, idx []int) ([]byte, []int16) {
+	frames := len(block) / ch
+	groups := (frames - 1 + 7) / 8
+	out := make([]byte, 4*ch+4*ch*groups)
+	recon := make([]int16, (1+8*groups)*ch)
+	for c := range ch {
+		pred := int32(block[c])
+		binary.LittleEndian.PutUint16(out[4*c:], uint16(block[c]))
+		out[4*c+2] = byte(idx[c])
+		recon[c] = block[c]
+
+		for k := range 8 * groups {
+			var s int32
+			if f := 1 + k; f < frames {
+				s = int32(block[f*ch+c])
+			}
+			step := imaStep[idx[c]]
+			d := s - pred
+			var code byte
+			if d < 0 {
+				code, d = 8, -d
+			}
+			if d >= step {
+				code |= 4
+				d -= step
+			}
+			if d >= step>>1 {
+				code |= 2
+				d -= step >> 1
+			}
+			if d >= step>>2 {
+				code |= 1
+			}
+
+			diff := step >> 3
+			if code&1 != 0 {
+				diff += step >> 2
+			}
+			if code&2 != 0 {
+				diff += step >> 1
+			}
+			if code&4 != 0 {
+				diff += step
+			}
+			if code&8 != 0 {
+				diff = -diff
+			}
+			pred = clamp16(pred + diff)
+			idx[c] = min(max(idx[c]+imaIndex[code], 0), len(imaStep)-1)
+			recon[(1+k)*ch+c] = int16(pred)
+
+			at := 4*ch + (k/8*ch+c)*4 + k%8/2
+			out[at] |= code << (4 * (k % 2))
+		}
+	}
+	return out, recon
+}
+
+// msBlock codes a block of MS ADPCM, channel c with predictor c
+func msBlock(block []int16, ch int) ([]byte, []int16) {
+	frames := max(len(block)/ch, 2)
+	at := func(f, c int) int32 {
+		if f*ch+c < len(block) {
+			return int32(block[f*ch+c])
+		}
+		return 0
+	}
+
+	out := make([]byte, 7*ch+((frames-2)*ch+1)/2)
+	recon := make([]int16, frames*ch)
+	delta := make([]int32, ch)
+	s1, s2 := make([]int32, ch), make([]int32, ch)
+	for c := range ch {
+		out[c] = byte(c)
+		delta[c] = 16
+		s1[c], s2[c] = at(1, c), at(0, c)
+		binary.LittleEndian.PutUint16(out[ch+2*c:], uint16(delta[c]))
+		binary.LittleEndian.PutUint16(out[3*ch+2*c:], uint16(s1[c]))
+		binary.LittleEndian.PutUint16(out[5*ch+2*c:], uint16(s2[c]))
+		recon[c], recon[ch+c] = int16(s2[c]), int16(s1[c])
+	}
+	for i := range (frames - 2) * ch {
+		c := i % ch
+		coef := msCoefs[c]
+		pred := (s1[c]*coef[0] + s2[c]*coef[1]) >> 8
+		e := float64(at(2+i/ch, c)-pred) / float64(delta[c])
+		signed := int32(min(max(math.Round(e), -8), 7))
+		code := signed & 0x0F
+
+		pred = clamp16(pred + signed*delta[c])
+		s2[c], s1[c] = s1[c], pred
+		delta[c] = max(msAdapt[code]*delta[c]>>8, 16)
+		recon[2*ch+i] = int16(pred)
+		if i%2 == 0 {
+			out[7*ch+i/2] |= byte(code) << 4
+		} else {
+			out[7*ch+i/2] |= byte(code)
+		}
+	}
+	return out, recon
+}
+
+// sine returns frames of interleaved 16-bit sines, a different one on
+// each channel
+func sine(frames, channels int) []int16 {
+	out := make([]int16, frames*channels)
+	for i := range out {
+		f := 300 * float64(1+i%channels)
+		out[i] = int16(16000 * math.Sin(2*math.Pi*f*float64(i/channels)/8000))
+	}
+	return out
+}
+
+func TestDecoder_ADPCM(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		tag      uint16
+		channels int
+		align    int
+		frames   int
+		fact     bool
+	}{
+		{name: "IMA mono", tag: formatIMAADPCM, channels: 1, align: 256, frames: 2000, fact: true},
+		{name: "IMA stereo", tag: formatIMAADPCM, channels: 2, align: 512, frames: 2000, fact: true},
+		{name: "IMA, whole blocks without fact", tag: formatIMAADPCM, channels: 1, align: 256, frames: 505 * 3},
+		{name: "MS mono", tag: formatMSADPCM, channels: 1, align: 256, frames: 2000, fact: true},
+		{name: "MS stereo", tag: formatMSADPCM, channels: 2, align: 512, frames: 2001, fact: true},
+		{name: "MS, whole blocks without fact", tag: formatMSADPCM, channels: 2, align: 512, frames: 500 * 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			in := sine(tt.frames, tt.channels)
+			data, want := adpcmFile(tt.tag, tt.channels, tt.align, in, tt.fact)
+
+			src, err := Decoder{}.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if f := audio.FormatOf(src); f.Channels != tt.channels || f.Rate != 8000 || f.SampleKind != audio.SampleInt16 {
+				t.Errorf("Format() = %v", f)
+			}
+			if n := src.(audio.Lengther).TotalFrames(); n != int64(tt.frames) {
+				t.Errorf("TotalFrames() = %d, want %d", n, tt.frames)
+			}
+
+			got := readAllSamples(t, src, 6*tt.channels)
+			if len(got) != len(want) {
+				t.Fatalf("decoded %d samples, want %d", len(got), len(want))
+			}
+			var signal, noise float64
+			for i, v := range got {
+				if v != float32(want[i])/32768 {
+					t.Fatalf("sample %d = %v, want %v", i, v*32768, want[i])
+				}
+				d := float64(in[i]) - float64(want[i])
+				signal += float64(in[i]) * float64(in[i])
+				noise += d * d
+			}
+			if snr := 10 * math.Log10(signal/noise); snr < 15 {
+				t.Errorf("SNR = %.1f dB, want at least 15", snr)
+			}
+		})
+	}
+}
+
+func TestDecoder_ADPCMSeek(t *testing.T) {
+	t.Parallel()
+
+	for _, tag := range []uint16{formatIMAADPCM, formatMSADPCM} {
+		data, want := adpcmFile(tag, 2, 256, sine(1000, 2), true)
+		src, err := Decoder{}.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		s := src.(audio.Seeker)
+
+		for _, frame := range []int64{700, 0, 249, 250, 999, 1000} {
+			if err := s.SeekFrame(frame); err != nil {
+				t.Fatalf("tag %d: SeekFrame(%d) error = %v", tag, frame, err)
+			}
+			if pos := s.Position(); pos != frame {
+				t.Errorf("tag %d: Position() = %d, want %d", tag, pos, frame)
+			}
+			got := readAllSamples(t, src, 64)
+			if len(got) != len(want)-2*int(frame) {
+				t.Fatalf("tag %d: read %d samples from frame %d, want %d", tag, len(got), frame, len(want)-2*int(frame))
+			}
+			for i, v := range got {
+				if v != float32(want[2*int(frame)+i])/32768 {
+					t.Fatalf("tag %d: sample %d from frame %d = %v, want %v", tag, i, frame, v*32768, want[2*int(frame)+i])
+				}
+			}
+		}
+		if err := s.SeekFrame(1001); !errors.Is(err, audio.ErrSeekOutOfRange) {
+			t.Errorf("tag %d: SeekFrame(past the end) error = %v, want ErrSeekOutOfRange", tag, err)
+		}
+	}
+}
+
+func TestDecoder_ADPCMErrors(t *testing.T) {
+	t.Parallel()
+
+	data, _ := adpcmFile(formatIMAADPCM, 1, 256, sine(100, 1), false)
+	fmtAt := bytes.Index(data, []byte("fmt ")) + 8
+
+	bits := bytes.Clone(data)
+	bits[fmtAt+14] = 3
+	if _, err := (Decoder{}).Decode(bytes.NewReader(bits)); !errors.Is(err, ErrUnsupportedBitDepth) {
+		t.Errorf("Decode(3-bit) error = %v, want ErrUnsupportedBitDepth", err)
+	}
+
+	align := bytes.Clone(data)
+	binary.LittleEndian.PutUint16(align[fmtAt+12:], 4)
+	if _, err := (Decoder{}).Decode(bytes.NewReader(align)); !errors.Is(err, ErrUnsupportedWavLayout) {
+		t.Errorf("Decode(4-byte blocks) error = %v, want ErrUnsupportedWavLayout", err)
+	}
+
+	ms, _ := adpcmFile(formatMSADPCM, 1, 256, sine(100, 1), false)
+	ms[bytes.Index(ms, []byte("data"))+8] = 7 // predictor past the 7 listed
+	src, err := Decoder{}.Decode(bytes.NewReader(ms))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if _, err := src.ReadSamples(make([]float32, 64)); !errors.Is(err, ErrUnsupportedWavLayout) {
+		t.Errorf("ReadSamples(bad predictor) error = %v, want ErrUnsupportedWavLayout", err)
+	}
+	if _, err := src.ReadSamples(make([]float32, 64)); !errors.Is(err, io.EOF) && err != nil {
+		t.Errorf("ReadSamples() after error = %v", err)
+	}
+}
+
+// readAllSamples reads src to the end in reads of chunk samples
+func readAllSamples(t *testing.T, src audio.Source, chunk int) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, chunk)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
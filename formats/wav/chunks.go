@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Chunk IDs the decoder acts on; everything else (LIST, INFO, JUNK, bext,
+// cue, ...) is skipped.
+var (
+	idRIFF = [4]byte{'R', 'I', 'F', 'F'}
+	idWAVE = [4]byte{'W', 'A', 'V', 'E'}
+	idFmt  = [4]byte{'f', 'm', 't', ' '}
+	idFact = [4]byte{'f', 'a', 'c', 't'}
+	idData = [4]byte{'d', 'a', 't', 'a'}
+)
+
+// maxFmtSize bounds the fmt chunk read into memory. The largest standard
+// layout (WAVE_FORMAT_EXTENSIBLE) is 40 bytes.
+const maxFmtSize = 1024
+
+// chunkWalker iterates over the chunks of a RIFF WAVE stream. Reading from
+// the walker reads the body of the current chunk; next skips whatever is
+// left of it, including the pad byte of odd sized chunks.
+type chunkWalker struct {
+	r    io.Reader
+	left int64 // unread body bytes of the current chunk
+	pad  int64 // 1 when the current chunk has an odd size
+}
+
+// newChunkWalker checks the RIFF/WAVE preamble of r.
+func newChunkWalker(r io.Reader) (*chunkWalker, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNotWavFile
+		}
+		return nil, fmt.Errorf("reading RIFF header: %w", err)
+	}
+	if [4]byte(hdr[0:4]) != idRIFF || [4]byte(hdr[8:12]) != idWAVE {
+		return nil, ErrNotWavFile
+	}
+
+	// The RIFF size is not trusted: streamed files carry 0 or 0xFFFFFFFF
+	return &chunkWalker{r: r}, nil
+}
+
+// next skips the rest of the current chunk and returns the ID and size of
+// the following one. It returns io.EOF when there are no more chunks.
+func (w *chunkWalker) next() ([4]byte, uint32, error) {
+	if err := w.skip(w.left + w.pad); err != nil {
+		return [4]byte{}, 0, err
+	}
+
+	var hdr [8]byte
+	if _, err := io.ReadFull(w.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return [4]byte{}, 0, fmt.Errorf("reading chunk header: %w", err)
+		}
+		return [4]byte{}, 0, err
+	}
+
+	size := binary.LittleEndian.Uint32(hdr[4:8])
+	w.left = int64(size)
+	w.pad = int64(size & 1)
+	return [4]byte(hdr[0:4]), size, nil
+}
+
+// enterRIFF reads the form type of a RIFF chunk whose header next just
+// returned, so that next continues with its sub-chunks. It is how the
+// walker steps into a WAV file concatenated after the current one.
+func (w *chunkWalker) enterRIFF() error {
+	var form [4]byte
+	if _, err := io.ReadFull(w.r, form[:]); err != nil || form != idWAVE {
+		return fmt.Errorf("%w: concatenated RIFF chunk", ErrNotWavFile)
+	}
+	w.left, w.pad = 0, 0
+	return nil
+}
+
+// Read reads from the body of the current chunk.
+func (w *chunkWalker) Read(p []byte) (int, error) {
+	if w.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > w.left {
+		p = p[:w.left]
+	}
+	n, err := w.r.Read(p)
+	w.left -= int64(n)
+	return n, err
+}
+
+// skip discards n bytes, seeking when the underlying reader allows it
+func (w *chunkWalker) skip(n int64) error {
+	w.left, w.pad = 0, 0
+	if n == 0 {
+		return nil
+	}
+
+	if s, ok := w.r.(io.Seeker); ok {
+		if _, err := s.Seek(n, io.SeekCurrent); err == nil {
+			return nil
+		}
+		// Pipes and sockets may implement Seeker and still fail
+	}
+
+	if _, err := io.CopyN(io.Discard, w.r, n); err != nil {
+		if errors.Is(err, io.EOF) {
+			// A truncated trailing chunk just ends the file
+			return io.EOF
+		}
+		return fmt.Errorf("skipping chunk: %w", err)
+	}
+	return nil
+}
+
+// waveFormat is the decoded content of a fmt chunk
+type waveFormat struct {
+	tag        uint16 // formatPCM, formatIEEEFloat or ADPCM, resolved for EXTENSIBLE
+	channels   int
+	sampleRate int
+	bitDepth   int
+	blockAlign int
+	extra      []byte // the extension after cbSize, for ADPCM
+	frames     int64  // frame count of the fact chunk, -1 without one
+	layout     audio.Layout
+}
+
+// parseFmt decodes a fmt chunk body of the given size.
+func parseFmt(r io.Reader, size uint32) (waveFormat, error) {
+	if size < 16 || size > maxFmtSize {
+		return waveFormat{}, fmt.Errorf("%w: fmt chunk of %d bytes", ErrUnsupportedWavLayout, size)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return waveFormat{}, fmt.Errorf("reading fmt chunk: %w", err)
+	}
+
+	f := waveFormat{
+		tag:        binary.LittleEndian.Uint16(buf[0:2]),
+		channels:   int(binary.LittleEndian.Uint16(buf[2:4])),
+		sampleRate: int(binary.LittleEndian.Uint32(buf[4:8])),
+		bitDepth:   int(binary.LittleEndian.Uint16(buf[14:16])),
+		blockAlign: int(binary.LittleEndian.Uint16(buf[12:14])),
+		frames:     -1,
+	}
+	f.layout = audio.DefaultLayout(f.channels)
+	if size >= 18 {
+		cb := int(binary.LittleEndian.Uint16(buf[16:18]))
+		f.extra = buf[18:min(18+cb, len(buf))]
+	}
+
+	// WAVE_FORMAT_EXTENSIBLE stores the real format tag in the first two
+	// bytes of the sub-format GUID
+	if f.tag == formatExtensible {
+		if size < 40 {
+			return waveFormat{}, fmt.Errorf("%w: short extensible fmt chunk", ErrUnsupportedWavLayout)
+		}
+		f.tag = binary.LittleEndian.Uint16(buf[24:26])
+		if mask := binary.LittleEndian.Uint32(buf[20:24]); mask != 0 {
+			f.layout = maskLayout(mask, f.channels)
+		}
+	}
+
+	if f.channels == 0 || f.sampleRate == 0 {
+		return waveFormat{}, fmt.Errorf("%w: %d channels at %d Hz", ErrUnsupportedWavLayout, f.channels, f.sampleRate)
+	}
+
+	return f, nil
+}
+
+// maskLayout returns the layout of the channels of a dwChannelMask. They
+// take its lowest bits in order; channels past the bits set have no
+// position, which leaves the layout unknown.
+func maskLayout(mask uint32, channels int) audio.Layout {
+	labels := audio.LayoutFromMask(mask).Labels()
+	if len(labels) < channels {
+		return audio.LayoutUnknown
+	}
+
+	var l audio.Layout
+	for _, c := range labels[:channels] {
+		l |= audio.Layout(c)
+	}
+	return l
+}
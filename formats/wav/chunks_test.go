@@ -0,0 +1,437 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// riffChunk renders one chunk with its pad byte
+func riffChunk(id string, body []byte) []byte {
+	out := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	out = append(out, body...)
+	if len(body)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// riffFile wraps chunks in a RIFF WAVE preamble
+func riffFile(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	out := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(out, body...)
+}
+
+// fmtBody renders a 16 byte fmt chunk body for 16-bit PCM
+func fmtBody(rate, channels int) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, formatPCM)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate*channels*2))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*2))
+	return binary.LittleEndian.AppendUint16(b, 16)
+}
+
+// extensibleFmtBody renders a WAVE_FORMAT_EXTENSIBLE fmt chunk body
+func extensibleFmtBody(rate, channels, bits int, tag uint16) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, formatExtensible)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(rate*channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+	b = binary.LittleEndian.AppendUint16(b, 22)           // cbSize
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits)) // valid bits
+	b = binary.LittleEndian.AppendUint32(b, 0x3)          // FL | FR
+	b = binary.LittleEndian.AppendUint16(b, tag)
+	// Remainder of the KSDATAFORMAT_SUBTYPE GUID
+	return append(b, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71)
+}
+
+func TestDecoder_ChunkWalk(t *testing.T) {
+	t.Parallel()
+
+	pcm := []byte{0x00, 0x40, 0x00, 0xc0, 0xff, 0x7f} // 0.5, -0.5, ~1
+	want := []float32{0.5, -0.5, 32767.0 / 32768}
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{
+			name: "canonical",
+			file: riffFile(riffChunk("fmt ", fmtBody(8000, 1)), riffChunk("data", pcm)),
+		},
+		{
+			name: "ffmpeg LIST before data",
+			file: riffFile(
+				riffChunk("fmt ", fmtBody(8000, 1)),
+				riffChunk("LIST", append([]byte("INFO"), riffChunk("ISFT", []byte("Lavf61.7.100\x00"))...)),
+				riffChunk("data", pcm),
+			),
+		},
+		{
+			name: "JUNK before fmt and fact after",
+			file: riffFile(
+				riffChunk("JUNK", make([]byte, 28)),
+				riffChunk("fmt ", fmtBody(8000, 1)),
+				riffChunk("fact", []byte{3, 0, 0, 0}),
+				riffChunk("data", pcm),
+			),
+		},
+		{
+			name: "odd sized chunks",
+			file: riffFile(
+				riffChunk("bext", []byte{1, 2, 3}),
+				riffChunk("fmt ", fmtBody(8000, 1)),
+				riffChunk("cue ", []byte{1}),
+				riffChunk("data", pcm),
+			),
+		},
+		{
+			name: "trailing chunks after data",
+			file: riffFile(
+				riffChunk("fmt ", fmtBody(8000, 1)),
+				riffChunk("data", pcm),
+				riffChunk("LIST", []byte("INFOjunk")),
+			),
+		},
+		{
+			name: "fmt chunk with extra bytes",
+			file: riffFile(riffChunk("fmt ", append(fmtBody(8000, 1), 0, 0)), riffChunk("data", pcm)),
+		},
+		{
+			name: "extensible PCM",
+			file: riffFile(riffChunk("fmt ", extensibleFmtBody(8000, 1, 16, formatPCM)), riffChunk("data", pcm)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Non-seekable input delivered a few bytes at a time
+			r := audiotest.NewFaultyReader(bytes.NewBuffer(tt.file), audiotest.WithShortReads(5))
+			src, err := Decoder{}.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if src.SampleRate() != 8000 || src.Channels() != 1 {
+				t.Errorf("format = %d Hz %d ch, want 8000 Hz mono", src.SampleRate(), src.Channels())
+			}
+
+			buf := make([]float32, 8)
+			n, err := src.ReadSamples(buf)
+			if err != nil && err != io.EOF {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+			if n != len(want) {
+				t.Fatalf("ReadSamples() = %d samples, want %d", n, len(want))
+			}
+			for i := range want {
+				if buf[i] != want[i] {
+					t.Errorf("sample %d = %v, want %v", i, buf[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_ChunkErrors(t *testing.T) {
+	t.Parallel()
+
+	pcm := make([]byte, 4)
+
+	tests := []struct {
+		name    string
+		file    []byte
+		wantErr error
+	}{
+		{
+			name:    "no data chunk",
+			file:    riffFile(riffChunk("fmt ", fmtBody(8000, 1)), riffChunk("LIST", []byte("INFO"))),
+			wantErr: ErrUnsupportedWavChunks,
+		},
+		{
+			name:    "data before fmt",
+			file:    riffFile(riffChunk("data", pcm), riffChunk("fmt ", fmtBody(8000, 1))),
+			wantErr: ErrUnsupportedWavChunks,
+		},
+		{
+			name:    "short fmt chunk",
+			file:    riffFile(riffChunk("fmt ", fmtBody(8000, 1)[:14]), riffChunk("data", pcm)),
+			wantErr: ErrUnsupportedWavLayout,
+		},
+		{
+			name:    "zero channels",
+			file:    riffFile(riffChunk("fmt ", fmtBody(8000, 0)), riffChunk("data", pcm)),
+			wantErr: ErrUnsupportedWavLayout,
+		},
+		{
+			name:    "short extensible fmt chunk",
+			file:    riffFile(riffChunk("fmt ", extensibleFmtBody(8000, 1, 16, formatPCM)[:30]), riffChunk("data", pcm)),
+			wantErr: ErrUnsupportedWavLayout,
+		},
+		{
+			name:    "truncated chunk header",
+			file:    append(riffFile(riffChunk("fmt ", fmtBody(8000, 1))), 'd', 'a'),
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated fmt chunk",
+			file:    riffFile(riffChunk("fmt ", fmtBody(8000, 1)))[:30],
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := (Decoder{}).Decode(bytes.NewReader(tt.file)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecoder_ExtensibleFloat(t *testing.T) {
+	t.Parallel()
+
+	data := binary.LittleEndian.AppendUint32(nil, 0x3e800000) // 0.25
+	data = binary.LittleEndian.AppendUint32(data, 0xbf000000) // -0.5
+	file := riffFile(riffChunk("fmt ", extensibleFmtBody(48000, 2, 32, formatIEEEFloat)), riffChunk("data", data))
+
+	src, err := Decoder{}.Decode(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	buf := make([]float32, 2)
+	if n, err := src.ReadSamples(buf); n != 2 || (err != nil && err != io.EOF) || buf[0] != 0.25 || buf[1] != -0.5 {
+		t.Errorf("ReadSamples() = %d %v %v, want [0.25 -0.5]", n, buf, err)
+	}
+}
+
+func TestDecoder_ChannelMask(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		mask uint32
+		want audio.Layout
+	}{
+		{name: "5.1 side", mask: 0x60F, want: audio.Layout5_1Side},
+		{name: "no mask", want: audio.Layout5_1},
+		{name: "extra bits", mask: 0x3F | 0x600, want: audio.Layout5_1},
+		{name: "missing bits", mask: 0x3, want: audio.LayoutUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fmtChunk := extensibleFmtBody(8000, 6, 16, formatPCM)
+			binary.LittleEndian.PutUint32(fmtChunk[20:24], tt.mask)
+			file := riffFile(riffChunk("fmt ", fmtChunk), riffChunk("data", make([]byte, 12)))
+
+			src, err := Decoder{}.Decode(bytes.NewReader(file))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got := audio.FormatOf(src).Layout; got != tt.want {
+				t.Errorf("Layout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoder_Downmix5_1(t *testing.T) {
+	t.Parallel()
+
+	// Frame k holds 0.5 in channel k alone, of FL FR C BL BR BC: no LFE,
+	// where 6 channels are usually 5.1
+	var data []byte
+	for k := range 6 {
+		for c := range 6 {
+			v := uint16(0)
+			if c == k {
+				v = 0x4000
+			}
+			data = binary.LittleEndian.AppendUint16(data, v)
+		}
+	}
+	fmtChunk := extensibleFmtBody(8000, 6, 16, formatPCM)
+	binary.LittleEndian.PutUint32(fmtChunk[20:24], 0x137)
+	src, err := Decoder{}.Decode(bytes.NewReader(riffFile(riffChunk("fmt ", fmtChunk), riffChunk("data", data))))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	got := make([]float32, 6)
+	if n, err := audio.NewMonoMixer(src).ReadSamples(got); n != 6 || (err != nil && err != io.EOF) {
+		t.Fatalf("ReadSamples() = %d, %v", n, err)
+	}
+
+	// Fronts at unity and surrounds at -3 dB
+	front := 0.5 / (3 + 3/math.Sqrt2)
+	back := front / math.Sqrt2
+	want := []float64{front, front, front, back, back, back}
+	for i := range want {
+		if math.Abs(float64(got[i])-want[i]) > 1e-6 {
+			t.Errorf("mono sample of channel %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecoder_Concatenated(t *testing.T) {
+	t.Parallel()
+
+	mono := riffFile(riffChunk("fmt ", fmtBody(8000, 1)), riffChunk("data", []byte{0x00, 0x40, 0x00, 0xc0})) // 0.5, -0.5
+	mono2 := riffFile(riffChunk("fmt ", fmtBody(8000, 1)), riffChunk("data", []byte{0x00, 0x20}))            // 0.25
+	stereo := riffFile(riffChunk("fmt ", fmtBody(16000, 2)), riffChunk("data", []byte{0x00, 0x20, 0x00, 0xe0}))
+	trailer := riffChunk("LIST", []byte("INFOtrailing"))
+
+	tests := []struct {
+		name string
+		file []byte
+		// want holds the samples of each link, split where ReadSamples
+		// reported a format change
+		want      [][]float32
+		wantFrame int64
+	}{
+		{
+			name:      "single file with trailing chunk",
+			file:      append(bytes.Clone(mono), trailer...),
+			want:      [][]float32{{0.5, -0.5}},
+			wantFrame: 2,
+		},
+		{
+			name:      "stray bytes after the data",
+			file:      append(bytes.Clone(mono), 0, 0, 0),
+			want:      [][]float32{{0.5, -0.5}},
+			wantFrame: 2,
+		},
+		{
+			name:      "same format joins",
+			file:      slices.Concat(mono, trailer, mono2),
+			want:      [][]float32{{0.5, -0.5, 0.25}},
+			wantFrame: 3,
+		},
+		{
+			name:      "format change",
+			file:      slices.Concat(mono, stereo, mono2),
+			want:      [][]float32{{0.5, -0.5}, {0.25, -0.25}, {0.25}},
+			wantFrame: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := Decoder{}.Decode(struct{ io.Reader }{bytes.NewReader(tt.file)})
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			got := [][]float32{nil}
+			buf := make([]float32, 4)
+			for {
+				n, err := src.ReadSamples(buf[:4-4%src.Channels()])
+				got[len(got)-1] = append(got[len(got)-1], buf[:n]...)
+
+				var fc *audio.FormatChangedError
+				if errors.As(err, &fc) {
+					if fc.To != audio.FormatOf(src) {
+						t.Errorf("FormatChangedError.To = %v, source now %v", fc.To, audio.FormatOf(src))
+					}
+					got = append(got, nil)
+					continue
+				}
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("samples = %v, want %v", got, tt.want)
+			}
+			if p := src.(interface{ Position() int64 }).Position(); p != tt.wantFrame {
+				t.Errorf("Position() = %d, want %d", p, tt.wantFrame)
+			}
+		})
+	}
+}
+
+func TestSource_SeekFrameConcatenated(t *testing.T) {
+	t.Parallel()
+
+	mono := riffFile(riffChunk("fmt ", fmtBody(8000, 1)), riffChunk("data", []byte{0x00, 0x40, 0x00, 0xc0}))
+	stereo := riffFile(riffChunk("fmt ", fmtBody(16000, 2)), riffChunk("data", []byte{0x00, 0x20, 0x00, 0xe0}))
+
+	src, err := Decoder{}.Decode(bytes.NewReader(slices.Concat(mono, stereo)))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	buf := make([]float32, 4)
+	if _, err := src.ReadSamples(buf); !errors.Is(err, audio.ErrFormatChanged) {
+		t.Fatalf("ReadSamples() error = %v, want ErrFormatChanged", err)
+	}
+	if src.Channels() != 2 {
+		t.Fatalf("Channels() = %d after the change, want 2", src.Channels())
+	}
+
+	// Seeking returns to the first file and its format
+	if err := src.(audio.Seeker).SeekFrame(1); err != nil {
+		t.Fatalf("SeekFrame() error = %v", err)
+	}
+	if src.Channels() != 1 || src.SampleRate() != 8000 {
+		t.Errorf("format after seek = %v, want the first file's", audio.FormatOf(src))
+	}
+	if n, _ := src.ReadSamples(buf[:1]); n != 1 || buf[0] != -0.5 {
+		t.Errorf("ReadSamples() after seek = %v, want [-0.5]", buf[:n])
+	}
+}
+
+func TestDecoder_ConcatenatedADPCM(t *testing.T) {
+	t.Parallel()
+
+	mono := riffFile(riffChunk("fmt ", fmtBody(8000, 1)), riffChunk("data", []byte{0x00, 0x40, 0x00, 0xc0}))
+	for _, tag := range []uint16{formatIMAADPCM, formatMSADPCM} {
+		adpcm, _ := adpcmFile(tag, 1, 256, make([]int16, 1000), true)
+
+		src, err := Decoder{}.Decode(struct{ io.Reader }{bytes.NewReader(slices.Concat(mono, adpcm))})
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+
+		// The PCM file is read, then the ADPCM one ends the stream
+		buf := make([]float32, 64)
+		n, err := src.ReadSamples(buf)
+		if n != 2 || !errors.Is(err, ErrUnsupportedWavLayout) {
+			t.Errorf("format %#x: ReadSamples() = %d, %v, want 2, ErrUnsupportedWavLayout", tag, n, err)
+		}
+		if l := src.(audio.Lengther).TotalFrames(); l != 2 {
+			t.Errorf("format %#x: TotalFrames() = %d, want 2", tag, l)
+		}
+	}
+}
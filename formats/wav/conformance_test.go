@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	gowav "github.com/go-audio/wav"
+	"github.com/ik5/audpbx/audio"
+)
+
+// Encoder bitstream conformance: every file produced by the writers in this
+// package must decode sample-exactly both with this package's Decoder and
+// with an independent reference decoder (go-audio/wav), so the writers
+// cannot drift into producing files other players reject.
+
+// conformanceSignals are int16 patterns that stress sign handling, the
+// extremes and odd lengths
+var conformanceSignals = map[string]func(n int) []int16{
+	"extremes": func(n int) []int16 {
+		out := make([]int16, n)
+		for i := range out {
+			switch i % 4 {
+			case 0:
+				out[i] = math.MaxInt16
+			case 1:
+				out[i] = math.MinInt16
+			case 2:
+				out[i] = 0
+			default:
+				out[i] = -1
+			}
+		}
+		return out
+	},
+	"ramp": func(n int) []int16 {
+		out := make([]int16, n)
+		for i := range out {
+			out[i] = int16(i*131 - 30000)
+		}
+		return out
+	},
+	"sine": func(n int) []int16 {
+		out := make([]int16, n)
+		for i := range out {
+			out[i] = int16(20000 * math.Sin(float64(i)*0.1))
+		}
+		return out
+	},
+}
+
+// referenceDecode decodes data with go-audio/wav, returning the raw integer
+// samples and the reported format
+func referenceDecode(t *testing.T, data []byte) ([]int, int, int) {
+	t.Helper()
+
+	dec := gowav.NewDecoder(bytes.NewReader(data))
+	if !dec.IsValidFile() {
+		t.Fatal("reference decoder rejected the file")
+	}
+
+	buf, err := dec.FullPCMBuffer()
+	if err != nil {
+		t.Fatalf("reference FullPCMBuffer() error = %v", err)
+	}
+	if dec.WavAudioFormat != 1 || dec.BitDepth != 16 {
+		t.Fatalf("reference sees format %d / %d bit, want PCM / 16 bit", dec.WavAudioFormat, dec.BitDepth)
+	}
+
+	return buf.Data, int(dec.SampleRate), int(dec.NumChans)
+}
+
+// ownDecode decodes data with this package's Decoder back to int16
+func ownDecode(t *testing.T, data []byte) ([]int16, audio.Source) {
+	t.Helper()
+
+	src, err := Decoder{}.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	var out []int16
+	buf := make([]float32, 1000)
+	for {
+		n, err := src.ReadSamples(buf)
+		for _, v := range buf[:n] {
+			out = append(out, int16(math.Round(float64(v)*32768)))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+
+	return out, src
+}
+
+func TestConformance_WriteWAV16(t *testing.T) {
+	t.Parallel()
+
+	for name, gen := range conformanceSignals {
+		for _, rate := range []int{8000, 16000, 44100} {
+			for _, n := range []int{1, 999, 20000} {
+				samples := gen(n)
+
+				var buf bytes.Buffer
+				if err := WriteWAV16(&buf, rate, samples); err != nil {
+					t.Fatalf("%s/%d/%d: WriteWAV16() error = %v", name, rate, n, err)
+				}
+
+				ref, refRate, refChans := referenceDecode(t, buf.Bytes())
+				if refRate != rate || refChans != 1 {
+					t.Errorf("%s/%d/%d: reference sees %d Hz %d ch", name, rate, n, refRate, refChans)
+				}
+				if len(ref) != n {
+					t.Fatalf("%s/%d/%d: reference decoded %d samples", name, rate, n, len(ref))
+				}
+
+				own, src := ownDecode(t, buf.Bytes())
+				if src.SampleRate() != rate || src.Channels() != 1 {
+					t.Errorf("%s/%d/%d: decoder sees %d Hz %d ch", name, rate, n, src.SampleRate(), src.Channels())
+				}
+				if len(own) != n {
+					t.Fatalf("%s/%d/%d: decoder produced %d samples", name, rate, n, len(own))
+				}
+
+				for i, want := range samples {
+					if ref[i] != int(want) {
+						t.Fatalf("%s/%d/%d: reference sample %d = %d, want %d", name, rate, n, i, ref[i], want)
+					}
+					if own[i] != want {
+						t.Fatalf("%s/%d/%d: decoded sample %d = %d, want %d", name, rate, n, i, own[i], want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestConformance_WriteWAV16Interleaved(t *testing.T) {
+	t.Parallel()
+
+	for _, channels := range []int{2, 6} {
+		samples := conformanceSignals["ramp"](600 * channels)
+
+		var buf bytes.Buffer
+		if err := WriteWAV16Interleaved(&buf, 48000, channels, samples); err != nil {
+			t.Fatalf("%d ch: WriteWAV16Interleaved() error = %v", channels, err)
+		}
+
+		ref, refRate, refChans := referenceDecode(t, buf.Bytes())
+		if refRate != 48000 || refChans != channels || len(ref) != len(samples) {
+			t.Fatalf("%d ch: reference sees %d Hz %d ch, %d samples", channels, refRate, refChans, len(ref))
+		}
+		own, _ := ownDecode(t, buf.Bytes())
+		for i, want := range samples {
+			if ref[i] != int(want) || own[i] != want {
+				t.Fatalf("%d ch: sample %d = %d (reference) / %d (decoder), want %d", channels, i, ref[i], own[i], want)
+			}
+		}
+	}
+}
+
+func TestConformance_WriteWAV16Split(t *testing.T) {
+	t.Parallel()
+
+	left := conformanceSignals["ramp"](500)
+	right := conformanceSignals["sine"](500)
+	src := &int16Source{rate: 8000, channels: 2}
+	for i := range left {
+		src.data = append(src.data, left[i], right[i])
+	}
+
+	var l, r bytes.Buffer
+	if err := WriteWAV16Split(src, &l, &r); err != nil {
+		t.Fatalf("WriteWAV16Split() error = %v", err)
+	}
+
+	for _, leg := range []struct {
+		name string
+		data []byte
+		want []int16
+	}{
+		{"left", l.Bytes(), left},
+		{"right", r.Bytes(), right},
+	} {
+		ref, _, chans := referenceDecode(t, leg.data)
+		if chans != 1 || len(ref) != len(leg.want) {
+			t.Fatalf("%s: reference sees %d ch, %d samples", leg.name, chans, len(ref))
+		}
+		for i, want := range leg.want {
+			if ref[i] != int(want) {
+				t.Fatalf("%s: reference sample %d = %d, want %d", leg.name, i, ref[i], want)
+			}
+		}
+	}
+}
+
+// int16Source replays exact int16 values as an audio.Source
+type int16Source struct {
+	rate, channels int
+	data           []int16
+	pos            int
+}
+
+func (s *int16Source) SampleRate() int { return s.rate }
+func (s *int16Source) Channels() int   { return s.channels }
+func (s *int16Source) BufSize() int    { return 4096 }
+func (s *int16Source) Close() error    { return nil }
+
+func (s *int16Source) ReadSamples(dst []float32) (int, error) {
+	if s.pos >= len(s.data) {
+		return 0, io.EOF
+	}
+	n := min(len(dst), len(s.data)-s.pos)
+	for i := range n {
+		dst[i] = float32(s.data[s.pos+i]) / 32768
+	}
+	s.pos += n
+	return n, nil
+}
@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Chunk IDs of cue points and their labels
+var (
+	idCue  = [4]byte{'c', 'u', 'e', ' '}
+	idList = [4]byte{'L', 'I', 'S', 'T'}
+	idAdtl = [4]byte{'a', 'd', 't', 'l'}
+	idLabl = [4]byte{'l', 'a', 'b', 'l'}
+	idLtxt = [4]byte{'l', 't', 'x', 't'}
+	idRgn  = [4]byte{'r', 'g', 'n', ' '}
+)
+
+// maxCueChunkSize bounds the cue and LIST chunks ReadCues reads into
+// memory
+const maxCueChunkSize = 1 << 20
+
+// Cue is a marked point or region of a recording, such as a DTMF digit,
+// a hold segment or a change of speaker. Players list cues by their label
+// and jump to them.
+type Cue struct {
+	Frame  int64  // sample frame the cue is at
+	Length int64  // frames of a region, 0 for a point
+	Label  string // shown by players, may be empty
+}
+
+// AddCue marks c in the file, stored as a cue point, with its label and
+// length in an associated data list, after the samples. The frame may be
+// ahead of or behind what has been written so far, so events can be added
+// as they are detected.
+//
+// Cues are written by Close, once the sizes in the header can be filled
+// in, so they need a writer that can seek: AddCue fails with
+// ErrCuesNeedSeek otherwise.
+func (w *Writer) AddCue(c Cue) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if w.start < 0 {
+		return ErrCuesNeedSeek
+	}
+	if c.Frame < 0 || c.Frame > math.MaxUint32 || c.Length < 0 || c.Length > math.MaxUint32 {
+		return fmt.Errorf("%w: cue at frame %d of %d frames", ErrInvalidCue, c.Frame, c.Length)
+	}
+	w.cues = append(w.cues, c)
+	return nil
+}
+
+// cueChunks returns the cue chunk and the LIST adtl chunk of cues
+func cueChunks(cues []Cue) []byte {
+	if len(cues) == 0 {
+		return nil
+	}
+
+	b := append([]byte(nil), idCue[:]...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(4+24*len(cues)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(cues)))
+	for i, c := range cues {
+		b = binary.LittleEndian.AppendUint32(b, uint32(i+1)) // ID
+		b = binary.LittleEndian.AppendUint32(b, uint32(c.Frame))
+		b = append(b, idData[:]...)
+		b = binary.LittleEndian.AppendUint32(b, 0) // chunk start
+		b = binary.LittleEndian.AppendUint32(b, 0) // block start
+		b = binary.LittleEndian.AppendUint32(b, uint32(c.Frame))
+	}
+
+	list := append([]byte(nil), idAdtl[:]...)
+	for i, c := range cues {
+		if c.Label != "" {
+			list = append(list, idLabl[:]...)
+			list = binary.LittleEndian.AppendUint32(list, uint32(4+len(c.Label)+1))
+			list = binary.LittleEndian.AppendUint32(list, uint32(i+1))
+			list = append(list, c.Label...)
+			list = append(list, 0)
+			if len(list)%2 != 0 {
+				list = append(list, 0)
+			}
+		}
+		if c.Length > 0 {
+			list = append(list, idLtxt[:]...)
+			list = binary.LittleEndian.AppendUint32(list, 20)
+			list = binary.LittleEndian.AppendUint32(list, uint32(i+1))
+			list = binary.LittleEndian.AppendUint32(list, uint32(c.Length))
+			list = append(list, idRgn[:]...)
+			list = append(list, make([]byte, 8)...) // country, language, dialect, code page
+		}
+	}
+	if len(list) == len(idAdtl) {
+		return b
+	}
+
+	b = append(b, idList[:]...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(list)))
+	return append(b, list...)
+}
+
+// ReadCues returns the cue points of the WAV file in r, with the labels
+// and region lengths of its associated data list, in the order they are
+// stored. The chunks follow the samples, which are skipped, by seeking
+// when r can. Cues whose label is missing have an empty one.
+func ReadCues(r io.Reader) ([]Cue, error) {
+	walker, err := newChunkWalker(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		cues  []Cue
+		ids   = map[uint32]int{} // cue ID to index in cues
+		label = map[uint32]string{}
+		span  = map[uint32]int64{}
+	)
+	for {
+		id, size, err := walker.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if (id != idCue && id != idList) || size > maxCueChunkSize {
+			continue
+		}
+
+		body := make([]byte, size)
+		if _, err := io.ReadFull(walker, body); err != nil {
+			return nil, fmt.Errorf("reading %s chunk: %w", id[:], err)
+		}
+		if id == idCue {
+			if len(body) < 4 {
+				return nil, fmt.Errorf("%w: short cue chunk", ErrUnsupportedWavChunks)
+			}
+			n := int(binary.LittleEndian.Uint32(body))
+			if len(body) < 4+24*n {
+				return nil, fmt.Errorf("%w: cue chunk of %d points in %d bytes", ErrUnsupportedWavChunks, n, len(body))
+			}
+			for i := range n {
+				p := body[4+24*i:]
+				ids[binary.LittleEndian.Uint32(p)] = len(cues)
+				cues = append(cues, Cue{Frame: int64(binary.LittleEndian.Uint32(p[20:]))})
+			}
+		} else if len(body) >= 4 && [4]byte(body[:4]) == idAdtl {
+			readAdtl(body[4:], label, span)
+		}
+	}
+
+	for id, i := range ids {
+		cues[i].Label = label[id]
+		cues[i].Length = span[id]
+	}
+	return cues, nil
+}
+
+// readAdtl collects the labels and region lengths of an associated data
+// list by cue ID
+func readAdtl(b []byte, label map[uint32]string, span map[uint32]int64) {
+	for len(b) >= 12 {
+		id := [4]byte(b[:4])
+		size := int(binary.LittleEndian.Uint32(b[4:]))
+		if size < 4 || size > len(b)-8 {
+			return
+		}
+		body := b[8 : 8+size]
+		cue := binary.LittleEndian.Uint32(body)
+
+		switch {
+		case id == idLabl:
+			text := body[4:]
+			for len(text) > 0 && text[len(text)-1] == 0 {
+				text = text[:len(text)-1]
+			}
+			label[cue] = string(text)
+		case id == idLtxt && size >= 8:
+			span[cue] = int64(binary.LittleEndian.Uint32(body[4:]))
+		}
+
+		b = b[min(8+size+size%2, len(b)):]
+	}
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	gowav "github.com/go-audio/wav"
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestWriter_Cues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cues []Cue
+	}{
+		{name: "none"},
+		{name: "points", cues: []Cue{{Frame: 0, Label: "start"}, {Frame: 4000, Label: "DTMF 5"}, {Frame: 7999}}},
+		{name: "regions", cues: []Cue{{Frame: 8000, Length: 16000, Label: "hold"}, {Frame: 100, Length: 20}}},
+		{name: "ahead of the audio", cues: []Cue{{Frame: 20000, Label: "speaker 2"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := os.Create(filepath.Join(t.TempDir(), "out.wav"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			w, err := NewWriter(file, audio.Format{Rate: 8000, Channels: 2})
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			if _, err := w.WriteSource(audiotest.NewSineSource(8000, 2, 12000, 440)); err != nil {
+				t.Fatalf("WriteSource() error = %v", err)
+			}
+			for _, c := range tt.cues {
+				if err := w.AddCue(c); err != nil {
+					t.Fatalf("AddCue() error = %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			data, err := os.ReadFile(file.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadCues(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ReadCues() error = %v", err)
+			}
+			if !slices.Equal(got, tt.cues) {
+				t.Errorf("ReadCues() = %v, want %v", got, tt.cues)
+			}
+
+			// The samples are untouched, and the cues where other readers
+			// look for them
+			if samples, _ := ownDecode(t, data); len(samples) != 24000 {
+				t.Errorf("decoded %d samples, want 24000", len(samples))
+			}
+			if ref, _, _ := referenceDecode(t, data); len(ref) != 24000 {
+				t.Errorf("reference decoded %d samples, want 24000", len(ref))
+			}
+			dec := gowav.NewDecoder(bytes.NewReader(data))
+			dec.ReadMetadata()
+			if err := dec.Err(); err != nil {
+				t.Fatalf("reference ReadMetadata() error = %v", err)
+			}
+			var frames []int64
+			if dec.Metadata != nil {
+				for _, c := range dec.Metadata.CuePoints {
+					frames = append(frames, int64(c.SampleOffset))
+				}
+			}
+			for i, c := range tt.cues {
+				if i >= len(frames) || frames[i] != c.Frame {
+					t.Fatalf("reference cue points at %v", frames)
+				}
+			}
+		})
+	}
+}
+
+func TestWriter_CueErrors(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, audio.Format{Rate: 8000, Channels: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddCue(Cue{Frame: 10}); !errors.Is(err, ErrCuesNeedSeek) {
+		t.Errorf("AddCue() on a stream error = %v, want ErrCuesNeedSeek", err)
+	}
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	w, err = NewWriter(file, audio.Format{Rate: 8000, Channels: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddCue(Cue{Frame: -1}); !errors.Is(err, ErrInvalidCue) {
+		t.Errorf("AddCue(-1) error = %v, want ErrInvalidCue", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddCue(Cue{Frame: 10}); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("AddCue() after Close error = %v, want ErrWriterClosed", err)
+	}
+
+	if _, err := ReadCues(bytes.NewReader([]byte("not a wav file"))); !errors.Is(err, ErrNotWavFile) {
+		t.Errorf("ReadCues() error = %v, want ErrNotWavFile", err)
+	}
+}
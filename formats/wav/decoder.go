@@ -3,165 +3,360 @@
 package wav
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"time"
 
-	goaudio "github.com/go-audio/audio"
-	"github.com/go-audio/wav"
 	"github.com/ik5/audpbx/audio"
 )
 
-// source wraps go-audio wav.Decoder to implement audio.Source
+// WAVE format tags
+const (
+	formatPCM        = 1
+	formatMSADPCM    = 2
+	formatIEEEFloat  = 3
+	formatIMAADPCM   = 0x11
+	formatExtensible = 0xFFFE
+)
+
+// source reads the data chunk of a WAV file, and of any WAV files
+// concatenated after it
 type source struct {
-	dec        *wav.Decoder
-	sampleRate int
-	channels   int
-	bitDepth   int
-	intBuf     *goaudio.IntBuffer
+	data   *chunkWalker
+	format waveFormat
+	first  waveFormat // format of the first file
+	size   int64      // data chunk size of the first file, possibly UnknownSize or bogus
+	raw    []byte
+	read   int64 // samples of the current file returned so far
+	base   int64 // frames of the previous files
+	loop   audio.LoopRegion
 }
 
-func (s *source) SampleRate() int { return s.sampleRate }
-func (s *source) Channels() int   { return s.channels }
+func (s *source) SampleRate() int { return s.format.sampleRate }
+func (s *source) Channels() int   { return s.format.channels }
 func (s *source) Close() error    { return nil }
-func (s *source) BufSize() int {
-	if s.intBuf != nil {
-		return cap(s.intBuf.Data)
+func (s *source) BufSize() int    { return 4096 }
+
+func (s *source) Format() audio.Format {
+	return s.format.audioFormat()
+}
+
+// TotalFrames returns the length of the first file, from the size of its
+// data chunk, or -1 when it is marked as unknown. Files concatenated after
+// it are not counted, as they are only found at its end.
+func (s *source) TotalFrames() int64 {
+	if s.size >= UnknownSize || s.first.frameSize() == 0 {
+		return -1
 	}
-	return 4096
+	return s.size / int64(s.first.frameSize())
+}
+
+func (s *source) Duration() time.Duration {
+	return audio.FramesDuration(s.TotalFrames(), s.first.sampleRate)
 }
 
+// Position returns the frames returned so far over all the files.
+func (s *source) Position() int64 { return s.base + s.read/int64(s.format.channels) }
+
 func (s *source) ReadSamples(dst []float32) (int, error) {
 	if len(dst) == 0 {
 		return 0, nil
 	}
 
-	// Resize buffer if needed
-	if s.intBuf == nil || cap(s.intBuf.Data) < len(dst) {
-		s.intBuf = &goaudio.IntBuffer{
-			Data:   make([]int, len(dst)),
-			Format: s.dec.Format(),
+	for {
+		size := s.format.bitDepth / 8
+		want := len(dst) * size
+		if cap(s.raw) < want {
+			s.raw = make([]byte, want)
+		}
+		s.raw = s.raw[:want]
+
+		// A trailing partial sample is dropped
+		m, err := io.ReadFull(s.data, s.raw)
+		n := m / size
+		s.convert(dst[:n], s.raw)
+		s.read += int64(n)
+
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return n, fmt.Errorf("%w", err)
+		}
+		if err == nil {
+			return n, nil
+		}
+
+		// Fewer samples than requested means the data chunk is exhausted.
+		// Unless the file was cut short, another one may follow.
+		if s.data.left > 0 {
+			return n, io.EOF
+		}
+		prev := s.format
+		if err := s.nextFile(); err != nil {
+			return n, err
+		}
+		if err := audio.CheckFormatChange(prev.audioFormat(), s.format.audioFormat()); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
 		}
-	} else {
-		s.intBuf.Data = s.intBuf.Data[:len(dst)]
 	}
+}
 
-	// Read from decoder
-	n, err := s.dec.PCMBuffer(s.intBuf)
-	if n == 0 {
+// nextFile moves to the data chunk of the WAV file concatenated after the
+// current one, as produced by "cat a.wav b.wav". Chunks trailing the data
+// (LIST, id3, ...) are skipped. It returns io.EOF when no file follows.
+func (s *source) nextFile() error {
+	for {
+		id, _, err := s.data.next()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// Stray bytes after the last chunk end the stream too
+			return io.EOF
+		}
 		if err != nil {
-			return 0, err
+			return err
+		}
+		if id != idRIFF {
+			continue
 		}
-		return 0, io.EOF
-	}
 
-	// Convert int samples to float32
-	// go-audio uses int format, we need to normalize based on bit depth
-	var maxVal float32
-	switch s.bitDepth {
-	case 8:
-		maxVal = 128.0
-	case 16:
-		maxVal = 32768.0
-	case 24:
-		maxVal = 8388608.0
-	case 32:
-		maxVal = 2147483648.0
-	default:
-		maxVal = 32768.0 // Default to 16-bit
-	}
+		if err := s.data.enterRIFF(); err != nil {
+			return err
+		}
+		format, _, err := findData(s.data, nil)
+		if err != nil {
+			return fmt.Errorf("concatenated file: %w", err)
+		}
+		// Samples are read raw from here on, which ADPCM blocks are not
+		if format.frameSize() == 0 {
+			return fmt.Errorf("%w: concatenated ADPCM file", ErrUnsupportedWavLayout)
+		}
 
-	for i := range n {
-		dst[i] = float32(s.intBuf.Data[i]) / maxVal
+		s.base = s.Position()
+		s.read = 0
+		s.format = format
+		return nil
 	}
+}
 
-	// If we got fewer samples than requested and no error, we're at EOF
-	if n < len(dst) && err == nil {
-		return n, io.EOF
+// convert normalizes little-endian samples from raw into dst
+func (s *source) convert(dst []float32, raw []byte) {
+	switch f := s.format; {
+	case f.float() && f.bitDepth == 64:
+		for i := range dst {
+			dst[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(raw[i*8:])))
+		}
+	case f.float():
+		for i := range dst {
+			dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+		}
+	case f.bitDepth == 8:
+		// 8-bit WAV is unsigned with silence at 128
+		for i := range dst {
+			dst[i] = float32(int(raw[i])-128) / 128.0
+		}
+	case f.bitDepth == 16:
+		for i := range dst {
+			dst[i] = float32(int16(binary.LittleEndian.Uint16(raw[i*2:]))) / 32768.0
+		}
+	case f.bitDepth == 24:
+		for i := range dst {
+			b := raw[i*3:]
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			dst[i] = float32(v) / 8388608.0
+		}
+	case f.bitDepth == 32:
+		for i := range dst {
+			dst[i] = float32(int32(binary.LittleEndian.Uint32(raw[i*4:]))) / 2147483648.0
+		}
 	}
-
-	return n, err
 }
 
 type Decoder struct{}
 
+// Decode walks the chunks of a WAV file up to the data chunk and returns a
+// source reading it. Chunks other than fmt and data (LIST, fact, JUNK, ...)
+// are skipped wherever they appear, so r does not need to be seekable;
+// when it is, skipped chunks are seeked over rather than read, and the
+// source implements audio.Seeker. The source always implements
+// audio.Lengther, from the size of the data chunk, and audio.LoopPoints,
+// from the first forward loop of a smpl chunk.
+//
+// WAV files concatenated after the first one are read on as one stream.
+// When one has a different rate or channel count, ReadSamples returns an
+// *audio.FormatChangedError at the boundary.
+//
+// IMA and Microsoft ADPCM files are decoded to 16-bit samples, block by
+// block, up to the frame count of their fact chunk. They are not followed
+// into concatenated files.
 func (Decoder) Decode(r io.Reader) (audio.Source, error) {
-	// go-audio requires io.ReadSeeker
-	rs, ok := r.(io.ReadSeeker)
-	if !ok {
-		// If not a ReadSeeker, we need to read all data into memory
-		// This is a limitation of go-audio
-		data, err := io.ReadAll(r)
-		if err != nil {
-			return nil, fmt.Errorf("reading wav data: %w", err)
-		}
-		rs = &readSeeker{data: data, offset: 0}
+	walker, err := newChunkWalker(r)
+	if err != nil {
+		return nil, err
 	}
 
-	dec := wav.NewDecoder(rs)
-	if !dec.IsValidFile() {
-		return nil, ErrNotWavFile
+	var loop audio.LoopRegion
+	format, size, err := findData(walker, &loop)
+	if err != nil {
+		return nil, err
 	}
-
-	// Only support PCM for now (WAV audio format 1)
-	if dec.WavAudioFormat != 1 {
-		return nil, fmt.Errorf("unsupported audio format: %d (only PCM supported)", dec.WavAudioFormat)
+	if format.adpcm() {
+		return newADPCMSource(r, walker, format, int64(size), loop)
 	}
 
-	// Check bit depth
-	if dec.BitDepth != 16 {
-		return nil, ErrOnlyPCM16bitSupported
+	src := &source{data: walker, format: format, first: format, size: int64(size), loop: loop}
+	if rs, ok := r.(io.ReadSeeker); ok {
+		// Pipes and sockets may implement Seeker and still fail
+		if start, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			if src.loop.End == 0 && src.size < UnknownSize {
+				if src.loop, err = trailingLoop(rs, start, src.size); err != nil {
+					return nil, err
+				}
+			}
+			return &seekSource{source: src, rs: rs, start: start}, nil
+		}
 	}
+	return src, nil
+}
 
-	// Forward to PCM data
-	if err := dec.FwdToPCM(); err != nil {
-		return nil, fmt.Errorf("forwarding to PCM data: %w", err)
+// Capabilities reports what the package reads and writes: integer PCM of
+// 8 to 32 bits, 4-bit ADPCM and float of 32 or 64 bits, written back as
+// 16-bit PCM.
+func (Decoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{
+		Name:            "WAV",
+		Decode:          true,
+		Encode:          true,
+		BitDepths:       []int{4, 8, 16, 24, 32, 64},
+		EncodeBitDepths: []int{16},
+		MaxChannels:     math.MaxUint16,
+		Seekable:        true,
 	}
+}
 
-	format := dec.Format()
-	if format == nil {
-		return nil, ErrUnsupportedWavLayout
-	}
+// findData walks the chunks of a WAV file up to its data chunk and returns
+// the format and the size of the data. A loop of a smpl chunk on the way is
+// stored in loop, unless it is nil.
+func findData(walker *chunkWalker, loop *audio.LoopRegion) (waveFormat, uint32, error) {
+	var (
+		format waveFormat
+		hasFmt bool
+		frames int64 = -1
+	)
+	for {
+		id, size, err := walker.next()
+		if errors.Is(err, io.EOF) {
+			return waveFormat{}, 0, fmt.Errorf("%w: no data chunk", ErrUnsupportedWavChunks)
+		}
+		if err != nil {
+			return waveFormat{}, 0, err
+		}
 
-	return &source{
-		dec:        dec,
-		sampleRate: format.SampleRate,
-		channels:   format.NumChannels,
-		bitDepth:   int(dec.BitDepth),
-	}, nil
+		switch id {
+		case idFmt:
+			if format, err = parseFmt(walker, size); err != nil {
+				return waveFormat{}, 0, err
+			}
+			if err := format.validate(); err != nil {
+				return waveFormat{}, 0, err
+			}
+			hasFmt = true
+		case idSmpl:
+			if loop != nil {
+				*loop = parseSmpl(walker, size)
+			}
+		case idFact:
+			var fact [4]byte
+			if _, err := io.ReadFull(walker, fact[:]); err == nil {
+				frames = int64(binary.LittleEndian.Uint32(fact[:]))
+			}
+		case idData:
+			if !hasFmt {
+				return waveFormat{}, 0, fmt.Errorf("%w: data chunk before fmt", ErrUnsupportedWavChunks)
+			}
+			format.frames = frames
+			return format, size, nil
+		}
+	}
 }
 
-// readSeeker implements io.ReadSeeker for in-memory data
-type readSeeker struct {
-	data   []byte
-	offset int64
+// seekSource is a source whose data chunk can be read at random. Seeking
+// addresses the first file of a concatenation and returns to its format.
+type seekSource struct {
+	*source
+	rs    io.ReadSeeker
+	start int64 // offset of the first sample
 }
 
-func (rs *readSeeker) Read(p []byte) (n int, err error) {
-	if rs.offset >= int64(len(rs.data)) {
-		return 0, io.EOF
+// SeekFrame moves to frame n. Streamed files whose data size is not known
+// can be seeked anywhere after the start; reading past the end yields
+// io.EOF.
+func (s *seekSource) SeekFrame(n int64) error {
+	if s.first.frameSize() == 0 {
+		return fmt.Errorf("%w: format %d", ErrUnsupportedWavLayout, s.first.tag)
+	}
+	offset := n * int64(s.first.frameSize())
+	if n < 0 || (s.size < UnknownSize && offset > s.size) {
+		return fmt.Errorf("%w: frame %d", audio.ErrSeekOutOfRange, n)
 	}
-	n = copy(p, rs.data[rs.offset:])
-	rs.offset += int64(n)
-	return n, nil
+
+	if _, err := s.rs.Seek(s.start+offset, io.SeekStart); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	s.data.left, s.data.pad = s.size-offset, 0
+	s.format = s.first
+	s.base = 0
+	s.read = n * int64(s.first.channels)
+	return nil
 }
 
-func (rs *readSeeker) Seek(offset int64, whence int) (int64, error) {
-	var newOffset int64
-	switch whence {
-	case io.SeekStart:
-		newOffset = offset
-	case io.SeekCurrent:
-		newOffset = rs.offset + offset
-	case io.SeekEnd:
-		newOffset = int64(len(rs.data)) + offset
-	default:
-		return 0, fmt.Errorf("invalid whence: %d", whence)
+// float reports whether the samples are IEEE floats
+func (f waveFormat) float() bool { return f.tag == formatIEEEFloat }
+
+// frameSize returns the bytes of a frame of PCM or float samples, or 0 for
+// ADPCM, whose frames are packed into blocks
+func (f waveFormat) frameSize() int {
+	if f.adpcm() {
+		return 0
 	}
+	return f.channels * f.bitDepth / 8
+}
 
-	if newOffset < 0 {
-		return 0, ErrNegativePosition
+func (f waveFormat) audioFormat() audio.Format {
+	kind := audio.SampleKindForBitDepth(f.bitDepth)
+	if f.float() {
+		kind = audio.SampleFloat32
+		if f.bitDepth == 64 {
+			kind = audio.SampleFloat64
+		}
 	}
+	return audio.Format{
+		Rate:       f.sampleRate,
+		Channels:   f.channels,
+		Layout:     f.layout,
+		SampleKind: kind,
+	}
+}
 
-	rs.offset = newOffset
-	return newOffset, nil
+// validate checks that the format is one ReadSamples can convert: integer
+// PCM of any common depth, 4-bit ADPCM or 32/64-bit IEEE float
+func (f waveFormat) validate() error {
+	switch f.tag {
+	case formatIMAADPCM, formatMSADPCM:
+		return f.validateADPCM()
+	case formatPCM:
+		switch f.bitDepth {
+		case 8, 16, 24, 32:
+			return nil
+		}
+		return fmt.Errorf("%w: %d", ErrUnsupportedBitDepth, f.bitDepth)
+	case formatIEEEFloat:
+		if f.bitDepth != 32 && f.bitDepth != 64 {
+			return fmt.Errorf("%w: %d-bit float", ErrUnsupportedBitDepth, f.bitDepth)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported audio format: %d (only PCM, ADPCM and IEEE float supported)", f.tag)
 }
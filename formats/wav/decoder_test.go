@@ -5,9 +5,13 @@ package wav
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 	"math"
 	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
 )
 
 // Helper function to create a minimal valid WAV file
@@ -140,33 +144,186 @@ func TestDecoder_TruncatedHeader(t *testing.T) {
 	}
 }
 
-func TestDecoder_Non16BitPCM(t *testing.T) {
+func TestDecoder_UnsupportedBitDepth(t *testing.T) {
 	t.Parallel()
 
+	wavData := createPCMWAVFile(8000, 1, 12, make([]byte, 4))
+
+	decoder := Decoder{}
+	_, err := decoder.Decode(bytes.NewReader(wavData))
+
+	if !errors.Is(err, ErrUnsupportedBitDepth) {
+		t.Errorf("Decode() error = %v, want ErrUnsupportedBitDepth", err)
+	}
+}
+
+// createPCMWAVFile creates a WAV file of any bit depth from raw sample bytes
+func createPCMWAVFile(sampleRate, channels, bitsPerSample int, data []byte) []byte {
 	buf := new(bytes.Buffer)
+
+	blockAlign := channels * ((bitsPerSample + 7) / 8)
+
 	buf.WriteString("RIFF")
-	binary.Write(buf, binary.LittleEndian, uint32(36))
+	binary.Write(buf, binary.LittleEndian, uint32(36+len(data)))
 	buf.WriteString("WAVE")
 
-	// fmt chunk with 8-bit PCM
 	buf.WriteString("fmt ")
 	binary.Write(buf, binary.LittleEndian, uint32(16))
-	binary.Write(buf, binary.LittleEndian, uint16(1))  // PCM
-	binary.Write(buf, binary.LittleEndian, uint16(1))  // mono
-	binary.Write(buf, binary.LittleEndian, uint32(8000))
-	binary.Write(buf, binary.LittleEndian, uint32(8000))
 	binary.Write(buf, binary.LittleEndian, uint16(1))
-	binary.Write(buf, binary.LittleEndian, uint16(8)) // 8-bit
+	binary.Write(buf, binary.LittleEndian, uint16(channels))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(buf, binary.LittleEndian, uint16(bitsPerSample))
 
-	// data chunk
 	buf.WriteString("data")
-	binary.Write(buf, binary.LittleEndian, uint32(0))
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
 
-	decoder := Decoder{}
-	_, err := decoder.Decode(buf)
+	return buf.Bytes()
+}
+
+func TestDecoder_BitDepths(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		bits int
+		data []byte
+		kind audio.SampleKind
+		want []float32
+	}{
+		{
+			name: "8-bit unsigned",
+			bits: 8,
+			data: []byte{128, 0, 255, 192},
+			kind: audio.SampleUint8,
+			want: []float32{0, -1, 127.0 / 128, 0.5},
+		},
+		{
+			name: "16-bit",
+			bits: 16,
+			data: []byte{0x00, 0x00, 0x00, 0x80, 0xff, 0x7f, 0x00, 0x40},
+			kind: audio.SampleInt16,
+			want: []float32{0, -1, 32767.0 / 32768, 0.5},
+		},
+		{
+			name: "24-bit",
+			bits: 24,
+			data: []byte{0, 0, 0, 0x00, 0x00, 0x80, 0xff, 0xff, 0x7f, 0x00, 0x00, 0xc0},
+			kind: audio.SampleInt24,
+			want: []float32{0, -1, 8388607.0 / 8388608, -0.5},
+		},
+		{
+			name: "32-bit",
+			bits: 32,
+			data: []byte{0, 0, 0, 0, 0, 0, 0, 0x80, 0, 0, 0, 0x40, 0, 0, 0, 0xc0},
+			kind: audio.SampleInt32,
+			want: []float32{0, -1, 0.5, -0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := Decoder{}.Decode(bytes.NewReader(createPCMWAVFile(8000, 1, tt.bits, tt.data)))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if got := audio.FormatOf(src).SampleKind; got != tt.kind {
+				t.Errorf("SampleKind = %v, want %v", got, tt.kind)
+			}
+
+			buf := make([]float32, 16)
+			n, err := src.ReadSamples(buf)
+			if err != nil && err != io.EOF {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+			if n != len(tt.want) {
+				t.Fatalf("ReadSamples() = %d samples, want %d", n, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if math.Abs(float64(buf[i]-want)) > 1e-6 {
+					t.Errorf("sample %d = %v, want %v", i, buf[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_IEEEFloat(t *testing.T) {
+	t.Parallel()
+
+	want := []float32{0, 0.25, -0.5, 1, -1, 0.125}
+
+	tests := []struct {
+		name string
+		bits int
+		kind audio.SampleKind
+	}{
+		{"32-bit float", 32, audio.SampleFloat32},
+		{"64-bit float", 64, audio.SampleFloat64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var data []byte
+			for _, v := range want {
+				if tt.bits == 64 {
+					data = binary.LittleEndian.AppendUint64(data, math.Float64bits(float64(v)))
+				} else {
+					data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+				}
+			}
+			wavData := createPCMWAVFile(48000, 2, tt.bits, data)
+			binary.LittleEndian.PutUint16(wavData[20:22], 3) // WAVE_FORMAT_IEEE_FLOAT
+
+			src, err := Decoder{}.Decode(bytes.NewReader(wavData))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if f := audio.FormatOf(src); f.SampleKind != tt.kind || f.Channels != 2 || f.Rate != 48000 {
+				t.Errorf("format = %v", f)
+			}
+
+			// Odd sized reads across the buffer boundary
+			var got []float32
+			buf := make([]float32, 4)
+			for {
+				n, err := src.ReadSamples(buf)
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("got %d samples, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("sample %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecoder_IEEEFloatUnsupportedDepth(t *testing.T) {
+	t.Parallel()
+
+	wavData := createPCMWAVFile(8000, 1, 16, make([]byte, 4))
+	binary.LittleEndian.PutUint16(wavData[20:22], 3)
 
-	if err != ErrOnlyPCM16bitSupported {
-		t.Errorf("Decode() error = %v, want ErrOnlyPCM16bitSupported", err)
+	if _, err := (Decoder{}).Decode(bytes.NewReader(wavData)); !errors.Is(err, ErrUnsupportedBitDepth) {
+		t.Errorf("Decode() error = %v, want ErrUnsupportedBitDepth", err)
 	}
 }
 
@@ -464,6 +621,78 @@ func TestSource_Close(t *testing.T) {
 	}
 }
 
+func TestSource_TotalFrames(t *testing.T) {
+	t.Parallel()
+
+	mono := createPCMWAVFile(8000, 1, 16, make([]byte, 2*8000))
+
+	tests := []struct {
+		name         string
+		file         []byte
+		seekable     bool
+		wantFrames   int64
+		wantDuration time.Duration
+	}{
+		{
+			name:         "16-bit mono",
+			file:         mono,
+			wantFrames:   8000,
+			wantDuration: time.Second,
+		},
+		{
+			name:         "24-bit stereo seekable",
+			file:         createPCMWAVFile(48000, 2, 24, make([]byte, 6*12000)),
+			seekable:     true,
+			wantFrames:   12000,
+			wantDuration: 250 * time.Millisecond,
+		},
+		{
+			name:         "empty",
+			file:         createPCMWAVFile(8000, 1, 16, nil),
+			wantFrames:   0,
+			wantDuration: 0,
+		},
+		{
+			name:         "unknown size",
+			file:         append(pcm16Header(8000, 1, UnknownSize), make([]byte, 100)...),
+			wantFrames:   -1,
+			wantDuration: -1,
+		},
+		{
+			name:         "concatenated counts the first file",
+			file:         append(bytes.Clone(mono), createPCMWAVFile(8000, 1, 16, make([]byte, 200))...),
+			wantFrames:   8000,
+			wantDuration: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r io.Reader = bytes.NewReader(tt.file)
+			if !tt.seekable {
+				r = struct{ io.Reader }{r}
+			}
+			src, err := Decoder{}.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			l, ok := src.(audio.Lengther)
+			if !ok {
+				t.Fatal("source does not implement audio.Lengther")
+			}
+			if got := l.TotalFrames(); got != tt.wantFrames {
+				t.Errorf("TotalFrames() = %d, want %d", got, tt.wantFrames)
+			}
+			if got := l.Duration(); got != tt.wantDuration {
+				t.Errorf("Duration() = %v, want %v", got, tt.wantDuration)
+			}
+		})
+	}
+}
+
 func TestDecoder_VariousSampleRates(t *testing.T) {
 	t.Parallel()
 
@@ -2,13 +2,21 @@
 
 // Package wav provides WAV audio file decoding and encoding.
 //
-// This package supports reading and writing WAV files in PCM 16-bit format.
-// It uses the github.com/go-audio library for robust WAV file handling.
+// This package reads 8, 16, 24 and 32-bit PCM, 32 and 64-bit IEEE float
+// and 4-bit IMA and Microsoft ADPCM WAV files and writes PCM 16-bit WAV
+// files.
 //
 // # Supported Formats
 //
 // Currently supported:
 //   - PCM 16-bit (most common WAV format)
+//   - PCM 8-bit unsigned, 24-bit and 32-bit signed (decoding)
+//   - IEEE float 32 and 64-bit, as exported by most DAWs (decoding)
+//   - WAVE_FORMAT_EXTENSIBLE headers carrying either of the above, whose
+//     dwChannelMask gives the speaker Layout of the audio.Format (decoding)
+//   - IMA ADPCM (0x0011) and Microsoft ADPCM (0x0002), 4 bits a sample, as
+//     recorded by voicemail systems, Windows Sound Recorder and Asterisk
+//     (decoding)
 //   - Mono and stereo
 //   - Any sample rate
 //
@@ -30,6 +38,20 @@
 // The decoder returns an audio.Source that provides samples as float32
 // values in the range [-1.0, 1.0].
 //
+// The source implements audio.Lengther from the data chunk size. When the
+// input is an io.ReadSeeker, it also implements audio.Seeker, so players
+// and editors can jump to any frame:
+//
+//	seeker := source.(audio.Seeker)
+//	err = seeker.SeekFrame(10 * 44100) // 10 s in
+//
+// WAV files concatenated into one stream (cat a.wav b.wav) are read on
+// from one to the next. Where the rate or channel count changes,
+// ReadSamples returns an *audio.FormatChangedError and continues in the
+// new format. Files with an unknown data size cannot be followed, as their
+// data runs to the end of the stream, and an ADPCM file after a PCM one
+// ends it with ErrUnsupportedWavLayout.
+//
 // # Writing WAV Files
 //
 // Use WriteWAV16 to create WAV files:
@@ -40,12 +62,103 @@
 //
 // The function writes a complete WAV file with proper headers.
 //
+// Stereo and multi-channel audio is written from interleaved samples
+// (L R L R ... for stereo) with WriteWAV16Interleaved:
+//
+//	err := wav.WriteWAV16Interleaved(file, 44100, 2, interleaved)
+//
+// # Streaming Encoding
+//
+// WriteWAV16 needs all samples in memory. For recordings of any length use
+// a Writer, which writes the header up front and the samples as they come,
+// and fills in the sizes on Close when the output can seek:
+//
+//	w, err := wav.NewWriter(file, audio.Format{Rate: 8000, Channels: 2})
+//	if err != nil {
+//	    return err
+//	}
+//	for chunk := range chunks {
+//	    if err := w.WriteSamples(chunk); err != nil {
+//	        return err
+//	    }
+//	}
+//	err = w.Close()
+//
+// Encode streams a whole audio.Source the same way:
+//
+//	err := wav.Encode(file, src)
+//
+// On outputs that cannot seek (pipes, sockets, compressed streams) the
+// sizes stay at UnknownSize, which the Decoder reads up to the end.
+//
+// WithNominalRate declares a different rate in the header than the data
+// was captured at, without resampling. This is the varispeed trick used to
+// correct the speed of digitized tapes and records:
+//
+//	err := wav.Encode(file, capture, wav.WithNominalRate(35556))
+//
+// # Splitting Channels
+//
+// To write each channel of a multi-channel source to its own mono file
+// (e.g. caller and agent legs of a stereo call recording), use
+// WriteWAV16Split, which decodes the source only once:
+//
+//	err := wav.WriteWAV16Split(stereoSource, callerFile, agentFile)
+//
+// # Cue Points
+//
+// Events found while processing a recording, such as DTMF digits, hold
+// segments or speaker changes, can be marked in the file as cue points,
+// points or regions with a label, which players and editors list so the
+// listener can jump to them. A Writer takes them as they are detected,
+// through the OnDigit callback of a dtmf.Detector for instance, and writes
+// them on Close:
+//
+//	det, err := dtmf.NewDetector(leg, dtmf.Options{OnDigit: func(d dtmf.Digit) {
+//	    _ = w.AddCue(wav.Cue{Frame: int64(audio.FrameLen(8000, d.At)), Label: "DTMF " + string(d.Key)})
+//	}})
+//	_, err = w.WriteSource(det)
+//	err = w.AddCue(wav.Cue{Frame: holdStart, Length: holdFrames, Label: "hold"})
+//	err = w.Close()
+//
+// Cues go after the samples, so the header must be filled in once they
+// are known: the Writer needs an output that can seek. ReadCues reads them
+// back.
+//
+// # Loop Points
+//
+// Music made to loop, such as hold music, marks the loop in a smpl chunk
+// so the intro is not replayed. Decoded sources implement
+// audio.LoopPoints with its first forward loop, which audio.Loop and
+// audio.LoopForever then repeat:
+//
+//	src, err := wav.Decoder{}.Decode(file)
+//	music, err := audio.LoopForever(src) // intro once, then the loop
+//
+// A smpl chunk after the data chunk is only found when the input can seek.
+//
+// # ADPCM
+//
+// ADPCM files are coded in blocks of the fmt chunk's block align, each
+// starting afresh from a header, and decode to int16 samples. The length
+// comes from the fact chunk, which also trims the padding of the last
+// block; without one, every block is taken as full. Seeking decodes from
+// the start of the block holding the frame.
+//
 // # Error Handling
 //
 // The package defines several error types:
 //   - ErrNotWavFile: The input is not a valid WAV file
-//   - ErrOnlyPCM16bitSupported: Only 16-bit PCM is supported
-//   - ErrUnsupportedWavLayout: Unsupported WAV file structure
+//   - ErrUnsupportedBitDepth: The PCM bit depth is not 8, 16, 24 or 32, or
+//     the ADPCM one not 4
+//   - ErrUnsupportedWavLayout: Unsupported WAV file structure, such as an
+//     ADPCM block too small for its header or an unknown MS ADPCM predictor
+//   - ErrChannelWriterMismatch: Writers given to WriteWAV16Split do not match the channels
+//   - ErrInvalidChannelCount: The channel count is out of range or samples
+//     do not form whole frames
+//   - ErrWriterClosed: A Writer was used after Close
+//   - ErrCuesNeedSeek: Cues were added to a Writer whose output cannot seek
+//   - ErrInvalidCue: A cue is at a negative frame, or beyond 32 bits
 //
 // Example:
 //
@@ -62,9 +175,9 @@
 //   - Pre-allocated header buffer
 //
 // The decoder provides:
-//   - Minimal allocations (2 per read)
-//   - Efficient buffer management
-//   - Stream-based reading for memory efficiency
+//   - No allocations per read once its buffer is sized
+//   - Stream-based reading: the input does not need to be seekable and is
+//     never loaded into memory as a whole
 //
 // # File Format
 //
@@ -73,5 +186,12 @@
 //   - fmt chunk (24 bytes): audio format, sample rate, channels, bit depth
 //   - data chunk: actual audio samples
 //
+// Real files often carry more chunks (LIST/INFO metadata from ffmpeg and
+// Audacity, fact, JUNK padding, bext, cue points with their LIST/adtl
+// labels). The decoder walks the chunks in order, skipping everything
+// except fmt, fact and data along with the pad byte of odd sized chunks,
+// and starts reading at the data chunk. The Writer puts the cue and LIST/adtl
+// chunks of its cues after the data chunk.
+//
 // The WriteWAV16 function handles all format details automatically.
 package wav
@@ -7,7 +7,15 @@ import "errors"
 var (
 	ErrNotWavFile = errors.New("not a WAV file")
 	ErrUnsupportedWavLayout = errors.New("unsupported WAV layout")
+	// Deprecated: the decoder reads 8, 16, 24 and 32-bit PCM and reports
+	// other depths with ErrUnsupportedBitDepth.
 	ErrOnlyPCM16bitSupported = errors.New("only PCM 16-bit supported")
+	ErrUnsupportedBitDepth   = errors.New("unsupported PCM bit depth")
 	ErrUnsupportedWavChunks =  errors.New("unsupported WAV chunks")
 	ErrNegativePosition = errors.New("negative position")
+	ErrChannelWriterMismatch = errors.New("number of writers must match channel count")
+	ErrInvalidChannelCount   = errors.New("invalid channel count")
+	ErrWriterClosed          = errors.New("WAV writer is closed")
+	ErrCuesNeedSeek          = errors.New("WAV cues need a seekable writer")
+	ErrInvalidCue            = errors.New("invalid WAV cue")
 )
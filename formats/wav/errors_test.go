@@ -116,6 +116,10 @@ func TestErrors_IsComparison(t *testing.T) {
 		{"ErrOnlyPCM16bitSupported", ErrOnlyPCM16bitSupported},
 		{"ErrUnsupportedWavChunks", ErrUnsupportedWavChunks},
 		{"ErrNegativePosition", ErrNegativePosition},
+		{"ErrChannelWriterMismatch", ErrChannelWriterMismatch},
+		{"ErrUnsupportedBitDepth", ErrUnsupportedBitDepth},
+		{"ErrInvalidChannelCount", ErrInvalidChannelCount},
+		{"ErrWriterClosed", ErrWriterClosed},
 	}
 
 	for _, tt := range tests {
@@ -148,6 +152,10 @@ func TestErrors_Wrapping(t *testing.T) {
 		{"ErrOnlyPCM16bitSupported", ErrOnlyPCM16bitSupported},
 		{"ErrUnsupportedWavChunks", ErrUnsupportedWavChunks},
 		{"ErrNegativePosition", ErrNegativePosition},
+		{"ErrChannelWriterMismatch", ErrChannelWriterMismatch},
+		{"ErrUnsupportedBitDepth", ErrUnsupportedBitDepth},
+		{"ErrInvalidChannelCount", ErrInvalidChannelCount},
+		{"ErrWriterClosed", ErrWriterClosed},
 	}
 
 	for _, tt := range tests {
@@ -173,6 +181,12 @@ func TestErrors_Uniqueness(t *testing.T) {
 		ErrOnlyPCM16bitSupported,
 		ErrUnsupportedWavChunks,
 		ErrNegativePosition,
+		ErrChannelWriterMismatch,
+		ErrUnsupportedBitDepth,
+		ErrInvalidChannelCount,
+		ErrWriterClosed,
+		ErrCuesNeedSeek,
+		ErrInvalidCue,
 	}
 
 	for i := range allErrors {
@@ -195,6 +209,10 @@ func TestErrors_Messages(t *testing.T) {
 		"ErrOnlyPCM16bitSupported": ErrOnlyPCM16bitSupported,
 		"ErrUnsupportedWavChunks":  ErrUnsupportedWavChunks,
 		"ErrNegativePosition": ErrNegativePosition,
+		"ErrChannelWriterMismatch": ErrChannelWriterMismatch,
+		"ErrUnsupportedBitDepth":   ErrUnsupportedBitDepth,
+		"ErrInvalidChannelCount":   ErrInvalidChannelCount,
+		"ErrWriterClosed":          ErrWriterClosed,
 	}
 
 	for name, err := range allErrors {
@@ -6,39 +6,24 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 )
 
 // WriteWAV16 writes a mono 16-bit PCM WAV at sampleRate.  samples must be int16 PCM.
 // This uses an optimized implementation for minimal allocations.
 func WriteWAV16(w io.Writer, sampleRate int, samples []int16) error {
-	numChannels := uint16(1)
-	bitsPerSample := uint16(16)
-	byteRate := uint32(sampleRate) * uint32(numChannels) * uint32(bitsPerSample/8)
-	blockAlign := uint16(numChannels) * uint16(bitsPerSample/8)
-	dataSize := uint32(len(samples) * 2)
-	riffSize := 36 + dataSize
-
-	// Pre-allocate buffer for entire header (44 bytes)
-	header := make([]byte, 44)
-
-	// RIFF header (12 bytes)
-	copy(header[0:4], "RIFF")
-	binary.LittleEndian.PutUint32(header[4:8], riffSize)
-	copy(header[8:12], "WAVE")
+	return WriteWAV16Interleaved(w, sampleRate, 1, samples)
+}
 
-	// fmt chunk (24 bytes)
-	copy(header[12:16], "fmt ")
-	binary.LittleEndian.PutUint32(header[16:20], 16) // PCM fmt chunk size
-	binary.LittleEndian.PutUint16(header[20:22], 1)  // PCM format
-	binary.LittleEndian.PutUint16(header[22:24], numChannels)
-	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))
-	binary.LittleEndian.PutUint32(header[28:32], byteRate)
-	binary.LittleEndian.PutUint16(header[32:34], blockAlign)
-	binary.LittleEndian.PutUint16(header[34:36], bitsPerSample)
+// WriteWAV16Interleaved writes a 16-bit PCM WAV with the given number of
+// channels at sampleRate. samples holds interleaved frames (L R L R ... for
+// stereo) and its length must be a multiple of channels.
+func WriteWAV16Interleaved(w io.Writer, sampleRate, channels int, samples []int16) error {
+	if channels <= 0 || channels > math.MaxUint16 || len(samples)%channels != 0 {
+		return fmt.Errorf("%w: %d samples for %d channels", ErrInvalidChannelCount, len(samples), channels)
+	}
 
-	// data chunk header (8 bytes)
-	copy(header[36:40], "data")
-	binary.LittleEndian.PutUint32(header[40:44], dataSize)
+	header := pcm16Header(sampleRate, channels, uint32(len(samples)*2))
 
 	// Write header in one operation
 	if _, err := w.Write(header); err != nil {
@@ -5,6 +5,7 @@ package wav
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 	"testing"
 )
@@ -402,3 +403,93 @@ func BenchmarkWriteWAV16_RoundTrip(b *testing.B) {
 		_, _ = decoder.Decode(bytes.NewReader(buf.Bytes()))
 	}
 }
+
+func TestWriteWAV16Interleaved(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		rate     int
+		channels int
+		samples  []int16
+	}{
+		{"mono", 8000, 1, []int16{1, 2, 3}},
+		{"stereo", 44100, 2, []int16{100, -100, 200, -200, 300, -300}},
+		{"5.1", 48000, 6, []int16{1, 2, 3, 4, 5, 6, -1, -2, -3, -4, -5, -6}},
+		{"stereo empty", 16000, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			if err := WriteWAV16Interleaved(&buf, tt.rate, tt.channels, tt.samples); err != nil {
+				t.Fatalf("WriteWAV16Interleaved() error = %v", err)
+			}
+
+			data := buf.Bytes()
+			if got := binary.LittleEndian.Uint16(data[22:24]); int(got) != tt.channels {
+				t.Errorf("num channels = %d, want %d", got, tt.channels)
+			}
+			if got := binary.LittleEndian.Uint32(data[28:32]); int(got) != tt.rate*tt.channels*2 {
+				t.Errorf("byte rate = %d, want %d", got, tt.rate*tt.channels*2)
+			}
+			if got := binary.LittleEndian.Uint16(data[32:34]); int(got) != tt.channels*2 {
+				t.Errorf("block align = %d, want %d", got, tt.channels*2)
+			}
+			if got := binary.LittleEndian.Uint32(data[40:44]); int(got) != len(tt.samples)*2 {
+				t.Errorf("data size = %d, want %d", got, len(tt.samples)*2)
+			}
+
+			src, err := Decoder{}.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if src.SampleRate() != tt.rate || src.Channels() != tt.channels {
+				t.Errorf("decoded %d Hz %d ch, want %d Hz %d ch", src.SampleRate(), src.Channels(), tt.rate, tt.channels)
+			}
+
+			got := make([]float32, len(tt.samples)+1)
+			n, _ := src.ReadSamples(got)
+			if n != len(tt.samples) {
+				t.Fatalf("decoded %d samples, want %d", n, len(tt.samples))
+			}
+			for i, want := range tt.samples {
+				if int16(got[i]*32768) != want {
+					t.Errorf("sample %d = %v, want %d", i, got[i]*32768, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteWAV16Interleaved_InvalidChannels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		channels int
+		samples  []int16
+	}{
+		{"zero channels", 0, []int16{1, 2}},
+		{"negative channels", -2, []int16{1, 2}},
+		{"partial frame", 2, []int16{1, 2, 3}},
+		{"too many channels", 1 << 16, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			err := WriteWAV16Interleaved(&buf, 8000, tt.channels, tt.samples)
+			if !errors.Is(err, ErrInvalidChannelCount) {
+				t.Errorf("WriteWAV16Interleaved() error = %v, want ErrInvalidChannelCount", err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("wrote %d bytes on error", buf.Len())
+			}
+		})
+	}
+}
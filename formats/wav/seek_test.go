@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// rampPCM renders frames of 16-bit stereo PCM where frame i holds i and -i
+func rampPCM(frames int) []byte {
+	var b []byte
+	for i := range frames {
+		b = binary.LittleEndian.AppendUint16(b, uint16(int16(i)))
+		b = binary.LittleEndian.AppendUint16(b, uint16(int16(-i)))
+	}
+	return b
+}
+
+func TestSource_SeekFrame(t *testing.T) {
+	t.Parallel()
+
+	const frames = 10
+	pcm := rampPCM(frames)
+
+	tests := []struct {
+		name string
+		file []byte
+		// seekPastEnd reports whether the length is unknown, so frames
+		// beyond the data can be seeked to
+		seekPastEnd bool
+	}{
+		{
+			name: "canonical",
+			file: riffFile(riffChunk("fmt ", fmtBody(8000, 2)), riffChunk("data", pcm)),
+		},
+		{
+			name: "chunks around data",
+			file: riffFile(
+				riffChunk("fmt ", fmtBody(8000, 2)),
+				riffChunk("JUNK", make([]byte, 27)),
+				riffChunk("data", pcm),
+				riffChunk("LIST", []byte("INFOtrailing metadata")),
+			),
+		},
+		{
+			name:        "unknown size",
+			file:        append(pcm16Header(8000, 2, UnknownSize), pcm...),
+			seekPastEnd: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := Decoder{}.Decode(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			s, ok := src.(audio.Seeker)
+			if !ok {
+				t.Fatal("source does not implement audio.Seeker")
+			}
+
+			// Read a little, then jump ahead
+			buf := make([]float32, 4)
+			if _, err := src.ReadSamples(buf); err != nil {
+				t.Fatalf("ReadSamples() error = %v", err)
+			}
+			if got := s.Position(); got != 2 {
+				t.Errorf("Position() after 2 frames = %d, want 2", got)
+			}
+
+			for _, frame := range []int64{7, 3, 0, 9} {
+				if err := s.SeekFrame(frame); err != nil {
+					t.Fatalf("SeekFrame(%d) error = %v", frame, err)
+				}
+				if got := s.Position(); got != frame {
+					t.Errorf("Position() = %d, want %d", got, frame)
+				}
+				n, err := src.ReadSamples(buf[:2])
+				if err != nil || n != 2 {
+					t.Fatalf("ReadSamples() after SeekFrame(%d) = %d, %v", frame, n, err)
+				}
+				want := float32(frame) / 32768
+				if buf[0] != want || buf[1] != -want {
+					t.Errorf("frame %d = %v, want [%v %v]", frame, buf[:2], want, -want)
+				}
+			}
+
+			// The end is a valid position with nothing left to read
+			if err := s.SeekFrame(frames); err != nil {
+				t.Fatalf("SeekFrame(end) error = %v", err)
+			}
+			if n, err := src.ReadSamples(buf); n != 0 || !errors.Is(err, io.EOF) {
+				t.Errorf("ReadSamples() at end = %d, %v, want 0, EOF", n, err)
+			}
+
+			if err := s.SeekFrame(-1); !errors.Is(err, audio.ErrSeekOutOfRange) {
+				t.Errorf("SeekFrame(-1) error = %v, want ErrSeekOutOfRange", err)
+			}
+			err = s.SeekFrame(frames + 1)
+			if tt.seekPastEnd {
+				if err != nil {
+					t.Errorf("SeekFrame(past end) error = %v, want nil for unknown length", err)
+				}
+				if n, err := src.ReadSamples(buf); n != 0 || !errors.Is(err, io.EOF) {
+					t.Errorf("ReadSamples() past end = %d, %v, want 0, EOF", n, err)
+				}
+			} else if !errors.Is(err, audio.ErrSeekOutOfRange) {
+				t.Errorf("SeekFrame(past end) error = %v, want ErrSeekOutOfRange", err)
+			}
+
+			// Rewinding after EOF reads the whole stream again
+			if err := s.SeekFrame(0); err != nil {
+				t.Fatalf("SeekFrame(0) error = %v", err)
+			}
+			var total int
+			for {
+				n, err := src.ReadSamples(buf)
+				total += n
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("reading after rewind: %v", err)
+				}
+			}
+			if total != frames*2 {
+				t.Errorf("read %d samples after rewind, want %d", total, frames*2)
+			}
+		})
+	}
+}
+
+func TestSource_NotSeekable(t *testing.T) {
+	t.Parallel()
+
+	file := riffFile(riffChunk("fmt ", fmtBody(8000, 2)), riffChunk("data", rampPCM(4)))
+	src, err := Decoder{}.Decode(struct{ io.Reader }{bytes.NewReader(file)})
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if _, ok := src.(audio.Seeker); ok {
+		t.Error("source over a plain io.Reader implements audio.Seeker")
+	}
+}
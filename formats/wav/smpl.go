@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// idSmpl is the sampler chunk, marking the loops of music made to repeat
+var idSmpl = [4]byte{'s', 'm', 'p', 'l'}
+
+// maxSmplSize bounds the smpl chunk read into memory
+const maxSmplSize = 1 << 16
+
+// smplLoopForward is the loop type of a loop played forward; alternating
+// and backward loops cannot be played by seeking and are ignored
+const smplLoopForward = 0
+
+// LoopPoints returns the first forward loop of the smpl chunk, which
+// audio.Looper repeats instead of the whole file. A smpl chunk after the
+// data is only found when the file can seek.
+func (s *source) LoopPoints() (audio.LoopRegion, bool) {
+	return s.loop, s.loop.End > s.loop.Start
+}
+
+// parseSmpl reads the first forward loop of a smpl chunk body, or returns
+// an empty region when there is none. A malformed chunk, as metadata, does
+// not stop the samples from being decoded: it yields no loop.
+func parseSmpl(r io.Reader, size uint32) audio.LoopRegion {
+	if size < 36 || size > maxSmplSize {
+		return audio.LoopRegion{}
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return audio.LoopRegion{}
+	}
+
+	n := int(binary.LittleEndian.Uint32(body[28:]))
+	for i := 0; i < n && 36+24*(i+1) <= len(body); i++ {
+		p := body[36+24*i:]
+		start, end := binary.LittleEndian.Uint32(p[8:]), binary.LittleEndian.Uint32(p[12:])
+		if binary.LittleEndian.Uint32(p[4:]) == smplLoopForward && start <= end {
+			// The end is the last frame of the loop
+			return audio.LoopRegion{Start: int64(start), End: int64(end) + 1}
+		}
+	}
+	return audio.LoopRegion{}
+}
+
+// trailingLoop looks for a smpl chunk after a data chunk of a known size,
+// as tools writing the chunk once the samples are known put it, and seeks
+// back to the samples. The chunks up to the end of the file, or to a file
+// concatenated after it, are walked.
+func trailingLoop(rs io.ReadSeeker, start, size int64) (audio.LoopRegion, error) {
+	if _, err := rs.Seek(start+size+size&1, io.SeekStart); err != nil {
+		return audio.LoopRegion{}, fmt.Errorf("%w", err)
+	}
+
+	var loop audio.LoopRegion
+	walker := &chunkWalker{r: rs}
+	for {
+		id, size, err := walker.next()
+		if err != nil || id == idRIFF {
+			// Whatever is malformed after the samples is left to
+			// ReadSamples to find, as when not seeking
+			if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return audio.LoopRegion{}, err
+			}
+			break
+		}
+		if id == idSmpl {
+			loop = parseSmpl(walker, size)
+			break
+		}
+	}
+
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return audio.LoopRegion{}, fmt.Errorf("%w", err)
+	}
+	return loop, nil
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// smplBody renders a smpl chunk body with one loop per start, end and type
+// triple
+func smplBody(loops ...[3]uint32) []byte {
+	b := make([]byte, 28)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(loops)))
+	b = binary.LittleEndian.AppendUint32(b, 0) // sampler data
+	for i, l := range loops {
+		b = binary.LittleEndian.AppendUint32(b, uint32(i)) // cue point ID
+		b = binary.LittleEndian.AppendUint32(b, l[2])
+		b = binary.LittleEndian.AppendUint32(b, l[0])
+		b = binary.LittleEndian.AppendUint32(b, l[1])
+		b = binary.LittleEndian.AppendUint32(b, 0) // fraction
+		b = binary.LittleEndian.AppendUint32(b, 0) // play count, 0 for no end
+	}
+	return b
+}
+
+func TestSource_LoopPoints(t *testing.T) {
+	t.Parallel()
+
+	const frames = 10
+	var (
+		fmtChunk  = riffChunk("fmt ", fmtBody(8000, 2))
+		dataChunk = riffChunk("data", rampPCM(frames))
+		loop      = riffChunk("smpl", smplBody([3]uint32{2, 5, 0}))
+	)
+
+	tests := []struct {
+		name   string
+		file   []byte
+		stream bool
+		want   audio.LoopRegion
+		found  bool
+	}{
+		{name: "none", file: riffFile(fmtChunk, dataChunk)},
+		{name: "before the data", file: riffFile(fmtChunk, loop, dataChunk), want: audio.LoopRegion{Start: 2, End: 6}, found: true},
+		{name: "before the data, streamed", file: riffFile(fmtChunk, loop, dataChunk), stream: true, want: audio.LoopRegion{Start: 2, End: 6}, found: true},
+		{name: "after the data", file: riffFile(fmtChunk, dataChunk, riffChunk("LIST", []byte("INFO")), loop), want: audio.LoopRegion{Start: 2, End: 6}, found: true},
+		{name: "after the data, streamed", file: riffFile(fmtChunk, dataChunk, loop), stream: true},
+		{
+			name: "first forward loop",
+			file: riffFile(fmtChunk, riffChunk("smpl", smplBody([3]uint32{0, 9, 1}, [3]uint32{4, 4, 0})), dataChunk),
+			want: audio.LoopRegion{Start: 4, End: 5}, found: true,
+		},
+		{name: "malformed", file: riffFile(fmtChunk, riffChunk("smpl", make([]byte, 12)), dataChunk)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var r io.Reader = bytes.NewReader(tt.file)
+			if tt.stream {
+				r = struct{ io.Reader }{r}
+			}
+			src, err := Decoder{}.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			got, found := src.(audio.LoopPoints).LoopPoints()
+			if got != tt.want || found != tt.found {
+				t.Errorf("LoopPoints() = %v, %v, want %v, %v", got, found, tt.want, tt.found)
+			}
+
+			// The samples read the same
+			if samples := readFrames(src); !slices.Equal(samples, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+				t.Errorf("read frames %v", samples)
+			}
+		})
+	}
+}
+
+func TestSource_LoopPoints_Looper(t *testing.T) {
+	t.Parallel()
+
+	file := riffFile(riffChunk("fmt ", fmtBody(8000, 2)), riffChunk("data", rampPCM(8)), riffChunk("smpl", smplBody([3]uint32{3, 4, 0})))
+	src, err := Decoder{}.Decode(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := audio.Loop(src, 3)
+	if err != nil {
+		t.Fatalf("Loop() error = %v", err)
+	}
+
+	got := readFrames(l)
+	want := []int{0, 1, 2, 3, 4, 3, 4, 3, 4, 5, 6, 7}
+	if !slices.Equal(got, want) {
+		t.Errorf("played frames %v, want %v", got, want)
+	}
+}
+
+// readFrames reads the left channel of a rampPCM source to its end
+func readFrames(src audio.Source) []int {
+	var frames []int
+	buf := make([]float32, 6)
+	for {
+		n, err := src.ReadSamples(buf)
+		for i := 0; i < n; i += 2 {
+			frames = append(frames, int(buf[i]*32768))
+		}
+		if err != nil {
+			return frames
+		}
+	}
+}
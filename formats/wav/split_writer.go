@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/utils"
+)
+
+// WriteWAV16Split decodes src once and writes every channel to its own mono
+// 16-bit PCM WAV, dsts[0] receiving channel 0 (left), dsts[1] channel 1
+// (right) and so on. This is the usual way to hand a stereo call recording
+// with one party per channel to a transcription service.
+//
+// The number of writers must match src.Channels(). Like WriteWAV16 the
+// samples are collected in memory before the files are written.
+func WriteWAV16Split(src audio.Source, dsts ...io.Writer) error {
+	channels := src.Channels()
+	if channels <= 0 || len(dsts) != channels {
+		return fmt.Errorf("%w: %d channels, %d writers", ErrChannelWriterMismatch, channels, len(dsts))
+	}
+
+	legs := make([][]int16, channels)
+	buf := make([]float32, 4096-4096%channels)
+
+	for {
+		n, err := src.ReadSamples(buf)
+		frames := n / channels
+		for f := range frames {
+			for c := range channels {
+				legs[c] = append(legs[c], utils.Float32ToInt16(buf[f*channels+c]))
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading source: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	for c, w := range dsts {
+		if err := WriteWAV16(w, src.SampleRate(), legs[c]); err != nil {
+			return fmt.Errorf("writing channel %d: %w", c, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestWriteWAV16Split_Stereo(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewMockSource(8000, 2, 1000, func(sample int, channel int) float32 {
+		if channel == 0 {
+			return 0.25
+		}
+		return -0.5
+	})
+
+	var left, right bytes.Buffer
+	if err := WriteWAV16Split(src, &left, &right); err != nil {
+		t.Fatalf("WriteWAV16Split() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want float32
+	}{
+		{"left", left.Bytes(), 0.25},
+		{"right", right.Bytes(), -0.5},
+	}
+
+	for _, tt := range tests {
+		dec, err := Decoder{}.Decode(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s: Decode() error = %v", tt.name, err)
+		}
+		if dec.Channels() != 1 || dec.SampleRate() != 8000 {
+			t.Errorf("%s: got %d ch @ %d Hz, want mono @ 8000 Hz", tt.name, dec.Channels(), dec.SampleRate())
+		}
+
+		buf := make([]float32, 2000)
+		n, err := dec.ReadSamples(buf)
+		if err != nil && err != io.EOF {
+			t.Fatalf("%s: ReadSamples() error = %v", tt.name, err)
+		}
+		if n != 1000 {
+			t.Errorf("%s: read %d samples, want 1000", tt.name, n)
+		}
+		for i := range n {
+			if buf[i] != tt.want {
+				t.Fatalf("%s: sample %d = %v, want %v", tt.name, i, buf[i], tt.want)
+			}
+		}
+	}
+}
+
+func TestWriteWAV16Split_WriterMismatch(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewSilentSource(8000, 2, 10)
+
+	err := WriteWAV16Split(src, new(bytes.Buffer))
+	if !errors.Is(err, ErrChannelWriterMismatch) {
+		t.Errorf("WriteWAV16Split() error = %v, want ErrChannelWriterMismatch", err)
+	}
+}
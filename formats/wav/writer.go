@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/utils"
+)
+
+const (
+	// HeaderSize is the size of the canonical 16-bit PCM header written by
+	// WriteWAV16 and Writer.
+	HeaderSize = 44
+
+	// UnknownSize is written as the RIFF and data chunk sizes of a stream
+	// whose length is not known, e.g. one written to a pipe. It is the
+	// largest even chunk size, as readers that pad odd sizes would overflow
+	// on 0xFFFFFFFF.
+	UnknownSize = math.MaxUint32 - 1
+)
+
+// pcm16Header returns the canonical header of a 16-bit PCM file. A dataSize
+// of UnknownSize marks both sizes as unknown.
+func pcm16Header(rate, channels int, dataSize uint32) []byte {
+	header := make([]byte, HeaderSize)
+	riffSize := uint32(UnknownSize)
+	if dataSize != UnknownSize {
+		riffSize = 36 + dataSize
+	}
+
+	// RIFF header (12 bytes)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], riffSize)
+	copy(header[8:12], "WAVE")
+
+	// fmt chunk (24 bytes)
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16) // PCM fmt chunk size
+	binary.LittleEndian.PutUint16(header[20:22], formatPCM)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(channels))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(rate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(rate*channels*2))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(channels*2))
+	binary.LittleEndian.PutUint16(header[34:36], 16)
+
+	// data chunk header (8 bytes)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], dataSize)
+
+	return header
+}
+
+// Writer encodes 16-bit PCM WAV incrementally, so recordings of any length
+// can be written without holding them in memory.
+//
+// The header is written by NewWriter with both sizes set to UnknownSize.
+// If the underlying writer is an io.WriteSeeker (such as *os.File), Close
+// seeks back and fills in the real sizes; otherwise the markers stay, which
+// this package's Decoder and most players read as "until the end of the
+// file".
+type Writer struct {
+	w         io.Writer
+	rate      int
+	nominal   int // rate declared in the header
+	channels  int
+	start     int64 // offset of the header, -1 when w cannot seek
+	data      int64 // bytes of sample data written
+	buf       []byte
+	err       error
+	closed    bool
+	cues      []Cue
+	appending bool  // continuing a file, see WithAppend
+	appended  int64 // frames the file continued already holds
+}
+
+// WriterOption configures a Writer.
+type WriterOption func(*Writer)
+
+// WithNominalRate declares rate in the header instead of the rate of the
+// data, without resampling. Players then run the audio faster or slower,
+// changing speed and pitch together like a varispeed tape deck, which is
+// how speed errors of digitized tapes and records are corrected: a 45 rpm
+// transfer of a 33⅓ rpm record captured at 48 kHz is fixed by declaring
+// 48000*33.333/45 ≈ 35556 Hz.
+func WithNominalRate(rate int) WriterOption {
+	return func(w *Writer) {
+		w.nominal = rate
+	}
+}
+
+// WithAppend continues a file that a Writer with the same format and
+// options left unfinished, holding frames sample frames, as a job resumed
+// after a crash does. NewWriter writes no header then, w must be
+// positioned right after those frames, and Close fills in the sizes of
+// the whole file.
+func WithAppend(frames int64) WriterOption {
+	return func(w *Writer) {
+		w.appending, w.appended = true, frames
+	}
+}
+
+// NewWriter writes a WAV header for format to w, unless WithAppend is
+// given, and returns a Writer for its samples. Only format.Rate and
+// format.Channels are used; samples are always stored as 16-bit PCM.
+func NewWriter(w io.Writer, format audio.Format, opts ...WriterOption) (*Writer, error) {
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if format.Channels > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidChannelCount, format.Channels)
+	}
+
+	ww := &Writer{w: w, rate: format.Rate, nominal: format.Rate, channels: format.Channels, start: -1}
+	for _, opt := range opts {
+		opt(ww)
+	}
+	if ww.nominal <= 0 {
+		return nil, fmt.Errorf("%w: nominal rate %d Hz", audio.ErrInvalidSampleRate, ww.nominal)
+	}
+
+	if ww.appending {
+		if ww.appended < 0 {
+			return nil, fmt.Errorf("%w: appending to %d frames", ErrNegativePosition, ww.appended)
+		}
+		ww.data = ww.appended * int64(2*format.Channels)
+	}
+
+	if s, ok := w.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			ww.start = pos
+			if ww.appending {
+				ww.start = max(pos-HeaderSize-ww.data, -1)
+			}
+		}
+	}
+	if ww.appending {
+		return ww, nil
+	}
+
+	if _, err := w.Write(pcm16Header(ww.nominal, format.Channels, UnknownSize)); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return ww, nil
+}
+
+// SampleRate returns the rate of the data written, which WriteSource
+// requires of its source.
+func (w *Writer) SampleRate() int { return w.rate }
+func (w *Writer) Channels() int   { return w.channels }
+
+// NominalRate returns the rate declared in the header.
+func (w *Writer) NominalRate() int { return w.nominal }
+
+// Frames returns the number of sample frames written so far.
+func (w *Writer) Frames() int64 { return w.data / int64(2*w.channels) }
+
+// WriteSamples encodes interleaved float32 samples in [-1, 1]; values
+// outside are clipped. len(samples) must be a multiple of Channels().
+func (w *Writer) WriteSamples(samples []float32) error {
+	if err := w.check(len(samples)); err != nil {
+		return err
+	}
+
+	buf := w.buffer(len(samples) * 2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(utils.Float32ToInt16(s)))
+	}
+	return w.write(buf)
+}
+
+// WriteInt16 encodes interleaved int16 samples. len(samples) must be a
+// multiple of Channels().
+func (w *Writer) WriteInt16(samples []int16) error {
+	if err := w.check(len(samples)); err != nil {
+		return err
+	}
+
+	buf := w.buffer(len(samples) * 2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(s))
+	}
+	return w.write(buf)
+}
+
+// WriteSource copies src to the end and returns the number of sample
+// frames written. src must have the rate and channel count of the Writer.
+// It does not close src.
+func (w *Writer) WriteSource(src audio.Source) (int64, error) {
+	if src.SampleRate() != w.rate || src.Channels() != w.channels {
+		return 0, fmt.Errorf("%w: source is %d Hz %d ch, writer %d Hz %d ch",
+			audio.ErrFormatMismatch, src.SampleRate(), src.Channels(), w.rate, w.channels)
+	}
+
+	size := max(src.BufSize(), 1024)
+	buf := make([]float32, size-size%w.channels)
+	var frames int64
+	for {
+		n, err := src.ReadSamples(buf)
+		n -= n % w.channels
+		if n > 0 {
+			if werr := w.WriteSamples(buf[:n]); werr != nil {
+				return frames, werr
+			}
+			frames += int64(n / w.channels)
+		}
+
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, fmt.Errorf("reading source: %w", err)
+		}
+	}
+}
+
+// Close writes the cues added and fills in the sizes in the header when
+// the underlying writer can seek, leaving it positioned at the end of the
+// file. It does not close the underlying writer. Writes after Close fail
+// with ErrWriterClosed.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+
+	// Files beyond 4 GiB keep the unknown size markers, and lose their cues
+	trailer := cueChunks(w.cues)
+	if w.err != nil || w.start < 0 || w.data > UnknownSize-36-int64(len(trailer)) {
+		return w.err
+	}
+
+	ws, ok := w.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+
+	if _, err := ws.Write(trailer); err != nil {
+		return fmt.Errorf("writing cues: %w", err)
+	}
+
+	var b [4]byte
+	for _, patch := range []struct {
+		offset int64
+		value  uint32
+	}{
+		{4, uint32(36 + w.data + int64(len(trailer)))},
+		{40, uint32(w.data)},
+	} {
+		binary.LittleEndian.PutUint32(b[:], patch.value)
+		if _, err := ws.Seek(w.start+patch.offset, io.SeekStart); err != nil {
+			return fmt.Errorf("patching header: %w", err)
+		}
+		if _, err := ws.Write(b[:]); err != nil {
+			return fmt.Errorf("patching header: %w", err)
+		}
+	}
+
+	if _, err := ws.Seek(w.start+HeaderSize+w.data+int64(len(trailer)), io.SeekStart); err != nil {
+		return fmt.Errorf("patching header: %w", err)
+	}
+	return nil
+}
+
+// check validates a write of n samples
+func (w *Writer) check(n int) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if n%w.channels != 0 {
+		return fmt.Errorf("%w: %d samples for %d channels", ErrInvalidChannelCount, n, w.channels)
+	}
+	return nil
+}
+
+func (w *Writer) buffer(size int) []byte {
+	if cap(w.buf) < size {
+		w.buf = make([]byte, size)
+	}
+	return w.buf[:size]
+}
+
+// write writes encoded samples. A failed write leaves the file in an
+// unknown state, so the error is returned by every later call.
+func (w *Writer) write(buf []byte) error {
+	n, err := w.w.Write(buf)
+	w.data += int64(n)
+	if err != nil {
+		w.err = fmt.Errorf("%w", err)
+		return w.err
+	}
+	return nil
+}
+
+// Encode writes src to w as a 16-bit PCM WAV file, streaming it with a
+// Writer configured by opts. It does not close src or w.
+func Encode(w io.Writer, src audio.Source, opts ...WriterOption) error {
+	ww, err := NewWriter(w, audio.FormatOf(src), opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := ww.WriteSource(src); err != nil {
+		return err
+	}
+	return ww.Close()
+}
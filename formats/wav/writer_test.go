@@ -0,0 +1,340 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// errWriter fails every write after the first ok ones
+type errWriter struct {
+	ok int
+}
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.ok == 0 {
+		return 0, audiotest.ErrInjected
+	}
+	w.ok--
+	return len(p), nil
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		channels int
+		seekable bool
+		prefix   int // bytes written to the file before the WAV
+	}{
+		{name: "file mono", channels: 1, seekable: true},
+		{name: "file stereo", channels: 2, seekable: true},
+		{name: "file at offset", channels: 2, seekable: true, prefix: 10},
+		{name: "stream", channels: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				file *os.File
+				out  io.Writer
+				buf  bytes.Buffer
+			)
+			if tt.seekable {
+				var err error
+				file, err = os.Create(filepath.Join(t.TempDir(), "out.wav"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+				_, _ = file.Write(make([]byte, tt.prefix))
+				out = file
+			} else {
+				out = &buf
+			}
+
+			w, err := NewWriter(out, audio.Format{Rate: 8000, Channels: tt.channels})
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+
+			// Two float writes and one int16 write
+			var want []int16
+			for i := range 3 {
+				chunk := make([]int16, 100*tt.channels)
+				for j := range chunk {
+					chunk[j] = int16((i*1000 + j*37) % 30000)
+				}
+				want = append(want, chunk...)
+
+				if i == 2 {
+					err = w.WriteInt16(chunk)
+				} else {
+					f := make([]float32, len(chunk))
+					for j, v := range chunk {
+						f[j] = float32(v) / 32768
+					}
+					err = w.WriteSamples(f)
+				}
+				if err != nil {
+					t.Fatalf("write %d error = %v", i, err)
+				}
+			}
+			if w.Frames() != 300 {
+				t.Errorf("Frames() = %d, want 300", w.Frames())
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			var data []byte
+			wantSize := uint32(len(want) * 2)
+			if tt.seekable {
+				end, _ := file.Seek(0, io.SeekCurrent)
+				if end != int64(tt.prefix+HeaderSize+len(want)*2) {
+					t.Errorf("file positioned at %d after Close, want the end", end)
+				}
+				data, _ = os.ReadFile(file.Name())
+				data = data[tt.prefix:]
+			} else {
+				data = buf.Bytes()
+				wantSize = UnknownSize
+			}
+
+			if got := binary.LittleEndian.Uint32(data[40:44]); got != wantSize {
+				t.Errorf("data size = %#x, want %#x", got, wantSize)
+			}
+			if tt.seekable {
+				if got := binary.LittleEndian.Uint32(data[4:8]); got != 36+wantSize {
+					t.Errorf("RIFF size = %d, want %d", got, 36+wantSize)
+				}
+				if ref, _, chans := referenceDecode(t, data); len(ref) != len(want) || chans != tt.channels {
+					t.Errorf("reference decoded %d samples, %d ch", len(ref), chans)
+				}
+			}
+
+			got, src := ownDecode(t, data)
+			if src.Channels() != tt.channels {
+				t.Errorf("decoded %d ch, want %d", src.Channels(), tt.channels)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("decoded %d samples, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("sample %d = %d, want %d", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	src := &int16Source{rate: 16000, channels: 2, data: conformanceSignals["extremes"](2000)}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, src); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	got, dec := ownDecode(t, buf.Bytes())
+	if dec.SampleRate() != 16000 || dec.Channels() != 2 {
+		t.Errorf("decoded %d Hz %d ch, want 16000 Hz 2 ch", dec.SampleRate(), dec.Channels())
+	}
+	for i, want := range src.data {
+		if got[i] != want {
+			t.Fatalf("sample %d = %d, want %d", i, got[i], want)
+		}
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid format", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := NewWriter(io.Discard, audio.Format{Rate: 0, Channels: 1}); err == nil {
+			t.Error("NewWriter() error = nil for 0 Hz")
+		}
+		if _, err := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 1 << 16}); !errors.Is(err, ErrInvalidChannelCount) {
+			t.Errorf("NewWriter() error = %v, want ErrInvalidChannelCount", err)
+		}
+	})
+
+	t.Run("header write", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := NewWriter(&errWriter{}, audio.Format{Rate: 8000, Channels: 1}); !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("NewWriter() error = %v, want %v", err, audiotest.ErrInjected)
+		}
+	})
+
+	t.Run("partial frame", func(t *testing.T) {
+		t.Parallel()
+
+		w, _ := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 2})
+		if err := w.WriteSamples(make([]float32, 3)); !errors.Is(err, ErrInvalidChannelCount) {
+			t.Errorf("WriteSamples() error = %v, want ErrInvalidChannelCount", err)
+		}
+	})
+
+	t.Run("write after close", func(t *testing.T) {
+		t.Parallel()
+
+		w, _ := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 1})
+		_ = w.Close()
+		if err := w.WriteInt16([]int16{1}); !errors.Is(err, ErrWriterClosed) {
+			t.Errorf("WriteInt16() error = %v, want ErrWriterClosed", err)
+		}
+	})
+
+	t.Run("sticky write error", func(t *testing.T) {
+		t.Parallel()
+
+		w, _ := NewWriter(&errWriter{ok: 1}, audio.Format{Rate: 8000, Channels: 1})
+		if err := w.WriteInt16([]int16{1}); !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("WriteInt16() error = %v, want %v", err, audiotest.ErrInjected)
+		}
+		if err := w.Close(); !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("Close() error = %v, want %v", err, audiotest.ErrInjected)
+		}
+	})
+
+	t.Run("source format mismatch", func(t *testing.T) {
+		t.Parallel()
+
+		w, _ := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 1})
+		if _, err := w.WriteSource(audiotest.NewSilentSource(16000, 1, 10)); !errors.Is(err, audio.ErrFormatMismatch) {
+			t.Errorf("WriteSource() error = %v, want ErrFormatMismatch", err)
+		}
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		t.Parallel()
+
+		w, _ := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 1})
+		src := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 8000), audiotest.WithShortReads(100), audiotest.WithErrorOnCall(3, audiotest.ErrInjected))
+		frames, err := w.WriteSource(src)
+		if !errors.Is(err, audiotest.ErrInjected) || frames != 200 {
+			t.Errorf("WriteSource() = %d, %v, want 200, %v", frames, err, audiotest.ErrInjected)
+		}
+	})
+}
+
+func TestWriter_NominalRate(t *testing.T) {
+	t.Parallel()
+
+	src := &int16Source{rate: 48000, channels: 2, data: conformanceSignals["ramp"](960)}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, src, WithNominalRate(35556)); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	data := buf.Bytes()
+	if got := binary.LittleEndian.Uint32(data[24:28]); got != 35556 {
+		t.Errorf("header rate = %d, want 35556", got)
+	}
+	if got := binary.LittleEndian.Uint32(data[28:32]); got != 35556*4 {
+		t.Errorf("byte rate = %d, want %d", got, 35556*4)
+	}
+
+	// The samples are stored untouched
+	got, dec := ownDecode(t, data)
+	if dec.SampleRate() != 35556 {
+		t.Errorf("decoded rate = %d, want 35556", dec.SampleRate())
+	}
+	if len(got) != len(src.data) {
+		t.Fatalf("decoded %d samples, want %d", len(got), len(src.data))
+	}
+	for i, want := range src.data {
+		if got[i] != want {
+			t.Fatalf("sample %d = %d, want %d", i, got[i], want)
+		}
+	}
+
+	w, _ := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 1}, WithNominalRate(7900))
+	if w.SampleRate() != 8000 || w.NominalRate() != 7900 {
+		t.Errorf("SampleRate() = %d, NominalRate() = %d, want 8000, 7900", w.SampleRate(), w.NominalRate())
+	}
+	if _, err := w.WriteSource(audiotest.NewSilentSource(8000, 1, 100)); err != nil {
+		t.Errorf("WriteSource() at the data rate error = %v", err)
+	}
+
+	if _, err := NewWriter(io.Discard, audio.Format{Rate: 8000, Channels: 1}, WithNominalRate(0)); !errors.Is(err, audio.ErrInvalidSampleRate) {
+		t.Errorf("NewWriter() error = %v, want ErrInvalidSampleRate", err)
+	}
+}
+
+func TestWriter_Append(t *testing.T) {
+	t.Parallel()
+
+	format := audio.Format{Rate: 8000, Channels: 2}
+	samples := conformanceSignals["ramp"](800)
+	dir := t.TempDir()
+
+	// One run
+	var want bytes.Buffer
+	if err := WriteWAV16Interleaved(&want, 8000, 2, samples); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run cut off after 300 of 400 frames, then continued
+	path := filepath.Join(dir, "resumed.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWriter(f, format)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	if err := w.WriteInt16(samples[:600]); err != nil {
+		t.Fatalf("WriteInt16() error = %v", err)
+	}
+	f.Close()
+
+	if f, err = os.OpenFile(path, os.O_RDWR, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if w, err = NewWriter(f, format, WithAppend(300)); err != nil {
+		t.Fatalf("NewWriter(WithAppend) error = %v", err)
+	}
+	if err := w.WriteInt16(samples[600:]); err != nil {
+		t.Fatalf("WriteInt16() error = %v", err)
+	}
+	if w.Frames() != 400 {
+		t.Errorf("Frames() = %d, want 400", w.Frames())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("continued file (%d bytes) differs from one written at once (%d bytes)", len(got), want.Len())
+	}
+
+	if _, err := NewWriter(io.Discard, format, WithAppend(-1)); !errors.Is(err, ErrNegativePosition) {
+		t.Errorf("NewWriter(WithAppend(-1)) error = %v, want ErrNegativePosition", err)
+	}
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package objstore streams audio to and from object stores such as S3, GCS
+// or MinIO without local temporary files, and without depending on any
+// vendor SDK.
+//
+// # Writing
+//
+// MultipartWriter is an io.WriteCloser that cuts the stream into parts of a
+// fixed size and hands every part to a writer obtained from a PartFunc,
+// which typically wraps one UploadPart call of a multipart upload:
+//
+//	w := objstore.NewMultipartWriter(func(part int) (io.WriteCloser, error) {
+//	    return uploadPart(ctx, bucket, key, uploadID, part), nil
+//	}, objstore.DefaultPartSize)
+//	err := wav.WriteWAV16(w, 8000, samples)
+//	err = w.Close() // uploads the last part; then complete the upload
+//
+// It can also be returned from record.Options.Create to record straight
+// into a bucket.
+//
+// # Reading
+//
+// RangeReaderAt turns ranged GET requests into an io.ReaderAt with a small
+// block cache. Open wraps it in an io.SectionReader, which is an
+// io.ReadSeeker, so the WAV and AIFF decoders read the object in place
+// instead of loading it into memory first:
+//
+//	obj := objstore.Open(func(off, n int64) (io.ReadCloser, error) {
+//	    return getRange(ctx, bucket, key, off, n)
+//	}, size, 0)
+//	src, err := wav.Decoder{}.Decode(obj)
+package objstore
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package objstore
+
+import "errors"
+
+var (
+	ErrWriterClosed   = errors.New("multipart writer is closed")
+	ErrNegativeOffset = errors.New("negative offset")
+	ErrShortRange     = errors.New("range read returned fewer bytes than requested")
+)
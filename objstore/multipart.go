@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package objstore
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultPartSize is the smallest part size S3 accepts for every part but
+// the last one.
+const DefaultPartSize = 5 << 20
+
+// PartFunc returns the writer for part number part, counting from 1 as
+// multipart uploads do. The part is complete when the writer is closed.
+type PartFunc func(part int) (io.WriteCloser, error)
+
+// MultipartWriter buffers a stream and writes it out in parts of a fixed
+// size. Only one part is held in memory at a time.
+type MultipartWriter struct {
+	newPart  PartFunc
+	partSize int
+	buf      []byte
+	parts    int
+	written  int64
+	err      error
+	closed   bool
+}
+
+// NewMultipartWriter creates a writer that emits parts of partSize bytes
+// (the last one may be smaller). A partSize <= 0 uses DefaultPartSize.
+func NewMultipartWriter(newPart PartFunc, partSize int) *MultipartWriter {
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+
+	return &MultipartWriter{
+		newPart:  newPart,
+		partSize: partSize,
+		buf:      make([]byte, 0, partSize),
+	}
+}
+
+// Parts returns the number of parts written so far.
+func (w *MultipartWriter) Parts() int { return w.parts }
+
+// Size returns the number of bytes accepted so far.
+func (w *MultipartWriter) Size() int64 { return w.written }
+
+func (w *MultipartWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), w.partSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		w.written += int64(n)
+
+		if len(w.buf) == w.partSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close writes the buffered data as the last part. A stream that was never
+// written to still produces one empty part, so the object exists.
+func (w *MultipartWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) > 0 || w.parts == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the buffer as the next part. A failed part fails the writer.
+func (w *MultipartWriter) flush() error {
+	part := w.parts + 1
+
+	pw, err := w.newPart(part)
+	if err != nil {
+		w.err = fmt.Errorf("opening part %d: %w", part, err)
+		return w.err
+	}
+
+	if _, err := pw.Write(w.buf); err != nil {
+		_ = pw.Close()
+		w.err = fmt.Errorf("writing part %d: %w", part, err)
+		return w.err
+	}
+	if err := pw.Close(); err != nil {
+		w.err = fmt.Errorf("closing part %d: %w", part, err)
+		return w.err
+	}
+
+	w.parts = part
+	w.buf = w.buf[:0]
+	return nil
+}
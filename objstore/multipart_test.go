@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package objstore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// fakeUpload records the parts of a multipart upload
+type fakeUpload struct {
+	parts   [][]byte
+	failOn  int
+	failErr error
+}
+
+type fakePart struct {
+	up  *fakeUpload
+	buf bytes.Buffer
+}
+
+func (p *fakePart) Write(b []byte) (int, error) { return p.buf.Write(b) }
+
+func (p *fakePart) Close() error {
+	p.up.parts = append(p.up.parts, p.buf.Bytes())
+	return nil
+}
+
+func (u *fakeUpload) newPart(part int) (io.WriteCloser, error) {
+	if part == u.failOn {
+		return nil, u.failErr
+	}
+	if part != len(u.parts)+1 {
+		return nil, errors.New("parts out of order")
+	}
+	return &fakePart{up: u}, nil
+}
+
+func (u *fakeUpload) object() []byte {
+	return bytes.Join(u.parts, nil)
+}
+
+func TestMultipartWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		size      int
+		partSize  int
+		writes    []int
+		wantParts []int
+	}{
+		{name: "single small part", size: 10, partSize: 16, writes: []int{10}, wantParts: []int{10}},
+		{name: "exact multiple", size: 32, partSize: 16, writes: []int{32}, wantParts: []int{16, 16}},
+		{name: "many small writes", size: 40, partSize: 16, writes: []int{3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 1}, wantParts: []int{16, 16, 8}},
+		{name: "empty stream", size: 0, partSize: 16, wantParts: []int{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := make([]byte, tt.size)
+			for i := range data {
+				data[i] = byte(i)
+			}
+
+			up := &fakeUpload{}
+			w := NewMultipartWriter(up.newPart, tt.partSize)
+			rest := data
+			for _, n := range tt.writes {
+				if _, err := w.Write(rest[:n]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				rest = rest[n:]
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if len(up.parts) != len(tt.wantParts) || w.Parts() != len(tt.wantParts) {
+				t.Fatalf("got %d parts (Parts() = %d), want %d", len(up.parts), w.Parts(), len(tt.wantParts))
+			}
+			for i, p := range up.parts {
+				if len(p) != tt.wantParts[i] {
+					t.Errorf("part %d is %d bytes, want %d", i+1, len(p), tt.wantParts[i])
+				}
+			}
+			if !bytes.Equal(up.object(), data) {
+				t.Error("object content differs from the written data")
+			}
+			if w.Size() != int64(tt.size) {
+				t.Errorf("Size() = %d, want %d", w.Size(), tt.size)
+			}
+		})
+	}
+}
+
+func TestMultipartWriter_Errors(t *testing.T) {
+	t.Parallel()
+
+	errUpload := errors.New("upload failed")
+	up := &fakeUpload{failOn: 2, failErr: errUpload}
+	w := NewMultipartWriter(up.newPart, 4)
+
+	if _, err := w.Write(make([]byte, 12)); !errors.Is(err, errUpload) {
+		t.Errorf("Write() error = %v, want %v", err, errUpload)
+	}
+	if _, err := w.Write([]byte{1}); !errors.Is(err, errUpload) {
+		t.Errorf("Write() after failure error = %v, want %v", err, errUpload)
+	}
+	if err := w.Close(); !errors.Is(err, errUpload) {
+		t.Errorf("Close() error = %v, want %v", err, errUpload)
+	}
+	if _, err := w.Write([]byte{1}); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("Write() after Close error = %v, want %v", err, ErrWriterClosed)
+	}
+}
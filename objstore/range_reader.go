@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package objstore
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultBlockSize is the size of the ranged reads RangeReaderAt issues.
+const DefaultBlockSize = 256 << 10
+
+// RangeFunc returns the n bytes of the object starting at off, as a ranged
+// GET ("Range: bytes=off-(off+n-1)") would.
+type RangeFunc func(off, n int64) (io.ReadCloser, error)
+
+// RangeReaderAt implements io.ReaderAt over a RangeFunc. Reads are rounded
+// to whole blocks and the most recent block is cached, so the small
+// sequential reads of a decoder cost one request per block. It is safe for
+// concurrent use.
+type RangeReaderAt struct {
+	fetch     RangeFunc
+	size      int64
+	blockSize int64
+
+	mu       *sync.Mutex
+	blockOff int64
+	block    []byte
+	requests int
+}
+
+// NewRangeReaderAt creates a reader for an object of size bytes. A
+// blockSize <= 0 uses DefaultBlockSize.
+func NewRangeReaderAt(fetch RangeFunc, size int64, blockSize int) *RangeReaderAt {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+
+	return &RangeReaderAt{
+		fetch:     fetch,
+		size:      size,
+		blockSize: int64(blockSize),
+		mu:        &sync.Mutex{},
+		blockOff:  -1,
+	}
+}
+
+// Open returns an io.ReadSeeker over the whole object.
+func Open(fetch RangeFunc, size int64, blockSize int) *io.SectionReader {
+	return io.NewSectionReader(NewRangeReaderAt(fetch, size, blockSize), 0, size)
+}
+
+// Size returns the size of the object.
+func (r *RangeReaderAt) Size() int64 { return r.size }
+
+// Requests returns the number of ranged reads issued so far.
+func (r *RangeReaderAt) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func (r *RangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+
+		blockOff := pos - pos%r.blockSize
+		if blockOff != r.blockOff {
+			if err := r.load(blockOff); err != nil {
+				return n, err
+			}
+		}
+
+		n += copy(p[n:], r.block[pos-blockOff:])
+	}
+
+	return n, nil
+}
+
+// load fetches the block starting at off into the cache
+func (r *RangeReaderAt) load(off int64) error {
+	length := min(r.blockSize, r.size-off)
+
+	rc, err := r.fetch(off, length)
+	if err != nil {
+		return fmt.Errorf("reading range %d-%d: %w", off, off+length-1, err)
+	}
+	defer rc.Close()
+	r.requests++
+
+	if int64(cap(r.block)) < length {
+		r.block = make([]byte, length)
+	}
+	r.block = r.block[:length]
+
+	if _, err := io.ReadFull(rc, r.block); err != nil {
+		r.blockOff = -1
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return fmt.Errorf("%w: range %d-%d", ErrShortRange, off, off+length-1)
+		}
+		return fmt.Errorf("reading range %d-%d: %w", off, off+length-1, err)
+	}
+
+	r.blockOff = off
+	return nil
+}
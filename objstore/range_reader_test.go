@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package objstore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/formats/wav"
+	"github.com/ik5/audpbx/record"
+)
+
+// fakeObject serves ranged reads of data
+func fakeObject(data []byte) RangeFunc {
+	return func(off, n int64) (io.ReadCloser, error) {
+		end := min(off+n, int64(len(data)))
+		return io.NopCloser(bytes.NewReader(data[off:end])), nil
+	}
+}
+
+func TestRangeReaderAt(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	tests := []struct {
+		name    string
+		off     int64
+		n       int
+		want    int
+		wantErr error
+	}{
+		{name: "inside a block", off: 10, n: 20, want: 20},
+		{name: "across blocks", off: 120, n: 300, want: 300},
+		{name: "up to the end", off: 900, n: 100, want: 100},
+		{name: "past the end", off: 950, n: 100, want: 50, wantErr: io.EOF},
+		{name: "negative offset", off: -1, n: 10, wantErr: ErrNegativeOffset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := NewRangeReaderAt(fakeObject(data), int64(len(data)), 128)
+			p := make([]byte, tt.n)
+			n, err := r.ReadAt(p, tt.off)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadAt() error = %v, want %v", err, tt.wantErr)
+			}
+			if n != tt.want {
+				t.Fatalf("ReadAt() = %d bytes, want %d", n, tt.want)
+			}
+			if n > 0 && !bytes.Equal(p[:n], data[tt.off:tt.off+int64(n)]) {
+				t.Error("ReadAt() returned wrong bytes")
+			}
+		})
+	}
+}
+
+func TestRangeReaderAt_ShortRange(t *testing.T) {
+	t.Parallel()
+
+	truncated := func(off, n int64) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(make([]byte, n/2))), nil
+	}
+	r := NewRangeReaderAt(truncated, 100, 64)
+	if _, err := r.ReadAt(make([]byte, 10), 0); !errors.Is(err, ErrShortRange) {
+		t.Errorf("ReadAt() error = %v, want %v", err, ErrShortRange)
+	}
+}
+
+// A recording streamed into a multipart upload decodes straight from ranged
+// reads of the finished object, one request per block.
+func TestRoundTrip_RecordAndDecode(t *testing.T) {
+	t.Parallel()
+
+	up := &fakeUpload{}
+	rec, err := record.NewRecorder(8000, 1, record.Options{
+		Create: func(string) (io.WriteCloser, error) {
+			return NewMultipartWriter(up.newPart, 4096), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+
+	samples := make([]float32, 8000)
+	for i := range samples {
+		samples[i] = float32(i%100) / 100
+	}
+	if err := rec.Write(samples); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	obj := up.object()
+	ra := NewRangeReaderAt(fakeObject(obj), int64(len(obj)), 1024)
+	src, err := wav.Decoder{}.Decode(io.NewSectionReader(ra, 0, ra.Size()))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	buf := make([]float32, 256)
+	total := 0
+	for {
+		n, err := src.ReadSamples(buf)
+		total += n
+		if err != nil {
+			break
+		}
+	}
+
+	if total != len(samples) {
+		t.Errorf("decoded %d samples, want %d", total, len(samples))
+	}
+	if want := (len(obj) + 1023) / 1024; ra.Requests() > want+1 {
+		t.Errorf("issued %d range requests for %d blocks", ra.Requests(), want)
+	}
+}
@@ -0,0 +1,215 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/aiff"
+	"github.com/ik5/audpbx/formats/g711"
+	"github.com/ik5/audpbx/formats/gsm"
+	"github.com/ik5/audpbx/formats/mp3"
+	"github.com/ik5/audpbx/formats/pcmz"
+	"github.com/ik5/audpbx/formats/sln"
+	"github.com/ik5/audpbx/formats/vorbis"
+	"github.com/ik5/audpbx/formats/wav"
+)
+
+// DefaultRegistry returns a new registry with every decoder that works
+// without configuration registered under the keys audio.DetectFormat
+// reports: "wav", "g711", "wav49", "mp3", "ogg", "vorbis", "aiff", "aif"
+// and "pcmz". The module has no Opus codec: formats/opus decodes Opus
+// audio only with a packet decoder you supply, so register it yourself
+// when you have one.
+//
+// The headerless signed linear and GSM files of Asterisk cannot be
+// recognized from their content, so they are registered under their
+// extensions, "sln" to "sln192" and "gsm", for Get to find by file name.
+//
+// Built with the audpbx_licensesafe tag, the registry has the policy of
+// LicenseSafePolicy.
+func DefaultRegistry() *audio.Registry {
+	reg := audio.NewRegistry()
+	reg.SetPolicy(buildPolicy())
+	reg.Register("wav", wav.Decoder{})
+	reg.Register("g711", g711.Decoder{})
+	reg.Register("wav49", gsm.WAV49Decoder{})
+	reg.Register("mp3", mp3.Decoder{})
+	reg.Register("ogg", vorbis.Decoder{})
+	reg.Register("vorbis", vorbis.Decoder{})
+	reg.Register("aiff", aiff.Decoder{})
+	reg.Register("aif", aiff.Decoder{})
+	reg.Register("pcmz", pcmz.Decoder{})
+	for _, rate := range sln.Rates() {
+		ext, _ := sln.Extension(rate)
+		reg.Register(ext, sln.Decoder{Rate: rate})
+	}
+	reg.Register("gsm", gsm.Decoder{})
+	reg.RegisterMIME("audio/wav", wav.Decoder{})
+	reg.RegisterMIME("audio/x-wav", wav.Decoder{})
+	reg.RegisterMIME("audio/mpeg", mp3.Decoder{})
+	reg.RegisterMIME("audio/ogg", vorbis.Decoder{})
+	reg.RegisterMIME("audio/aiff", aiff.Decoder{})
+	reg.RegisterMIME("audio/x-aiff", aiff.Decoder{})
+	return reg
+}
+
+var defaultRegistry = sync.OnceValue(DefaultRegistry)
+
+// Open opens the audio file at path and decodes it with the decoder its
+// content calls for, whatever the file is named. Closing the source closes
+// the file. Files in a format no default decoder reads fail with
+// audio.ErrUnknownFormat, and those the policy refuses (see SetPolicy)
+// with audio.ErrRefusedByPolicy.
+//
+// The source keeps the random access and length of the decoder, when it
+// has them (see audio.Seeker and audio.Lengther).
+func Open(path string) (audio.Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	src, err := OpenReader(f)
+	if err != nil {
+		if cerr := f.Close(); cerr != nil {
+			err = errors.Join(err, cerr)
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return src, nil
+}
+
+// OpenReader decodes r like Open, sniffing its format from the first bytes.
+// Closing the source closes r when it is an io.Closer, so an HTTP body can
+// be handed over as is; r is not closed when decoding fails.
+func OpenReader(r io.Reader) (audio.Source, error) {
+	src, err := defaultRegistry().DetectAndDecode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	c, _ := r.(io.Closer)
+	return wrapCloser(src, c), nil
+}
+
+// DecodeHeader reads the header of the audio in r, sniffing its format like
+// OpenReader, and returns its format, length and tags without decoding
+// any audio, so an upload can be checked as soon as its first bytes are
+// in, whatever its size:
+//
+//	h, err := audpbx.DecodeHeader(req.Body)
+//	if err == nil && h.Duration() > 10*time.Minute {
+//	    http.Error(w, "too long", http.StatusRequestEntityTooLarge)
+//	}
+//
+// Decoders that implement audio.HeaderDecoder read the header alone;
+// others decode r and stop before its audio. r is not closed. Streams in a
+// format no default decoder reads fail with audio.ErrUnknownFormat.
+func DecodeHeader(r io.Reader) (audio.Header, error) {
+	h, err := defaultRegistry().DecodeHeader(r)
+	if err != nil {
+		return audio.Header{}, fmt.Errorf("%w", err)
+	}
+	return h, nil
+}
+
+// closingSource closes the input of a decoded source along with it
+type closingSource struct {
+	audio.Source
+	in io.Closer
+}
+
+func (s *closingSource) Format() audio.Format     { return audio.FormatOf(s.Source) }
+func (s *closingSource) Upstream() []audio.Source { return []audio.Source{s.Source} }
+
+// LoopPoints returns the loop of the decoded source, and false when it
+// marks none or does not implement audio.LoopPoints.
+func (s *closingSource) LoopPoints() (audio.LoopRegion, bool) {
+	if lp, ok := s.Source.(audio.LoopPoints); ok {
+		return lp.LoopPoints()
+	}
+	return audio.LoopRegion{}, false
+}
+
+// Metadata returns the tags of the decoded source, or none when it does
+// not implement audio.Metadata.
+func (s *closingSource) Metadata() audio.Tags {
+	if m, ok := s.Source.(audio.Metadata); ok {
+		return m.Metadata()
+	}
+	return audio.Tags{}
+}
+
+func (s *closingSource) Close() error {
+	err := s.Source.Close()
+	if s.in != nil {
+		err = errors.Join(err, s.in.Close())
+	}
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// wrapCloser wraps src in a closingSource that implements the same
+// optional interfaces as src: Seeker, Lengther and the g711.CodeReader of
+// G.711 sources, which Writer.WriteSource copies without decoding. Those a
+// caller can tell apart by a type assertion alone; the closingSource itself
+// forwards LoopPoints and Metadata.
+func wrapCloser(src audio.Source, in io.Closer) audio.Source {
+	s := &closingSource{Source: src, in: in}
+	seeker, canSeek := src.(audio.Seeker)
+	lengther, hasLength := src.(audio.Lengther)
+	codes, hasCodes := src.(g711.CodeReader)
+
+	switch {
+	case canSeek && hasLength && hasCodes:
+		return struct {
+			*closingSource
+			audio.Seeker
+			audio.Lengther
+			g711.CodeReader
+		}{s, seeker, lengther, codes}
+	case canSeek && hasLength:
+		return struct {
+			*closingSource
+			audio.Seeker
+			audio.Lengther
+		}{s, seeker, lengther}
+	case canSeek && hasCodes:
+		return struct {
+			*closingSource
+			audio.Seeker
+			g711.CodeReader
+		}{s, seeker, codes}
+	case hasLength && hasCodes:
+		return struct {
+			*closingSource
+			audio.Lengther
+			g711.CodeReader
+		}{s, lengther, codes}
+	case canSeek:
+		return struct {
+			*closingSource
+			audio.Seeker
+		}{s, seeker}
+	case hasLength:
+		return struct {
+			*closingSource
+			audio.Lengther
+		}{s, lengther}
+	case hasCodes:
+		return struct {
+			*closingSource
+			g711.CodeReader
+		}{s, codes}
+	default:
+		return s
+	}
+}
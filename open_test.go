@@ -0,0 +1,434 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+	"github.com/ik5/audpbx/formats/g711"
+	"github.com/ik5/audpbx/formats/wav"
+)
+
+// closeReader is a reader that records being closed
+type closeReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	var pcm, ulaw bytes.Buffer
+	if err := wav.Encode(&pcm, audiotest.NewSineSource(8000, 2, 800, 440)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g711.Encode(&ulaw, audiotest.NewSineSource(8000, 1, 800, 440), g711.MuLaw); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		channels int
+	}{
+		// Misnamed on purpose: the content decides
+		{name: "pcm.mp3", data: pcm.Bytes(), channels: 2},
+		{name: "ulaw.wav", data: ulaw.Bytes(), channels: 1},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			src, err := Open(path)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if src.SampleRate() != 8000 || src.Channels() != tt.channels {
+				t.Errorf("Open() = %d Hz, %d channels, want 8000 Hz, %d", src.SampleRate(), src.Channels(), tt.channels)
+			}
+
+			frames := 0
+			buf := make([]float32, 256)
+			for {
+				n, err := src.ReadSamples(buf)
+				frames += n / tt.channels
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+			if frames != 800 {
+				t.Errorf("read %d frames, want 800", frames)
+			}
+			if err := src.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenReader_Close(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := wav.Encode(&buf, audiotest.NewSilentSource(8000, 1, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &closeReader{Reader: &buf}
+	src, err := OpenReader(r)
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	if r.closed {
+		t.Fatal("OpenReader() closed the reader")
+	}
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !r.closed {
+		t.Error("Close() did not close the reader")
+	}
+
+	r = &closeReader{Reader: bytes.NewReader([]byte("not audio at all"))}
+	if _, err := OpenReader(r); !errors.Is(err, audio.ErrUnknownFormat) {
+		t.Errorf("OpenReader(text) error = %v, want ErrUnknownFormat", err)
+	}
+	if r.closed {
+		t.Error("OpenReader() closed the reader on failure")
+	}
+}
+
+func TestOpen_OptionalInterfaces(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "tone.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wav.Encode(f, audiotest.NewSineSource(8000, 1, 8000, 440)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	src, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer src.Close()
+
+	l, ok := src.(audio.Lengther)
+	if !ok || l.TotalFrames() != 8000 {
+		t.Errorf("Open() source is no audio.Lengther of 8000 frames")
+	}
+	s, ok := src.(audio.Seeker)
+	if !ok {
+		t.Fatal("Open() source of a file is not an audio.Seeker")
+	}
+	if err := s.SeekFrame(4000); err != nil || s.Position() != 4000 {
+		t.Errorf("SeekFrame(4000) = %v, position %d", err, s.Position())
+	}
+	if got := audio.FormatOf(src).SampleKind; got != audio.SampleInt16 {
+		t.Errorf("Format().SampleKind = %v, want Int16", got)
+	}
+
+	// Empty and missing inputs fail
+	if _, err := OpenReader(bytes.NewReader(nil)); !errors.Is(err, audio.ErrUnknownFormat) {
+		t.Errorf("OpenReader(empty) error = %v, want ErrUnknownFormat", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.wav")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Open(missing) error = %v, want ErrNotExist", err)
+	}
+}
+
+func TestOpen_G711Passthrough(t *testing.T) {
+	t.Parallel()
+
+	// Every µ-law code, negative zero (0x7f) included, which decoding
+	// folds into zero and encoding writes as 0xff
+	codes := make([]byte, 256)
+	for i := range codes {
+		codes[i] = byte(i)
+	}
+	raw, err := g711.NewRawSource(bytes.NewReader(codes), 8000, 1, g711.MuLaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "codes.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g711.Encode(f, raw, g711.MuLaw); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	src, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer src.Close()
+	if _, ok := src.(g711.CodeReader); !ok {
+		t.Error("Open() source of a G.711 file is not a g711.CodeReader")
+	}
+	if _, ok := src.(audio.Lengther); !ok {
+		t.Error("Open() source of a G.711 file is not an audio.Lengther")
+	}
+
+	var out bytes.Buffer
+	if n, err := g711.EncodeRaw(&out, src, g711.MuLaw); err != nil || n != 256 {
+		t.Fatalf("EncodeRaw() = %d, %v, want 256 frames", n, err)
+	}
+	if !bytes.Equal(out.Bytes(), codes) {
+		t.Error("EncodeRaw() of an opened G.711 file changed its codes")
+	}
+}
+
+func TestDefaultRegistry_Capabilities(t *testing.T) {
+	t.Parallel()
+
+	reg := DefaultRegistry()
+	for _, format := range reg.Formats() {
+		c, ok := reg.Capabilities(format)
+		if !ok {
+			t.Errorf("Capabilities(%q) not reported", format)
+			continue
+		}
+		if !c.Decode || c.Name == "" || c.MaxChannels <= 0 {
+			t.Errorf("Capabilities(%q) = %+v", format, c)
+		}
+	}
+
+	wavCaps, _ := reg.Capabilities("wav")
+	if err := wavCaps.CheckEncode(audio.Format{Rate: 8000, Channels: 1, SampleKind: audio.SampleInt16}); err != nil {
+		t.Errorf("WAV CheckEncode(s16) error = %v", err)
+	}
+	if err := wavCaps.CheckEncode(audio.Format{Rate: 8000, Channels: 1, SampleKind: audio.SampleFloat32}); !errors.Is(err, audio.ErrUnsupported) {
+		t.Errorf("WAV CheckEncode(f32) error = %v, want ErrUnsupported", err)
+	}
+	mp3Caps, _ := reg.Capabilities("mp3")
+	if err := mp3Caps.CheckEncode(audio.Format{Rate: 44100, Channels: 2}); !errors.Is(err, audio.ErrUnsupported) {
+		t.Errorf("MP3 CheckEncode() error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestDefaultRegistry_Matrix(t *testing.T) {
+	t.Parallel()
+
+	reg := DefaultRegistry()
+	rows := reg.Matrix()
+	if len(rows) != len(reg.Formats()) {
+		t.Fatalf("Matrix() has %d rows, want one for each of %v", len(rows), reg.Formats())
+	}
+	for _, row := range rows {
+		if !row.Reported {
+			t.Errorf("%s: capabilities not reported", row.Format)
+		}
+		switch row.Format {
+		case "wav":
+			if !slices.Equal(row.MIMETypes, []string{"audio/wav", "audio/x-wav"}) {
+				t.Errorf("wav MIME types = %v", row.MIMETypes)
+			}
+		case "g711", "pcmz":
+			if row.MIMETypes != nil {
+				t.Errorf("%s MIME types = %v, want none", row.Format, row.MIMETypes)
+			}
+		}
+	}
+
+	var b strings.Builder
+	if err := audio.WriteMatrix(&b, rows); err != nil {
+		t.Fatalf("WriteMatrix() error = %v", err)
+	}
+	if !strings.Contains(b.String(), "| mp3 | MP3 | audio/mpeg | yes | no |") {
+		t.Errorf("WriteMatrix() =\n%s\nwant a row for MP3", b.String())
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	t.Parallel()
+
+	// Written to files, so the headers record the length
+	encode := func(name string, enc func(io.Writer) error) []byte {
+		path := filepath.Join(t.TempDir(), name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc(f); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	pcm := encode("pcm.wav", func(w io.Writer) error {
+		return wav.Encode(w, audiotest.NewSineSource(16000, 2, 1600, 440))
+	})
+	ulaw := encode("ulaw.wav", func(w io.Writer) error {
+		return g711.Encode(w, audiotest.NewSineSource(8000, 1, 800, 440), g711.MuLaw)
+	})
+
+	tests := []struct {
+		name   string
+		in     io.Reader
+		rate   int
+		frames int64
+	}{
+		{name: "wav", in: bytes.NewReader(pcm), rate: 16000, frames: 1600},
+		{name: "wav stream", in: io.MultiReader(bytes.NewReader(pcm)), rate: 16000, frames: 1600},
+		{name: "ulaw", in: bytes.NewReader(ulaw), rate: 8000, frames: 800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := DecodeHeader(tt.in)
+			if err != nil {
+				t.Fatalf("DecodeHeader() error = %v", err)
+			}
+			if h.Format.Rate != tt.rate || h.Frames != tt.frames || h.Duration() != 100*time.Millisecond {
+				t.Errorf("DecodeHeader() = %+v, want %d Hz, %d frames", h, tt.rate, tt.frames)
+			}
+		})
+	}
+
+	if _, err := DecodeHeader(bytes.NewReader([]byte("not audio at all"))); !errors.Is(err, audio.ErrUnknownFormat) {
+		t.Errorf("DecodeHeader(unknown) error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestOpen_LoopPoints(t *testing.T) {
+	t.Parallel()
+
+	// Written to a file, so the header records the size of the data
+	path := filepath.Join(t.TempDir(), "loop.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wav.Encode(f, audiotest.NewSilentSource(8000, 1, 300)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A smpl chunk with one forward loop over frames 100 to 199
+	smpl := make([]byte, 28, 60)
+	smpl = binary.LittleEndian.AppendUint32(smpl, 1) // loops
+	smpl = binary.LittleEndian.AppendUint32(smpl, 0) // sampler data
+	for _, v := range []uint32{0, 0, 100, 199, 0, 0} {
+		smpl = binary.LittleEndian.AppendUint32(smpl, v)
+	}
+	data = append(data, "smpl"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(smpl)))
+	data = append(data, smpl...)
+	binary.LittleEndian.PutUint32(data[4:8], uint32(len(data)-8))
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer src.Close()
+
+	lp, ok := src.(audio.LoopPoints)
+	if !ok {
+		t.Fatal("Open() source is not an audio.LoopPoints")
+	}
+	if r, found := lp.LoopPoints(); !found || r != (audio.LoopRegion{Start: 100, End: 200}) {
+		t.Errorf("LoopPoints() = %v, %v, want {100 200}, true", r, found)
+	}
+
+	var tone bytes.Buffer
+	if err := g711.Encode(&tone, audiotest.NewSineSource(8000, 1, 800, 440), g711.MuLaw); err != nil {
+		t.Fatal(err)
+	}
+	ulaw, err := OpenReader(&tone)
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	if r, found := ulaw.(audio.LoopPoints).LoopPoints(); found {
+		t.Errorf("LoopPoints() of a G.711 file = %v, true, want none", r)
+	}
+}
+
+func TestOpen_Metadata(t *testing.T) {
+	t.Parallel()
+
+	// An ID3v2.3 tag with a title and an artist, then silent MPEG-1 layer
+	// III frames
+	var frames []byte
+	for _, f := range []struct{ id, text string }{{"TIT2", "Please hold"}, {"TPE1", "Front desk"}} {
+		frames = append(frames, f.id...)
+		frames = binary.BigEndian.AppendUint32(frames, uint32(len(f.text)+1))
+		frames = append(frames, 0, 0, 0) // flags, ISO-8859-1
+		frames = append(frames, f.text...)
+	}
+	data := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, byte(len(frames) >> 7), byte(len(frames) & 0x7F)}
+	data = append(data, frames...)
+	for range 5 {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+		data = append(data, frame...)
+	}
+
+	path := filepath.Join(t.TempDir(), "hold.mp3")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer src.Close()
+
+	m, ok := src.(audio.Metadata)
+	if !ok {
+		t.Fatal("Open() source is not an audio.Metadata")
+	}
+	if got := m.Metadata(); got.Title != "Please hold" || got.Artist != "Front desk" {
+		t.Errorf("Metadata() = %+v, want Please hold by Front desk", got)
+	}
+	if h, err := DecodeHeader(bytes.NewReader(data)); err != nil || h.Tags.Title != "Please hold" {
+		t.Errorf("DecodeHeader() = %+v, %v, want the title of the tag", h, err)
+	}
+}
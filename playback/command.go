@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Command is a Device playing through a program that reads raw samples
+// from its standard input, such as aplay of ALSA, pacat of PulseAudio or
+// pw-play of PipeWire. It needs no cgo and no audio library; the program
+// does the talking to the sound system.
+type Command struct {
+	// Name is the program, looked up in PATH.
+	Name string
+
+	// Args returns the arguments telling the program the format of its
+	// input, 16-bit signed little-endian PCM, and the frames to buffer.
+	Args func(f audio.Format, buffer int) []string
+}
+
+// Aplay plays to the default ALSA device with aplay, which PulseAudio and
+// PipeWire systems route to their own default output.
+var Aplay = Command{
+	Name: "aplay",
+	Args: func(f audio.Format, buffer int) []string {
+		return []string{
+			"-q", "-t", "raw", "-f", "S16_LE",
+			"-r", strconv.Itoa(f.Rate),
+			"-c", strconv.Itoa(f.Channels),
+			"--buffer-size", strconv.Itoa(buffer),
+		}
+	},
+}
+
+// Pacat plays to the default PulseAudio or PipeWire output with pacat.
+var Pacat = Command{
+	Name: "pacat",
+	Args: func(f audio.Format, buffer int) []string {
+		return []string{
+			"--playback", "--raw", "--format=s16le",
+			"--rate=" + strconv.Itoa(f.Rate),
+			"--channels=" + strconv.Itoa(f.Channels),
+			"--latency=" + strconv.Itoa(buffer*f.Channels*2),
+		}
+	},
+}
+
+// Open starts the program, which plays what is written to it.
+func (c Command) Open(f audio.Format, buffer int) (io.WriteCloser, error) {
+	var args []string
+	if c.Args != nil {
+		args = c.Args(f, buffer)
+	}
+	cmd := exec.Command(c.Name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	return &commandOutput{stdin: stdin, cmd: cmd}, nil
+}
+
+// commandOutput is the output of a Command
+type commandOutput struct {
+	stdin io.WriteCloser
+	cmd   *exec.Cmd
+}
+
+func (o *commandOutput) Write(p []byte) (int, error) {
+	n, err := o.stdin.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("%w", err)
+	}
+	return n, nil
+}
+
+// Close ends the input of the program and waits for it to play what it
+// has buffered and exit.
+func (o *commandOutput) Close() error {
+	if err := errors.Join(o.stdin.Close(), o.cmd.Wait()); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
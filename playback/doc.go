@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package playback plays an audio.Source on a sound device in real time,
+// as when checking a prompt or a recording from a terminal.
+//
+// A Player renders the source from a goroutine of its own, and can be
+// paused, resumed and stopped from any other:
+//
+//	f, err := os.Open("welcome.wav")
+//	src, err := wav.Decoder{}.Decode(f)
+//	p, err := playback.NewPlayer(src, playback.Options{})
+//	err = p.Play()
+//	p.Pause()
+//	err = p.Play() // resumes
+//	<-p.Done()     // the end of the file, or p.Stop()
+//
+// # Devices
+//
+// The sound system is reached through a Device, which takes interleaved
+// 16-bit PCM. Command plays through a program reading raw samples from its
+// standard input, so no cgo or audio library is needed: Aplay, the
+// default, for ALSA, and Pacat for PulseAudio and PipeWire. Another
+// program, or a binding to a library such as PortAudio, can be used by
+// implementing Device:
+//
+//	pw := playback.Command{Name: "pw-play", Args: func(f audio.Format, buffer int) []string {
+//	    return []string{"--format=s16", "--rate=" + strconv.Itoa(f.Rate),
+//	        "--channels=" + strconv.Itoa(f.Channels), "-"}
+//	}}
+//	p, err := playback.NewPlayer(src, playback.Options{Device: pw})
+//
+// # Buffering
+//
+// Options.Buffer is the audio handed to the device at a time, and the
+// size of its buffer. A longer one rides out a busy machine, at the cost
+// of Pause and Stop taking up to that long to be heard, and of Position
+// running ahead of the sound by as much.
+//
+// # Events
+//
+// Options.OnEvent tells a UI or an IVR state machine what playback is
+// doing without polling: the position every Options.Interval, underruns
+// of a live source that falls behind, and the end, with its error:
+//
+//	p, err := playback.NewPlayer(src, playback.Options{
+//	    Interval: time.Second,
+//	    OnEvent: func(e playback.Event) {
+//	        switch e.Kind {
+//	        case playback.EventPosition:
+//	            progress.Set(e.Position)
+//	        case playback.EventDone:
+//	            menu.Next(e.Err)
+//	        }
+//	    },
+//	})
+//
+// It is called from a goroutine of its own, which may call the methods of
+// the Player.
+package playback
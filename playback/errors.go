@@ -0,0 +1,9 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package playback
+
+import "errors"
+
+var (
+	ErrPlayerStopped = errors.New("player is stopped")
+)
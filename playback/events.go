@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package playback
+
+import "time"
+
+// DefaultInterval is how often a Player reports its position to
+// Options.OnEvent while playing.
+const DefaultInterval = 250 * time.Millisecond
+
+// eventQueue is the number of events OnEvent may fall behind by before
+// position and underrun events are dropped
+const eventQueue = 64
+
+// EventKind tells what an Event reports.
+type EventKind int
+
+const (
+	// EventPosition reports the position while playing, every
+	// Options.Interval of audio.
+	EventPosition EventKind = iota
+	// EventUnderrun reports that the source had no audio when the device
+	// needed some, as a live source falling behind does: the device plays
+	// out its buffer and then falls silent. It is reported once, until
+	// the source gives audio again.
+	EventUnderrun
+	// EventDone reports the end of playback, at the end of the source, on
+	// an error or on Stop. It is the last event.
+	EventDone
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventPosition:
+		return "position"
+	case EventUnderrun:
+		return "underrun"
+	case EventDone:
+		return "done"
+	}
+	return "unknown"
+}
+
+// Event is something that happened during playback, reported to
+// Options.OnEvent.
+type Event struct {
+	Kind EventKind
+	// Position is where playback was: the multiple of Options.Interval
+	// reached for EventPosition, else the audio written to the device so
+	// far, as Player.Position returns it.
+	Position time.Duration
+	// Err is the error playback ended with, for EventDone.
+	Err error
+}
+
+// emit queues e for OnEvent. Position and underrun events are dropped when
+// OnEvent is too far behind: the next ones supersede them.
+func (p *Player) emit(e Event) {
+	if p.events == nil {
+		return
+	}
+	if e.Kind == EventDone {
+		p.events <- e
+		close(p.events)
+		return
+	}
+	select {
+	case p.events <- e:
+	default:
+	}
+}
+
+// dispatch calls OnEvent with the queued events, from a goroutine of its
+// own so that it may call the methods of the Player
+func (p *Player) dispatch(onEvent func(Event)) {
+	for e := range p.events {
+		onEvent(e)
+	}
+}
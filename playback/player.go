@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package playback
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/utils"
+)
+
+// DefaultBuffer is the audio a Player hands the device at a time, and
+// asks it to buffer: short enough for Pause and Stop to take effect
+// quickly, long enough not to underrun on a busy machine.
+const DefaultBuffer = 100 * time.Millisecond
+
+// Device is an audio output a Player renders to, such as a sound card.
+//
+// Open starts an output of interleaved 16-bit signed little-endian
+// samples in format f, buffering about buffer frames. Writes to it must
+// block while that buffer is full, which paces the Player in real time;
+// Close plays out what is buffered.
+type Device interface {
+	Open(f audio.Format, buffer int) (io.WriteCloser, error)
+}
+
+// Options configures a Player.
+type Options struct {
+	// Device plays the audio. Defaults to Aplay.
+	Device Device
+
+	// Buffer is the audio written to the device at a time, and the size
+	// of its buffer. Defaults to DefaultBuffer.
+	Buffer time.Duration
+
+	// OnEvent, when set, is called with the events of playback once it
+	// has started: the position every Interval, underruns of the source
+	// and the end. It is called in order from a goroutine of its own, so
+	// it may call the methods of the Player, Stop included, and should
+	// return quickly.
+	OnEvent func(Event)
+
+	// Interval is how often OnEvent gets the position while playing.
+	// Defaults to DefaultInterval.
+	Interval time.Duration
+}
+
+// Player state
+const (
+	idle = iota
+	playing
+	paused
+	stopped
+)
+
+// Player renders an audio.Source to a Device in real time, as when
+// listening to a prompt or a recording from a terminal. Its methods are
+// safe to call from any goroutine.
+//
+// Play opens the device and plays the source from a goroutine of its
+// own, until the source ends or Stop is called. The source is not closed
+// by the Player.
+type Player struct {
+	src    audio.Source
+	format audio.Format
+	device Device
+	frames int // frames written at a time
+
+	onEvent  func(Event)
+	interval int64      // frames between position events
+	events   chan Event // to dispatch, nil without OnEvent
+
+	mu     *sync.Mutex
+	cond   *sync.Cond
+	state  int
+	played int64 // frames written to the device
+	err    error
+	done   chan struct{}
+}
+
+// NewPlayer creates a Player of src. Nothing plays until Play is called.
+func NewPlayer(src audio.Source, opts Options) (*Player, error) {
+	format := audio.FormatOf(src)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	if opts.Device == nil {
+		opts.Device = Aplay
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+
+	mu := &sync.Mutex{}
+	return &Player{
+		src:      src,
+		format:   format,
+		device:   opts.Device,
+		frames:   max(audio.FrameLen(format.Rate, opts.Buffer), 1),
+		onEvent:  opts.OnEvent,
+		interval: int64(max(audio.FrameLen(format.Rate, opts.Interval), 1)),
+		mu:       mu,
+		cond:     sync.NewCond(mu),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Play starts playing, or resumes after Pause. The first call opens the
+// device, and returns its error. It fails with ErrPlayerStopped once the
+// player is stopped or the source has ended.
+func (p *Player) Play() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.state {
+	case stopped:
+		return ErrPlayerStopped
+	case paused:
+		p.state = playing
+		p.cond.Broadcast()
+		return nil
+	case playing:
+		return nil
+	}
+
+	out, err := p.device.Open(p.format, p.frames)
+	if err != nil {
+		return fmt.Errorf("opening device: %w", err)
+	}
+	p.state = playing
+	if p.onEvent != nil {
+		p.events = make(chan Event, eventQueue)
+		go p.dispatch(p.onEvent)
+	}
+	go p.run(out)
+	return nil
+}
+
+// Pause stops writing to the device, which plays out what it has
+// buffered, until Play is called again.
+func (p *Player) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state == playing {
+		p.state = paused
+	}
+}
+
+// Stop ends playback for good and closes the device, once the write in
+// progress, of no more than the buffer, is done. It returns the error
+// playback ended with, as Err does.
+func (p *Player) Stop() error {
+	p.mu.Lock()
+	if p.state == idle {
+		close(p.done)
+	}
+	p.state = stopped
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	<-p.done
+	return p.Err()
+}
+
+// Done returns a channel closed when playback has ended, at the end of
+// the source, on an error or on Stop.
+func (p *Player) Done() <-chan struct{} { return p.done }
+
+// Err returns the error that ended playback, if any. The end of the
+// source is not an error.
+func (p *Player) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// Position returns the audio written to the device so far, which is
+// ahead of what has been heard by up to the buffer.
+func (p *Player) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return audio.FramesDuration(p.played, p.format.Rate)
+}
+
+// run plays the source to out until it ends or the player is stopped
+func (p *Player) run(out io.WriteCloser) {
+	err := p.play(out)
+	if cerr := out.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("closing device: %w", cerr)
+	}
+
+	p.mu.Lock()
+	p.err = err
+	p.state = stopped
+	played := p.played
+	p.mu.Unlock()
+	close(p.done)
+
+	p.emit(Event{Kind: EventDone, Position: audio.FramesDuration(played, p.format.Rate), Err: err})
+}
+
+func (p *Player) play(out io.Writer) error {
+	ch := p.format.Channels
+	buf := make([]float32, p.frames*ch)
+	raw := make([]byte, 2*len(buf))
+	next := p.interval // frames at the next position event
+	starved := false
+
+	for {
+		p.mu.Lock()
+		for p.state == paused {
+			p.cond.Wait()
+		}
+		state := p.state
+		p.mu.Unlock()
+		if state == stopped {
+			return nil
+		}
+
+		n, err := p.src.ReadSamples(buf)
+		n -= n % ch
+		for i, v := range buf[:n] {
+			binary.LittleEndian.PutUint16(raw[2*i:], uint16(utils.Float32ToInt16(v)))
+		}
+		if n > 0 {
+			if _, err := out.Write(raw[:2*n]); err != nil {
+				return fmt.Errorf("writing to device: %w", err)
+			}
+			p.mu.Lock()
+			p.played += int64(n / ch)
+			played := p.played
+			p.mu.Unlock()
+
+			starved = false
+			for ; played >= next; next += p.interval {
+				p.emit(Event{Kind: EventPosition, Position: audio.FramesDuration(next, p.format.Rate)})
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+		if n == 0 {
+			// A live source with nothing yet: wait for some
+			if !starved {
+				starved = true
+				p.mu.Lock()
+				played := p.played
+				p.mu.Unlock()
+				p.emit(Event{Kind: EventUnderrun, Position: audio.FramesDuration(played, p.format.Rate)})
+			}
+			time.Sleep(audio.FramesDuration(int64(p.frames), p.format.Rate) / 10)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package playback
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// fakeDevice records what is played. With gate set, each write waits for
+// a value from it, as a device with a full buffer does.
+type fakeDevice struct {
+	gate     chan struct{}
+	openErr  error
+	writeErr error
+
+	mu     sync.Mutex
+	format audio.Format
+	buffer int
+	writes int
+	data   bytes.Buffer
+	closed bool
+}
+
+func (d *fakeDevice) Open(f audio.Format, buffer int) (io.WriteCloser, error) {
+	if d.openErr != nil {
+		return nil, d.openErr
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.format, d.buffer = f, buffer
+	return d, nil
+}
+
+func (d *fakeDevice) Write(p []byte) (int, error) {
+	if d.gate != nil {
+		<-d.gate
+	}
+	if d.writeErr != nil {
+		return 0, d.writeErr
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.writes++
+	return d.data.Write(p)
+}
+
+func (d *fakeDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	return nil
+}
+
+func (d *fakeDevice) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writes
+}
+
+func TestPlayer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		channels int
+		frames   int
+		buffer   time.Duration
+		value    float32
+		want     int16
+		writes   int
+		frameBuf int // frames the device is asked to buffer
+	}{
+		{name: "default buffer", channels: 1, frames: 2000, value: 0.5, want: 16384, writes: 3, frameBuf: 800},
+		{name: "short buffer", channels: 2, frames: 200, buffer: 10 * time.Millisecond, value: -0.25, want: -8192, writes: 3, frameBuf: 80},
+		{name: "full scale", channels: 1, frames: 10, value: 1, want: 32767, writes: 1, frameBuf: 800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dev := &fakeDevice{}
+			src := audiotest.NewConstantSource(8000, tt.channels, tt.frames, tt.value)
+			p, err := NewPlayer(src, Options{Device: dev, Buffer: tt.buffer})
+			if err != nil {
+				t.Fatalf("NewPlayer() error = %v", err)
+			}
+			if err := p.Play(); err != nil {
+				t.Fatalf("Play() error = %v", err)
+			}
+			<-p.Done()
+
+			if err := p.Err(); err != nil {
+				t.Errorf("Err() = %v", err)
+			}
+			if dev.format.Rate != 8000 || dev.format.Channels != tt.channels || dev.buffer != tt.frameBuf {
+				t.Errorf("device opened for %v, %d frames", dev.format, dev.buffer)
+			}
+			if dev.writes != tt.writes || !dev.closed {
+				t.Errorf("device got %d writes, closed = %v, want %d writes", dev.writes, dev.closed, tt.writes)
+			}
+			pcm := dev.data.Bytes()
+			if len(pcm) != 2*tt.channels*tt.frames {
+				t.Fatalf("played %d bytes, want %d", len(pcm), 2*tt.channels*tt.frames)
+			}
+			for i := 0; i < len(pcm); i += 2 {
+				if v := int16(binary.LittleEndian.Uint16(pcm[i:])); v != tt.want {
+					t.Fatalf("sample %d = %d, want %d", i/2, v, tt.want)
+				}
+			}
+			if got, want := p.Position(), audio.FramesDuration(int64(tt.frames), 8000); got != want {
+				t.Errorf("Position() = %v, want %v", got, want)
+			}
+			if err := p.Play(); !errors.Is(err, ErrPlayerStopped) {
+				t.Errorf("Play() after the end error = %v, want ErrPlayerStopped", err)
+			}
+		})
+	}
+}
+
+func TestPlayer_PauseStop(t *testing.T) {
+	t.Parallel()
+
+	dev := &fakeDevice{gate: make(chan struct{})}
+	p, err := NewPlayer(audiotest.NewSilentSource(8000, 1, 80000), Options{Device: dev, Buffer: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Play(); err != nil {
+		t.Fatalf("Play() error = %v", err)
+	}
+	dev.gate <- struct{}{}
+
+	// A write may have started before the pause, but none after it
+	p.Pause()
+	select {
+	case dev.gate <- struct{}{}:
+	case <-time.After(20 * time.Millisecond):
+	}
+	writes := dev.count()
+	select {
+	case dev.gate <- struct{}{}:
+		t.Fatal("wrote to the device while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := p.Play(); err != nil {
+		t.Fatalf("Play() to resume error = %v", err)
+	}
+	dev.gate <- struct{}{}
+	if dev.count() <= writes {
+		t.Error("did not resume writing")
+	}
+
+	close(dev.gate)
+	if err := p.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	select {
+	case <-p.Done():
+	default:
+		t.Error("Done() not closed after Stop")
+	}
+	if !dev.closed {
+		t.Error("device not closed after Stop")
+	}
+	if err := p.Play(); !errors.Is(err, ErrPlayerStopped) {
+		t.Errorf("Play() after Stop error = %v, want ErrPlayerStopped", err)
+	}
+}
+
+func TestPlayer_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewPlayer(audiotest.NewSilentSource(0, 1, 10), Options{}); err == nil {
+		t.Error("NewPlayer(0 Hz) error = nil")
+	}
+
+	p, err := NewPlayer(audiotest.NewSilentSource(8000, 1, 10), Options{Device: &fakeDevice{openErr: audiotest.ErrInjected}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Play(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Play() error = %v, want ErrInjected", err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Errorf("Stop() before playing error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		src  audio.Source
+		dev  *fakeDevice
+	}{
+		{name: "device", src: audiotest.NewSilentSource(8000, 1, 10), dev: &fakeDevice{writeErr: audiotest.ErrInjected}},
+		{name: "source", src: audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 10), audiotest.WithErrorOnCall(1, audiotest.ErrInjected)), dev: &fakeDevice{}},
+	} {
+		p, err := NewPlayer(tt.src, Options{Device: tt.dev})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Play(); err != nil {
+			t.Fatal(err)
+		}
+		<-p.Done()
+		if err := p.Err(); !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("%s: Err() = %v, want ErrInjected", tt.name, err)
+		}
+		if !tt.dev.closed {
+			t.Errorf("%s: device not closed", tt.name)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	out := filepath.Join(t.TempDir(), "out.raw")
+	dev := Command{Name: "sh", Args: func(f audio.Format, buffer int) []string {
+		return []string{"-c", "cat > " + out}
+	}}
+
+	p, err := NewPlayer(audiotest.NewConstantSource(8000, 2, 1000, 0.5), Options{Device: dev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Play(); err != nil {
+		t.Fatalf("Play() error = %v", err)
+	}
+	<-p.Done()
+	if err := p.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	pcm, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pcm) != 4000 || int16(binary.LittleEndian.Uint16(pcm[3998:])) != 16384 {
+		t.Errorf("the program got %d bytes", len(pcm))
+	}
+
+	if _, err := (Command{Name: "no-such-player"}).Open(audio.Format{Rate: 8000, Channels: 1}, 800); err == nil {
+		t.Error("Open() of a missing program error = nil")
+	}
+}
+
+// starvingSource gives nothing on its reads from the third to the fifth,
+// as a live source falling behind does
+type starvingSource struct {
+	audio.Source
+	reads int
+}
+
+func (s *starvingSource) ReadSamples(dst []float32) (int, error) {
+	s.reads++
+	if s.reads >= 3 && s.reads <= 5 {
+		return 0, nil
+	}
+	return s.Source.ReadSamples(dst)
+}
+
+func TestPlayer_Events(t *testing.T) {
+	t.Parallel()
+
+	ms := time.Millisecond
+	tests := []struct {
+		name     string
+		src      audio.Source
+		interval time.Duration
+		want     []Event
+	}{
+		{
+			name:     "positions",
+			src:      audiotest.NewSilentSource(8000, 1, 2000),
+			interval: 50 * ms,
+			want: []Event{
+				{Kind: EventPosition, Position: 50 * ms}, {Kind: EventPosition, Position: 100 * ms},
+				{Kind: EventPosition, Position: 150 * ms}, {Kind: EventPosition, Position: 200 * ms},
+				{Kind: EventPosition, Position: 250 * ms}, {Kind: EventDone, Position: 250 * ms},
+			},
+		},
+		{
+			name: "default interval",
+			src:  audiotest.NewSilentSource(8000, 2, 3000),
+			want: []Event{{Kind: EventPosition, Position: 250 * ms}, {Kind: EventDone, Position: 375 * ms}},
+		},
+		{
+			name:     "underrun",
+			src:      &starvingSource{Source: audiotest.NewSilentSource(8000, 1, 4000)},
+			interval: 200 * ms,
+			want: []Event{
+				{Kind: EventPosition, Position: 200 * ms}, {Kind: EventUnderrun, Position: 200 * ms},
+				{Kind: EventPosition, Position: 400 * ms}, {Kind: EventDone, Position: 500 * ms},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []Event
+			ended := make(chan struct{})
+			p, err := NewPlayer(tt.src, Options{Device: &fakeDevice{}, Interval: tt.interval, OnEvent: func(e Event) {
+				got = append(got, e)
+				if e.Kind == EventDone {
+					close(ended)
+				}
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Play(); err != nil {
+				t.Fatalf("Play() error = %v", err)
+			}
+			<-ended
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayer_EventsStop(t *testing.T) {
+	t.Parallel()
+
+	// A state machine stopping playback from its callback
+	var p *Player
+	done := make(chan Event, 1)
+	dev := &fakeDevice{gate: make(chan struct{})}
+	p, err := NewPlayer(audiotest.NewSilentSource(8000, 1, 80000), Options{
+		Device:   dev,
+		Buffer:   10 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+		OnEvent: func(e Event) {
+			switch e.Kind {
+			case EventPosition:
+				if err := p.Stop(); err != nil {
+					t.Errorf("Stop() error = %v", err)
+				}
+			case EventDone:
+				done <- e
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Play(); err != nil {
+		t.Fatalf("Play() error = %v", err)
+	}
+	go func() {
+		// A device playing a write every millisecond
+		for {
+			select {
+			case dev.gate <- struct{}{}:
+				time.Sleep(time.Millisecond)
+			case <-p.Done():
+				return
+			}
+		}
+	}()
+
+	select {
+	case e := <-done:
+		if e.Err != nil || e.Position >= time.Second {
+			t.Errorf("done event = %+v, want stopped early without error", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no done event after Stop")
+	}
+}
+
+func TestPlayer_EventsError(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan Event, eventQueue)
+	src := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 10), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	p, err := NewPlayer(src, Options{Device: &fakeDevice{}, OnEvent: func(e Event) { events <- e }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Play(); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-events; e.Kind != EventDone || !errors.Is(e.Err, audiotest.ErrInjected) {
+		t.Errorf("event = %+v, want done with ErrInjected", e)
+	}
+	if s := EventUnderrun.String(); s != "underrun" {
+		t.Errorf("EventUnderrun.String() = %q", s)
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"fmt"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// LicenseSafePolicy returns the "license-safe" policy, for deployments
+// that must not decode codecs still under patent licensing: AAC, AMR,
+// AMR-WB, EVS and E-AC-3, by their usual format keys, extensions and MIME
+// types. None of the decoders of this module is among them; the policy
+// keeps decoders of them registered by the application, or by a library
+// it pulls in, from being used. The list is a starting point, not legal
+// advice: extend it with audio.DenyFormats as counsel directs.
+//
+// Building with the audpbx_licensesafe tag makes it the policy of
+// DefaultRegistry and of Open, so that a build cannot ship without it:
+//
+//	go build -tags audpbx_licensesafe ./cmd/ivr
+func LicenseSafePolicy() audio.Policy {
+	return audio.DenyFormats("license-safe",
+		"aac", "m4a", "audio/aac", "audio/mp4", "audio/x-m4a",
+		"amr", "awb", "amr-wb", "audio/amr", "audio/amr-wb",
+		"evs", "audio/evs",
+		"eac3", "ec3", "e-ac-3", "audio/eac3",
+	)
+}
+
+// SetPolicy restricts Open, OpenReader and PromptLoader to the formats p
+// allows, or lifts the restriction when p is nil, replacing the policy
+// they were built with. Registries of DefaultRegistry are not affected:
+// set their policy with SetPolicy on them.
+func SetPolicy(p audio.Policy) {
+	defaultRegistry().SetPolicy(p)
+}
+
+// checkPolicy returns the error of the policy of Open refusing d, given
+// for files with the extension ext
+func checkPolicy(ext string, d audio.Decoder) error {
+	p := defaultRegistry().Policy()
+	if p == nil || p.Allows(ext, d) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s refused by the %q policy", audio.ErrRefusedByPolicy, ext, p.Name())
+}
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: EPL-2.0
+
+//go:build audpbx_licensesafe
+
+package audpbx
+
+import "github.com/ik5/audpbx/audio"
+
+// buildPolicy returns the policy registries start with: LicenseSafePolicy,
+// as the module is built with the audpbx_licensesafe tag.
+func buildPolicy() audio.Policy { return LicenseSafePolicy() }
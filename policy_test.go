@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// aacDecoder stands for a decoder of an encumbered codec an application
+// registers itself
+type aacDecoder struct{}
+
+func (aacDecoder) Decode(io.Reader) (audio.Source, error) { return nil, nil }
+
+func (aacDecoder) Capabilities() audio.Capabilities {
+	return audio.Capabilities{Name: "AAC", Decode: true}
+}
+
+func TestLicenseSafePolicy(t *testing.T) {
+	t.Parallel()
+
+	reg := DefaultRegistry()
+	if p := reg.Policy(); (p == nil) != (buildPolicy() == nil) {
+		t.Errorf("DefaultRegistry() policy = %v, want that of the build", p)
+	}
+
+	reg.SetPolicy(LicenseSafePolicy())
+	reg.Register("mp4a", aacDecoder{}) // refused by its capabilities name
+	reg.RegisterMIME("audio/aac", aacDecoder{})
+
+	if _, err := reg.Lookup("mp4a"); !errors.Is(err, audio.ErrRefusedByPolicy) {
+		t.Errorf("Lookup(AAC) error = %v, want ErrRefusedByPolicy", err)
+	}
+	if _, ok := reg.GetByMIME("audio/aac"); ok {
+		t.Error("GetByMIME(audio/aac) ok under the license-safe policy")
+	}
+	for _, f := range []string{"wav", "mp3", "ogg", "aiff", "g711", "gsm", "wav49", "sln"} {
+		if _, err := reg.Lookup(f); err != nil {
+			t.Errorf("Lookup(%q) error = %v, want the format allowed", f, err)
+		}
+	}
+}
+
+// TestSetPolicy changes the policy of Open, so it does not run in parallel
+func TestSetPolicy(t *testing.T) {
+	defer SetPolicy(buildPolicy())
+
+	fsys := promptFS(t)
+	SetPolicy(audio.DenyFormats("no-ulaw", "ulaw"))
+	file, err := NewPromptLoader(fsys).Find("en/menu", PromptTarget{Format: audio.Format{Rate: 8000, Channels: 1}, Codec: "ulaw"})
+	if err != nil || file.Path == "en/menu.ulaw" {
+		t.Errorf("Find() = %q, %v, want a file other than the refused µ-law one", file.Path, err)
+	}
+
+	SetPolicy(audio.DenyFormats("no-wav", "WAV"))
+	_, err = OpenReader(bytes.NewReader(fsys["en/greeting.wav"].Data))
+	if !errors.Is(err, audio.ErrRefusedByPolicy) || !strings.Contains(err.Error(), `"no-wav"`) {
+		t.Errorf("OpenReader(WAV) error = %v, want ErrRefusedByPolicy naming the policy", err)
+	}
+	_, err = NewPromptLoader(fsys).Find("en/greeting", PromptTarget{Format: audio.Format{Rate: 8000, Channels: 1}})
+	if !errors.Is(err, ErrPromptNotFound) || !errors.Is(err, audio.ErrRefusedByPolicy) {
+		t.Errorf("Find(only a WAV) error = %v, want ErrPromptNotFound and ErrRefusedByPolicy", err)
+	}
+}
@@ -0,0 +1,278 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/gsm"
+	"github.com/ik5/audpbx/formats/pcm"
+	"github.com/ik5/audpbx/formats/sln"
+)
+
+// Costs of the conversions a prompt file may need to reach its target.
+// Resampling costs more than downmixing and downmixing more than
+// transcoding, so a file at the right rate always wins.
+const (
+	costTranscode = 1
+	costDownmix   = 2
+	costResample  = 4
+)
+
+// PromptFormat is a file format prompts can be stored in, recognised by
+// its extension.
+type PromptFormat struct {
+	// Ext is the file extension, without the dot.
+	Ext string
+	// Codec names the encoding of the files, such as "ulaw" or "slin", to
+	// match PromptTarget.Codec.
+	Codec string
+	// Format is the format of the decoded audio when the extension fixes
+	// it, as for headerless files. The zero Format means it is read from
+	// the header of each file.
+	Format audio.Format
+	// Decoder decodes the files; nil sniffs the format of each file like
+	// OpenReader.
+	Decoder audio.Decoder
+}
+
+// DefaultPromptFormats returns the formats a PromptLoader looks for by
+// default, in the order Asterisk prefers them when they are as cheap:
+// headerless µ-law, A-law and signed linear at 8, 16 and 48 kHz, GSM,
+// then WAV, Ogg Vorbis and MP3 files.
+func DefaultPromptFormats() []PromptFormat {
+	raw := func(ext, codec string, rate int, enc pcm.Encoding) PromptFormat {
+		return PromptFormat{
+			Ext:     ext,
+			Codec:   codec,
+			Format:  audio.Format{Rate: rate, Channels: 1, Layout: audio.DefaultLayout(1), SampleKind: enc.SampleKind()},
+			Decoder: pcm.Decoder{Rate: rate, Channels: 1, Encoding: enc},
+		}
+	}
+	slin := func(ext string, rate int) PromptFormat {
+		f := raw(ext, "slin", rate, pcm.S16LE)
+		f.Decoder = sln.Decoder{Rate: rate}
+		return f
+	}
+	return []PromptFormat{
+		raw("ulaw", "ulaw", 8000, pcm.MuLaw),
+		raw("alaw", "alaw", 8000, pcm.ALaw),
+		slin("sln", 8000),
+		slin("sln16", 16000),
+		slin("sln48", 48000),
+		{
+			Ext:     "gsm",
+			Codec:   "gsm",
+			Format:  audio.Format{Rate: gsm.Rate, Channels: 1, Layout: audio.DefaultLayout(1), SampleKind: audio.SampleInt16},
+			Decoder: gsm.Decoder{},
+		},
+		{Ext: "wav", Codec: "slin"},
+		{Ext: "ogg", Codec: "vorbis"},
+		{Ext: "mp3", Codec: "mp3"},
+	}
+}
+
+// PromptTarget is the output a prompt is loaded for.
+type PromptTarget struct {
+	audio.Format
+	// Codec is the encoding the output is sent in, such as "ulaw" on a
+	// G.711 µ-law channel: files already in it are preferred, as they need
+	// no transcoding. Empty when the output takes linear samples, like
+	// "slin".
+	Codec string
+}
+
+// PromptFile is a file chosen for a prompt.
+type PromptFile struct {
+	// Path is the path of the file in the loader's file system.
+	Path string
+	// Codec is the encoding of the file, from its PromptFormat.
+	Codec string
+	// Format is the format the file decodes to.
+	Format audio.Format
+	// Cost rates the conversions the file needs to reach the target: 0
+	// for none, more for transcoding, downmixing and resampling, in that
+	// order.
+	Cost int
+
+	format *PromptFormat
+}
+
+// PromptLoader finds the best available file of a prompt among the
+// formats it is stored in, the way Asterisk picks sound files: for a
+// prompt named "main-menu" it looks at main-menu.ulaw, main-menu.alaw,
+// main-menu.wav and so on, and takes the one that needs the least work
+// to play on the target, so a µ-law channel plays main-menu.ulaw as is
+// rather than resampling and encoding main-menu.wav at every call.
+type PromptLoader struct {
+	fsys    fs.FS
+	formats []PromptFormat
+}
+
+// NewPromptLoader creates a loader of the prompts in fsys, stored in
+// formats, in order of preference; none selects DefaultPromptFormats.
+// Use os.DirFS for a sounds directory.
+func NewPromptLoader(fsys fs.FS, formats ...PromptFormat) *PromptLoader {
+	if len(formats) == 0 {
+		formats = DefaultPromptFormats()
+	}
+	return &PromptLoader{fsys: fsys, formats: formats}
+}
+
+// Find returns the file of the prompt name, a path without extension,
+// that is cheapest to play on target; the first in order of preference
+// among files as cheap. Files whose format is not fixed by their
+// extension are opened to read it. Prompts with no file that can be
+// played on target, in channel count, fail with ErrPromptNotFound.
+func (l *PromptLoader) Find(name string, target PromptTarget) (PromptFile, error) {
+	file, src, err := l.find(name, target)
+	if err != nil {
+		return PromptFile{}, err
+	}
+	if src != nil {
+		if err := src.Close(); err != nil {
+			return PromptFile{}, fmt.Errorf("%w", err)
+		}
+	}
+	return file, nil
+}
+
+// Open opens the file Find returns and converts it to the rate and, for
+// mono targets, the channel count of target. Closing the source closes
+// the file.
+func (l *PromptLoader) Open(name string, target PromptTarget) (audio.Source, error) {
+	file, src, err := l.find(name, target)
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		if src, err = l.open(file.Path, file.format); err != nil {
+			return nil, err
+		}
+	}
+
+	if src.Channels() != target.Channels {
+		src = audio.NewMonoMixer(src)
+	}
+	if src.SampleRate() != target.Rate {
+		src = audio.Resample(src, target.Rate)
+	}
+	return src, nil
+}
+
+// find rates every file of name and returns the cheapest, along with its
+// source when it had to be opened to learn its format
+func (l *PromptLoader) find(name string, target PromptTarget) (PromptFile, audio.Source, error) {
+	if err := target.Validate(); err != nil {
+		return PromptFile{}, nil, fmt.Errorf("target: %w", err)
+	}
+
+	var (
+		best    PromptFile
+		bestSrc audio.Source
+		found   bool
+		errs    []error
+	)
+	for i := range l.formats {
+		pf := &l.formats[i]
+		path := name + "." + pf.Ext
+		if _, err := fs.Stat(l.fsys, path); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if pf.Decoder != nil {
+			if err := checkPolicy(pf.Ext, pf.Decoder); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+		}
+
+		file := PromptFile{Path: path, Codec: pf.Codec, Format: pf.Format, format: pf}
+		var src audio.Source
+		if file.Format.Rate == 0 {
+			var err error
+			if src, err = l.open(path, pf); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			file.Format = audio.FormatOf(src)
+		}
+
+		cost, ok := promptCost(file, target)
+		if !ok || (found && cost >= best.Cost) {
+			if src != nil {
+				errs = append(errs, src.Close())
+			}
+			continue
+		}
+
+		if bestSrc != nil {
+			errs = append(errs, bestSrc.Close())
+		}
+		file.Cost = cost
+		best, bestSrc, found = file, src, true
+	}
+
+	if !found {
+		err := fmt.Errorf("%w: %s for %s", ErrPromptNotFound, name, target.Format)
+		return PromptFile{}, nil, errors.Join(append([]error{err}, errs...)...)
+	}
+	return best, bestSrc, nil
+}
+
+// promptCost rates playing file on target, and reports whether it can be
+// played there at all
+func promptCost(file PromptFile, target PromptTarget) (int, bool) {
+	cost := 0
+	if file.Format.Channels != target.Channels {
+		if target.Channels != 1 {
+			return 0, false
+		}
+		cost += costDownmix
+	}
+	if file.Format.Rate != target.Rate {
+		cost += costResample
+	}
+	codec := target.Codec
+	if codec == "" {
+		codec = "slin"
+	}
+	if file.Codec != codec {
+		cost += costTranscode
+	}
+	return cost, true
+}
+
+// open decodes the file at path, closing it with the source
+func (l *PromptLoader) open(path string, pf *PromptFormat) (audio.Source, error) {
+	f, err := l.fsys.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	// Some decoders close what they read; the source closes f instead.
+	// Seekable files stay seekable for decoders with random access.
+	var r io.Reader = struct{ io.Reader }{f}
+	if rs, ok := f.(io.ReadSeeker); ok {
+		r = struct{ io.ReadSeeker }{rs}
+	}
+	var src audio.Source
+	if pf.Decoder != nil {
+		src, err = pf.Decoder.Decode(r)
+	} else {
+		src, err = defaultRegistry().DetectAndDecode(r)
+	}
+	if err != nil {
+		if cerr := f.Close(); cerr != nil {
+			err = errors.Join(err, cerr)
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return wrapCloser(src, f), nil
+}
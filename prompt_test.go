@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+	"github.com/ik5/audpbx/formats/g711"
+	"github.com/ik5/audpbx/formats/wav"
+)
+
+// promptFS holds the prompts "menu" as µ-law, 16 kHz signed linear and
+// a 16 kHz WAV, and "greeting" as an 8 kHz stereo WAV only
+func promptFS(t *testing.T) fstest.MapFS {
+	t.Helper()
+
+	var ulaw, menuWAV, greeting bytes.Buffer
+	if _, err := g711.EncodeRaw(&ulaw, audiotest.NewSineSource(8000, 1, 800, 440), g711.MuLaw); err != nil {
+		t.Fatal(err)
+	}
+	if err := wav.Encode(&menuWAV, audiotest.NewSineSource(16000, 1, 1600, 440)); err != nil {
+		t.Fatal(err)
+	}
+	if err := wav.Encode(&greeting, audiotest.NewSineSource(8000, 2, 800, 440)); err != nil {
+		t.Fatal(err)
+	}
+	sln16 := make([]byte, 0, 3200)
+	for range 1600 {
+		sln16 = binary.LittleEndian.AppendUint16(sln16, 1000)
+	}
+
+	return fstest.MapFS{
+		"en/menu.ulaw":     {Data: ulaw.Bytes()},
+		"en/menu.sln16":    {Data: sln16},
+		"en/menu.wav":      {Data: menuWAV.Bytes()},
+		"en/greeting.wav":  {Data: greeting.Bytes()},
+		"en/greeting.txt":  {Data: []byte("not a prompt")},
+		"en/broken.wav":    {Data: []byte("RIFF")},
+		"en/broken.sln":    {Data: sln16},
+		"en/corrupted.wav": {Data: []byte("garbage")},
+	}
+}
+
+func TestPromptLoader_Find(t *testing.T) {
+	t.Parallel()
+
+	l := NewPromptLoader(promptFS(t))
+	mono8k := audio.Format{Rate: 8000, Channels: 1}
+
+	tests := []struct {
+		name     string
+		prompt   string
+		target   PromptTarget
+		wantPath string
+		wantCost int
+		wantErr  error
+	}{
+		{name: "as is on µ-law", prompt: "en/menu", target: PromptTarget{Format: mono8k, Codec: "ulaw"}, wantPath: "en/menu.ulaw"},
+		{name: "decoded for linear", prompt: "en/menu", target: PromptTarget{Format: mono8k}, wantPath: "en/menu.ulaw", wantCost: costTranscode},
+		{name: "preferred among equals", prompt: "en/menu", target: PromptTarget{Format: audio.Format{Rate: 16000, Channels: 1}}, wantPath: "en/menu.sln16"},
+		{name: "transcoded rather than resampled", prompt: "en/menu", target: PromptTarget{Format: mono8k, Codec: "alaw"}, wantPath: "en/menu.ulaw", wantCost: costTranscode},
+		{name: "read from the header", prompt: "en/greeting", target: PromptTarget{Format: audio.Format{Rate: 8000, Channels: 2}}, wantPath: "en/greeting.wav"},
+		{name: "everything", prompt: "en/greeting", target: PromptTarget{Format: audio.Format{Rate: 16000, Channels: 1}, Codec: "ulaw"}, wantPath: "en/greeting.wav", wantCost: costResample + costDownmix + costTranscode},
+		{name: "broken file skipped", prompt: "en/broken", target: PromptTarget{Format: mono8k}, wantPath: "en/broken.sln"},
+		{name: "no channel upmix", prompt: "en/menu", target: PromptTarget{Format: audio.Format{Rate: 8000, Channels: 2}}, wantErr: ErrPromptNotFound},
+		{name: "missing", prompt: "en/goodbye", target: PromptTarget{Format: mono8k}, wantErr: ErrPromptNotFound},
+		{name: "unreadable", prompt: "en/corrupted", target: PromptTarget{Format: mono8k}, wantErr: audio.ErrUnknownFormat},
+		{name: "invalid target", prompt: "en/menu", target: PromptTarget{}, wantErr: audio.ErrInvalidSampleRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := l.Find(tt.prompt, tt.target)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Find() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if got.Path != tt.wantPath || got.Cost != tt.wantCost {
+				t.Errorf("Find() = %s at cost %d, want %s at cost %d", got.Path, got.Cost, tt.wantPath, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestPromptLoader_Open(t *testing.T) {
+	t.Parallel()
+
+	fsys := promptFS(t)
+	tests := []struct {
+		name   string
+		loader *PromptLoader
+		prompt string
+		target audio.Format
+		frames int
+	}{
+		{name: "as is", loader: NewPromptLoader(fsys), prompt: "en/menu", target: audio.Format{Rate: 8000, Channels: 1}, frames: 800},
+		{name: "converted", loader: NewPromptLoader(fsys), prompt: "en/greeting", target: audio.Format{Rate: 16000, Channels: 1}, frames: 1600},
+		// Only WAV files: the 16 kHz one is resampled
+		{name: "custom formats", loader: NewPromptLoader(fsys, PromptFormat{Ext: "wav", Codec: "slin"}), prompt: "en/menu", target: audio.Format{Rate: 8000, Channels: 1}, frames: 800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := tt.loader.Open(tt.prompt, PromptTarget{Format: tt.target})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if got := audio.FormatOf(src); got.Compatible(tt.target) != nil {
+				t.Fatalf("Open() format = %s, want %s", got, tt.target)
+			}
+
+			frames := 0
+			buf := make([]float32, 256)
+			for {
+				n, err := src.ReadSamples(buf)
+				frames += n
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadSamples() error = %v", err)
+				}
+			}
+			// The resampler may round the length by a few frames
+			if frames < tt.frames-8 || frames > tt.frames+8 {
+				t.Errorf("read %d frames, want about %d", frames, tt.frames)
+			}
+			if err := src.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Defaults of PromptCacheOptions.
+const (
+	DefaultPromptCrossfade   = 5 * time.Millisecond
+	DefaultPromptCacheLength = 10 * time.Minute
+)
+
+// PromptCacheOptions configures a PromptCache. Zero values select the
+// defaults.
+type PromptCacheOptions struct {
+	// Crossfade is how long the prompts of a sequence overlap at each
+	// join, fading one out as the next fades in, so they run together
+	// without a click: 5 ms by default. A join never takes more than half
+	// of either prompt. A negative Crossfade butts prompts together.
+	Crossfade time.Duration
+	// MaxLength bounds the audio held, all sequences together, 10 minutes
+	// by default: 19 MB at 8 kHz mono. The sequences played least recently
+	// are dropped to make room, and a sequence longer than MaxLength on its
+	// own fails with ErrPromptTooLong.
+	MaxLength time.Duration
+}
+
+func (o PromptCacheOptions) withDefaults() PromptCacheOptions {
+	if o.Crossfade == 0 {
+		o.Crossfade = DefaultPromptCrossfade
+	}
+	if o.Crossfade < 0 {
+		o.Crossfade = 0
+	}
+	if o.MaxLength <= 0 {
+		o.MaxLength = DefaultPromptCacheLength
+	}
+	return o
+}
+
+// PromptCache plays sequences of prompts, such as "please-hold",
+// "your-call-is-important", from PCM rendered once and kept in memory, so
+// the hot paths of an IVR play with no decoding, resampling or
+// concatenation per call. A sequence is rendered the first time it is
+// played, or ahead of time with Compile, by opening its prompts from a
+// PromptSet and joining them with a short crossfade.
+//
+// Sources returned by Open share the rendered samples, and each implements
+// audio.Seeker and audio.Lengther. A PromptCache is safe for use by any
+// number of goroutines; a sequence missed by several at once may be
+// rendered more than once.
+type PromptCache struct {
+	set       *PromptSet
+	target    PromptTarget
+	crossfade time.Duration
+	limit     int // samples
+
+	mu      *sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // of *cachedSequence, most recently played first
+	held    int        // samples
+}
+
+// cachedSequence is a rendered sequence, never written once cached
+type cachedSequence struct {
+	key     string
+	format  audio.Format
+	samples []float32
+}
+
+// NewPromptCache creates an empty cache of the prompts of set, opened for
+// target.
+func NewPromptCache(set *PromptSet, target PromptTarget, opts PromptCacheOptions) *PromptCache {
+	opts = opts.withDefaults()
+	return &PromptCache{
+		set:       set,
+		target:    target,
+		crossfade: opts.Crossfade,
+		limit:     audio.FrameLen(target.Rate, opts.MaxLength) * max(target.Channels, 1),
+		mu:        &sync.Mutex{},
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// Compile renders the sequence of the prompts keys in locale, unless it is
+// cached already, so that the first call to play it does not wait.
+func (c *PromptCache) Compile(keys []string, locale string) error {
+	_, err := c.sequence(keys, locale)
+	return err
+}
+
+// Open returns a source playing the prompts keys in locale back to back,
+// rendering them first if the sequence is not cached. Prompts are found
+// as PromptSet.Open finds them.
+func (c *PromptCache) Open(keys []string, locale string) (audio.Source, error) {
+	seq, err := c.sequence(keys, locale)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedPrompt{seq: seq}, nil
+}
+
+// Length returns the duration of the audio held.
+func (c *PromptCache) Length() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return audio.FramesDuration(int64(c.held/max(c.target.Channels, 1)), c.target.Rate)
+}
+
+// Reset drops every sequence, as when the prompt files have changed.
+// Sources already open keep playing.
+func (c *PromptCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.lru.Init()
+	c.held = 0
+}
+
+// sequence returns the cached sequence of keys in locale, rendering and
+// caching it on a miss
+func (c *PromptCache) sequence(keys []string, locale string) (*cachedSequence, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no prompts to play", audio.ErrInvalidState)
+	}
+	key := locale + "\x00" + strings.Join(keys, "\x00")
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cachedSequence), nil
+	}
+	c.mu.Unlock()
+
+	seq, err := c.render(keys, locale)
+	if err != nil {
+		return nil, err
+	}
+	seq.key = key
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		// Rendered meanwhile by another call
+		c.lru.MoveToFront(e)
+		return e.Value.(*cachedSequence), nil
+	}
+	for c.held+len(seq.samples) > c.limit {
+		last := c.lru.Back()
+		old := c.lru.Remove(last).(*cachedSequence)
+		delete(c.entries, old.key)
+		c.held -= len(old.samples)
+	}
+	c.entries[key] = c.lru.PushFront(seq)
+	c.held += len(seq.samples)
+	return seq, nil
+}
+
+// render opens the prompts of keys one by one and joins their samples
+func (c *PromptCache) render(keys []string, locale string) (*cachedSequence, error) {
+	seq := &cachedSequence{}
+	for i, key := range keys {
+		src, err := c.set.Open(key, locale, c.target)
+		if err != nil {
+			return nil, err
+		}
+
+		format := audio.FormatOf(src)
+		if i == 0 {
+			seq.format = format
+		} else if err := format.Compatible(seq.format); err != nil {
+			return nil, errors.Join(fmt.Errorf("prompt %s: %w", key, err), src.Close())
+		}
+
+		samples, err := c.readAll(src, c.limit-len(seq.samples))
+		if err = errors.Join(err, src.Close()); err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", key, err)
+		}
+		seq.samples = crossfade(seq.samples, samples, seq.format.Channels, audio.FrameLen(seq.format.Rate, c.crossfade))
+	}
+	return seq, nil
+}
+
+// readAll reads the whole of src, failing with ErrPromptTooLong past limit
+// samples
+func (c *PromptCache) readAll(src audio.Source, limit int) ([]float32, error) {
+	ch := max(src.Channels(), 1)
+	var samples []float32
+	buf := make([]float32, max(src.BufSize()-src.BufSize()%ch, ch))
+	for {
+		n, err := src.ReadSamples(buf)
+		samples = append(samples, buf[:n-n%ch]...)
+		if len(samples) > limit {
+			return nil, fmt.Errorf("%w: over %v", ErrPromptTooLong, audio.FramesDuration(int64(c.limit/ch), src.SampleRate()))
+		}
+		if errors.Is(err, io.EOF) {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+}
+
+// crossfade appends next to prev, overlapping them by up to fade frames
+// with a linear fade
+func crossfade(prev, next []float32, channels, fade int) []float32 {
+	fade = min(fade, len(prev)/channels/2, len(next)/channels/2)
+	start := len(prev) - fade*channels
+	for i := range fade {
+		w := (float32(i) + 0.5) / float32(fade)
+		for c := range channels {
+			j := i*channels + c
+			prev[start+j] = prev[start+j]*(1-w) + next[j]*w
+		}
+	}
+	return append(prev, next[fade*channels:]...)
+}
+
+// cachedPrompt plays a cached sequence
+type cachedPrompt struct {
+	seq *cachedSequence
+	pos int // samples
+}
+
+func (p *cachedPrompt) SampleRate() int      { return p.seq.format.Rate }
+func (p *cachedPrompt) Channels() int        { return p.seq.format.Channels }
+func (p *cachedPrompt) BufSize() int         { return 4096 }
+func (p *cachedPrompt) Format() audio.Format { return p.seq.format }
+func (p *cachedPrompt) Close() error         { return nil }
+
+func (p *cachedPrompt) ReadSamples(dst []float32) (int, error) {
+	ch := p.seq.format.Channels
+	n := copy(dst[:len(dst)-len(dst)%ch], p.seq.samples[p.pos:])
+	p.pos += n
+	if p.pos == len(p.seq.samples) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (p *cachedPrompt) SeekFrame(n int64) error {
+	if n < 0 || n > p.TotalFrames() {
+		return fmt.Errorf("%w: frame %d of %d", audio.ErrSeekOutOfRange, n, p.TotalFrames())
+	}
+	p.pos = int(n) * p.seq.format.Channels
+	return nil
+}
+
+func (p *cachedPrompt) Position() int64 { return int64(p.pos / p.seq.format.Channels) }
+
+func (p *cachedPrompt) TotalFrames() int64 {
+	return int64(len(p.seq.samples) / p.seq.format.Channels)
+}
+
+func (p *cachedPrompt) Duration() time.Duration {
+	return audio.FramesDuration(p.TotalFrames(), p.seq.format.Rate)
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// slnFile is frames of 8 kHz signed linear audio at value
+func slnFile(frames int, value int16) *fstest.MapFile {
+	data := make([]byte, 0, frames*2)
+	for range frames {
+		data = binary.LittleEndian.AppendUint16(data, uint16(value))
+	}
+	return &fstest.MapFile{Data: data}
+}
+
+// readPrompt reads src to the end and closes it
+func readPrompt(t *testing.T, src audio.Source) []float32 {
+	t.Helper()
+
+	var got []float32
+	buf := make([]float32, 100)
+	for {
+		n, err := src.ReadSamples(buf)
+		got = append(got, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return got
+}
+
+func TestPromptCache_Open(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"en/please-hold.sln": slnFile(800, 16384),   // 0.5
+		"en/thank-you.sln":   slnFile(400, -16384),  // -0.5
+		"fr/thank-you.sln":   slnFile(400, 8192),    // 0.25
+		"en/long.sln":        slnFile(80000, 16384), // 10 s
+	}
+	set := NewPromptSet(NewPromptLoader(fsys), PromptSetOptions{})
+	target := PromptTarget{Format: audio.Format{Rate: 8000, Channels: 1}}
+
+	tests := []struct {
+		name      string
+		crossfade time.Duration
+		keys      []string
+		locale    string
+		wantLen   int
+		// The samples at the join
+		at   int
+		want []float32
+	}{
+		{name: "butted", crossfade: -1, keys: []string{"please-hold", "thank-you"}, wantLen: 1200, at: 799, want: []float32{0.5, -0.5}},
+		// 5 ms, 40 frames: half way through the fade, both at half gain
+		{name: "crossfaded", keys: []string{"please-hold", "thank-you"}, wantLen: 1160, at: 779, want: []float32{0.0125, -0.0125}},
+		{name: "fallback", crossfade: -1, keys: []string{"please-hold", "thank-you"}, locale: "fr", wantLen: 1200, at: 799, want: []float32{0.5, 0.25}},
+		// Never more than half of the shorter prompt
+		{name: "long fade", crossfade: time.Second, keys: []string{"thank-you", "please-hold"}, wantLen: 1000, at: 200, want: []float32{-0.4975}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cache := NewPromptCache(set, target, PromptCacheOptions{Crossfade: tt.crossfade})
+			src, err := cache.Open(tt.keys, tt.locale)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if got := src.(audio.Lengther).TotalFrames(); got != int64(tt.wantLen) {
+				t.Errorf("TotalFrames() = %d, want %d", got, tt.wantLen)
+			}
+
+			got := readPrompt(t, src)
+			if len(got) != tt.wantLen {
+				t.Fatalf("read %d samples, want %d", len(got), tt.wantLen)
+			}
+			for i, want := range tt.want {
+				if math.Abs(float64(got[tt.at+i]-want)) > 1e-4 {
+					t.Errorf("sample %d = %v, want %v", tt.at+i, got[tt.at+i], want)
+				}
+			}
+			if cache.Length() != audio.FramesDuration(int64(tt.wantLen), 8000) {
+				t.Errorf("Length() = %v, want the sequence", cache.Length())
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		cache := NewPromptCache(set, target, PromptCacheOptions{MaxLength: 5 * time.Second})
+		if _, err := cache.Open(nil, "en"); !errors.Is(err, audio.ErrInvalidState) {
+			t.Errorf("Open(nil) error = %v, want ErrInvalidState", err)
+		}
+		if _, err := cache.Open([]string{"please-hold", "nope"}, "en"); !errors.Is(err, ErrPromptNotFound) {
+			t.Errorf("Open() of a missing prompt error = %v, want ErrPromptNotFound", err)
+		}
+		if err := cache.Compile([]string{"long"}, "en"); !errors.Is(err, ErrPromptTooLong) {
+			t.Errorf("Compile() of 10 s error = %v, want ErrPromptTooLong", err)
+		}
+		if cache.Length() != 0 {
+			t.Errorf("Length() = %v after failures, want 0", cache.Length())
+		}
+	})
+}
+
+func TestPromptCache_Caching(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"en/a.sln": slnFile(8000, 16384),
+		"en/b.sln": slnFile(8000, 16384),
+		"en/c.sln": slnFile(8000, 16384),
+	}
+	set := NewPromptSet(NewPromptLoader(fsys), PromptSetOptions{})
+	target := PromptTarget{Format: audio.Format{Rate: 8000, Channels: 1}}
+	// Room for two of the one second prompts
+	cache := NewPromptCache(set, target, PromptCacheOptions{MaxLength: 2500 * time.Millisecond})
+
+	for _, key := range []string{"a", "b"} {
+		if err := cache.Compile([]string{key}, "en"); err != nil {
+			t.Fatalf("Compile(%s) error = %v", key, err)
+		}
+	}
+
+	// Cached prompts play without their files, and seek
+	delete(fsys, "en/a.sln")
+	src, err := cache.Open([]string{"a"}, "en")
+	if err != nil {
+		t.Fatalf("Open() of a cached prompt error = %v", err)
+	}
+	seeker := src.(audio.Seeker)
+	if err := seeker.SeekFrame(7900); err != nil {
+		t.Fatalf("SeekFrame() error = %v", err)
+	}
+	if got := readPrompt(t, src); len(got) != 100 || got[0] != 0.5 {
+		t.Errorf("read %d samples after the seek, want 100 at 0.5", len(got))
+	}
+	if err := seeker.SeekFrame(8001); !errors.Is(err, audio.ErrSeekOutOfRange) {
+		t.Errorf("SeekFrame() past the end error = %v, want ErrSeekOutOfRange", err)
+	}
+
+	// c makes room by dropping b, played longest ago
+	if err := cache.Compile([]string{"c"}, "en"); err != nil {
+		t.Fatalf("Compile(c) error = %v", err)
+	}
+	if cache.Length() != 2*time.Second {
+		t.Errorf("Length() = %v, want 2s", cache.Length())
+	}
+	delete(fsys, "en/b.sln")
+	if _, err := cache.Open([]string{"b"}, "en"); !errors.Is(err, ErrPromptNotFound) {
+		t.Errorf("Open() of a dropped prompt error = %v, want ErrPromptNotFound", err)
+	}
+	if _, err := cache.Open([]string{"a"}, "en"); err != nil {
+		t.Errorf("Open() of a kept prompt error = %v", err)
+	}
+
+	cache.Reset()
+	if _, err := cache.Open([]string{"a"}, "en"); !errors.Is(err, ErrPromptNotFound) {
+		t.Errorf("Open() after Reset error = %v, want ErrPromptNotFound", err)
+	}
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// DefaultPromptLocale is the locale a PromptSet falls back to by default.
+const DefaultPromptLocale = "en"
+
+// PromptSetOptions configures a PromptSet. Zero values select the
+// defaults.
+type PromptSetOptions struct {
+	// Fallback lists the locales tried, in order, after the requested one
+	// and its parents: []string{"en"} by default. An empty, non-nil slice
+	// disables the fallback.
+	Fallback []string
+	// Path returns the name of the prompt key in locale, as given to
+	// PromptLoader.Find. The default, locale + "/" + key, is the layout
+	// of Asterisk's sounds directory: "fr_CA/vm-intro".
+	Path func(locale, key string) string
+}
+
+func (o PromptSetOptions) withDefaults() PromptSetOptions {
+	if o.Fallback == nil {
+		o.Fallback = []string{DefaultPromptLocale}
+	}
+	if o.Path == nil {
+		o.Path = func(locale, key string) string { return locale + "/" + key }
+	}
+	return o
+}
+
+// PromptSet resolves prompts by key and locale, so a multilingual IVR asks
+// for "vm-intro" in the caller's locale rather than building paths. A
+// prompt missing in the locale is taken from its parent locales, then from
+// the fallback ones: for "fr_CA", from fr_CA, fr and en in turn. Within a
+// locale, the PromptLoader picks the file cheapest to play.
+type PromptSet struct {
+	loader *PromptLoader
+	opts   PromptSetOptions
+}
+
+// NewPromptSet creates a prompt set over the files of loader.
+func NewPromptSet(loader *PromptLoader, opts PromptSetOptions) *PromptSet {
+	return &PromptSet{loader: loader, opts: opts.withDefaults()}
+}
+
+// Locales returns the locales searched for locale, in order. Locales are
+// written like fr_CA; fr-CA and POSIX ones like fr_CA.UTF-8 are read the
+// same way.
+func (s *PromptSet) Locales(locale string) []string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	locale = strings.ReplaceAll(locale, "-", "_")
+
+	var chain []string
+	add := func(l string) {
+		for _, c := range chain {
+			if c == l {
+				return
+			}
+		}
+		chain = append(chain, l)
+	}
+	for l := locale; l != ""; {
+		add(l)
+		i := strings.LastIndexByte(l, '_')
+		if i < 0 {
+			break
+		}
+		l = l[:i]
+	}
+	for _, l := range s.opts.Fallback {
+		add(l)
+	}
+	return chain
+}
+
+// Find returns the file of the prompt key in the first locale for locale
+// that has one, as PromptLoader.Find picks it. Prompts missing in every
+// locale fail with ErrPromptNotFound.
+func (s *PromptSet) Find(key, locale string, target PromptTarget) (PromptFile, error) {
+	var file PromptFile
+	err := s.resolve(key, locale, func(name string) error {
+		var err error
+		file, err = s.loader.Find(name, target)
+		return err
+	})
+	return file, err
+}
+
+// Open opens the prompt key in the first locale for locale that has it,
+// converted for target as PromptLoader.Open does.
+func (s *PromptSet) Open(key, locale string, target PromptTarget) (audio.Source, error) {
+	var src audio.Source
+	err := s.resolve(key, locale, func(name string) error {
+		var err error
+		src, err = s.loader.Open(name, target)
+		return err
+	})
+	return src, err
+}
+
+// resolve calls load with the name of key in each locale for locale until
+// it finds the prompt
+func (s *PromptSet) resolve(key, locale string, load func(name string) error) error {
+	chain := s.Locales(locale)
+	for _, l := range chain {
+		err := load(s.opts.Path(l, key))
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrPromptNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s in %s", ErrPromptNotFound, key, strings.Join(chain, ", "))
+}
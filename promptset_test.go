@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+func TestPromptSet_Locales(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		locale   string
+		fallback []string
+		want     []string
+	}{
+		{locale: "fr_CA", want: []string{"fr_CA", "fr", "en"}},
+		{locale: "fr-CA", want: []string{"fr_CA", "fr", "en"}},
+		{locale: "fr_CA.UTF-8", want: []string{"fr_CA", "fr", "en"}},
+		{locale: "en_US", want: []string{"en_US", "en"}},
+		{locale: "", want: []string{"en"}},
+		{locale: "pt_BR", fallback: []string{"es", "en"}, want: []string{"pt_BR", "pt", "es", "en"}},
+		{locale: "de", fallback: []string{}, want: []string{"de"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewPromptSet(NewPromptLoader(fstest.MapFS{}), PromptSetOptions{Fallback: tt.fallback})
+			if got := s.Locales(tt.locale); !slices.Equal(got, tt.want) {
+				t.Errorf("Locales(%q) = %v, want %v", tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromptSet_Find(t *testing.T) {
+	t.Parallel()
+
+	sln := make([]byte, 160)
+	fsys := fstest.MapFS{
+		"en/vm-intro.sln":     {Data: sln},
+		"en/vm-goodbye.sln":   {Data: sln},
+		"fr/vm-intro.sln":     {Data: sln},
+		"fr_CA/vm-intro.ulaw": {Data: sln},
+		"fr/vm-goodbye.mp3":   {Data: []byte("not an mp3")},
+		"sounds-es-vm.ulaw":   {Data: sln},
+	}
+	set := NewPromptSet(NewPromptLoader(fsys), PromptSetOptions{})
+	target := PromptTarget{Format: audio.Format{Rate: 8000, Channels: 1}}
+
+	tests := []struct {
+		name     string
+		set      *PromptSet
+		key      string
+		locale   string
+		wantPath string
+		wantErr  error
+	}{
+		// Locale first, even when a parent has a cheaper file
+		{name: "own locale", set: set, key: "vm-intro", locale: "fr_CA", wantPath: "fr_CA/vm-intro.ulaw"},
+		{name: "parent locale", set: set, key: "vm-intro", locale: "fr_BE", wantPath: "fr/vm-intro.sln"},
+		{name: "fallback locale", set: set, key: "vm-intro", locale: "de_DE", wantPath: "en/vm-intro.sln"},
+		{name: "unreadable files skipped", set: set, key: "vm-goodbye", locale: "fr", wantPath: "en/vm-goodbye.sln"},
+		{name: "missing", set: set, key: "vm-nope", locale: "fr_CA", wantErr: ErrPromptNotFound},
+		{
+			name: "custom layout",
+			set: NewPromptSet(NewPromptLoader(fsys), PromptSetOptions{
+				Path: func(locale, key string) string { return "sounds-" + locale + "-" + key },
+			}),
+			key: "vm", locale: "es_MX", wantPath: "sounds-es-vm.ulaw",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file, err := tt.set.Find(tt.key, tt.locale, target)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Find() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if file.Path != tt.wantPath {
+				t.Errorf("Find() = %s, want %s", file.Path, tt.wantPath)
+			}
+
+			src, err := tt.set.Open(tt.key, tt.locale, target)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if err := audio.FormatOf(src).Compatible(target.Format); err != nil {
+				t.Errorf("Open() format: %v", err)
+			}
+			if err := src.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"testing"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// Golden-ear regression corpus: speech-like material rendered at common
+// input rates is pushed through the telephony presets and scored against
+// the same material rendered directly at the target rate. The minimum
+// scores were recorded on a known-good build; a filter or interpolation
+// change that makes the output audibly worse drops below them. The clips
+// are the same generator with different seeds, so they differ in pitch
+// contours, formants and the placement of fricatives and pauses, not in
+// kind.
+
+var goldenCorpus = []struct {
+	name string
+	seed int64
+}{
+	{"seed-1", 1},
+	{"seed-7", 7},
+	{"seed-13", 13},
+}
+
+var telephonyPresets = []struct {
+	name       string
+	srcRate    int
+	targetRate int
+	minScore   float64
+}{
+	{"cd-to-narrowband", 44100, 8000, 3.45},
+	{"studio-to-narrowband", 48000, 8000, 3.45},
+	{"studio-to-wideband", 48000, 16000, 4.25},
+	{"wideband-to-narrowband", 16000, 8000, 3.6},
+	{"narrowband-to-wideband", 8000, 16000, 3.0},
+}
+
+func TestResampleToMono16_GoldenCorpus(t *testing.T) {
+	t.Parallel()
+
+	const seconds = 2
+
+	for _, preset := range telephonyPresets {
+		t.Run(preset.name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, clip := range goldenCorpus {
+				input := audiotest.SpeechLikeSignal(preset.srcRate, seconds, clip.seed)
+				band := 0.45 * float64(min(preset.srcRate, preset.targetRate))
+				ref := audiotest.SpeechLikeSignalLimited(preset.targetRate, band, seconds, clip.seed)
+
+				src := audiotest.NewMockSource(preset.srcRate, 1, len(input), func(sample int, channel int) float32 {
+					return input[sample]
+				})
+
+				pcm16, _, err := ResampleToMono16(src, preset.targetRate, 4096)
+				if err != nil {
+					t.Fatalf("%s/%s: ResampleToMono16() error = %v", preset.name, clip.name, err)
+				}
+
+				out := make([]float32, len(pcm16))
+				for i, s := range pcm16 {
+					out[i] = float32(s) / 32768
+				}
+
+				score := audiotest.SpeechQuality(ref, out, preset.targetRate)
+				t.Logf("%s/%s: score %.2f", preset.name, clip.name, score)
+
+				if score < preset.minScore {
+					t.Errorf("%s/%s: score %.2f below golden minimum %.2f",
+						preset.name, clip.name, score, preset.minScore)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Defaults of BeepOptions.
+const (
+	DefaultBeepFrequency    = 1000.0
+	DefaultBeepDuration     = 500 * time.Millisecond
+	DefaultBeepLevel        = -10.0
+	DefaultMaxRecording     = 2 * time.Minute
+	DefaultSilenceTimeout   = 3 * time.Second
+	DefaultNoInputTimeout   = 5 * time.Second
+	DefaultSilenceThreshold = 0.02
+)
+
+// beepFrame is the duration of the blocks the endpointer looks at
+const beepFrame = 20 * time.Millisecond
+
+// beepRamp fades the beep in and out so it does not click
+const beepRamp = 5 * time.Millisecond
+
+// Encoder receives the recorded audio. The WAV and G.711 writers implement
+// it, as does anything else with the same method.
+type Encoder interface {
+	WriteSamples(samples []float32) error
+}
+
+// DigitDetector reports DTMF keys heard in a stream. Detect is given the
+// recorded audio in consecutive blocks and returns the key that ended in
+// that block, if any.
+type DigitDetector interface {
+	Detect(samples []float32) (digit rune, ok bool)
+}
+
+// StopReason tells why RecordAfterBeep stopped recording.
+type StopReason int
+
+const (
+	// StopEnded means the source ended, as when the caller hangs up.
+	StopEnded StopReason = iota
+	// StopMaxDuration means the recording reached BeepOptions.MaxDuration.
+	StopMaxDuration
+	// StopSilence means the caller stopped talking for SilenceTimeout.
+	StopSilence
+	// StopNoInput means the caller never started talking.
+	StopNoInput
+	// StopDigit means the caller pressed a terminating key.
+	StopDigit
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopEnded:
+		return "ended"
+	case StopMaxDuration:
+		return "max duration"
+	case StopSilence:
+		return "silence"
+	case StopNoInput:
+		return "no input"
+	case StopDigit:
+		return "digit"
+	default:
+		return fmt.Sprintf("StopReason(%d)", int(r))
+	}
+}
+
+// BeepOptions configures RecordAfterBeep. Zero values select the defaults.
+type BeepOptions struct {
+	// Play plays the beep to the caller, returning once it has been
+	// played. The beep has the rate and channel count of the recorded
+	// source. nil skips the beep, for callers that play their own.
+	Play func(beep audio.Source) error
+
+	// BeepFrequency is the pitch of the beep, 1000 Hz by default.
+	BeepFrequency float64
+	// BeepDuration is the length of the beep, 500 ms by default.
+	BeepDuration time.Duration
+	// BeepLevel is the level of the beep in dBFS, -10 by default.
+	BeepLevel float64
+
+	// MaxDuration caps the length of the recording, 2 minutes by default.
+	MaxDuration time.Duration
+	// SilenceTimeout ends the recording once the caller has been quiet for
+	// that long after speaking, 3 s by default.
+	SilenceTimeout time.Duration
+	// NoInputTimeout ends the recording if the caller has not started
+	// speaking within that time, 5 s by default.
+	NoInputTimeout time.Duration
+	// SilenceThreshold is the peak level, in [0, 1], below which audio
+	// counts as silence. The default, 0.02 (-34 dBFS), ignores line noise.
+	SilenceThreshold float32
+
+	// Digits, when set, listens for DTMF keys in the recorded audio.
+	Digits DigitDetector
+	// Terminators are the keys that end the recording, "#" by default.
+	// Other keys are ignored.
+	Terminators string
+}
+
+func (o BeepOptions) withDefaults() BeepOptions {
+	if o.BeepFrequency <= 0 {
+		o.BeepFrequency = DefaultBeepFrequency
+	}
+	if o.BeepDuration <= 0 {
+		o.BeepDuration = DefaultBeepDuration
+	}
+	if o.BeepLevel == 0 {
+		o.BeepLevel = DefaultBeepLevel
+	}
+	if o.MaxDuration <= 0 {
+		o.MaxDuration = DefaultMaxRecording
+	}
+	if o.SilenceTimeout <= 0 {
+		o.SilenceTimeout = DefaultSilenceTimeout
+	}
+	if o.NoInputTimeout <= 0 {
+		o.NoInputTimeout = DefaultNoInputTimeout
+	}
+	if o.SilenceThreshold <= 0 {
+		o.SilenceThreshold = DefaultSilenceThreshold
+	}
+	if o.Terminators == "" {
+		o.Terminators = "#"
+	}
+	return o
+}
+
+// BeepResult describes a recording made by RecordAfterBeep.
+type BeepResult struct {
+	// Frames is the number of sample frames written to the Encoder.
+	Frames int64
+	// Duration is the length of the recording.
+	Duration time.Duration
+	// Reason is why the recording stopped.
+	Reason StopReason
+	// Digit is the key that stopped the recording when Reason is
+	// StopDigit.
+	Digit rune
+}
+
+// RecordAfterBeep plays a beep, then records src into sink until the
+// caller stops talking, presses a terminating key, the maximum duration is
+// reached or src ends: the usual voicemail flow.
+//
+// Silence after the last word is left out of the recording, and so is the
+// block in which a terminating key was detected; the start of the key tone
+// may remain. src is not closed, so the call can go on after recording.
+func RecordAfterBeep(src audio.Source, sink Encoder, opts BeepOptions) (BeepResult, error) {
+	format := audio.FormatOf(src)
+	if err := format.Validate(); err != nil {
+		return BeepResult{}, fmt.Errorf("%w", err)
+	}
+	opts = opts.withDefaults()
+
+	if opts.Play != nil {
+		beep := newBeep(format, opts.BeepFrequency, opts.BeepDuration, opts.BeepLevel)
+		if err := opts.Play(beep); err != nil {
+			return BeepResult{}, fmt.Errorf("playing beep: %w", err)
+		}
+	}
+
+	rate := format.Rate
+	ch := format.Channels
+	var (
+		res     BeepResult
+		spoken  bool
+		pending []float32 // silence held back until speech resumes
+		heard   int64     // frames read since the beep
+	)
+	maxFrames := int64(audio.FrameLen(rate, opts.MaxDuration))
+	silence := int64(audio.FrameLen(rate, opts.SilenceTimeout))
+	noInput := int64(audio.FrameLen(rate, opts.NoInputTimeout))
+
+	framer := audio.NewFramer(src, audio.FrameLen(rate, beepFrame))
+	for {
+		frame, valid, err := framer.Next()
+		if errors.Is(err, io.EOF) {
+			res.Reason = StopEnded
+			break
+		}
+		if err != nil {
+			return res.done(rate), fmt.Errorf("recording: %w", err)
+		}
+		frame = frame[:valid*ch]
+		heard += int64(valid)
+
+		if opts.Digits != nil {
+			if d, ok := opts.Digits.Detect(frame); ok && strings.ContainsRune(opts.Terminators, d) {
+				res.Reason, res.Digit = StopDigit, d
+				break
+			}
+		}
+
+		var peak float32
+		for _, v := range frame {
+			peak = max(peak, v, -v)
+		}
+
+		if peak < opts.SilenceThreshold {
+			if !spoken {
+				if heard >= noInput {
+					res.Reason = StopNoInput
+					break
+				}
+				continue
+			}
+			pending = append(pending, frame...)
+			if int64(len(pending)/ch) >= silence {
+				res.Reason = StopSilence
+				break
+			}
+			continue
+		}
+
+		spoken = true
+		if len(pending) > 0 {
+			if err := res.write(sink, pending, ch, maxFrames); err != nil {
+				return res.done(rate), err
+			}
+			pending = pending[:0]
+		}
+		if err := res.write(sink, frame, ch, maxFrames); err != nil {
+			return res.done(rate), err
+		}
+		if res.Frames >= maxFrames {
+			res.Reason = StopMaxDuration
+			break
+		}
+	}
+
+	return res.done(rate), nil
+}
+
+// write passes samples to sink, cut off at maxFrames
+func (r *BeepResult) write(sink Encoder, samples []float32, ch int, maxFrames int64) error {
+	frames := min(int64(len(samples)/ch), maxFrames-r.Frames)
+	if frames <= 0 {
+		return nil
+	}
+	if err := sink.WriteSamples(samples[:frames*int64(ch)]); err != nil {
+		return fmt.Errorf("writing recording: %w", err)
+	}
+	r.Frames += frames
+	return nil
+}
+
+func (r BeepResult) done(rate int) BeepResult {
+	r.Duration = time.Duration(r.Frames) * time.Second / time.Duration(rate)
+	return r
+}
+
+// beep is a sine tone with short fades at both ends
+type beep struct {
+	format audio.Format
+	step   float64 // phase increment per frame
+	amp    float64
+	frames int
+	ramp   int
+	pos    int
+}
+
+func newBeep(format audio.Format, freq float64, d time.Duration, level float64) *beep {
+	frames := audio.FrameLen(format.Rate, d)
+	return &beep{
+		format: audio.Format{Rate: format.Rate, Channels: format.Channels},
+		step:   2 * math.Pi * freq / float64(format.Rate),
+		amp:    math.Pow(10, level/20),
+		frames: frames,
+		ramp:   min(audio.FrameLen(format.Rate, beepRamp), frames/2),
+	}
+}
+
+func (b *beep) SampleRate() int      { return b.format.Rate }
+func (b *beep) Channels() int        { return b.format.Channels }
+func (b *beep) BufSize() int         { return 4096 }
+func (b *beep) Format() audio.Format { return b.format }
+func (b *beep) Close() error         { return nil }
+
+func (b *beep) ReadSamples(dst []float32) (int, error) {
+	ch := b.format.Channels
+	if len(dst)%ch != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+	if b.pos >= b.frames {
+		return 0, io.EOF
+	}
+
+	n := min(len(dst)/ch, b.frames-b.pos)
+	for i := range n {
+		p := b.pos + i
+		gain := 1.0
+		if edge := min(p, b.frames-1-p); edge < b.ramp {
+			gain = float64(edge) / float64(b.ramp)
+		}
+		v := float32(b.amp * gain * math.Sin(b.step*float64(p)))
+		for c := range ch {
+			dst[i*ch+c] = v
+		}
+	}
+	b.pos += n
+	return n * ch, nil
+}
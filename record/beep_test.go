@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+type sampleSink struct {
+	samples []float32
+	err     error
+}
+
+func (s *sampleSink) WriteSamples(samples []float32) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.samples = append(s.samples, samples...)
+	return nil
+}
+
+// blockDigits reports digits[i] for the i-th block it is given
+type blockDigits struct {
+	digits map[int]rune
+	block  int
+}
+
+func (d *blockDigits) Detect([]float32) (rune, bool) {
+	r, ok := d.digits[d.block]
+	d.block++
+	return r, ok
+}
+
+// speech is a 1 kHz mono source of frames, at 0.5 inside spans and silent
+// elsewhere
+func speech(frames int, spans ...[2]int) audio.Source {
+	return audiotest.NewMockSource(1000, 1, frames, func(sample, _ int) float32 {
+		for _, s := range spans {
+			if sample >= s[0] && sample < s[1] {
+				return 0.5
+			}
+		}
+		return 0
+	})
+}
+
+func TestRecordAfterBeep_Endpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		src        audio.Source
+		opts       BeepOptions
+		wantFrames int64
+		wantReason StopReason
+		wantDigit  rune
+	}{
+		{
+			name:       "silence",
+			src:        speech(10000, [2]int{500, 1500}),
+			wantFrames: 1000,
+			wantReason: StopSilence,
+		},
+		{
+			name:       "pause kept, trailing silence dropped",
+			src:        speech(2600, [2]int{0, 1000}, [2]int{2000, 2500}),
+			wantFrames: 2500,
+			wantReason: StopEnded,
+		},
+		{
+			name:       "no input",
+			src:        speech(10000),
+			wantReason: StopNoInput,
+		},
+		{
+			name:       "max duration",
+			src:        speech(100000, [2]int{0, 100000}),
+			opts:       BeepOptions{MaxDuration: time.Second},
+			wantFrames: 1000,
+			wantReason: StopMaxDuration,
+		},
+		{
+			name: "terminating digit",
+			src:  speech(3000, [2]int{0, 3000}),
+			opts: BeepOptions{
+				// 1 is not a terminator; # ends the 50th 20 ms block
+				Digits: &blockDigits{digits: map[int]rune{10: '1', 49: '#'}},
+			},
+			wantFrames: 980,
+			wantReason: StopDigit,
+			wantDigit:  '#',
+		},
+		{
+			name: "custom terminators",
+			src:  speech(3000, [2]int{0, 3000}),
+			opts: BeepOptions{
+				Digits:      &blockDigits{digits: map[int]rune{10: '1', 49: '#'}},
+				Terminators: "*1",
+			},
+			wantFrames: 200,
+			wantReason: StopDigit,
+			wantDigit:  '1',
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink := &sampleSink{}
+			res, err := RecordAfterBeep(tt.src, sink, tt.opts)
+			if err != nil {
+				t.Fatalf("RecordAfterBeep() error = %v", err)
+			}
+			if res.Reason != tt.wantReason || res.Digit != tt.wantDigit {
+				t.Errorf("stopped by %v (%q), want %v (%q)", res.Reason, res.Digit, tt.wantReason, tt.wantDigit)
+			}
+			if res.Frames != tt.wantFrames || int64(len(sink.samples)) != tt.wantFrames {
+				t.Errorf("recorded %d frames, wrote %d, want %d", res.Frames, len(sink.samples), tt.wantFrames)
+			}
+			if want := time.Duration(tt.wantFrames) * time.Millisecond; res.Duration != want {
+				t.Errorf("Duration = %v, want %v", res.Duration, want)
+			}
+		})
+	}
+}
+
+func TestRecordAfterBeep_Beep(t *testing.T) {
+	t.Parallel()
+
+	var beep []float32
+	var played bool
+	opts := BeepOptions{
+		Play: func(b audio.Source) error {
+			if b.SampleRate() != 8000 || b.Channels() != 2 {
+				t.Errorf("beep is %d Hz, %d channels, want 8000 Hz, 2", b.SampleRate(), b.Channels())
+			}
+			buf := make([]float32, 256)
+			for {
+				n, err := b.ReadSamples(buf)
+				beep = append(beep, buf[:n]...)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					return err
+				}
+			}
+			played = true
+			return b.Close()
+		},
+	}
+
+	src := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 2, 100))
+	if _, err := RecordAfterBeep(src, &sampleSink{}, opts); err != nil {
+		t.Fatalf("RecordAfterBeep() error = %v", err)
+	}
+	if !played {
+		t.Fatal("the beep was not played")
+	}
+	if src.Closed() {
+		t.Error("RecordAfterBeep() closed the source")
+	}
+
+	if got := len(beep) / 2; got != 4000 {
+		t.Fatalf("beep is %d frames, want 4000 (500 ms)", got)
+	}
+	var peak float64
+	for i, v := range beep {
+		peak = max(peak, math.Abs(float64(v)))
+		if i%2 == 1 && beep[i-1] != v {
+			t.Fatalf("channels differ at frame %d", i/2)
+		}
+	}
+	if want := math.Pow(10, -0.5); math.Abs(peak-want) > 0.01 {
+		t.Errorf("beep peak = %.3f, want %.3f (-10 dBFS)", peak, want)
+	}
+	if beep[0] != 0 || math.Abs(float64(beep[len(beep)-1])) > 0.01 {
+		t.Errorf("beep edges = %v, %v, want faded to 0", beep[0], beep[len(beep)-1])
+	}
+}
+
+func TestRecordAfterBeep_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		src        audio.Source
+		sink       *sampleSink
+		opts       BeepOptions
+		wantFrames int64
+	}{
+		{
+			name: "play",
+			src:  speech(100, [2]int{0, 100}),
+			sink: &sampleSink{},
+			opts: BeepOptions{Play: func(audio.Source) error { return audiotest.ErrInjected }},
+		},
+		{
+			name: "sink",
+			src:  speech(100, [2]int{0, 100}),
+			sink: &sampleSink{err: audiotest.ErrInjected},
+		},
+		{
+			name: "source",
+			src: audiotest.NewFaultySource(speech(1000, [2]int{0, 1000}),
+				audiotest.WithShortReads(20), audiotest.WithErrorOnCall(3, audiotest.ErrInjected)),
+			sink:       &sampleSink{},
+			wantFrames: 40,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := RecordAfterBeep(tt.src, tt.sink, tt.opts)
+			if !errors.Is(err, audiotest.ErrInjected) {
+				t.Errorf("RecordAfterBeep() error = %v, want ErrInjected", err)
+			}
+			if res.Frames != tt.wantFrames {
+				t.Errorf("recorded %d frames before the error, want %d", res.Frames, tt.wantFrames)
+			}
+		})
+	}
+}
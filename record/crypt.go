@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// SegmentSize is the size of the plaintext segments an EncryptWriter
+	// seals one by one, so neither side holds more than one in memory.
+	SegmentSize = 64 * 1024
+
+	// envelopeMagic starts every encrypted file, followed by the version
+	envelopeMagic   = "APBXENC"
+	envelopeVersion = 1
+
+	// noncePrefixSize is the random part of the segment nonces; the rest
+	// is the segment number and the last segment flag
+	noncePrefixSize = 7
+
+	// maxKeyID is the longest key ID the header can hold
+	maxKeyID = 255
+)
+
+// Key is an AES key used to encrypt recordings at rest. Its ID is stored,
+// in the clear, in the header of every file it encrypts, so the key of a
+// file can be found again after keys have been rotated.
+type Key struct {
+	// ID names the key, such as "2024-06" or a KMS key version. It is at
+	// most 255 bytes long and must not be secret.
+	ID string
+	// Secret is the AES key: 16, 24 or 32 bytes for AES-128, AES-192 or
+	// AES-256.
+	Secret []byte
+}
+
+// aead returns the AES-GCM cipher of the key
+func (k Key) aead() (cipher.AEAD, error) {
+	if len(k.ID) > maxKeyID {
+		return nil, fmt.Errorf("%w: ID of %d bytes", ErrInvalidKey, len(k.ID))
+	}
+	block, err := aes.NewCipher(k.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	return gcm, nil
+}
+
+// EncryptWriter encrypts what is written to it with AES-GCM into a
+// streaming envelope: a header naming the key, then segments of up to
+// SegmentSize bytes, each sealed on its own. The header is authenticated
+// with every segment and the last segment is marked, so a file that is
+// altered, reordered or cut short fails to decrypt rather than losing its
+// end silently.
+//
+// Put it between an encoder and the file, as the Recorder does with
+// Options.Encrypt:
+//
+//	ew, err := record.NewEncryptWriter(file, key)
+//	err = wav.Encode(ew, src)
+//	err = ew.Close()
+type EncryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	nonce  []byte
+	seq    uint32
+	buf    []byte // plaintext of the segment being filled
+	out    []byte
+	err    error
+}
+
+// NewEncryptWriter writes the envelope header to w and returns a writer
+// encrypting into it with key.
+func NewEncryptWriter(w io.Writer, key Key) (*EncryptWriter, error) {
+	aead, err := key.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	header := make([]byte, 0, len(envelopeMagic)+1+4+noncePrefixSize+1+len(key.ID))
+	header = append(header, envelopeMagic...)
+	header = append(header, envelopeVersion)
+	header = binary.BigEndian.AppendUint32(header, SegmentSize)
+	header = append(header, prefix...)
+	header = append(header, byte(len(key.ID)))
+	header = append(header, key.ID...)
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return &EncryptWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		nonce:  append(prefix, make([]byte, aead.NonceSize()-noncePrefixSize)...),
+		buf:    make([]byte, 0, SegmentSize),
+	}, nil
+}
+
+// Write encrypts p. Segments are written once full, the last one by Close.
+func (e *EncryptWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full segment is only sealed once more data follows, as the
+		// last one is sealed differently
+		if len(e.buf) == SegmentSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(len(p), SegmentSize-len(e.buf))
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the last segment. It does not close the underlying writer.
+func (e *EncryptWriter) Close() error {
+	if e.err != nil {
+		if errors.Is(e.err, ErrEncryptWriterClosed) {
+			return nil
+		}
+		return e.err
+	}
+	if err := e.seal(true); err != nil {
+		return err
+	}
+	e.err = ErrEncryptWriterClosed
+	return nil
+}
+
+// seal encrypts and writes the buffered segment
+func (e *EncryptWriter) seal(last bool) error {
+	segmentNonce(e.nonce, e.seq, last)
+	e.out = e.aead.Seal(e.out[:0], e.nonce, e.buf, e.header)
+	if _, err := e.w.Write(e.out); err != nil {
+		e.err = fmt.Errorf("%w", err)
+		return e.err
+	}
+	e.seq++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// DecryptReader reads the plaintext of a file written by an EncryptWriter,
+// segment by segment, so it can be given to a decoder as is:
+//
+//	dr, err := record.NewDecryptReader(file, keys)
+//	src, err := wav.Decoder{}.Decode(dr)
+//
+// Every segment is authenticated before any of it is returned. Read fails
+// with ErrDecrypt when the file was altered or cut short.
+type DecryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	keyID   string
+	nonce   []byte
+	seq     uint32
+	segment int // ciphertext bytes of a full segment
+	in      []byte
+	plain   []byte // not yet read
+	err     error
+}
+
+// NewDecryptReader reads the envelope header from r and returns a reader
+// of the plaintext. keys returns the secret of the key with the ID found
+// in the header; its errors are returned as they are.
+func NewDecryptReader(r io.Reader, keys func(id string) ([]byte, error)) (*DecryptReader, error) {
+	br := bufio.NewReader(r)
+
+	fixed := make([]byte, len(envelopeMagic)+1+4+noncePrefixSize+1)
+	if _, err := io.ReadFull(br, fixed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNotEncrypted
+		}
+		return nil, fmt.Errorf("%w", err)
+	}
+	if !bytes.HasPrefix(fixed, []byte(envelopeMagic)) {
+		return nil, ErrNotEncrypted
+	}
+	if v := fixed[len(envelopeMagic)]; v != envelopeVersion {
+		return nil, fmt.Errorf("%w: envelope version %d", ErrNotEncrypted, v)
+	}
+	size := binary.BigEndian.Uint32(fixed[len(envelopeMagic)+1:])
+	if size == 0 || size > 16*SegmentSize {
+		return nil, fmt.Errorf("%w: segment size %d", ErrNotEncrypted, size)
+	}
+
+	id := make([]byte, fixed[len(fixed)-1])
+	if _, err := io.ReadFull(br, id); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotEncrypted, err)
+	}
+	secret, err := keys(string(id))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := Key{ID: string(id), Secret: secret}.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fixed[len(envelopeMagic)+5 : len(envelopeMagic)+5+noncePrefixSize]
+	return &DecryptReader{
+		r:       br,
+		aead:    aead,
+		header:  append(fixed, id...),
+		keyID:   string(id),
+		nonce:   append(bytes.Clone(prefix), make([]byte, aead.NonceSize()-noncePrefixSize)...),
+		segment: int(size) + aead.Overhead(),
+	}, nil
+}
+
+// KeyID returns the ID of the key the file is encrypted with.
+func (d *DecryptReader) KeyID() string { return d.keyID }
+
+// Read reads the plaintext, returning io.EOF after the last segment.
+func (d *DecryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.open()
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open reads and authenticates the next segment
+func (d *DecryptReader) open() error {
+	if cap(d.in) < d.segment {
+		d.in = make([]byte, d.segment)
+	}
+	n, err := io.ReadFull(d.r, d.in[:d.segment])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			// The last segment, even empty, is never missing
+			return fmt.Errorf("%w: truncated", ErrDecrypt)
+		}
+		return fmt.Errorf("%w", err)
+	}
+
+	// The last segment is the one the file ends with
+	last := err != nil
+	if !last {
+		if _, err := d.r.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	segmentNonce(d.nonce, d.seq, last)
+	plain, err := d.aead.Open(d.in[:0], d.nonce, d.in[:n], d.header)
+	if err != nil {
+		return fmt.Errorf("%w: segment %d", ErrDecrypt, d.seq)
+	}
+	d.seq++
+	d.plain = plain
+
+	if last {
+		return io.EOF
+	}
+	return nil
+}
+
+// segmentNonce fills the segment number and last segment flag of nonce,
+// after its random prefix
+func segmentNonce(nonce []byte, seq uint32, last bool) {
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], seq)
+	nonce[len(nonce)-1] = 0
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+}
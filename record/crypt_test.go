@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+var testKey = Key{ID: "2024-06", Secret: bytes.Repeat([]byte{0x42}, 32)}
+
+// testKeys knows testKey only
+func testKeys(id string) ([]byte, error) {
+	if id != testKey.ID {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return testKey.Secret, nil
+}
+
+func encrypt(t *testing.T, plain []byte) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	ew, err := NewEncryptWriter(&out, testKey)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter() error = %v", err)
+	}
+	// Odd sized writes cross the segments
+	for len(plain) > 0 {
+		n := min(len(plain), 10007)
+		if _, err := ew.Write(plain[:n]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		plain = plain[n:]
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return out.Bytes()
+}
+
+func decrypt(data []byte) ([]byte, error) {
+	dr, err := NewDecryptReader(bytes.NewReader(data), testKeys)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dr)
+}
+
+func TestEncryptWriter_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{0, 1, SegmentSize - 1, SegmentSize, SegmentSize + 1, 3 * SegmentSize} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			t.Parallel()
+
+			plain := make([]byte, size)
+			_, _ = rand.Read(plain)
+			data := encrypt(t, plain)
+			// A few random bytes turn up in any ciphertext by chance
+			if size >= 16 && bytes.Contains(data, plain[:min(size, 64)]) {
+				t.Fatal("ciphertext holds the plaintext")
+			}
+
+			dr, err := NewDecryptReader(bytes.NewReader(data), testKeys)
+			if err != nil {
+				t.Fatalf("NewDecryptReader() error = %v", err)
+			}
+			if dr.KeyID() != testKey.ID {
+				t.Errorf("KeyID() = %q, want %q", dr.KeyID(), testKey.ID)
+			}
+			got, err := io.ReadAll(dr)
+			if err != nil {
+				t.Fatalf("reading: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decrypted %d bytes, want the %d written", len(got), size)
+			}
+		})
+	}
+}
+
+func TestDecryptReader_Errors(t *testing.T) {
+	t.Parallel()
+
+	plain := make([]byte, 2*SegmentSize+100)
+	data := encrypt(t, plain)
+	header := len(data) - len(plain) - 3*16
+	tampered := func(i int) []byte {
+		d := bytes.Clone(data)
+		d[i] ^= 1
+		return d
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		keys    func(string) ([]byte, error)
+		wantErr error
+	}{
+		{name: "not encrypted", data: []byte("RIFF\x00\x00\x00\x00WAVEfmt "), wantErr: ErrNotEncrypted},
+		{name: "empty", data: nil, wantErr: ErrNotEncrypted},
+		{name: "altered", data: tampered(len(data) / 2), wantErr: ErrDecrypt},
+		{name: "altered key ID", data: tampered(header - 1), wantErr: nil},
+		{name: "altered header", data: tampered(len(envelopeMagic) + 6), wantErr: ErrDecrypt},
+		{name: "cut at a segment", data: data[:header+SegmentSize+16], wantErr: ErrDecrypt},
+		{name: "cut in a segment", data: data[:len(data)-10], wantErr: ErrDecrypt},
+		{name: "last segment dropped", data: data[:header+2*(SegmentSize+16)], wantErr: ErrDecrypt},
+		{
+			name:    "wrong key",
+			data:    data,
+			keys:    func(string) ([]byte, error) { return bytes.Repeat([]byte{1}, 32), nil },
+			wantErr: ErrDecrypt,
+		},
+		{
+			name:    "bad key",
+			data:    data,
+			keys:    func(string) ([]byte, error) { return []byte("short"), nil },
+			wantErr: ErrInvalidKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			keys := tt.keys
+			if keys == nil {
+				keys = testKeys
+			}
+			var err error
+			if dr, derr := NewDecryptReader(bytes.NewReader(tt.data), keys); derr != nil {
+				err = derr
+			} else {
+				_, err = io.ReadAll(dr)
+			}
+
+			// An altered key ID names a key that does not exist
+			if tt.wantErr == nil {
+				if err == nil || errors.Is(err, ErrDecrypt) {
+					t.Errorf("error = %v, want the error of the key lookup", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptWriter_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewEncryptWriter(io.Discard, Key{ID: "k", Secret: []byte("short")}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("NewEncryptWriter(short secret) error = %v, want ErrInvalidKey", err)
+	}
+	long := Key{ID: string(make([]byte, 256)), Secret: testKey.Secret}
+	if _, err := NewEncryptWriter(io.Discard, long); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("NewEncryptWriter(long ID) error = %v, want ErrInvalidKey", err)
+	}
+
+	ew, err := NewEncryptWriter(io.Discard, testKey)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter() error = %v", err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if _, err := ew.Write([]byte{1}); !errors.Is(err, ErrEncryptWriterClosed) {
+		t.Errorf("Write() after Close error = %v, want ErrEncryptWriterClosed", err)
+	}
+}
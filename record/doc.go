@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package record implements always-on recording of audio streams into
+// rolling 16-bit PCM WAV files, optionally compressed and encrypted.
+//
+// A Recorder writes the samples it is given into a sequence of files,
+// starting a new one when the current file reaches a size or duration
+// limit. File names come from a template, and files can be gzip compressed
+// on the fly:
+//
+//	rec, err := record.NewRecorder(8000, 1, record.Options{
+//	    Dir:         "/var/spool/trunk1",
+//	    Template:    "trunk1-{time}-{seq}.wav",
+//	    MaxDuration: 15 * time.Minute,
+//	    Gzip:        true,
+//	})
+//
+// A Tap is an audio.Source that passes its input through unchanged while
+// copying it to an attached Recorder. Recorders can be attached and
+// detached at any time, from any goroutine, without disturbing the stream:
+//
+//	tap := record.NewTap(src)
+//	tap.Attach(rec)
+//	// ... read from tap as from src ...
+//	tap.Detach().Close()
+//
+// A failing Recorder never breaks the stream it taps: the Tap detaches it
+// and keeps the error for Err.
+//
+// # Voicemail
+//
+// RecordAfterBeep runs the usual "leave a message after the tone" flow:
+// it plays a beep, records into any Encoder (such as a wav.Writer) and
+// stops when the caller goes quiet, presses a terminating key, runs out of
+// time or hangs up:
+//
+//	res, err := record.RecordAfterBeep(leg, w, record.BeepOptions{
+//	    Play:        playToCaller,
+//	    MaxDuration: 3 * time.Minute,
+//	    Digits:      detector, // # ends the message
+//	})
+//	log.Printf("%v message, ended by %v", res.Duration, res.Reason)
+//
+// # Encryption
+//
+// Recordings can be encrypted at rest with AES-GCM, with no tool outside
+// the pipeline. Options.Encrypt encrypts the files of a Recorder; an
+// EncryptWriter does the same for any encoder, and a DecryptReader gives
+// the plaintext back to a decoder:
+//
+//	rec, err := record.NewRecorder(8000, 1, record.Options{
+//	    Encrypt: &record.Key{ID: "2024-06", Secret: secret},
+//	})
+//
+//	dr, err := record.NewDecryptReader(file, func(id string) ([]byte, error) {
+//	    return keyring.Secret(id)
+//	})
+//	src, err := wav.Decoder{}.Decode(dr)
+//
+// Files start with a header naming their key, in the clear, followed by
+// segments of up to SegmentSize bytes sealed one by one, so neither side
+// holds a whole recording in memory. Altered or truncated files fail with
+// ErrDecrypt.
+//
+// # Chain of Custody
+//
+// For recordings kept as evidence, Options.Manifest writes a Manifest next
+// to every file: its duration, start and end times and SHA-256 digest,
+// chained to the manifest before it and signed with Options.Sign:
+//
+//	rec, err := record.NewRecorder(8000, 1, record.Options{
+//	    Manifest: true,
+//	    Sign:     func(p []byte) ([]byte, error) { return ed25519.Sign(priv, p), nil },
+//	})
+//
+// Manifest.Verify later checks a file and its signature, failing with
+// ErrTampered when either was changed.
+//
+// # File Format
+//
+// When the file is an io.WriteSeeker (as *os.File is) and neither gzip nor
+// encryption is on, the RIFF and data sizes are patched when the file is
+// finished. Otherwise they are left at their maximum (0xFFFFFFFE), the
+// usual marker for WAV streams of unknown length, which decoders handle by
+// reading to the end of the data.
+package record
@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import "errors"
+
+var (
+	ErrRecorderClosed = errors.New("recorder is closed")
+	ErrPartialFrame   = errors.New("samples must be a multiple of channels")
+	ErrInvalidFormat  = errors.New("invalid recording format")
+
+	ErrInvalidKey          = errors.New("invalid encryption key")
+	ErrNotEncrypted        = errors.New("not an encrypted recording")
+	ErrDecrypt             = errors.New("encrypted recording failed authentication")
+	ErrEncryptWriterClosed = errors.New("encrypt writer is closed")
+
+	ErrTampered = errors.New("recording does not match its manifest")
+)
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// ManifestSuffix is added to the name of a file to name its manifest.
+const ManifestSuffix = ".manifest.json"
+
+// Manifest describes a finished recording file for chain of custody: what
+// it holds, when it was recorded and the SHA-256 digest of its bytes. The
+// manifests of a Recorder are chained, each holding the digest of the one
+// before, so a file removed from a sequence is noticed too. Signed
+// manifests also prove who wrote them.
+type Manifest struct {
+	// Name is the name of the file.
+	Name string `json:"name"`
+	// Seq is the number of the file in the sequence of the Recorder.
+	Seq int `json:"seq"`
+	// Start and End are the times the file was opened and finished.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Duration is the audio time the file holds.
+	Duration time.Duration `json:"duration"`
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex SHA-256 digest of the file.
+	SHA256 string `json:"sha256"`
+	// Prev is the hex SHA-256 digest of the payload of the previous
+	// manifest of the Recorder, empty for the first one.
+	Prev string `json:"prev,omitempty"`
+	// Signature is the signature of the payload, when the Recorder has a
+	// Sign function.
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Payload returns the bytes the manifest is signed and chained over: its
+// JSON encoding without the signature.
+func (m Manifest) Payload() []byte {
+	m.Signature = nil
+	// Cannot fail: the fields are all plain values
+	b, _ := json.Marshal(m)
+	return b
+}
+
+// Digest returns the hex SHA-256 digest of the payload, which the next
+// manifest of the sequence holds as Prev.
+func (m Manifest) Digest() string {
+	sum := sha256.Sum256(m.Payload())
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks the file r against the manifest, and its signature with
+// verify when verify is not nil: ed25519.Verify with the public key, for
+// manifests signed with ed25519.Sign. Mismatches fail with ErrTampered.
+func (m Manifest) Verify(r io.Reader, verify func(payload, sig []byte) bool) error {
+	if verify != nil && !verify(m.Payload(), m.Signature) {
+		return fmt.Errorf("%w: %s: bad signature", ErrTampered, m.Name)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	if n != m.Size || hex.EncodeToString(h.Sum(nil)) != m.SHA256 {
+		return fmt.Errorf("%w: %s: content does not match", ErrTampered, m.Name)
+	}
+	return nil
+}
+
+// hashWriter digests and counts what is written to a file. It also hides
+// the file's Seek, so the digest is that of the file as it ends up.
+type hashWriter struct {
+	w    io.Writer
+	h    hash.Hash
+	size int64
+}
+
+func newHashWriter(w io.Writer) *hashWriter {
+	return &hashWriter{w: w, h: sha256.New()}
+}
+
+func (hw *hashWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	hw.h.Write(p[:n])
+	hw.size += int64(n)
+	return n, err
+}
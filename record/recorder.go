@@ -0,0 +1,363 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/clock"
+	"github.com/ik5/audpbx/formats/wav"
+)
+
+const (
+	// DefaultTemplate names files after their start time and sequence number.
+	DefaultTemplate = "rec-{time}-{seq}.wav"
+
+	// TimeLayout is how {time} is rendered in file names.
+	TimeLayout = "20060102-150405"
+)
+
+// Options configures a Recorder. The zero value records into a single
+// uncompressed file in the current directory.
+type Options struct {
+	// Dir is the directory the files are created in.
+	Dir string
+
+	// Template is the file name template. It supports the placeholders
+	// {seq} (file number, starting at 1, zero padded to 4 digits), {time}
+	// (start time of the file in TimeLayout), {unix} (start time in Unix
+	// seconds), {rate} and {channels}. When Gzip is set and the name does
+	// not end in ".gz", the suffix is added.
+	Template string
+
+	// MaxBytes starts a new file before the current one would grow beyond
+	// this size, including the WAV header. 0 means no size limit.
+	MaxBytes int64
+
+	// MaxDuration starts a new file once the current one holds this much
+	// audio. It counts audio time, not wall clock time, so it is exact
+	// regardless of how fast the stream is read. 0 means no duration limit.
+	MaxDuration time.Duration
+
+	// Gzip compresses the files.
+	Gzip bool
+
+	// Encrypt, when set, encrypts the files with the key, after they are
+	// compressed, into the envelope EncryptWriter writes. When the name
+	// does not end in ".enc", the suffix is added.
+	Encrypt *Key
+
+	// Clock provides the start times used by the template. Defaults to
+	// clock.Real().
+	Clock clock.Clock
+
+	// Create opens a file for writing. Defaults to os.Create of the name
+	// joined with Dir. It allows recording to other storage.
+	Create func(name string) (io.WriteCloser, error)
+
+	// OnRotate is called with the name of every file once it is complete,
+	// for example to hand it to an uploader.
+	OnRotate func(name string)
+
+	// Manifest writes a Manifest of every file once it is complete, to a
+	// file named after it with ManifestSuffix added, before OnRotate is
+	// called. The sizes in the WAV header are then never patched, so the
+	// digest is that of the file as it was streamed.
+	Manifest bool
+
+	// Sign, when set with Manifest, signs the payload of every manifest,
+	// for example with ed25519.Sign and the private key of the recorder.
+	Sign func(payload []byte) ([]byte, error)
+}
+
+// Recorder writes interleaved float32 samples to rolling 16-bit PCM WAV
+// files. It is safe for concurrent use.
+type Recorder struct {
+	mu       *sync.Mutex
+	rate     int
+	channels int
+	opts     Options
+
+	maxFrames int64 // frames per file, 0 for unlimited
+	seq       int
+	closed    bool
+
+	// current file
+	name   string
+	file   io.WriteCloser
+	gz     *gzip.Writer
+	crypt  *EncryptWriter
+	hash   *hashWriter
+	enc    *wav.Writer
+	frames int64
+	start  time.Time
+	prev   string // digest of the last manifest
+}
+
+// NewRecorder creates a Recorder for audio at rate with the given number of
+// channels. No file is created until the first samples are written.
+func NewRecorder(rate, channels int, opts Options) (*Recorder, error) {
+	if rate <= 0 || channels <= 0 {
+		return nil, fmt.Errorf("%w: %d Hz, %d channels", ErrInvalidFormat, rate, channels)
+	}
+
+	if opts.Template == "" {
+		opts.Template = DefaultTemplate
+	}
+	if opts.Gzip && !strings.HasSuffix(opts.Template, ".gz") {
+		opts.Template += ".gz"
+	}
+	if opts.Encrypt != nil {
+		if _, err := opts.Encrypt.aead(); err != nil {
+			return nil, err
+		}
+		if !strings.HasSuffix(opts.Template, ".enc") {
+			opts.Template += ".enc"
+		}
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real()
+	}
+	if opts.Create == nil {
+		dir := opts.Dir
+		opts.Create = func(name string) (io.WriteCloser, error) {
+			return os.Create(filepath.Join(dir, name))
+		}
+	}
+
+	frameBytes := int64(2 * channels)
+	var maxFrames int64
+	if opts.MaxBytes > 0 {
+		maxFrames = max((opts.MaxBytes-wav.HeaderSize)/frameBytes, 1)
+	}
+	if opts.MaxDuration > 0 {
+		byDuration := max(int64(rate)*int64(opts.MaxDuration)/int64(time.Second), 1)
+		if maxFrames == 0 || byDuration < maxFrames {
+			maxFrames = byDuration
+		}
+	}
+
+	return &Recorder{
+		mu:        &sync.Mutex{},
+		rate:      rate,
+		channels:  channels,
+		opts:      opts,
+		maxFrames: maxFrames,
+	}, nil
+}
+
+func (r *Recorder) SampleRate() int { return r.rate }
+func (r *Recorder) Channels() int   { return r.channels }
+
+// Write records interleaved samples. len(samples) must be a multiple of
+// Channels().
+func (r *Recorder) Write(samples []float32) error {
+	if len(samples)%r.channels != 0 {
+		return ErrPartialFrame
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrRecorderClosed
+	}
+
+	for len(samples) > 0 {
+		if r.file == nil {
+			if err := r.open(); err != nil {
+				return err
+			}
+		}
+
+		frames := int64(len(samples) / r.channels)
+		if r.maxFrames > 0 {
+			room := r.maxFrames - r.frames
+			if room == 0 {
+				if err := r.finish(); err != nil {
+					return err
+				}
+				continue
+			}
+			frames = min(frames, room)
+		}
+
+		n := int(frames) * r.channels
+		if err := r.enc.WriteSamples(samples[:n]); err != nil {
+			return fmt.Errorf("writing %s: %w", r.name, err)
+		}
+		r.frames += frames
+		samples = samples[n:]
+	}
+
+	return nil
+}
+
+// Rotate finishes the current file, if any. The next Write starts a new one.
+func (r *Recorder) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	return r.finish()
+}
+
+// Close finishes the current file. Further writes fail with
+// ErrRecorderClosed.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	if r.file == nil {
+		return nil
+	}
+	return r.finish()
+}
+
+// open starts the next file and writes its header
+func (r *Recorder) open() error {
+	r.seq++
+	r.start = r.opts.Clock.Now()
+	r.name = r.fileName(r.start)
+
+	file, err := r.opts.Create(r.name)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", r.name, err)
+	}
+
+	// Sizes are patched by the wav.Writer when the file can seek, gzipped
+	// and encrypted files keep the unknown size markers
+	var w io.Writer = file
+	r.gz, r.crypt, r.hash = nil, nil, nil
+	if r.opts.Manifest {
+		r.hash = newHashWriter(file)
+		w = r.hash
+	}
+	if r.opts.Encrypt != nil {
+		if r.crypt, err = NewEncryptWriter(w, *r.opts.Encrypt); err != nil {
+			_ = file.Close()
+			return fmt.Errorf("writing %s: %w", r.name, err)
+		}
+		w = r.crypt
+	}
+	if r.opts.Gzip {
+		r.gz = gzip.NewWriter(w)
+		w = r.gz
+	}
+
+	enc, err := wav.NewWriter(w, audio.Format{Rate: r.rate, Channels: r.channels})
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("writing %s: %w", r.name, err)
+	}
+
+	r.file, r.enc = file, enc
+	r.frames = 0
+
+	return nil
+}
+
+// finish completes and closes the current file
+func (r *Recorder) finish() error {
+	file, name := r.file, r.name
+	r.file = nil
+
+	err := r.enc.Close()
+	if r.gz != nil {
+		if gerr := r.gz.Close(); err == nil {
+			err = gerr
+		}
+		r.gz = nil
+	}
+	if r.crypt != nil {
+		if cerr := r.crypt.Close(); err == nil {
+			err = cerr
+		}
+		r.crypt = nil
+	}
+
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && r.hash != nil {
+		err = r.writeManifest()
+	}
+	if err != nil {
+		return fmt.Errorf("finishing %s: %w", name, err)
+	}
+
+	if r.opts.OnRotate != nil {
+		r.opts.OnRotate(name)
+	}
+
+	return nil
+}
+
+// writeManifest writes the manifest of the file just finished
+func (r *Recorder) writeManifest() error {
+	m := Manifest{
+		Name:     r.name,
+		Seq:      r.seq,
+		Start:    r.start,
+		End:      r.opts.Clock.Now(),
+		Duration: audio.FramesDuration(r.frames, r.rate),
+		Size:     r.hash.size,
+		SHA256:   hex.EncodeToString(r.hash.h.Sum(nil)),
+		Prev:     r.prev,
+	}
+	if r.opts.Sign != nil {
+		sig, err := r.opts.Sign(m.Payload())
+		if err != nil {
+			return fmt.Errorf("signing the manifest: %w", err)
+		}
+		m.Signature = sig
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	file, err := r.opts.Create(r.name + ManifestSuffix)
+	if err != nil {
+		return fmt.Errorf("creating the manifest: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("writing the manifest: %w", err)
+	}
+
+	r.prev = m.Digest()
+	return nil
+}
+
+// fileName expands the template for a file started at t
+func (r *Recorder) fileName(t time.Time) string {
+	return strings.NewReplacer(
+		"{seq}", fmt.Sprintf("%04d", r.seq),
+		"{time}", t.Format(TimeLayout),
+		"{unix}", strconv.FormatInt(t.Unix(), 10),
+		"{rate}", strconv.Itoa(r.rate),
+		"{channels}", strconv.Itoa(r.channels),
+	).Replace(r.opts.Template)
+}
@@ -0,0 +1,405 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/clock"
+	"github.com/ik5/audpbx/formats/wav"
+)
+
+// memFS collects the files created by a Recorder
+type memFS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+	order []string
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: make(map[string]*memFile)}
+}
+
+func (fs *memFS) create(name string) (io.WriteCloser, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f := &memFile{}
+	fs.files[name] = f
+	fs.order = append(fs.order, name)
+	return f, nil
+}
+
+// memFile is a non-seekable in-memory file
+type memFile struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (f *memFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+func decodeFrames(t *testing.T, data []byte) (rate, channels, frames int) {
+	t.Helper()
+
+	src, err := wav.Decoder{}.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	buf := make([]float32, 4096)
+	total := 0
+	for {
+		n, err := src.ReadSamples(buf)
+		total += n
+		if err != nil {
+			break
+		}
+	}
+	return src.SampleRate(), src.Channels(), total / src.Channels()
+}
+
+func TestRecorder_Rotation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		opts       Options
+		channels   int
+		frames     int
+		wantFrames []int
+	}{
+		{
+			name:       "no limit",
+			channels:   1,
+			frames:     2500,
+			wantFrames: []int{2500},
+		},
+		{
+			name:       "duration",
+			opts:       Options{MaxDuration: 100 * time.Millisecond},
+			channels:   1,
+			frames:     2500,
+			wantFrames: []int{800, 800, 800, 100},
+		},
+		{
+			name:       "size",
+			opts:       Options{MaxBytes: wav.HeaderSize + 4000},
+			channels:   2,
+			frames:     2500,
+			wantFrames: []int{1000, 1000, 500},
+		},
+		{
+			name:       "smallest limit wins",
+			opts:       Options{MaxBytes: wav.HeaderSize + 4000, MaxDuration: 50 * time.Millisecond},
+			channels:   1,
+			frames:     1000,
+			wantFrames: []int{400, 400, 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := newMemFS()
+			opts := tt.opts
+			opts.Create = fs.create
+			var rotated []string
+			opts.OnRotate = func(name string) { rotated = append(rotated, name) }
+
+			rec, err := NewRecorder(8000, tt.channels, opts)
+			if err != nil {
+				t.Fatalf("NewRecorder() error = %v", err)
+			}
+
+			// Odd sized writes so rotation falls in the middle of a write
+			samples := make([]float32, tt.frames*tt.channels)
+			for i := 0; i < len(samples); i += 333 * tt.channels {
+				end := min(i+333*tt.channels, len(samples))
+				if err := rec.Write(samples[i:end]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := rec.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if len(fs.order) != len(tt.wantFrames) {
+				t.Fatalf("created %d files, want %d", len(fs.order), len(tt.wantFrames))
+			}
+			if len(rotated) != len(fs.order) {
+				t.Errorf("OnRotate called %d times, want %d", len(rotated), len(fs.order))
+			}
+
+			for i, name := range fs.order {
+				f := fs.files[name]
+				if !f.closed {
+					t.Errorf("%s was not closed", name)
+				}
+				if tt.opts.MaxBytes > 0 && int64(f.Len()) > tt.opts.MaxBytes {
+					t.Errorf("%s is %d bytes, limit %d", name, f.Len(), tt.opts.MaxBytes)
+				}
+
+				rate, channels, frames := decodeFrames(t, f.Bytes())
+				if rate != 8000 || channels != tt.channels || frames != tt.wantFrames[i] {
+					t.Errorf("%s: %d Hz %dch %d frames, want 8000 Hz %dch %d frames",
+						name, rate, channels, frames, tt.channels, tt.wantFrames[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecorder_Template(t *testing.T) {
+	t.Parallel()
+
+	fs := newMemFS()
+	fc := clock.NewFake(time.Date(2024, 3, 1, 12, 30, 5, 0, time.UTC))
+	rec, err := NewRecorder(16000, 2, Options{
+		Template: "{rate}-{channels}-{time}-{unix}-{seq}.wav",
+		Gzip:     true,
+		Clock:    fc,
+		Create:   fs.create,
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+
+	for range 2 {
+		if err := rec.Write(make([]float32, 32)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := rec.Rotate(); err != nil {
+			t.Fatalf("Rotate() error = %v", err)
+		}
+		fc.Advance(time.Minute)
+	}
+
+	want := []string{
+		"16000-2-20240301-123005-1709296205-0001.wav.gz",
+		"16000-2-20240301-123105-1709296265-0002.wav.gz",
+	}
+	for i, name := range want {
+		if i >= len(fs.order) || fs.order[i] != name {
+			t.Fatalf("files = %v, want %v", fs.order, want)
+		}
+	}
+}
+
+func TestRecorder_Gzip(t *testing.T) {
+	t.Parallel()
+
+	fs := newMemFS()
+	rec, err := NewRecorder(8000, 1, Options{Gzip: true, Create: fs.create})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if err := rec.Write(make([]float32, 1234)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	zr, err := gzip.NewReader(&fs.files[fs.order[0]].Buffer)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading gzip: %v", err)
+	}
+
+	if got := binary.LittleEndian.Uint32(data[40:44]); got != wav.UnknownSize {
+		t.Errorf("data size = %#x, want unknown size marker", got)
+	}
+	if _, _, frames := decodeFrames(t, data); frames != 1234 {
+		t.Errorf("decoded %d frames, want 1234", frames)
+	}
+}
+
+func TestRecorder_PatchesSeekableFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rec, err := NewRecorder(8000, 1, Options{Dir: dir, Template: "call.wav"})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if err := rec.Write(make([]float32, 800)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "call.wav"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if got := binary.LittleEndian.Uint32(data[4:8]); got != 36+1600 {
+		t.Errorf("RIFF size = %d, want %d", got, 36+1600)
+	}
+	if got := binary.LittleEndian.Uint32(data[40:44]); got != 1600 {
+		t.Errorf("data size = %d, want 1600", got)
+	}
+}
+
+func TestRecorder_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewRecorder(0, 1, Options{}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("NewRecorder(0 Hz) error = %v, want %v", err, ErrInvalidFormat)
+	}
+
+	fs := newMemFS()
+	rec, err := NewRecorder(8000, 2, Options{Create: fs.create})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if err := rec.Write(make([]float32, 3)); !errors.Is(err, ErrPartialFrame) {
+		t.Errorf("Write(3 samples) error = %v, want %v", err, ErrPartialFrame)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(fs.order) != 0 {
+		t.Errorf("created %d files without any samples", len(fs.order))
+	}
+	if err := rec.Write(make([]float32, 2)); !errors.Is(err, ErrRecorderClosed) {
+		t.Errorf("Write after Close error = %v, want %v", err, ErrRecorderClosed)
+	}
+}
+
+func TestRecorder_Encrypt(t *testing.T) {
+	t.Parallel()
+
+	fs := newMemFS()
+	key := testKey
+	rec, err := NewRecorder(8000, 1, Options{Gzip: true, Encrypt: &key, Create: fs.create})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if err := rec.Write(make([]float32, 1234)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if name := fs.order[0]; filepath.Ext(name) != ".enc" {
+		t.Errorf("file name = %s, want a .enc suffix", name)
+	}
+	dr, err := NewDecryptReader(&fs.files[fs.order[0]].Buffer, testKeys)
+	if err != nil {
+		t.Fatalf("NewDecryptReader() error = %v", err)
+	}
+	zr, err := gzip.NewReader(dr)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if _, _, frames := decodeFrames(t, data); frames != 1234 {
+		t.Errorf("decoded %d frames, want 1234", frames)
+	}
+
+	if _, err := NewRecorder(8000, 1, Options{Encrypt: &Key{Secret: []byte("short")}}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("NewRecorder() with a bad key error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestRecorder_Manifest(t *testing.T) {
+	t.Parallel()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := newMemFS()
+	fc := clock.NewFake(time.Date(2024, 3, 1, 12, 30, 5, 0, time.UTC))
+	var rotated []string
+	rec, err := NewRecorder(8000, 1, Options{
+		Template:    "call-{seq}.wav",
+		MaxDuration: time.Second,
+		Clock:       fc,
+		Create:      fs.create,
+		OnRotate:    func(name string) { rotated = append(rotated, name) },
+		Manifest:    true,
+		Sign:        func(payload []byte) ([]byte, error) { return ed25519.Sign(priv, payload), nil },
+	})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	for range 3 {
+		if err := rec.Write(make([]float32, 6000)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		fc.Advance(750 * time.Millisecond)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if len(rotated) != 3 {
+		t.Fatalf("rotated %v, want 3 files", rotated)
+	}
+	verify := func(payload, sig []byte) bool { return ed25519.Verify(pub, payload, sig) }
+
+	prev := ""
+	for i, name := range rotated {
+		mf, ok := fs.files[name+ManifestSuffix]
+		if !ok {
+			t.Fatalf("no manifest for %s", name)
+		}
+		var m Manifest
+		if err := json.Unmarshal(mf.Bytes(), &m); err != nil {
+			t.Fatalf("reading the manifest of %s: %v", name, err)
+		}
+		if m.Name != name || m.Seq != i+1 || m.Prev != prev {
+			t.Errorf("manifest %d = %+v, want %s chained to %q", i, m, name, prev)
+		}
+		if wantDur := []time.Duration{time.Second, time.Second, 250 * time.Millisecond}[i]; m.Duration != wantDur {
+			t.Errorf("%s Duration = %v, want %v", name, m.Duration, wantDur)
+		}
+		if !m.End.After(m.Start) && i < 2 {
+			t.Errorf("%s recorded from %v to %v", name, m.Start, m.End)
+		}
+		prev = m.Digest()
+
+		data := fs.files[name].Bytes()
+		if err := m.Verify(bytes.NewReader(data), verify); err != nil {
+			t.Errorf("Verify(%s) error = %v", name, err)
+		}
+		if _, _, frames := decodeFrames(t, data); time.Duration(frames)*time.Second/8000 != m.Duration {
+			t.Errorf("%s decodes to %d frames, manifest says %v", name, frames, m.Duration)
+		}
+
+		altered := bytes.Clone(data)
+		altered[len(altered)-1] ^= 1
+		if err := m.Verify(bytes.NewReader(altered), verify); !errors.Is(err, ErrTampered) {
+			t.Errorf("Verify(altered %s) error = %v, want ErrTampered", name, err)
+		}
+		forged := m
+		forged.Duration /= 2
+		if err := forged.Verify(bytes.NewReader(data), verify); !errors.Is(err, ErrTampered) {
+			t.Errorf("Verify(forged manifest of %s) error = %v, want ErrTampered", name, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Tap is an audio.Source that passes src through unchanged and copies
+// everything read from it to the attached Recorder, if any.
+type Tap struct {
+	src audio.Source
+
+	mu  *sync.Mutex
+	rec *Recorder
+	err error
+}
+
+// NewTap wraps src. Nothing is recorded until a Recorder is attached.
+func NewTap(src audio.Source) *Tap {
+	return &Tap{src: src, mu: &sync.Mutex{}}
+}
+
+func (t *Tap) SampleRate() int      { return t.src.SampleRate() }
+func (t *Tap) Channels() int        { return t.src.Channels() }
+func (t *Tap) BufSize() int         { return t.src.BufSize() }
+func (t *Tap) Format() audio.Format { return audio.FormatOf(t.src) }
+
+// Attach starts copying the stream to rec, replacing and returning the
+// previously attached Recorder (nil if none). rec must match the rate and
+// channel count of the stream.
+func (t *Tap) Attach(rec *Recorder) (*Recorder, error) {
+	want := audio.Format{Rate: t.src.SampleRate(), Channels: t.src.Channels()}
+	got := audio.Format{Rate: rec.SampleRate(), Channels: rec.Channels()}
+	if err := want.Compatible(got); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev := t.rec
+	t.rec = rec
+	return prev, nil
+}
+
+// Detach stops recording and returns the Recorder that was attached, or nil.
+// The Recorder is not closed, so it can be attached again later.
+func (t *Tap) Detach() *Recorder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rec := t.rec
+	t.rec = nil
+	return rec
+}
+
+// Err returns the error that made the Tap detach a failing Recorder, if any.
+func (t *Tap) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+func (t *Tap) ReadSamples(dst []float32) (int, error) {
+	n, err := t.src.ReadSamples(dst)
+
+	if n > 0 {
+		t.mu.Lock()
+		if t.rec != nil {
+			if werr := t.rec.Write(dst[:n]); werr != nil {
+				t.err = werr
+				t.rec = nil
+			}
+		}
+		t.mu.Unlock()
+	}
+
+	return n, err
+}
+
+// Close closes the wrapped source. An attached Recorder is left open; close
+// it after Detach.
+func (t *Tap) Close() error {
+	err := t.src.Close()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
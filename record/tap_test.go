@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package record
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestTap_AttachDetach(t *testing.T) {
+	t.Parallel()
+
+	fs := newMemFS()
+	rec, err := NewRecorder(8000, 1, Options{Template: "{seq}.wav", Create: fs.create})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+
+	src := audiotest.NewSineSource(8000, 1, 4000, 440)
+	tap := NewTap(src)
+	buf := make([]float32, 1000)
+
+	read := func() {
+		t.Helper()
+		if _, err := tap.ReadSamples(buf); err != nil && err != io.EOF {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+
+	read() // not recorded
+	if _, err := tap.Attach(rec); err != nil {
+		t.Fatalf("Attach() error = %v", err)
+	}
+	read()
+	read()
+	if got := tap.Detach(); got != rec {
+		t.Errorf("Detach() = %p, want %p", got, rec)
+	}
+	read() // not recorded
+
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, _, frames := decodeFrames(t, fs.files["0001.wav"].Bytes()); frames != 2000 {
+		t.Errorf("recorded %d frames, want 2000", frames)
+	}
+}
+
+func TestTap_FormatMismatch(t *testing.T) {
+	t.Parallel()
+
+	rec, err := NewRecorder(16000, 1, Options{Create: newMemFS().create})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+
+	tap := NewTap(audiotest.NewSilentSource(8000, 1, 100))
+	if _, err := tap.Attach(rec); !errors.Is(err, audio.ErrFormatMismatch) {
+		t.Errorf("Attach() error = %v, want %v", err, audio.ErrFormatMismatch)
+	}
+}
+
+func TestTap_DetachesFailingRecorder(t *testing.T) {
+	t.Parallel()
+
+	rec, err := NewRecorder(8000, 1, Options{Create: newMemFS().create})
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	tap := NewTap(audiotest.NewConstantSource(8000, 1, 100, 0.25))
+	if _, err := tap.Attach(rec); err != nil {
+		t.Fatalf("Attach() error = %v", err)
+	}
+
+	buf := make([]float32, 100)
+	n, err := tap.ReadSamples(buf)
+	if n != 100 || (err != nil && err != io.EOF) || buf[0] != 0.25 {
+		t.Fatalf("ReadSamples() = %d, %v; the stream must not be disturbed", n, err)
+	}
+	if !errors.Is(tap.Err(), ErrRecorderClosed) {
+		t.Errorf("Err() = %v, want %v", tap.Err(), ErrRecorderClosed)
+	}
+	if tap.Detach() != nil {
+		t.Error("failing recorder is still attached")
+	}
+}
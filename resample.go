@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/utils"
 )
 
 // ResampleToMono16 is a high-level convenience function that resamples audio to a target
@@ -31,6 +32,8 @@ import (
 //
 // Note: This is a convenience function for common use cases. For more control over
 // the audio processing pipeline, use NewResampler() and NewMonoMixer() directly.
+// Samples beyond ±1.0 are hard clipped; level hot sources first, e.g. with
+// dsp.NewLimiter.
 //
 // Example:
 //
@@ -41,8 +44,16 @@ import (
 //	}
 //	// pcm16 now contains mono 16-bit PCM at 8kHz
 func ResampleToMono16(src audio.Source, targetRate int, bufferSize int) ([]int16, int, error) {
+	// Reject unusable formats before building the pipeline
+	if err := audio.FormatOf(src).Validate(); err != nil {
+		return nil, targetRate, fmt.Errorf("source: %w", err)
+	}
+	if targetRate <= 0 {
+		return nil, targetRate, fmt.Errorf("target: %w: %d Hz", audio.ErrInvalidSampleRate, targetRate)
+	}
+
 	// Create the processing pipeline: resample -> mono
-	resampler := audio.NewResampler(src, targetRate)
+	resampler := audio.Resample(src, targetRate)
 	mono := audio.NewMonoMixer(resampler)
 
 	// Pre-allocate based on estimated output size to reduce allocations
@@ -64,19 +75,11 @@ func ResampleToMono16(src audio.Source, targetRate int, bufferSize int) ([]int16
 				pcm16 = newSlice
 			}
 
-			// Batch convert float32 to int16 (inlined for performance)
+			// Batch convert float32 to int16
 			startIdx := len(pcm16)
 			pcm16 = pcm16[:startIdx+n]
-			const maxInt16 float32 = 32768.0
-			for i := range n {
-				x := buf[i]
-				// Clamp to [-1, 1]
-				if x > 1 {
-					x = 1
-				} else if x < -1 {
-					x = -1
-				}
-				pcm16[startIdx+i] = int16(x * maxInt16)
+			for i, x := range buf[:n] {
+				pcm16[startIdx+i] = utils.Float32ToInt16(x)
 			}
 		}
 
@@ -3,11 +3,13 @@
 package audpbx
 
 import (
+	"errors"
 	"io"
 	"math"
 	"testing"
 
-	"github.com/ik5/audpbx/internal/audiotest"
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
 )
 
 func TestResampleToMono16_Basic(t *testing.T) {
@@ -213,6 +215,39 @@ func TestResampleToMono16_Clamping(t *testing.T) {
 	}
 }
 
+func TestResampleToMono16_FullScale(t *testing.T) {
+	t.Parallel()
+
+	// 1.0 scales to 32768, one past the int16 range
+	src := audiotest.NewMockSource(8000, 1, 100, func(sample int, channel int) float32 {
+		return 1.0
+	})
+
+	pcm16, _, err := ResampleToMono16(src, 8000, 4096)
+	if err != nil {
+		t.Fatalf("ResampleToMono16() error = %v", err)
+	}
+	for i, s := range pcm16 {
+		if s < 32000 {
+			t.Fatalf("pcm16[%d] = %d, want full scale", i, s)
+		}
+	}
+}
+
+func TestResampleToMono16_InvalidFormat(t *testing.T) {
+	t.Parallel()
+
+	src := audiotest.NewSilentSource(0, 1, 100)
+	if _, _, err := ResampleToMono16(src, 8000, 4096); !errors.Is(err, audio.ErrInvalidSampleRate) {
+		t.Errorf("ResampleToMono16() with 0 Hz source error = %v, want ErrInvalidSampleRate", err)
+	}
+
+	src = audiotest.NewSilentSource(8000, 1, 100)
+	if _, _, err := ResampleToMono16(src, 0, 4096); !errors.Is(err, audio.ErrInvalidSampleRate) {
+		t.Errorf("ResampleToMono16() with 0 Hz target error = %v, want ErrInvalidSampleRate", err)
+	}
+}
+
 // BenchmarkResampleToMono16 benchmarks the complete pipeline
 func BenchmarkResampleToMono16(b *testing.B) {
 	// 1 second of stereo 44.1kHz audio
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/g711"
+	"github.com/ik5/audpbx/utils"
+)
+
+// Codec is an RTP audio payload format.
+type Codec int
+
+const (
+	CodecUnknown Codec = iota
+	PCMU               // G.711 µ-law, RFC 3551
+	PCMA               // G.711 A-law, RFC 3551
+	L16                // 16-bit big-endian linear PCM, RFC 3551
+)
+
+// DynamicPayloadType is the payload type L16 is sent with at rates that
+// have no static one, the first of the dynamic range. The SDP of the call
+// maps it to the codec.
+const DynamicPayloadType = 96
+
+// String returns the encoding name of the codec in SDP.
+func (c Codec) String() string {
+	switch c {
+	case PCMU:
+		return "PCMU"
+	case PCMA:
+		return "PCMA"
+	case L16:
+		return "L16"
+	default:
+		return "unknown"
+	}
+}
+
+// PayloadType returns the static payload type of the codec for format:
+// 0 for PCMU, 8 for PCMA and, at 44.1 kHz, 11 for mono L16 and 10 for
+// stereo. Others get DynamicPayloadType.
+func (c Codec) PayloadType(format audio.Format) uint8 {
+	switch {
+	case c == PCMU:
+		return 0
+	case c == PCMA:
+		return 8
+	case format.Rate == 44100 && format.Channels == 1:
+		return 11
+	case format.Rate == 44100 && format.Channels == 2:
+		return 10
+	default:
+		return DynamicPayloadType
+	}
+}
+
+// check returns an error when the codec cannot carry format: G.711 is
+// sent at 8 kHz.
+func (c Codec) check(format audio.Format) error {
+	if err := format.Validate(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	switch c {
+	case PCMU, PCMA:
+		if format.Rate != 8000 {
+			return fmt.Errorf("%w: %s at %d Hz, want 8000 Hz", audio.ErrFormatMismatch, c, format.Rate)
+		}
+	case L16:
+	default:
+		return fmt.Errorf("%w: %d", ErrUnknownCodec, c)
+	}
+	return nil
+}
+
+// sampleSize returns the bytes of a sample in a payload
+func (c Codec) sampleSize() int {
+	if c == L16 {
+		return 2
+	}
+	return 1
+}
+
+// encode appends samples to a payload
+func (c Codec) encode(b []byte, samples []float32) []byte {
+	for _, v := range samples {
+		s := utils.Float32ToInt16(v)
+		switch c {
+		case PCMU:
+			b = append(b, g711.EncodeMulaw(s))
+		case PCMA:
+			b = append(b, g711.EncodeAlaw(s))
+		default:
+			b = binary.BigEndian.AppendUint16(b, uint16(s))
+		}
+	}
+	return b
+}
+
+// decode appends the samples of a payload to dst, dropping a trailing
+// partial sample
+func (c Codec) decode(dst []float32, payload []byte) []float32 {
+	switch c {
+	case PCMU:
+		for _, b := range payload {
+			dst = append(dst, float32(g711.DecodeMulaw(b))/32768)
+		}
+	case PCMA:
+		for _, b := range payload {
+			dst = append(dst, float32(g711.DecodeAlaw(b))/32768)
+		}
+	default:
+		for i := 0; i+2 <= len(payload); i += 2 {
+			dst = append(dst, float32(int16(binary.BigEndian.Uint16(payload[i:])))/32768)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package rtp
+
+import (
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// maxGap bounds the loss or silence, in seconds, a jump of the timestamps
+// is taken for: a longer jump restarts the stream, as some PBXs do on a
+// transfer without changing the SSRC.
+const maxGap = 10
+
+// Depacketizer is an audio.Source of the RTP packets of a call, given to
+// Push as they are received, typically from the goroutine reading the
+// socket.
+//
+// The audio plays in timestamp order: packets arriving out of order are
+// put back in order if the reader has not gone past them yet, and are
+// dropped otherwise, as are duplicates. The audio of lost packets, and of
+// gaps from silence suppression, plays as silence. Packets of another
+// payload type, such as RFC 4733 telephone events, are ignored, and a new
+// SSRC restarts the stream.
+//
+// ReadSamples blocks until there is audio, and returns what there is
+// rather than waiting for dst to be full. It is not a jitter buffer:
+// the reader is expected to fall behind the packets, as a recorder does,
+// or to be paced by a buffer of its own.
+type Depacketizer struct {
+	format audio.Format
+	codec  Codec
+	pt     uint8
+	ch     int
+
+	mu      *sync.Mutex
+	cond    *sync.Cond
+	pending []Packet // in timestamp order
+	ssrc    uint32
+	synced  bool   // next is set, from the first packet of ssrc
+	next    uint32 // timestamp of the next frame to play
+	gap     int64  // frames of silence left to play
+	samples []float32
+	pos     int // of the next sample of samples to play
+	closed  bool
+}
+
+// NewDepacketizer creates a Depacketizer of audio in format, whose rate
+// is the clock rate of the stream: 8 kHz for G.711.
+func NewDepacketizer(format audio.Format, opts Options) (*Depacketizer, error) {
+	if err := opts.Codec.check(format); err != nil {
+		return nil, err
+	}
+
+	mu := &sync.Mutex{}
+	return &Depacketizer{
+		format: format,
+		codec:  opts.Codec,
+		pt:     opts.payloadType(format),
+		ch:     format.Channels,
+		mu:     mu,
+		cond:   sync.NewCond(mu),
+	}, nil
+}
+
+func (d *Depacketizer) SampleRate() int      { return d.format.Rate }
+func (d *Depacketizer) Channels() int        { return d.format.Channels }
+func (d *Depacketizer) BufSize() int         { return 4096 }
+func (d *Depacketizer) Format() audio.Format { return d.format }
+
+// Push queues a received packet. Its payload is copied. It fails with
+// ErrDepacketizerClosed after Close.
+func (d *Depacketizer) Push(p Packet) error {
+	if p.PayloadType != d.pt {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return ErrDepacketizerClosed
+	}
+	if d.synced && p.SSRC != d.ssrc {
+		// A new stream, whose timestamps have nothing to do with the
+		// previous ones: what is left of that one is dropped
+		d.synced = false
+		d.pending = d.pending[:0]
+		d.gap = 0
+	}
+	d.ssrc = p.SSRC
+	if d.synced && int32(p.Timestamp-d.next) < 0 {
+		return nil // too late
+	}
+
+	i, found := slices.BinarySearchFunc(d.pending, p.Timestamp, func(q Packet, ts uint32) int {
+		return int(int32(q.Timestamp - ts))
+	})
+	if found {
+		return nil
+	}
+	p.Payload = slices.Clone(p.Payload)
+	d.pending = slices.Insert(d.pending, i, p)
+	d.cond.Broadcast()
+	return nil
+}
+
+// Close ends the stream: once what is queued has been read, ReadSamples
+// returns io.EOF. It is safe to call from any goroutine.
+func (d *Depacketizer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	d.cond.Broadcast()
+	return nil
+}
+
+// ReadSamples waits for audio and reads what there is of it into dst, in
+// whole frames.
+func (d *Depacketizer) ReadSamples(dst []float32) (int, error) {
+	if len(dst)%d.ch != 0 {
+		return 0, audio.ErrInvalidDstSize
+	}
+	if len(dst) == 0 {
+		return 0, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for {
+		switch {
+		case d.pos < len(d.samples):
+			n := copy(dst, d.samples[d.pos:])
+			d.pos += n
+			return n, nil
+		case d.gap > 0:
+			n := int(min(int64(len(dst)), d.gap*int64(d.ch)))
+			clear(dst[:n])
+			d.gap -= int64(n / d.ch)
+			return n, nil
+		case len(d.pending) > 0:
+			d.nextPacket()
+		case d.closed:
+			return 0, io.EOF
+		default:
+			d.cond.Wait()
+		}
+	}
+}
+
+// nextPacket decodes the first queued packet into samples, or moves the
+// gap before it to gap
+func (d *Depacketizer) nextPacket() {
+	p := d.pending[0]
+	if !d.synced {
+		d.next, d.synced = p.Timestamp, true
+	}
+
+	switch ahead := int32(p.Timestamp - d.next); {
+	case ahead < 0:
+		// Already played, as by a packet that overlaps it
+		d.pending = d.pending[1:]
+		return
+	case ahead > 0 && int64(ahead) <= maxGap*int64(d.format.Rate):
+		d.gap = int64(ahead)
+		d.next = p.Timestamp
+		return
+	}
+
+	d.pending = d.pending[1:]
+	d.samples = d.codec.decode(d.samples[:0], p.Payload)
+	d.samples = d.samples[:len(d.samples)-len(d.samples)%d.ch]
+	d.pos = 0
+	d.next = p.Timestamp + uint32(len(d.samples)/d.ch)
+}
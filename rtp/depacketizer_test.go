@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// readAll reads src to its end, chunk samples at a time
+func readAll(t *testing.T, src audio.Source, chunk int) []float32 {
+	t.Helper()
+
+	var out []float32
+	buf := make([]float32, chunk)
+	for {
+		n, err := src.ReadSamples(buf)
+		out = append(out, buf[:n]...)
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+// l16 returns an L16 packet of frames mono frames, each holding the low
+// bits of its timestamp as its sample
+func l16(ts uint32, frames int) Packet {
+	var payload []byte
+	for i := range frames {
+		payload = binary.BigEndian.AppendUint16(payload, uint16(ts)+uint16(i))
+	}
+	return Packet{PayloadType: DynamicPayloadType, Timestamp: ts, SSRC: 1, Payload: payload}
+}
+
+// ramp returns the samples of frames of l16 packets from ts on
+func ramp(ts uint32, frames int) []float32 {
+	out := make([]float32, frames)
+	for i := range out {
+		out[i] = float32(int16(uint16(ts)+uint16(i))) / 32768
+	}
+	return out
+}
+
+func TestDepacketizer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		packets []Packet
+		want    []float32
+	}{
+		{name: "in order", packets: []Packet{l16(100, 4), l16(104, 4), l16(108, 4)}, want: ramp(100, 12)},
+		{name: "reordered", packets: []Packet{l16(100, 4), l16(108, 4), l16(104, 4)}, want: ramp(100, 12)},
+		{name: "duplicate", packets: []Packet{l16(100, 4), l16(104, 4), l16(104, 4)}, want: ramp(100, 8)},
+		{name: "lost", packets: []Packet{l16(100, 4), l16(108, 4)}, want: slices.Concat(ramp(100, 4), make([]float32, 4), ramp(108, 4))},
+		{name: "wrapping timestamps", packets: []Packet{l16(0xFFFFFFFE, 2), l16(0, 2)}, want: slices.Concat(ramp(0xFFFFFFFE, 2), ramp(0, 2))},
+		{name: "telephone event", packets: []Packet{l16(100, 4), {PayloadType: 101, Timestamp: 104, SSRC: 1, Payload: []byte{1, 0, 0, 160}}, l16(104, 4)}, want: ramp(100, 8)},
+		{name: "restart", packets: []Packet{l16(100, 4), l16(100+11*8000, 4)}, want: slices.Concat(ramp(100, 4), ramp(100+11*8000, 4))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := NewDepacketizer(audio.Format{Rate: 8000, Channels: 1}, Options{Codec: L16})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range tt.packets {
+				if err := d.Push(p); err != nil {
+					t.Fatalf("Push() error = %v", err)
+				}
+			}
+			d.Close()
+			if got := readAll(t, d, 3); !slices.Equal(got, tt.want) {
+				t.Errorf("played %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDepacketizer_Live(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewDepacketizer(audio.Format{Rate: 8000, Channels: 1}, Options{Codec: L16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := make(chan []float32)
+	go func() {
+		buf := make([]float32, 16)
+		n, _ := d.ReadSamples(buf)
+		read <- buf[:n]
+	}()
+
+	select {
+	case <-read:
+		t.Fatal("ReadSamples() returned with no packet")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := d.Push(l16(100, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-read; !slices.Equal(got, ramp(100, 4)) {
+		t.Errorf("read %v", got)
+	}
+
+	// Too late once the reader has gone past
+	if err := d.Push(l16(98, 4)); err != nil {
+		t.Fatal(err)
+	}
+	// A new SSRC starts over
+	p := l16(5000, 2)
+	p.SSRC = 2
+	if err := d.Push(p); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	if got := readAll(t, d, 16); !slices.Equal(got, ramp(5000, 2)) {
+		t.Errorf("read %v", got)
+	}
+	if err := d.Push(l16(5002, 2)); !errors.Is(err, ErrDepacketizerClosed) {
+		t.Errorf("Push() after Close error = %v, want ErrDepacketizerClosed", err)
+	}
+}
+
+func TestDepacketizer_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewDepacketizer(audio.Format{Rate: 16000, Channels: 1}, Options{Codec: PCMA}); !errors.Is(err, audio.ErrFormatMismatch) {
+		t.Errorf("NewDepacketizer(PCMA at 16 kHz) error = %v, want ErrFormatMismatch", err)
+	}
+	d, err := NewDepacketizer(audio.Format{Rate: 8000, Channels: 2}, Options{Codec: PCMU})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadSamples(make([]float32, 3)); !errors.Is(err, audio.ErrInvalidDstSize) {
+		t.Errorf("ReadSamples(odd) error = %v, want ErrInvalidDstSize", err)
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package rtp bridges audio sources and the RTP media streams of SIP calls
+// (RFC 3550), in the PCMU, PCMA and L16 payload formats of RFC 3551. It
+// handles the payloads, sequence numbers and timestamps; sockets, RTCP
+// and SRTP are left to the media stack.
+//
+// A Packetizer cuts a Source into packets of 20 ms, or Options.Ptime, to
+// send:
+//
+//	p, err := rtp.NewPacketizer(prompt, rtp.Options{Codec: rtp.PCMU})
+//	for {
+//	    pkt, err := p.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    conn.Write(pkt.Marshal())
+//	    time.Sleep(rtp.DefaultPtime) // or a ticker of the media stack
+//	}
+//
+// A Depacketizer is the reverse, a Source of the packets received, which
+// can be recorded, analyzed or bridged like any other:
+//
+//	d, err := rtp.NewDepacketizer(audio.Format{Rate: 8000, Channels: 1}, rtp.Options{Codec: rtp.PCMA})
+//	go func() {
+//	    defer d.Close()
+//	    buf := make([]byte, 1500)
+//	    for {
+//	        n, err := conn.Read(buf)
+//	        if err != nil {
+//	            return
+//	        }
+//	        var pkt rtp.Packet
+//	        if pkt.Unmarshal(buf[:n]) == nil {
+//	            d.Push(pkt)
+//	        }
+//	    }
+//	}()
+//	_, err = wavWriter.WriteSource(d)
+//
+// # Formats
+//
+// G.711 is sent at 8 kHz; sources of another rate are resampled first,
+// with audio.NewResampler. L16 takes any rate and channel count, and the
+// payload type of the SDP, Options.PayloadType, when the rate has no
+// static one. The RTP clock rate is the sample rate in every case, so
+// timestamps count sample frames.
+package rtp
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package rtp
+
+import "errors"
+
+var (
+	ErrInvalidPacket      = errors.New("invalid RTP packet")
+	ErrUnknownCodec       = errors.New("unknown RTP codec")
+	ErrDepacketizerClosed = errors.New("depacketizer is closed")
+)
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// headerSize is the size of the fixed RTP header
+const headerSize = 12
+
+// Packet is an RTP packet (RFC 3550) of audio. CSRC lists and header
+// extensions are skipped when reading and never written.
+type Packet struct {
+	PayloadType    uint8
+	Marker         bool // the first packet of a talkspurt
+	SequenceNumber uint16
+	Timestamp      uint32 // of the first sample frame, in clock rate ticks
+	SSRC           uint32
+	Payload        []byte
+}
+
+// Marshal returns the packet as sent on the wire.
+func (p *Packet) Marshal() []byte {
+	return p.AppendTo(make([]byte, 0, headerSize+len(p.Payload)))
+}
+
+// AppendTo appends the packet as sent on the wire to b.
+func (p *Packet) AppendTo(b []byte) []byte {
+	pt := p.PayloadType & 0x7F
+	if p.Marker {
+		pt |= 0x80
+	}
+	b = append(b, 2<<6, pt)
+	b = binary.BigEndian.AppendUint16(b, p.SequenceNumber)
+	b = binary.BigEndian.AppendUint32(b, p.Timestamp)
+	b = binary.BigEndian.AppendUint32(b, p.SSRC)
+	return append(b, p.Payload...)
+}
+
+// Unmarshal reads a packet from b, as received from the wire. The payload
+// is a slice of b.
+func (p *Packet) Unmarshal(b []byte) error {
+	if len(b) < headerSize {
+		return fmt.Errorf("%w: %d bytes", ErrInvalidPacket, len(b))
+	}
+	if v := b[0] >> 6; v != 2 {
+		return fmt.Errorf("%w: version %d", ErrInvalidPacket, v)
+	}
+
+	end := len(b)
+	if b[0]&0x20 != 0 {
+		// The last byte counts the padding, itself included
+		pad := int(b[end-1])
+		if pad == 0 || pad > end-headerSize {
+			return fmt.Errorf("%w: %d bytes of padding", ErrInvalidPacket, pad)
+		}
+		end -= pad
+	}
+	start := headerSize + 4*int(b[0]&0x0F) // CSRC list
+	if b[0]&0x10 != 0 {
+		if start+4 > end {
+			return fmt.Errorf("%w: short header extension", ErrInvalidPacket)
+		}
+		start += 4 + 4*int(binary.BigEndian.Uint16(b[start+2:]))
+	}
+	if start > end {
+		return fmt.Errorf("%w: header of %d bytes in %d", ErrInvalidPacket, start, end)
+	}
+
+	*p = Packet{
+		PayloadType:    b[1] & 0x7F,
+		Marker:         b[1]&0x80 != 0,
+		SequenceNumber: binary.BigEndian.Uint16(b[2:]),
+		Timestamp:      binary.BigEndian.Uint32(b[4:]),
+		SSRC:           binary.BigEndian.Uint32(b[8:]),
+		Payload:        b[start:end],
+	}
+	return nil
+}
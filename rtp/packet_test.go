@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package rtp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPacket_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []Packet{
+		{PayloadType: 0, Marker: true, SequenceNumber: 1, Timestamp: 160, SSRC: 0xDEADBEEF, Payload: []byte{1, 2, 3}},
+		{PayloadType: 96, SequenceNumber: 65535, Timestamp: 0xFFFFFFFF, SSRC: 1, Payload: []byte{}},
+	}
+
+	for _, want := range tests {
+		b := want.Marshal()
+		if len(b) != headerSize+len(want.Payload) {
+			t.Fatalf("Marshal() = %d bytes", len(b))
+		}
+		var got Packet
+		if err := got.Unmarshal(b); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if got.PayloadType != want.PayloadType || got.Marker != want.Marker || got.SequenceNumber != want.SequenceNumber ||
+			got.Timestamp != want.Timestamp || got.SSRC != want.SSRC || !bytes.Equal(got.Payload, want.Payload) {
+			t.Errorf("Unmarshal(Marshal()) = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestPacket_Unmarshal(t *testing.T) {
+	t.Parallel()
+
+	header := func(first byte) []byte {
+		return []byte{first, 8, 0, 1, 0, 0, 0, 160, 0, 0, 0, 7}
+	}
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name    string
+		b       []byte
+		payload []byte
+		wantErr bool
+	}{
+		{name: "plain", b: join(header(0x80), []byte{1, 2}), payload: []byte{1, 2}},
+		{name: "CSRC", b: join(header(0x82), make([]byte, 8), []byte{1, 2}), payload: []byte{1, 2}},
+		{name: "extension", b: join(header(0x90), []byte{0xBE, 0xDE, 0, 1}, make([]byte, 4), []byte{1, 2}), payload: []byte{1, 2}},
+		{name: "padding", b: join(header(0xA0), []byte{1, 2, 0, 0, 3}), payload: []byte{1, 2}},
+		{name: "short", b: header(0x80)[:11], wantErr: true},
+		{name: "version 1", b: header(0x40), wantErr: true},
+		{name: "too much padding", b: join(header(0xA0), []byte{1, 9}), wantErr: true},
+		{name: "CSRC past the end", b: join(header(0x83), make([]byte, 8)), wantErr: true},
+		{name: "extension past the end", b: join(header(0x90), []byte{0xBE, 0xDE, 0, 2}, make([]byte, 4)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var p Packet
+			err := p.Unmarshal(tt.b)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPacket) {
+					t.Errorf("Unmarshal() error = %v, want ErrInvalidPacket", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if p.PayloadType != 8 || p.SequenceNumber != 1 || p.Timestamp != 160 || p.SSRC != 7 || !bytes.Equal(p.Payload, tt.payload) {
+				t.Errorf("Unmarshal() = %+v", p)
+			}
+		})
+	}
+}
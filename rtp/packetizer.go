@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package rtp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// DefaultPtime is the audio carried by a packet when Options.Ptime is
+// zero, the usual packetization of telephony.
+const DefaultPtime = 20 * time.Millisecond
+
+// Options configures a Packetizer or a Depacketizer.
+type Options struct {
+	// Codec is the payload format.
+	Codec Codec
+
+	// PayloadType is the RTP payload type of the codec, as the SDP of the
+	// call maps it. 0 selects Codec.PayloadType of the format.
+	PayloadType uint8
+
+	// Ptime is the audio a packet carries. Defaults to DefaultPtime. Only
+	// sending uses it; packets of any length are received.
+	Ptime time.Duration
+
+	// SSRC, Sequence and Timestamp are those of the first packet sent.
+	// As RFC 3550 recommends, zero ones are chosen at random.
+	SSRC      uint32
+	Sequence  uint16
+	Timestamp uint32
+}
+
+// payloadType returns the payload type of the codec for format
+func (o Options) payloadType(format audio.Format) uint8 {
+	if o.PayloadType != 0 {
+		return o.PayloadType
+	}
+	return o.Codec.PayloadType(format)
+}
+
+// Packetizer cuts an audio.Source into RTP packets of Options.Ptime,
+// numbered and timestamped, for a SIP media stack to send. The clock rate
+// is the sample rate, so timestamps count sample frames.
+type Packetizer struct {
+	src    audio.Source
+	codec  Codec
+	pt     uint8
+	frames int // per packet
+	buf    []float32
+
+	seq    uint16
+	ts     uint32
+	ssrc   uint32
+	marker bool
+	ended  bool
+}
+
+// NewPacketizer creates a Packetizer of src, which must have the rate of
+// the codec: 8 kHz for G.711. The first packet has the marker bit set,
+// as it starts a talkspurt.
+func NewPacketizer(src audio.Source, opts Options) (*Packetizer, error) {
+	format := audio.FormatOf(src)
+	if err := opts.Codec.check(format); err != nil {
+		return nil, err
+	}
+	if opts.Ptime <= 0 {
+		opts.Ptime = DefaultPtime
+	}
+	if opts.SSRC == 0 {
+		opts.SSRC = rand.Uint32()
+	}
+	if opts.Sequence == 0 {
+		opts.Sequence = uint16(rand.Uint32())
+	}
+	if opts.Timestamp == 0 {
+		opts.Timestamp = rand.Uint32()
+	}
+
+	frames := max(audio.FrameLen(format.Rate, opts.Ptime), 1)
+	return &Packetizer{
+		src:    src,
+		codec:  opts.Codec,
+		pt:     opts.payloadType(format),
+		frames: frames,
+		buf:    make([]float32, frames*format.Channels),
+		seq:    opts.Sequence,
+		ts:     opts.Timestamp,
+		ssrc:   opts.SSRC,
+		marker: true,
+	}, nil
+}
+
+// SSRC returns the synchronization source of the packets.
+func (p *Packetizer) SSRC() uint32 { return p.ssrc }
+
+// Next reads a packet of audio from the source. The last packet may be
+// shorter; after it, Next returns io.EOF. The payload is the caller's.
+func (p *Packetizer) Next() (Packet, error) {
+	if p.ended {
+		return Packet{}, io.EOF
+	}
+
+	ch := p.src.Channels()
+	n := 0
+	for n < len(p.buf) {
+		k, err := p.src.ReadSamples(p.buf[n:])
+		n += k
+		if errors.Is(err, io.EOF) {
+			p.ended = true
+			break
+		}
+		if err != nil {
+			return Packet{}, fmt.Errorf("%w", err)
+		}
+	}
+	n -= n % ch
+	if n == 0 {
+		return Packet{}, io.EOF
+	}
+
+	pkt := Packet{
+		PayloadType:    p.pt,
+		Marker:         p.marker,
+		SequenceNumber: p.seq,
+		Timestamp:      p.ts,
+		SSRC:           p.ssrc,
+		Payload:        p.codec.encode(make([]byte, 0, n*p.codec.sampleSize()), p.buf[:n]),
+	}
+	p.marker = false
+	p.seq++
+	p.ts += uint32(n / ch)
+	return pkt, nil
+}
+
+// Close closes the source.
+func (p *Packetizer) Close() error {
+	if err := p.src.Close(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package rtp
+
+import (
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestPacketizer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		opts     Options
+		rate     int
+		channels int
+		frames   int
+		pt       uint8
+		packets  int
+		perPkt   int     // bytes of a full packet
+		maxErr   float64 // of a round trip through the depacketizer
+	}{
+		{name: "PCMU", opts: Options{Codec: PCMU}, rate: 8000, channels: 1, frames: 800, pt: 0, packets: 5, perPkt: 160, maxErr: 0.03},
+		{name: "PCMA, 30 ms", opts: Options{Codec: PCMA, Ptime: 30 * time.Millisecond}, rate: 8000, channels: 1, frames: 800, pt: 8, packets: 4, perPkt: 240, maxErr: 0.03},
+		{name: "L16 44.1 kHz stereo", opts: Options{Codec: L16}, rate: 44100, channels: 2, frames: 4410, pt: 10, packets: 5, perPkt: 3528, maxErr: 1e-4},
+		{name: "L16 16 kHz", opts: Options{Codec: L16, PayloadType: 118}, rate: 16000, channels: 1, frames: 1000, pt: 118, packets: 4, perPkt: 640, maxErr: 1e-4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := tt.opts
+			opts.SSRC, opts.Sequence, opts.Timestamp = 42, 65534, 0xFFFFFF00
+			p, err := NewPacketizer(audiotest.NewSineSource(tt.rate, tt.channels, tt.frames, 440), opts)
+			if err != nil {
+				t.Fatalf("NewPacketizer() error = %v", err)
+			}
+			format := audio.Format{Rate: tt.rate, Channels: tt.channels}
+			d, err := NewDepacketizer(format, opts)
+			if err != nil {
+				t.Fatalf("NewDepacketizer() error = %v", err)
+			}
+
+			var packets []Packet
+			for {
+				pkt, err := p.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				packets = append(packets, pkt)
+			}
+			if len(packets) != tt.packets {
+				t.Fatalf("got %d packets, want %d", len(packets), tt.packets)
+			}
+
+			ts, frameSize := uint32(0xFFFFFF00), tt.channels*opts.Codec.sampleSize()
+			for i, pkt := range packets {
+				if pkt.PayloadType != tt.pt || pkt.SSRC != 42 || pkt.SequenceNumber != uint16(65534+i) || pkt.Marker != (i == 0) {
+					t.Errorf("packet %d: %+v", i, pkt)
+				}
+				if pkt.Timestamp != ts {
+					t.Errorf("packet %d: timestamp %d, want %d", i, pkt.Timestamp, ts)
+				}
+				if i < len(packets)-1 && len(pkt.Payload) != tt.perPkt {
+					t.Errorf("packet %d: %d bytes, want %d", i, len(pkt.Payload), tt.perPkt)
+				}
+				ts += uint32(len(pkt.Payload) / frameSize)
+				if err := d.Push(pkt); err != nil {
+					t.Fatalf("Push() error = %v", err)
+				}
+			}
+			d.Close()
+
+			got := readAll(t, d, 100*tt.channels)
+			ref := readAll(t, audiotest.NewSineSource(tt.rate, tt.channels, tt.frames, 440), 4096)
+			if len(got) != len(ref) {
+				t.Fatalf("depacketized %d frames, want %d", len(got)/tt.channels, tt.frames)
+			}
+			for i := range got {
+				if e := math.Abs(float64(got[i] - ref[i])); e > tt.maxErr {
+					t.Fatalf("sample %d = %v, want %v", i, got[i], ref[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPacketizer_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewPacketizer(audiotest.NewSilentSource(16000, 1, 10), Options{Codec: PCMU}); !errors.Is(err, audio.ErrFormatMismatch) {
+		t.Errorf("NewPacketizer(PCMU at 16 kHz) error = %v, want ErrFormatMismatch", err)
+	}
+	if _, err := NewPacketizer(audiotest.NewSilentSource(8000, 1, 10), Options{}); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("NewPacketizer(no codec) error = %v, want ErrUnknownCodec", err)
+	}
+
+	faulty := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 1000), audiotest.WithErrorOnCall(2, audiotest.ErrInjected))
+	p, err := NewPacketizer(faulty, Options{Codec: PCMA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.SSRC() == 0 {
+		t.Error("SSRC() = 0, want a random one")
+	}
+	if _, err := p.Next(); err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if _, err := p.Next(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Next() error = %v, want ErrInjected", err)
+	}
+	if err := p.Close(); err != nil || !faulty.Closed() {
+		t.Errorf("Close() error = %v, source closed = %v", err, faulty.Closed())
+	}
+}
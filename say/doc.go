@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package say says numbers, amounts of money, dates and times to callers,
+// the way Asterisk's SayNumber and SayUnixTime do: a value becomes a
+// sequence of prompt keys by the rules of the caller's language, and the
+// prompts are played back to back as one audio.Source.
+//
+//	prompts := audpbx.NewPromptSet(audpbx.NewPromptLoader(os.DirFS("/var/lib/sounds")), audpbx.PromptSetOptions{})
+//	sayer := say.New(prompts, target, say.Options{})
+//
+//	balance, err := sayer.Money(1205, "USD", "en_US") // twelve dollars and five cents
+//	when, err := sayer.Date(time.Now(), "fr_CA")       // mardi 5 mars 2024
+//
+// # Prompt Keys
+//
+// Keys follow the layout of Asterisk's sound files, so its sound packages
+// serve as they are:
+//   - digits/0 to digits/19, digits/20 to digits/90 by tens, digits/hundred,
+//     digits/thousand, digits/million, digits/billion and digits/minus
+//   - digits/day-0 (Sunday) to digits/day-6 and digits/mon-0 (January) to
+//     digits/mon-11
+//   - digits/h-1 to digits/h-31, the ordinals of the days of the month
+//   - digits/oclock, digits/oh, digits/a-m and digits/p-m for times
+//   - letters/a to letters/z, digits/star and digits/pound for Digits
+//   - currency/dollar, currency/dollars, currency/cent, currency/cents and
+//     the like, and digits/and or digits/et between units and cents
+//
+// Rules return the keys without playing them, for a sentence of prompts
+// of your own:
+//
+//	r, _ := sayer.Rules("en")
+//	keys := append([]string{"vm-youhave"}, r.Number(3)...)
+//	keys = append(keys, "vm-messages")
+//	src, err := sayer.Play(keys, "en")
+//
+// # Languages
+//
+// English and French (France) rules are built in. Rules for a locale are
+// found like its prompts, through the fallback chain of the PromptSet:
+// fr_CA callers get the French rules. Add rules of your own, or for a
+// region, with Options.Rules.
+package say
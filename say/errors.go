@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package say
+
+import "errors"
+
+var (
+	ErrNoRules         = errors.New("no say rules for the locale")
+	ErrUnknownCurrency = errors.New("currency not known to the say rules")
+	ErrUnsayable       = errors.New("character cannot be said")
+)
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package say
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// French are the rules of French, as spoken in France: 70 is said
+// "soixante-dix" and 80 "quatre-vingts", with "digits/60" followed by
+// "digits/10" and the "digits/80" prompt.
+type French struct{}
+
+var frenchCurrencies = map[string]currencyKeys{
+	"EUR": {"currency/euro", "currency/euros", "currency/centime", "currency/centimes"},
+	"CAD": {"currency/dollar", "currency/dollars", "currency/cent", "currency/cents"},
+	"USD": {"currency/dollar", "currency/dollars", "currency/cent", "currency/cents"},
+	"CHF": {"currency/franc", "currency/francs", "currency/centime", "currency/centimes"},
+}
+
+// Number says n with the long scale names of French: "digits/thousand"
+// alone for 1000 (mille), "digits/et" in 21, 31, ... 71.
+func (French) Number(n int64) []string {
+	if n < 0 {
+		return append([]string{"digits/minus"}, French{}.number(-uint64(n))...)
+	}
+	return French{}.number(uint64(n))
+}
+
+func (f French) number(n uint64) []string {
+	if n == 0 {
+		return []string{"digits/0"}
+	}
+
+	var keys []string
+	for _, scale := range []struct {
+		value uint64
+		key   string
+	}{
+		{1_000_000_000, "digits/billion"},
+		{1_000_000, "digits/million"},
+		{1_000, "digits/thousand"},
+		{100, "digits/hundred"},
+	} {
+		if n < scale.value {
+			continue
+		}
+		// Mille and cent take no "un", million and milliard do
+		if q := n / scale.value; q > 1 || scale.value >= 1_000_000 {
+			keys = append(keys, f.number(q)...)
+		}
+		keys = append(keys, scale.key)
+		n %= scale.value
+	}
+
+	tens, units := n/10*10, n%10
+	switch {
+	case n == 0:
+	case n < 20:
+		keys = append(keys, digit(n))
+	case n < 70:
+		keys = append(keys, digit(tens))
+		if units == 1 {
+			keys = append(keys, "digits/et")
+		}
+		if units > 0 {
+			keys = append(keys, digit(units))
+		}
+	case n < 80:
+		// soixante-dix, soixante et onze, soixante-douze...
+		keys = append(keys, "digits/60")
+		if n == 71 {
+			keys = append(keys, "digits/et")
+		}
+		keys = append(keys, digit(n-60))
+	default:
+		// quatre-vingts, quatre-vingt-un, quatre-vingt-dix...
+		keys = append(keys, "digits/80")
+		if n > 80 {
+			keys = append(keys, digit(n-80))
+		}
+	}
+	return keys
+}
+
+func (French) Digits(s string) ([]string, error) { return digitKeys(s) }
+
+// Money says "12 euros et 5 centimes", "zéro euro" or "1 euro": French
+// takes the singular below 2.
+func (f French) Money(amount int64, currency string) ([]string, error) {
+	c, ok := frenchCurrencies[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, currency)
+	}
+
+	units, cents, negative := splitMoney(amount)
+	var keys []string
+	if negative {
+		keys = append(keys, "digits/minus")
+	}
+	if units > 0 || cents == 0 {
+		keys = append(keys, f.number(units)...)
+		keys = append(keys, plural(max(units, 1), c.one, c.many))
+	}
+	if cents > 0 {
+		if units > 0 {
+			keys = append(keys, "digits/et")
+		}
+		keys = append(keys, f.number(cents)...)
+		keys = append(keys, plural(max(cents, 1), c.minorOne, c.minorMany))
+	}
+	return keys, nil
+}
+
+// Date says "mardi 5 mars 2024", and "premier" for the first of the
+// month.
+func (f French) Date(t time.Time) []string {
+	keys := []string{"digits/day-" + strconv.Itoa(int(t.Weekday()))}
+	if t.Day() == 1 {
+		keys = append(keys, "digits/h-1")
+	} else {
+		keys = append(keys, f.number(uint64(t.Day()))...)
+	}
+	keys = append(keys, "digits/mon-"+strconv.Itoa(int(t.Month())-1))
+	return append(keys, f.Number(int64(t.Year()))...)
+}
+
+// Time says "15 heures 5", on the 24-hour clock, with "digits/oclock" for
+// "heures".
+func (f French) Time(t time.Time) []string {
+	keys := append(f.number(uint64(t.Hour())), "digits/oclock")
+	if t.Minute() > 0 {
+		keys = append(keys, f.number(uint64(t.Minute()))...)
+	}
+	return keys
+}
@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package say
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Rules turn values into prompt keys the way a language says them. Keys
+// follow the layout of Asterisk's sound files, so its sound packages can be
+// used as they are: "digits/7", "digits/hundred", "digits/mon-0", ...
+type Rules interface {
+	// Number says n as a cardinal: "digits/2", "digits/hundred", ...
+	Number(n int64) []string
+	// Digits says the characters of s one by one: digits, letters, '*'
+	// and '#'. Other characters fail with ErrUnsayable.
+	Digits(s string) ([]string, error)
+	// Money says amount, in the minor unit of the ISO 4217 currency
+	// (cents of "USD"). Currencies the rules do not know fail with
+	// ErrUnknownCurrency.
+	Money(amount int64, currency string) ([]string, error)
+	// Date says the day of the week, the day, the month and the year of t.
+	Date(t time.Time) []string
+	// Time says the hour and the minutes of t.
+	Time(t time.Time) []string
+}
+
+// currencyKeys are the keys of the units of a currency, singular and
+// plural
+type currencyKeys struct {
+	one, many           string
+	minorOne, minorMany string
+}
+
+// digitKeys says the characters of s one by one, as Asterisk's SayDigits
+// and SayAlpha do
+func digitKeys(s string) ([]string, error) {
+	keys := make([]string, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			keys = append(keys, "digits/"+string(r))
+		case r >= 'a' && r <= 'z':
+			keys = append(keys, "letters/"+string(r))
+		case r >= 'A' && r <= 'Z':
+			keys = append(keys, "letters/"+string(r-'A'+'a'))
+		case r == '*':
+			keys = append(keys, "digits/star")
+		case r == '#':
+			keys = append(keys, "digits/pound")
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnsayable, r)
+		}
+	}
+	return keys, nil
+}
+
+// digit is the key of the number n said as one prompt
+func digit(n uint64) string {
+	return "digits/" + strconv.FormatUint(n, 10)
+}
+
+// splitMoney returns the major and minor units of amount, and whether it
+// is negative
+func splitMoney(amount int64) (units, cents uint64, negative bool) {
+	abs := uint64(amount)
+	if amount < 0 {
+		abs = -abs
+	}
+	return abs / 100, abs % 100, amount < 0
+}
+
+// English are the rules of English.
+type English struct{}
+
+var englishCurrencies = map[string]currencyKeys{
+	"USD": {"currency/dollar", "currency/dollars", "currency/cent", "currency/cents"},
+	"CAD": {"currency/dollar", "currency/dollars", "currency/cent", "currency/cents"},
+	"EUR": {"currency/euro", "currency/euros", "currency/cent", "currency/cents"},
+	"GBP": {"currency/pound", "currency/pounds", "currency/penny", "currency/pence"},
+}
+
+// Number says n with the short scale: "digits/1", "digits/hundred",
+// "digits/20", "digits/3" for 123.
+func (English) Number(n int64) []string {
+	if n < 0 {
+		return append([]string{"digits/minus"}, English{}.number(-uint64(n))...)
+	}
+	return English{}.number(uint64(n))
+}
+
+func (e English) number(n uint64) []string {
+	if n == 0 {
+		return []string{"digits/0"}
+	}
+
+	var keys []string
+	for _, scale := range []struct {
+		value uint64
+		key   string
+	}{
+		{1_000_000_000, "digits/billion"},
+		{1_000_000, "digits/million"},
+		{1_000, "digits/thousand"},
+		{100, "digits/hundred"},
+	} {
+		if n >= scale.value {
+			keys = append(keys, e.number(n/scale.value)...)
+			keys = append(keys, scale.key)
+			n %= scale.value
+		}
+	}
+
+	switch {
+	case n == 0:
+	case n < 20:
+		keys = append(keys, digit(n))
+	default:
+		keys = append(keys, digit(n/10*10))
+		if n%10 != 0 {
+			keys = append(keys, digit(n%10))
+		}
+	}
+	return keys
+}
+
+func (English) Digits(s string) ([]string, error) { return digitKeys(s) }
+
+// Money says "12 dollars and 5 cents", or "zero dollars".
+func (e English) Money(amount int64, currency string) ([]string, error) {
+	c, ok := englishCurrencies[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, currency)
+	}
+
+	units, cents, negative := splitMoney(amount)
+	var keys []string
+	if negative {
+		keys = append(keys, "digits/minus")
+	}
+	if units > 0 || cents == 0 {
+		keys = append(keys, e.number(units)...)
+		keys = append(keys, plural(units, c.one, c.many))
+	}
+	if cents > 0 {
+		if units > 0 {
+			keys = append(keys, "digits/and")
+		}
+		keys = append(keys, e.number(cents)...)
+		keys = append(keys, plural(cents, c.minorOne, c.minorMany))
+	}
+	return keys, nil
+}
+
+// Date says "Tuesday, March fifth, 2024".
+func (e English) Date(t time.Time) []string {
+	keys := []string{
+		"digits/day-" + strconv.Itoa(int(t.Weekday())),
+		"digits/mon-" + strconv.Itoa(int(t.Month())-1),
+		"digits/h-" + strconv.Itoa(t.Day()),
+	}
+	return append(keys, e.Number(int64(t.Year()))...)
+}
+
+// Time says "3 oh 5 p.m.", "3 o'clock p.m." or "3 45 p.m.".
+func (e English) Time(t time.Time) []string {
+	hour := uint64(t.Hour() % 12)
+	if hour == 0 {
+		hour = 12
+	}
+	keys := e.number(hour)
+
+	switch m := uint64(t.Minute()); {
+	case m == 0:
+		keys = append(keys, "digits/oclock")
+	case m < 10:
+		keys = append(keys, "digits/oh", digit(m))
+	default:
+		keys = append(keys, e.number(m)...)
+	}
+
+	if t.Hour() < 12 {
+		return append(keys, "digits/a-m")
+	}
+	return append(keys, "digits/p-m")
+}
+
+// plural returns one for n == 1 and many otherwise
+func plural(n uint64, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package say
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keys splits a space separated list of keys, "digits/" implied for
+// those without a slash
+func keys(s string) []string {
+	k := strings.Fields(s)
+	for i := range k {
+		if !strings.Contains(k[i], "/") {
+			k[i] = "digits/" + k[i]
+		}
+	}
+	return k
+}
+
+func TestRules_Number(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		n      int64
+		en, fr string
+	}{
+		{n: 0, en: "0", fr: "0"},
+		{n: 7, en: "7", fr: "7"},
+		{n: 17, en: "17", fr: "17"},
+		{n: 21, en: "20 1", fr: "20 et 1"},
+		{n: 40, en: "40", fr: "40"},
+		{n: 70, en: "70", fr: "60 10"},
+		{n: 71, en: "70 1", fr: "60 et 11"},
+		{n: 79, en: "70 9", fr: "60 19"},
+		{n: 80, en: "80", fr: "80"},
+		{n: 81, en: "80 1", fr: "80 1"},
+		{n: 99, en: "90 9", fr: "80 19"},
+		{n: 100, en: "1 hundred", fr: "hundred"},
+		{n: 123, en: "1 hundred 20 3", fr: "hundred 20 3"},
+		{n: 1000, en: "1 thousand", fr: "thousand"},
+		{n: 2024, en: "2 thousand 20 4", fr: "2 thousand 20 4"},
+		{n: 1_000_000, en: "1 million", fr: "1 million"},
+		{n: 3_400_015, en: "3 million 4 hundred thousand 15", fr: "3 million 4 hundred thousand 15"},
+		{n: -5, en: "minus 5", fr: "minus 5"},
+		// 9 223 372 036 854 775 808
+		{
+			n:  math.MinInt64,
+			en: "minus 9 billion 2 hundred 20 3 million 3 hundred 70 2 thousand 30 6 billion 8 hundred 50 4 million 7 hundred 70 5 thousand 8 hundred 8",
+			fr: "minus 9 billion 2 hundred 20 3 million 3 hundred 60 12 thousand 30 6 billion 8 hundred 50 4 million 7 hundred 60 15 thousand 8 hundred 8",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := (English{}).Number(tt.n); !slices.Equal(got, keys(tt.en)) {
+			t.Errorf("English.Number(%d) = %v, want %v", tt.n, got, keys(tt.en))
+		}
+		if got := (French{}).Number(tt.n); !slices.Equal(got, keys(tt.fr)) {
+			t.Errorf("French.Number(%d) = %v, want %v", tt.n, got, keys(tt.fr))
+		}
+	}
+}
+
+func TestRules_Money(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rules    Rules
+		amount   int64
+		currency string
+		want     string
+		wantErr  error
+	}{
+		{rules: English{}, amount: 1205, currency: "USD", want: "12 currency/dollars and 5 currency/cents"},
+		{rules: English{}, amount: 100, currency: "USD", want: "1 currency/dollar"},
+		{rules: English{}, amount: 1, currency: "GBP", want: "1 currency/penny"},
+		{rules: English{}, amount: 0, currency: "EUR", want: "0 currency/euros"},
+		{rules: English{}, amount: -250, currency: "USD", want: "minus 2 currency/dollars and 50 currency/cents"},
+		{rules: English{}, amount: 1, currency: "XYZ", wantErr: ErrUnknownCurrency},
+		{rules: French{}, amount: 1205, currency: "EUR", want: "12 currency/euros et 5 currency/centimes"},
+		{rules: French{}, amount: 0, currency: "EUR", want: "0 currency/euro"},
+		{rules: French{}, amount: 101, currency: "EUR", want: "1 currency/euro et 1 currency/centime"},
+		{rules: French{}, amount: 1, currency: "GBP", wantErr: ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.rules.Money(tt.amount, tt.currency)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%T.Money(%d, %s) error = %v, want %v", tt.rules, tt.amount, tt.currency, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, keys(tt.want)) {
+			t.Errorf("%T.Money(%d, %s) = %v, want %v", tt.rules, tt.amount, tt.currency, got, keys(tt.want))
+		}
+	}
+}
+
+func TestRules_DateTime(t *testing.T) {
+	t.Parallel()
+
+	tuesday := time.Date(2024, time.March, 5, 15, 5, 0, 0, time.UTC)
+	first := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	evening := time.Date(2024, time.January, 1, 21, 45, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		got  []string
+		want string
+	}{
+		{name: "English date", got: English{}.Date(tuesday), want: "day-2 mon-2 h-5 2 thousand 20 4"},
+		{name: "English time", got: English{}.Time(tuesday), want: "3 oh 5 p-m"},
+		{name: "English midnight", got: English{}.Time(first), want: "12 oclock a-m"},
+		{name: "English evening", got: English{}.Time(evening), want: "9 40 5 p-m"},
+		{name: "French date", got: French{}.Date(tuesday), want: "day-2 5 mon-2 2 thousand 20 4"},
+		{name: "French first", got: French{}.Date(first), want: "day-1 h-1 mon-0 2 thousand 20 4"},
+		{name: "French time", got: French{}.Time(tuesday), want: "15 oclock 5"},
+		{name: "French midnight", got: French{}.Time(first), want: "0 oclock"},
+	}
+
+	for _, tt := range tests {
+		if !slices.Equal(tt.got, keys(tt.want)) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, keys(tt.want))
+		}
+	}
+}
+
+func TestRules_Digits(t *testing.T) {
+	t.Parallel()
+
+	got, err := English{}.Digits("07aZ*#")
+	if err != nil {
+		t.Fatalf("Digits() error = %v", err)
+	}
+	if want := keys("0 7 letters/a letters/z star pound"); !slices.Equal(got, want) {
+		t.Errorf("Digits() = %v, want %v", got, want)
+	}
+	if _, err := (French{}).Digits("1-2"); !errors.Is(err, ErrUnsayable) {
+		t.Errorf("Digits(\"1-2\") error = %v, want ErrUnsayable", err)
+	}
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package say
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ik5/audpbx"
+	"github.com/ik5/audpbx/audio"
+)
+
+// Options configures a Sayer.
+type Options struct {
+	// Rules adds rules by locale, or replaces the built-in ones: English
+	// for "en" and French for "fr".
+	Rules map[string]Rules
+}
+
+// Sayer says values to callers: it turns them into prompt keys with the
+// rules of the caller's locale, and plays the prompts back to back.
+type Sayer struct {
+	prompts *audpbx.PromptSet
+	target  audpbx.PromptTarget
+	rules   map[string]Rules
+}
+
+// New creates a Sayer playing the prompts of set, opened for target.
+func New(set *audpbx.PromptSet, target audpbx.PromptTarget, opts Options) *Sayer {
+	rules := map[string]Rules{"en": English{}, "fr": French{}}
+	for locale, r := range opts.Rules {
+		rules[locale] = r
+	}
+	return &Sayer{prompts: set, target: target, rules: rules}
+}
+
+// Rules returns the rules for locale: those of the first of its locales,
+// as the prompt set searches them, that has any. "fr_CA" gets the French
+// rules unless rules for "fr_CA" were given.
+func (s *Sayer) Rules(locale string) (Rules, error) {
+	locales := s.prompts.Locales(locale)
+	for _, l := range locales {
+		if r, ok := s.rules[l]; ok {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoRules, locale)
+}
+
+// Play opens the prompts of keys in locale and returns them as one source,
+// an audio.Concat. Prompts missing in the locale come from its fallbacks.
+func (s *Sayer) Play(keys []string, locale string) (audio.Source, error) {
+	srcs := make([]audio.Source, 0, len(keys))
+	closeAll := func(err error) error {
+		for _, src := range srcs {
+			err = errors.Join(err, src.Close())
+		}
+		return err
+	}
+
+	for _, key := range keys {
+		src, err := s.prompts.Open(key, locale, s.target)
+		if err != nil {
+			return nil, closeAll(err)
+		}
+		srcs = append(srcs, src)
+	}
+
+	c, err := audio.NewConcat(srcs...)
+	if err != nil {
+		return nil, closeAll(fmt.Errorf("%w", err))
+	}
+	return c, nil
+}
+
+// Number says n in locale.
+func (s *Sayer) Number(n int64, locale string) (audio.Source, error) {
+	return s.say(locale, func(r Rules) ([]string, error) { return r.Number(n), nil })
+}
+
+// Digits says the characters of digits one by one in locale, as for a
+// phone or account number.
+func (s *Sayer) Digits(digits, locale string) (audio.Source, error) {
+	return s.say(locale, func(r Rules) ([]string, error) { return r.Digits(digits) })
+}
+
+// Money says amount, in the minor unit of the ISO 4217 currency, in
+// locale.
+func (s *Sayer) Money(amount int64, currency, locale string) (audio.Source, error) {
+	return s.say(locale, func(r Rules) ([]string, error) { return r.Money(amount, currency) })
+}
+
+// Date says the date of t in locale, in the location of t.
+func (s *Sayer) Date(t time.Time, locale string) (audio.Source, error) {
+	return s.say(locale, func(r Rules) ([]string, error) { return r.Date(t), nil })
+}
+
+// Time says the time of day of t in locale, in the location of t.
+func (s *Sayer) Time(t time.Time, locale string) (audio.Source, error) {
+	return s.say(locale, func(r Rules) ([]string, error) { return r.Time(t), nil })
+}
+
+// say plays the keys the rules for locale give
+func (s *Sayer) say(locale string, keys func(Rules) ([]string, error)) (audio.Source, error) {
+	r, err := s.Rules(locale)
+	if err != nil {
+		return nil, err
+	}
+	k, err := keys(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.Play(k, locale)
+}
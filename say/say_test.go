@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package say
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ik5/audpbx"
+	"github.com/ik5/audpbx/audio"
+)
+
+// prompt is a 10 sample 8 kHz signed linear prompt at value/32768
+func prompt(value int16) *fstest.MapFile {
+	var b []byte
+	for range 10 {
+		b = binary.LittleEndian.AppendUint16(b, uint16(value))
+	}
+	return &fstest.MapFile{Data: b}
+}
+
+// played reads src and returns the value of each prompt in it
+func played(t *testing.T, src audio.Source) []int16 {
+	t.Helper()
+
+	var got []int16
+	buf := make([]float32, 10)
+	for {
+		n, err := src.ReadSamples(buf)
+		if n == 10 {
+			got = append(got, int16(buf[0]*32768))
+		} else if n != 0 {
+			t.Fatalf("read %d samples, want whole prompts", n)
+		}
+		if errors.Is(err, io.EOF) {
+			return got
+		}
+		if err != nil {
+			t.Fatalf("ReadSamples() error = %v", err)
+		}
+	}
+}
+
+func TestSayer(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"en/digits/1.sln":           prompt(1),
+		"en/digits/20.sln":          prompt(20),
+		"en/digits/60.sln":          prompt(60),
+		"en/digits/and.sln":         prompt(99),
+		"en/currency/dollars.sln":   prompt(100),
+		"en/currency/cent.sln":      prompt(101),
+		"fr/digits/60.sln":          prompt(-60),
+		"fr/digits/et.sln":          prompt(-99),
+		"fr/digits/11.sln":          prompt(-11),
+		"fr_CA/currency/dollar.sln": prompt(-100),
+	}
+	set := audpbx.NewPromptSet(audpbx.NewPromptLoader(fsys), audpbx.PromptSetOptions{})
+	s := New(set, audpbx.PromptTarget{Format: audio.Format{Rate: 8000, Channels: 1}}, Options{})
+
+	tests := []struct {
+		name string
+		say  func() (audio.Source, error)
+		want []int16
+	}{
+		{name: "English", say: func() (audio.Source, error) { return s.Number(21, "en_US") }, want: []int16{20, 1}},
+		{name: "French", say: func() (audio.Source, error) { return s.Number(71, "fr_CA") }, want: []int16{-60, -99, -11}},
+		// Rules of French, the "20" prompt of English
+		{name: "prompt fallback", say: func() (audio.Source, error) { return s.Number(21, "fr") }, want: []int16{20, -99, 1}},
+		{name: "money", say: func() (audio.Source, error) { return s.Money(2001, "USD", "en") }, want: []int16{20, 100, 99, 1, 101}},
+		{name: "regional prompt", say: func() (audio.Source, error) { return s.Money(100, "CAD", "fr_CA") }, want: []int16{1, -100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := tt.say()
+			if err != nil {
+				t.Fatalf("say error = %v", err)
+			}
+			if got := played(t, src); !slices.Equal(got, tt.want) {
+				t.Errorf("played %v, want %v", got, tt.want)
+			}
+			if err := src.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSayer_Errors(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{"de/digits/1.sln": prompt(1)}
+	target := audpbx.PromptTarget{Format: audio.Format{Rate: 8000, Channels: 1}}
+	set := audpbx.NewPromptSet(audpbx.NewPromptLoader(fsys), audpbx.PromptSetOptions{Fallback: []string{}})
+
+	if _, err := New(set, target, Options{}).Number(1, "de"); !errors.Is(err, ErrNoRules) {
+		t.Errorf("Number() without rules error = %v, want ErrNoRules", err)
+	}
+
+	s := New(set, target, Options{Rules: map[string]Rules{"de": English{}}})
+	src, err := s.Number(1, "de_AT")
+	if err != nil {
+		t.Fatalf("Number() with custom rules error = %v", err)
+	}
+	if got := played(t, src); !slices.Equal(got, []int16{1}) {
+		t.Errorf("played %v, want [1]", got)
+	}
+
+	if _, err := s.Number(21, "de"); !errors.Is(err, audpbx.ErrPromptNotFound) {
+		t.Errorf("Number() with a missing prompt error = %v, want ErrPromptNotFound", err)
+	}
+	if _, err := s.Money(1, "XYZ", "de"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Money() error = %v, want ErrUnknownCurrency", err)
+	}
+}
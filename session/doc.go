@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package session runs both directions of a call leg together: prompts
+// played to the caller and the caller's audio recorded and analyzed, over
+// one transport such as an RTP stream or an AudioSocket connection.
+//
+// A Transport is an audio.Source of the caller's audio that can also send
+// audio back. A Session moves one frame in each direction per step, so the
+// recording and the prompts share one timeline from the first frame:
+//
+//	s, err := session.New(leg, session.Options{
+//	    Record:    wavWriter,
+//	    Analyze:   detector.Feed, // DTMF, speech recognition, ...
+//	    OnBargeIn: func(e session.BargeIn) { log.Println("barge-in at", e.At) },
+//	})
+//	s.Play(menuPrompt, true) // the caller may interrupt it
+//	err = s.Run()            // until the caller hangs up or Close
+//
+// # Barge-In
+//
+// A prompt played with bargeIn set stops as soon as the caller speaks over
+// it for Options.BargeInMinSpeech, and the prompts queued after it are
+// dropped, as an IVR menu does when the caller already knows the option.
+// Speech is measured by an audio.BargeIn over the transport: frames at or
+// above Options.BargeInThreshold RMS.
+//
+// # Lifecycle
+//
+// Close stops the session and closes everything it was given: the
+// transport, the prompts still queued and the recording sink when it is
+// an io.Closer, so a WAV header gets its final sizes. A failing recording
+// sink never drops the call: recording stops and the error is kept for
+// Err.
+package session
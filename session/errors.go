@@ -0,0 +1,10 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package session
+
+import "errors"
+
+var (
+	ErrSessionClosed  = errors.New("session is closed")
+	ErrSessionRunning = errors.New("session is already running")
+)
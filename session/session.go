@@ -0,0 +1,376 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/record"
+)
+
+// DefaultFrame is the duration moved in each direction per step when
+// Options.Frame is zero.
+const DefaultFrame = 20 * time.Millisecond
+
+// Transport is a call leg: an audio.Source of what the caller says that
+// also sends audio to the caller. ReadSamples is expected to block until
+// the audio arrives, which paces the Session; Close hangs up.
+type Transport interface {
+	audio.Source
+	WriteSamples(samples []float32) error
+}
+
+// BargeIn describes a prompt the caller interrupted.
+type BargeIn struct {
+	// At is the session time the prompt was stopped at.
+	At time.Duration
+	// Prompt is the interrupted prompt, already closed.
+	Prompt audio.Source
+	// Dropped is the number of queued prompts dropped with it.
+	Dropped int
+}
+
+// Options configures a Session. Zero values select the defaults.
+type Options struct {
+	// Frame is the duration moved in each direction per step, 20 ms by
+	// default, the usual RTP packet.
+	Frame time.Duration
+
+	// Record receives the caller's audio, from the first frame on. nil
+	// records nothing.
+	Record record.Encoder
+	// Analyze is called with every frame of the caller's audio, after
+	// barge-in detection. The slice is reused by the next frame.
+	Analyze func(frame []float32)
+
+	// BargeInThreshold is the RMS level of a frame, in [0, 1], at or
+	// above which the caller counts as speaking, 0.03 (-30 dBFS) by
+	// default; see audio.BargeInOptions.
+	BargeInThreshold float32
+	// BargeInMinSpeech is how long the caller must speak over a prompt to
+	// stop it, 200 ms by default, so a cough or a click does not.
+	BargeInMinSpeech time.Duration
+	// OnBargeIn, when set, is called from Run after a prompt is stopped.
+	OnBargeIn func(BargeIn)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Frame <= 0 {
+		o.Frame = DefaultFrame
+	}
+	return o
+}
+
+// Session plays prompts to a caller and records what the caller says over
+// one Transport, a frame in each direction at a time, so both share one
+// timeline. Prompts play one after the other, with silence in between;
+// those played with barge-in stop when the caller talks over them.
+//
+// Play, Stop and Close are safe to call from any goroutine while Run runs.
+type Session struct {
+	transport Transport
+	inbound   *audio.BargeIn // over transport
+	format    audio.Format
+	opts      Options
+
+	mu      *sync.Mutex
+	prompts []prompt
+	frames  int64 // sample frames moved so far
+	barged  bool  // set by inbound while reading
+	err     error
+	running bool
+	closed  bool
+
+	in, out []float32
+}
+
+type prompt struct {
+	src     audio.Source
+	bargeIn bool
+}
+
+// New creates a Session over t. Nothing moves until Run or Step.
+func New(t Transport, opts Options) (*Session, error) {
+	format := audio.FormatOf(t)
+	if err := format.Validate(); err != nil {
+		return nil, fmt.Errorf("transport: %w", err)
+	}
+	opts = opts.withDefaults()
+	frameLen := audio.FrameLen(format.Rate, opts.Frame)
+
+	s := &Session{
+		transport: t,
+		format:    format,
+		opts:      opts,
+		mu:        &sync.Mutex{},
+		in:        make([]float32, frameLen*format.Channels),
+		out:       make([]float32, frameLen*format.Channels),
+	}
+	s.inbound = audio.NewBargeIn(t, audio.BargeInOptions{
+		Threshold: opts.BargeInThreshold,
+		Window:    opts.Frame,
+		MinSpeech: opts.BargeInMinSpeech,
+		OnBargeIn: func() { s.barged = true },
+	})
+	return s, nil
+}
+
+// Format returns the format of the transport, which prompts must match.
+func (s *Session) Format() audio.Format { return s.format }
+
+// Elapsed returns the session time: the audio moved in each direction so
+// far.
+func (s *Session) Elapsed() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return audio.FramesDuration(s.frames, s.format.Rate)
+}
+
+// Playing reports whether a prompt is playing or queued.
+func (s *Session) Playing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.prompts) > 0
+}
+
+// Err returns the error that stopped the recording, if any.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Play queues src to play after the prompts already queued. With bargeIn
+// set the caller can interrupt it. src must have the rate and channel
+// count of the transport; it is closed once played or stopped.
+func (s *Session) Play(src audio.Source, bargeIn bool) error {
+	if err := audio.FormatOf(src).Compatible(s.format); err != nil {
+		return fmt.Errorf("prompt: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSessionClosed
+	}
+	s.prompts = append(s.prompts, prompt{src: src, bargeIn: bargeIn})
+	return nil
+}
+
+// Stop stops the playing prompt and drops the queued ones, closing them.
+func (s *Session) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stop()
+}
+
+func (s *Session) stop() error {
+	errs := make([]error, 0, len(s.prompts))
+	for _, p := range s.prompts {
+		errs = append(errs, p.src.Close())
+	}
+	s.prompts = nil
+	s.inbound.Disarm()
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
+
+// Run steps the session until the caller hangs up, returning nil when the
+// transport ends, or until Close, returning ErrSessionClosed. A transport
+// or prompt error ends Run too; the session can then be closed.
+func (s *Session) Run() error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrSessionRunning
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		err := s.Step()
+		if err == nil {
+			continue
+		}
+
+		s.mu.Lock()
+		closed := s.closed
+		s.mu.Unlock()
+		switch {
+		case closed:
+			// Closing the transport may have ended the read
+			return ErrSessionClosed
+		case errors.Is(err, io.EOF):
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// Step moves one frame each way: it sends the next frame of the playing
+// prompt, or silence, and reads one frame from the caller, records it and
+// passes it to the analysis. It returns io.EOF once the transport ends.
+// Run calls Step in a loop; Step is for callers that drive the session
+// from their own loop.
+func (s *Session) Step() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSessionClosed
+	}
+	err := s.fill(s.out)
+	if len(s.prompts) > 0 && s.prompts[0].bargeIn {
+		s.inbound.Arm()
+	} else {
+		s.inbound.Disarm()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := s.transport.WriteSamples(s.out); err != nil {
+		return fmt.Errorf("sending: %w", err)
+	}
+
+	n, err := s.readFrame()
+	if n == 0 {
+		return err
+	}
+	in := s.in[:n]
+
+	s.mu.Lock()
+	var (
+		event    *BargeIn
+		bargeErr error
+	)
+	if s.barged {
+		s.barged = false
+		event, bargeErr = s.bargeIn(n)
+	}
+	if s.opts.Record != nil && s.err == nil && !s.closed {
+		if werr := s.opts.Record.WriteSamples(in); werr != nil {
+			s.err = fmt.Errorf("recording: %w", werr)
+		}
+	}
+	s.frames += int64(n / s.format.Channels)
+	s.mu.Unlock()
+
+	if event != nil && s.opts.OnBargeIn != nil {
+		s.opts.OnBargeIn(*event)
+	}
+	if s.opts.Analyze != nil {
+		s.opts.Analyze(in)
+	}
+	if bargeErr != nil {
+		return bargeErr
+	}
+	return err
+}
+
+// fill writes the playing prompts into out, then silence
+func (s *Session) fill(out []float32) error {
+	ch := s.format.Channels
+	written := 0
+	for written < len(out) && len(s.prompts) > 0 {
+		cur := s.prompts[0]
+		n, err := cur.src.ReadSamples(out[written:])
+		n -= n % ch
+		written += n
+
+		if err != nil {
+			s.prompts = s.prompts[1:]
+			s.inbound.Disarm()
+			cerr := cur.src.Close()
+			if !errors.Is(err, io.EOF) {
+				return fmt.Errorf("prompt: %w", err)
+			}
+			if cerr != nil {
+				return fmt.Errorf("closing prompt: %w", cerr)
+			}
+			continue
+		}
+		if n == 0 {
+			// Nothing yet: play silence and try again next frame
+			break
+		}
+	}
+	clear(out[written:])
+	return nil
+}
+
+// readFrame reads a full frame from the transport unless it ends first
+func (s *Session) readFrame() (int, error) {
+	ch := s.format.Channels
+	n := 0
+	for n < len(s.in) {
+		r, err := s.inbound.ReadSamples(s.in[n:])
+		n += r
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return n - n%ch, io.EOF
+			}
+			return n - n%ch, fmt.Errorf("receiving: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// bargeIn stops the prompts after the caller spoke over the playing one
+// up to the end of the n samples just read
+func (s *Session) bargeIn(n int) (*BargeIn, error) {
+	if len(s.prompts) == 0 {
+		// Stopped meanwhile
+		return nil, nil
+	}
+
+	frames := s.frames + int64(n/s.format.Channels)
+	event := &BargeIn{
+		At:      audio.FramesDuration(frames, s.format.Rate),
+		Prompt:  s.prompts[0].src,
+		Dropped: len(s.prompts) - 1,
+	}
+	return event, s.stop()
+}
+
+// Close stops the session and closes the transport, the queued prompts and
+// the recording sink if it is an io.Closer. A Run in progress returns once
+// its current step is done.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	errs := []error{s.stop(), s.transport.Close()}
+	if c, ok := s.opts.Record.(io.Closer); ok {
+		errs = append(errs, c.Close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package session
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// fakeTransport receives inbound and keeps what is sent
+type fakeTransport struct {
+	inbound audio.Source
+
+	mu       sync.Mutex
+	sent     []float32
+	writeErr error
+	closed   bool
+}
+
+func (f *fakeTransport) SampleRate() int { return f.inbound.SampleRate() }
+func (f *fakeTransport) Channels() int   { return f.inbound.Channels() }
+func (f *fakeTransport) BufSize() int    { return f.inbound.BufSize() }
+
+func (f *fakeTransport) ReadSamples(dst []float32) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, io.ErrClosedPipe
+	}
+	return f.inbound.ReadSamples(dst)
+}
+
+func (f *fakeTransport) WriteSamples(samples []float32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.writeErr != nil {
+		return f.writeErr
+	}
+	f.sent = append(f.sent, samples...)
+	return nil
+}
+
+func (f *fakeTransport) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return nil
+}
+
+// sampleSink is a recording sink that can fail and tells when closed
+type sampleSink struct {
+	samples []float32
+	err     error
+	closed  bool
+}
+
+func (s *sampleSink) WriteSamples(samples []float32) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.samples = append(s.samples, samples...)
+	return nil
+}
+
+func (s *sampleSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// caller is an 8 kHz mono inbound stream, at 0.3 inside spans of sample
+// frames and at 0.01 (line noise) elsewhere
+func caller(frames int, spans ...[2]int) audio.Source {
+	return audiotest.NewMockSource(8000, 1, frames, func(sample, _ int) float32 {
+		for _, s := range spans {
+			if sample >= s[0] && sample < s[1] {
+				return 0.3
+			}
+		}
+		return 0.01
+	})
+}
+
+// clip is an 8 kHz mono prompt of frames at value
+func clip(frames int, value float32) *audiotest.FaultySource {
+	return audiotest.NewFaultySource(audiotest.NewConstantSource(8000, 1, frames, value))
+}
+
+// checkSent fails t unless sent holds want in [from, to)
+func checkSent(t *testing.T, sent []float32, from, to int, want float32) {
+	t.Helper()
+
+	for i := from; i < to; i++ {
+		if sent[i] != want {
+			t.Fatalf("sent sample %d = %v, want %v", i, sent[i], want)
+		}
+	}
+}
+
+func TestSession_PlayAndRecord(t *testing.T) {
+	t.Parallel()
+
+	tr := &fakeTransport{inbound: caller(800)}
+	sink := &sampleSink{}
+	var analyzed int
+	s, err := New(tr, Options{Record: sink, Analyze: func(frame []float32) { analyzed += len(frame) }})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	first, second := clip(240, 0.5), clip(160, 0.25)
+	for _, c := range []audio.Source{first, second} {
+		if err := s.Play(c, false); err != nil {
+			t.Fatalf("Play() error = %v", err)
+		}
+	}
+	if !s.Playing() {
+		t.Error("Playing() = false with queued prompts")
+	}
+
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Prompts back to back from the first frame, then silence
+	if len(tr.sent) < 800 {
+		t.Fatalf("sent %d samples, want at least 800", len(tr.sent))
+	}
+	checkSent(t, tr.sent, 0, 240, 0.5)
+	checkSent(t, tr.sent, 240, 400, 0.25)
+	checkSent(t, tr.sent, 400, 800, 0)
+
+	if len(sink.samples) != 800 || analyzed != 800 {
+		t.Errorf("recorded %d and analyzed %d samples, want 800", len(sink.samples), analyzed)
+	}
+	if got := s.Elapsed(); got != 100*time.Millisecond {
+		t.Errorf("Elapsed() = %v, want 100ms", got)
+	}
+	if !first.Closed() || !second.Closed() || s.Playing() {
+		t.Error("played prompts were not closed and dropped")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !tr.closed || !sink.closed {
+		t.Error("Close() did not close the transport and the sink")
+	}
+	if err := s.Play(clip(10, 1), false); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Play() after Close error = %v, want ErrSessionClosed", err)
+	}
+	if err := s.Step(); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Step() after Close error = %v, want ErrSessionClosed", err)
+	}
+}
+
+func TestSession_BargeIn(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		speech   [2]int
+		bargeIn  bool
+		wantStop int // sample frame the prompt stops at, 0 if it plays out
+	}{
+		// 200 ms of speech from 100 ms on: ten 20 ms frames
+		{name: "interrupted", speech: [2]int{800, 3200}, bargeIn: true, wantStop: 2400},
+		{name: "too short", speech: [2]int{800, 1600}, bargeIn: true},
+		{name: "not interruptible", speech: [2]int{800, 3200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tr := &fakeTransport{inbound: caller(8000, tt.speech)}
+			var events []BargeIn
+			s, err := New(tr, Options{OnBargeIn: func(e BargeIn) { events = append(events, e) }})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			menu, next := clip(4000, 0.5), clip(800, 0.25)
+			if err := s.Play(menu, tt.bargeIn); err != nil {
+				t.Fatalf("Play() error = %v", err)
+			}
+			if err := s.Play(next, tt.bargeIn); err != nil {
+				t.Fatalf("Play() error = %v", err)
+			}
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if tt.wantStop == 0 {
+				if len(events) != 0 {
+					t.Fatalf("barge-in events = %+v, want none", events)
+				}
+				checkSent(t, tr.sent, 0, 4000, 0.5)
+				checkSent(t, tr.sent, 4000, 4800, 0.25)
+				return
+			}
+
+			if len(events) != 1 {
+				t.Fatalf("got %d barge-in events, want 1", len(events))
+			}
+			e := events[0]
+			if want := audio.FramesDuration(int64(tt.wantStop), 8000); e.At != want {
+				t.Errorf("BargeIn.At = %v, want %v", e.At, want)
+			}
+			if e.Prompt != menu || e.Dropped != 1 {
+				t.Errorf("BargeIn = %+v, want the menu with 1 dropped", e)
+			}
+			if !menu.Closed() || !next.Closed() {
+				t.Error("interrupted prompts were not closed")
+			}
+			checkSent(t, tr.sent, 0, tt.wantStop, 0.5)
+			checkSent(t, tr.sent, tt.wantStop, 8000, 0)
+		})
+	}
+}
+
+func TestSession_Errors(t *testing.T) {
+	t.Parallel()
+
+	tr := &fakeTransport{inbound: caller(8000)}
+	sink := &sampleSink{err: audiotest.ErrInjected}
+	s, err := New(tr, Options{Record: sink})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := s.Play(audiotest.NewSilentSource(16000, 1, 10), false); !errors.Is(err, audio.ErrFormatMismatch) {
+		t.Errorf("Play(16 kHz) error = %v, want ErrFormatMismatch", err)
+	}
+
+	// A failing recording does not end the call
+	if err := s.Step(); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if err := s.Err(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Err() = %v, want ErrInjected", err)
+	}
+
+	tr.writeErr = audiotest.ErrInjected
+	if err := s.Run(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Run() with a failing transport error = %v, want ErrInjected", err)
+	}
+
+	bad := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 100), audiotest.WithErrorOnCall(1, audiotest.ErrInjected))
+	tr.writeErr = nil
+	if err := s.Play(bad, false); err != nil {
+		t.Fatalf("Play() error = %v", err)
+	}
+	if err := s.Step(); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Step() with a failing prompt error = %v, want ErrInjected", err)
+	}
+	if !bad.Closed() {
+		t.Error("failing prompt was not closed")
+	}
+}
+
+func TestSession_CloseWhileRunning(t *testing.T) {
+	t.Parallel()
+
+	tr := &fakeTransport{inbound: caller(8000 * 3600)}
+	s, err := New(tr, Options{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.Run() }()
+
+	waiting := clip(8000, 0.5)
+	for s.Elapsed() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Play(waiting, false); err != nil {
+		t.Fatalf("Play() error = %v", err)
+	}
+	if err := s.Run(); !errors.Is(err, ErrSessionRunning) {
+		t.Errorf("second Run() error = %v, want ErrSessionRunning", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if err := <-done; !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Run() error = %v, want ErrSessionClosed", err)
+	}
+	if !waiting.Closed() {
+		t.Error("Close() did not close the queued prompt")
+	}
+}
@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import "github.com/ik5/audpbx/audio"
+
+// Align maps the timestamps of results, from a transcript of the input of
+// edited, onto the timeline of edited, so that captions stay in sync with
+// audio whose silences were trimmed or shortened, or which was time
+// stretched (see audio.MapTime). Call it once edited was read to the end,
+// or at least past the results.
+//
+// A result partly cut keeps the part left, and one cut whole is dropped.
+// The results are returned in a new slice, in order.
+func Align(results []Result, edited audio.Source) []Result {
+	out := make([]Result, 0, len(results))
+	for _, r := range results {
+		start, startKept := audio.MapTime(edited, r.Start)
+		end, endKept := audio.MapTime(edited, r.End)
+		if !startKept && !endKept && start == end {
+			continue
+		}
+		r.Start, r.End = start, end
+		out = append(out, r)
+	}
+	return out
+}
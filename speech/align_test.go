@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestAlign(t *testing.T) {
+	t.Parallel()
+
+	// Words at 8 kHz over 0-500 ms and 1500-2000 ms, with a 1 s pause in
+	// between and 500 ms of silence after
+	words := func(i, _ int) float32 {
+		if i < 4000 || (i >= 12000 && i < 16000) {
+			return 0.5
+		}
+		return 0
+	}
+	ms := time.Millisecond
+	transcript := []Result{
+		{Text: "hello", Start: 0, End: 450 * ms, Final: true},
+		{Text: "um", Start: 800 * ms, End: ms * 1000},
+		{Text: "there", Start: 450 * ms, End: 1600 * ms},
+		{Text: "world", Start: 1500 * ms, End: 1900 * ms},
+	}
+
+	tests := []struct {
+		name string
+		edit func(audio.Source) (audio.Source, error)
+		want []Result
+	}{
+		{
+			name: "untouched",
+			edit: func(src audio.Source) (audio.Source, error) { return src, nil },
+			want: transcript,
+		},
+		{
+			// The pause keeps 150 ms at each end: 650 to 1350 ms is cut
+			name: "silence shortened",
+			edit: func(src audio.Source) (audio.Source, error) {
+				return audio.ShortenSilence(src, -40, 300*ms), nil
+			},
+			want: []Result{
+				{Text: "hello", Start: 0, End: 450 * ms, Final: true},
+				{Text: "there", Start: 450 * ms, End: 900 * ms},
+				{Text: "world", Start: 800 * ms, End: 1200 * ms},
+			},
+		},
+		{
+			name: "speed read",
+			edit: func(src audio.Source) (audio.Source, error) {
+				return audio.SpeedRead(src, audio.SpeedReadOptions{Speed: 1.25})
+			},
+			want: []Result{
+				{Text: "hello", Start: 0, End: 360 * ms, Final: true},
+				{Text: "there", Start: 360 * ms, End: 720 * ms},
+				{Text: "world", Start: 640 * ms, End: 960 * ms},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			edited, err := tt.edit(audiotest.NewMockSource(8000, 1, 20000, words))
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]float32, 512)
+			for {
+				if _, err := edited.ReadSamples(buf); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := Align(transcript, edited); !slices.Equal(got, tt.want) {
+				t.Errorf("Align() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/utils"
+)
+
+// Frame is a fixed-size chunk of mono 16-bit audio fed to a recognizer.
+type Frame struct {
+	// PCM holds exactly StreamConfig.FrameLen samples. The last frame of a
+	// stream is zero padded. The slice is reused after Accept returns.
+	PCM []int16
+	// Seq numbers the frames of a stream from 0.
+	Seq int64
+	// Timestamp is the stream time of the first sample.
+	Timestamp time.Duration
+}
+
+// StreamConfig is the audio format a recognizer wants.
+type StreamConfig struct {
+	// Rate is the sample rate in Hz, typically 8000 or 16000.
+	Rate int
+	// FrameDuration is the duration of each frame, typically 20 to 100 ms.
+	FrameDuration time.Duration
+}
+
+// FrameLen returns the number of samples per frame.
+func (c StreamConfig) FrameLen() int {
+	return audio.FrameLen(c.Rate, c.FrameDuration)
+}
+
+// Result is a recognition hypothesis. Recognizers deliver them in their own
+// way (channel, callback); the type only gives integrations a common shape.
+type Result struct {
+	Text       string
+	Start, End time.Duration
+	// Final is false for interim hypotheses that may still change.
+	Final      bool
+	Confidence float64
+	// Speaker is the diarization label, if the engine provides one.
+	Speaker string
+}
+
+// StreamingRecognizer is implemented by speech recognition engines such as
+// Whisper servers, Vosk or cloud streaming APIs.
+type StreamingRecognizer interface {
+	// Config returns the audio format the engine accepts.
+	Config() StreamConfig
+	// Accept sends one frame. Frames arrive in order and without gaps.
+	Accept(ctx context.Context, frame Frame) error
+	// Finish signals the end of the audio, so the engine can flush its
+	// final results.
+	Finish(ctx context.Context) error
+}
+
+// Recognize drives rec with the audio of src: it resamples and downmixes
+// src to the format rec asks for, cuts it into frames and feeds them in
+// order. It returns when src is exhausted and Finish returned, or on the
+// first error. Canceling ctx stops between frames.
+func Recognize(ctx context.Context, src audio.Source, rec StreamingRecognizer) error {
+	cfg := rec.Config()
+	frameLen := cfg.FrameLen()
+	if frameLen <= 0 {
+		return fmt.Errorf("%w: %d Hz, %v frames", audio.ErrInvalidSampleRate, cfg.Rate, cfg.FrameDuration)
+	}
+	if err := audio.FormatOf(src).Validate(); err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+
+	var pipeline audio.Source = src
+	if src.SampleRate() != cfg.Rate {
+		pipeline = audio.Resample(pipeline, cfg.Rate)
+	}
+	if src.Channels() != 1 {
+		pipeline = audio.NewMonoMixer(pipeline)
+	}
+
+	framer := audio.NewFramer(pipeline, frameLen)
+	frame := Frame{PCM: make([]int16, frameLen)}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		samples, _, err := framer.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading audio: %w", err)
+		}
+
+		for i, v := range samples {
+			frame.PCM[i] = utils.Float32ToInt16(v)
+		}
+		if err := rec.Accept(ctx, frame); err != nil {
+			return fmt.Errorf("recognizer: %w", err)
+		}
+
+		frame.Seq++
+		frame.Timestamp = time.Duration(frame.Seq) * time.Duration(frameLen) * time.Second / time.Duration(cfg.Rate)
+	}
+
+	if err := rec.Finish(ctx); err != nil {
+		return fmt.Errorf("recognizer: %w", err)
+	}
+	return nil
+}
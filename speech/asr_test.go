@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+// fakeRecognizer records what it is fed
+type fakeRecognizer struct {
+	cfg       StreamConfig
+	frames    []Frame
+	finished  bool
+	failAfter int
+	cancel    context.CancelFunc
+}
+
+func (f *fakeRecognizer) Config() StreamConfig { return f.cfg }
+
+func (f *fakeRecognizer) Accept(_ context.Context, frame Frame) error {
+	if f.failAfter > 0 && len(f.frames) == f.failAfter {
+		return errors.New("connection reset")
+	}
+	frame.PCM = slices.Clone(frame.PCM)
+	f.frames = append(f.frames, frame)
+	if f.cancel != nil && len(f.frames) == 2 {
+		f.cancel()
+	}
+	return nil
+}
+
+func (f *fakeRecognizer) Finish(context.Context) error {
+	f.finished = true
+	return nil
+}
+
+func TestRecognize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		rate       int
+		channels   int
+		frames     int
+		cfg        StreamConfig
+		wantFrames int
+	}{
+		{name: "matching format", rate: 16000, channels: 1, frames: 16000, cfg: StreamConfig{16000, 100 * time.Millisecond}, wantFrames: 10},
+		{name: "resample and downmix", rate: 44100, channels: 2, frames: 44100, cfg: StreamConfig{8000, 20 * time.Millisecond}, wantFrames: 50},
+		{name: "partial tail", rate: 8000, channels: 1, frames: 8100, cfg: StreamConfig{8000, 100 * time.Millisecond}, wantFrames: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := &fakeRecognizer{cfg: tt.cfg}
+			src := audiotest.NewSineSource(tt.rate, tt.channels, tt.frames, 440)
+			if err := Recognize(context.Background(), src, rec); err != nil {
+				t.Fatalf("Recognize() error = %v", err)
+			}
+
+			// Resampling may add or drop a frame's worth of samples at the end
+			if n := len(rec.frames); n < tt.wantFrames || n > tt.wantFrames+1 {
+				t.Fatalf("fed %d frames, want %d", n, tt.wantFrames)
+			}
+			for i, f := range rec.frames {
+				if len(f.PCM) != tt.cfg.FrameLen() {
+					t.Fatalf("frame %d has %d samples, want %d", i, len(f.PCM), tt.cfg.FrameLen())
+				}
+				if f.Seq != int64(i) || f.Timestamp != time.Duration(i)*tt.cfg.FrameDuration {
+					t.Errorf("frame %d: seq %d at %v, want %d at %v", i, f.Seq, f.Timestamp, i, time.Duration(i)*tt.cfg.FrameDuration)
+				}
+			}
+			if !rec.finished {
+				t.Error("Finish not called")
+			}
+		})
+	}
+}
+
+func TestRecognize_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("recognizer error", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &fakeRecognizer{cfg: StreamConfig{8000, 20 * time.Millisecond}, failAfter: 3}
+		err := Recognize(context.Background(), audiotest.NewSilentSource(8000, 1, 8000), rec)
+		if err == nil || rec.finished {
+			t.Errorf("Recognize() error = %v, finished = %v", err, rec.finished)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		rec := &fakeRecognizer{cfg: StreamConfig{8000, 20 * time.Millisecond}, cancel: cancel}
+		err := Recognize(ctx, audiotest.NewSilentSource(8000, 1, 8000), rec)
+		if !errors.Is(err, context.Canceled) || len(rec.frames) != 2 {
+			t.Errorf("Recognize() error = %v after %d frames, want context.Canceled after 2", err, len(rec.frames))
+		}
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		t.Parallel()
+
+		src := audiotest.NewFaultySource(audiotest.NewSilentSource(8000, 1, 8000), audiotest.WithShortReads(100), audiotest.WithErrorOnCall(5, audiotest.ErrInjected))
+		rec := &fakeRecognizer{cfg: StreamConfig{8000, 20 * time.Millisecond}}
+		if err := Recognize(context.Background(), src, rec); !errors.Is(err, audiotest.ErrInjected) {
+			t.Errorf("Recognize() error = %v, want %v", err, audiotest.ErrInjected)
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &fakeRecognizer{cfg: StreamConfig{8000, 0}}
+		if err := Recognize(context.Background(), audiotest.NewSilentSource(8000, 1, 10), rec); err == nil {
+			t.Error("Recognize() error = nil for a zero frame duration")
+		}
+	})
+}
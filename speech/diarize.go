@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+)
+
+// Segment is a span of the timeline attributed to one speaker.
+type Segment struct {
+	Start, End time.Duration
+	// Speaker is the label assigned by the diarizer, e.g. "spk0". Labels
+	// are only meaningful within one timeline.
+	Speaker string
+}
+
+// Duration returns the length of the segment.
+func (s Segment) Duration() time.Duration { return s.End - s.Start }
+
+// Diarizer is implemented by speaker diarization engines (a pyannote
+// service, an x-vector clustering library, ...). It receives the feature
+// frames of a whole recording and returns who spoke when. Segments may be
+// returned in any order and may overlap for crosstalk.
+type Diarizer interface {
+	Diarize(ctx context.Context, frames []FeatureFrame) ([]Segment, error)
+}
+
+// DiarizerFunc adapts a function to the Diarizer interface.
+type DiarizerFunc func(ctx context.Context, frames []FeatureFrame) ([]Segment, error)
+
+func (f DiarizerFunc) Diarize(ctx context.Context, frames []FeatureFrame) ([]Segment, error) {
+	return f(ctx, frames)
+}
+
+// Timeline is a sorted list of speaker segments.
+type Timeline []Segment
+
+// Diarize extracts MFCC features from src, typically a mono recording with
+// both parties of a call mixed together, and lets d attribute them to
+// speakers. The result is sorted by start time, with touching or
+// overlapping segments of the same speaker merged.
+func Diarize(ctx context.Context, src audio.Source, d Diarizer, opts MFCCOptions) (Timeline, error) {
+	frames, err := Features(src, opts)
+	if err != nil {
+		return nil, fmt.Errorf("extracting features: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	segments, err := d.Diarize(ctx, frames)
+	if err != nil {
+		return nil, fmt.Errorf("diarizer: %w", err)
+	}
+
+	return NewTimeline(segments)
+}
+
+// NewTimeline validates and normalizes segments into a Timeline.
+func NewTimeline(segments []Segment) (Timeline, error) {
+	tl := make(Timeline, 0, len(segments))
+	for _, s := range segments {
+		if s.Start < 0 || s.End < s.Start || s.Speaker == "" {
+			return nil, fmt.Errorf("%w: %q %v-%v", ErrInvalidSegment, s.Speaker, s.Start, s.End)
+		}
+		if s.End > s.Start {
+			tl = append(tl, s)
+		}
+	}
+
+	slices.SortStableFunc(tl, func(a, b Segment) int {
+		return cmp.Or(cmp.Compare(a.Start, b.Start), cmp.Compare(a.Speaker, b.Speaker))
+	})
+
+	// Merge each segment into the latest one of the same speaker it touches
+	merged := tl[:0]
+	last := make(map[string]int)
+	for _, s := range tl {
+		if i, ok := last[s.Speaker]; ok && s.Start <= merged[i].End {
+			merged[i].End = max(merged[i].End, s.End)
+			continue
+		}
+		last[s.Speaker] = len(merged)
+		merged = append(merged, s)
+	}
+
+	return merged, nil
+}
+
+// Speakers returns the speaker labels in order of first appearance.
+func (tl Timeline) Speakers() []string {
+	var out []string
+	for _, s := range tl {
+		if !slices.Contains(out, s.Speaker) {
+			out = append(out, s.Speaker)
+		}
+	}
+	return out
+}
+
+// SpeakersAt returns the labels of everyone speaking at t, usually one.
+func (tl Timeline) SpeakersAt(t time.Duration) []string {
+	var out []string
+	for _, s := range tl {
+		if s.Start > t {
+			break
+		}
+		if t < s.End {
+			out = append(out, s.Speaker)
+		}
+	}
+	return out
+}
+
+// TalkTime returns the total speaking time per speaker.
+func (tl Timeline) TalkTime() map[string]time.Duration {
+	out := make(map[string]time.Duration)
+	for _, s := range tl {
+		out[s.Speaker] += s.Duration()
+	}
+	return out
+}
+
+// Annotate sets the Speaker of every result that has none to the speaker
+// overlapping it the longest, so transcripts from a StreamingRecognizer
+// can be attributed after the fact.
+func (tl Timeline) Annotate(results []Result) {
+	for i := range results {
+		r := &results[i]
+		if r.Speaker != "" {
+			continue
+		}
+
+		overlap := make(map[string]time.Duration)
+		best := time.Duration(0)
+		for _, s := range tl {
+			if s.Start >= r.End {
+				break
+			}
+			o := min(s.End, r.End) - max(s.Start, r.Start)
+			if o <= 0 {
+				continue
+			}
+			overlap[s.Speaker] += o
+			if overlap[s.Speaker] > best {
+				best, r.Speaker = overlap[s.Speaker], s.Speaker
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+const ms = time.Millisecond
+
+func TestNewTimeline(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		in      []Segment
+		want    Timeline
+		wantErr error
+	}{
+		{
+			name: "sorted and merged",
+			in: []Segment{
+				{Start: 500 * ms, End: 900 * ms, Speaker: "b"},
+				{Start: 0, End: 300 * ms, Speaker: "a"},
+				{Start: 300 * ms, End: 450 * ms, Speaker: "a"},
+				{Start: 800 * ms, End: 1200 * ms, Speaker: "b"},
+			},
+			want: Timeline{
+				{Start: 0, End: 450 * ms, Speaker: "a"},
+				{Start: 500 * ms, End: 1200 * ms, Speaker: "b"},
+			},
+		},
+		{
+			name: "crosstalk kept apart",
+			in: []Segment{
+				{Start: 0, End: 1000 * ms, Speaker: "a"},
+				{Start: 400 * ms, End: 600 * ms, Speaker: "b"},
+				{Start: 900 * ms, End: 1500 * ms, Speaker: "a"},
+			},
+			want: Timeline{
+				{Start: 0, End: 1500 * ms, Speaker: "a"},
+				{Start: 400 * ms, End: 600 * ms, Speaker: "b"},
+			},
+		},
+		{
+			name: "empty segments dropped",
+			in:   []Segment{{Start: 100 * ms, End: 100 * ms, Speaker: "a"}},
+			want: Timeline{},
+		},
+		{name: "negative start", in: []Segment{{Start: -ms, End: ms, Speaker: "a"}}, wantErr: ErrInvalidSegment},
+		{name: "end before start", in: []Segment{{Start: 2 * ms, End: ms, Speaker: "a"}}, wantErr: ErrInvalidSegment},
+		{name: "no speaker", in: []Segment{{Start: 0, End: ms}}, wantErr: ErrInvalidSegment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewTimeline(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewTimeline() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !slices.Equal(got, tt.want) {
+				t.Errorf("NewTimeline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeline_Queries(t *testing.T) {
+	t.Parallel()
+
+	tl, err := NewTimeline([]Segment{
+		{Start: 0, End: 1000 * ms, Speaker: "agent"},
+		{Start: 900 * ms, End: 2500 * ms, Speaker: "caller"},
+		{Start: 2600 * ms, End: 3000 * ms, Speaker: "agent"},
+	})
+	if err != nil {
+		t.Fatalf("NewTimeline() error = %v", err)
+	}
+
+	if got := tl.Speakers(); !slices.Equal(got, []string{"agent", "caller"}) {
+		t.Errorf("Speakers() = %v", got)
+	}
+
+	for _, tc := range []struct {
+		at   time.Duration
+		want []string
+	}{
+		{500 * ms, []string{"agent"}},
+		{950 * ms, []string{"agent", "caller"}},
+		{2550 * ms, nil},
+		{3000 * ms, nil},
+	} {
+		if got := tl.SpeakersAt(tc.at); !slices.Equal(got, tc.want) {
+			t.Errorf("SpeakersAt(%v) = %v, want %v", tc.at, got, tc.want)
+		}
+	}
+
+	talk := tl.TalkTime()
+	if talk["agent"] != 1400*ms || talk["caller"] != 1600*ms {
+		t.Errorf("TalkTime() = %v", talk)
+	}
+
+	results := []Result{
+		{Text: "hello", Start: 100 * ms, End: 800 * ms},
+		{Text: "hi there", Start: 850 * ms, End: 2000 * ms},
+		{Text: "bye", Start: 2600 * ms, End: 2900 * ms, Speaker: "engine"},
+		{Text: "noise", Start: 3100 * ms, End: 3200 * ms},
+	}
+	tl.Annotate(results)
+	for i, want := range []string{"agent", "caller", "engine", ""} {
+		if results[i].Speaker != want {
+			t.Errorf("result %q speaker = %q, want %q", results[i].Text, results[i].Speaker, want)
+		}
+	}
+}
+
+func TestDiarize(t *testing.T) {
+	t.Parallel()
+
+	// A low voice for the first second and a high one for the next; the
+	// fake diarizer splits frames on the spectral tilt carried by c1
+	src := audiotest.NewMockSource(16000, 1, 32000, func(sample, _ int) float32 {
+		freq := 200.0
+		if sample >= 16000 {
+			freq = 3000
+		}
+		return float32(0.5 * math.Sin(2*math.Pi*freq*float64(sample)/16000))
+	})
+
+	var got []FeatureFrame
+	d := DiarizerFunc(func(_ context.Context, frames []FeatureFrame) ([]Segment, error) {
+		got = frames
+		var segs []Segment
+		for _, f := range frames {
+			spk := "low"
+			if f.MFCC[1] < 0 {
+				spk = "high"
+			}
+			segs = append(segs, Segment{Start: f.Timestamp, End: f.Timestamp + 25*ms, Speaker: spk})
+		}
+		return segs, nil
+	})
+
+	tl, err := Diarize(context.Background(), src, d, MFCCOptions{})
+	if err != nil {
+		t.Fatalf("Diarize() error = %v", err)
+	}
+	if len(got) != 198 {
+		t.Errorf("diarizer got %d frames, want 198", len(got))
+	}
+	if len(tl) != 2 || tl[0].Speaker != "low" || tl[1].Speaker != "high" {
+		t.Fatalf("Diarize() = %v, want low then high", tl)
+	}
+	// The last whole 25 ms window starts at 1.97 s
+	if tl[0].Start != 0 || tl[1].End != 1995*ms {
+		t.Errorf("timeline spans %v-%v, want 0s-1.995s", tl[0].Start, tl[1].End)
+	}
+	if d := tl[1].Start - time.Second; d < -30*ms || d > 30*ms {
+		t.Errorf("speaker change at %v, want about 1s", tl[1].Start)
+	}
+}
+
+func TestDiarize_Errors(t *testing.T) {
+	t.Parallel()
+
+	failing := DiarizerFunc(func(context.Context, []FeatureFrame) ([]Segment, error) {
+		return nil, audiotest.ErrInjected
+	})
+	if _, err := Diarize(context.Background(), audiotest.NewSilentSource(8000, 1, 8000), failing, MFCCOptions{}); !errors.Is(err, audiotest.ErrInjected) {
+		t.Errorf("Diarize() error = %v, want %v", err, audiotest.ErrInjected)
+	}
+
+	bad := DiarizerFunc(func(context.Context, []FeatureFrame) ([]Segment, error) {
+		return []Segment{{Start: 0, End: time.Second}}, nil
+	})
+	if _, err := Diarize(context.Background(), audiotest.NewSilentSource(8000, 1, 8000), bad, MFCCOptions{}); !errors.Is(err, ErrInvalidSegment) {
+		t.Errorf("Diarize() error = %v, want ErrInvalidSegment", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Diarize(ctx, audiotest.NewSilentSource(8000, 1, 8000), failing, MFCCOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Diarize() error = %v, want context.Canceled", err)
+	}
+}
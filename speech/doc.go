@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: EPL-2.0
+
+// Package speech connects external speech engines to audio pipelines.
+//
+// # Text to Speech
+//
+// A Synthesizer wraps a TTS engine (a cloud API, a local Piper or eSpeak
+// process, ...) that returns raw PCM. Speak turns its output into an
+// audio.Source at the rate the engine declared, so synthesized prompts go
+// through the same resample, mix and encode steps as prompts read from
+// files:
+//
+//	src, err := speech.Speak(ctx, engine, speech.Request{Text: "Please hold"})
+//	if err != nil {
+//	    return err
+//	}
+//	defer src.Close()
+//	pcm16, rate, err := audpbx.ResampleToMono16(src, 8000, 4096)
+//
+// Engines that return a container (WAV, MP3, Ogg) rather than raw PCM can
+// decode it with the matching formats package inside their Synthesize and
+// hand the result over with SourceSynthesis.
+//
+// # Streaming Recognition
+//
+// A StreamingRecognizer wraps an ASR engine (Whisper, Vosk, a cloud
+// streaming API) that accepts fixed-size frames of mono 16-bit PCM. Its
+// Config names the rate and frame duration it wants; Recognize resamples,
+// downmixes and frames any Source to match and feeds it, so integrations
+// only implement the engine side:
+//
+//	err := speech.Recognize(ctx, callLeg, engine)
+//
+// Results are delivered by the engine in whatever way suits it; Result is
+// the common shape for them.
+//
+// # Speaker Diarization
+//
+// FeatureExtractor turns a Source into MFCC frames with timestamps, the
+// usual input of speaker diarization. A Diarizer plugs in the engine that
+// clusters them; Diarize runs both over a recording (typically a mono mix
+// of both call parties) and returns a Timeline of speaker segments:
+//
+//	tl, err := speech.Diarize(ctx, recording, engine, speech.MFCCOptions{})
+//	if err != nil {
+//	    return err
+//	}
+//	tl.Annotate(transcript) // fill Result.Speaker
+//
+// # Aligning Transcripts
+//
+// A transcript made from the original recording drifts from an edited
+// version of it, with its silences trimmed or shortened or played faster.
+// Align maps its timestamps onto the edited audio, once it was read, so
+// captions stay in sync:
+//
+//	fast, err := audio.SpeedRead(recording, audio.SpeedReadOptions{Speed: 1.25})
+//	err = wav.Encode(out, fast)
+//	captions := speech.Align(transcript, fast)
+package speech
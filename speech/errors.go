@@ -0,0 +1,13 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import "errors"
+
+var (
+	ErrEmptyText = errors.New("nothing to synthesize")
+	ErrNoAudio   = errors.New("synthesizer returned no audio")
+
+	ErrInvalidFeatureOptions = errors.New("invalid feature extraction options")
+	ErrInvalidSegment        = errors.New("invalid speaker segment")
+)
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/utils"
+)
+
+// MFCCOptions configures a FeatureExtractor. Zero values select the
+// defaults used by most speaker diarization and recognition front ends.
+type MFCCOptions struct {
+	// FrameDuration is the analysis window, 25 ms by default.
+	FrameDuration time.Duration
+	// Hop is the distance between frame starts, 10 ms by default.
+	Hop time.Duration
+	// Filters is the number of mel filters, 26 by default.
+	Filters int
+	// Coefficients is the number of cepstral coefficients kept, 13 by
+	// default. It cannot exceed Filters.
+	Coefficients int
+	// LowFreq and HighFreq bound the filter bank in Hz; HighFreq defaults
+	// to half the sample rate.
+	LowFreq  float64
+	HighFreq float64
+	// PreEmphasis is the first order high-pass coefficient, 0.97 by
+	// default. Negative disables it.
+	PreEmphasis float64
+}
+
+func (o MFCCOptions) withDefaults(rate int) MFCCOptions {
+	if o.FrameDuration <= 0 {
+		o.FrameDuration = 25 * time.Millisecond
+	}
+	if o.Hop <= 0 {
+		o.Hop = 10 * time.Millisecond
+	}
+	if o.Filters <= 0 {
+		o.Filters = 26
+	}
+	if o.Coefficients <= 0 {
+		o.Coefficients = 13
+	}
+	if o.HighFreq <= 0 || o.HighFreq > float64(rate)/2 {
+		o.HighFreq = float64(rate) / 2
+	}
+	if o.PreEmphasis == 0 {
+		o.PreEmphasis = 0.97
+	}
+	o.PreEmphasis = max(o.PreEmphasis, 0)
+	return o
+}
+
+// FeatureFrame is the feature vector of one analysis window.
+type FeatureFrame struct {
+	// Timestamp is the stream time of the first sample of the window.
+	Timestamp time.Duration
+	// MFCC holds the cepstral coefficients, c0 first.
+	MFCC []float64
+	// LogEnergy is the natural log of the window energy, handy for
+	// telling speech from silence.
+	LogEnergy float64
+}
+
+// FeatureExtractor computes MFCC frames from a Source. Multi-channel
+// sources are downmixed to mono first; the sample rate is kept.
+type FeatureExtractor struct {
+	framer   *audio.Framer
+	rate     int
+	frameLen int
+	hop      int
+	coeffs   int
+	emphasis float32
+
+	buf     []float32 // samples not yet consumed by a full window
+	prev    float32   // sample before buf[0], for pre-emphasis
+	eof     bool
+	frames  int64
+	window  []float64
+	filters [][]float64 // per filter weights over the FFT bins
+	first   []int       // first FFT bin of each filter
+	dct     [][]float64
+	spec    []complex128
+	logMel  []float64
+}
+
+// NewFeatureExtractor creates a FeatureExtractor over src.
+func NewFeatureExtractor(src audio.Source, opts MFCCOptions) (*FeatureExtractor, error) {
+	if err := audio.FormatOf(src).Validate(); err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+
+	rate := src.SampleRate()
+	opts = opts.withDefaults(rate)
+	frameLen := audio.FrameLen(rate, opts.FrameDuration)
+	hop := audio.FrameLen(rate, opts.Hop)
+	if frameLen < 2 || hop < 1 || hop > frameLen || opts.Coefficients > opts.Filters || opts.LowFreq >= opts.HighFreq {
+		return nil, fmt.Errorf("%w: %+v at %d Hz", ErrInvalidFeatureOptions, opts, rate)
+	}
+
+	if src.Channels() != 1 {
+		src = audio.NewMonoMixer(src)
+	}
+
+	fftSize := utils.NextPowerOfTwo(frameLen)
+	e := &FeatureExtractor{
+		framer:   audio.NewFramer(src, hop),
+		rate:     rate,
+		frameLen: frameLen,
+		hop:      hop,
+		coeffs:   opts.Coefficients,
+		emphasis: float32(opts.PreEmphasis),
+		window:   make([]float64, frameLen),
+		spec:     make([]complex128, fftSize),
+		logMel:   make([]float64, opts.Filters),
+	}
+
+	// Hamming window
+	for i := range e.window {
+		e.window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(frameLen-1))
+	}
+
+	e.first, e.filters = melFilterBank(opts.Filters, fftSize, rate, opts.LowFreq, opts.HighFreq)
+
+	// DCT-II, orthonormal
+	e.dct = make([][]float64, opts.Coefficients)
+	n := float64(opts.Filters)
+	for k := range e.dct {
+		scale := math.Sqrt(2 / n)
+		if k == 0 {
+			scale = math.Sqrt(1 / n)
+		}
+		e.dct[k] = make([]float64, opts.Filters)
+		for i := range e.dct[k] {
+			e.dct[k][i] = scale * math.Cos(math.Pi*float64(k)*(float64(i)+0.5)/n)
+		}
+	}
+
+	return e, nil
+}
+
+// melFilterBank returns triangular filters equally spaced on the mel scale
+// between low and high Hz, as the first bin and weights of each filter
+func melFilterBank(filters, fftSize, rate int, low, high float64) ([]int, [][]float64) {
+	mel := func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) }
+	hz := func(m float64) float64 { return 700 * (math.Pow(10, m/2595) - 1) }
+
+	lowMel, highMel := mel(low), mel(high)
+	edges := make([]float64, filters+2) // in fractional FFT bins
+	for i := range edges {
+		m := lowMel + (highMel-lowMel)*float64(i)/float64(filters+1)
+		edges[i] = hz(m) * float64(fftSize) / float64(rate)
+	}
+
+	first := make([]int, filters)
+	weights := make([][]float64, filters)
+	for f := range filters {
+		left, center, right := edges[f], edges[f+1], edges[f+2]
+		first[f] = int(math.Ceil(left))
+		for bin := first[f]; float64(bin) <= right && bin <= fftSize/2; bin++ {
+			var w float64
+			if float64(bin) <= center {
+				w = (float64(bin) - left) / max(center-left, 1e-9)
+			} else {
+				w = (right - float64(bin)) / max(right-center, 1e-9)
+			}
+			weights[f] = append(weights[f], max(w, 0))
+		}
+	}
+
+	return first, weights
+}
+
+// SampleRate returns the rate the features were computed at.
+func (e *FeatureExtractor) SampleRate() int { return e.rate }
+
+// Next returns the features of the next window. Windows advance by the hop;
+// audio at the end too short for a whole window is dropped. It returns
+// io.EOF when the source is exhausted.
+func (e *FeatureExtractor) Next() (FeatureFrame, error) {
+	for len(e.buf) < e.frameLen {
+		if e.eof {
+			return FeatureFrame{}, io.EOF
+		}
+		hop, valid, err := e.framer.Next()
+		if errors.Is(err, io.EOF) {
+			e.eof = true
+			continue
+		}
+		if err != nil {
+			return FeatureFrame{}, fmt.Errorf("%w", err)
+		}
+		e.buf = append(e.buf, hop[:valid]...)
+	}
+
+	frame := FeatureFrame{
+		Timestamp: time.Duration(e.frames) * time.Duration(e.hop) * time.Second / time.Duration(e.rate),
+		MFCC:      make([]float64, e.coeffs),
+	}
+
+	// Pre-emphasis, window and energy
+	var energy float64
+	prev := e.prev
+	for i, v := range e.buf[:e.frameLen] {
+		x := float64(v - e.emphasis*prev)
+		prev = v
+		energy += float64(v) * float64(v)
+		e.spec[i] = complex(x*e.window[i], 0)
+	}
+	clear(e.spec[e.frameLen:])
+	frame.LogEnergy = math.Log(max(energy, 1e-10))
+
+	utils.FFT(e.spec)
+	for f, weights := range e.filters {
+		var p float64
+		for j, w := range weights {
+			a := cmplx.Abs(e.spec[e.first[f]+j])
+			p += w * a * a
+		}
+		e.logMel[f] = math.Log(max(p, 1e-10))
+	}
+	for k, row := range e.dct {
+		for i, w := range row {
+			frame.MFCC[k] += w * e.logMel[i]
+		}
+	}
+
+	// Slide by one hop
+	e.prev = e.buf[e.hop-1]
+	e.buf = append(e.buf[:0], e.buf[e.hop:]...)
+	e.frames++
+
+	return frame, nil
+}
+
+// Features reads src to the end and returns all its feature frames.
+func Features(src audio.Source, opts MFCCOptions) ([]FeatureFrame, error) {
+	e, err := NewFeatureExtractor(src, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []FeatureFrame
+	for {
+		f, err := e.Next()
+		if errors.Is(err, io.EOF) {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, f)
+	}
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ik5/audpbx/audio/audiotest"
+)
+
+func TestFeatures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		rate       int
+		channels   int
+		frames     int
+		opts       MFCCOptions
+		wantFrames int
+		wantCoeffs int
+		wantHop    time.Duration
+	}{
+		{name: "defaults 16 kHz", rate: 16000, channels: 1, frames: 16000, wantFrames: 98, wantCoeffs: 13, wantHop: 10 * time.Millisecond},
+		{name: "telephony stereo", rate: 8000, channels: 2, frames: 8000, wantFrames: 98, wantCoeffs: 13, wantHop: 10 * time.Millisecond},
+		{
+			name: "custom", rate: 16000, channels: 1, frames: 8000,
+			opts:       MFCCOptions{FrameDuration: 32 * time.Millisecond, Hop: 16 * time.Millisecond, Filters: 40, Coefficients: 20, LowFreq: 100, HighFreq: 7000},
+			wantFrames: 30, wantCoeffs: 20, wantHop: 16 * time.Millisecond,
+		},
+		{name: "shorter than a frame", rate: 16000, channels: 1, frames: 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			frames, err := Features(audiotest.NewSineSource(tt.rate, tt.channels, tt.frames, 440), tt.opts)
+			if err != nil {
+				t.Fatalf("Features() error = %v", err)
+			}
+			if len(frames) != tt.wantFrames {
+				t.Fatalf("Features() = %d frames, want %d", len(frames), tt.wantFrames)
+			}
+			for i, f := range frames {
+				if f.Timestamp != time.Duration(i)*tt.wantHop {
+					t.Fatalf("frame %d at %v, want %v", i, f.Timestamp, time.Duration(i)*tt.wantHop)
+				}
+				if len(f.MFCC) != tt.wantCoeffs {
+					t.Fatalf("frame %d has %d coefficients, want %d", i, len(f.MFCC), tt.wantCoeffs)
+				}
+				for k, c := range f.MFCC {
+					if math.IsNaN(c) || math.IsInf(c, 0) {
+						t.Fatalf("frame %d c%d = %v", i, k, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFeatures_GainInvariance(t *testing.T) {
+	t.Parallel()
+
+	// Scaling the signal adds a constant to every log mel energy, which
+	// the DCT moves entirely into c0
+	loud, err := Features(audiotest.NewSineSource(16000, 1, 4000, 700), MFCCOptions{})
+	if err != nil {
+		t.Fatalf("Features() error = %v", err)
+	}
+	quiet, err := Features(audiotest.NewMockSource(16000, 1, 4000, func(sample, _ int) float32 {
+		return float32(0.25 * math.Sin(2*math.Pi*700*float64(sample)/16000))
+	}), MFCCOptions{})
+	if err != nil {
+		t.Fatalf("Features() error = %v", err)
+	}
+
+	// NewSineSource renders at full scale, 16 times the power
+	wantC0 := math.Sqrt(26) * math.Log(16)
+	for i := range loud {
+		if d := loud[i].MFCC[0] - quiet[i].MFCC[0]; math.Abs(d-wantC0) > 1e-3 {
+			t.Fatalf("frame %d: c0 difference = %v, want %v", i, d, wantC0)
+		}
+		for k := 1; k < len(loud[i].MFCC); k++ {
+			if d := loud[i].MFCC[k] - quiet[i].MFCC[k]; math.Abs(d) > 1e-3 {
+				t.Fatalf("frame %d: c%d differs by %v", i, k, d)
+			}
+		}
+		if d := loud[i].LogEnergy - quiet[i].LogEnergy; math.Abs(d-math.Log(16)) > 1e-3 {
+			t.Fatalf("frame %d: log energy difference = %v, want ln 16", i, d)
+		}
+	}
+}
+
+func TestFeatures_Discriminates(t *testing.T) {
+	t.Parallel()
+
+	distance := func(a, b []float64) float64 {
+		var d float64
+		for k := 1; k < len(a); k++ {
+			d += (a[k] - b[k]) * (a[k] - b[k])
+		}
+		return math.Sqrt(d)
+	}
+
+	low, _ := Features(audiotest.NewSineSource(16000, 1, 4000, 200), MFCCOptions{})
+	low2, _ := Features(audiotest.NewSineSource(16000, 1, 4000, 210), MFCCOptions{})
+	high, _ := Features(audiotest.NewSineSource(16000, 1, 4000, 3000), MFCCOptions{})
+
+	same, diff := distance(low[10].MFCC, low2[10].MFCC), distance(low[10].MFCC, high[10].MFCC)
+	if diff < 4*same {
+		t.Errorf("distance 200/210 Hz = %v, 200/3000 Hz = %v, want the latter far larger", same, diff)
+	}
+}
+
+func TestNewFeatureExtractor_InvalidOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		rate int
+		opts MFCCOptions
+	}{
+		{"more coefficients than filters", 16000, MFCCOptions{Filters: 10, Coefficients: 13}},
+		{"hop longer than frame", 16000, MFCCOptions{FrameDuration: 10 * time.Millisecond, Hop: 20 * time.Millisecond}},
+		{"empty band", 16000, MFCCOptions{LowFreq: 9000}},
+		{"frame too short", 16000, MFCCOptions{FrameDuration: time.Microsecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewFeatureExtractor(audiotest.NewSilentSource(tt.rate, 1, 100), tt.opts)
+			if !errors.Is(err, ErrInvalidFeatureOptions) {
+				t.Errorf("NewFeatureExtractor() error = %v, want ErrInvalidFeatureOptions", err)
+			}
+		})
+	}
+
+	if _, err := NewFeatureExtractor(audiotest.NewSilentSource(0, 1, 100), MFCCOptions{}); err == nil {
+		t.Error("NewFeatureExtractor() error = nil for a 0 Hz source")
+	}
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/pcm"
+)
+
+// Request describes what to synthesize.
+type Request struct {
+	// Text is the text to speak, or an SSML document when SSML is set.
+	Text string
+	SSML bool
+	// Voice and Language are engine specific names such as "en-US-Wavenet-D"
+	// and "en-US". Empty values select the engine defaults.
+	Voice    string
+	Language string
+	// Rate is the preferred output sample rate; engines may ignore it and
+	// declare a different one in their Synthesis.
+	Rate int
+}
+
+// Synthesis is the audio produced by a Synthesizer. Either Body with its
+// PCM description or Source is set.
+type Synthesis struct {
+	// Body is the raw PCM stream. It is closed with the returned Source.
+	Body     io.ReadCloser
+	Rate     int
+	Channels int
+	Encoding pcm.Encoding
+
+	// Source is already decoded audio, for engines that return a container.
+	Source audio.Source
+}
+
+// PCMSynthesis describes raw PCM output of an engine.
+func PCMSynthesis(body io.ReadCloser, rate, channels int, enc pcm.Encoding) *Synthesis {
+	return &Synthesis{Body: body, Rate: rate, Channels: channels, Encoding: enc}
+}
+
+// SourceSynthesis wraps already decoded engine output.
+func SourceSynthesis(src audio.Source) *Synthesis {
+	return &Synthesis{Source: src}
+}
+
+// Synthesizer is implemented by text-to-speech engines.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, req Request) (*Synthesis, error)
+}
+
+// SynthesizerFunc adapts a function to the Synthesizer interface.
+type SynthesizerFunc func(ctx context.Context, req Request) (*Synthesis, error)
+
+func (f SynthesizerFunc) Synthesize(ctx context.Context, req Request) (*Synthesis, error) {
+	return f(ctx, req)
+}
+
+// Speak synthesizes req with s and returns the speech as an audio.Source at
+// the rate declared by the engine. Closing the Source releases the engine's
+// stream.
+func Speak(ctx context.Context, s Synthesizer, req Request) (audio.Source, error) {
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, ErrEmptyText
+	}
+
+	syn, err := s.Synthesize(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("synthesizing: %w", err)
+	}
+	if syn == nil {
+		return nil, ErrNoAudio
+	}
+
+	if syn.Source != nil {
+		return syn.Source, nil
+	}
+	if syn.Body == nil {
+		return nil, ErrNoAudio
+	}
+
+	src, err := pcm.NewSource(syn.Body, syn.Rate, syn.Channels, syn.Encoding)
+	if err != nil {
+		_ = syn.Body.Close()
+		return nil, fmt.Errorf("synthesizer output: %w", err)
+	}
+
+	return src, nil
+}
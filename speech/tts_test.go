@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package speech
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+	"github.com/ik5/audpbx/formats/pcm"
+)
+
+// closeTracker records whether the engine stream was closed
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestSpeak_PCM(t *testing.T) {
+	t.Parallel()
+
+	body := &closeTracker{Reader: bytes.NewReader([]byte{0x00, 0x40, 0x00, 0xc0, 0x00, 0x00})}
+	var got Request
+	engine := SynthesizerFunc(func(_ context.Context, req Request) (*Synthesis, error) {
+		got = req
+		return PCMSynthesis(body, 22050, 1, pcm.S16LE), nil
+	})
+
+	req := Request{Text: "Please hold", Voice: "test", Rate: 8000}
+	src, err := Speak(context.Background(), engine, req)
+	if err != nil {
+		t.Fatalf("Speak() error = %v", err)
+	}
+	if got != req {
+		t.Errorf("engine got %+v, want %+v", got, req)
+	}
+
+	if f := audio.FormatOf(src); f.Rate != 22050 || f.Channels != 1 || f.SampleKind != audio.SampleInt16 {
+		t.Errorf("format = %v, want the declared 22050 Hz mono s16", f)
+	}
+
+	buf := make([]float32, 8)
+	n, _ := src.ReadSamples(buf)
+	if n != 3 || buf[0] != 0.5 || buf[1] != -0.5 {
+		t.Errorf("ReadSamples() = %v", buf[:n])
+	}
+
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !body.closed {
+		t.Error("engine stream not closed")
+	}
+}
+
+func TestSpeak_Source(t *testing.T) {
+	t.Parallel()
+
+	decoded := audiotest.NewSilentSource(24000, 1, 100)
+	engine := SynthesizerFunc(func(context.Context, Request) (*Synthesis, error) {
+		return SourceSynthesis(decoded), nil
+	})
+
+	src, err := Speak(context.Background(), engine, Request{Text: "hi"})
+	if err != nil {
+		t.Fatalf("Speak() error = %v", err)
+	}
+	if src != audio.Source(decoded) {
+		t.Error("Speak() did not return the engine source")
+	}
+}
+
+func TestSpeak_Errors(t *testing.T) {
+	t.Parallel()
+
+	errEngine := errors.New("quota exceeded")
+	body := &closeTracker{Reader: bytes.NewReader(nil)}
+
+	tests := []struct {
+		name    string
+		text    string
+		syn     *Synthesis
+		err     error
+		wantErr error
+	}{
+		{name: "empty text", text: "  ", wantErr: ErrEmptyText},
+		{name: "engine error", text: "hi", err: errEngine, wantErr: errEngine},
+		{name: "nil synthesis", text: "hi", wantErr: ErrNoAudio},
+		{name: "no body", text: "hi", syn: &Synthesis{Rate: 8000, Channels: 1, Encoding: pcm.S16LE}, wantErr: ErrNoAudio},
+		{name: "bad encoding", text: "hi", syn: PCMSynthesis(body, 8000, 1, pcm.EncodingUnknown), wantErr: pcm.ErrUnknownEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := SynthesizerFunc(func(context.Context, Request) (*Synthesis, error) {
+				return tt.syn, tt.err
+			})
+			if _, err := Speak(context.Background(), engine, Request{Text: tt.text}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Speak() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if !body.closed {
+		t.Error("engine stream not closed after a rejected synthesis")
+	}
+}
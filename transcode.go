@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/formats/wav"
+)
+
+// maxOutputFrames is the most 16-bit mono frames a WAV file can record the
+// size of
+const maxOutputFrames = (wav.UnknownSize - 36) / 2
+
+// headFrames is the start of the source whose checksum a checkpoint keeps
+// to recognize its input
+const headFrames = 48000
+
+// Checkpoint records how far a resumable transcode got. It is stored as
+// JSON next to the output.
+type Checkpoint struct {
+	// SourceRate, SourceChannels and TargetRate identify the job, so a
+	// checkpoint is never applied to a different setting.
+	SourceRate     int `json:"source_rate"`
+	SourceChannels int `json:"source_channels"`
+	TargetRate     int `json:"target_rate"`
+	// SourceFrames, the length of a source implementing audio.Lengther or
+	// -1, and SourceHead, a CRC-32 of the bits of its samples up to the
+	// first 48000 frames, identify the input. Inputs of the same length
+	// that start alike are taken for the same one.
+	SourceFrames int64  `json:"source_frames"`
+	SourceHead   uint32 `json:"source_head"`
+	// InputFrames is the number of frames consumed from the source.
+	InputFrames int64 `json:"input_frames"`
+	// OutputFrames is the number of mono frames written to the output.
+	OutputFrames int64 `json:"output_frames"`
+	// ResamplerState is the serialized state of the resampler.
+	ResamplerState []byte `json:"resampler_state"`
+}
+
+// TranscodeOptions configures TranscodeResumable.
+type TranscodeOptions struct {
+	// CheckpointPath is the checkpoint file. When it exists the job resumes
+	// from it; it is removed once the job completes. Empty disables
+	// checkpointing.
+	CheckpointPath string
+	// CheckpointInterval is the number of output frames between
+	// checkpoints. Defaults to 60 seconds of output.
+	CheckpointInterval int64
+	// BufferSize is the read buffer size in samples. Defaults to 4096.
+	BufferSize int
+}
+
+// OutputFile is the destination of a resumable transcode. *os.File
+// implements it.
+type OutputFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+}
+
+// TranscodeResumable resamples src to a mono 16-bit PCM WAV at targetRate
+// written to dst, like ResampleToMono16 followed by wav.WriteWAV16, but
+// streaming and with periodic checkpoints.
+//
+// If the job is interrupted (crash, kill, I/O error) run it again with a
+// freshly opened src from its beginning and the same dst and checkpoint
+// path. A checkpoint of another input or target rate fails with
+// ErrCheckpointMismatch; otherwise the source is skipped up to the checkpointed offset, the resampler
+// state is restored and dst is truncated to the checkpointed length, so the
+// finished file is identical to one made in a single run. Skipping still
+// decodes the skipped audio, but does not resample or write it.
+//
+// Outputs past 4 GiB, whose size a WAV header cannot hold, fail with
+// ErrOutputTooLarge as soon as they reach it.
+func TranscodeResumable(src audio.Source, dst OutputFile, targetRate int, opts TranscodeOptions) error {
+	if err := audio.FormatOf(src).Validate(); err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	if targetRate <= 0 {
+		return fmt.Errorf("target: %w: %d Hz", audio.ErrInvalidSampleRate, targetRate)
+	}
+	if opts.CheckpointInterval <= 0 {
+		opts.CheckpointInterval = int64(targetRate) * 60
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 4096
+	}
+
+	cp := Checkpoint{
+		SourceRate:     src.SampleRate(),
+		SourceChannels: src.Channels(),
+		TargetRate:     targetRate,
+		SourceFrames:   -1,
+	}
+	if l, ok := src.(audio.Lengther); ok {
+		cp.SourceFrames = l.TotalFrames()
+	}
+
+	counter := &countingSource{Source: src}
+	resampler := audio.NewResampler(counter, targetRate)
+	mono := audio.NewMonoMixer(resampler)
+
+	saved, err := loadCheckpoint(opts.CheckpointPath)
+	if err != nil {
+		return err
+	}
+	var out *wav.Writer
+	if saved != nil {
+		out, err = resume(saved, &cp, counter, resampler, dst)
+	} else {
+		out, err = startOutput(dst, targetRate)
+	}
+	if err != nil {
+		return err
+	}
+
+	buf := make([]float32, opts.BufferSize)
+	sinceCheckpoint := int64(0)
+
+	for {
+		n, err := mono.ReadSamples(buf)
+		if n > 0 {
+			if cp.OutputFrames+int64(n) > maxOutputFrames {
+				return fmt.Errorf("%w: over %d frames", ErrOutputTooLarge, int64(maxOutputFrames))
+			}
+			if werr := out.WriteSamples(buf[:n]); werr != nil {
+				return fmt.Errorf("writing output: %w", werr)
+			}
+			cp.OutputFrames += int64(n)
+			sinceCheckpoint += int64(n)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if opts.CheckpointPath != "" && sinceCheckpoint >= opts.CheckpointInterval {
+			if err := saveCheckpoint(opts.CheckpointPath, &cp, counter, resampler, dst); err != nil {
+				return err
+			}
+			sinceCheckpoint = 0
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+
+	if opts.CheckpointPath != "" {
+		if err := os.Remove(opts.CheckpointPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("removing checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// resume brings the pipeline and the output to the state of saved, and
+// returns a writer appending to the output
+func resume(saved, cp *Checkpoint, counter *countingSource, resampler *audio.Resampler, dst OutputFile) (*wav.Writer, error) {
+	if saved.SourceRate != cp.SourceRate || saved.SourceChannels != cp.SourceChannels ||
+		saved.TargetRate != cp.TargetRate {
+		return nil, fmt.Errorf("%w: %d Hz %dch to %d Hz, job is %d Hz %dch to %d Hz", ErrCheckpointMismatch,
+			saved.SourceRate, saved.SourceChannels, saved.TargetRate,
+			cp.SourceRate, cp.SourceChannels, cp.TargetRate)
+	}
+
+	if err := counter.skip(saved.InputFrames); err != nil {
+		return nil, err
+	}
+	if saved.SourceFrames != cp.SourceFrames || saved.SourceHead != counter.head {
+		return nil, fmt.Errorf("%w: a different input of the same format", ErrCheckpointMismatch)
+	}
+	if err := resampler.UnmarshalBinary(saved.ResamplerState); err != nil {
+		return nil, fmt.Errorf("restoring resampler: %w", err)
+	}
+
+	end := wav.HeaderSize + saved.OutputFrames*2
+	if err := dst.Truncate(end); err != nil {
+		return nil, fmt.Errorf("truncating output: %w", err)
+	}
+	if _, err := dst.Seek(end, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking output: %w", err)
+	}
+	out, err := wav.NewWriter(dst, audio.Format{Rate: cp.TargetRate, Channels: 1}, wav.WithAppend(saved.OutputFrames))
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	*cp = *saved
+	return out, nil
+}
+
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCheckpointMismatch, err)
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint makes the output durable and then atomically replaces the
+// checkpoint file, so a checkpoint never points past data that was lost
+func saveCheckpoint(path string, cp *Checkpoint, counter *countingSource, resampler *audio.Resampler, dst OutputFile) error {
+	state, err := resampler.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	cp.InputFrames = counter.frames
+	cp.SourceHead = counter.head
+	cp.ResamplerState = state
+
+	if s, ok := dst.(interface{ Sync() error }); ok {
+		if err := s.Sync(); err != nil {
+			return fmt.Errorf("syncing output: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// startOutput starts the output over with a WAV header, whose sizes the
+// Close of the writer returned fills in
+func startOutput(dst OutputFile, rate int) (*wav.Writer, error) {
+	if err := dst.Truncate(0); err != nil {
+		return nil, fmt.Errorf("truncating output: %w", err)
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking output: %w", err)
+	}
+
+	out, err := wav.NewWriter(dst, audio.Format{Rate: rate, Channels: 1})
+	if err != nil {
+		return nil, fmt.Errorf("writing output: %w", err)
+	}
+	return out, nil
+}
+
+// countingSource counts the frames read through it and takes the checksum
+// of the first headFrames of them
+type countingSource struct {
+	audio.Source
+	frames int64
+	head   uint32
+}
+
+func (c *countingSource) ReadSamples(dst []float32) (int, error) {
+	n, err := c.Source.ReadSamples(dst)
+	ch := c.Source.Channels()
+	if c.frames < headFrames {
+		var b [4]byte
+		for _, v := range dst[:min(n, int(headFrames-c.frames)*ch)] {
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(v))
+			c.head = crc32.Update(c.head, crc32.IEEETable, b[:])
+		}
+	}
+	c.frames += int64(n / ch)
+	return n, err
+}
+
+// skip reads and discards frames from the source
+func (c *countingSource) skip(frames int64) error {
+	ch := c.Source.Channels()
+	buf := make([]float32, 4096-4096%ch)
+
+	for c.frames < frames {
+		want := min(int64(len(buf)), (frames-c.frames)*int64(ch))
+		n, err := c.ReadSamples(buf[:want])
+		if c.frames >= frames {
+			return nil
+		}
+		if err == io.EOF || (err == nil && n == 0) {
+			return fmt.Errorf("%w: %d of %d frames", ErrSourceTooShort, c.frames, frames)
+		}
+		if err != nil {
+			return fmt.Errorf("skipping to checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package audpbx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ik5/audpbx/audio"
+	"github.com/ik5/audpbx/audio/audiotest"
+	"github.com/ik5/audpbx/formats/wav"
+)
+
+func transcodeSource() *audiotest.MockSource {
+	return audiotest.NewSineSource(44100, 2, 44100*2, 440)
+}
+
+func TestTranscodeResumable_MatchesSingleRun(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	// Reference: one uninterrupted run
+	ref, err := os.Create(filepath.Join(dir, "ref.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := TranscodeResumable(transcodeSource(), ref, 8000, TranscodeOptions{}); err != nil {
+		t.Fatalf("TranscodeResumable() error = %v", err)
+	}
+	ref.Close()
+
+	// Interrupted twice, then completed
+	outPath := filepath.Join(dir, "out.wav")
+	cpPath := filepath.Join(dir, "out.checkpoint")
+	opts := TranscodeOptions{CheckpointPath: cpPath, CheckpointInterval: 1000, BufferSize: 333}
+
+	for _, failAt := range []int{20000, 45000} {
+		out, err := os.OpenFile(outPath, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := audiotest.NewFaultySource(transcodeSource(), audiotest.WithErrorOnCall(failAt, audiotest.ErrInjected))
+		err = TranscodeResumable(src, out, 8000, opts)
+		out.Close()
+		if !errors.Is(err, audiotest.ErrInjected) {
+			t.Fatalf("interrupted run error = %v, want %v", err, audiotest.ErrInjected)
+		}
+		if _, err := os.Stat(cpPath); err != nil {
+			t.Fatalf("no checkpoint after interruption: %v", err)
+		}
+	}
+
+	out, err := os.OpenFile(outPath, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := TranscodeResumable(transcodeSource(), out, 8000, opts); err != nil {
+		t.Fatalf("resumed run error = %v", err)
+	}
+	out.Close()
+
+	want, _ := os.ReadFile(filepath.Join(dir, "ref.wav"))
+	got, _ := os.ReadFile(outPath)
+	if !bytes.Equal(got, want) {
+		t.Errorf("resumed output (%d bytes) differs from a single run (%d bytes)", len(got), len(want))
+	}
+	if _, err := os.Stat(cpPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint not removed after completion: %v", err)
+	}
+
+	src, err := wav.Decoder{}.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if src.SampleRate() != 8000 || src.Channels() != 1 {
+		t.Errorf("output is %d Hz %dch, want 8000 Hz 1ch", src.SampleRate(), src.Channels())
+	}
+}
+
+// nullFile is an OutputFile that keeps nothing, standing in for outputs
+// too large to write in a test
+type nullFile struct {
+	pos, size int64
+}
+
+func (f *nullFile) Write(p []byte) (int, error) {
+	f.pos += int64(len(p))
+	f.size = max(f.size, f.pos)
+	return len(p), nil
+}
+
+func (f *nullFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *nullFile) Truncate(size int64) error {
+	f.size = size
+	return nil
+}
+
+func TestTranscodeResumable_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cpPath := filepath.Join(dir, "job.checkpoint")
+	src := audiotest.NewFaultySource(transcodeSource(), audiotest.WithErrorOnCall(10000, audiotest.ErrInjected))
+	opts := TranscodeOptions{CheckpointPath: cpPath, CheckpointInterval: 500, BufferSize: 256}
+	if err := TranscodeResumable(src, &nullFile{}, 8000, opts); !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("TranscodeResumable() error = %v, want %v", err, audiotest.ErrInjected)
+	}
+
+	// Resume as if the output were just short of 4 GiB
+	data, err := os.ReadFile(cpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		t.Fatal(err)
+	}
+	cp.OutputFrames = maxOutputFrames - 100
+	if data, err = json.Marshal(cp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cpPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &nullFile{}
+	if err := TranscodeResumable(transcodeSource(), out, 8000, opts); !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("TranscodeResumable() error = %v, want ErrOutputTooLarge", err)
+	}
+	if out.size > wav.HeaderSize+2*maxOutputFrames {
+		t.Errorf("output grew to %d bytes, past the WAV limit", out.size)
+	}
+}
+
+func TestTranscodeResumable_Errors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cpPath := filepath.Join(dir, "job.checkpoint")
+	out, err := os.Create(filepath.Join(dir, "job.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	// Leave a checkpoint behind
+	src := audiotest.NewFaultySource(transcodeSource(), audiotest.WithErrorOnCall(10000, audiotest.ErrInjected))
+	opts := TranscodeOptions{CheckpointPath: cpPath, CheckpointInterval: 500, BufferSize: 256}
+	if err := TranscodeResumable(src, out, 8000, opts); !errors.Is(err, audiotest.ErrInjected) {
+		t.Fatalf("TranscodeResumable() error = %v, want %v", err, audiotest.ErrInjected)
+	}
+
+	tests := []struct {
+		name    string
+		src     audio.Source
+		rate    int
+		wantErr error
+	}{
+		{name: "different target rate", src: transcodeSource(), rate: 16000, wantErr: ErrCheckpointMismatch},
+		{name: "different source", src: audiotest.NewSineSource(48000, 2, 48000, 440), rate: 8000, wantErr: ErrCheckpointMismatch},
+		{name: "different source of the same format", src: audiotest.NewSineSource(44100, 2, 44100*2, 1000), rate: 8000, wantErr: ErrCheckpointMismatch},
+		{name: "source shorter than offset", src: audiotest.NewSineSource(44100, 2, 100, 440), rate: 8000, wantErr: ErrSourceTooShort},
+		{name: "invalid target", src: transcodeSource(), rate: 0, wantErr: audio.ErrInvalidSampleRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := TranscodeResumable(tt.src, out, tt.rate, opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("TranscodeResumable() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package utils
+
+import (
+	"math"
+	"math/bits"
+)
+
+// IsPowerOfTwo reports whether n is a positive power of two.
+func IsPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// NextPowerOfTwo returns the smallest power of two that is >= n.
+func NextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// FFT computes the discrete Fourier transform of x in place using an
+// iterative radix-2 Cooley-Tukey algorithm. len(x) must be a power of two;
+// other lengths panic.
+func FFT(x []complex128) {
+	fft(x, false)
+}
+
+// IFFT computes the inverse discrete Fourier transform of x in place,
+// including the 1/N scaling, so IFFT(FFT(x)) == x.
+func IFFT(x []complex128) {
+	fft(x, true)
+
+	scale := 1 / float64(len(x))
+	for i := range x {
+		x[i] = complex(real(x[i])*scale, imag(x[i])*scale)
+	}
+}
+
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	if n <= 1 {
+		return
+	}
+	if !IsPowerOfTwo(n) {
+		panic("utils: FFT length must be a power of two")
+	}
+
+	// Bit reversal permutation
+	shift := bits.UintSize - bits.TrailingZeros(uint(n))
+	for i := range n {
+		j := int(bits.Reverse(uint(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		half := size >> 1
+		step := sign * 2 * math.Pi / float64(size)
+		wStep := complex(math.Cos(step), math.Sin(step))
+
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := range half {
+				a := x[start+k]
+				b := x[start+k+half] * w
+				x[start+k] = a + b
+				x[start+k+half] = a - b
+				w *= wStep
+			}
+		}
+	}
+}
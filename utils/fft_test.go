@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: EPL-2.0
+
+package utils
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// naiveDFT is the O(n^2) reference transform
+func naiveDFT(x []complex128) []complex128 {
+	n := len(x)
+	out := make([]complex128, n)
+	for k := range n {
+		for t := range n {
+			angle := -2 * math.Pi * float64(k*t) / float64(n)
+			out[k] += x[t] * cmplx.Exp(complex(0, angle))
+		}
+	}
+	return out
+}
+
+func TestFFT_MatchesNaiveDFT(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{1, 2, 8, 64} {
+		x := make([]complex128, n)
+		for i := range x {
+			x[i] = complex(math.Sin(float64(i)*0.3)+0.1*float64(i%3), 0)
+		}
+
+		want := naiveDFT(x)
+		got := append([]complex128(nil), x...)
+		FFT(got)
+
+		for k := range n {
+			if cmplx.Abs(got[k]-want[k]) > 1e-9 {
+				t.Errorf("n=%d: FFT()[%d] = %v, want %v", n, k, got[k], want[k])
+			}
+		}
+	}
+}
+
+func TestIFFT_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	x := make([]complex128, 128)
+	for i := range x {
+		x[i] = complex(float64(i%7)-3, float64(i%5))
+	}
+
+	y := append([]complex128(nil), x...)
+	FFT(y)
+	IFFT(y)
+
+	for i := range x {
+		if cmplx.Abs(x[i]-y[i]) > 1e-9 {
+			t.Fatalf("IFFT(FFT(x))[%d] = %v, want %v", i, y[i], x[i])
+		}
+	}
+}
+
+func TestFFT_PanicsOnInvalidLength(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("FFT() with length 6 did not panic")
+		}
+	}()
+
+	FFT(make([]complex128, 6))
+}
+
+func TestNextPowerOfTwo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct{ in, want int }{
+		{0, 1}, {1, 1}, {2, 2}, {3, 4}, {255, 256}, {256, 256}, {257, 512},
+	}
+
+	for _, tt := range tests {
+		if got := NextPowerOfTwo(tt.in); got != tt.want {
+			t.Errorf("NextPowerOfTwo(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkFFT_1024 benchmarks a 1024 point transform
+func BenchmarkFFT_1024(b *testing.B) {
+	x := make([]complex128, 1024)
+	b.ReportAllocs()
+
+	for b.Loop() {
+		FFT(x)
+	}
+}
@@ -5,15 +5,14 @@ package utils
 func Float32ToInt16(x float32) int16 {
 	const maxInt16 float32 = 32768.0 // 2^15 -> +32767
 
-	// Clamp and scale
-	if x > 1 {
-		x = 1
+	// Clamp and scale; 1.0 would scale to 32768, past the int16 range
+	if x >= 1 {
+		return 32767
 	}
 
 	if x < -1 {
 		x = -1
 	}
 
-	// Use 32767 for positive max to avoid overflow
 	return int16(x * maxInt16)
 }
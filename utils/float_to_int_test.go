@@ -83,7 +83,7 @@ func TestFloat32ToInt16(t *testing.T) {
 
 			got := Float32ToInt16(tt.input)
 			// Allow for rounding differences of ±1
-			diff := int16(math.Abs(float64(got - tt.want)))
+			diff := math.Abs(float64(got) - float64(tt.want))
 
 			if diff > 1 {
 				t.Errorf("Float32ToInt16(%v) = %v, want %v (diff %v)",